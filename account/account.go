@@ -0,0 +1,164 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 组合敞口快照：汇总账户余额与全部持仓，计算按币种与整体的多空敞口、保证金率和未实现盈亏。
+//
+// 币安合约直接读取 /fapi/v2/account 与 /fapi/v2/positionRisk（含维持保证金，可计算保证金率）；
+// 其他交易所通过交易器的 GetBalance/GetPositions 计算（没有维持保证金时保证金率为 0）。
+// 快照既可在代码中直接使用，也会写入 AI 决策的用户提示词。
+
+// Balance 账户余额（USDT）
+type Balance struct {
+	WalletBalance    float64 `json:"wallet_balance"`    // 钱包余额
+	MarginBalance    float64 `json:"margin_balance"`    // 保证金余额（钱包余额 + 未实现盈亏）
+	AvailableBalance float64 `json:"available_balance"` // 可用余额
+	UnrealizedPnL    float64 `json:"unrealized_pnl"`    // 未实现盈亏
+	InitialMargin    float64 `json:"initial_margin"`    // 已占用的起始保证金（含挂单）
+	MaintMargin      float64 `json:"maint_margin"`      // 维持保证金（未知时为 0）
+}
+
+// Position 单个持仓
+type Position struct {
+	Symbol           string  `json:"symbol"`
+	Side             string  `json:"side"`     // long/short
+	Quantity         float64 `json:"quantity"` // 数量（绝对值）
+	EntryPrice       float64 `json:"entry_price"`
+	MarkPrice        float64 `json:"mark_price"`
+	Notional         float64 `json:"notional"` // 名义价值（数量 × 标记价）
+	UnrealizedPnL    float64 `json:"unrealized_pnl"`
+	Leverage         float64 `json:"leverage"`
+	LiquidationPrice float64 `json:"liquidation_price"`
+	IsolatedMargin   float64 `json:"isolated_margin,omitempty"` // 逐仓保证金（全仓为 0）
+}
+
+// SymbolExposure 单个币种的敞口（双向持仓时多空合并）
+type SymbolExposure struct {
+	Symbol        string     `json:"symbol"`
+	LongNotional  float64    `json:"long_notional"`
+	ShortNotional float64    `json:"short_notional"`
+	NetNotional   float64    `json:"net_notional"` // 多 - 空
+	UnrealizedPnL float64    `json:"unrealized_pnl"`
+	EquityPct     float64    `json:"equity_pct"` // 总名义价值占保证金余额的百分比
+	Positions     []Position `json:"positions"`
+}
+
+// Snapshot 组合敞口快照
+type Snapshot struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"` // binance / trader
+	Balance Balance   `json:"balance"`
+
+	LongExposure  float64 `json:"long_exposure"`  // 多头名义价值合计
+	ShortExposure float64 `json:"short_exposure"` // 空头名义价值合计
+	GrossExposure float64 `json:"gross_exposure"` // 多 + 空
+	NetExposure   float64 `json:"net_exposure"`   // 多 - 空
+	Leverage      float64 `json:"leverage"`       // 实际杠杆（总敞口 / 保证金余额）
+	MarginRatio   float64 `json:"margin_ratio"`   // 保证金率（维持保证金 / 保证金余额，百分比；达到 100% 触发强平）
+	MarginUsedPct float64 `json:"margin_used_pct"`
+
+	Symbols []SymbolExposure `json:"symbols"` // 按总名义价值从大到小
+}
+
+// Source 敞口快照来源
+type Source interface {
+	Snapshot(ctx context.Context) (*Snapshot, error)
+}
+
+// Compute 由余额与持仓计算快照（持仓名义价值为 0 时按数量 × 标记价补齐）
+func Compute(source string, bal Balance, positions []Position) *Snapshot {
+	if bal.MarginBalance == 0 {
+		bal.MarginBalance = bal.WalletBalance + bal.UnrealizedPnL
+	}
+	s := &Snapshot{Time: time.Now(), Source: source, Balance: bal}
+
+	bySymbol := make(map[string]*SymbolExposure)
+	for _, p := range positions {
+		if p.Quantity == 0 {
+			continue
+		}
+		p.Quantity = math.Abs(p.Quantity)
+		p.Side = strings.ToLower(p.Side)
+		if p.Notional == 0 {
+			p.Notional = p.Quantity * p.MarkPrice
+		}
+		p.Notional = math.Abs(p.Notional)
+
+		e := bySymbol[p.Symbol]
+		if e == nil {
+			e = &SymbolExposure{Symbol: p.Symbol}
+			bySymbol[p.Symbol] = e
+		}
+		if p.Side == "short" {
+			e.ShortNotional += p.Notional
+			s.ShortExposure += p.Notional
+		} else {
+			e.LongNotional += p.Notional
+			s.LongExposure += p.Notional
+		}
+		e.UnrealizedPnL += p.UnrealizedPnL
+		e.Positions = append(e.Positions, p)
+	}
+
+	s.GrossExposure = s.LongExposure + s.ShortExposure
+	s.NetExposure = s.LongExposure - s.ShortExposure
+	equity := s.Balance.MarginBalance
+	if equity > 0 {
+		s.Leverage = s.GrossExposure / equity
+		s.MarginRatio = s.Balance.MaintMargin / equity * 100
+		s.MarginUsedPct = s.Balance.InitialMargin / equity * 100
+	}
+
+	s.Symbols = make([]SymbolExposure, 0, len(bySymbol))
+	for _, e := range bySymbol {
+		e.NetNotional = e.LongNotional - e.ShortNotional
+		if equity > 0 {
+			e.EquityPct = (e.LongNotional + e.ShortNotional) / equity * 100
+		}
+		s.Symbols = append(s.Symbols, *e)
+	}
+	sort.Slice(s.Symbols, func(i, j int) bool {
+		gi := s.Symbols[i].LongNotional + s.Symbols[i].ShortNotional
+		gj := s.Symbols[j].LongNotional + s.Symbols[j].ShortNotional
+		if gi != gj {
+			return gi > gj
+		}
+		return s.Symbols[i].Symbol < s.Symbols[j].Symbol
+	})
+	return s
+}
+
+// Symbol 返回币种的敞口（无持仓返回 false）
+func (s *Snapshot) Symbol(symbol string) (SymbolExposure, bool) {
+	for _, e := range s.Symbols {
+		if e.Symbol == symbol {
+			return e, true
+		}
+	}
+	return SymbolExposure{}, false
+}
+
+// Format 格式化为提示词中的组合敞口段落
+func (s *Snapshot) Format() string {
+	var b strings.Builder
+	b.WriteString("## 组合敞口\n")
+	fmt.Fprintf(&b, "多头%.2f | 空头%.2f | 净敞口%+.2f | 总敞口%.2f (实际杠杆%.2fx) | 未实现盈亏%+.2f | 可用余额%.2f",
+		s.LongExposure, s.ShortExposure, s.NetExposure, s.GrossExposure, s.Leverage,
+		s.Balance.UnrealizedPnL, s.Balance.AvailableBalance)
+	if s.Balance.MaintMargin > 0 {
+		fmt.Fprintf(&b, " | 保证金率%.2f%%", s.MarginRatio)
+	}
+	b.WriteString("\n")
+	for _, e := range s.Symbols {
+		fmt.Fprintf(&b, "- %s: 净%+.2f (多%.2f/空%.2f) | 占净值%.1f%% | 盈亏%+.2f\n",
+			e.Symbol, e.NetNotional, e.LongNotional, e.ShortNotional, e.EquityPct, e.UnrealizedPnL)
+	}
+	return b.String()
+}
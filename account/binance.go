@@ -0,0 +1,71 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// Binance 从币安合约账户接口读取快照（/fapi/v2/account 与 /fapi/v2/positionRisk，权重各 5）
+type Binance struct {
+	client *futures.Client
+}
+
+// NewBinance 使用已有的合约客户端（与交易器共用时间同步与代理设置）
+func NewBinance(client *futures.Client) *Binance {
+	return &Binance{client: client}
+}
+
+// Snapshot 实现 Source
+func (b *Binance) Snapshot(ctx context.Context) (*Snapshot, error) {
+	acc, err := b.client.NewGetAccountService().Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取合约账户失败: %w", err)
+	}
+	risks, err := b.client.NewGetPositionRiskService().Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓风险失败: %w", err)
+	}
+
+	bal := Balance{
+		WalletBalance:    parseFloat(acc.TotalWalletBalance),
+		MarginBalance:    parseFloat(acc.TotalMarginBalance),
+		AvailableBalance: parseFloat(acc.AvailableBalance),
+		UnrealizedPnL:    parseFloat(acc.TotalUnrealizedProfit),
+		InitialMargin:    parseFloat(acc.TotalInitialMargin),
+		MaintMargin:      parseFloat(acc.TotalMaintMargin),
+	}
+
+	var positions []Position
+	for _, r := range risks {
+		amt := parseFloat(r.PositionAmt)
+		if amt == 0 {
+			continue
+		}
+		side := "long"
+		// 双向持仓以 positionSide 为准，单向持仓按数量正负
+		if r.PositionSide == string(futures.PositionSideTypeShort) || (r.PositionSide != string(futures.PositionSideTypeLong) && amt < 0) {
+			side = "short"
+		}
+		positions = append(positions, Position{
+			Symbol:           r.Symbol,
+			Side:             side,
+			Quantity:         amt,
+			EntryPrice:       parseFloat(r.EntryPrice),
+			MarkPrice:        parseFloat(r.MarkPrice),
+			Notional:         parseFloat(r.Notional),
+			UnrealizedPnL:    parseFloat(r.UnRealizedProfit),
+			Leverage:         parseFloat(r.Leverage),
+			LiquidationPrice: parseFloat(r.LiquidationPrice),
+			IsolatedMargin:   parseFloat(r.IsolatedMargin),
+		})
+	}
+	return Compute("binance", bal, positions), nil
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
@@ -0,0 +1,115 @@
+package account
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Delta 中性对冲建议：按各持仓相对基准（BTC/ETH 永续）的 beta 折算组合的 beta 敞口，
+// 给出使组合 beta 归零所需的基准合约名义价值与数量。只是建议，不会自动下单。
+//
+//	beta 敞口 = Σ 净名义价值_i × beta_i（基准自身 beta 为 1）
+//	对冲名义价值 = -beta 敞口（负数表示做空基准）
+
+// HedgeBenchmarks 默认的对冲基准
+var HedgeBenchmarks = []string{"BTCUSDT", "ETHUSDT"}
+
+// hedgeMinNotional 对冲名义价值低于该值时视为无需对冲（USDT）
+const hedgeMinNotional = 10
+
+// BetaFunc 返回 symbol 相对 benchmark 的 beta 与相关系数（无法计算时返回 false）
+type BetaFunc func(symbol, benchmark string) (beta, correlation float64, ok bool)
+
+// HedgeLeg 单个币种对组合 beta 的贡献
+type HedgeLeg struct {
+	Symbol       string  `json:"symbol"`
+	NetNotional  float64 `json:"net_notional"`
+	Beta         float64 `json:"beta"`
+	Correlation  float64 `json:"correlation"`
+	BetaNotional float64 `json:"beta_notional"` // 净名义价值 × beta
+}
+
+// HedgeSuggestion 以某个基准对冲组合 beta 的建议
+type HedgeSuggestion struct {
+	Benchmark     string     `json:"benchmark"`
+	BetaExposure  float64    `json:"beta_exposure"`  // 组合折算到基准的名义价值（正为净多）
+	HedgeNotional float64    `json:"hedge_notional"` // 建议对冲名义价值（负为做空基准）
+	Side          string     `json:"side"`           // short/long，无需对冲时为空
+	Price         float64    `json:"price"`          // 基准价格
+	Quantity      float64    `json:"quantity"`       // 基准合约数量（绝对值，价格未知时为 0）
+	Coverage      float64    `json:"coverage"`       // 有 beta 数据的名义价值占总名义价值的比例（0~1）
+	Legs          []HedgeLeg `json:"legs"`
+	Unhedged      []string   `json:"unhedged,omitempty"` // 缺少 beta 数据、未计入的币种
+}
+
+// SuggestHedge 计算以 benchmark 对冲组合 beta 的建议；price 为基准当前价格
+func SuggestHedge(s *Snapshot, benchmark string, price float64, beta BetaFunc) *HedgeSuggestion {
+	h := &HedgeSuggestion{Benchmark: benchmark, Price: price, Legs: []HedgeLeg{}}
+	var gross, covered float64
+	for _, e := range s.Symbols {
+		if e.NetNotional == 0 {
+			continue
+		}
+		g := math.Abs(e.NetNotional)
+		gross += g
+		leg := HedgeLeg{Symbol: e.Symbol, NetNotional: e.NetNotional, Beta: 1, Correlation: 1}
+		if e.Symbol != benchmark {
+			b, corr, ok := beta(e.Symbol, benchmark)
+			if !ok {
+				h.Unhedged = append(h.Unhedged, e.Symbol)
+				continue
+			}
+			leg.Beta, leg.Correlation = b, corr
+		}
+		leg.BetaNotional = leg.NetNotional * leg.Beta
+		h.BetaExposure += leg.BetaNotional
+		covered += g
+		h.Legs = append(h.Legs, leg)
+	}
+	if gross > 0 {
+		h.Coverage = covered / gross
+	}
+	if math.Abs(h.BetaExposure) < hedgeMinNotional {
+		return h
+	}
+	h.HedgeNotional = -h.BetaExposure
+	h.Side = "long"
+	if h.HedgeNotional < 0 {
+		h.Side = "short"
+	}
+	if price > 0 {
+		h.Quantity = math.Abs(h.HedgeNotional) / price
+	}
+	return h
+}
+
+// FormatHedges 格式化为提示词中的对冲建议段落（均无需对冲时返回空字符串）
+func FormatHedges(hedges []*HedgeSuggestion) string {
+	var b strings.Builder
+	for _, h := range hedges {
+		if h.Side == "" {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString("## Delta中性对冲建议（按beta折算，各基准任选其一，仅供参考）\n")
+		}
+		fmt.Fprintf(&b, "- %s: 组合beta敞口%+.2f，建议%s %.2f USDT", h.Benchmark, h.BetaExposure, hedgeSideText(h.Side), math.Abs(h.HedgeNotional))
+		if h.Quantity > 0 {
+			fmt.Fprintf(&b, " (约%.4f个)", h.Quantity)
+		}
+		fmt.Fprintf(&b, " | 覆盖%.0f%%", h.Coverage*100)
+		if len(h.Unhedged) > 0 {
+			fmt.Fprintf(&b, " | 缺少beta: %s", strings.Join(h.Unhedged, ","))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func hedgeSideText(side string) string {
+	if side == "short" {
+		return "做空"
+	}
+	return "做多"
+}
@@ -0,0 +1,76 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Trader 提供余额与持仓的交易器（trader.Trader 满足该接口）
+type Trader interface {
+	GetBalance() (map[string]interface{}, error)
+	GetPositions() ([]map[string]interface{}, error)
+}
+
+// traderSource 由交易器的余额与持仓计算快照（字段名与 trader 包各交易所的返回一致）
+type traderSource struct {
+	t Trader
+}
+
+// FromTrader 使用交易器作为快照来源（没有维持保证金，保证金率为 0）
+func FromTrader(t Trader) Source {
+	return &traderSource{t: t}
+}
+
+// Snapshot 实现 Source
+func (s *traderSource) Snapshot(ctx context.Context) (*Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	balance, err := s.t.GetBalance()
+	if err != nil {
+		return nil, fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	rawPositions, err := s.t.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+
+	bal := Balance{
+		WalletBalance:    number(balance["totalWalletBalance"]),
+		AvailableBalance: number(balance["availableBalance"]),
+		UnrealizedPnL:    number(balance["totalUnrealizedProfit"]),
+	}
+	positions := make([]Position, 0, len(rawPositions))
+	for _, p := range rawPositions {
+		pos := Position{
+			Symbol:           fmt.Sprint(p["symbol"]),
+			Side:             fmt.Sprint(p["side"]),
+			Quantity:         number(p["positionAmt"]),
+			EntryPrice:       number(p["entryPrice"]),
+			MarkPrice:        number(p["markPrice"]),
+			UnrealizedPnL:    number(p["unRealizedProfit"]),
+			Leverage:         number(p["leverage"]),
+			LiquidationPrice: number(p["liquidationPrice"]),
+		}
+		// 交易器不返回保证金时按 名义价值 / 杠杆 估算已用保证金
+		if pos.Leverage > 0 {
+			bal.InitialMargin += math.Abs(pos.Quantity) * pos.MarkPrice / pos.Leverage
+		}
+		positions = append(positions, pos)
+	}
+	return Compute("trader", bal, positions), nil
+}
+
+// number 读取数值字段（缺失或类型不符时为 0）
+func number(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
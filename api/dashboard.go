@@ -0,0 +1,474 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+	"nofx/trader"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 看板接口：把交易员、持仓、最近决策、对账不一致数、AI 用量/费用与行情快照汇总为 JSON，
+// 前端无需解析日志即可展示。挂在受保护路由组下（Authorization: Bearer <JWT>），只返回当前用户的交易员。
+
+const (
+	dashboardDefaultDecisions = 20
+	dashboardMaxDecisions     = 100
+	dashboardMaxSymbols       = 10
+)
+
+// defaultReconcileReportDir 对账工具 -report_format json 的默认报告目录
+var defaultReconcileReportDir = filepath.Join("tools", "log_reconcile", "reports")
+
+// SetReconcileReportDir 设置对账报告目录（空字符串恢复默认）
+func (s *Server) SetReconcileReportDir(dir string) {
+	s.reconcileReportDir = dir
+}
+
+// registerDashboardRoutes 注册看板接口
+func (s *Server) registerDashboardRoutes(r *gin.RouterGroup) {
+	d := r.Group("/dashboard")
+	d.GET("/traders", s.handleDashboardTraders)
+	d.GET("/positions", s.handleDashboardPositions)
+	d.GET("/decisions", s.handleDashboardDecisions)
+	d.GET("/reconcile", s.handleDashboardReconcile)
+	d.GET("/ai-usage", s.handleDashboardAIUsage)
+	d.GET("/market", s.handleDashboardMarket)
+	d.GET("/hedge", s.handleDashboardHedge)
+	d.GET("/calibration", s.handleDashboardCalibration)
+	d.GET("/experiments", s.handleDashboardExperiments)
+}
+
+// dashboardTrader 当前用户在内存中的交易员
+type dashboardTrader struct {
+	id     string
+	name   string
+	trader *trader.AutoTrader
+}
+
+// userTraders 加载并返回当前用户的交易员（trader_id 参数可限定为单个交易员）
+func (s *Server) userTraders(c *gin.Context) ([]dashboardTrader, error) {
+	userID := c.GetString("user_id")
+	if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+	}
+	records, err := s.database.GetTraders(userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取交易员列表失败: %w", err)
+	}
+	only := c.Query("trader_id")
+	var result []dashboardTrader
+	for _, r := range records {
+		if only != "" && r.ID != only {
+			continue
+		}
+		at, err := s.traderManager.GetUserTrader(userID, r.ID)
+		if err != nil {
+			continue
+		}
+		result = append(result, dashboardTrader{id: r.ID, name: r.Name, trader: at})
+	}
+	if only != "" && len(result) == 0 {
+		return nil, fmt.Errorf("交易员不存在: %s", only)
+	}
+	return result, nil
+}
+
+// forEachTrader 并发处理每个交易员（查询余额、持仓都要请求交易所）
+func forEachTrader(traders []dashboardTrader, fn func(i int, t dashboardTrader)) {
+	var wg sync.WaitGroup
+	for i, t := range traders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(i, t)
+		}()
+	}
+	wg.Wait()
+}
+
+// handleDashboardTraders 交易员概览：运行状态、账户净值与盈亏、持仓数、最近一次决策时间
+func (s *Server) handleDashboardTraders(c *gin.Context) {
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]gin.H, len(traders))
+	forEachTrader(traders, func(i int, t dashboardTrader) {
+		item := gin.H{
+			"trader_id":   t.id,
+			"trader_name": t.name,
+			"ai_model":    t.trader.GetAIModel(),
+			"exchange":    t.trader.GetExchange(),
+			"status":      t.trader.GetStatus(),
+		}
+		if account, err := t.trader.GetAccountInfo(); err != nil {
+			item["error"] = err.Error()
+		} else {
+			item["account"] = account
+		}
+		if records, err := t.trader.GetDecisionLogger().GetLatestRecords(1); err == nil && len(records) > 0 {
+			item["last_decision_at"] = records[len(records)-1].Timestamp
+		}
+		result[i] = item
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+// handleDashboardPositions 所有交易员的当前持仓（每条附带 trader_id/trader_name）
+func (s *Server) handleDashboardPositions(c *gin.Context) {
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	perTrader := make([][]map[string]interface{}, len(traders))
+	errs := make(map[string]string)
+	var mu sync.Mutex
+	forEachTrader(traders, func(i int, t dashboardTrader) {
+		positions, err := t.trader.GetPositions()
+		if err != nil {
+			mu.Lock()
+			errs[t.id] = err.Error()
+			mu.Unlock()
+			return
+		}
+		for _, pos := range positions {
+			pos["trader_id"] = t.id
+			pos["trader_name"] = t.name
+		}
+		perTrader[i] = positions
+	})
+	positions := make([]map[string]interface{}, 0)
+	for _, list := range perTrader {
+		positions = append(positions, list...)
+	}
+	c.JSON(http.StatusOK, gin.H{"positions": positions, "errors": errs})
+}
+
+// dashboardDecision 附带交易员信息的决策记录
+type dashboardDecision struct {
+	TraderID   string `json:"trader_id"`
+	TraderName string `json:"trader_name"`
+	*logger.DecisionRecord
+}
+
+// handleDashboardDecisions 所有交易员最近的决策，按时间从新到旧（limit 默认 20，最多 100）
+func (s *Server) handleDashboardDecisions(c *gin.Context) {
+	limit := dashboardDefaultDecisions
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须是正整数"})
+			return
+		}
+		limit = min(n, dashboardMaxDecisions)
+	}
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	decisions := make([]dashboardDecision, 0)
+	for _, t := range traders {
+		records, err := t.trader.GetDecisionLogger().GetLatestRecords(limit)
+		if err != nil {
+			log.Printf("⚠️ 读取交易员 %s 的决策日志失败: %v", t.id, err)
+			continue
+		}
+		for _, r := range records {
+			decisions = append(decisions, dashboardDecision{TraderID: t.id, TraderName: t.name, DecisionRecord: r})
+		}
+	}
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].Timestamp.After(decisions[j].Timestamp)
+	})
+	if len(decisions) > limit {
+		decisions = decisions[:limit]
+	}
+	c.JSON(http.StatusOK, decisions)
+}
+
+// reconcileReport 对账工具 report.json 中看板需要的字段
+type reconcileReport struct {
+	Action      string    `json:"action"`
+	GeneratedAt time.Time `json:"generated_at"`
+	DryRun      bool      `json:"dry_run"`
+	Traders     []struct {
+		TraderID    string `json:"trader_id"`
+		Files       int    `json:"files"`
+		Actions     int    `json:"actions"`
+		Corrections int    `json:"corrections"`
+		Applied     int    `json:"applied"`
+		Issues      int    `json:"issues"`
+		Failures    int    `json:"failures"`
+	} `json:"traders"`
+	Entries []struct {
+		TraderID string `json:"trader_id"`
+		Kind     string `json:"kind"`
+		Severity string `json:"severity"`
+	} `json:"entries"`
+}
+
+// latestReconcileReport 报告目录下最新的 report.json（子目录名为 <action>_<时间>，按修改时间取最新）
+func latestReconcileReport(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*", "report.json"))
+	if err != nil {
+		return "", err
+	}
+	var latest string
+	var latestMod time.Time
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latestMod) {
+			latest, latestMod = path, info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// handleDashboardReconcile 最近一次对账运行中当前用户交易员的不一致统计（需对账工具以 -report_format json 运行）
+func (s *Server) handleDashboardReconcile(c *gin.Context) {
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	dir := s.reconcileReportDir
+	if dir == "" {
+		dir = defaultReconcileReportDir
+	}
+	path, err := latestReconcileReport(dir)
+	if err != nil || path == "" {
+		c.JSON(http.StatusOK, gin.H{"available": false, "report_dir": dir})
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("读取对账报告失败: %v", err)})
+		return
+	}
+	var report reconcileReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("解析对账报告失败: %v", err)})
+		return
+	}
+
+	owned := make(map[string]bool, len(traders))
+	for _, t := range traders {
+		owned[t.id] = true
+	}
+	// 按交易员、条目类型与严重级别统计
+	bySeverity := make(map[string]map[string]int)
+	byKind := make(map[string]map[string]int)
+	for _, e := range report.Entries {
+		if !owned[e.TraderID] {
+			continue
+		}
+		if byKind[e.TraderID] == nil {
+			byKind[e.TraderID] = make(map[string]int)
+			bySeverity[e.TraderID] = make(map[string]int)
+		}
+		byKind[e.TraderID][e.Kind]++
+		if e.Severity != "" {
+			bySeverity[e.TraderID][e.Severity]++
+		}
+	}
+	items := make([]gin.H, 0)
+	var totals struct {
+		Corrections int `json:"corrections"`
+		Applied     int `json:"applied"`
+		Issues      int `json:"issues"`
+		Failures    int `json:"failures"`
+	}
+	for _, t := range report.Traders {
+		if !owned[t.TraderID] {
+			continue
+		}
+		items = append(items, gin.H{
+			"trader_id":   t.TraderID,
+			"files":       t.Files,
+			"actions":     t.Actions,
+			"corrections": t.Corrections,
+			"applied":     t.Applied,
+			"issues":      t.Issues,
+			"failures":    t.Failures,
+			"by_kind":     byKind[t.TraderID],
+			"by_severity": bySeverity[t.TraderID],
+		})
+		totals.Corrections += t.Corrections
+		totals.Applied += t.Applied
+		totals.Issues += t.Issues
+		totals.Failures += t.Failures
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"available":    true,
+		"report":       path,
+		"action":       report.Action,
+		"generated_at": report.GeneratedAt,
+		"dry_run":      report.DryRun,
+		"traders":      items,
+		"totals":       totals,
+	})
+}
+
+// handleDashboardAIUsage 本进程启动以来当前用户交易员的AI调用用量与估算费用（费用需在 config.json 的 ai_pricing 中配置单价）
+func (s *Server) handleDashboardAIUsage(c *gin.Context) {
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	byTrader, _ := mcp.DefaultUsageTracker().StatsByTag(mcp.TagTraderID)
+	var total mcp.UsageTotals
+	items := make([]gin.H, 0, len(traders))
+	for _, t := range traders {
+		u := byTrader[t.id]
+		total.Calls += u.Calls
+		total.Errors += u.Errors
+		total.PromptTokens += u.PromptTokens
+		total.CompletionTokens += u.CompletionTokens
+		total.TotalTokens += u.TotalTokens
+		total.CostUSD += u.CostUSD
+		total.QueueWaitSeconds += u.QueueWaitSeconds
+		items = append(items, gin.H{"trader_id": t.id, "trader_name": t.name, "usage": u})
+	}
+	// 排队状态只列出当前用户的交易员
+	stats := mcp.DefaultScheduler().Stats()
+	waiting := make(map[string]int)
+	for _, t := range traders {
+		if n := stats.Waiting[t.id]; n > 0 {
+			waiting[t.id] = n
+		}
+	}
+	queue := gin.H{"limit": stats.Limit, "running": stats.Running, "waiting": waiting}
+	c.JSON(http.StatusOK, gin.H{"total": total, "traders": items, "queue": queue})
+}
+
+// handleDashboardMarket 行情快照：symbols=BTCUSDT,ETHUSDT（最多 10 个）
+func (s *Server) handleDashboardMarket(c *gin.Context) {
+	var symbols []string
+	for _, sym := range strings.Split(c.Query("symbols"), ",") {
+		if sym = strings.TrimSpace(sym); sym != "" {
+			symbols = append(symbols, market.Normalize(sym))
+		}
+	}
+	if len(symbols) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 symbols 参数"})
+		return
+	}
+	if len(symbols) > dashboardMaxSymbols {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一次最多查询 %d 个币种", dashboardMaxSymbols)})
+		return
+	}
+	data, errs := market.GetBatch(symbols, 4)
+	failed := make(map[string]string, len(errs))
+	for sym, err := range errs {
+		failed[sym] = err.Error()
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data, "errors": failed})
+}
+
+// handleDashboardHedge 各交易员以 BTC/ETH 永续中和组合 beta 的对冲建议（仅建议，不会下单）
+func (s *Server) handleDashboardHedge(c *gin.Context) {
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	items := make([]gin.H, len(traders))
+	errs := make(map[string]string)
+	var mu sync.Mutex
+	forEachTrader(traders, func(i int, t dashboardTrader) {
+		hedges, err := t.trader.HedgeSuggestions(c.Request.Context())
+		if err != nil {
+			mu.Lock()
+			errs[t.id] = err.Error()
+			mu.Unlock()
+			return
+		}
+		items[i] = gin.H{"trader_id": t.id, "trader_name": t.name, "hedges": hedges}
+	})
+	result := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			result = append(result, item)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"traders": result, "errors": errs})
+}
+
+// handleDashboardCalibration 各交易员 AI 信心度与实际胜率的校准报告（Brier 分数与分桶胜率）
+func (s *Server) handleDashboardCalibration(c *gin.Context) {
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	items := make([]gin.H, len(traders))
+	errs := make(map[string]string)
+	var mu sync.Mutex
+	forEachTrader(traders, func(i int, t dashboardTrader) {
+		calibration, err := t.trader.ConfidenceCalibration()
+		if err != nil {
+			mu.Lock()
+			errs[t.id] = err.Error()
+			mu.Unlock()
+			return
+		}
+		items[i] = gin.H{"trader_id": t.id, "trader_name": t.name, "calibration": calibration}
+	})
+	result := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			result = append(result, item)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"traders": result, "errors": errs})
+}
+
+// handleDashboardExperiments 各交易员参与的 A/B 提示词实验的分组对比（周期数、开仓、已平仓结果与AI费用）
+func (s *Server) handleDashboardExperiments(c *gin.Context) {
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	items := make([]gin.H, len(traders))
+	errs := make(map[string]string)
+	var mu sync.Mutex
+	forEachTrader(traders, func(i int, t dashboardTrader) {
+		reports, err := t.trader.ExperimentReports()
+		if err != nil {
+			mu.Lock()
+			errs[t.id] = err.Error()
+			mu.Unlock()
+			return
+		}
+		items[i] = gin.H{"trader_id": t.id, "trader_name": t.name, "experiments": reports}
+	})
+	result := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			result = append(result, item)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"traders": result, "errors": errs})
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"nofx/auth"
+	"nofx/killswitch"
+	"nofx/trader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 紧急停止接口：暂停后交易员不再开新仓，flatten=true 时同时平掉全部持仓；暂停与恢复都会写入决策日志。
+// 全局暂停影响所有用户的交易员，只允许 admin 角色操作；单个交易员只能由其所属用户操作。
+
+// pauseRequest 暂停请求
+type pauseRequest struct {
+	Reason  string `json:"reason"`
+	Flatten bool   `json:"flatten"`
+}
+
+// registerKillSwitchRoutes 注册紧急停止接口
+func (s *Server) registerKillSwitchRoutes(r *gin.RouterGroup) {
+	r.GET("/kill-switch", s.handleKillSwitchStatus)
+	r.POST("/kill-switch", requireRole(auth.RoleAdmin), s.handleGlobalPause)
+	r.DELETE("/kill-switch", requireRole(auth.RoleAdmin), s.handleGlobalResume)
+	r.POST("/traders/:id/pause", s.handleTraderPause)
+	r.POST("/traders/:id/resume", s.handleTraderResume)
+}
+
+// handleKillSwitchStatus 全局与当前用户各交易员的暂停状态
+func (s *Server) handleKillSwitchStatus(c *gin.Context) {
+	traders, err := s.userTraders(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	items := make([]gin.H, 0, len(traders))
+	for _, t := range traders {
+		items = append(items, gin.H{
+			"trader_id":   t.id,
+			"trader_name": t.name,
+			"state":       killswitch.Status(t.id),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"global":  killswitch.Status(killswitch.Global),
+		"file":    killswitch.Default().File(),
+		"traders": items,
+	})
+}
+
+// handleGlobalPause 全局暂停（仅管理员）
+func (s *Server) handleGlobalPause(c *gin.Context) {
+	var req pauseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	st, err := killswitch.Default().Pause(killswitch.Global, req.Reason, c.GetString("user_id"), req.Flatten)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("⛔ 全局紧急停止: %s", st.Describe())
+	s.applyKillSwitch(s.allTraders())
+	c.JSON(http.StatusOK, st)
+}
+
+// handleGlobalResume 解除全局暂停（仅管理员）
+func (s *Server) handleGlobalResume(c *gin.Context) {
+	if !s.resume(c, killswitch.Global) {
+		return
+	}
+	log.Printf("▶️ 全局紧急停止已解除")
+	s.applyKillSwitch(s.allTraders())
+	c.JSON(http.StatusOK, killswitch.Status(killswitch.Global))
+}
+
+// handleTraderPause 暂停单个交易员
+func (s *Server) handleTraderPause(c *gin.Context) {
+	at, ok := s.ownedTrader(c)
+	if !ok {
+		return
+	}
+	var req pauseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := killswitch.Default().Pause(at.GetID(), req.Reason, c.GetString("user_id"), req.Flatten); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	s.applyKillSwitch([]*trader.AutoTrader{at})
+	c.JSON(http.StatusOK, killswitch.Status(at.GetID()))
+}
+
+// handleTraderResume 解除单个交易员的暂停（全局暂停仍然生效）
+func (s *Server) handleTraderResume(c *gin.Context) {
+	at, ok := s.ownedTrader(c)
+	if !ok {
+		return
+	}
+	if !s.resume(c, at.GetID()) {
+		return
+	}
+	s.applyKillSwitch([]*trader.AutoTrader{at})
+	c.JSON(http.StatusOK, killswitch.Status(at.GetID()))
+}
+
+// resume 清除暂停标记；暂停文件仍存在时返回 409
+func (s *Server) resume(c *gin.Context, scope string) bool {
+	err := killswitch.Default().Resume(scope)
+	switch {
+	case errors.Is(err, killswitch.ErrFileActive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "file": killswitch.Default().File()})
+		return false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// ownedTrader 校验交易员属于当前用户并返回内存中的交易员
+func (s *Server) ownedTrader(c *gin.Context) (*trader.AutoTrader, bool) {
+	traderID := c.Param("id")
+	if _, _, _, err := s.database.GetTraderConfig(c.GetString("user_id"), traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return nil, false
+	}
+	at, err := s.traderManager.GetUserTrader(c.GetString("user_id"), traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return nil, false
+	}
+	return at, true
+}
+
+// allTraders 内存中的所有交易员
+func (s *Server) allTraders() []*trader.AutoTrader {
+	all := s.traderManager.GetAllTraders()
+	result := make([]*trader.AutoTrader, 0, len(all))
+	for _, at := range all {
+		result = append(result, at)
+	}
+	return result
+}
+
+// applyKillSwitch 让交易员立即检查暂停状态（写入决策记录、需要时平仓），不等待下次轮询
+func (s *Server) applyKillSwitch(traders []*trader.AutoTrader) {
+	for _, at := range traders {
+		go at.CheckKillSwitch()
+	}
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"nofx/auth"
+	"nofx/config"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 多用户隔离与角色权限：
+//   - 认证方式：登录得到的JWT，或用户创建的API令牌（Authorization: Bearer nofx_...）
+//   - 角色：viewer 只读；operator 可以修改自己的交易员与配置；admin 额外可以执行全局操作
+//   - API令牌的角色不高于创建者，实际生效的角色取令牌与用户当前角色中较低的一个
+//   - 无论角色如何，所有查询都限定在当前用户自己的数据内
+
+// 认证方式（上下文中的 auth_type）
+const (
+	authTypeJWT       = "jwt"
+	authTypeAPIToken  = "api_token"
+	authTypeAdminMode = "admin_mode"
+)
+
+// apiTokenTouchInterval 记录令牌最近使用时间的最小间隔（避免每个请求都写数据库）
+const apiTokenTouchInterval = time.Minute
+
+// methodRole 请求方法需要的最低角色
+func methodRole(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return auth.RoleViewer
+	}
+	return auth.RoleOperator
+}
+
+// authenticateAPIToken 校验API令牌并设置上下文；失败时已写入响应
+func (s *Server) authenticateAPIToken(c *gin.Context, token string) bool {
+	reject := func(msg string) bool {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+		c.Abort()
+		return false
+	}
+	t, err := s.database.GetAPITokenByHash(auth.HashAPIToken(token))
+	if err != nil {
+		return reject("无效的API令牌")
+	}
+	now := time.Now()
+	if !t.Active(now) {
+		return reject("API令牌已撤销或已过期")
+	}
+	user, err := s.database.GetUserByID(t.UserID)
+	if err != nil {
+		return reject("API令牌所属用户不存在")
+	}
+	role := t.Role
+	if !auth.RoleAllows(user.Role, role) {
+		role = user.Role // 用户被降级后令牌随之降级
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= apiTokenTouchInterval {
+		if err := s.database.TouchAPIToken(t.ID, now); err != nil {
+			log.Printf("⚠️ 更新API令牌使用时间失败: %v", err)
+		}
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("email", user.Email)
+	c.Set("role", role)
+	c.Set("auth_type", authTypeAPIToken)
+	c.Set("api_token_id", t.ID)
+	return true
+}
+
+// requireRole 要求当前用户具备指定角色
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.RoleAllows(c.GetString("role"), role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "权限不足：需要 " + role + " 角色"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireSession 要求使用登录会话（令牌管理不允许用API令牌操作，避免令牌自我续期）
+func requireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") == authTypeAPIToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "请使用登录会话管理API令牌"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// registerRBACRoutes 注册当前用户信息、API令牌与角色管理接口
+func (s *Server) registerRBACRoutes(r *gin.RouterGroup) {
+	r.GET("/me", s.handleMe)
+
+	tokens := r.Group("/tokens", requireSession())
+	tokens.GET("", s.handleListAPITokens)
+	tokens.POST("", s.handleCreateAPIToken)
+	tokens.DELETE("/:id", s.handleRevokeAPIToken)
+
+	r.PUT("/users/:id/role", requireRole(auth.RoleAdmin), s.handleUpdateUserRole)
+}
+
+// handleMe 当前用户、角色与认证方式
+func (s *Server) handleMe(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":   c.GetString("user_id"),
+		"email":     c.GetString("email"),
+		"role":      c.GetString("role"),
+		"auth_type": c.GetString("auth_type"),
+	})
+}
+
+// handleListAPITokens 当前用户的API令牌（不含明文）
+func (s *Server) handleListAPITokens(c *gin.Context) {
+	tokens, err := s.database.GetAPITokens(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tokens == nil {
+		tokens = []*config.APIToken{}
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// handleCreateAPIToken 创建API令牌：角色默认 viewer，不能高于当前用户；明文只在响应中返回一次
+func (s *Server) handleCreateAPIToken(c *gin.Context) {
+	var req struct {
+		Name          string `json:"name" binding:"required"`
+		Role          string `json:"role"`
+		ExpiresInDays int    `json:"expires_in_days"` // 0 表示不过期
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = auth.RoleViewer
+	}
+	if !auth.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色: " + req.Role})
+		return
+	}
+	if !auth.RoleAllows(c.GetString("role"), req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "令牌角色不能高于当前用户角色"})
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days 不能为负数"})
+		return
+	}
+
+	token, hash, err := auth.GenerateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	t := &config.APIToken{
+		ID:        uuid.New().String(),
+		UserID:    c.GetString("user_id"),
+		Name:      strings.TrimSpace(req.Name),
+		TokenHash: hash,
+		Role:      req.Role,
+		CreatedAt: time.Now(),
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		t.ExpiresAt = &expires
+	}
+	if err := s.database.CreateAPIToken(t); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("🔑 用户 %s 创建API令牌 %s (%s)", t.UserID, t.Name, t.Role)
+	c.JSON(http.StatusOK, gin.H{"token": token, "api_token": t})
+}
+
+// handleRevokeAPIToken 撤销当前用户的API令牌
+func (s *Server) handleRevokeAPIToken(c *gin.Context) {
+	err := s.database.RevokeAPIToken(c.GetString("user_id"), c.Param("id"))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "API令牌不存在"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API令牌已撤销"})
+}
+
+// handleUpdateUserRole 修改用户角色（仅管理员；不能修改自己的角色，避免误操作后失去管理员权限）
+func (s *Server) handleUpdateUserRole(c *gin.Context) {
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.Param("id")
+	if userID == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能修改自己的角色"})
+		return
+	}
+	if !auth.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色: " + req.Role})
+		return
+	}
+	err := s.database.UpdateUserRole(userID, req.Role)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("👤 管理员 %s 将用户 %s 的角色改为 %s", c.GetString("user_id"), userID, req.Role)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": req.Role})
+}
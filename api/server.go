@@ -1,7 +1,10 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -10,6 +13,7 @@ import (
 	"nofx/config"
 	"nofx/decision"
 	"nofx/manager"
+	"nofx/market"
 	"nofx/trader"
 	"strconv"
 	"strings"
@@ -25,6 +29,9 @@ type Server struct {
 	traderManager *manager.TraderManager
 	database      *config.Database
 	port          int
+	httpServer    *http.Server
+
+	reconcileReportDir string // 看板读取的对账报告目录（见 dashboard.go）
 }
 
 // NewServer 创建API服务器
@@ -43,6 +50,7 @@ func NewServer(traderManager *manager.TraderManager, database *config.Database,
 		database:      database,
 		port:          port,
 	}
+	s.httpServer = &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: router}
 
 	// 设置路由
 	s.setupRoutes()
@@ -137,6 +145,15 @@ func (s *Server) setupRoutes() {
 			protected.GET("/decisions/latest", s.handleLatestDecisions)
 			protected.GET("/statistics", s.handleStatistics)
 			protected.GET("/performance", s.handlePerformance)
+
+			// 看板汇总数据（当前用户的所有交易员）
+			s.registerDashboardRoutes(protected)
+
+			// 紧急停止（全局/单个交易员暂停）
+			s.registerKillSwitchRoutes(protected)
+
+			// 当前用户、API令牌与角色管理
+			s.registerRBACRoutes(protected)
 		}
 	}
 }
@@ -317,6 +334,7 @@ func isPrivateIP(ip net.IP) bool {
 }
 
 // getTraderFromQuery 从query参数获取trader
+// 已认证的请求只能访问当前用户自己的交易员；公开接口（未认证）沿用原有行为
 func (s *Server) getTraderFromQuery(c *gin.Context) (*manager.TraderManager, string, error) {
 	userID := c.GetString("user_id")
 	traderID := c.Query("trader_id")
@@ -327,6 +345,19 @@ func (s *Server) getTraderFromQuery(c *gin.Context) (*manager.TraderManager, str
 		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
 	}
 
+	if userID != "" {
+		if traderID == "" {
+			userTraders, err := s.database.GetTraders(userID)
+			if err != nil || len(userTraders) == 0 {
+				return nil, "", fmt.Errorf("没有可用的trader")
+			}
+			traderID = userTraders[0].ID
+		} else if _, err := s.database.GetTrader(userID, traderID); err != nil {
+			return nil, "", fmt.Errorf("交易员不存在或无访问权限")
+		}
+		return s.traderManager, traderID, nil
+	}
+
 	if traderID == "" {
 		// 如果没有指定trader_id，返回该用户的第一个trader
 		ids := s.traderManager.GetTraderIDs()
@@ -364,6 +395,10 @@ type CreateTraderRequest struct {
 	UseOITop             bool    `json:"use_oi_top"`
 	CoinPoolAPIURL       string  `json:"coin_pool_api_url"` // 币种池API URL
 	OITopAPIURL          string  `json:"oi_top_api_url"`    // OI Top API URL
+
+	TrailingStop *trader.TrailingStopRules `json:"trailing_stop"` // 保本/移动止损规则，nil表示不启用
+	// IndicatorConfig 指标集合与周期，nil表示使用默认指标
+	IndicatorConfig *market.IndicatorConfig `json:"indicator_config"`
 }
 
 type ModelConfig struct {
@@ -467,6 +502,17 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		isCrossMargin = *req.IsCrossMargin
 	}
 
+	trailingStop, err := encodeTrailingStop(req.TrailingStop, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	indicatorConfig, err := encodeIndicatorConfig(req.IndicatorConfig, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 设置杠杆默认值（从系统配置获取）
 	btcEthLeverage := 5
 	altcoinLeverage := 5
@@ -543,6 +589,8 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 				exchangeCfg.AsterSigner,
 				exchangeCfg.AsterPrivateKey,
 			)
+		case "paper":
+			// 模拟盘没有真实余额，使用用户输入的初始资金
 		default:
 			log.Printf("⚠️ 不支持的交易所类型: %s，使用用户输入的初始资金", req.ExchangeID)
 		}
@@ -589,6 +637,8 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: systemPromptTemplate,
 		IsCrossMargin:        isCrossMargin,
+		TrailingStop:         trailingStop,
+		IndicatorConfig:      indicatorConfig,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            false,
 	}
@@ -634,6 +684,61 @@ type UpdateTraderRequest struct {
 	UseOITop            bool    `json:"use_oi_top"`
 	CoinPoolAPIURL      string  `json:"coin_pool_api_url"` // 币种池API URL
 	OITopAPIURL         string  `json:"oi_top_api_url"`    // OI Top API URL
+
+	TrailingStop *trader.TrailingStopRules `json:"trailing_stop"` // 保本/移动止损规则，nil表示保持原值
+	// IndicatorConfig 指标集合与周期，nil表示保持原值
+	IndicatorConfig *market.IndicatorConfig `json:"indicator_config"`
+}
+
+// encodeTrailingStop 校验请求中的移动止损规则并序列化为数据库存储的JSON；未提供时返回原值
+func encodeTrailingStop(rules *trader.TrailingStopRules, current string) (string, error) {
+	if rules == nil {
+		return current, nil
+	}
+	if err := rules.Validate(); err != nil {
+		return "", err
+	}
+	if !rules.Enabled() {
+		return "", nil
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// indicatorConfig 返回给前端的指标配置（未设置或无效时为 nil，表示默认指标）
+func indicatorConfig(stored string) *market.IndicatorConfig {
+	cfg, err := market.ParseIndicatorConfig(stored)
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
+
+// trailingStopRules 返回给前端的移动止损规则（未启用或无效时为 nil）
+func trailingStopRules(stored string) *trader.TrailingStopRules {
+	rules, err := trader.ParseTrailingStopRules(stored)
+	if err != nil || !rules.Enabled() {
+		return nil
+	}
+	return &rules
+}
+
+// encodeIndicatorConfig 校验请求中的指标配置并序列化为数据库存储的JSON；未提供时返回原值
+func encodeIndicatorConfig(cfg *market.IndicatorConfig, current string) (string, error) {
+	if cfg == nil {
+		return current, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
 }
 
 // handleUpdateTrader 更新交易员配置
@@ -651,22 +756,13 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 	log.Printf("🧪 handleUpdateTrader 输入检查: trader_id=%s use_coin_pool=%v use_oi_top=%v coin_pool_api_url='%s' oi_top_api_url='%s'", traderID, req.UseCoinPool, req.UseOITop, req.CoinPoolAPIURL, req.OITopAPIURL)
 
 	// 检查交易员是否存在且属于当前用户
-	traders, err := s.database.GetTraders(userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取交易员列表失败"})
+	existingTrader, err := s.database.GetTrader(userID, traderID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
 		return
 	}
-
-	var existingTrader *config.TraderRecord
-	for _, trader := range traders {
-		if trader.ID == traderID {
-			existingTrader = trader
-			break
-		}
-	}
-
-	if existingTrader == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取交易员失败"})
 		return
 	}
 
@@ -676,6 +772,17 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		isCrossMargin = *req.IsCrossMargin
 	}
 
+	trailingStop, err := encodeTrailingStop(req.TrailingStop, existingTrader.TrailingStop)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	indicatorConfig, err := encodeIndicatorConfig(req.IndicatorConfig, existingTrader.IndicatorConfig)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 设置杠杆默认值
 	btcEthLeverage := req.BTCETHLeverage
 	altcoinLeverage := req.AltcoinLeverage
@@ -729,6 +836,8 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: existingTrader.SystemPromptTemplate, // 保持原值
 		IsCrossMargin:        isCrossMargin,
+		TrailingStop:         trailingStop,
+		IndicatorConfig:      indicatorConfig,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            existingTrader.IsRunning, // 保持原值
 	}
@@ -761,6 +870,12 @@ func (s *Server) handleDeleteTrader(c *gin.Context) {
 	userID := c.GetString("user_id")
 	traderID := c.Param("id")
 
+	// 校验交易员是否属于当前用户（否则下面会停止其他用户的同ID交易员）
+	if _, err := s.database.GetTrader(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
 	// 从数据库删除
 	err := s.database.DeleteTrader(userID, traderID)
 	if err != nil {
@@ -769,7 +884,7 @@ func (s *Server) handleDeleteTrader(c *gin.Context) {
 	}
 
 	// 如果交易员正在运行，先停止它
-	if trader, err := s.traderManager.GetTrader(traderID); err == nil {
+	if trader, err := s.traderManager.GetUserTrader(userID, traderID); err == nil {
 		status := trader.GetStatus()
 		if isRunning, ok := status["is_running"].(bool); ok && isRunning {
 			trader.Stop()
@@ -793,7 +908,7 @@ func (s *Server) handleStartTrader(c *gin.Context) {
 		return
 	}
 
-	trader, err := s.traderManager.GetTrader(traderID)
+	trader, err := s.traderManager.GetUserTrader(userID, traderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
 		return
@@ -836,7 +951,7 @@ func (s *Server) handleStopTrader(c *gin.Context) {
 		return
 	}
 
-	trader, err := s.traderManager.GetTrader(traderID)
+	trader, err := s.traderManager.GetUserTrader(userID, traderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
 		return
@@ -868,8 +983,9 @@ func (s *Server) handleUpdateTraderPrompt(c *gin.Context) {
 	userID := c.GetString("user_id")
 
 	var req struct {
-		CustomPrompt       string `json:"custom_prompt"`
-		OverrideBasePrompt bool   `json:"override_base_prompt"`
+		CustomPrompt         string  `json:"custom_prompt"`
+		OverrideBasePrompt   bool    `json:"override_base_prompt"`
+		SystemPromptTemplate *string `json:"system_prompt_template,omitempty"` // 不传则保持原模板
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -883,12 +999,21 @@ func (s *Server) handleUpdateTraderPrompt(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新自定义prompt失败: %v", err)})
 		return
 	}
+	if req.SystemPromptTemplate != nil {
+		if err := s.database.UpdateTraderPromptTemplate(userID, traderID, *req.SystemPromptTemplate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新提示词模板失败: %v", err)})
+			return
+		}
+	}
 
 	// 如果trader在内存中，更新其custom prompt和override设置
-	trader, err := s.traderManager.GetTrader(traderID)
+	trader, err := s.traderManager.GetUserTrader(userID, traderID)
 	if err == nil {
 		trader.SetCustomPrompt(req.CustomPrompt)
 		trader.SetOverrideBasePrompt(req.OverrideBasePrompt)
+		if req.SystemPromptTemplate != nil && *req.SystemPromptTemplate != "" {
+			trader.SetSystemPromptTemplate(*req.SystemPromptTemplate)
+		}
 		log.Printf("✓ 已更新交易员 %s 的自定义prompt (覆盖基础=%v)", trader.GetName(), req.OverrideBasePrompt)
 	}
 
@@ -933,6 +1058,9 @@ func (s *Server) handleSyncBalance(c *gin.Context) {
 			exchangeCfg.AsterSigner,
 			exchangeCfg.AsterPrivateKey,
 		)
+	case "paper":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "模拟盘没有交易所余额可同步"})
+		return
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的交易所类型"})
 		return
@@ -1145,7 +1273,7 @@ func (s *Server) handleTraderList(c *gin.Context) {
 	for _, trader := range traders {
 		// 获取实时运行状态
 		isRunning := trader.IsRunning
-		if at, err := s.traderManager.GetTrader(trader.ID); err == nil {
+		if at, err := s.traderManager.GetUserTrader(userID, trader.ID); err == nil {
 			status := at.GetStatus()
 			if running, ok := status["is_running"].(bool); ok {
 				isRunning = running
@@ -1185,7 +1313,7 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 
 	// 获取实时运行状态
 	isRunning := traderConfig.IsRunning
-	if at, err := s.traderManager.GetTrader(traderID); err == nil {
+	if at, err := s.traderManager.GetUserTrader(userID, traderID); err == nil {
 		status := at.GetStatus()
 		if running, ok := status["is_running"].(bool); ok {
 			isRunning = running
@@ -1208,6 +1336,8 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 		"custom_prompt":         traderConfig.CustomPrompt,
 		"override_base_prompt":  traderConfig.OverrideBasePrompt,
 		"is_cross_margin":       traderConfig.IsCrossMargin,
+		"trailing_stop":         trailingStopRules(traderConfig.TrailingStop),
+		"indicator_config":      indicatorConfig(traderConfig.IndicatorConfig),
 		"use_coin_pool":         traderConfig.UseCoinPool,
 		"use_oi_top":            traderConfig.UseOITop,
 		"coin_pool_api_url":     traderConfig.CoinPoolAPIURL,
@@ -1510,13 +1640,16 @@ func (s *Server) handlePerformance(c *gin.Context) {
 	c.JSON(http.StatusOK, performance)
 }
 
-// authMiddleware JWT认证中间件
+// authMiddleware 认证中间件：支持JWT与API令牌（nofx_ 前缀），在上下文中设置 user_id/email/role/auth_type，
+// 并按请求方法检查角色（只读请求需要 viewer，其他请求需要 operator）
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 如果是管理员模式，直接使用admin用户
 		if auth.IsAdminMode() {
 			c.Set("user_id", "admin")
 			c.Set("email", "admin@localhost")
+			c.Set("role", auth.RoleAdmin)
+			c.Set("auth_type", authTypeAdminMode)
 			c.Next()
 			return
 		}
@@ -1536,17 +1669,38 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 验证JWT token
-		claims, err := auth.ValidateJWT(tokenParts[1])
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token: " + err.Error()})
+		if auth.IsAPIToken(tokenParts[1]) {
+			if !s.authenticateAPIToken(c, tokenParts[1]) {
+				return
+			}
+		} else {
+			// 验证JWT token
+			claims, err := auth.ValidateJWT(tokenParts[1])
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token: " + err.Error()})
+				c.Abort()
+				return
+			}
+			// 角色以数据库为准（修改角色后立即生效，无需重新登录）
+			user, err := s.database.GetUserByID(claims.UserID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+				c.Abort()
+				return
+			}
+
+			// 将用户信息存储到上下文中
+			c.Set("user_id", claims.UserID)
+			c.Set("email", claims.Email)
+			c.Set("role", user.Role)
+			c.Set("auth_type", authTypeJWT)
+		}
+
+		if required := methodRole(c.Request.Method); !auth.RoleAllows(c.GetString("role"), required) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("权限不足：需要 %s 角色", required)})
 			c.Abort()
 			return
 		}
-
-		// 将用户信息存储到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
 		c.Next()
 	}
 }
@@ -1694,6 +1848,7 @@ func (s *Server) handleCompleteRegistration(c *gin.Context) {
 		"token":   token,
 		"user_id": user.ID,
 		"email":   user.Email,
+		"role":    user.Role,
 		"message": "注册完成",
 	})
 }
@@ -1778,6 +1933,7 @@ func (s *Server) handleVerifyOTP(c *gin.Context) {
 		"token":   token,
 		"user_id": user.ID,
 		"email":   user.Email,
+		"role":    user.Role,
 		"message": "登录成功",
 	})
 }
@@ -1889,7 +2045,15 @@ func (s *Server) Start() error {
 	log.Printf("  • GET  /api/performance?trader_id=xxx - 指定trader的AI学习表现分析")
 	log.Println()
 
-	return s.router.Run(addr)
+	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown 停止接收新请求并等待处理中的请求完成（ctx 超时后强制关闭）
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
 
 // handleGetPromptTemplates 获取所有系统提示词模板列表
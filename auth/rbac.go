@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// 角色：viewer 只读；operator 管理自己的交易员与配置；admin 额外可以执行全局操作（全局暂停、修改用户角色）。
+// 角色只放宽操作权限，不放宽数据范围：所有查询都限定在当前用户自己的数据内。
+
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// DefaultRole 新注册用户的角色
+const DefaultRole = RoleOperator
+
+var roleRank = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ValidRole 是否为有效角色
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RoleAllows role 是否具备 required 的权限（无效角色没有任何权限）
+func RoleAllows(role, required string) bool {
+	have, ok := roleRank[role]
+	return ok && have >= roleRank[required]
+}
+
+// APITokenPrefix API令牌前缀（用于区分JWT）
+const APITokenPrefix = "nofx_"
+
+// IsAPIToken 是否为API令牌（而不是JWT）
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// GenerateAPIToken 生成API令牌，返回明文（只展示一次）与保存到数据库的哈希
+func GenerateAPIToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("生成API令牌失败: %w", err)
+	}
+	token = APITokenPrefix + hex.EncodeToString(b)
+	return token, HashAPIToken(token), nil
+}
+
+// HashAPIToken 计算API令牌的哈希（令牌本身是高熵随机数，不需要加盐）
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,352 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"nofx/decision"
+	"nofx/market"
+)
+
+// 平仓原因
+const (
+	CloseReasonSignal      = "signal"      // 策略发出的平仓/部分平仓
+	CloseReasonStopLoss    = "stop_loss"   // 触发止损
+	CloseReasonTakeProfit  = "take_profit" // 触发止盈
+	CloseReasonLiquidation = "liquidation" // 触发强平
+	CloseReasonEnd         = "end_of_test" // 回测结束时按最新价平仓
+)
+
+// Position 模拟持仓
+type Position struct {
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"` // long/short
+	Quantity   float64   `json:"quantity"`
+	EntryPrice float64   `json:"entry_price"`
+	Leverage   int       `json:"leverage"`
+	Margin     float64   `json:"margin"`
+	StopLoss   float64   `json:"stop_loss"`
+	TakeProfit float64   `json:"take_profit"`
+	OpenTime   time.Time `json:"open_time"`
+	OpenFee    float64   `json:"open_fee"` // 尚未分摊到已平仓部分的开仓手续费
+}
+
+// unrealized 按指定价格计算的未实现盈亏
+func (p *Position) unrealized(price float64) float64 {
+	if p.Side == "long" {
+		return (price - p.EntryPrice) * p.Quantity
+	}
+	return (p.EntryPrice - price) * p.Quantity
+}
+
+// liquidationPrice 逐仓近似强平价（忽略维持保证金）
+func (p *Position) liquidationPrice() float64 {
+	if p.Leverage <= 0 {
+		return 0
+	}
+	if p.Side == "long" {
+		return p.EntryPrice * (1 - 1/float64(p.Leverage))
+	}
+	return p.EntryPrice * (1 + 1/float64(p.Leverage))
+}
+
+// broker 模拟撮合：按K线收盘价成交，计入手续费与滑点，盘中按最高/最低价检查止损、止盈与强平
+type broker struct {
+	feeRate   float64
+	slippage  float64 // 滑点比例（SlippageBps/10000）
+	balance   float64 // 钱包余额（已计入已实现盈亏与手续费）
+	positions map[string]*Position
+	marks     map[string]float64
+	trades    []Trade
+	fees      float64
+}
+
+func newBroker(cfg Config) *broker {
+	return &broker{
+		feeRate:   cfg.FeeRate,
+		slippage:  cfg.SlippageBps / 10000,
+		balance:   cfg.InitialBalance,
+		positions: make(map[string]*Position),
+		marks:     make(map[string]float64),
+	}
+}
+
+func positionKey(symbol, side string) string { return symbol + "_" + side }
+
+// equity 账户净值（钱包余额 + 按最新价计算的未实现盈亏）
+func (b *broker) equity() float64 {
+	eq := b.balance
+	for _, p := range b.positions {
+		if mark, ok := b.marks[p.Symbol]; ok {
+			eq += p.unrealized(mark)
+		}
+	}
+	return eq
+}
+
+func (b *broker) marginUsed() float64 {
+	total := 0.0
+	for _, p := range b.positions {
+		total += p.Margin
+	}
+	return total
+}
+
+// fillPrice 按滑点计算成交价：买入上浮、卖出下浮
+func (b *broker) fillPrice(price float64, buy bool) float64 {
+	if buy {
+		return price * (1 + b.slippage)
+	}
+	return price * (1 - b.slippage)
+}
+
+// sortedPositions 按交易对、方向排序的持仓（保证回测结果可复现）
+func (b *broker) sortedPositions() []*Position {
+	list := make([]*Position, 0, len(b.positions))
+	for _, p := range b.positions {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Symbol != list[j].Symbol {
+			return list[i].Symbol < list[j].Symbol
+		}
+		return list[i].Side < list[j].Side
+	})
+	return list
+}
+
+// open 开仓：sizeUSD 为名义价值
+func (b *broker) open(symbol, side string, sizeUSD float64, leverage int, stopLoss, takeProfit, price float64, now time.Time) error {
+	if sizeUSD <= 0 {
+		return fmt.Errorf("%s 开仓金额无效: %.2f", symbol, sizeUSD)
+	}
+	key := positionKey(symbol, side)
+	if _, ok := b.positions[key]; ok {
+		return fmt.Errorf("%s 已有%s仓位，拒绝重复开仓", symbol, side)
+	}
+	if leverage <= 0 {
+		leverage = 1
+	}
+	fill := b.fillPrice(price, side == "long")
+	fee := sizeUSD * b.feeRate
+	margin := sizeUSD / float64(leverage)
+	if available := b.equity() - b.marginUsed(); margin+fee > available {
+		return fmt.Errorf("%s 可用余额不足: 需要 %.2f USDT，可用 %.2f USDT", symbol, margin+fee, available)
+	}
+	b.balance -= fee
+	b.fees += fee
+	b.positions[key] = &Position{
+		Symbol:     symbol,
+		Side:       side,
+		Quantity:   sizeUSD / fill,
+		EntryPrice: fill,
+		Leverage:   leverage,
+		Margin:     margin,
+		StopLoss:   stopLoss,
+		TakeProfit: takeProfit,
+		OpenTime:   now,
+		OpenFee:    fee,
+	}
+	return nil
+}
+
+// close 平掉持仓的 fraction 部分（0-1），withSlippage 为 false 时按给定价格精确成交（如强平）
+func (b *broker) close(symbol, side string, fraction, price float64, withSlippage bool, reason string, now time.Time) error {
+	key := positionKey(symbol, side)
+	p, ok := b.positions[key]
+	if !ok {
+		return fmt.Errorf("%s 没有%s仓位", symbol, side)
+	}
+	if fraction <= 0 || fraction > 1 {
+		fraction = 1
+	}
+	fill := price
+	if withSlippage {
+		fill = b.fillPrice(price, side == "short")
+	}
+	qty := p.Quantity * fraction
+	gross := qty / p.Quantity * p.unrealized(fill)
+	fee := fill * qty * b.feeRate
+	openFee := p.OpenFee * fraction
+	margin := p.Margin * fraction
+
+	b.balance += gross - fee
+	b.fees += fee
+	pnl := gross - fee - openFee
+	pnlPct := 0.0
+	if margin > 0 {
+		pnlPct = pnl / margin * 100
+	}
+	b.trades = append(b.trades, Trade{
+		Symbol:     symbol,
+		Side:       side,
+		Quantity:   qty,
+		Leverage:   p.Leverage,
+		EntryPrice: p.EntryPrice,
+		ExitPrice:  fill,
+		OpenTime:   p.OpenTime,
+		CloseTime:  now,
+		PnL:        pnl,
+		PnLPct:     pnlPct,
+		Fees:       fee + openFee,
+		Reason:     reason,
+	})
+
+	if fraction >= 1 {
+		delete(b.positions, key)
+		return nil
+	}
+	p.Quantity -= qty
+	p.Margin -= margin
+	p.OpenFee -= openFee
+	return nil
+}
+
+// checkTriggers 用一根K线的高低价检查止损、强平、止盈（同一根K线同时触及止损与止盈时保守地按止损处理）
+func (b *broker) checkTriggers(symbol string, k market.Kline, now time.Time) {
+	for _, side := range []string{"long", "short"} {
+		p, ok := b.positions[positionKey(symbol, side)]
+		if !ok {
+			continue
+		}
+		liq := p.liquidationPrice()
+		if side == "long" {
+			switch {
+			case p.StopLoss > 0 && k.Low <= p.StopLoss:
+				b.close(symbol, side, 1, math.Min(p.StopLoss, k.Open), true, CloseReasonStopLoss, now)
+			case liq > 0 && k.Low <= liq:
+				b.close(symbol, side, 1, liq, false, CloseReasonLiquidation, now)
+			case p.TakeProfit > 0 && k.High >= p.TakeProfit:
+				b.close(symbol, side, 1, math.Max(p.TakeProfit, k.Open), true, CloseReasonTakeProfit, now)
+			}
+			continue
+		}
+		switch {
+		case p.StopLoss > 0 && k.High >= p.StopLoss:
+			b.close(symbol, side, 1, math.Max(p.StopLoss, k.Open), true, CloseReasonStopLoss, now)
+		case liq > 0 && k.High >= liq:
+			b.close(symbol, side, 1, liq, false, CloseReasonLiquidation, now)
+		case p.TakeProfit > 0 && k.Low <= p.TakeProfit:
+			b.close(symbol, side, 1, math.Min(p.TakeProfit, k.Open), true, CloseReasonTakeProfit, now)
+		}
+	}
+}
+
+// execute 按最新价执行一条决策
+func (b *broker) execute(d decision.Decision, now time.Time) error {
+	if d.Action == "hold" || d.Action == "wait" {
+		return nil
+	}
+	price, ok := b.marks[d.Symbol]
+	if !ok || price <= 0 {
+		return fmt.Errorf("%s 没有可用的K线价格", d.Symbol)
+	}
+	switch d.Action {
+	case "open_long":
+		return b.open(d.Symbol, "long", d.PositionSizeUSD, d.Leverage, d.StopLoss, d.TakeProfit, price, now)
+	case "open_short":
+		return b.open(d.Symbol, "short", d.PositionSizeUSD, d.Leverage, d.StopLoss, d.TakeProfit, price, now)
+	case "close_long":
+		return b.close(d.Symbol, "long", 1, price, true, CloseReasonSignal, now)
+	case "close_short":
+		return b.close(d.Symbol, "short", 1, price, true, CloseReasonSignal, now)
+	case "partial_close":
+		p := b.findPosition(d.Symbol)
+		if p == nil {
+			return fmt.Errorf("%s 没有可部分平仓的持仓", d.Symbol)
+		}
+		if d.ClosePercentage <= 0 || d.ClosePercentage > 100 {
+			return fmt.Errorf("%s 部分平仓比例无效: %.1f", d.Symbol, d.ClosePercentage)
+		}
+		return b.close(d.Symbol, p.Side, d.ClosePercentage/100, price, true, CloseReasonSignal, now)
+	case "update_stop_loss":
+		p := b.findPosition(d.Symbol)
+		if p == nil {
+			return fmt.Errorf("%s 没有可调整止损的持仓", d.Symbol)
+		}
+		p.StopLoss = d.NewStopLoss
+		return nil
+	case "update_take_profit":
+		p := b.findPosition(d.Symbol)
+		if p == nil {
+			return fmt.Errorf("%s 没有可调整止盈的持仓", d.Symbol)
+		}
+		p.TakeProfit = d.NewTakeProfit
+		return nil
+	}
+	return fmt.Errorf("未知的决策动作: %s", d.Action)
+}
+
+// findPosition 查找交易对的持仓（多仓优先）
+func (b *broker) findPosition(symbol string) *Position {
+	if p, ok := b.positions[positionKey(symbol, "long")]; ok {
+		return p
+	}
+	return b.positions[positionKey(symbol, "short")]
+}
+
+// closeAll 回测结束时按最新价平掉全部持仓
+func (b *broker) closeAll(now time.Time) {
+	for _, p := range b.sortedPositions() {
+		if mark, ok := b.marks[p.Symbol]; ok {
+			b.close(p.Symbol, p.Side, 1, mark, true, CloseReasonEnd, now)
+		}
+	}
+}
+
+// accountInfo 转换为决策引擎使用的账户信息
+func (b *broker) accountInfo(initial float64) decision.AccountInfo {
+	equity := b.equity()
+	margin := b.marginUsed()
+	info := decision.AccountInfo{
+		TotalEquity:      equity,
+		AvailableBalance: equity - margin,
+		TotalPnL:         equity - initial,
+		MarginUsed:       margin,
+		PositionCount:    len(b.positions),
+	}
+	if equity > 0 {
+		info.MarginUsedPct = margin / equity * 100
+	}
+	notional, unrealized := 0.0, 0.0
+	for _, p := range b.positions {
+		notional += p.EntryPrice * p.Quantity
+		if mark, ok := b.marks[p.Symbol]; ok {
+			unrealized += p.unrealized(mark)
+		}
+	}
+	if notional > 0 {
+		info.TotalPnLPct = unrealized / notional * 100
+	}
+	return info
+}
+
+// positionInfos 转换为决策引擎使用的持仓信息
+func (b *broker) positionInfos() []decision.PositionInfo {
+	list := b.sortedPositions()
+	infos := make([]decision.PositionInfo, 0, len(list))
+	for _, p := range list {
+		mark := b.marks[p.Symbol]
+		pnl := p.unrealized(mark)
+		pnlPct := 0.0
+		if p.Margin > 0 {
+			pnlPct = pnl / p.Margin * 100
+		}
+		infos = append(infos, decision.PositionInfo{
+			Symbol:           p.Symbol,
+			Side:             p.Side,
+			EntryPrice:       p.EntryPrice,
+			MarkPrice:        mark,
+			Quantity:         p.Quantity,
+			Leverage:         p.Leverage,
+			UnrealizedPnL:    pnl,
+			UnrealizedPnLPct: pnlPct,
+			LiquidationPrice: p.liquidationPrice(),
+			MarginUsed:       p.Margin,
+			UpdateTime:       p.OpenTime.UnixMilli(),
+		})
+	}
+	return infos
+}
@@ -0,0 +1,305 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"nofx/decision"
+	"nofx/market"
+)
+
+// Config 回测参数
+type Config struct {
+	InitialBalance float64       // 初始资金（USDT），默认 1000
+	FeeRate        float64       // 单边手续费率，默认 0.0004（币安合约吃单费率）
+	SlippageBps    float64       // 成交滑点（基点），默认 2，负数表示不计滑点
+	Interval       string        // 驱动回测的K线周期，默认 3m
+	DecisionEvery  time.Duration // 决策间隔，默认等于K线周期
+	From, To       time.Time     // 回测区间（零值表示使用全部已加载K线）
+}
+
+func (c Config) withDefaults() Config {
+	if c.InitialBalance <= 0 {
+		c.InitialBalance = 1000
+	}
+	if c.FeeRate <= 0 {
+		c.FeeRate = 0.0004
+	}
+	if c.SlippageBps < 0 {
+		c.SlippageBps = 0
+	} else if c.SlippageBps == 0 {
+		c.SlippageBps = 2
+	}
+	if c.Interval == "" {
+		c.Interval = "3m"
+	}
+	return c
+}
+
+// Engine 回测引擎：按K线时间轴推进，在每个决策时点把市场快照交给策略，并模拟成交
+type Engine struct {
+	cfg      Config
+	strategy Strategy
+	replay   *market.Replay
+	klines   map[string][]market.Kline
+}
+
+// NewEngine 创建回测引擎
+func NewEngine(cfg Config, strategy Strategy) *Engine {
+	return &Engine{
+		cfg:      cfg.withDefaults(),
+		strategy: strategy,
+		klines:   make(map[string][]market.Kline),
+	}
+}
+
+// AddKlines 加载交易对的历史K线（周期须与 Config.Interval 一致）
+func (e *Engine) AddKlines(symbol string, klines []market.Kline) {
+	sorted := append([]market.Kline(nil), klines...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].OpenTime < sorted[j].OpenTime })
+	e.klines[market.Normalize(symbol)] = sorted
+}
+
+// LoadKlines 从币安拉取回测区间内的历史K线
+func (e *Engine) LoadKlines(symbols []string) error {
+	if e.cfg.From.IsZero() || e.cfg.To.IsZero() {
+		return errors.New("从交易所加载K线需要指定回测区间 From/To")
+	}
+	client := market.NewAPIClient()
+	for _, sym := range symbols {
+		sym = market.Normalize(sym)
+		klines, err := client.GetKlinesRange(sym, e.cfg.Interval, e.cfg.From, e.cfg.To)
+		if err != nil {
+			return err
+		}
+		log.Printf("📥 [回测] %s 加载 %d 根 %s K线", sym, len(klines), e.cfg.Interval)
+		e.AddKlines(sym, klines)
+	}
+	return nil
+}
+
+// SetReplay 使用快照回放提供完整的市场数据（未设置时由K线构造简化数据）
+func (e *Engine) SetReplay(r *market.Replay) {
+	e.replay = r
+}
+
+// Run 执行回测
+func (e *Engine) Run() (*Result, error) {
+	if e.strategy == nil {
+		return nil, errors.New("未设置回测策略")
+	}
+	if len(e.klines) == 0 {
+		return nil, errors.New("没有可用的K线数据")
+	}
+	barDur, err := parseInterval(e.cfg.Interval)
+	if err != nil {
+		return nil, err
+	}
+	decisionEvery := e.cfg.DecisionEvery
+	if decisionEvery < barDur {
+		decisionEvery = barDur
+	}
+
+	// 决策引擎经包级 market.GetBatch 取数，回测期间将其切换到回放源
+	if e.replay != nil {
+		market.SetReplay(e.replay)
+		defer market.SetReplay(nil)
+	}
+
+	symbols := make([]string, 0, len(e.klines))
+	for sym := range e.klines {
+		symbols = append(symbols, sym)
+	}
+	sort.Strings(symbols)
+	timeline := e.timeline()
+	if len(timeline) == 0 {
+		return nil, errors.New("回测区间内没有K线")
+	}
+
+	b := newBroker(e.cfg)
+	cursor := make(map[string]int, len(symbols))
+	equity := make([]EquityPoint, 0, len(timeline))
+	var decisions, decisionErrors int
+	var prevDecision, nextDecision time.Time
+
+	for _, openTime := range timeline {
+		now := time.UnixMilli(openTime).Add(barDur)
+		for _, sym := range symbols {
+			ks := e.klines[sym]
+			i := cursor[sym]
+			for i < len(ks) && ks[i].OpenTime < openTime {
+				i++
+			}
+			cursor[sym] = i
+			if i >= len(ks) || ks[i].OpenTime != openTime {
+				continue
+			}
+			b.checkTriggers(sym, ks[i], now)
+			b.marks[sym] = ks[i].Close
+		}
+
+		if !now.Before(nextDecision) {
+			if prevDecision.IsZero() {
+				prevDecision = now.Add(-decisionEvery)
+			}
+			step := &StepContext{
+				Time:      now,
+				Prev:      prevDecision,
+				Account:   b.accountInfo(e.cfg.InitialBalance),
+				Positions: b.positionInfos(),
+				Symbols:   symbols,
+				Market:    e.marketData(symbols, cursor, now, barDur),
+			}
+			list, err := e.strategy.Decide(step)
+			if err != nil {
+				decisionErrors++
+				log.Printf("⚠️  [回测] %s 策略决策失败: %v", now.Format("2006-01-02 15:04"), err)
+			}
+			for _, d := range sortByPriority(list) {
+				d.Symbol = market.Normalize(d.Symbol)
+				if err := b.execute(d, now); err != nil {
+					decisionErrors++
+					log.Printf("⚠️  [回测] %s %s %s 执行失败: %v", now.Format("2006-01-02 15:04"), d.Symbol, d.Action, err)
+					continue
+				}
+				if d.Action != "hold" && d.Action != "wait" {
+					decisions++
+				}
+			}
+			prevDecision = now
+			nextDecision = now.Add(decisionEvery)
+		}
+
+		equity = append(equity, EquityPoint{Time: now, Equity: b.equity(), Balance: b.balance})
+	}
+
+	end := time.UnixMilli(timeline[len(timeline)-1]).Add(barDur)
+	b.closeAll(end)
+	equity = append(equity, EquityPoint{Time: end, Equity: b.equity(), Balance: b.balance})
+
+	result := buildResult(e.cfg, b, equity)
+	result.Decisions = decisions
+	result.DecisionErrors = decisionErrors
+	return result, nil
+}
+
+// timeline 回测区间内所有交易对K线开盘时间的并集（升序）
+func (e *Engine) timeline() []int64 {
+	seen := make(map[int64]bool)
+	var times []int64
+	for _, ks := range e.klines {
+		for _, k := range ks {
+			if !e.cfg.From.IsZero() && k.OpenTime < e.cfg.From.UnixMilli() {
+				continue
+			}
+			if !e.cfg.To.IsZero() && k.OpenTime > e.cfg.To.UnixMilli() {
+				continue
+			}
+			if !seen[k.OpenTime] {
+				seen[k.OpenTime] = true
+				times = append(times, k.OpenTime)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times
+}
+
+// marketData 当前时点各交易对的市场数据：优先使用回放快照，否则由截至当前的K线构造
+func (e *Engine) marketData(symbols []string, cursor map[string]int, now time.Time, barDur time.Duration) map[string]*market.Data {
+	if e.replay != nil {
+		e.replay.SetTime(now)
+	}
+	out := make(map[string]*market.Data, len(symbols))
+	for _, sym := range symbols {
+		if e.replay != nil {
+			if data, err := e.replay.GetWithSource(sym, nil, market.IndicatorConfig{}); err == nil {
+				out[sym] = data
+				continue
+			}
+		}
+		ks := e.klines[sym]
+		i := cursor[sym]
+		if i >= len(ks) || ks[i].OpenTime >= now.UnixMilli() {
+			i--
+		}
+		if i < 0 {
+			continue
+		}
+		out[sym] = klineSnapshot(sym, ks[:i+1], now, barDur)
+	}
+	return out
+}
+
+// klineSnapshot 由K线构造简化的市场数据（仅含价格与各周期涨跌幅）
+func klineSnapshot(symbol string, ks []market.Kline, now time.Time, barDur time.Duration) *market.Data {
+	last := ks[len(ks)-1].Close
+	change := func(d time.Duration) float64 {
+		n := int(d / barDur)
+		if n <= 0 || n >= len(ks) {
+			return 0
+		}
+		prev := ks[len(ks)-1-n].Close
+		if prev == 0 {
+			return 0
+		}
+		return (last - prev) / prev * 100
+	}
+	return &market.Data{
+		Symbol:         symbol,
+		Timestamp:      now,
+		Source:         "backtest",
+		CurrentPrice:   last,
+		PriceChange3m:  change(3 * time.Minute),
+		PriceChange15m: change(15 * time.Minute),
+		PriceChange1h:  change(time.Hour),
+		PriceChange4h:  change(4 * time.Hour),
+		PriceChange1d:  change(24 * time.Hour),
+	}
+}
+
+// sortByPriority 先平仓，再调整止盈止损，再开仓，最后观望（与实盘执行顺序一致）
+func sortByPriority(decisions []decision.Decision) []decision.Decision {
+	priority := func(action string) int {
+		switch action {
+		case "close_long", "close_short", "partial_close":
+			return 1
+		case "update_stop_loss", "update_take_profit":
+			return 2
+		case "open_long", "open_short":
+			return 3
+		case "hold", "wait":
+			return 4
+		}
+		return 999
+	}
+	sorted := append([]decision.Decision(nil), decisions...)
+	sort.SliceStable(sorted, func(i, j int) bool { return priority(sorted[i].Action) < priority(sorted[j].Action) })
+	return sorted
+}
+
+// parseInterval 解析K线周期（支持 m/h/d/w）
+func parseInterval(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("无效的K线周期: %s", interval)
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的K线周期: %s", interval)
+	}
+	switch interval[len(interval)-1:] {
+	case "m":
+		return time.Duration(n) * time.Minute, nil
+	case "h":
+		return time.Duration(n) * time.Hour, nil
+	case "d":
+		return time.Duration(n) * 24 * time.Hour, nil
+	case "w":
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("无效的K线周期: %s", interval)
+}
@@ -0,0 +1,166 @@
+package backtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Trade 一笔已平仓交易（部分平仓单独记为一笔）
+type Trade struct {
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"`
+	Quantity   float64   `json:"quantity"`
+	Leverage   int       `json:"leverage"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	OpenTime   time.Time `json:"open_time"`
+	CloseTime  time.Time `json:"close_time"`
+	PnL        float64   `json:"pnl"`     // 净盈亏（已扣除开平仓手续费）
+	PnLPct     float64   `json:"pnl_pct"` // 相对保证金的盈亏百分比
+	Fees       float64   `json:"fees"`
+	Reason     string    `json:"reason"` // 平仓原因（CloseReason*）
+}
+
+// EquityPoint 净值曲线上的一个点
+type EquityPoint struct {
+	Time        time.Time `json:"time"`
+	Equity      float64   `json:"equity"`
+	Balance     float64   `json:"balance"`
+	DrawdownPct float64   `json:"drawdown_pct"` // 相对历史最高净值的回撤百分比
+}
+
+// SymbolStats 单个交易对的回测统计
+type SymbolStats struct {
+	Symbol   string  `json:"symbol"`
+	Trades   int     `json:"trades"`
+	Wins     int     `json:"wins"`
+	Losses   int     `json:"losses"`
+	WinRate  float64 `json:"win_rate"`
+	TotalPnL float64 `json:"total_pnl"`
+	AvgPnL   float64 `json:"avg_pnl"`
+	Fees     float64 `json:"fees"`
+}
+
+// Result 回测结果
+type Result struct {
+	Start          time.Time               `json:"start"`
+	End            time.Time               `json:"end"`
+	InitialBalance float64                 `json:"initial_balance"`
+	FinalEquity    float64                 `json:"final_equity"`
+	TotalPnL       float64                 `json:"total_pnl"`
+	ReturnPct      float64                 `json:"return_pct"`
+	MaxDrawdown    float64                 `json:"max_drawdown"`     // 最大回撤（USDT）
+	MaxDrawdownPct float64                 `json:"max_drawdown_pct"` // 最大回撤百分比
+	TotalTrades    int                     `json:"total_trades"`
+	WinningTrades  int                     `json:"winning_trades"`
+	LosingTrades   int                     `json:"losing_trades"`
+	WinRate        float64                 `json:"win_rate"`
+	ProfitFactor   float64                 `json:"profit_factor"` // 总盈利/总亏损（无亏损时为0）
+	TotalFees      float64                 `json:"total_fees"`
+	Decisions      int                     `json:"decisions"`       // 执行成功的决策数
+	DecisionErrors int                     `json:"decision_errors"` // 策略报错或决策无法执行的次数
+	Trades         []Trade                 `json:"trades"`
+	Equity         []EquityPoint           `json:"equity"`
+	SymbolStats    map[string]*SymbolStats `json:"symbol_stats"`
+}
+
+// buildResult 根据成交记录与净值曲线汇总统计
+func buildResult(cfg Config, b *broker, equity []EquityPoint) *Result {
+	r := &Result{
+		InitialBalance: cfg.InitialBalance,
+		FinalEquity:    b.equity(),
+		TotalFees:      b.fees,
+		Trades:         b.trades,
+		Equity:         equity,
+		SymbolStats:    make(map[string]*SymbolStats),
+	}
+	if len(equity) > 0 {
+		r.Start = equity[0].Time
+		r.End = equity[len(equity)-1].Time
+	}
+	r.TotalPnL = r.FinalEquity - r.InitialBalance
+	if r.InitialBalance > 0 {
+		r.ReturnPct = r.TotalPnL / r.InitialBalance * 100
+	}
+
+	peak := cfg.InitialBalance
+	for i := range r.Equity {
+		pt := &r.Equity[i]
+		if pt.Equity > peak {
+			peak = pt.Equity
+		}
+		if dd := peak - pt.Equity; dd > r.MaxDrawdown {
+			r.MaxDrawdown = dd
+		}
+		if peak > 0 {
+			pt.DrawdownPct = (peak - pt.Equity) / peak * 100
+			if pt.DrawdownPct > r.MaxDrawdownPct {
+				r.MaxDrawdownPct = pt.DrawdownPct
+			}
+		}
+	}
+
+	grossWin, grossLoss := 0.0, 0.0
+	for _, t := range r.Trades {
+		s, ok := r.SymbolStats[t.Symbol]
+		if !ok {
+			s = &SymbolStats{Symbol: t.Symbol}
+			r.SymbolStats[t.Symbol] = s
+		}
+		s.Trades++
+		s.TotalPnL += t.PnL
+		s.Fees += t.Fees
+		if t.PnL > 0 {
+			s.Wins++
+			r.WinningTrades++
+			grossWin += t.PnL
+		} else {
+			s.Losses++
+			r.LosingTrades++
+			grossLoss -= t.PnL
+		}
+	}
+	r.TotalTrades = len(r.Trades)
+	if r.TotalTrades > 0 {
+		r.WinRate = float64(r.WinningTrades) / float64(r.TotalTrades) * 100
+	}
+	if grossLoss > 0 {
+		r.ProfitFactor = grossWin / grossLoss
+	}
+	for _, s := range r.SymbolStats {
+		s.WinRate = float64(s.Wins) / float64(s.Trades) * 100
+		s.AvgPnL = s.TotalPnL / float64(s.Trades)
+	}
+	return r
+}
+
+// Summary 文本格式的回测摘要
+func (r *Result) Summary() string {
+	var sb strings.Builder
+	sb.WriteString("📊 回测结果\n")
+	sb.WriteString(fmt.Sprintf("区间: %s ~ %s\n", r.Start.Format("2006-01-02 15:04"), r.End.Format("2006-01-02 15:04")))
+	sb.WriteString(fmt.Sprintf("初始资金: %.2f USDT | 最终净值: %.2f USDT | 盈亏: %+.2f USDT (%+.2f%%)\n",
+		r.InitialBalance, r.FinalEquity, r.TotalPnL, r.ReturnPct))
+	sb.WriteString(fmt.Sprintf("最大回撤: %.2f USDT (%.2f%%) | 手续费: %.2f USDT\n", r.MaxDrawdown, r.MaxDrawdownPct, r.TotalFees))
+	sb.WriteString(fmt.Sprintf("交易: %d 笔 | 盈利 %d | 亏损 %d | 胜率 %.1f%% | 盈亏比 %.2f\n",
+		r.TotalTrades, r.WinningTrades, r.LosingTrades, r.WinRate, r.ProfitFactor))
+	sb.WriteString(fmt.Sprintf("决策: 执行 %d 条 | 失败 %d 条\n", r.Decisions, r.DecisionErrors))
+
+	if len(r.SymbolStats) == 0 {
+		return sb.String()
+	}
+	symbols := make([]string, 0, len(r.SymbolStats))
+	for s := range r.SymbolStats {
+		symbols = append(symbols, s)
+	}
+	sort.Strings(symbols)
+	sb.WriteString("\n各币种表现:\n")
+	for _, sym := range symbols {
+		s := r.SymbolStats[sym]
+		sb.WriteString(fmt.Sprintf("  %-12s 交易 %d | 胜率 %.1f%% | 总盈亏 %+.2f | 平均 %+.2f | 手续费 %.2f\n",
+			s.Symbol, s.Trades, s.WinRate, s.TotalPnL, s.AvgPnL, s.Fees))
+	}
+	return sb.String()
+}
@@ -0,0 +1,187 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+)
+
+// StepContext 每个决策时点提供给策略的输入
+type StepContext struct {
+	Time      time.Time               // 当前决策时间（K线收盘时间）
+	Prev      time.Time               // 上一次决策时间
+	Account   decision.AccountInfo    // 模拟账户状态
+	Positions []decision.PositionInfo // 模拟持仓
+	Symbols   []string                // 回测交易对
+	Market    map[string]*market.Data // 当前时点的市场数据（回放快照或由K线构造的简化数据）
+}
+
+// Strategy 回测策略：根据当前时点的账户与行情给出决策
+type Strategy interface {
+	Decide(step *StepContext) ([]decision.Decision, error)
+}
+
+// StrategyFunc 函数形式的策略
+type StrategyFunc func(step *StepContext) ([]decision.Decision, error)
+
+// Decide 调用函数本身
+func (f StrategyFunc) Decide(step *StepContext) ([]decision.Decision, error) { return f(step) }
+
+// RecordedStrategy 按时间回放决策日志中记录的AI决策
+type RecordedStrategy struct {
+	records []*logger.DecisionRecord
+	next    int
+}
+
+// NewRecordedStrategy 创建决策日志回放策略（记录按时间排序）
+func NewRecordedStrategy(records []*logger.DecisionRecord) *RecordedStrategy {
+	sorted := append([]*logger.DecisionRecord(nil), records...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+	return &RecordedStrategy{records: sorted}
+}
+
+// Decide 返回 (Prev, Time] 区间内记录的全部决策，早于 Prev 的记录直接跳过
+func (s *RecordedStrategy) Decide(step *StepContext) ([]decision.Decision, error) {
+	var out []decision.Decision
+	for s.next < len(s.records) && !s.records[s.next].Timestamp.After(step.Time) {
+		rec := s.records[s.next]
+		s.next++
+		if !rec.Timestamp.After(step.Prev) {
+			continue
+		}
+		out = append(out, recordDecisions(rec)...)
+	}
+	return out, nil
+}
+
+// Symbols 决策日志中出现过的交易对
+func (s *RecordedStrategy) Symbols() []string {
+	set := make(map[string]bool)
+	for _, rec := range s.records {
+		for _, d := range recordDecisions(rec) {
+			if d.Symbol != "" {
+				set[d.Symbol] = true
+			}
+		}
+	}
+	symbols := make([]string, 0, len(set))
+	for sym := range set {
+		symbols = append(symbols, sym)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// TimeRange 决策日志覆盖的时间区间
+func (s *RecordedStrategy) TimeRange() (time.Time, time.Time) {
+	if len(s.records) == 0 {
+		return time.Time{}, time.Time{}
+	}
+	return s.records[0].Timestamp, s.records[len(s.records)-1].Timestamp
+}
+
+// recordDecisions 提取一条决策记录中的AI决策：优先解析 DecisionJSON，否则由执行成功的动作还原
+func recordDecisions(rec *logger.DecisionRecord) []decision.Decision {
+	var decisions []decision.Decision
+	if strings.TrimSpace(rec.DecisionJSON) != "" {
+		if err := json.Unmarshal([]byte(rec.DecisionJSON), &decisions); err == nil {
+			return decisions
+		}
+	}
+	for _, a := range rec.Decisions {
+		if !a.Success {
+			continue
+		}
+		d := decision.Decision{Symbol: a.Symbol, Action: a.Action, Leverage: a.Leverage}
+		switch a.Action {
+		case "open_long", "open_short":
+			d.PositionSizeUSD = a.Quantity * a.Price
+		case "auto_close_long":
+			d.Action = "close_long"
+		case "auto_close_short":
+			d.Action = "close_short"
+		case "close_long", "close_short":
+		default:
+			// 部分平仓等动作缺少比例信息，无法还原
+			continue
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
+
+// LoadDecisionRecords 读取决策日志目录（decision_*.json）中的全部记录，可按时间区间过滤（零值表示不限）
+func LoadDecisionRecords(dir string, from, to time.Time) ([]*logger.DecisionRecord, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "decision_*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("读取决策日志目录失败: %w", err)
+	}
+	var records []*logger.DecisionRecord
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("读取决策日志失败 %s: %w", f, err)
+		}
+		var rec logger.DecisionRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("解析决策日志失败 %s: %w", f, err)
+		}
+		if (!from.IsZero() && rec.Timestamp.Before(from)) || (!to.IsZero() && rec.Timestamp.After(to)) {
+			continue
+		}
+		records = append(records, &rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	return records, nil
+}
+
+// AIStrategy 在回测中调用决策引擎生成决策
+// 需通过 Engine.SetReplay 提供完整的市场快照（决策引擎经 market.GetBatch 取数）；搭配 mcp 模拟服务商可完全离线运行
+type AIStrategy struct {
+	Client          *mcp.Client
+	CustomPrompt    string
+	OverrideBase    bool
+	TemplateName    string
+	BTCETHLeverage  int
+	AltcoinLeverage int
+
+	start     time.Time
+	callCount int
+}
+
+// Decide 构造决策上下文并调用AI
+func (s *AIStrategy) Decide(step *StepContext) ([]decision.Decision, error) {
+	if s.start.IsZero() {
+		s.start = step.Time
+	}
+	s.callCount++
+	coins := make([]decision.CandidateCoin, 0, len(step.Symbols))
+	for _, sym := range step.Symbols {
+		coins = append(coins, decision.CandidateCoin{Symbol: sym, Sources: []string{"backtest"}})
+	}
+	ctx := &decision.Context{
+		TraderID:        "backtest",
+		CurrentTime:     step.Time.Format("2006-01-02 15:04:05"),
+		RuntimeMinutes:  int(step.Time.Sub(s.start).Minutes()),
+		CallCount:       s.callCount,
+		Account:         step.Account,
+		Positions:       step.Positions,
+		CandidateCoins:  coins,
+		BTCETHLeverage:  s.BTCETHLeverage,
+		AltcoinLeverage: s.AltcoinLeverage,
+	}
+	full, err := decision.GetFullDecisionWithCustomPrompt(ctx, s.Client, s.CustomPrompt, s.OverrideBase, s.TemplateName)
+	if err != nil {
+		return nil, err
+	}
+	return full.Decisions, nil
+}
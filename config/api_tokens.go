@@ -0,0 +1,104 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"nofx/auth"
+	"time"
+)
+
+// APIToken API令牌（明文只在创建时返回一次，数据库只保存哈希）
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Role       string     `json:"role"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Revoked    bool       `json:"revoked"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active 令牌是否可用（未撤销且未过期）
+func (t *APIToken) Active(now time.Time) bool {
+	return !t.Revoked && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+const apiTokenColumns = `id, user_id, name, token_hash, role, expires_at, last_used_at, revoked, created_at`
+
+// CreateAPIToken 创建API令牌
+func (d *Database) CreateAPIToken(t *APIToken) error {
+	if t.ID == "" || t.UserID == "" || t.TokenHash == "" {
+		return fmt.Errorf("API令牌的ID、用户ID与哈希不能为空")
+	}
+	if !auth.ValidRole(t.Role) {
+		return fmt.Errorf("无效的角色: %q", t.Role)
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO api_tokens (id, user_id, name, token_hash, role, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, t.ID, t.UserID, t.Name, t.TokenHash, t.Role, t.ExpiresAt)
+	return d.notify(err, TableAPITokens, OpCreate, t.UserID, t.ID)
+}
+
+// GetAPITokenByHash 按哈希查找令牌（包括已撤销、已过期的令牌，由调用方判断）
+func (d *Database) GetAPITokenByHash(hash string) (*APIToken, error) {
+	return scanAPIToken(d.db.QueryRow(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = ?`, hash))
+}
+
+// GetAPITokens 获取用户的API令牌（按创建时间倒序）
+func (d *Database) GetAPITokens(userID string) ([]*APIToken, error) {
+	rows, err := d.db.Query(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken 撤销用户的令牌（令牌不属于该用户时返回 sql.ErrNoRows）
+func (d *Database) RevokeAPIToken(userID, id string) error {
+	res, err := d.db.Exec(`UPDATE api_tokens SET revoked = 1 WHERE id = ? AND user_id = ?`, id, userID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	return d.notify(err, TableAPITokens, OpUpdate, userID, id)
+}
+
+// TouchAPIToken 记录令牌最近使用时间（不广播变更）
+func (d *Database) TouchAPIToken(id string, at time.Time) error {
+	_, err := d.db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+// rowScanner sql.Row 与 sql.Rows 的共同接口
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIToken(row rowScanner) (*APIToken, error) {
+	var t APIToken
+	var expiresAt, lastUsedAt sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.Role, &expiresAt, &lastUsedAt, &t.Revoked, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		t.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		t.LastUsedAt = &lastUsedAt.Time
+	}
+	return &t, nil
+}
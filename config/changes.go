@@ -0,0 +1,69 @@
+package config
+
+// 配置变更通知：Database 的写操作成功后向所有订阅者广播一条 Change。
+// 只覆盖通过本包写入的变更；其他进程直接修改 config.db 不会通知。
+// 订阅者消费不及时时丢弃通知（不阻塞写操作），需要完整状态时应重新读取。
+
+// 变更涉及的表
+const (
+	TableUsers         = "users"
+	TableAIModels      = "ai_models"
+	TableExchanges     = "exchanges"
+	TableTraders       = "traders"
+	TableSystemConfig  = "system_config"
+	TableSignalSources = "user_signal_sources"
+	TableAPITokens     = "api_tokens"
+)
+
+// 变更类型
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Change 一次配置变更
+type Change struct {
+	Table  string `json:"table"`
+	Op     string `json:"op"`
+	UserID string `json:"user_id,omitempty"`
+	ID     string `json:"id,omitempty"` // 记录ID（system_config 为配置键）
+}
+
+// Subscribe 订阅配置变更，返回的取消函数会关闭通道
+func (d *Database) Subscribe(buffer int) (<-chan Change, func()) {
+	ch := make(chan Change, buffer)
+	d.subMu.Lock()
+	if d.subs == nil {
+		d.subs = make(map[chan Change]struct{})
+	}
+	d.subs[ch] = struct{}{}
+	d.subMu.Unlock()
+
+	cancel := func() {
+		d.subMu.Lock()
+		defer d.subMu.Unlock()
+		if _, ok := d.subs[ch]; ok {
+			delete(d.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// notify 写操作成功后广播变更（err 不为 nil 时不通知），返回 err 便于直接 return
+func (d *Database) notify(err error, table, op, userID, id string) error {
+	if err != nil {
+		return err
+	}
+	d.subMu.Lock()
+	defer d.subMu.Unlock()
+	c := Change{Table: table, Op: op, UserID: userID, ID: id}
+	for ch := range d.subs {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+	return nil
+}
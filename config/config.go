@@ -15,7 +15,7 @@ type TraderConfig struct {
 	AIModel string `json:"ai_model"` // "qwen" or "deepseek"
 
 	// 交易平台选择（二选一）
-	Exchange string `json:"exchange"` // "binance" or "hyperliquid"
+	Exchange string `json:"exchange"` // "binance", "hyperliquid", "aster" or "paper"
 
 	// 币安配置
 	BinanceAPIKey    string `json:"binance_api_key,omitempty"`
@@ -143,8 +143,8 @@ func (c *Config) Validate() error {
 		if trader.Exchange == "" {
 			trader.Exchange = "binance" // 默认使用币安
 		}
-		if trader.Exchange != "binance" && trader.Exchange != "hyperliquid" && trader.Exchange != "aster" {
-			return fmt.Errorf("trader[%d]: exchange必须是 'binance', 'hyperliquid' 或 'aster'", i)
+		if trader.Exchange != "binance" && trader.Exchange != "hyperliquid" && trader.Exchange != "aster" && trader.Exchange != "paper" {
+			return fmt.Errorf("trader[%d]: exchange必须是 'binance', 'hyperliquid', 'aster' 或 'paper'", i)
 		}
 
 		// 根据平台验证对应的密钥
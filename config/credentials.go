@@ -0,0 +1,220 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// 交易所凭据静态加密
+//
+// exchanges 表的 api_key/secret_key/passphrase/aster_private_key 可保存为 "enc:v1:" + base64(nonce || AES-256-GCM 密文)，
+// 附加数据为 "exchanges.<列名>"，密文不能挪到其他列使用。Database 读取交易所配置时透明解密，未加密的明文照常使用，
+// 因此主程序与对账工具可以共用同一个已加密的 config.db。配置了密钥时，创建、更新交易所写入的凭据同样加密保存，
+// 不会因为在界面上修改交易所而退回明文。
+// 密钥（32 字节，base64）取自环境变量 NOFX_CREDENTIAL_KEY；也可通过 NOFX_CREDENTIAL_KEY_CMD
+// 指定一条命令在运行时输出密钥（如调用 KMS 解密数据密钥），避免密钥落盘。只有遇到密文时才加载密钥。
+
+const (
+	CredentialKeyEnv    = "NOFX_CREDENTIAL_KEY"
+	CredentialKeyCmdEnv = "NOFX_CREDENTIAL_KEY_CMD"
+	// credentialPrefix 密文前缀（含格式版本）
+	credentialPrefix = "enc:v1:"
+)
+
+// credentialColumns 需要加密的 exchanges 列
+var credentialColumns = []string{"api_key", "secret_key", "passphrase", "aster_private_key"}
+
+// credentialAEAD 进程内只加载一次密钥（密钥命令可能较慢或有调用配额）
+var credentialAEAD = sync.OnceValues(func() (cipher.AEAD, error) {
+	key, err := loadCredentialKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+})
+
+// loadCredentialKey 从环境变量或密钥命令读取 AES-256 密钥
+func loadCredentialKey() ([]byte, error) {
+	spec := strings.TrimSpace(os.Getenv(CredentialKeyEnv))
+	if spec == "" {
+		if cmdline := strings.TrimSpace(os.Getenv(CredentialKeyCmdEnv)); cmdline != "" {
+			out, err := exec.Command("sh", "-c", cmdline).Output()
+			if err != nil {
+				return nil, fmt.Errorf("执行 %s 失败: %w", CredentialKeyCmdEnv, err)
+			}
+			spec = strings.TrimSpace(string(out))
+		}
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("config.db 中的凭据已加密，请设置 %s 或 %s", CredentialKeyEnv, CredentialKeyCmdEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(spec)
+	if err != nil {
+		return nil, fmt.Errorf("凭据密钥不是合法的 base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("凭据密钥应为 32 字节（AES-256），实际 %d 字节", len(key))
+	}
+	return key, nil
+}
+
+// credentialKeyConfigured 是否配置了凭据密钥（配置后写入的凭据一律加密保存）
+func credentialKeyConfigured() bool {
+	return strings.TrimSpace(os.Getenv(CredentialKeyEnv)) != "" || strings.TrimSpace(os.Getenv(CredentialKeyCmdEnv)) != ""
+}
+
+// IsEncryptedCredential 是否为加密后的凭据
+func IsEncryptedCredential(s string) bool {
+	return strings.HasPrefix(s, credentialPrefix)
+}
+
+// encryptCredential 加密单个凭据（column 作为附加数据）
+func encryptCredential(aead cipher.AEAD, column, plain string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), []byte("exchanges."+column))
+	return credentialPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptCredential 解密单个凭据；明文原样返回
+func decryptCredential(column, s string) (string, error) {
+	if !IsEncryptedCredential(s) {
+		return s, nil
+	}
+	aead, err := credentialAEAD()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, credentialPrefix))
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("%s 密文格式错误", column)
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte("exchanges."+column))
+	if err != nil {
+		return "", fmt.Errorf("%s 解密失败（密钥不匹配或密文被篡改）", column)
+	}
+	return string(plain), nil
+}
+
+// decryptCredentials 按 credentialColumns 的顺序解密凭据字段（传 nil 跳过）
+func decryptCredentials(fields ...*string) error {
+	for i, p := range fields {
+		if p == nil {
+			continue
+		}
+		v, err := decryptCredential(credentialColumns[i], *p)
+		if err != nil {
+			return err
+		}
+		*p = v
+	}
+	return nil
+}
+
+// encryptCredentials 按 credentialColumns 的顺序加密明文凭据字段（nil、空值与已加密的跳过），返回加密的数量；
+// dryRun 时只统计
+func encryptCredentials(aead cipher.AEAD, dryRun bool, fields ...*string) (int, error) {
+	count := 0
+	for i, p := range fields {
+		if p == nil || *p == "" || IsEncryptedCredential(*p) {
+			continue
+		}
+		count++
+		if dryRun {
+			continue
+		}
+		v, err := encryptCredential(aead, credentialColumns[i], *p)
+		if err != nil {
+			return 0, err
+		}
+		*p = v
+	}
+	return count, nil
+}
+
+// sealCredentials 写入前加密凭据字段（顺序同 credentialColumns，传 nil 跳过）；未配置密钥时原样写入
+func sealCredentials(fields ...*string) error {
+	if !credentialKeyConfigured() {
+		return nil
+	}
+	aead, err := credentialAEAD()
+	if err != nil {
+		return err
+	}
+	if _, err := encryptCredentials(aead, false, fields...); err != nil {
+		return fmt.Errorf("加密交易所凭据失败: %w", err)
+	}
+	return nil
+}
+
+// decryptExchange 解密交易所配置中的凭据
+func decryptExchange(e *ExchangeConfig) error {
+	if err := decryptCredentials(&e.APIKey, &e.SecretKey, &e.Passphrase, &e.AsterPrivateKey); err != nil {
+		return fmt.Errorf("交易所 %s/%s 凭据解密失败: %w", e.UserID, e.ID, err)
+	}
+	return nil
+}
+
+// EncryptExchangeCredentials 把明文保存的交易所凭据加密（已加密的跳过），在一个事务中写回；返回加密的凭据数量。
+// dryRun 时只统计待加密的数量
+func (d *Database) EncryptExchangeCredentials(dryRun bool) (int, error) {
+	aead, err := credentialAEAD()
+	if err != nil {
+		return 0, err
+	}
+	// 直接读取原始列：queryExchanges 会解密，无法区分明文与密文
+	rows, err := d.db.Query(`
+		SELECT user_id, id, COALESCE(api_key, ''), COALESCE(secret_key, ''), COALESCE(passphrase, ''),
+		       COALESCE(aster_private_key, '')
+		FROM exchanges
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("读取 exchanges 失败: %w", err)
+	}
+	var all []ExchangeCredentials
+	for rows.Next() {
+		var c ExchangeCredentials
+		if err := rows.Scan(&c.UserID, &c.ID, &c.APIKey, &c.SecretKey, &c.Passphrase, &c.AsterPrivateKey); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("读取 exchanges 失败: %w", err)
+		}
+		all = append(all, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("读取 exchanges 失败: %w", err)
+	}
+
+	var updates []ExchangeCredentials
+	count := 0
+	for _, c := range all {
+		n, err := encryptCredentials(aead, dryRun, &c.APIKey, &c.SecretKey, &c.Passphrase, &c.AsterPrivateKey)
+		if err != nil {
+			return 0, err
+		}
+		count += n
+		if n > 0 {
+			updates = append(updates, c)
+		}
+	}
+	if dryRun || len(updates) == 0 {
+		return count, nil
+	}
+	if err := d.UpdateExchangeCredentials(updates); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -7,10 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"nofx/auth"
 	"nofx/market"
 	"os"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -19,6 +21,9 @@ import (
 // Database 配置数据库
 type Database struct {
 	db *sql.DB
+
+	subMu sync.Mutex
+	subs  map[chan Change]struct{} // 配置变更订阅者（见 changes.go）
 }
 
 // NewDatabase 创建配置数据库
@@ -40,6 +45,23 @@ func NewDatabase(dbPath string) (*Database, error) {
 	return database, nil
 }
 
+// OpenDatabase 打开已有的配置数据库（补齐新增列，但不写入默认数据），供对账等工具读取
+func OpenDatabase(dbPath string) (*Database, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("配置数据库不存在: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+	database := &Database{db: db}
+	if err := database.createTables(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建表失败: %w", err)
+	}
+	return database, nil
+}
+
 // createTables 创建数据库表
 func (d *Database) createTables() error {
 	queries := []string{
@@ -120,10 +142,25 @@ func (d *Database) createTables() error {
 			password_hash TEXT NOT NULL,
 			otp_secret TEXT,
 			otp_verified BOOLEAN DEFAULT 0,
+			role TEXT DEFAULT 'operator',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
 
+		// API令牌表（只保存令牌哈希，角色不高于所属用户）
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			role TEXT NOT NULL DEFAULT 'viewer',
+			expires_at DATETIME DEFAULT NULL,
+			last_used_at DATETIME DEFAULT NULL,
+			revoked BOOLEAN DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
 		// 系统配置表
 		`CREATE TABLE IF NOT EXISTS system_config (
 			key TEXT PRIMARY KEY,
@@ -205,6 +242,7 @@ func (d *Database) createTables() error {
 		`ALTER TABLE exchanges ADD COLUMN aster_user TEXT DEFAULT ''`,
 		`ALTER TABLE exchanges ADD COLUMN aster_signer TEXT DEFAULT ''`,
 		`ALTER TABLE exchanges ADD COLUMN aster_private_key TEXT DEFAULT ''`,
+		`ALTER TABLE exchanges ADD COLUMN passphrase TEXT DEFAULT ''`, // OKX API passphrase
 		`ALTER TABLE traders ADD COLUMN custom_prompt TEXT DEFAULT ''`,
 		`ALTER TABLE traders ADD COLUMN override_base_prompt BOOLEAN DEFAULT 0`,
 		`ALTER TABLE traders ADD COLUMN is_cross_margin BOOLEAN DEFAULT 1`,             // 默认为全仓模式
@@ -218,8 +256,11 @@ func (d *Database) createTables() error {
 		`ALTER TABLE traders ADD COLUMN coin_pool_api_url TEXT DEFAULT ''`,             // 币种池API URL
 		`ALTER TABLE traders ADD COLUMN oi_top_api_url TEXT DEFAULT ''`,                // OI Top API URL
 		`ALTER TABLE traders ADD COLUMN system_prompt_template TEXT DEFAULT 'default'`, // 系统提示词模板名称
+		`ALTER TABLE traders ADD COLUMN trailing_stop TEXT DEFAULT ''`,                 // 保本/移动止损规则（JSON）
+		`ALTER TABLE traders ADD COLUMN indicator_config TEXT DEFAULT ''`,              // 指标集合与周期（JSON）
 		`ALTER TABLE ai_models ADD COLUMN custom_api_url TEXT DEFAULT ''`,              // 自定义API地址
 		`ALTER TABLE ai_models ADD COLUMN custom_model_name TEXT DEFAULT ''`,           // 自定义模型名称
+		`ALTER TABLE users ADD COLUMN role TEXT DEFAULT 'operator'`,                    // 用户角色（viewer/operator/admin）
 		// 精简：position_meta 列在建表时已完整，不再重复 ALTER（保持其他表的向后兼容）
 	}
 
@@ -265,6 +306,7 @@ func (d *Database) initDefaultData() error {
 		{"binance", "Binance Futures", "binance"},
 		{"hyperliquid", "Hyperliquid", "hyperliquid"},
 		{"aster", "Aster DEX", "aster"},
+		{"paper", "Paper Trading", "paper"},
 	}
 
 	for _, exchange := range exchanges {
@@ -279,17 +321,26 @@ func (d *Database) initDefaultData() error {
 
 	// 初始化系统配置 - 创建所有字段，设置默认值，后续由config.json同步更新
 	systemConfigs := map[string]string{
-		"admin_mode":           "true",                                                                                // 默认开启管理员模式，便于首次使用
-		"beta_mode":            "false",                                                                               // 默认关闭内测模式
-		"api_server_port":      "8080",                                                                                // 默认API端口
-		"use_default_coins":    "true",                                                                                // 默认使用内置币种列表
-		"default_coins":        `["BTCUSDT","ETHUSDT","SOLUSDT","BNBUSDT","XRPUSDT","DOGEUSDT","ADAUSDT","HYPEUSDT"]`, // 默认币种列表（JSON格式）
-		"max_daily_loss":       "10.0",                                                                                // 最大日损失百分比
-		"max_drawdown":         "20.0",                                                                                // 最大回撤百分比
-		"stop_trading_minutes": "60",                                                                                  // 停止交易时间（分钟）
-		"btc_eth_leverage":     "5",                                                                                   // BTC/ETH杠杆倍数
-		"altcoin_leverage":     "5",                                                                                   // 山寨币杠杆倍数
-		"jwt_secret":           "",                                                                                    // JWT密钥，默认为空，由config.json或系统生成
+		"admin_mode":              "true",                                                                                // 默认开启管理员模式，便于首次使用
+		"beta_mode":               "false",                                                                               // 默认关闭内测模式
+		"api_server_port":         "8080",                                                                                // 默认API端口
+		"use_default_coins":       "true",                                                                                // 默认使用内置币种列表
+		"default_coins":           `["BTCUSDT","ETHUSDT","SOLUSDT","BNBUSDT","XRPUSDT","DOGEUSDT","ADAUSDT","HYPEUSDT"]`, // 默认币种列表（JSON格式）
+		"max_daily_loss":          "10.0",                                                                                // 最大日损失百分比
+		"max_drawdown":            "20.0",                                                                                // 最大回撤百分比
+		"stop_trading_minutes":    "60",                                                                                  // 停止交易时间（分钟）
+		"btc_eth_leverage":        "5",                                                                                   // BTC/ETH杠杆倍数
+		"altcoin_leverage":        "5",                                                                                   // 山寨币杠杆倍数
+		"max_positions":           "0",                                                                                   // 最大同时持仓数（0 表示不限制）
+		"max_notional_per_symbol": "0",                                                                                   // 单币种最大名义价值 USDT（0 表示不限制）
+		"max_correlated_exposure": "0",                                                                                   // 同向高相关持仓合计名义价值上限 USDT（0 表示不限制）
+		"risk_per_trade_pct":      "0",                                                                                   // 每笔风险占净值百分比（0 表示沿用 AI 的仓位大小）
+		"atr_stop_multiplier":     "2",                                                                                   // 止损距离的 ATR 倍数
+		"max_funding_cost_pct":    "0",                                                                                   // 预期持仓期间资金费占名义价值的百分比上限（0 表示不检查）
+		"funding_window_minutes":  "60",                                                                                  // 距下次资金费结算多少分钟内检查资金费
+		"expected_holding_hours":  "8",                                                                                   // 估算资金费的预期持仓时长（小时）
+		"hedge_in_prompt":         "false",                                                                               // 是否在 AI 提示词中附加 Delta 中性对冲建议
+		"jwt_secret":              "",                                                                                    // JWT密钥，默认为空，由config.json或系统生成
 	}
 
 	for key, value := range systemConfigs {
@@ -572,6 +623,7 @@ type User struct {
 	PasswordHash string    `json:"-"` // 不返回到前端
 	OTPSecret    string    `json:"-"` // 不返回到前端
 	OTPVerified  bool      `json:"otp_verified"`
+	Role         string    `json:"role"` // viewer/operator/admin
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -603,11 +655,13 @@ type ExchangeConfig struct {
 	// Hyperliquid 特定字段
 	HyperliquidWalletAddr string `json:"hyperliquidWalletAddr"`
 	// Aster 特定字段
-	AsterUser       string    `json:"asterUser"`
-	AsterSigner     string    `json:"asterSigner"`
-	AsterPrivateKey string    `json:"asterPrivateKey"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	AsterUser       string `json:"asterUser"`
+	AsterSigner     string `json:"asterSigner"`
+	AsterPrivateKey string `json:"asterPrivateKey"`
+	// OKX 特定字段
+	Passphrase string    `json:"passphrase"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // TraderRecord 交易员配置（数据库实体）
@@ -631,6 +685,8 @@ type TraderRecord struct {
 	OverrideBasePrompt   bool      `json:"override_base_prompt"`   // 是否覆盖基础prompt
 	SystemPromptTemplate string    `json:"system_prompt_template"` // 系统提示词模板名称
 	IsCrossMargin        bool      `json:"is_cross_margin"`        // 是否为全仓模式（true=全仓，false=逐仓）
+	TrailingStop         string    `json:"trailing_stop"`          // 保本/移动止损规则（JSON，空表示不启用）
+	IndicatorConfig      string    `json:"indicator_config"`       // 指标集合与周期（market.IndicatorConfig 的JSON，空表示默认）
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
 }
@@ -657,11 +713,17 @@ func GenerateOTPSecret() (string, error) {
 
 // CreateUser 创建用户
 func (d *Database) CreateUser(user *User) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	if user.Role == "" {
+		user.Role = auth.DefaultRole
+	}
 	_, err := d.db.Exec(`
-		INSERT INTO users (id, email, password_hash, otp_secret, otp_verified)
-		VALUES (?, ?, ?, ?, ?)
-	`, user.ID, user.Email, user.PasswordHash, user.OTPSecret, user.OTPVerified)
-	return err
+		INSERT INTO users (id, email, password_hash, otp_secret, otp_verified, role)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, user.ID, user.Email, user.PasswordHash, user.OTPSecret, user.OTPVerified, user.Role)
+	return d.notify(err, TableUsers, OpCreate, user.ID, user.ID)
 }
 
 // EnsureAdminUser 确保admin用户存在（用于管理员模式）
@@ -673,9 +735,10 @@ func (d *Database) EnsureAdminUser() error {
 		return err
 	}
 
-	// 如果已存在，直接返回
+	// 如果已存在，确保角色为admin（旧数据库迁移后默认为operator）
 	if count > 0 {
-		return nil
+		_, err := d.db.Exec(`UPDATE users SET role = ? WHERE id = 'admin' AND role != ?`, auth.RoleAdmin, auth.RoleAdmin)
+		return err
 	}
 
 	// 创建admin用户（密码为空，因为管理员模式下不需要密码）
@@ -685,6 +748,7 @@ func (d *Database) EnsureAdminUser() error {
 		PasswordHash: "", // 管理员模式下不使用密码
 		OTPSecret:    "",
 		OTPVerified:  true,
+		Role:         auth.RoleAdmin,
 	}
 
 	return d.CreateUser(adminUser)
@@ -694,11 +758,11 @@ func (d *Database) EnsureAdminUser() error {
 func (d *Database) GetUserByEmail(email string) (*User, error) {
 	var user User
 	err := d.db.QueryRow(`
-		SELECT id, email, password_hash, otp_secret, otp_verified, created_at, updated_at
+		SELECT id, email, password_hash, otp_secret, otp_verified, COALESCE(role, 'operator'), created_at, updated_at
 		FROM users WHERE email = ?
 	`, email).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.OTPSecret,
-		&user.OTPVerified, &user.CreatedAt, &user.UpdatedAt,
+		&user.OTPVerified, &user.Role, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -710,11 +774,11 @@ func (d *Database) GetUserByEmail(email string) (*User, error) {
 func (d *Database) GetUserByID(userID string) (*User, error) {
 	var user User
 	err := d.db.QueryRow(`
-		SELECT id, email, password_hash, otp_secret, otp_verified, created_at, updated_at
+		SELECT id, email, password_hash, otp_secret, otp_verified, COALESCE(role, 'operator'), created_at, updated_at
 		FROM users WHERE id = ?
 	`, userID).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.OTPSecret,
-		&user.OTPVerified, &user.CreatedAt, &user.UpdatedAt,
+		&user.OTPVerified, &user.Role, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -744,7 +808,21 @@ func (d *Database) GetAllUsers() ([]string, error) {
 // UpdateUserOTPVerified 更新用户OTP验证状态
 func (d *Database) UpdateUserOTPVerified(userID string, verified bool) error {
 	_, err := d.db.Exec(`UPDATE users SET otp_verified = ? WHERE id = ?`, verified, userID)
-	return err
+	return d.notify(err, TableUsers, OpUpdate, userID, userID)
+}
+
+// UpdateUserRole 更新用户角色
+func (d *Database) UpdateUserRole(userID, role string) error {
+	if !auth.ValidRole(role) {
+		return fmt.Errorf("无效的角色: %q", role)
+	}
+	res, err := d.db.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, userID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	return d.notify(err, TableUsers, OpUpdate, userID, userID)
 }
 
 // UpdateUserPassword 更新用户密码
@@ -754,7 +832,25 @@ func (d *Database) UpdateUserPassword(userID, passwordHash string) error {
 		SET password_hash = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, passwordHash, userID)
-	return err
+	return d.notify(err, TableUsers, OpUpdate, userID, userID)
+}
+
+// DeleteUser 删除用户及其AI模型、交易所、交易员与信号源配置
+func (d *Database) DeleteUser(userID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{TableTraders, TableExchanges, TableAIModels, TableSignalSources, TableAPITokens} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("删除用户的 %s 失败: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = ?`, userID); err != nil {
+		return err
+	}
+	return d.notify(tx.Commit(), TableUsers, OpDelete, userID, userID)
 }
 
 // GetAIModels 获取用户的AI模型配置
@@ -791,6 +887,9 @@ func (d *Database) GetAIModels(userID string) ([]*AIModelConfig, error) {
 
 // UpdateAIModel 更新AI模型配置，如果不存在则创建用户特定配置
 func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, customAPIURL, customModelName string) error {
+	if err := validateURL(customAPIURL); err != nil {
+		return fmt.Errorf("自定义API地址无效: %w", err)
+	}
 	// 先尝试精确匹配 ID（新版逻辑，支持多个相同 provider 的模型）
 	var existingID string
 	err := d.db.QueryRow(`
@@ -803,7 +902,7 @@ func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, custom
 			UPDATE ai_models SET enabled = ?, api_key = ?, custom_api_url = ?, custom_model_name = ?, updated_at = datetime('now')
 			WHERE id = ? AND user_id = ?
 		`, enabled, apiKey, customAPIURL, customModelName, existingID, userID)
-		return err
+		return d.notify(err, TableAIModels, OpUpdate, userID, existingID)
 	}
 
 	// ID 不存在，尝试兼容旧逻辑：将 id 作为 provider 查找
@@ -819,7 +918,7 @@ func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, custom
 			UPDATE ai_models SET enabled = ?, api_key = ?, custom_api_url = ?, custom_model_name = ?, updated_at = datetime('now')
 			WHERE id = ? AND user_id = ?
 		`, enabled, apiKey, customAPIURL, customModelName, existingID, userID)
-		return err
+		return d.notify(err, TableAIModels, OpUpdate, userID, existingID)
 	}
 
 	// 没有找到任何现有配置，创建新的
@@ -867,20 +966,30 @@ func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, custom
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))
 	`, newModelID, userID, name, provider, enabled, apiKey, customAPIURL, customModelName)
 
-	return err
+	return d.notify(err, TableAIModels, OpCreate, userID, newModelID)
 }
 
 // GetExchanges 获取用户的交易所配置
 func (d *Database) GetExchanges(userID string) ([]*ExchangeConfig, error) {
+	return d.queryExchanges(`WHERE user_id = ? ORDER BY id`, userID)
+}
+
+// GetAllExchanges 获取所有用户的交易所配置（供离线工具遍历凭据）
+func (d *Database) GetAllExchanges() ([]*ExchangeConfig, error) {
+	return d.queryExchanges(`ORDER BY user_id, id`)
+}
+
+// queryExchanges 按条件查询交易所配置
+func (d *Database) queryExchanges(where string, args ...any) ([]*ExchangeConfig, error) {
 	rows, err := d.db.Query(`
-		SELECT id, user_id, name, type, enabled, api_key, secret_key, testnet, 
+		SELECT id, user_id, name, type, enabled, COALESCE(api_key, ''), COALESCE(secret_key, ''), testnet,
 		       COALESCE(hyperliquid_wallet_addr, '') as hyperliquid_wallet_addr,
 		       COALESCE(aster_user, '') as aster_user,
 		       COALESCE(aster_signer, '') as aster_signer,
 		       COALESCE(aster_private_key, '') as aster_private_key,
-		       created_at, updated_at 
-		FROM exchanges WHERE user_id = ? ORDER BY id
-	`, userID)
+		       COALESCE(passphrase, '') as passphrase,
+		       created_at, updated_at
+		FROM exchanges `+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -894,21 +1003,27 @@ func (d *Database) GetExchanges(userID string) ([]*ExchangeConfig, error) {
 			&exchange.ID, &exchange.UserID, &exchange.Name, &exchange.Type,
 			&exchange.Enabled, &exchange.APIKey, &exchange.SecretKey, &exchange.Testnet,
 			&exchange.HyperliquidWalletAddr, &exchange.AsterUser,
-			&exchange.AsterSigner, &exchange.AsterPrivateKey,
+			&exchange.AsterSigner, &exchange.AsterPrivateKey, &exchange.Passphrase,
 			&exchange.CreatedAt, &exchange.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if err := decryptExchange(&exchange); err != nil {
+			return nil, err
+		}
 		exchanges = append(exchanges, &exchange)
 	}
 
-	return exchanges, nil
+	return exchanges, rows.Err()
 }
 
 // UpdateExchange 更新交易所配置，如果不存在则创建用户特定配置
 func (d *Database) UpdateExchange(userID, id string, enabled bool, apiKey, secretKey string, testnet bool, hyperliquidWalletAddr, asterUser, asterSigner, asterPrivateKey string) error {
 	log.Printf("🔧 UpdateExchange: userID=%s, id=%s, enabled=%v", userID, id, enabled)
+	if err := sealCredentials(&apiKey, &secretKey, nil, &asterPrivateKey); err != nil {
+		return err
+	}
 
 	// 首先尝试更新现有的用户配置
 	result, err := d.db.Exec(`
@@ -945,6 +1060,9 @@ func (d *Database) UpdateExchange(userID, id string, enabled bool, apiKey, secre
 		} else if id == "aster" {
 			name = "Aster DEX"
 			typ = "dex"
+		} else if id == "paper" {
+			name = "Paper Trading"
+			typ = "paper"
 		} else {
 			name = id + " Exchange"
 			typ = "cex"
@@ -964,42 +1082,136 @@ func (d *Database) UpdateExchange(userID, id string, enabled bool, apiKey, secre
 		} else {
 			log.Printf("✅ UpdateExchange: 创建记录成功")
 		}
-		return err
+		return d.notify(err, TableExchanges, OpCreate, userID, id)
 	}
 
 	log.Printf("✅ UpdateExchange: 更新现有记录成功")
+	return d.notify(nil, TableExchanges, OpUpdate, userID, id)
+}
+
+// ExchangeCredentials 交易所凭据（按 user_id + id 定位记录）
+type ExchangeCredentials struct {
+	UserID          string
+	ID              string
+	APIKey          string
+	SecretKey       string
+	Passphrase      string
+	AsterPrivateKey string
+}
+
+// UpdateExchangeCredentials 在一个事务中批量更新交易所凭据（用于凭据加密、轮换）
+func (d *Database) UpdateExchangeCredentials(creds []ExchangeCredentials) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	for _, c := range creds {
+		if err := sealCredentials(&c.APIKey, &c.SecretKey, &c.Passphrase, &c.AsterPrivateKey); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE exchanges SET api_key = ?, secret_key = ?, passphrase = ?, aster_private_key = ?, updated_at = datetime('now')
+			WHERE id = ? AND user_id = ?
+		`, c.APIKey, c.SecretKey, c.Passphrase, c.AsterPrivateKey, c.ID, c.UserID); err != nil {
+			return fmt.Errorf("更新交易所 %s/%s 凭据失败: %w", c.UserID, c.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	for _, c := range creds {
+		d.notify(nil, TableExchanges, OpUpdate, c.UserID, c.ID)
+	}
 	return nil
 }
 
+// DeleteExchange 删除交易所配置（仍被交易员使用时拒绝）
+func (d *Database) DeleteExchange(userID, id string) error {
+	var inUse int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM traders WHERE user_id = ? AND exchange_id = ?`, userID, id).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return fmt.Errorf("交易所 %s 仍被 %d 个交易员使用", id, inUse)
+	}
+	_, err := d.db.Exec(`DELETE FROM exchanges WHERE id = ? AND user_id = ?`, id, userID)
+	return d.notify(err, TableExchanges, OpDelete, userID, id)
+}
+
+// DeleteAIModel 删除AI模型配置（仍被交易员使用时拒绝）
+func (d *Database) DeleteAIModel(userID, id string) error {
+	var inUse int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM traders WHERE user_id = ? AND ai_model_id = ?`, userID, id).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return fmt.Errorf("AI模型 %s 仍被 %d 个交易员使用", id, inUse)
+	}
+	_, err := d.db.Exec(`DELETE FROM ai_models WHERE id = ? AND user_id = ?`, id, userID)
+	return d.notify(err, TableAIModels, OpDelete, userID, id)
+}
+
 // CreateAIModel 创建AI模型配置
 func (d *Database) CreateAIModel(userID, id, name, provider string, enabled bool, apiKey, customAPIURL string) error {
+	model := AIModelConfig{ID: id, UserID: userID, Name: name, Provider: provider, CustomAPIURL: customAPIURL}
+	if err := model.Validate(); err != nil {
+		return err
+	}
 	_, err := d.db.Exec(`
 		INSERT OR IGNORE INTO ai_models (id, user_id, name, provider, enabled, api_key, custom_api_url) 
 		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`, id, userID, name, provider, enabled, apiKey, customAPIURL)
-	return err
+	return d.notify(err, TableAIModels, OpCreate, userID, id)
 }
 
 // CreateExchange 创建交易所配置
 func (d *Database) CreateExchange(userID, id, name, typ string, enabled bool, apiKey, secretKey string, testnet bool, hyperliquidWalletAddr, asterUser, asterSigner, asterPrivateKey string) error {
+	exchange := ExchangeConfig{ID: id, UserID: userID, Name: name, Type: typ}
+	if err := exchange.Validate(); err != nil {
+		return err
+	}
+	if err := sealCredentials(&apiKey, &secretKey, nil, &asterPrivateKey); err != nil {
+		return err
+	}
 	_, err := d.db.Exec(`
 		INSERT OR IGNORE INTO exchanges (id, user_id, name, type, enabled, api_key, secret_key, testnet, hyperliquid_wallet_addr, aster_user, aster_signer, aster_private_key) 
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, id, userID, name, typ, enabled, apiKey, secretKey, testnet, hyperliquidWalletAddr, asterUser, asterSigner, asterPrivateKey)
-	return err
+	return d.notify(err, TableExchanges, OpCreate, userID, id)
 }
 
 // CreateTrader 创建交易员
 func (d *Database) CreateTrader(trader *TraderRecord) error {
+	if err := trader.Validate(); err != nil {
+		return err
+	}
 	_, err := d.db.Exec(`
-		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, coin_pool_api_url, oi_top_api_url, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CoinPoolAPIURL, trader.OITopAPIURL, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin)
-	return err
+		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, coin_pool_api_url, oi_top_api_url, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin, trailing_stop, indicator_config)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CoinPoolAPIURL, trader.OITopAPIURL, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin, trader.TrailingStop, trader.IndicatorConfig)
+	return d.notify(err, TableTraders, OpCreate, trader.UserID, trader.ID)
 }
 
 // GetTraders 获取用户的交易员
 func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
+	return d.queryTraders(`WHERE user_id = ? ORDER BY created_at DESC`, userID)
+}
+
+// GetTrader 获取单个交易员，不存在时返回 sql.ErrNoRows
+func (d *Database) GetTrader(userID, id string) (*TraderRecord, error) {
+	traders, err := d.queryTraders(`WHERE user_id = ? AND id = ?`, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(traders) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return traders[0], nil
+}
+
+// queryTraders 按条件查询交易员
+func (d *Database) queryTraders(where string, args ...any) ([]*TraderRecord, error) {
 	rows, err := d.db.Query(`
 		SELECT id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running,
 		       COALESCE(btc_eth_leverage, 5) as btc_eth_leverage, COALESCE(altcoin_leverage, 5) as altcoin_leverage,
@@ -1008,9 +1220,9 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 		       COALESCE(coin_pool_api_url, '') as coin_pool_api_url, COALESCE(oi_top_api_url, '') as oi_top_api_url,
 		       COALESCE(custom_prompt, '') as custom_prompt, COALESCE(override_base_prompt, 0) as override_base_prompt,
 		       COALESCE(system_prompt_template, 'default') as system_prompt_template,
-		       COALESCE(is_cross_margin, 1) as is_cross_margin, created_at, updated_at
-		FROM traders WHERE user_id = ? ORDER BY created_at DESC
-	`, userID)
+		       COALESCE(is_cross_margin, 1) as is_cross_margin, COALESCE(trailing_stop, '') as trailing_stop,
+		       COALESCE(indicator_config, '') as indicator_config, created_at, updated_at
+		FROM traders `+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1026,7 +1238,7 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 			&trader.UseCoinPool, &trader.UseOITop,
 			&trader.CoinPoolAPIURL, &trader.OITopAPIURL,
 			&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
-			&trader.IsCrossMargin,
+			&trader.IsCrossMargin, &trader.TrailingStop, &trader.IndicatorConfig,
 			&trader.CreatedAt, &trader.UpdatedAt,
 		)
 		if err != nil {
@@ -1035,49 +1247,61 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 		traders = append(traders, &trader)
 	}
 
-	return traders, nil
+	return traders, rows.Err()
 }
 
 // UpdateTraderStatus 更新交易员状态
 func (d *Database) UpdateTraderStatus(userID, id string, isRunning bool) error {
 	_, err := d.db.Exec(`UPDATE traders SET is_running = ? WHERE id = ? AND user_id = ?`, isRunning, id, userID)
-	return err
+	return d.notify(err, TableTraders, OpUpdate, userID, id)
 }
 
 // UpdateTrader 更新交易员配置
 func (d *Database) UpdateTrader(trader *TraderRecord) error {
+	if err := trader.Validate(); err != nil {
+		return err
+	}
 	_, err := d.db.Exec(`
 		UPDATE traders SET
 			name = ?, ai_model_id = ?, exchange_id = ?, initial_balance = ?,
 			scan_interval_minutes = ?, btc_eth_leverage = ?, altcoin_leverage = ?,
 			trading_symbols = ?, use_coin_pool = ?, use_oi_top = ?,
 			coin_pool_api_url = ?, oi_top_api_url = ?, custom_prompt = ?, override_base_prompt = ?,
-			system_prompt_template = ?, is_cross_margin = ?, updated_at = CURRENT_TIMESTAMP
+			system_prompt_template = ?, is_cross_margin = ?, trailing_stop = ?, indicator_config = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND user_id = ?
 	`, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance,
 		trader.ScanIntervalMinutes, trader.BTCETHLeverage, trader.AltcoinLeverage,
 		trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop,
 		trader.CoinPoolAPIURL, trader.OITopAPIURL, trader.CustomPrompt, trader.OverrideBasePrompt,
-		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ID, trader.UserID)
-	return err
+		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.TrailingStop, trader.IndicatorConfig, trader.ID, trader.UserID)
+	return d.notify(err, TableTraders, OpUpdate, trader.UserID, trader.ID)
 }
 
 // UpdateTraderCustomPrompt 更新交易员自定义Prompt
 func (d *Database) UpdateTraderCustomPrompt(userID, id string, customPrompt string, overrideBase bool) error {
 	_, err := d.db.Exec(`UPDATE traders SET custom_prompt = ?, override_base_prompt = ? WHERE id = ? AND user_id = ?`, customPrompt, overrideBase, id, userID)
-	return err
+	return d.notify(err, TableTraders, OpUpdate, userID, id)
+}
+
+// UpdateTraderPromptTemplate 更新交易员使用的系统提示词模板
+func (d *Database) UpdateTraderPromptTemplate(userID, id, template string) error {
+	if strings.TrimSpace(template) == "" {
+		template = "default"
+	}
+	_, err := d.db.Exec(`UPDATE traders SET system_prompt_template = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, template, id, userID)
+	return d.notify(err, TableTraders, OpUpdate, userID, id)
 }
 
 // UpdateTraderInitialBalance 更新交易员初始余额（用于自动同步交易所实际余额）
 func (d *Database) UpdateTraderInitialBalance(userID, id string, newBalance float64) error {
 	_, err := d.db.Exec(`UPDATE traders SET initial_balance = ? WHERE id = ? AND user_id = ?`, newBalance, id, userID)
-	return err
+	return d.notify(err, TableTraders, OpUpdate, userID, id)
 }
 
 // DeleteTrader 删除交易员
 func (d *Database) DeleteTrader(userID, id string) error {
 	_, err := d.db.Exec(`DELETE FROM traders WHERE id = ? AND user_id = ?`, id, userID)
-	return err
+	return d.notify(err, TableTraders, OpDelete, userID, id)
 }
 
 // GetTraderConfig 获取交易员完整配置（包含AI模型和交易所信息）
@@ -1100,6 +1324,8 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 			COALESCE(t.override_base_prompt, 0) as override_base_prompt,
 			COALESCE(t.system_prompt_template, 'default') as system_prompt_template,
 			COALESCE(t.is_cross_margin, 1) as is_cross_margin,
+			COALESCE(t.trailing_stop, '') as trailing_stop,
+			COALESCE(t.indicator_config, '') as indicator_config,
 			t.created_at, t.updated_at,
 			a.id, a.user_id, a.name, a.provider, a.enabled, a.api_key,
 			COALESCE(a.custom_api_url, '') as custom_api_url,
@@ -1110,6 +1336,7 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 			COALESCE(e.aster_user, '') as aster_user,
 			COALESCE(e.aster_signer, '') as aster_signer,
 			COALESCE(e.aster_private_key, '') as aster_private_key,
+			COALESCE(e.passphrase, '') as passphrase,
 			e.created_at, e.updated_at
 		FROM traders t
 		JOIN ai_models a ON t.ai_model_id = a.id AND t.user_id = a.user_id
@@ -1122,7 +1349,7 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 		&trader.UseCoinPool, &trader.UseOITop,
 		&trader.CoinPoolAPIURL, &trader.OITopAPIURL,
 		&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
-		&trader.IsCrossMargin,
+		&trader.IsCrossMargin, &trader.TrailingStop, &trader.IndicatorConfig,
 		&trader.CreatedAt, &trader.UpdatedAt,
 		&aiModel.ID, &aiModel.UserID, &aiModel.Name, &aiModel.Provider, &aiModel.Enabled, &aiModel.APIKey,
 		&aiModel.CustomAPIURL, &aiModel.CustomModelName,
@@ -1130,12 +1357,16 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 		&exchange.ID, &exchange.UserID, &exchange.Name, &exchange.Type, &exchange.Enabled,
 		&exchange.APIKey, &exchange.SecretKey, &exchange.Testnet,
 		&exchange.HyperliquidWalletAddr, &exchange.AsterUser, &exchange.AsterSigner, &exchange.AsterPrivateKey,
+		&exchange.Passphrase,
 		&exchange.CreatedAt, &exchange.UpdatedAt,
 	)
 
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	if err := decryptExchange(&exchange); err != nil {
+		return nil, nil, nil, err
+	}
 
 	return &trader, &aiModel, &exchange, nil
 }
@@ -1152,7 +1383,7 @@ func (d *Database) SetSystemConfig(key, value string) error {
 	_, err := d.db.Exec(`
 		INSERT OR REPLACE INTO system_config (key, value) VALUES (?, ?)
 	`, key, value)
-	return err
+	return d.notify(err, TableSystemConfig, OpUpdate, "", key)
 }
 
 // CreateUserSignalSource 创建用户信号源配置
@@ -1161,7 +1392,7 @@ func (d *Database) CreateUserSignalSource(userID, coinPoolURL, oiTopURL string)
 		INSERT OR REPLACE INTO user_signal_sources (user_id, coin_pool_url, oi_top_url, updated_at)
 		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
 	`, userID, coinPoolURL, oiTopURL)
-	return err
+	return d.notify(err, TableSignalSources, OpCreate, userID, userID)
 }
 
 // GetUserSignalSource 获取用户信号源配置
@@ -1186,7 +1417,7 @@ func (d *Database) UpdateUserSignalSource(userID, coinPoolURL, oiTopURL string)
 		UPDATE user_signal_sources SET coin_pool_url = ?, oi_top_url = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE user_id = ?
 	`, coinPoolURL, oiTopURL, userID)
-	return err
+	return d.notify(err, TableSignalSources, OpUpdate, userID, userID)
 }
 
 // GetCustomCoins 获取所有交易员自定义币种 / Get all trader-customized currencies
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"nofx/auth"
+	"strings"
+)
+
+// 写入 config.db 前的字段校验：只检查会让记录无法使用的取值，业务默认值由调用方补齐。
+
+// maxLeverage 交易所支持的最大杠杆
+const maxLeverage = 125
+
+// Validate 校验用户
+func (u *User) Validate() error {
+	if strings.TrimSpace(u.ID) == "" {
+		return fmt.Errorf("用户ID不能为空")
+	}
+	if _, err := mail.ParseAddress(u.Email); err != nil {
+		return fmt.Errorf("邮箱格式无效: %q", u.Email)
+	}
+	if u.Role != "" && !auth.ValidRole(u.Role) {
+		return fmt.Errorf("无效的角色: %q", u.Role)
+	}
+	return nil
+}
+
+// Validate 校验AI模型配置
+func (m *AIModelConfig) Validate() error {
+	if strings.TrimSpace(m.ID) == "" || strings.TrimSpace(m.UserID) == "" {
+		return fmt.Errorf("AI模型的ID与用户ID不能为空")
+	}
+	if strings.TrimSpace(m.Provider) == "" {
+		return fmt.Errorf("AI模型 %s 缺少 provider", m.ID)
+	}
+	if err := validateURL(m.CustomAPIURL); err != nil {
+		return fmt.Errorf("AI模型 %s 的自定义API地址无效: %w", m.ID, err)
+	}
+	return nil
+}
+
+// Validate 校验交易所配置
+func (e *ExchangeConfig) Validate() error {
+	if strings.TrimSpace(e.ID) == "" || strings.TrimSpace(e.UserID) == "" {
+		return fmt.Errorf("交易所的ID与用户ID不能为空")
+	}
+	if strings.TrimSpace(e.Name) == "" {
+		return fmt.Errorf("交易所 %s 缺少名称", e.ID)
+	}
+	return nil
+}
+
+// Validate 校验交易员配置
+func (t *TraderRecord) Validate() error {
+	if strings.TrimSpace(t.ID) == "" || strings.TrimSpace(t.UserID) == "" {
+		return fmt.Errorf("交易员的ID与用户ID不能为空")
+	}
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("交易员名称不能为空")
+	}
+	if t.AIModelID == "" || t.ExchangeID == "" {
+		return fmt.Errorf("交易员 %s 必须绑定AI模型与交易所", t.Name)
+	}
+	if t.InitialBalance < 0 {
+		return fmt.Errorf("初始余额不能为负数: %.2f", t.InitialBalance)
+	}
+	if t.ScanIntervalMinutes < 0 {
+		return fmt.Errorf("扫描间隔不能为负数: %d", t.ScanIntervalMinutes)
+	}
+	for _, lev := range []int{t.BTCETHLeverage, t.AltcoinLeverage} {
+		if lev < 0 || lev > maxLeverage {
+			return fmt.Errorf("杠杆倍数超出范围 (1-%d): %d", maxLeverage, lev)
+		}
+	}
+	for _, u := range []string{t.CoinPoolAPIURL, t.OITopAPIURL} {
+		if err := validateURL(u); err != nil {
+			return fmt.Errorf("信号源地址无效: %w", err)
+		}
+	}
+	if t.TrailingStop != "" && !json.Valid([]byte(t.TrailingStop)) {
+		return fmt.Errorf("移动止损规则不是合法的JSON")
+	}
+	if t.IndicatorConfig != "" && !json.Valid([]byte(t.IndicatorConfig)) {
+		return fmt.Errorf("指标配置不是合法的JSON")
+	}
+	return nil
+}
+
+// validateURL 空字符串或 http(s) 地址
+func validateURL(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("需要 http(s)://host 形式: %q", s)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"nofx/market"
+	"nofx/mcp"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 行情压缩：两阶段生成决策 prompt。第一阶段把每个币种的多周期行情（market.Format 输出，
+// 其中逐点序列占大部分 token）压缩为紧凑统计，第二阶段再把压缩结果嵌入主决策 prompt。
+//
+//	deterministic  把每个数值序列替换为 {n 首 末 低 高 变化%}，不调用模型
+//	model          由较便宜的模型（PromptCompression.Model）写摘要；失败或不比 deterministic 更短时回退到 deterministic
+//
+// 压缩率与预计节省的费用（主模型少付的输入费用 - 摘要模型的费用）记录在 FullDecision.Compression。
+
+// 压缩方式
+const (
+	CompressionOff           = "off"
+	CompressionDeterministic = "deterministic"
+	CompressionModel         = "model"
+)
+
+// compressionConcurrency 摘要模型的并发调用数
+const compressionConcurrency = 4
+
+// compressionMaxTokens 摘要模型单个币种的输出上限
+const compressionMaxTokens = 600
+
+// reNumericSeries 行情文本中的数值序列（至少 5 个数，如 [1.000, 2.000, 3.000, 4.000, 5.000]）
+var reNumericSeries = regexp.MustCompile(`\[(-?[0-9.eE+]+(?:, -?[0-9.eE+]+){4,})\]`)
+
+// PromptCompression 行情压缩配置
+type PromptCompression struct {
+	Mode string `json:"mode"` // off（默认）/ deterministic / model
+	// Model 摘要使用的模型（与交易员相同的服务商与密钥），为空时使用交易员自己的模型
+	Model string `json:"model"`
+	// MinTokens 单个币种行情估算 token 数低于该值时不压缩（默认 0，全部压缩）
+	MinTokens int `json:"min_tokens"`
+}
+
+// CompressionReport 一次决策的行情压缩结果
+type CompressionReport struct {
+	Mode             string  `json:"mode"`
+	Symbols          int     `json:"symbols"`           // 压缩的币种数
+	Fallbacks        int     `json:"fallbacks"`         // model 模式下回退到 deterministic 的币种数
+	OriginalTokens   int     `json:"original_tokens"`   // 压缩前的行情估算 token 数
+	CompressedTokens int     `json:"compressed_tokens"` // 压缩后的行情估算 token 数
+	Ratio            float64 `json:"ratio"`             // 压缩后 / 压缩前
+	SummarizerTokens int     `json:"summarizer_tokens"` // 摘要模型消耗的估算 token 数（输入+输出）
+	SavedUSD         float64 `json:"saved_usd"`         // 预计节省的费用（需配置 ai_pricing，可能为负）
+}
+
+// String 日志与决策记录中使用的摘要
+func (r *CompressionReport) String() string {
+	s := fmt.Sprintf("行情压缩(%s): %d个币种 %d→%d tokens (%.0f%%)", r.Mode, r.Symbols, r.OriginalTokens, r.CompressedTokens, r.Ratio*100)
+	if r.Fallbacks > 0 {
+		s += fmt.Sprintf("，%d个回退为确定性压缩", r.Fallbacks)
+	}
+	if r.SavedUSD != 0 {
+		s += fmt.Sprintf("，预计节省 $%.4f", r.SavedUSD)
+	}
+	return s
+}
+
+var (
+	compressionMu sync.RWMutex
+	compression   = PromptCompression{Mode: CompressionOff}
+)
+
+// SetPromptCompression 设置行情压缩方式（所有交易员共用）
+func SetPromptCompression(c PromptCompression) error {
+	if c.Mode == "" {
+		c.Mode = CompressionOff
+	}
+	switch c.Mode {
+	case CompressionOff, CompressionDeterministic, CompressionModel:
+	default:
+		return fmt.Errorf("未知的行情压缩方式: %s", c.Mode)
+	}
+	if c.MinTokens < 0 {
+		return fmt.Errorf("行情压缩阈值不能为负: %d", c.MinTokens)
+	}
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	compression = c
+	return nil
+}
+
+// currentPromptCompression 返回当前压缩配置
+func currentPromptCompression() PromptCompression {
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+	return compression
+}
+
+// compressionItem 单个币种的压缩过程
+type compressionItem struct {
+	symbol, original, compressed string
+	promptTokens, outputTokens   int // 摘要模型的估算输入/输出 token
+	fallback                     bool
+}
+
+// compressMarketData 第一阶段：压缩上下文中各币种的行情文本，写入 ctx.CompressedMarket（未启用时返回 nil）
+func compressMarketData(ctx *Context, mcpClient *mcp.Client) *CompressionReport {
+	cfg := currentPromptCompression()
+	if cfg.Mode == CompressionOff || len(ctx.MarketDataMap) == 0 {
+		return nil
+	}
+
+	items := make([]*compressionItem, 0, len(ctx.MarketDataMap))
+	for symbol, data := range ctx.MarketDataMap {
+		text := market.Format(data)
+		if mcp.EstimateTokens(text) < cfg.MinTokens {
+			continue
+		}
+		items = append(items, &compressionItem{symbol: symbol, original: text})
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	var summarizer *mcp.Client
+	if cfg.Mode == CompressionModel && mcpClient != nil {
+		c := *mcpClient
+		if cfg.Model != "" {
+			c.Model = cfg.Model
+		}
+		summarizer = &c
+	}
+	opts := mcp.CallOptions{Temperature: mcp.Float64(0), MaxTokens: compressionMaxTokens}.WithTags(costTags(ctx))
+
+	sem := make(chan struct{}, compressionConcurrency)
+	var wg sync.WaitGroup
+	for _, it := range items {
+		deterministic := summarizeSeries(it.original)
+		if summarizer == nil {
+			it.compressed = deterministic
+			continue
+		}
+		wg.Add(1)
+		go func(it *compressionItem) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			summary, err := summarizeWithModel(summarizer, it, opts)
+			if err != nil || summary == "" || mcp.EstimateTokens(summary) >= mcp.EstimateTokens(deterministic) {
+				if err != nil {
+					log.Printf("⚠️  %s 行情摘要失败，使用确定性压缩: %v", it.symbol, err)
+				}
+				it.compressed, it.fallback = deterministic, true
+				return
+			}
+			it.compressed = summary
+		}(it)
+	}
+	wg.Wait()
+
+	report := &CompressionReport{Mode: cfg.Mode, Symbols: len(items)}
+	ctx.CompressedMarket = make(map[string]string, len(items))
+	summarizerPrompt, summarizerOutput := 0, 0
+	for _, it := range items {
+		ctx.CompressedMarket[it.symbol] = it.compressed
+		report.OriginalTokens += mcp.EstimateTokens(it.original)
+		report.CompressedTokens += mcp.EstimateTokens(it.compressed)
+		summarizerPrompt += it.promptTokens
+		summarizerOutput += it.outputTokens
+		if it.fallback {
+			report.Fallbacks++
+		}
+	}
+	report.SummarizerTokens = summarizerPrompt + summarizerOutput
+	if report.OriginalTokens > 0 {
+		report.Ratio = float64(report.CompressedTokens) / float64(report.OriginalTokens)
+	}
+	if mcpClient != nil {
+		saved := mcp.EstimateCost(mcpClient.Model, report.OriginalTokens-report.CompressedTokens, 0)
+		if summarizer != nil {
+			saved -= mcp.EstimateCost(summarizer.Model, summarizerPrompt, summarizerOutput)
+		}
+		report.SavedUSD = saved
+	}
+	return report
+}
+
+// summarizeWithModel 由摘要模型压缩单个币种的行情，并记录估算的输入/输出 token 数
+func summarizeWithModel(client *mcp.Client, it *compressionItem, opts mcp.CallOptions) (string, error) {
+	systemPrompt := "你是行情数据压缩器。把用户给出的单个币种多周期行情压缩成紧凑的统计摘要：" +
+		"保留当前价格、各周期关键指标的最新值与方向（上升/下降/震荡）、序列的高低点与变化幅度、" +
+		"资金费率、持仓量、关键价位等数值结论；删除逐点序列与说明性文字。" +
+		"只输出摘要本身，不给交易建议，不超过400字。"
+	userPrompt := fmt.Sprintf("币种: %s\n\n%s", it.symbol, it.original)
+	summary, err := client.CallWithOptions(systemPrompt, userPrompt, opts)
+	it.promptTokens = mcp.EstimateTokens(systemPrompt) + mcp.EstimateTokens(userPrompt)
+	it.outputTokens = mcp.EstimateTokens(summary)
+	return strings.TrimSpace(summary), err
+}
+
+// summarizeSeries 确定性压缩：把每个数值序列替换为 {n 首 末 低 高 变化%}（数值保持原有精度）
+func summarizeSeries(text string) string {
+	return reNumericSeries.ReplaceAllStringFunc(text, func(m string) string {
+		parts := strings.Split(m[1:len(m)-1], ", ")
+		values := make([]float64, len(parts))
+		lo, hi := 0, 0
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil {
+				return m
+			}
+			values[i] = v
+			if v < values[lo] {
+				lo = i
+			}
+			if v > values[hi] {
+				hi = i
+			}
+		}
+		first, last := values[0], values[len(values)-1]
+		s := fmt.Sprintf("{n=%d 首%s 末%s 低%s 高%s", len(parts), parts[0], parts[len(parts)-1], parts[lo], parts[hi])
+		if first != 0 {
+			s += fmt.Sprintf(" 变化%+.2f%%", (last-first)/math.Abs(first)*100)
+		}
+		return s + "}"
+	})
+}
+
+// marketText 第二阶段：prompt 中使用的币种行情（有压缩结果时使用压缩结果）
+func marketText(ctx *Context, symbol string, data *market.Data) string {
+	if text, ok := ctx.CompressedMarket[symbol]; ok {
+		return text
+	}
+	return market.Format(data)
+}
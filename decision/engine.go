@@ -2,27 +2,19 @@ package decision
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"nofx/account"
+	"nofx/decisions"
 	"nofx/market"
 	"nofx/mcp"
 	"nofx/pool"
-	"regexp"
 	"strings"
 	"time"
 )
 
-// 预编译正则表达式（性能优化：避免每次调用时重新编译）
-var (
-	// ✅ 安全的正則：精確匹配 ```json 代碼塊
-	// 使用反引號 + 拼接避免轉義問題
-	reJSONFence      = regexp.MustCompile(`(?is)` + "```json\\s*(\\[\\s*\\{.*?\\}\\s*\\])\\s*```")
-	reJSONArray      = regexp.MustCompile(`(?is)\[\s*\{.*?\}\s*\]`)
-	reArrayHead      = regexp.MustCompile(`^\[\s*\{`)
-	reArrayOpenSpace = regexp.MustCompile(`^\[\s+\{`)
-	reInvisibleRunes = regexp.MustCompile("[\u200B\u200C\u200D\uFEFF]")
-)
-
 // PositionInfo 持仓信息
 type PositionInfo struct {
 	Symbol            string  `json:"symbol"`
@@ -68,17 +60,26 @@ type OITopData struct {
 
 // Context 交易上下文（传递给AI的完整信息）
 type Context struct {
-	CurrentTime     string                  `json:"current_time"`
-	RuntimeMinutes  int                     `json:"runtime_minutes"`
-	CallCount       int                     `json:"call_count"`
-	Account         AccountInfo             `json:"account"`
-	Positions       []PositionInfo          `json:"positions"`
-	CandidateCoins  []CandidateCoin         `json:"candidate_coins"`
-	MarketDataMap   map[string]*market.Data `json:"-"` // 不序列化，但内部使用
-	OITopDataMap    map[string]*OITopData   `json:"-"` // OI Top数据映射
-	Performance     interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
-	BTCETHLeverage  int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
-	AltcoinLeverage int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	TraderID       string                  `json:"-"` // 交易员ID（用于AI成本归属）
+	Experiment     string                  `json:"-"` // A/B 实验分组（交易员ID:实验名/组名，用于AI成本归属，未参与实验时为空）
+	CurrentTime    string                  `json:"current_time"`
+	RuntimeMinutes int                     `json:"runtime_minutes"`
+	CallCount      int                     `json:"call_count"`
+	Account        AccountInfo             `json:"account"`
+	Exposure       *account.Snapshot       `json:"exposure,omitempty"` // 组合敞口（获取失败时为 nil）
+	Positions      []PositionInfo          `json:"positions"`
+	CandidateCoins []CandidateCoin         `json:"candidate_coins"`
+	MarketDataMap  map[string]*market.Data `json:"-"` // 不序列化，但内部使用
+	OITopDataMap   map[string]*OITopData   `json:"-"` // OI Top数据映射
+	Performance    interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
+	Feedback       string                  `json:"-"` // 近期开仓决策的实际结果摘要（performance 包生成）
+	Hedges         string                  `json:"-"` // Delta 中性对冲建议（account.FormatHedges，未启用时为空）
+	// CompressedMarket 压缩后的各币种行情（见 SetPromptCompression，未启用时为 nil）
+	CompressedMarket map[string]string       `json:"-"`
+	BTCETHLeverage   int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
+	AltcoinLeverage  int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	IndicatorConfig  *market.IndicatorConfig `json:"-"` // 指标配置（nil 时使用默认指标集合）
+	MarketSource     string                  `json:"-"` // 行情数据源（binance/okx/bybit，空则按交易对选择）
 }
 
 // Decision AI的交易决策
@@ -96,6 +97,7 @@ type Decision struct {
 	NewStopLoss     float64 `json:"new_stop_loss,omitempty"`    // 用于 update_stop_loss
 	NewTakeProfit   float64 `json:"new_take_profit,omitempty"`  // 用于 update_take_profit
 	ClosePercentage float64 `json:"close_percentage,omitempty"` // 用于 partial_close (0-100)
+	PositionSide    string  `json:"position_side,omitempty"`    // 用于 update_stop_loss：long/short（同币种多空并存时指定方向，为空取第一个持仓）
 
 	// 通用参数
 	Confidence        int     `json:"confidence,omitempty"` // 信心度 (0-100)
@@ -111,6 +113,8 @@ type FullDecision struct {
 	CoTTrace     string     `json:"cot_trace"`     // 思维链分析（AI输出）
 	Decisions    []Decision `json:"decisions"`     // 具体决策列表
 	Timestamp    time.Time  `json:"timestamp"`
+	// Compression 行情压缩结果（未启用时为 nil）
+	Compression *CompressionReport `json:"compression,omitempty"`
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -127,16 +131,25 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
 
 	// 2. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
 	systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
-	userPrompt := buildUserPrompt(ctx)
+	// 启用行情压缩时，先把各币种的多周期序列压缩为统计摘要，再嵌入主 prompt
+	compression := compressMarketData(ctx, mcpClient)
+	if compression != nil {
+		log.Printf("🗜️  %s", compression)
+	}
+	userPrompt := buildUserPromptWithBudget(ctx, mcpClient.PromptBudget(systemPrompt, mcp.DecisionCallOptions().MaxTokens))
 
-	// 3. 调用AI API（使用 system + user prompt）
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	// 3. 调用AI API（使用 system + user prompt）；推理模型的思考过程单独返回，不参与 JSON 提取
+	aiResponse, reasoning, err := mcpClient.CallWithReasoning(systemPrompt, userPrompt, mcp.DecisionCallOptions().WithTags(costTags(ctx)))
 	if err != nil {
 		return nil, fmt.Errorf("调用AI API失败: %w", err)
 	}
 
-	// 4. 解析AI响应
+	// 4. 解析AI响应（思考过程放在思维链之前一并记录）
 	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	decision.Compression = compression
+	if reasoning != "" {
+		decision.CoTTrace = strings.TrimSpace(reasoning + "\n\n" + decision.CoTTrace)
+	}
 	if err != nil {
 		return decision, fmt.Errorf("解析AI响应失败: %w", err)
 	}
@@ -147,6 +160,38 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
 	return decision, nil
 }
 
+// costTags 本次决策的成本归属标签：交易员、周期，以及参与分析的币种（持仓+候选，用量按币种平均分摊）
+func costTags(ctx *Context) map[string]string {
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, pos := range ctx.Positions {
+		if !seen[pos.Symbol] {
+			seen[pos.Symbol] = true
+			symbols = append(symbols, pos.Symbol)
+		}
+	}
+	for _, coin := range ctx.CandidateCoins {
+		if !seen[coin.Symbol] {
+			seen[coin.Symbol] = true
+			symbols = append(symbols, coin.Symbol)
+		}
+	}
+	tags := map[string]string{
+		mcp.TagCycleID: fmt.Sprintf("%d", ctx.CallCount),
+	}
+	if ctx.TraderID != "" {
+		tags[mcp.TagTraderID] = ctx.TraderID
+		tags[mcp.TagCycleID] = fmt.Sprintf("%s#%d", ctx.TraderID, ctx.CallCount)
+	}
+	if len(symbols) > 0 {
+		tags[mcp.TagSymbol] = strings.Join(symbols, ",")
+	}
+	if ctx.Experiment != "" {
+		tags[mcp.TagExperiment] = ctx.Experiment
+	}
+	return tags
+}
+
 // fetchMarketDataForContext 为上下文中的所有币种获取市场数据和OI数据
 func fetchMarketDataForContext(ctx *Context) error {
 	ctx.MarketDataMap = make(map[string]*market.Data)
@@ -176,12 +221,37 @@ func fetchMarketDataForContext(ctx *Context) error {
 		positionSymbols[pos.Symbol] = true
 	}
 
+	symbols := make([]string, 0, len(symbolSet))
 	for symbol := range symbolSet {
-		data, err := market.Get(symbol)
+		symbols = append(symbols, symbol)
+	}
+	indicatorConfig := market.DefaultIndicatorConfig()
+	if ctx.IndicatorConfig != nil {
+		indicatorConfig = *ctx.IndicatorConfig
+	}
+	// 交易员指定了行情数据源时统一使用该数据源，否则按交易对选择（默认币安）
+	var source market.Source
+	if ctx.MarketSource != "" {
+		src, err := market.SourceByName(ctx.MarketSource)
 		if err != nil {
-			// 单个币种失败不影响整体，只记录错误
-			continue
+			return err
+		}
+		source = src
+	}
+	dataMap, errMap := market.GetBatchWithSource(symbols, 0, source, indicatorConfig)
+	for symbol, err := range errMap {
+		// 单个币种失败不影响整体，只记录错误
+		switch {
+		case errors.Is(err, market.ErrSymbolNotFound):
+			log.Printf("⚠️  %s 交易对不存在或已下架，跳过: %v", symbol, err)
+		case errors.Is(err, market.ErrStaleData):
+			log.Printf("⚠️  %s 行情已过期，本周期跳过: %v", symbol, err)
+		default:
+			log.Printf("⚠️  获取 %s 市场数据失败: %v", symbol, err)
 		}
+	}
+
+	for symbol, data := range dataMap {
 
 		// ⚠️ 流动性过滤：持仓价值低于阈值的币种不做（多空都不做）
 		// 持仓价值 = 持仓量 × 当前价格
@@ -351,6 +421,11 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 
 // buildUserPrompt 构建 User Prompt（动态数据）
 func buildUserPrompt(ctx *Context) string {
+	return renderUserPrompt(ctx, noPromptTrim)
+}
+
+// renderUserPrompt 按裁剪设置构建 User Prompt（见 buildUserPromptWithBudget）
+func renderUserPrompt(ctx *Context, trim promptTrim) string {
 	var sb strings.Builder
 
 	// 系统状态
@@ -382,6 +457,16 @@ func buildUserPrompt(ctx *Context) string {
 
 	sb.WriteString("\n")
 
+	// 组合敞口（按币种的多空名义价值、实际杠杆与保证金率）
+	if ctx.Exposure != nil && len(ctx.Exposure.Symbols) > 0 {
+		sb.WriteString(ctx.Exposure.Format())
+		sb.WriteString("\n")
+	}
+	if ctx.Hedges != "" {
+		sb.WriteString(ctx.Hedges)
+		sb.WriteString("\n")
+	}
+
 	// 持仓（完整市场数据）
 	if len(ctx.Positions) > 0 {
 		sb.WriteString("## 当前持仓\n")
@@ -425,7 +510,7 @@ func buildUserPrompt(ctx *Context) string {
 
 			// 使用FormatMarketData输出完整市场数据
 			if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
-				sb.WriteString(market.Format(marketData))
+				sb.WriteString(trim.market(marketText(ctx, pos.Symbol, marketData)))
 				sb.WriteString("\n")
 			}
 		}
@@ -453,7 +538,17 @@ func buildUserPrompt(ctx *Context) string {
 		displayedCandidates = append(displayedCandidates, coin)
 	}
 
-	sb.WriteString(fmt.Sprintf("## 候选币种 (%d个)\n\n", len(displayedCandidates)))
+	// 超出上下文预算时从末尾（优先级最低）开始省略候选币种
+	omitted := 0
+	if trim.maxCandidates >= 0 && len(displayedCandidates) > trim.maxCandidates {
+		omitted = len(displayedCandidates) - trim.maxCandidates
+		displayedCandidates = displayedCandidates[:trim.maxCandidates]
+	}
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf("## 候选币种 (%d个，另有%d个因上下文长度省略)\n\n", len(displayedCandidates), omitted))
+	} else {
+		sb.WriteString(fmt.Sprintf("## 候选币种 (%d个)\n\n", len(displayedCandidates)))
+	}
 	displayedCount := 0
 	for _, coin := range displayedCandidates {
 		marketData := ctx.MarketDataMap[coin.Symbol]
@@ -468,11 +563,17 @@ func buildUserPrompt(ctx *Context) string {
 
 		// 使用FormatMarketData输出完整市场数据
 		sb.WriteString(fmt.Sprintf("### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
-		sb.WriteString(market.Format(marketData))
+		sb.WriteString(trim.market(marketText(ctx, coin.Symbol, marketData)))
 		sb.WriteString("\n")
 	}
 	sb.WriteString("\n")
 
+	// 近期交易结果反馈
+	if ctx.Feedback != "" {
+		sb.WriteString(ctx.Feedback)
+		sb.WriteString("\n")
+	}
+
 	// 夏普比率（直接传值，不要复杂格式化）
 	if ctx.Performance != nil {
 		// 直接从interface{}中提取SharpeRatio
@@ -535,116 +636,45 @@ func extractCoTTrace(response string) string {
 	return strings.TrimSpace(response)
 }
 
-// extractDecisions 提取JSON决策列表
+// extractDecisions 提取JSON决策列表（修复常见格式问题后按 decisions 的严格结构校验）
+// 直接使用校验通过的决策项，未知字段只记录警告
 func extractDecisions(response string) ([]Decision, error) {
-	// 预清洗：去零宽/BOM
-	s := removeInvisibleRunes(response)
-	s = strings.TrimSpace(s)
-
-	// 🔧 關鍵修復：在正則匹配之前就先修復全角字符！
-	// 否則正則表達式 \[ 無法匹配全角的 ［
-	s = fixMissingQuotes(s)
-
-	// 1) 优先从 ```json 代码块中提取
-	if m := reJSONFence.FindStringSubmatch(s); len(m) > 1 {
-		jsonContent := strings.TrimSpace(m[1])
-		jsonContent = compactArrayOpen(jsonContent) // 把 "[ {" 规整为 "[{"
-		jsonContent = fixMissingQuotes(jsonContent) // 二次修復（防止 regex 提取後還有全角）
-		if err := validateJSONFormat(jsonContent); err != nil {
-			return nil, fmt.Errorf("JSON格式验证失败: %w\nJSON内容: %s\n完整响应:\n%s", err, jsonContent, response)
-		}
-		var decisions []Decision
-		if err := json.Unmarshal([]byte(jsonContent), &decisions); err != nil {
-			return nil, fmt.Errorf("JSON解析失败: %w\nJSON内容: %s", err, jsonContent)
-		}
-		return decisions, nil
-	}
-
-	// 2) 退而求其次：全文寻找首个对象数组
-	// 注意：此時 s 已經過 fixMissingQuotes()，全角字符已轉換為半角
-	jsonContent := strings.TrimSpace(reJSONArray.FindString(s))
-	if jsonContent == "" {
-		return nil, fmt.Errorf("无法找到JSON数组起始（已嘗試修復全角字符）\n原始響應前200字符: %s", s[:min(200, len(s))])
+	jsonContent, err := decisions.Extract(response)
+	if err != nil {
+		return nil, err
 	}
-
-	// 🔧 規整格式（此時全角字符已在前面修復過）
-	jsonContent = compactArrayOpen(jsonContent)
-	jsonContent = fixMissingQuotes(jsonContent) // 二次修復（防止 regex 提取後還有殘留全角）
-
-	// 🔧 验证 JSON 格式（检测常见错误）
-	if err := validateJSONFormat(jsonContent); err != nil {
+	items, err := decisions.Parse(jsonContent)
+	if err != nil {
 		return nil, fmt.Errorf("JSON格式验证失败: %w\nJSON内容: %s\n完整响应:\n%s", err, jsonContent, response)
 	}
 
-	// 解析JSON
-	var decisions []Decision
-	if err := json.Unmarshal([]byte(jsonContent), &decisions); err != nil {
-		return nil, fmt.Errorf("JSON解析失败: %w\nJSON内容: %s", err, jsonContent)
-	}
-
-	return decisions, nil
-}
-
-// fixMissingQuotes 替换中文引号和全角字符为英文引号和半角字符（避免AI输出全角JSON字符导致解析失败）
-func fixMissingQuotes(jsonStr string) string {
-	// 替换中文引号
-	jsonStr = strings.ReplaceAll(jsonStr, "\u201c", "\"") // "
-	jsonStr = strings.ReplaceAll(jsonStr, "\u201d", "\"") // "
-	jsonStr = strings.ReplaceAll(jsonStr, "\u2018", "'")  // '
-	jsonStr = strings.ReplaceAll(jsonStr, "\u2019", "'")  // '
-
-	// ⚠️ 替换全角括号、冒号、逗号（防止AI输出全角JSON字符）
-	jsonStr = strings.ReplaceAll(jsonStr, "［", "[") // U+FF3B 全角左方括号
-	jsonStr = strings.ReplaceAll(jsonStr, "］", "]") // U+FF3D 全角右方括号
-	jsonStr = strings.ReplaceAll(jsonStr, "｛", "{") // U+FF5B 全角左花括号
-	jsonStr = strings.ReplaceAll(jsonStr, "｝", "}") // U+FF5D 全角右花括号
-	jsonStr = strings.ReplaceAll(jsonStr, "：", ":") // U+FF1A 全角冒号
-	jsonStr = strings.ReplaceAll(jsonStr, "，", ",") // U+FF0C 全角逗号
-
-	// ⚠️ 替换CJK标点符号（AI在中文上下文中也可能输出这些）
-	jsonStr = strings.ReplaceAll(jsonStr, "【", "[") // CJK左方头括号 U+3010
-	jsonStr = strings.ReplaceAll(jsonStr, "】", "]") // CJK右方头括号 U+3011
-	jsonStr = strings.ReplaceAll(jsonStr, "〔", "[") // CJK左龟壳括号 U+3014
-	jsonStr = strings.ReplaceAll(jsonStr, "〕", "]") // CJK右龟壳括号 U+3015
-	jsonStr = strings.ReplaceAll(jsonStr, "、", ",") // CJK顿号 U+3001
-
-	// ⚠️ 替换全角空格为半角空格（JSON中不应该有全角空格）
-	jsonStr = strings.ReplaceAll(jsonStr, "　", " ") // U+3000 全角空格
-
-	return jsonStr
-}
-
-// validateJSONFormat 验证 JSON 格式，检测常见错误
-func validateJSONFormat(jsonStr string) error {
-	trimmed := strings.TrimSpace(jsonStr)
-
-	// 允许 [ 和 { 之间存在任意空白（含零宽）
-	if !reArrayHead.MatchString(trimmed) {
-		// 检查是否是纯数字/范围数组（常见错误）
-		if strings.HasPrefix(trimmed, "[") && !strings.Contains(trimmed[:min(20, len(trimmed))], "{") {
-			return fmt.Errorf("不是有效的决策数组（必须包含对象 {}），实际内容: %s", trimmed[:min(50, len(trimmed))])
+	result := make([]Decision, len(items))
+	for i, it := range items {
+		if len(it.UnknownFields) > 0 {
+			log.Printf("⚠️  决策 #%d %s 含未知字段 %v，已忽略", i+1, it.Symbol, it.UnknownFields)
 		}
-		return fmt.Errorf("JSON 必须以 [{ 开头（允许空白），实际: %s", trimmed[:min(20, len(trimmed))])
+		result[i] = decisionFromItem(it)
 	}
+	return result, nil
+}
 
-	// 检查是否包含范围符号 ~（LLM 常见错误）
-	if strings.Contains(jsonStr, "~") {
-		return fmt.Errorf("JSON 中不可包含范围符号 ~，所有数字必须是精确的单一值")
+// decisionFromItem 将解析后的决策项转换为 Decision（信心度四舍五入为整数）
+func decisionFromItem(it decisions.Item) Decision {
+	return Decision{
+		Symbol:            it.Symbol,
+		Action:            it.Action,
+		Leverage:          it.Leverage,
+		PositionSizeUSD:   it.PositionSizeUSD,
+		StopLoss:          it.StopLoss,
+		TakeProfit:        it.TakeProfit,
+		NewStopLoss:       it.NewStopLoss,
+		NewTakeProfit:     it.NewTakeProfit,
+		ClosePercentage:   it.ClosePercentage,
+		Confidence:        int(math.Round(it.Confidence)),
+		RiskUSD:           it.RiskUSD,
+		Reasoning:         it.Reasoning,
+		StopLossCondition: it.StopLossCondition,
 	}
-
-	// 检查是否包含千位分隔符（如 98,000）
-	// 使用简单的模式匹配：数字+逗号+3位数字
-	for i := 0; i < len(jsonStr)-4; i++ {
-		if jsonStr[i] >= '0' && jsonStr[i] <= '9' &&
-			jsonStr[i+1] == ',' &&
-			jsonStr[i+2] >= '0' && jsonStr[i+2] <= '9' &&
-			jsonStr[i+3] >= '0' && jsonStr[i+3] <= '9' &&
-			jsonStr[i+4] >= '0' && jsonStr[i+4] <= '9' {
-			return fmt.Errorf("JSON 数字不可包含千位分隔符逗号，发现: %s", jsonStr[i:min(i+10, len(jsonStr))])
-		}
-	}
-
-	return nil
 }
 
 // min 返回两个整数中的较小值
@@ -655,16 +685,6 @@ func min(a, b int) int {
 	return b
 }
 
-// removeInvisibleRunes 去除零宽字符和 BOM，避免肉眼看不见的前缀破坏校验
-func removeInvisibleRunes(s string) string {
-	return reInvisibleRunes.ReplaceAllString(s, "")
-}
-
-// compactArrayOpen 规整开头的 "[ {" → "[{"
-func compactArrayOpen(s string) string {
-	return reArrayOpenSpace.ReplaceAllString(strings.TrimSpace(s), "[{")
-}
-
 // validateDecisions 验证所有决策（需要账户信息和杠杆配置）
 func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
 	// 遍历并在必要时对超限的 position_size_usd 自动限幅（clamp），然后再进行严格校验
@@ -761,19 +781,7 @@ func findMatchingBracket(s string, start int) int {
 // validateDecision 验证单个决策的有效性
 func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
 	// 验证action
-	validActions := map[string]bool{
-		"open_long":          true,
-		"open_short":         true,
-		"close_long":         true,
-		"close_short":        true,
-		"update_stop_loss":   true,
-		"update_take_profit": true,
-		"partial_close":      true,
-		"hold":               true,
-		"wait":               true,
-	}
-
-	if !validActions[d.Action] {
+	if !decisions.ValidAction(d.Action) {
 		return fmt.Errorf("无效的action: %s", d.Action)
 	}
 
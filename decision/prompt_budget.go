@@ -0,0 +1,65 @@
+package decision
+
+import (
+	"log"
+	"nofx/mcp"
+	"strings"
+)
+
+// 决策 prompt 的上下文预算：估算超出模型上下文窗口时按优先级裁剪行情数据，避免被服务端拒绝。
+//  1. 各币种行情中的数值序列只保留最新的 6 个点，仍超出时只保留 3 个
+//  2. 从末尾（优先级最低）开始逐个省略候选币种，标题中的候选币种数量随之更新
+//
+// 账户、持仓、结果反馈与夏普比率等始终保留；仍超出时由 mcp 客户端兜底截断。
+
+// promptSeriesKeeps 依次尝试的序列保留点数
+var promptSeriesKeeps = []int{6, 3}
+
+// promptTrim User Prompt 的裁剪设置
+type promptTrim struct {
+	seriesKeep    int // >0 时每个数值序列只保留最新的若干点
+	maxCandidates int // 最多展示的候选币种数（<0 表示不限制）
+}
+
+// noPromptTrim 不裁剪
+var noPromptTrim = promptTrim{maxCandidates: -1}
+
+// market 按设置裁剪单个币种的行情文本
+func (t promptTrim) market(text string) string {
+	if t.seriesKeep <= 0 {
+		return text
+	}
+	return reNumericSeries.ReplaceAllStringFunc(text, func(m string) string {
+		parts := strings.Split(m[1:len(m)-1], ", ")
+		if len(parts) <= t.seriesKeep {
+			return m
+		}
+		return "[…, " + strings.Join(parts[len(parts)-t.seriesKeep:], ", ") + "]"
+	})
+}
+
+// buildUserPromptWithBudget 构建不超过 budget 个估算 token 的 User Prompt（budget<=0 时不裁剪）
+func buildUserPromptWithBudget(ctx *Context, budget int) string {
+	prompt := buildUserPrompt(ctx)
+	before := mcp.EstimateTokens(prompt)
+	if budget <= 0 || before <= budget {
+		return prompt
+	}
+
+	trim := noPromptTrim
+	for _, keep := range promptSeriesKeeps {
+		trim.seriesKeep = keep
+		if prompt = renderUserPrompt(ctx, trim); mcp.EstimateTokens(prompt) <= budget {
+			log.Printf("✂️  决策 prompt 超出上下文预算 (估算 %d > 预算 %d)，行情序列只保留最新 %d 个点", before, budget, keep)
+			return prompt
+		}
+	}
+	for trim.maxCandidates = len(ctx.CandidateCoins) - 1; trim.maxCandidates >= 0; trim.maxCandidates-- {
+		if prompt = renderUserPrompt(ctx, trim); mcp.EstimateTokens(prompt) <= budget {
+			break
+		}
+	}
+	log.Printf("✂️  决策 prompt 超出上下文预算 (估算 %d > 预算 %d)，已裁剪行情序列并最多保留 %d 个候选币种 (约 %d tokens)",
+		before, budget, max(trim.maxCandidates, 0), mcp.EstimateTokens(prompt))
+	return prompt
+}
@@ -0,0 +1,318 @@
+package decisions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// AI 决策 JSON（decision_json）的解析与校验，由决策引擎、决策日志与对账工具共用。
+//
+// Parse 严格校验：类型错误、无效 action、越界的百分比与价格都会逐项报告（Errors）；
+// 未知字段不算错误（模型常附带额外说明字段），解码时丢弃并记录在 Item.UnknownFields 中供调用方告警。
+// Decode 只做修复与解码，用于读取历史日志（字段可能来自旧版本）。
+// 与账户相关的约束（杠杆限幅、仓位金额、风险回报比）仍由 decision 包校验。
+
+// 决策动作
+const (
+	ActionOpenLong         = "open_long"
+	ActionOpenShort        = "open_short"
+	ActionCloseLong        = "close_long"
+	ActionCloseShort       = "close_short"
+	ActionUpdateStopLoss   = "update_stop_loss"
+	ActionUpdateTakeProfit = "update_take_profit"
+	ActionPartialClose     = "partial_close"
+	ActionHold             = "hold"
+	ActionWait             = "wait"
+)
+
+var validActions = map[string]bool{
+	ActionOpenLong:         true,
+	ActionOpenShort:        true,
+	ActionCloseLong:        true,
+	ActionCloseShort:       true,
+	ActionUpdateStopLoss:   true,
+	ActionUpdateTakeProfit: true,
+	ActionPartialClose:     true,
+	ActionHold:             true,
+	ActionWait:             true,
+}
+
+// ValidAction 是否为支持的决策动作
+func ValidAction(action string) bool {
+	return validActions[action]
+}
+
+// IsOpen 是否为开仓动作
+func IsOpen(action string) bool {
+	return action == ActionOpenLong || action == ActionOpenShort
+}
+
+// Item decision_json 中的单个决策项（字段与 decision.Decision 的 JSON 一致）
+type Item struct {
+	Symbol string `json:"symbol"`
+	Action string `json:"action"`
+
+	// 开仓参数
+	Leverage        int     `json:"leverage,omitempty"`
+	PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
+	StopLoss        float64 `json:"stop_loss,omitempty"`
+	TakeProfit      float64 `json:"take_profit,omitempty"`
+
+	// 调整参数
+	NewStopLoss     float64 `json:"new_stop_loss,omitempty"`
+	NewTakeProfit   float64 `json:"new_take_profit,omitempty"`
+	ClosePercentage float64 `json:"close_percentage,omitempty"` // 0-100
+
+	// 通用参数
+	Confidence        float64 `json:"confidence,omitempty"` // 0-100
+	RiskUSD           float64 `json:"risk_usd,omitempty"`
+	Reasoning         string  `json:"reasoning"`
+	StopLossCondition string  `json:"stop_loss_condition,omitempty"`
+	Price             float64 `json:"price,omitempty"` // 旧版本日志记录的市价
+
+	// UnknownFields Parse 时丢弃的未知字段（按出现顺序，不参与序列化）
+	UnknownFields []string `json:"-"`
+}
+
+// itemFields Item 的 JSON 字段名
+var itemFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(Item{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}()
+
+// FieldError 单个决策项的字段错误（Index 从 0 开始）
+type FieldError struct {
+	Index  int
+	Symbol string
+	Field  string
+	Msg    string
+}
+
+func (e *FieldError) Error() string {
+	where := fmt.Sprintf("决策 #%d", e.Index+1)
+	if e.Symbol != "" {
+		where += " (" + e.Symbol + ")"
+	}
+	if e.Field == "" {
+		return where + ": " + e.Msg
+	}
+	return fmt.Sprintf("%s 字段 %s: %s", where, e.Field, e.Msg)
+}
+
+// Errors 校验发现的全部字段错误
+type Errors []*FieldError
+
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// SyntaxError JSON 无法解析（修复后仍失败）
+type SyntaxError struct {
+	Offset  int64  // 出错位置（修复后文本中的字节偏移，未知时为 -1）
+	Context string // 出错位置附近的内容
+	Err     error
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Context == "" {
+		return fmt.Sprintf("JSON格式错误: %v", e.Err)
+	}
+	return fmt.Sprintf("JSON格式错误: %v（附近内容: %s）", e.Err, e.Context)
+}
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+// ErrEmpty decision_json 为空
+var ErrEmpty = errors.New("决策JSON为空")
+
+// Parse 修复并严格解析 decision_json（数组或单个对象）
+// 字段校验失败时仍返回已解码的决策项，错误为 Errors；JSON 本身无法解析时返回 *SyntaxError；
+// 未知字段被丢弃并记录在 Item.UnknownFields 中，不产生错误
+func Parse(s string) ([]Item, error) {
+	raws, err := split(s)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(raws))
+	var errs Errors
+	for i, raw := range raws {
+		var it Item
+		if err := json.Unmarshal(raw, &it); err != nil {
+			// 类型错误时其余字段已尽量解码，便于调用方记录
+			errs = append(errs, decodeError(i, it.Symbol, err))
+			items = append(items, it)
+			continue
+		}
+		it.UnknownFields = unknownFields(raw)
+		errs = append(errs, it.validate(i)...)
+		items = append(items, it)
+	}
+	if len(errs) > 0 {
+		return items, errs
+	}
+	return items, nil
+}
+
+// Decode 修复并解码 decision_json（数组或单个对象），不做字段校验，忽略未知字段
+func Decode(s string) ([]Item, error) {
+	raws, err := split(s)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(raws))
+	for i, raw := range raws {
+		var it Item
+		if err := json.Unmarshal(raw, &it); err != nil {
+			return nil, decodeError(i, "", err)
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// unknownFields 返回决策项中 Item 未定义的字段（按出现顺序）
+func unknownFields(raw json.RawMessage) []string {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil { // {
+		return nil
+	}
+	var unknown []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return unknown
+		}
+		if key, ok := tok.(string); ok && !itemFields[key] {
+			unknown = append(unknown, key)
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return unknown
+		}
+	}
+	return unknown
+}
+
+// split 修复文本后拆分为各决策项的原始 JSON
+func split(s string) ([]json.RawMessage, error) {
+	fixed := Repair(s)
+	if fixed == "" {
+		return nil, ErrEmpty
+	}
+	if err := lint(fixed); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(fixed, "{") {
+		var raw json.RawMessage
+		if err := json.Unmarshal([]byte(fixed), &raw); err != nil {
+			return nil, syntaxError(fixed, err)
+		}
+		return []json.RawMessage{raw}, nil
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal([]byte(fixed), &raws); err != nil {
+		return nil, syntaxError(fixed, err)
+	}
+	for i, raw := range raws {
+		if t := bytes.TrimSpace(raw); len(t) == 0 || t[0] != '{' {
+			return nil, &FieldError{Index: i, Msg: fmt.Sprintf("决策项必须是对象，实际: %s", truncate(string(t), 50))}
+		}
+	}
+	return raws, nil
+}
+
+// decodeError 把单项的解码错误转换为字段错误
+func decodeError(index int, symbol string, err error) *FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &FieldError{Index: index, Symbol: symbol, Field: typeErr.Field, Msg: fmt.Sprintf("类型应为 %s，实际为 %s", typeErr.Type, typeErr.Value)}
+	}
+	return &FieldError{Index: index, Symbol: symbol, Msg: err.Error()}
+}
+
+// syntaxError 附带出错位置附近的内容
+func syntaxError(s string, err error) *SyntaxError {
+	se := &SyntaxError{Offset: -1, Err: err}
+	var jerr *json.SyntaxError
+	if errors.As(err, &jerr) {
+		se.Offset = jerr.Offset
+		start := max(int(jerr.Offset)-20, 0)
+		end := min(int(jerr.Offset)+20, len(s))
+		se.Context = strings.ToValidUTF8(s[start:end], "")
+	}
+	return se
+}
+
+// validate 校验单个决策项（与账户无关的约束）
+func (it *Item) validate(index int) Errors {
+	var errs Errors
+	add := func(field, format string, args ...any) {
+		errs = append(errs, &FieldError{Index: index, Symbol: it.Symbol, Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if !ValidAction(it.Action) {
+		add("action", "无效的action: %q", it.Action)
+	}
+	if strings.TrimSpace(it.Symbol) == "" && it.Action != ActionHold && it.Action != ActionWait {
+		add("symbol", "不能为空")
+	}
+	if it.Confidence < 0 || it.Confidence > 100 {
+		add("confidence", "必须在0-100之间: %g", it.Confidence)
+	}
+	if it.ClosePercentage < 0 || it.ClosePercentage > 100 {
+		add("close_percentage", "必须在0-100之间: %g", it.ClosePercentage)
+	}
+	for _, f := range []struct {
+		name  string
+		value float64
+	}{
+		{"position_size_usd", it.PositionSizeUSD},
+		{"stop_loss", it.StopLoss},
+		{"take_profit", it.TakeProfit},
+		{"new_stop_loss", it.NewStopLoss},
+		{"new_take_profit", it.NewTakeProfit},
+		{"risk_usd", it.RiskUSD},
+	} {
+		if f.value < 0 {
+			add(f.name, "不能为负数: %g", f.value)
+		}
+	}
+
+	switch it.Action {
+	case ActionUpdateStopLoss:
+		if it.NewStopLoss <= 0 {
+			add("new_stop_loss", "update_stop_loss 必须提供大于0的新止损价")
+		}
+	case ActionUpdateTakeProfit:
+		if it.NewTakeProfit <= 0 {
+			add("new_take_profit", "update_take_profit 必须提供大于0的新止盈价")
+		}
+	case ActionPartialClose:
+		if it.ClosePercentage <= 0 {
+			add("close_percentage", "partial_close 必须提供 0-100 之间的平仓百分比")
+		}
+	}
+	return errs
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
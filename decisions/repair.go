@@ -0,0 +1,201 @@
+package decisions
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	reInvisibleRunes = regexp.MustCompile("[\u200B\u200C\u200D\uFEFF]")
+	reCodeFence      = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*(.*?)```")
+	reArrayStart     = regexp.MustCompile(`[\[［【〔]\s*[{｛]`)
+)
+
+// fullwidth 字符串外的全角/CJK 标点 → JSON 半角标点
+var fullwidth = map[rune]rune{
+	'［': '[', '］': ']', '｛': '{', '｝': '}', '：': ':', '，': ',',
+	'【': '[', '】': ']', '〔': '[', '〕': ']', '、': ',', '　': ' ',
+}
+
+// Repair 修复 AI 常见的格式问题：零宽字符/BOM、代码块围栏、全角标点与中文引号、尾随逗号
+// 字符串内的内容（如 reasoning 中的中文标点）保持不变
+func Repair(s string) string {
+	s = reInvisibleRunes.ReplaceAllString(s, "")
+	if m := reCodeFence.FindStringSubmatch(s); len(m) > 1 {
+		s = m[1]
+	}
+	s = normalizePunct(strings.TrimSpace(s))
+	return strings.TrimSpace(removeTrailingCommas(s))
+}
+
+// Extract 从 AI 的完整响应（思维链 + JSON）中提取决策数组并修复
+// 优先使用代码块中的数组，否则取全文第一个对象数组
+func Extract(response string) (string, error) {
+	s := reInvisibleRunes.ReplaceAllString(response, "")
+	for _, m := range reCodeFence.FindAllStringSubmatch(s, -1) {
+		if loc := reArrayStart.FindStringIndex(m[1]); loc != nil && strings.TrimSpace(m[1][:loc[0]]) == "" {
+			return cutArray(m[1][loc[0]:])
+		}
+	}
+	loc := reArrayStart.FindStringIndex(s)
+	if loc == nil {
+		return "", fmt.Errorf("无法找到JSON数组起始，响应前200字符: %s", truncate(s, 200))
+	}
+	return cutArray(s[loc[0]:])
+}
+
+// cutArray 修复以数组开头的文本并截取到匹配的右括号
+func cutArray(s string) (string, error) {
+	s = normalizePunct(s)
+	end := matchingBracket(s)
+	if end < 0 {
+		return "", fmt.Errorf("JSON数组没有闭合: %s", truncate(s, 200))
+	}
+	return strings.TrimSpace(removeTrailingCommas(s[:end+1])), nil
+}
+
+// normalizePunct 把字符串外的全角标点换成半角；以中文引号包围的字符串视为 JSON 字符串
+func normalizePunct(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	var quote rune // 当前字符串的结束引号（0 表示在字符串外）
+	escaped := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+				r = '"'
+			}
+		case r == '"':
+			quote = '"'
+		case r == '“': // “ … ”
+			quote = '”'
+			r = '"'
+		case r == '”': // AI 偶尔两侧都用 ”
+			quote = '”'
+			r = '"'
+		default:
+			if h, ok := fullwidth[r]; ok {
+				r = h
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// removeTrailingCommas 删除字符串外紧跟在 } 或 ] 之前的逗号
+func removeTrailingCommas(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			b.WriteByte(c)
+			continue
+		}
+		if c == '"' {
+			inString = true
+		} else if c == ',' {
+			j := i + 1
+			for j < len(s) && strings.IndexByte(" \t\r\n", s[j]) >= 0 {
+				j++
+			}
+			if j < len(s) && (s[j] == '}' || s[j] == ']') {
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// matchingBracket 返回与开头 [ 匹配的 ] 的位置（忽略字符串内的括号），未闭合返回 -1
+func matchingBracket(s string) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// lint 检查修复无法处理的常见错误：范围符号 ~ 与千位分隔符（只检查字符串外）
+func lint(s string) error {
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+		case c == '~':
+			return lintError(s, i, "不可包含范围符号 ~，所有数字必须是精确的单一值")
+		case c == ',' && i > 0 && isDigit(s[i-1]) && i+3 < len(s) &&
+			isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) && (i+4 == len(s) || !isDigit(s[i+4])):
+			return lintError(s, i, "数字不可包含千位分隔符逗号")
+		}
+	}
+	return nil
+}
+
+func lintError(s string, i int, msg string) *SyntaxError {
+	return &SyntaxError{
+		Offset:  int64(i),
+		Context: strings.ToValidUTF8(s[max(i-20, 0):min(i+20, len(s))], ""),
+		Err:     errors.New(msg),
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,145 @@
+package experiments
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+)
+
+// A/B 提示词实验：按比例把部分决策周期分到替代的提示词模板或模型，其余周期为对照组（沿用交易员自己的配置）。
+//
+// 分组由 实验名 + 交易员ID + 周期编号 的哈希决定（同一周期重跑时分组不变），结果写入决策记录的
+// experiment / experiment_arm 字段与AI用量标签，BuildReport 按组对比已平仓结果。
+
+// ControlArm 对照组名称
+const ControlArm = "control"
+
+// Arm 实验组
+type Arm struct {
+	Name    string  `json:"name"`
+	Percent float64 `json:"percent"` // 分到该组的决策周期比例（0-100）
+	// PromptTemplate 使用的系统提示词模板，为空时沿用交易员的模板
+	PromptTemplate string `json:"prompt_template"`
+	// Model 使用的模型（与交易员相同的服务商与密钥），为空时沿用交易员的模型
+	Model string `json:"model"`
+}
+
+// Experiment 一个实验
+type Experiment struct {
+	Name    string   `json:"name"`
+	Enabled bool     `json:"enabled"`
+	Traders []string `json:"traders"` // 参与的交易员ID，为空时所有交易员参与
+	Arms    []Arm    `json:"arms"`    // 实验组，未分到任何实验组的周期为对照组
+}
+
+// Assignment 一个决策周期的分组结果
+type Assignment struct {
+	Experiment     string
+	Arm            string
+	PromptTemplate string // 为空时沿用交易员的模板
+	Model          string // 为空时沿用交易员的模型
+}
+
+// Tag 实验分组（实验名/组名）
+func (a Assignment) Tag() string {
+	return a.Experiment + "/" + a.Arm
+}
+
+// UsageTag 成本归属标签值（交易员ID:实验名/组名），按交易员区分，用量汇总 ByTag["experiment"] 可直接取到交易员各组的费用
+func (a Assignment) UsageTag(traderID string) string {
+	return traderID + ":" + a.Tag()
+}
+
+var (
+	mu          sync.RWMutex
+	experiments []Experiment
+)
+
+// Validate 检查实验配置
+func (e Experiment) Validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("实验名称不能为空")
+	}
+	if len(e.Arms) == 0 {
+		return fmt.Errorf("实验 %s 没有实验组", e.Name)
+	}
+	total := 0.0
+	seen := make(map[string]bool)
+	for _, arm := range e.Arms {
+		switch {
+		case arm.Name == "" || arm.Name == ControlArm:
+			return fmt.Errorf("实验 %s 的实验组名称无效: %q", e.Name, arm.Name)
+		case seen[arm.Name]:
+			return fmt.Errorf("实验 %s 的实验组重复: %s", e.Name, arm.Name)
+		case arm.Percent <= 0:
+			return fmt.Errorf("实验 %s 的实验组 %s 比例必须大于 0", e.Name, arm.Name)
+		case arm.PromptTemplate == "" && arm.Model == "":
+			return fmt.Errorf("实验 %s 的实验组 %s 未指定提示词模板或模型", e.Name, arm.Name)
+		}
+		seen[arm.Name] = true
+		total += arm.Percent
+	}
+	if total > 100 {
+		return fmt.Errorf("实验 %s 的实验组比例合计 %.1f%% 超过 100%%", e.Name, total)
+	}
+	return nil
+}
+
+// Includes 交易员是否参与该实验
+func (e Experiment) Includes(traderID string) bool {
+	return len(e.Traders) == 0 || slices.Contains(e.Traders, traderID)
+}
+
+// Assign 按哈希为交易员的第 cycle 个决策周期分组
+func (e Experiment) Assign(traderID string, cycle int) Assignment {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s|%s|%d", e.Name, traderID, cycle)
+	point := float64(h.Sum32()%10000) / 100 // [0, 100)
+
+	a := Assignment{Experiment: e.Name, Arm: ControlArm}
+	upper := 0.0
+	for _, arm := range e.Arms {
+		upper += arm.Percent
+		if point < upper {
+			a.Arm, a.PromptTemplate, a.Model = arm.Name, arm.PromptTemplate, arm.Model
+			break
+		}
+	}
+	return a
+}
+
+// Set 设置实验配置（所有交易员共用）；任一实验无效时返回错误且不启用任何实验
+func Set(list []Experiment) error {
+	seen := make(map[string]bool)
+	for _, e := range list {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("实验名称重复: %s", e.Name)
+		}
+		seen[e.Name] = true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	experiments = slices.Clone(list)
+	return nil
+}
+
+// List 返回当前配置的实验（包括未启用的）
+func List() []Experiment {
+	mu.RLock()
+	defer mu.RUnlock()
+	return slices.Clone(experiments)
+}
+
+// Assign 为交易员的第 cycle 个决策周期分组：使用第一个包含该交易员的已启用实验，没有时返回 false
+func Assign(traderID string, cycle int) (Assignment, bool) {
+	for _, e := range List() {
+		if e.Enabled && e.Includes(traderID) {
+			return e.Assign(traderID, cycle), true
+		}
+	}
+	return Assignment{}, false
+}
@@ -0,0 +1,88 @@
+package experiments
+
+import (
+	"nofx/logger"
+	"nofx/performance"
+	"sort"
+)
+
+// ArmReport 一个组的表现
+type ArmReport struct {
+	Arm            string `json:"arm"`
+	PromptTemplate string `json:"prompt_template,omitempty"`
+	Model          string `json:"model,omitempty"`
+	Cycles         int    `json:"cycles"`        // 分到该组的决策周期数
+	FailedCycles   int    `json:"failed_cycles"` // 其中获取或执行决策失败的周期数
+	Opens          int    `json:"opens"`         // 成功开仓次数
+	// Summary 该组开仓的已平仓结果（按开仓所在周期的分组归属）
+	Summary      performance.Summary `json:"summary"`
+	AvgReturnPct float64             `json:"avg_return_pct"` // 已平仓结果的平均收益率
+	AICostUSD    float64             `json:"ai_cost_usd"`    // 进程启动以来该组的AI费用估算（由调用方按用量标签填写）
+}
+
+// Report 一个实验的分组对比
+type Report struct {
+	Experiment string      `json:"experiment"`
+	Enabled    bool        `json:"enabled"`
+	Arms       []ArmReport `json:"arms"` // 对照组在前，其后为配置中的实验组与记录中出现过的其他组
+}
+
+// BuildReport 按组汇总决策记录与已平仓结果（outcomes 由 performance.Tracker 基于同一批记录生成）
+func BuildReport(e Experiment, records []*logger.DecisionRecord, outcomes []performance.Outcome) Report {
+	report := Report{Experiment: e.Name, Enabled: e.Enabled}
+	index := make(map[string]int)
+	arm := func(name string) *ArmReport {
+		i, ok := index[name]
+		if !ok {
+			i = len(report.Arms)
+			index[name] = i
+			report.Arms = append(report.Arms, ArmReport{Arm: name})
+		}
+		return &report.Arms[i]
+	}
+	arm(ControlArm)
+	for _, a := range e.Arms {
+		r := arm(a.Name)
+		r.PromptTemplate, r.Model = a.PromptTemplate, a.Model
+	}
+
+	configured := len(report.Arms)
+	for _, rec := range records {
+		if rec.Experiment != e.Name || rec.ExperimentArm == "" {
+			continue
+		}
+		r := arm(rec.ExperimentArm)
+		r.Cycles++
+		if !rec.Success {
+			r.FailedCycles++
+		}
+		for _, act := range rec.Decisions {
+			if act.Success && (act.Action == "open_long" || act.Action == "open_short") {
+				r.Opens++
+			}
+		}
+	}
+	// 已不在配置中的组排在最后（按名称）
+	removed := report.Arms[configured:]
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Arm < removed[j].Arm })
+
+	byArm := make(map[string][]performance.Outcome)
+	for _, o := range outcomes {
+		if o.Experiment == e.Name && o.Arm != "" {
+			byArm[o.Arm] = append(byArm[o.Arm], o)
+		}
+	}
+	for i := range report.Arms {
+		r := &report.Arms[i]
+		outs := byArm[r.Arm]
+		r.Summary = performance.Summarize(outs, 0, 0)
+		if len(outs) > 0 {
+			total := 0.0
+			for _, o := range outs {
+				total += o.ReturnPct
+			}
+			r.AvgReturnPct = total / float64(len(outs))
+		}
+	}
+	return report
+}
@@ -0,0 +1,258 @@
+package killswitch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 紧急停止（kill switch）：交易所故障等情况下全局或按交易员暂停交易，无需杀进程。
+// 暂停期间不再开新仓（平仓、止损更新照常执行），可选同时平掉全部持仓。
+// 暂停状态有两个来源，任一生效即暂停：
+//   - 文件：文件存在即暂停（默认 KILL_SWITCH）。内容为空表示全局暂停，
+//     也可以是 JSON：{"reason": "...", "flatten": true, "traders": ["trader_id"]}（traders 为空表示全局）
+//   - 数据库：system_config 中的 kill_switch（全局）与 kill_switch:<trader_id>，由 HTTP 接口写入，重启后仍然生效
+
+// Global 全局范围（所有交易员）
+const Global = ""
+
+// 暂停来源
+const (
+	SourceFile = "file"
+	SourceDB   = "db"
+)
+
+// DefaultFile 默认的暂停文件
+const DefaultFile = "KILL_SWITCH"
+
+const dbKeyPrefix = "kill_switch"
+
+// ErrFileActive 暂停由文件触发，只能删除文件恢复
+var ErrFileActive = errors.New("暂停由文件触发，请删除文件后恢复")
+
+// State 暂停状态
+type State struct {
+	Active  bool      `json:"active"`
+	Scope   string    `json:"scope"`            // "global" 或交易员ID
+	Flatten bool      `json:"flatten"`          // 暂停时平掉全部持仓
+	Reason  string    `json:"reason,omitempty"` // 暂停原因
+	Source  string    `json:"source,omitempty"` // file/db
+	By      string    `json:"by,omitempty"`     // 操作者（接口调用时为用户ID）
+	Since   time.Time `json:"since"`
+}
+
+// Describe 日志与决策记录中使用的描述
+func (s State) Describe() string {
+	scope := "全局"
+	if s.Scope != "global" {
+		scope = "交易员 " + s.Scope
+	}
+	desc := fmt.Sprintf("紧急停止（%s，来源 %s，自 %s）", scope, s.Source, s.Since.Format("2006-01-02 15:04:05"))
+	if s.Reason != "" {
+		desc += ": " + s.Reason
+	}
+	return desc
+}
+
+// Store 持久化暂停标记（config.Database 满足该接口）
+type Store interface {
+	GetSystemConfig(key string) (string, error)
+	SetSystemConfig(key, value string) error
+}
+
+// Switch 暂停状态的读取与设置（store 为 nil 时只使用文件）
+type Switch struct {
+	mu    sync.Mutex
+	store Store
+	file  string
+}
+
+// New 创建（file 为空时使用 DefaultFile）
+func New(store Store, file string) *Switch {
+	if file == "" {
+		file = DefaultFile
+	}
+	return &Switch{store: store, file: file}
+}
+
+// File 暂停文件路径
+func (s *Switch) File() string {
+	return s.file
+}
+
+// Status 交易员当前的暂停状态（traderID 为 Global 时只看全局暂停）
+// 多个来源同时生效时取最早的一个，任一来源要求平仓即平仓
+func (s *Switch) Status(traderID string) State {
+	var active []State
+	if st, ok := s.fileState(traderID); ok {
+		active = append(active, st)
+	}
+	for _, scope := range scopes(traderID) {
+		if st, ok := s.dbState(scope); ok {
+			active = append(active, st)
+		}
+	}
+	if len(active) == 0 {
+		return State{Scope: scopeName(traderID)}
+	}
+	result := active[0]
+	for _, st := range active[1:] {
+		if st.Since.Before(result.Since) {
+			flatten := result.Flatten
+			result = st
+			result.Flatten = result.Flatten || flatten
+		} else {
+			result.Flatten = result.Flatten || st.Flatten
+		}
+	}
+	return result
+}
+
+// Pause 暂停（写入数据库）；已暂停时更新原因与平仓选项，保留开始时间
+func (s *Switch) Pause(scope, reason, by string, flatten bool) (State, error) {
+	if s.store == nil {
+		return State{}, errors.New("未配置数据库，只能通过文件暂停")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := State{
+		Active:  true,
+		Scope:   scopeName(scope),
+		Flatten: flatten,
+		Reason:  reason,
+		Source:  SourceDB,
+		By:      by,
+		Since:   time.Now(),
+	}
+	if old, ok := s.dbState(scope); ok {
+		st.Since = old.Since
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return State{}, err
+	}
+	if err := s.store.SetSystemConfig(dbKey(scope), string(data)); err != nil {
+		return State{}, fmt.Errorf("保存暂停状态失败: %w", err)
+	}
+	return st, nil
+}
+
+// Resume 恢复（清除数据库标记）；文件仍存在时返回 ErrFileActive
+func (s *Switch) Resume(scope string) error {
+	if s.store != nil {
+		s.mu.Lock()
+		err := s.store.SetSystemConfig(dbKey(scope), "")
+		s.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("清除暂停状态失败: %w", err)
+		}
+	}
+	if _, ok := s.fileState(scope); ok {
+		return ErrFileActive
+	}
+	return nil
+}
+
+// fileState 读取暂停文件（文件内容无法解析时按全局暂停处理，宁可多停）
+func (s *Switch) fileState(traderID string) (State, bool) {
+	info, err := os.Stat(s.file)
+	if err != nil || info.IsDir() {
+		return State{}, false
+	}
+	st := State{Active: true, Scope: "global", Source: SourceFile, Since: info.ModTime()}
+	data, err := os.ReadFile(s.file)
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		return st, true
+	}
+	var content struct {
+		Reason  string   `json:"reason"`
+		Flatten bool     `json:"flatten"`
+		Traders []string `json:"traders"`
+	}
+	if err := json.Unmarshal(data, &content); err != nil {
+		st.Reason = strings.TrimSpace(string(data))
+		return st, true
+	}
+	st.Reason, st.Flatten = content.Reason, content.Flatten
+	if len(content.Traders) > 0 {
+		if traderID == Global || !slices.Contains(content.Traders, traderID) {
+			return State{}, false
+		}
+		st.Scope = traderID
+	}
+	return st, true
+}
+
+// dbState 读取数据库标记（空值或无法解析时视为未暂停）
+func (s *Switch) dbState(scope string) (State, bool) {
+	if s.store == nil {
+		return State{}, false
+	}
+	value, err := s.store.GetSystemConfig(dbKey(scope))
+	if err != nil || value == "" {
+		return State{}, false
+	}
+	var st State
+	if err := json.Unmarshal([]byte(value), &st); err != nil || !st.Active {
+		return State{}, false
+	}
+	st.Source = SourceDB
+	return st, true
+}
+
+// scopes 交易员适用的范围：全局与自身
+func scopes(traderID string) []string {
+	if traderID == Global {
+		return []string{Global}
+	}
+	return []string{Global, traderID}
+}
+
+func scopeName(scope string) string {
+	if scope == Global {
+		return "global"
+	}
+	return scope
+}
+
+func dbKey(scope string) string {
+	if scope == Global {
+		return dbKeyPrefix
+	}
+	return dbKeyPrefix + ":" + scope
+}
+
+// 进程内共享的开关（Init 之前只检查默认文件）
+var (
+	defaultMu     sync.RWMutex
+	defaultSwitch = New(nil, "")
+)
+
+// Init 设置共享开关使用的数据库与暂停文件
+func Init(store Store, file string) {
+	SetDefault(New(store, file))
+}
+
+// SetDefault 替换共享开关
+func SetDefault(s *Switch) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultSwitch = s
+}
+
+// Default 返回共享开关
+func Default() *Switch {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultSwitch
+}
+
+// Status 共享开关中交易员的暂停状态
+func Status(traderID string) State {
+	return Default().Status(traderID)
+}
@@ -0,0 +1,121 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// 生命周期管理：各子系统注册为有序的组件，按注册顺序启动（配置 → 行情 → 交易员 → 对账 → API），
+// 收到退出信号或任一组件的后台任务出错后按相反顺序停止，保证交易员先于行情与数据库停止，
+// 正在进行的决策周期写完决策日志后才关闭 WebSocket 与数据库连接。
+
+// DefaultShutdownTimeout 默认的停止超时（所有组件共用）
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Component 一个子系统。三个函数都可以为空：
+//   - Start 同步完成初始化，返回错误时停止启动并回滚已启动的组件
+//   - Run 后台运行直到 ctx 取消；返回非 nil 错误时触发整体退出
+//   - Stop 释放资源，ctx 在停止超时后取消
+type Component struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Run   func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Manager 按顺序启动、逆序停止组件
+type Manager struct {
+	components      []Component
+	shutdownTimeout time.Duration
+}
+
+// New 创建生命周期管理器（shutdownTimeout <= 0 时使用 DefaultShutdownTimeout）
+func New(shutdownTimeout time.Duration) *Manager {
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+	return &Manager{shutdownTimeout: shutdownTimeout}
+}
+
+// Add 注册组件（启动顺序即注册顺序）
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// Run 启动所有组件并阻塞，直到 ctx 取消或某个组件的 Run 返回错误，然后逆序停止所有已启动的组件。
+// 返回启动失败、后台运行失败或停止失败的错误（ctx 取消本身不算错误）。
+func (m *Manager) Run(ctx context.Context) error {
+	started := 0
+	for _, c := range m.components {
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				stopErr := m.stop(m.components[:started])
+				return errors.Join(fmt.Errorf("启动 %s 失败: %w", c.Name, err), stopErr)
+			}
+		}
+		started++
+		log.Printf("✓ [lifecycle] %s 已启动", c.Name)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, c := range m.components {
+		if c.Run == nil {
+			continue
+		}
+		g.Go(func() error {
+			if err := c.Run(gctx); err != nil && !errors.Is(err, context.Canceled) {
+				return fmt.Errorf("%s 运行失败: %w", c.Name, err)
+			}
+			return nil
+		})
+	}
+
+	<-gctx.Done()
+	if ctx.Err() != nil {
+		log.Printf("📛 [lifecycle] 收到退出信号，开始按顺序停止")
+	}
+	stopErr := m.stop(m.components)
+	return errors.Join(g.Wait(), stopErr)
+}
+
+// stop 逆序停止组件；某个组件停止失败不影响其他组件
+func (m *Manager) stop(components []Component) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if c.Stop == nil {
+			continue
+		}
+		start := time.Now()
+		if err := c.Stop(ctx); err != nil {
+			log.Printf("⚠️  [lifecycle] 停止 %s 失败: %v", c.Name, err)
+			errs = append(errs, fmt.Errorf("停止 %s 失败: %w", c.Name, err))
+			continue
+		}
+		log.Printf("✓ [lifecycle] %s 已停止 (%s)", c.Name, time.Since(start).Round(time.Millisecond))
+	}
+	return errors.Join(errs...)
+}
+
+// Wait 在停止期间等待 fn 完成，ctx 超时后放弃等待并返回 ctx 的错误（fn 仍在后台继续）
+func Wait(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -5,26 +5,47 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"nofx/decisions"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
 // DecisionRecord 决策记录
 type DecisionRecord struct {
-	Timestamp      time.Time          `json:"timestamp"`       // 决策时间
-	CycleNumber    int                `json:"cycle_number"`    // 周期编号
-	SystemPrompt   string             `json:"system_prompt"`   // 系统提示词（发送给AI的系统prompt）
-	InputPrompt    string             `json:"input_prompt"`    // 发送给AI的输入prompt
-	CoTTrace       string             `json:"cot_trace"`       // AI思维链（输出）
-	DecisionJSON   string             `json:"decision_json"`   // 决策JSON
-	AccountState   AccountSnapshot    `json:"account_state"`   // 账户状态快照
-	Positions      []PositionSnapshot `json:"positions"`       // 持仓快照
-	CandidateCoins []string           `json:"candidate_coins"` // 候选币种列表
-	Decisions      []DecisionAction   `json:"decisions"`       // 执行的决策
-	ExecutionLog   []string           `json:"execution_log"`   // 执行日志
-	Success        bool               `json:"success"`         // 是否成功
-	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
+	Timestamp      time.Time          `json:"timestamp"`                // 决策时间
+	CycleNumber    int                `json:"cycle_number"`             // 周期编号
+	SystemPrompt   string             `json:"system_prompt"`            // 系统提示词（发送给AI的系统prompt）
+	InputPrompt    string             `json:"input_prompt"`             // 发送给AI的输入prompt
+	CoTTrace       string             `json:"cot_trace"`                // AI思维链（输出）
+	DecisionJSON   string             `json:"decision_json"`            // 决策JSON
+	AccountState   AccountSnapshot    `json:"account_state"`            // 账户状态快照
+	Positions      []PositionSnapshot `json:"positions"`                // 持仓快照
+	CandidateCoins []string           `json:"candidate_coins"`          // 候选币种列表
+	Decisions      []DecisionAction   `json:"decisions"`                // 执行的决策
+	ExecutionLog   []string           `json:"execution_log"`            // 执行日志
+	Success        bool               `json:"success"`                  // 是否成功
+	ErrorMessage   string             `json:"error_message"`            // 错误信息（如果有）
+	RiskOverrides  []RiskOverride     `json:"risk_overrides,omitempty"` // 被风控拒绝的决策
+	Experiment     string             `json:"experiment,omitempty"`     // 参与的 A/B 实验
+	ExperimentArm  string             `json:"experiment_arm,omitempty"` // 本周期所在的实验组（control 为对照组）
+}
+
+// Plans 解析记录中的AI决策（DecisionJSON），不做字段校验，兼容历史记录；未记录时返回 nil
+func (r *DecisionRecord) Plans() ([]decisions.Item, error) {
+	if r.DecisionJSON == "" {
+		return nil, nil
+	}
+	return decisions.Decode(r.DecisionJSON)
+}
+
+// RiskOverride 风控对一条决策的拒绝（Symbol/Action 为空时为账户级，如日亏损暂停）
+type RiskOverride struct {
+	Symbol string `json:"symbol,omitempty"`
+	Action string `json:"action,omitempty"`
+	Rule   string `json:"rule"`   // 触发的规则，如 max_positions、daily_loss
+	Reason string `json:"reason"` // 拒绝原因
 }
 
 // AccountSnapshot 账户状态快照
@@ -50,20 +71,23 @@ type PositionSnapshot struct {
 
 // DecisionAction 决策动作
 type DecisionAction struct {
-	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short, update_stop_loss, update_take_profit, partial_close
-	Symbol    string    `json:"symbol"`    // 币种
-	Quantity  float64   `json:"quantity"`  // 数量（部分平仓时使用）
-	Leverage  int       `json:"leverage"`  // 杠杆（开仓时）
-	Price     float64   `json:"price"`     // 执行价格
-	OrderID   int64     `json:"order_id"`  // 订单ID
-	Timestamp time.Time `json:"timestamp"` // 执行时间
-	Success   bool      `json:"success"`   // 是否成功
-	Error     string    `json:"error"`     // 错误信息
+	Action        string    `json:"action"`                    // open_long, open_short, close_long, close_short, update_stop_loss, update_take_profit, partial_close
+	Symbol        string    `json:"symbol"`                    // 币种
+	Quantity      float64   `json:"quantity"`                  // 数量（部分平仓时使用）
+	Leverage      int       `json:"leverage"`                  // 杠杆（开仓时）
+	Confidence    int       `json:"confidence,omitempty"`      // AI 给出的信心度 0-100（开仓时，用于置信度校准）
+	Price         float64   `json:"price"`                     // 执行价格
+	OrderID       int64     `json:"order_id"`                  // 订单ID
+	ClientOrderID string    `json:"client_order_id,omitempty"` // 下单时指定的 clientOrderId（对账工具优先按其精确匹配订单）
+	Timestamp     time.Time `json:"timestamp"`                 // 执行时间
+	Success       bool      `json:"success"`                   // 是否成功
+	Error         string    `json:"error"`                     // 错误信息
 }
 
 // DecisionLogger 决策日志记录器
 type DecisionLogger struct {
 	logDir      string
+	mu          sync.Mutex // 决策周期与止损监控可能同时写入
 	cycleNumber int
 }
 
@@ -86,6 +110,8 @@ func NewDecisionLogger(logDir string) *DecisionLogger {
 
 // LogDecision 记录决策
 func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.cycleNumber++
 	record.CycleNumber = l.cycleNumber
 	record.Timestamp = time.Now()
@@ -1,20 +1,31 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"nofx/api"
 	"nofx/auth"
 	"nofx/config"
+	"nofx/decision"
+	"nofx/experiments"
+	"nofx/killswitch"
+	"nofx/lifecycle"
 	"nofx/manager"
 	"nofx/market"
+	"nofx/market/events"
+	"nofx/mcp"
+	"nofx/notify"
+	"nofx/performance"
 	"nofx/pool"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // LeverageConfig 杠杆配置
@@ -23,6 +34,19 @@ type LeverageConfig struct {
 	AltcoinLeverage int `json:"altcoin_leverage"`
 }
 
+// RiskConfig 风控限制（0 表示不限制）
+type RiskConfig struct {
+	MaxPositions          int     `json:"max_positions"`
+	MaxNotionalPerSymbol  float64 `json:"max_notional_per_symbol"`
+	MaxCorrelatedExposure float64 `json:"max_correlated_exposure"`
+	RiskPerTradePct       float64 `json:"risk_per_trade_pct"`
+	ATRStopMultiplier     float64 `json:"atr_stop_multiplier"`
+	MaxFundingCostPct     float64 `json:"max_funding_cost_pct"`
+	FundingWindowMinutes  int     `json:"funding_window_minutes"`
+	ExpectedHoldingHours  float64 `json:"expected_holding_hours"`
+	HedgeInPrompt         bool    `json:"hedge_in_prompt"`
+}
+
 // ConfigFile 配置文件结构，只包含需要同步到数据库的字段
 type ConfigFile struct {
 	AdminMode          bool              `json:"admin_mode"`
@@ -36,9 +60,28 @@ type ConfigFile struct {
 	MaxDrawdown        float64           `json:"max_drawdown"`
 	StopTradingMinutes int               `json:"stop_trading_minutes"`
 	Leverage           LeverageConfig    `json:"leverage"`
+	Risk               RiskConfig        `json:"risk"`
 	JWTSecret          string            `json:"jwt_secret"`
 	DataKLineTime      string            `json:"data_k_line_time"`
 	Log                *config.LogConfig `json:"log"` // 日志配置
+	// AIPricing 模型单价（美元/百万 token），用于看板估算AI费用
+	AIPricing map[string]mcp.ModelPricing `json:"ai_pricing"`
+	// PromptCompression 决策 prompt 中行情数据的压缩方式（off/deterministic/model）
+	PromptCompression decision.PromptCompression `json:"prompt_compression"`
+	// Experiments A/B 提示词实验：按比例把部分决策周期分到替代的提示词模板或模型
+	Experiments []experiments.Experiment `json:"experiments"`
+	// AIMaxConcurrency 所有交易员合计的AI并发调用上限，超出时按交易员轮询排队（0 表示不限制）
+	AIMaxConcurrency int `json:"ai_max_concurrency"`
+	// ReconcileReportDir 看板读取的对账报告目录（对账工具 -report_dir，默认 tools/log_reconcile/reports）
+	ReconcileReportDir string `json:"reconcile_report_dir"`
+	// ReconcileDB 对账数据库（对账工具 -db，默认 tools/log_reconcile/reconcile.db），用于把开仓决策关联到交易所成交
+	ReconcileDB string `json:"reconcile_db"`
+	// Notify 开平仓、AI密钥移除、行情警报等事件的通知渠道（Telegram/Discord/webhook）
+	Notify *notify.Config `json:"notify"`
+	// KillSwitchFile 紧急停止文件（存在即暂停开仓，默认 KILL_SWITCH）
+	KillSwitchFile string `json:"kill_switch_file"`
+	// ShutdownTimeoutSeconds 退出时等待各子系统停止的最长时间（默认 30 秒）
+	ShutdownTimeoutSeconds int `json:"shutdown_timeout_seconds"`
 }
 
 // loadConfigFile 读取并解析config.json文件
@@ -99,6 +142,35 @@ func syncConfigToDatabase(database *config.Database, configFile *ConfigFile) err
 		configs["altcoin_leverage"] = strconv.Itoa(configFile.Leverage.AltcoinLeverage)
 	}
 
+	// 同步风控限制（未配置时保留数据库中的值）
+	if configFile.Risk.MaxPositions > 0 {
+		configs["max_positions"] = strconv.Itoa(configFile.Risk.MaxPositions)
+	}
+	if configFile.Risk.MaxNotionalPerSymbol > 0 {
+		configs["max_notional_per_symbol"] = fmt.Sprintf("%.2f", configFile.Risk.MaxNotionalPerSymbol)
+	}
+	if configFile.Risk.MaxCorrelatedExposure > 0 {
+		configs["max_correlated_exposure"] = fmt.Sprintf("%.2f", configFile.Risk.MaxCorrelatedExposure)
+	}
+	if configFile.Risk.RiskPerTradePct > 0 {
+		configs["risk_per_trade_pct"] = fmt.Sprintf("%.2f", configFile.Risk.RiskPerTradePct)
+	}
+	if configFile.Risk.ATRStopMultiplier > 0 {
+		configs["atr_stop_multiplier"] = fmt.Sprintf("%.2f", configFile.Risk.ATRStopMultiplier)
+	}
+	if configFile.Risk.MaxFundingCostPct > 0 {
+		configs["max_funding_cost_pct"] = fmt.Sprintf("%.4f", configFile.Risk.MaxFundingCostPct)
+	}
+	if configFile.Risk.FundingWindowMinutes > 0 {
+		configs["funding_window_minutes"] = strconv.Itoa(configFile.Risk.FundingWindowMinutes)
+	}
+	if configFile.Risk.ExpectedHoldingHours > 0 {
+		configs["expected_holding_hours"] = fmt.Sprintf("%.1f", configFile.Risk.ExpectedHoldingHours)
+	}
+
+	// 对冲建议开关（与 admin_mode 等布尔配置一样始终同步）
+	configs["hedge_in_prompt"] = fmt.Sprintf("%t", configFile.Risk.HedgeInPrompt)
+
 	// 如果JWT密钥不为空，也同步
 	if configFile.JWTSecret != "" {
 		configs["jwt_secret"] = configFile.JWTSecret
@@ -175,7 +247,19 @@ func main() {
 	if err != nil {
 		log.Fatalf("❌ 初始化数据库失败: %v", err)
 	}
-	defer database.Close()
+
+	// 事件通知（未配置渠道时不推送）
+	if configFile.Notify != nil {
+		if err := notify.Init(*configFile.Notify); err != nil {
+			log.Printf("⚠️  通知渠道配置无效，不推送通知: %v", err)
+		}
+	}
+
+	// 紧急停止：文件与数据库标记（接口写入），启动时仍处于暂停状态则提示
+	killswitch.Init(database, configFile.KillSwitchFile)
+	if st := killswitch.Status(killswitch.Global); st.Active {
+		log.Printf("⛔ %s，交易员不会开新仓", st.Describe())
+	}
 
 	// 同步config.json到数据库
 	if err := syncConfigToDatabase(database, configFile); err != nil {
@@ -246,15 +330,6 @@ func main() {
 	// 注意：币种池API URL和OI Top API URL现在由每个交易员独立配置
 	// 不再使用全局配置
 
-	// 创建TraderManager
-	traderManager := manager.NewTraderManager()
-
-	// 从数据库加载所有交易员到内存
-	err = traderManager.LoadTradersFromDatabase(database)
-	if err != nil {
-		log.Fatalf("❌ 加载交易员失败: %v", err)
-	}
-
 	// 获取数据库中的所有交易员配置（用于显示，使用default用户）
 	traders, err := database.GetTraders("default")
 	if err != nil {
@@ -299,31 +374,251 @@ func main() {
 		}
 	}
 
-	// 创建并启动API服务器
-	apiServer := api.NewServer(traderManager, database, apiPort)
-	go func() {
-		if err := apiServer.Start(); err != nil {
-			log.Printf("❌ API服务器错误: %v", err)
+	for model, pricing := range configFile.AIPricing {
+		mcp.SetModelPricing(model, pricing)
+	}
+	if err := decision.SetPromptCompression(configFile.PromptCompression); err != nil {
+		log.Printf("⚠️  行情压缩配置无效，已关闭: %v", err)
+	} else if mode := configFile.PromptCompression.Mode; mode != "" && mode != decision.CompressionOff {
+		log.Printf("🗜️  行情压缩: %s", mode)
+	}
+	if err := experiments.Set(configFile.Experiments); err != nil {
+		log.Printf("⚠️  实验配置无效，已关闭所有实验: %v", err)
+	} else {
+		for _, e := range configFile.Experiments {
+			if e.Enabled {
+				log.Printf("🧪 A/B 实验 %s: %d 个实验组", e.Name, len(e.Arms))
+			}
 		}
-	}()
+	}
+	if configFile.AIMaxConcurrency > 0 {
+		mcp.DefaultScheduler().SetLimit(configFile.AIMaxConcurrency)
+		log.Printf("🔧 AI并发调用上限: %d（超出时按交易员轮询排队）", configFile.AIMaxConcurrency)
+	}
 
-	// 启动流行情数据 - 默认使用所有交易员设置的币种 如果没有设置币种 则优先使用系统默认
-	go market.NewWSMonitor(150).Start(database.GetCustomCoins())
-	//go market.NewWSMonitor(150).Start([]string{}) //这里是一个使用方式 传入空的话 则使用market市场的所有币种
-	// 设置优雅退出
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// 子系统按顺序启动：配置 → 行情 → 交易员 → 对账 → API；退出时逆序停止
+	traderManager := manager.NewTraderManager()
+	apiServer := api.NewServer(traderManager, database, apiPort)
+	apiServer.SetReconcileReportDir(configFile.ReconcileReportDir)
+
+	lc := lifecycle.New(time.Duration(configFile.ShutdownTimeoutSeconds) * time.Second)
+	// 配置：数据库与通知渠道最后关闭，保证其他子系统停止过程中仍可写库、推送通知
+	lc.Add(lifecycle.Component{
+		Name: "config",
+		Stop: func(context.Context) error {
+			notify.Close()
+			return database.Close()
+		},
+	})
+	lc.Add(marketComponent(database))
+	// 交易员：停止时等待进行中的决策周期结束（决策日志同步写入磁盘），超时则放弃等待
+	lc.Add(lifecycle.Component{
+		Name: "traders",
+		Start: func(context.Context) error {
+			return traderManager.LoadTradersFromDatabase(database)
+		},
+		Stop: func(ctx context.Context) error {
+			return lifecycle.Wait(ctx, traderManager.StopAll)
+		},
+	})
+	// 对账：决策结果反馈使用的对账数据库（只读，不存在时按日志价格估算）
+	lc.Add(lifecycle.Component{
+		Name: "reconciler",
+		Start: func(context.Context) error {
+			performance.SetReconcileDB(configFile.ReconcileDB)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			return performance.CloseShared()
+		},
+	})
+	// API：最后启动、最先停止，停止时等待处理中的请求完成
+	lc.Add(lifecycle.Component{
+		Name: "api",
+		Run: func(context.Context) error {
+			return apiServer.Start()
+		},
+		Stop: apiServer.Shutdown,
+	})
+
+	// 收到 SIGINT/SIGTERM 后优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := lc.Run(ctx); err != nil {
+		log.Printf("❌ %v", err)
+	}
 
-	// TODO: 启动数据库中配置为运行状态的交易员
-	// traderManager.StartAll()
+	fmt.Println("👋 感谢使用AI交易系统！")
+}
 
-	// 等待退出信号
-	<-sigChan
-	fmt.Println()
-	fmt.Println()
-	log.Println("📛 收到退出信号，正在停止所有trader...")
-	traderManager.StopAll()
+// effortSaveInterval 协同效率校准样本的保存间隔
+const effortSaveInterval = 10 * time.Minute
+
+// marketComponent 行情子系统：代理、警报、OI历史、快照录制、事件日历、WebSocket行情、交易规则缓存与筛选器。
+// 停止时按启动的相反顺序关闭（先关 WebSocket 与后台刷新，再关持久化存储）。
+func marketComponent(database *config.Database) lifecycle.Component {
+	var closers []func() error
+	return lifecycle.Component{
+		Name: "market",
+		Start: func(context.Context) error {
+			// 行情 REST 请求代理（未设置时沿用 HTTP(S)_PROXY 环境变量）
+			if proxy := os.Getenv("MARKET_HTTP_PROXY"); proxy != "" {
+				cfg := market.DefaultHTTPConfig()
+				cfg.Proxy = proxy
+				if err := market.SetHTTPConfig(cfg); err != nil {
+					log.Printf("⚠️  行情代理配置无效，使用默认连接: %v", err)
+				}
+			}
 
-	fmt.Println()
-	fmt.Println("👋 感谢使用AI交易系统！")
+			// 行情警报（成交量突增、RSI 超买超卖、15分钟涨跌、OI 跳变），设置 MARKET_ALERT_WEBHOOK（多个地址以逗号分隔）
+			// 或配置了接收 market_alert 的通知渠道后启用
+			if webhooks := os.Getenv("MARKET_ALERT_WEBHOOK"); webhooks != "" || notify.Enabled(notify.EventMarketAlert) {
+				alertEngine := market.NewAlertEngine(market.DefaultAlertConfig())
+				for _, url := range strings.Split(webhooks, ",") {
+					if url = strings.TrimSpace(url); url != "" {
+						alertEngine.AddWebhook(url)
+					}
+				}
+				market.SetAlertEngine(alertEngine)
+				go func() {
+					for a := range alertEngine.Alerts() {
+						log.Printf("🔔 [行情警报] %s", a.Message)
+						notify.Send(notify.Event{
+							Type:    notify.EventMarketAlert,
+							Title:   "🔔 行情警报 " + a.Type,
+							Message: a.Message,
+							Symbol:  a.Symbol,
+							Fields:  map[string]string{"value": fmt.Sprintf("%.4f", a.Value), "threshold": fmt.Sprintf("%.4f", a.Threshold)},
+							Time:    a.Timestamp,
+						})
+					}
+				}()
+			}
+
+			// OI历史持久化（重启后 OI 变化率仍基于真实历史），可通过 OI_HISTORY_DB 指定路径
+			oiHistoryPath := os.Getenv("OI_HISTORY_DB")
+			if oiHistoryPath == "" {
+				oiHistoryPath = "oi_history.db"
+			}
+			oiStore, err := market.NewSQLiteOIStore(oiHistoryPath)
+			if err != nil {
+				log.Printf("⚠️  OI历史数据库不可用，仅使用内存缓存: %v", err)
+			} else {
+				market.SetOIStore(oiStore)
+				closers = append(closers, oiStore.Close)
+			}
+			// OI 各周期序列保留点数，如 MARKET_OI_SERIES_POINTS=5m=288,1h=168,1d=30（未配置的周期保留 300 点）
+			if spec := os.Getenv("MARKET_OI_SERIES_POINTS"); spec != "" {
+				retention, err := market.ParseOISeriesRetention(spec)
+				if err == nil {
+					err = market.SetOISeriesPolicy(market.OISeriesPolicy{Retention: retention})
+				}
+				if err != nil {
+					log.Printf("⚠️  OI序列保留配置无效，使用默认值: %v", err)
+				}
+			}
+			// OI 趋势评分方法：average（默认）、weighted[:5m=1,1h=2,...]、regression[:1h=24]，方法名随评分一起输出
+			if spec := os.Getenv("MARKET_OI_TREND"); spec != "" {
+				policy, err := market.ParseOITrendPolicy(spec)
+				if err == nil {
+					err = market.SetOITrendPolicy(policy)
+				}
+				if err != nil {
+					log.Printf("⚠️  OI趋势评分配置无效，使用简单平均: %v", err)
+				}
+			}
+			// 协同效率公式权重与阈值校准，如 MARKET_EFFORT=calibration=percentile,window=500,min_samples=50
+			// percentile 校准的样本保存在 MARKET_EFFORT_STATE（默认 effort_calibration.json），重启后继续使用
+			if spec := os.Getenv("MARKET_EFFORT"); spec != "" {
+				cfg, err := market.ParseEffortConfig(spec)
+				if err == nil {
+					err = market.SetEffortConfig(cfg)
+				}
+				if err != nil {
+					log.Printf("⚠️  协同效率配置无效，使用固定阈值: %v", err)
+				} else if cfg.Calibration == market.EffortCalibrationPercentile {
+					statePath := os.Getenv("MARKET_EFFORT_STATE")
+					if statePath == "" {
+						statePath = "effort_calibration.json"
+					}
+					if err := market.LoadEffortCalibration(statePath); err != nil {
+						log.Printf("⚠️  %v，从零开始积累样本", err)
+					}
+					closers = append(closers, market.SaveEffortCalibration)
+				}
+			}
+
+			// 市场快照录制（供离线回放/回测），设置 MARKET_SNAPSHOT_DB 后启用：.db 结尾使用 SQLite，否则视为 JSONL 目录
+			if snapshotPath := os.Getenv("MARKET_SNAPSHOT_DB"); snapshotPath != "" {
+				var snapshotStore market.SnapshotStore
+				if strings.HasSuffix(snapshotPath, ".db") {
+					snapshotStore, err = market.NewSQLiteSnapshotStore(snapshotPath)
+				} else {
+					snapshotStore, err = market.NewJSONSnapshotStore(snapshotPath)
+				}
+				if err != nil {
+					log.Printf("⚠️  市场快照存储不可用，跳过录制: %v", err)
+				} else {
+					market.SetRecorder(market.NewRecorder(snapshotStore))
+					closers = append(closers, snapshotStore.Close)
+					log.Printf("📼 市场快照录制已启用: %s", snapshotPath)
+				}
+			}
+
+			// 事件日历（CPI、FOMC、交易所维护等），设置 MARKET_EVENTS_SOURCE 后启用：逗号分隔的 JSON 文件路径或 http(s) 地址
+			if eventSources := os.Getenv("MARKET_EVENTS_SOURCE"); eventSources != "" {
+				calendar := events.NewCalendar(events.Config{Sources: events.ParseSources(eventSources)})
+				calendar.Start()
+				closers = append(closers, func() error { calendar.Stop(); return nil })
+				events.SetDefault(calendar)
+				log.Printf("📅 事件日历已启用: %s", eventSources)
+			}
+
+			// 启动流行情数据 - 默认使用所有交易员设置的币种 如果没有设置币种 则优先使用系统默认
+			// 设置 MARKET_AGG_TRADES=1 后为所有币种订阅实时成交流（默认仅为持仓币种按需订阅）
+			// 设置 MARKET_KLINE_BASE=1m/3m 后仅订阅该周期K线，15m/1h/4h/1d 由本地聚合生成（减少订阅流数量）
+			marketMonitor := market.NewMonitor(market.MonitorConfig{
+				BatchSize:     150,
+				AggTrades:     os.Getenv("MARKET_AGG_TRADES") == "1",
+				AggregateFrom: os.Getenv("MARKET_KLINE_BASE"),
+			})
+			market.SetDefaultMonitor(marketMonitor)
+			go marketMonitor.Start(database.GetCustomCoins())
+			closers = append(closers, func() error { marketMonitor.Close(); return nil })
+			// 交易规则（tickSize/stepSize/最小名义价值）缓存，每小时刷新，下单时用于价格与数量取整
+			market.DefaultExchangeInfoCache().Start()
+			closers = append(closers, func() error { market.DefaultExchangeInfoCache().Stop(); return nil })
+			//go marketMonitor.Start([]string{}) //这里是一个使用方式 传入空的话 则使用market市场的所有币种
+			// 交易对筛选器（按成交量比、波动率、动量评分），设置 MARKET_SCREENER=1 后启用，交易员通过 ScreenerTopN 使用
+			if os.Getenv("MARKET_SCREENER") == "1" {
+				screener := market.NewScreener(market.ScreenerConfig{})
+				market.SetDefaultScreener(screener)
+				screener.Start()
+				closers = append(closers, func() error { screener.Stop(); return nil })
+			}
+			return nil
+		},
+		// 定期保存协同效率校准样本（未启用 percentile 校准时为空操作）
+		Run: func(ctx context.Context) error {
+			ticker := time.NewTicker(effortSaveInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					if err := market.SaveEffortCalibration(); err != nil {
+						log.Printf("⚠️  保存协同效率校准样本失败: %v", err)
+					}
+				}
+			}
+		},
+		Stop: func(context.Context) error {
+			var errs []error
+			for i := len(closers) - 1; i >= 0; i-- {
+				errs = append(errs, closers[i]())
+			}
+			return errors.Join(errs...)
+		},
+	}
 }
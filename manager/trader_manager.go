@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"nofx/config"
+	"nofx/market"
 	"nofx/pool"
 	"nofx/trader"
 	"sort"
@@ -464,6 +465,9 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 	}
 
 	// 创建trader实例
+	applyRiskConfig(&traderConfig, database)
+	applyTrailingStop(&traderConfig, traderCfg)
+	applyIndicatorConfig(&traderConfig, traderCfg)
 	at, err := trader.NewAutoTrader(traderConfig, database, userID)
 	if err != nil {
 		return fmt.Errorf("创建trader失败: %w", err)
@@ -564,6 +568,9 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 	}
 
 	// 创建trader实例
+	applyRiskConfig(&traderConfig, database)
+	applyTrailingStop(&traderConfig, traderCfg)
+	applyIndicatorConfig(&traderConfig, traderCfg)
 	at, err := trader.NewAutoTrader(traderConfig, database, userID)
 	if err != nil {
 		return fmt.Errorf("创建trader失败: %w", err)
@@ -597,6 +604,18 @@ func (tm *TraderManager) GetTrader(id string) (*trader.AutoTrader, error) {
 	return t, nil
 }
 
+// GetUserTrader 获取属于指定用户的trader（属于其他用户时与不存在返回相同的错误）
+func (tm *TraderManager) GetUserTrader(userID, id string) (*trader.AutoTrader, error) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	t, exists := tm.traders[id]
+	if !exists || t.GetUserID() != userID {
+		return nil, fmt.Errorf("trader ID '%s' 不存在", id)
+	}
+	return t, nil
+}
+
 // GetAllTraders 获取所有trader
 func (tm *TraderManager) GetAllTraders() map[string]*trader.AutoTrader {
 	tm.mu.RLock()
@@ -621,6 +640,76 @@ func (tm *TraderManager) GetTraderIDs() []string {
 	return ids
 }
 
+// applyRiskConfig 从系统配置读取风控限制（max_positions / max_notional_per_symbol / max_correlated_exposure / max_funding_cost_pct，0 表示不限制）
+func applyRiskConfig(cfg *trader.AutoTraderConfig, database *config.Database) {
+	if database == nil {
+		return
+	}
+	if v, err := database.GetSystemConfig("max_positions"); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxPositions = n
+		}
+	}
+	if v, err := database.GetSystemConfig("max_notional_per_symbol"); err == nil {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			cfg.MaxNotionalPerSymbol = f
+		}
+	}
+	if v, err := database.GetSystemConfig("max_correlated_exposure"); err == nil {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			cfg.MaxCorrelatedExposure = f
+		}
+	}
+	if v, err := database.GetSystemConfig("risk_per_trade_pct"); err == nil {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			cfg.RiskPerTradePct = f
+		}
+	}
+	if v, err := database.GetSystemConfig("atr_stop_multiplier"); err == nil {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			cfg.ATRStopMultiplier = f
+		}
+	}
+	if v, err := database.GetSystemConfig("max_funding_cost_pct"); err == nil {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			cfg.MaxFundingCostPct = f
+		}
+	}
+	if v, err := database.GetSystemConfig("funding_window_minutes"); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.FundingWindow = time.Duration(n) * time.Minute
+		}
+	}
+	if v, err := database.GetSystemConfig("expected_holding_hours"); err == nil {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			cfg.HoldingHours = f
+		}
+	}
+	if v, err := database.GetSystemConfig("hedge_in_prompt"); err == nil {
+		cfg.HedgeInPrompt = v == "true"
+	}
+}
+
+// applyTrailingStop 解析交易员的保本/移动止损规则（解析失败时不启用并记录警告）
+func applyTrailingStop(cfg *trader.AutoTraderConfig, traderCfg *config.TraderRecord) {
+	rules, err := trader.ParseTrailingStopRules(traderCfg.TrailingStop)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的移动止损规则无效，已忽略: %v", traderCfg.Name, err)
+		return
+	}
+	cfg.TrailingStop = rules
+}
+
+// applyIndicatorConfig 解析交易员的指标集合与周期（解析失败时使用默认指标并记录警告）
+func applyIndicatorConfig(cfg *trader.AutoTraderConfig, traderCfg *config.TraderRecord) {
+	indicators, err := market.ParseIndicatorConfig(traderCfg.IndicatorConfig)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的指标配置无效，使用默认指标: %v", traderCfg.Name, err)
+		return
+	}
+	cfg.IndicatorConfig = indicators
+}
+
 // StartAll 启动所有trader
 func (tm *TraderManager) StartAll() {
 	tm.mu.RLock()
@@ -637,15 +726,21 @@ func (tm *TraderManager) StartAll() {
 	}
 }
 
-// StopAll 停止所有trader
+// StopAll 并行停止所有trader，等待各自进行中的决策周期结束后返回
 func (tm *TraderManager) StopAll() {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 
 	log.Println("⏹  停止所有Trader...")
+	var wg sync.WaitGroup
 	for _, t := range tm.traders {
-		t.Stop()
+		wg.Add(1)
+		go func(at *trader.AutoTrader) {
+			defer wg.Done()
+			at.Stop()
+		}(t)
 	}
+	wg.Wait()
 }
 
 // GetComparisonData 获取对比数据
@@ -1103,6 +1198,9 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 	}
 
 	// 创建trader实例
+	applyRiskConfig(&traderConfig, database)
+	applyTrailingStop(&traderConfig, traderCfg)
+	applyIndicatorConfig(&traderConfig, traderCfg)
 	at, err := trader.NewAutoTrader(traderConfig, database, userID)
 	if err != nil {
 		return fmt.Errorf("创建trader失败: %w", err)
@@ -0,0 +1,277 @@
+package market
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 警报类型
+const (
+	AlertVolumeSpike    = "volume_spike"     // 3m 成交量突增
+	AlertVolumeTrend    = "volume_trend"     // 1h 成交量持续放大
+	AlertPriceChange15m = "price_change_15m" // 15分钟涨跌幅
+	AlertRSIOverbought  = "rsi_overbought"   // 15m RSI14 超买
+	AlertRSIOversold    = "rsi_oversold"     // 15m RSI14 超卖
+	AlertOIJump         = "oi_jump"          // 15分钟持仓量跳变
+)
+
+// alertChannelSize 警报通道缓冲，消费者过慢时丢弃新警报
+const alertChannelSize = 256
+
+// DefaultAlertConfig 默认警报配置
+func DefaultAlertConfig() Config {
+	return config
+}
+
+// alertSymbolState 单个交易对的跟踪状态（用于去重与清理）
+type alertSymbolState struct {
+	firstSeen time.Time            // 开始跟踪的时间
+	lastSeen  time.Time            // 最近一次收到数据
+	lastAlert time.Time            // 最近一次触发警报
+	score     float64              // 最近一次评估的活跃度评分
+	fired     map[string]time.Time // 警报类型 -> 最近触发时间
+}
+
+// AlertEngine 基于阈值评估市场数据并产生警报
+// 同一交易对同类警报在 DedupWindow 内只发送一次；按 CleanupConfig 定期清理不活跃的交易对状态
+type AlertEngine struct {
+	cfg Config
+
+	mu          sync.Mutex
+	symbols     map[string]*alertSymbolState
+	webhooks    []string
+	lastCleanup time.Time
+
+	alerts chan Alert
+}
+
+// NewAlertEngine 创建警报引擎（零值字段使用默认配置）
+func NewAlertEngine(cfg Config) *AlertEngine {
+	d := DefaultAlertConfig()
+	if cfg.AlertThresholds == (AlertThresholds{}) {
+		cfg.AlertThresholds = d.AlertThresholds
+	}
+	if cfg.CleanupConfig == (CleanupConfig{}) {
+		cfg.CleanupConfig = d.CleanupConfig
+	}
+	if cfg.DedupWindow <= 0 {
+		cfg.DedupWindow = d.DedupWindow
+	}
+	return &AlertEngine{
+		cfg:     cfg,
+		symbols: make(map[string]*alertSymbolState),
+		alerts:  make(chan Alert, alertChannelSize),
+	}
+}
+
+// Alerts 警报通道
+func (e *AlertEngine) Alerts() <-chan Alert {
+	return e.alerts
+}
+
+// AddWebhook 注册 webhook，每条警报以 JSON POST 发送
+func (e *AlertEngine) AddWebhook(url string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.webhooks = append(e.webhooks, url)
+}
+
+// Tracked 当前跟踪的交易对
+func (e *AlertEngine) Tracked() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	symbols := make([]string, 0, len(e.symbols))
+	for s := range e.symbols {
+		symbols = append(symbols, s)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Evaluate 评估一份市场数据，返回本次新产生（已去重）的警报并投递到通道与 webhook
+func (e *AlertEngine) Evaluate(data *Data) []Alert {
+	if data == nil {
+		return nil
+	}
+	now := data.Timestamp
+	if now.IsZero() {
+		now = clockNow()
+	}
+	candidates, score := e.check(data, now)
+
+	e.mu.Lock()
+	st, ok := e.symbols[data.Symbol]
+	if !ok {
+		st = &alertSymbolState{firstSeen: now, fired: make(map[string]time.Time)}
+		e.symbols[data.Symbol] = st
+	}
+	st.lastSeen = now
+	st.score = score
+	var fresh []Alert
+	for _, a := range candidates {
+		if last, ok := st.fired[a.Type]; ok && now.Sub(last) < e.cfg.DedupWindow {
+			continue
+		}
+		st.fired[a.Type] = now
+		st.lastAlert = now
+		fresh = append(fresh, a)
+	}
+	webhooks := append([]string(nil), e.webhooks...)
+	cleanupDue := now.Sub(e.lastCleanup) >= e.cfg.CleanupConfig.CheckInterval
+	e.mu.Unlock()
+
+	for _, a := range fresh {
+		select {
+		case e.alerts <- a:
+		default:
+			log.Printf("⚠️  警报通道已满，丢弃 %s %s", a.Symbol, a.Type)
+		}
+		for _, url := range webhooks {
+			go postAlertWebhook(url, a)
+		}
+	}
+	if cleanupDue {
+		e.Cleanup(now)
+	}
+	return fresh
+}
+
+// check 按阈值检查各项指标，返回候选警报与活跃度评分（各项指标相对阈值的比例 × 10 之和）
+func (e *AlertEngine) check(data *Data, now time.Time) ([]Alert, float64) {
+	th := e.cfg.AlertThresholds
+	var alerts []Alert
+	score := 0.0
+	add := func(typ string, value, threshold float64, msg string) {
+		alerts = append(alerts, Alert{Type: typ, Symbol: data.Symbol, Value: value, Threshold: threshold, Message: msg, Timestamp: now})
+	}
+	ratio := func(value, threshold float64) float64 {
+		if threshold <= 0 {
+			return 0
+		}
+		return value / threshold
+	}
+
+	if data.IntradaySeries != nil && th.VolumeSpike > 0 {
+		v := data.IntradaySeries.VolumeSpikeRatio
+		score += ratio(v, th.VolumeSpike) * 10
+		if v >= th.VolumeSpike {
+			add(AlertVolumeSpike, v, th.VolumeSpike, fmt.Sprintf("%s 3m成交量放大 %.2f 倍", data.Symbol, v))
+		}
+	}
+	if data.Intraday1h != nil && th.VolumeTrend > 0 {
+		v := data.Intraday1h.VolumeSpikeRatio
+		if v >= th.VolumeTrend {
+			add(AlertVolumeTrend, v, th.VolumeTrend, fmt.Sprintf("%s 1h成交量放大 %.2f 倍", data.Symbol, v))
+		}
+	}
+	if th.PriceChange15Min > 0 {
+		v := data.PriceChange15m / 100
+		score += ratio(math.Abs(v), th.PriceChange15Min) * 10
+		if math.Abs(v) >= th.PriceChange15Min {
+			add(AlertPriceChange15m, v, th.PriceChange15Min, fmt.Sprintf("%s 15分钟价格变化 %+.2f%%", data.Symbol, data.PriceChange15m))
+		}
+	}
+	if rsi, ok := alertRSI(data); ok {
+		switch {
+		case th.RSIOverbought > 0 && rsi >= th.RSIOverbought:
+			add(AlertRSIOverbought, rsi, th.RSIOverbought, fmt.Sprintf("%s 15m RSI14 超买 %.1f", data.Symbol, rsi))
+		case th.RSIOversold > 0 && rsi <= th.RSIOversold:
+			add(AlertRSIOversold, rsi, th.RSIOversold, fmt.Sprintf("%s 15m RSI14 超卖 %.1f", data.Symbol, rsi))
+		}
+	}
+	if data.OpenInterest != nil && th.OIJump > 0 {
+		v := data.OpenInterest.Change15m
+		score += ratio(math.Abs(v), th.OIJump) * 10
+		if math.Abs(v) >= th.OIJump {
+			add(AlertOIJump, v, th.OIJump, fmt.Sprintf("%s 15分钟持仓量变化 %+.2f%%", data.Symbol, v*100))
+		}
+	}
+	return alerts, score
+}
+
+// alertRSI 优先使用 15m RSI14，缺失时退回当前 RSI
+func alertRSI(data *Data) (float64, bool) {
+	if data.Intraday15m != nil && len(data.Intraday15m.RSI14Values) > 0 {
+		return data.Intraday15m.RSI14Values[len(data.Intraday15m.RSI14Values)-1], true
+	}
+	if data.CurrentRSI7 > 0 {
+		return data.CurrentRSI7, true
+	}
+	return 0, false
+}
+
+// Cleanup 按清理策略移除交易对状态：超过 InactiveTimeout 未收到数据，
+// 或超过 NoAlertTimeout 未触发警报且活跃度评分低于 MinScoreThreshold
+func (e *AlertEngine) Cleanup(now time.Time) []string {
+	cc := e.cfg.CleanupConfig
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastCleanup = now
+	var removed []string
+	for sym, st := range e.symbols {
+		inactive := cc.InactiveTimeout > 0 && now.Sub(st.lastSeen) > cc.InactiveTimeout
+		quiet := cc.NoAlertTimeout > 0 && now.Sub(maxTime(st.lastAlert, st.firstSeen)) > cc.NoAlertTimeout &&
+			st.score < cc.MinScoreThreshold
+		if inactive || quiet {
+			delete(e.symbols, sym)
+			removed = append(removed, sym)
+		}
+	}
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		log.Printf("🧹 警报引擎清理 %d 个不活跃交易对: %v", len(removed), removed)
+	}
+	return removed
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// postAlertWebhook 发送警报到 webhook（经共享 HTTP 客户端，失败仅记录日志）
+func postAlertWebhook(url string, a Alert) {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return
+	}
+	resp, err := HTTPClient().Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("⚠️  警报 webhook 发送失败 %s: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("⚠️  警报 webhook 返回 %d: %s", resp.StatusCode, url)
+	}
+}
+
+var (
+	alertEngineMu sync.RWMutex
+	alertEngine   *AlertEngine
+)
+
+// SetAlertEngine 设置全局警报引擎（nil 表示关闭），Monitor 每次计算出市场数据后都会评估
+func SetAlertEngine(e *AlertEngine) {
+	alertEngineMu.Lock()
+	defer alertEngineMu.Unlock()
+	alertEngine = e
+}
+
+// evaluateAlerts 评估警报（未设置引擎时忽略）
+func evaluateAlerts(data *Data) {
+	alertEngineMu.RLock()
+	e := alertEngine
+	alertEngineMu.RUnlock()
+	if e != nil {
+		e.Evaluate(data)
+	}
+}
@@ -3,9 +3,8 @@ package market
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 )
@@ -14,27 +13,16 @@ const (
 	baseURL = "https://fapi.binance.com"
 )
 
-type APIClient struct {
-	client *http.Client
-}
+// APIClient 币安合约 REST 客户端（经共享 HTTP 客户端与权重限流）
+type APIClient struct{}
 
 func NewAPIClient() *APIClient {
-	return &APIClient{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}
+	return &APIClient{}
 }
 
 func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 	url := fmt.Sprintf("%s/fapi/v1/exchangeInfo", baseURL)
-	resp, err := c.client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := binanceGet(url, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -48,32 +36,56 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 }
 
 func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
-	url := fmt.Sprintf("%s/fapi/v1/klines", baseURL)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	q := req.URL.Query()
+	q := url.Values{}
 	q.Add("symbol", symbol)
 	q.Add("interval", interval)
 	q.Add("limit", strconv.Itoa(limit))
-	req.URL.RawQuery = q.Encode()
+	return c.getKlines(q)
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
+// GetKlinesRange 分页获取 [start, end] 区间内的全部K线（每页最多1500根，用于回测等需要长历史的场景）
+func (c *APIClient) GetKlinesRange(symbol, interval string, start, end time.Time) ([]Kline, error) {
+	const pageLimit = 1500
+	var all []Kline
+	cursor := start.UnixMilli()
+	endMs := end.UnixMilli()
+	for cursor < endMs {
+		q := url.Values{}
+		q.Add("symbol", symbol)
+		q.Add("interval", interval)
+		q.Add("startTime", strconv.FormatInt(cursor, 10))
+		q.Add("endTime", strconv.FormatInt(endMs, 10))
+		q.Add("limit", strconv.Itoa(pageLimit))
+		page, err := c.getKlines(q)
+		if err != nil {
+			return nil, fmt.Errorf("获取 %s %s K线失败: %w", symbol, interval, err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		all = append(all, page...)
+		if len(page) < pageLimit {
+			break
+		}
+		cursor = page[len(page)-1].OpenTime + 1
 	}
-	defer resp.Body.Close()
+	return all, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// getKlines 请求 /fapi/v1/klines 并解析
+func (c *APIClient) getKlines(q url.Values) ([]Kline, error) {
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	body, err := binanceGet(fmt.Sprintf("%s/fapi/v1/klines?%s", baseURL, q.Encode()), klinesWeight(limit))
 	if err != nil {
 		return nil, err
 	}
+	return ParseKlines(body)
+}
 
+// ParseKlines 解析 /fapi/v1/klines 响应（单根K线解析失败时跳过）
+func ParseKlines(body []byte) ([]Kline, error) {
 	var klineResponses []KlineResponse
-	err = json.Unmarshal(body, &klineResponses)
-	if err != nil {
+	if err := json.Unmarshal(body, &klineResponses); err != nil {
 		return nil, err
 	}
 
@@ -114,23 +126,9 @@ func parseKline(kr KlineResponse) (Kline, error) {
 }
 
 func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
-	url := fmt.Sprintf("%s/fapi/v1/ticker/price", baseURL)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	q := req.URL.Query()
+	q := url.Values{}
 	q.Add("symbol", symbol)
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := binanceGet(fmt.Sprintf("%s/fapi/v1/ticker/price?%s", baseURL, q.Encode()), 1)
 	if err != nil {
 		return 0, err
 	}
@@ -148,3 +146,16 @@ func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
 
 	return price, nil
 }
+
+// GetTickers24hr 获取全部合约交易对的24小时行情（不带 symbol 时权重为 40）
+func (c *APIClient) GetTickers24hr() ([]Ticker24hr, error) {
+	body, err := binanceGet(fmt.Sprintf("%s/fapi/v1/ticker/24hr", baseURL), 40)
+	if err != nil {
+		return nil, err
+	}
+	var tickers []Ticker24hr
+	if err := json.Unmarshal(body, &tickers); err != nil {
+		return nil, err
+	}
+	return tickers, nil
+}
@@ -0,0 +1,128 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 基差/标记-指数价差趋势
+const (
+	BasisWidening  = "widening"  // 偏离指数扩大
+	BasisNarrowing = "narrowing" // 偏离指数收敛
+	BasisStable    = "stable"
+)
+
+const (
+	// basisTrendWindow 计算短期变化的回看窗口
+	basisTrendWindow = 15 * time.Minute
+	// basisHistoryRetention 各交易对保留的采样时长
+	basisHistoryRetention = time.Hour
+	// basisTrendThreshold 价差变化绝对值低于该值（百分比）视为平稳
+	basisTrendThreshold = 0.02
+	// basisStressThreshold 标记-指数价差绝对值达到该值（百分比）视为异常（插针、操纵或指数成分交易所失真）
+	basisStressThreshold = 0.3
+)
+
+// BasisData 基差与标记/指数价差（百分比均相对指数价格）
+type BasisData struct {
+	MarkPrice       float64 `json:"mark_price"`
+	IndexPrice      float64 `json:"index_price"`
+	LastPrice       float64 `json:"last_price"`
+	Basis           float64 `json:"basis"`             // (最新成交价 - 指数价) / 指数价 × 100
+	MarkIndexSpread float64 `json:"mark_index_spread"` // (标记价 - 指数价) / 指数价 × 100
+	BasisChange     float64 `json:"basis_change"`      // 基差相对 basisTrendWindow 前的变化（百分点）
+	SpreadChange    float64 `json:"spread_change"`     // 标记-指数价差相对 basisTrendWindow 前的变化（百分点）
+	Trend           string  `json:"trend"`             // 价差趋势（widening/narrowing/stable），采样不足时为空
+	Stressed        bool    `json:"stressed"`          // 标记-指数价差超过 basisStressThreshold
+}
+
+// basisSample 一次基差采样
+type basisSample struct {
+	at     time.Time
+	basis  float64
+	spread float64
+}
+
+// basisSeries 单个交易对的基差采样序列（从旧到新）
+type basisSeries struct {
+	mu      sync.Mutex
+	samples []basisSample
+}
+
+// basisHistory 各交易对基差采样（每次 Get 时记录）
+var basisHistory sync.Map // symbol -> *basisSeries
+
+// record 记录采样并返回 basisTrendWindow 之前最近的一次采样
+func (s *basisSeries) record(sample basisSample) (basisSample, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := sample.at.Add(-basisHistoryRetention)
+	kept := s.samples[:0]
+	for _, old := range s.samples {
+		if old.at.After(cutoff) && old.at.Before(sample.at) {
+			kept = append(kept, old)
+		}
+	}
+	s.samples = append(kept, sample)
+
+	var ref basisSample
+	found := false
+	for _, old := range s.samples {
+		if sample.at.Sub(old.at) >= basisTrendWindow {
+			ref, found = old, true
+		}
+	}
+	return ref, found
+}
+
+// calculateBasisData 根据 premiumIndex 的标记/指数价格与最新成交价计算基差，并更新短期趋势
+func calculateBasisData(symbol string, funding *FundingData, lastPrice float64, now time.Time) *BasisData {
+	if funding == nil || funding.IndexPrice <= 0 || funding.MarkPrice <= 0 {
+		return nil
+	}
+	index := funding.IndexPrice
+	b := &BasisData{
+		MarkPrice:       funding.MarkPrice,
+		IndexPrice:      index,
+		LastPrice:       lastPrice,
+		MarkIndexSpread: (funding.MarkPrice - index) / index * 100,
+	}
+	if lastPrice > 0 {
+		b.Basis = (lastPrice - index) / index * 100
+	}
+	b.Stressed = math.Abs(b.MarkIndexSpread) >= basisStressThreshold
+
+	value, _ := basisHistory.LoadOrStore(symbol, &basisSeries{})
+	ref, ok := value.(*basisSeries).record(basisSample{at: now, basis: b.Basis, spread: b.MarkIndexSpread})
+	if !ok {
+		return b
+	}
+	b.BasisChange = b.Basis - ref.basis
+	b.SpreadChange = b.MarkIndexSpread - ref.spread
+	switch delta := math.Abs(b.MarkIndexSpread) - math.Abs(ref.spread); {
+	case delta >= basisTrendThreshold:
+		b.Trend = BasisWidening
+	case delta <= -basisTrendThreshold:
+		b.Trend = BasisNarrowing
+	default:
+		b.Trend = BasisStable
+	}
+	return b
+}
+
+// writeBasis 输出基差与标记/指数价差
+func writeBasis(sb *strings.Builder, l reportLocale, b *BasisData) {
+	if b == nil {
+		return
+	}
+	line := l.f("basis_summary", b.MarkPrice, b.IndexPrice, b.MarkIndexSpread, b.Basis)
+	if b.Trend != "" {
+		line += l.f("basis_trend", l.text("basis:"+b.Trend), b.SpreadChange, b.BasisChange)
+	}
+	if b.Stressed {
+		line += l.text("basis_stressed")
+	}
+	sb.WriteString(line + "\n\n")
+}
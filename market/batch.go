@@ -0,0 +1,109 @@
+package market
+
+import (
+	"sync"
+	"time"
+)
+
+// 批量获取的默认并发数与请求间隔
+// 每个交易对约产生2次REST请求（OI、资金费率），间隔 50ms 约合 40 次/秒，远低于币安每分钟权重上限
+const (
+	defaultBatchConcurrency = 8
+	batchRequestInterval    = 50 * time.Millisecond
+)
+
+// GetBatch 使用默认监控器并发获取多个交易对的市场数据（默认指标配置）
+func GetBatch(symbols []string, concurrency int) (map[string]*Data, map[string]error) {
+	return GetBatchWithConfig(symbols, concurrency, DefaultIndicatorConfig())
+}
+
+// GetBatchWithConfig 使用默认监控器（启用回放时为回放源），按指定指标配置并发获取多个交易对的市场数据
+func GetBatchWithConfig(symbols []string, concurrency int, cfg IndicatorConfig) (map[string]*Data, map[string]error) {
+	return GetBatchWithSource(symbols, concurrency, nil, cfg)
+}
+
+// GetBatchWithSource 使用默认监控器（启用回放时为回放源），从指定数据源并发获取多个交易对的市场数据（src 为 nil 时按交易对选择）
+func GetBatchWithSource(symbols []string, concurrency int, src Source, cfg IndicatorConfig) (map[string]*Data, map[string]error) {
+	p, err := currentProvider()
+	if err != nil {
+		errs := make(map[string]error, len(symbols))
+		for _, s := range symbols {
+			errs[s] = err
+		}
+		return map[string]*Data{}, errs
+	}
+	if m, ok := p.(*Monitor); ok {
+		return m.GetBatchWithSource(symbols, concurrency, src, cfg)
+	}
+	// 回放数据无需限流
+	return getBatch(p, symbols, concurrency, 0, src, cfg)
+}
+
+// GetBatch 并发获取多个交易对的市场数据（默认指标配置）
+func (m *Monitor) GetBatch(symbols []string, concurrency int) (map[string]*Data, map[string]error) {
+	return m.GetBatchWithConfig(symbols, concurrency, DefaultIndicatorConfig())
+}
+
+// GetBatchWithConfig 并发获取多个交易对的市场数据
+// 返回成功的数据与逐个交易对的错误，均以调用方传入的 symbol 为键；重复的 symbol 只获取一次
+// concurrency<=0 时使用默认并发数；各请求的启动时间按 batchRequestInterval 间隔发放，避免瞬时突发触发限流
+func (m *Monitor) GetBatchWithConfig(symbols []string, concurrency int, cfg IndicatorConfig) (map[string]*Data, map[string]error) {
+	return m.GetBatchWithSource(symbols, concurrency, nil, cfg)
+}
+
+// GetBatchWithSource 从指定数据源并发获取多个交易对的市场数据（src 为 nil 时按交易对选择）
+func (m *Monitor) GetBatchWithSource(symbols []string, concurrency int, src Source, cfg IndicatorConfig) (map[string]*Data, map[string]error) {
+	return getBatch(m, symbols, concurrency, batchRequestInterval, src, cfg)
+}
+
+// getBatch 通过提供者并发获取；interval>0 时各请求的启动时间按该间隔发放
+func getBatch(p Provider, symbols []string, concurrency int, interval time.Duration, src Source, cfg IndicatorConfig) (map[string]*Data, map[string]error) {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	results := make(map[string]*Data, len(symbols))
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, concurrency)
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	seen := make(map[string]bool, len(symbols))
+	first := true
+	for _, symbol := range symbols {
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+
+		sem <- struct{}{}
+		if !first && tick != nil {
+			<-tick
+		}
+		first = false
+
+		wg.Add(1)
+		go func(s string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			data, err := p.GetWithSource(s, src, cfg)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[s] = err
+				return
+			}
+			results[s] = data
+		}(symbol)
+	}
+
+	wg.Wait()
+	return results, errs
+}
@@ -0,0 +1,380 @@
+package market
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+)
+
+// K线图渲染：把K线绘制为 PNG（蜡烛图 + EMA/布林带 + 成交量 + RSI 副图），
+// 供支持图像输入的模型做图表分析（见 mcp.CallOptions.Images）。只使用标准库绘制。
+
+// 图表配色
+var (
+	chartBackground = color.RGBA{R: 0x12, G: 0x16, B: 0x1c, A: 0xff}
+	chartGrid       = color.RGBA{R: 0x2a, G: 0x30, B: 0x3a, A: 0xff}
+	chartText       = color.RGBA{R: 0xc8, G: 0xcc, B: 0xd2, A: 0xff}
+	chartUp         = color.RGBA{R: 0x26, G: 0xa6, B: 0x9a, A: 0xff}
+	chartDown       = color.RGBA{R: 0xef, G: 0x53, B: 0x50, A: 0xff}
+	chartBand       = color.RGBA{R: 0x78, G: 0x80, B: 0x8c, A: 0xff}
+	chartRSI        = color.RGBA{R: 0xab, G: 0x47, B: 0xbc, A: 0xff}
+	// chartEMAColors EMA 线按配置顺序依次使用的颜色
+	chartEMAColors = []color.RGBA{
+		{R: 0xff, G: 0xb3, B: 0x00, A: 0xff},
+		{R: 0x42, G: 0xa5, B: 0xf5, A: 0xff},
+		{R: 0xec, G: 0x40, B: 0x7a, A: 0xff},
+	}
+)
+
+// 布局（像素）
+const (
+	chartPadding     = 10
+	chartAxisWidth   = 90 // 右侧价格刻度区域
+	chartTitleHeight = 24
+	chartPriceTicks  = 5
+)
+
+// ChartOptions K线图参数
+type ChartOptions struct {
+	Title           string // 左上角标题，如 "BTCUSDT 1H"（只支持数字、字母与 .-:/%()）
+	Width           int    // 默认 960
+	Height          int    // 默认 600
+	EMA             []int  // 叠加的 EMA 周期（最多 3 条），默认 20、50
+	BollingerPeriod int    // 布林带周期（2 倍标准差），0 表示不绘制，默认 20
+	RSIPeriod       int    // RSI 副图周期，0 表示不绘制，默认 14
+	Volume          bool   // 是否绘制成交量副图，默认绘制
+}
+
+// DefaultChartOptions 默认图表参数
+func DefaultChartOptions() ChartOptions {
+	return ChartOptions{
+		Width:           960,
+		Height:          600,
+		EMA:             []int{20, 50},
+		BollingerPeriod: 20,
+		RSIPeriod:       14,
+		Volume:          true,
+	}
+}
+
+// chartPanel 一个绘图区域及其纵轴范围
+type chartPanel struct {
+	rect     image.Rectangle
+	min, max float64
+}
+
+// y 把数值映射到面板内的纵坐标
+func (p chartPanel) y(v float64) int {
+	if p.max == p.min {
+		return (p.rect.Min.Y + p.rect.Max.Y) / 2
+	}
+	frac := (v - p.min) / (p.max - p.min)
+	return p.rect.Max.Y - 1 - int(math.Round(frac*float64(p.rect.Dy()-1)))
+}
+
+// RenderChart 绘制K线图（最新的K线在最右侧）
+func RenderChart(klines []Kline, opts ChartOptions) (*image.RGBA, error) {
+	if len(klines) == 0 {
+		return nil, ErrNoKlines
+	}
+	if opts.Width <= 0 {
+		opts.Width = 960
+	}
+	if opts.Height <= 0 {
+		opts.Height = 600
+	}
+	if len(opts.EMA) > len(chartEMAColors) {
+		return nil, fmt.Errorf("最多绘制 %d 条EMA: %v", len(chartEMAColors), opts.EMA)
+	}
+	plotWidth := opts.Width - 2*chartPadding - chartAxisWidth
+	if plotWidth < len(klines) || opts.Height < 200 {
+		return nil, fmt.Errorf("图表尺寸 %dx%d 不足以绘制 %d 根K线", opts.Width, opts.Height, len(klines))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	fillRect(img, 0, 0, opts.Width, opts.Height, chartBackground)
+
+	// 纵向分区：价格 / 成交量 / RSI
+	left := chartPadding
+	right := left + plotWidth
+	top := chartPadding + chartTitleHeight
+	bottom := opts.Height - chartPadding
+	total := bottom - top
+	volumeHeight, rsiHeight := 0, 0
+	if opts.Volume {
+		volumeHeight = total * 15 / 100
+	}
+	if opts.RSIPeriod > 0 {
+		rsiHeight = total * 20 / 100
+	}
+	priceBottom := bottom - volumeHeight - rsiHeight
+
+	// 叠加指标
+	emas := make([][]float64, len(opts.EMA))
+	for i, period := range opts.EMA {
+		emas[i] = chartEMASeries(klines, period)
+	}
+	var upper, lower []float64
+	if opts.BollingerPeriod > 0 {
+		upper, lower = chartBollingerSeries(klines, opts.BollingerPeriod, 2)
+	}
+
+	// 价格区间包含影线与所有叠加线
+	price := chartPanel{rect: image.Rect(left, top, right, priceBottom), min: math.Inf(1), max: math.Inf(-1)}
+	extend := func(v float64) {
+		if v > 0 && !math.IsNaN(v) {
+			price.min = math.Min(price.min, v)
+			price.max = math.Max(price.max, v)
+		}
+	}
+	for _, k := range klines {
+		extend(k.Low)
+		extend(k.High)
+	}
+	for _, series := range append(append([][]float64{}, emas...), upper, lower) {
+		for _, v := range series {
+			extend(v)
+		}
+	}
+	if math.IsInf(price.min, 1) {
+		return nil, fmt.Errorf("K线价格无效，无法绘制")
+	}
+	pad := (price.max - price.min) * 0.05
+	price.min -= pad
+	price.max += pad
+
+	slot := float64(plotWidth) / float64(len(klines))
+	x := func(i int) int { return left + int(float64(i)*slot+slot/2) }
+	bodyWidth := max(1, int(slot*0.7))
+
+	// 网格与价格刻度
+	for t := 0; t <= chartPriceTicks; t++ {
+		v := price.min + (price.max-price.min)*float64(t)/chartPriceTicks
+		y := price.y(v)
+		drawHLine(img, left, right, y, chartGrid)
+		drawChartText(img, right+6, y-5, formatChartPrice(v), chartText)
+	}
+
+	// 布林带与 EMA 画在蜡烛下面
+	drawChartSeries(img, price, upper, x, chartBand)
+	drawChartSeries(img, price, lower, x, chartBand)
+	for i, series := range emas {
+		drawChartSeries(img, price, series, x, chartEMAColors[i])
+	}
+
+	// 蜡烛
+	for i, k := range klines {
+		c := chartUp
+		if k.Close < k.Open {
+			c = chartDown
+		}
+		cx := x(i)
+		drawVLine(img, cx, price.y(k.High), price.y(k.Low), c)
+		yTop, yBottom := price.y(math.Max(k.Open, k.Close)), price.y(math.Min(k.Open, k.Close))
+		fillRect(img, cx-bodyWidth/2, yTop, bodyWidth, max(1, yBottom-yTop+1), c)
+	}
+
+	// 成交量
+	if volumeHeight > 0 {
+		volume := chartPanel{rect: image.Rect(left, priceBottom+4, right, priceBottom+volumeHeight)}
+		for _, k := range klines {
+			volume.max = math.Max(volume.max, k.Volume)
+		}
+		drawHLine(img, left, right, volume.rect.Min.Y-2, chartGrid)
+		for i, k := range klines {
+			c := chartUp
+			if k.Close < k.Open {
+				c = chartDown
+			}
+			y := volume.y(k.Volume)
+			fillRect(img, x(i)-bodyWidth/2, y, bodyWidth, volume.rect.Max.Y-y, c)
+		}
+		drawChartText(img, left+4, volume.rect.Min.Y+2, "VOL", chartText)
+	}
+
+	// RSI：30/70 参考线
+	if rsiHeight > 0 {
+		rsi := chartPanel{rect: image.Rect(left, bottom-rsiHeight+4, right, bottom), min: 0, max: 100}
+		drawHLine(img, left, right, rsi.rect.Min.Y-2, chartGrid)
+		for _, level := range []float64{30, 70} {
+			drawHLine(img, left, right, rsi.y(level), chartGrid)
+			drawChartText(img, right+6, rsi.y(level)-5, strconv.Itoa(int(level)), chartText)
+		}
+		series := make([]float64, len(klines))
+		for i := range series {
+			series[i] = math.NaN()
+		}
+		for i, v := range calculateRSISeries(klines, opts.RSIPeriod) {
+			series[i+opts.RSIPeriod] = v
+		}
+		drawChartSeries(img, rsi, series, x, chartRSI)
+		drawChartText(img, left+4, rsi.rect.Min.Y+2, fmt.Sprintf("RSI%d", opts.RSIPeriod), chartRSI)
+	}
+
+	// 标题与图例
+	legendX := left
+	if opts.Title != "" {
+		drawChartText(img, legendX, chartPadding, opts.Title, chartText)
+		legendX += chartTextWidth(opts.Title) + 16
+	}
+	for i, period := range opts.EMA {
+		label := fmt.Sprintf("EMA%d", period)
+		drawChartText(img, legendX, chartPadding, label, chartEMAColors[i])
+		legendX += chartTextWidth(label) + 12
+	}
+	if opts.BollingerPeriod > 0 {
+		drawChartText(img, legendX, chartPadding, fmt.Sprintf("BOLL%d", opts.BollingerPeriod), chartBand)
+	}
+	return img, nil
+}
+
+// RenderChartPNG 绘制K线图并编码为 PNG
+func RenderChartPNG(klines []Kline, opts ChartOptions) ([]byte, error) {
+	img, err := RenderChart(klines, opts)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("编码K线图失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ChartPNG 从交易对对应的数据源获取最近 limit 根K线并绘制（标题默认为 "交易对 周期"）
+func ChartPNG(symbol, interval string, limit int, opts ChartOptions) ([]byte, error) {
+	symbol = Normalize(symbol)
+	src := SourceFor(symbol)
+	klines, err := src.Klines(src.Normalize(symbol), interval, limit)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s %s K线失败: %w", symbol, interval, err)
+	}
+	if opts.Title == "" {
+		opts.Title = symbol + " " + interval
+	}
+	return RenderChartPNG(klines, opts)
+}
+
+// chartEMASeries 与K线对齐的 EMA 序列（前 period-1 个为 NaN，初值为 SMA，与 calculateEMA 一致）
+func chartEMASeries(klines []Kline, period int) []float64 {
+	out := make([]float64, len(klines))
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	if period <= 0 || len(klines) < period {
+		return out
+	}
+	sum := 0.0
+	for i := 0; i < period; i++ {
+		sum += klines[i].Close
+	}
+	ema := sum / float64(period)
+	out[period-1] = ema
+	multiplier := 2.0 / float64(period+1)
+	for i := period; i < len(klines); i++ {
+		ema = (klines[i].Close-ema)*multiplier + ema
+		out[i] = ema
+	}
+	return out
+}
+
+// chartBollingerSeries 与K线对齐的布林带上下轨（与 calculateBollinger 一致，前 period-1 个为 NaN）
+func chartBollingerSeries(klines []Kline, period int, multiplier float64) (upper, lower []float64) {
+	upper = make([]float64, len(klines))
+	lower = make([]float64, len(klines))
+	for i := range klines {
+		if i < period-1 {
+			upper[i], lower[i] = math.NaN(), math.NaN()
+			continue
+		}
+		b := calculateBollinger(klines[:i+1], period, multiplier)
+		upper[i], lower[i] = b.Upper, b.Lower
+	}
+	return upper, lower
+}
+
+// formatChartPrice 价格刻度：按数量级保留 4~5 位有效数字
+func formatChartPrice(v float64) string {
+	digits := 2
+	if a := math.Abs(v); a > 0 && a < 100 {
+		digits = min(8, 4-int(math.Floor(math.Log10(a))))
+	}
+	return strconv.FormatFloat(v, 'f', digits, 64)
+}
+
+// drawChartSeries 折线（NaN 处断开）
+func drawChartSeries(img *image.RGBA, p chartPanel, series []float64, x func(int) int, c color.RGBA) {
+	prev := -1
+	for i, v := range series {
+		if math.IsNaN(v) {
+			prev = -1
+			continue
+		}
+		if prev >= 0 {
+			drawLine(img, x(prev), p.y(series[prev]), x(i), p.y(v), c)
+		}
+		prev = i
+	}
+}
+
+// fillRect 填充矩形（超出画布的部分忽略）
+func fillRect(img *image.RGBA, x, y, w, h int, c color.RGBA) {
+	r := image.Rect(x, y, x+w, y+h).Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+func drawHLine(img *image.RGBA, x0, x1, y int, c color.RGBA) {
+	fillRect(img, x0, y, x1-x0, 1, c)
+}
+
+func drawVLine(img *image.RGBA, x, y0, y1 int, c color.RGBA) {
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	fillRect(img, x, y0, 1, y1-y0+1, c)
+}
+
+// drawLine Bresenham 直线
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		if (image.Point{X: x0, Y: y0}).In(img.Bounds()) {
+			img.SetRGBA(x0, y0, c)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
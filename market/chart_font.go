@@ -0,0 +1,81 @@
+package market
+
+import (
+	"image"
+	"image/color"
+	"strings"
+)
+
+// 图表标注用的 3×5 点阵字体（只含数字、大写字母与少量符号，足够标注价格、周期与指标名；
+// 不依赖字体文件与第三方绘图库）。未收录的字符按空格处理。
+var chartGlyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'A': {"###", "#.#", "###", "#.#", "#.#"},
+	'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'C': {"###", "#..", "#..", "#..", "###"},
+	'D': {"##.", "#.#", "#.#", "#.#", "##."},
+	'E': {"###", "#..", "###", "#..", "###"},
+	'F': {"###", "#..", "###", "#..", "#.."},
+	'G': {"###", "#..", "#.#", "#.#", "###"},
+	'H': {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I': {"###", ".#.", ".#.", ".#.", "###"},
+	'J': {"..#", "..#", "..#", "#.#", "###"},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L': {"#..", "#..", "#..", "#..", "###"},
+	'M': {"#.#", "###", "###", "#.#", "#.#"},
+	'N': {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O': {"###", "#.#", "#.#", "#.#", "###"},
+	'P': {"###", "#.#", "###", "#..", "#.."},
+	'Q': {"###", "#.#", "#.#", "###", "..#"},
+	'R': {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S': {"###", "#..", "###", "..#", "###"},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	'U': {"#.#", "#.#", "#.#", "#.#", "###"},
+	'V': {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W': {"#.#", "#.#", "###", "###", "#.#"},
+	'X': {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y': {"#.#", "#.#", ".#.", ".#.", ".#."},
+	'Z': {"###", "..#", ".#.", "#..", "###"},
+	'.': {"...", "...", "...", "...", ".#."},
+	'-': {"...", "...", "###", "...", "..."},
+	':': {"...", ".#.", "...", ".#.", "..."},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+	'%': {"#.#", "..#", ".#.", "#..", "#.#"},
+	'(': {".#.", "#..", "#..", "#..", ".#."},
+	')': {".#.", "..#", "..#", "..#", ".#."},
+}
+
+// chartFontScale 点阵放大倍数（每个点 2×2 像素）
+const chartFontScale = 2
+
+// chartTextWidth 文本宽度（像素）
+func chartTextWidth(s string) int {
+	return len([]rune(s)) * 4 * chartFontScale
+}
+
+// drawChartText 以 (x, y) 为左上角绘制文本（小写字母按大写绘制）
+func drawChartText(img *image.RGBA, x, y int, s string, c color.RGBA) {
+	for _, r := range strings.ToUpper(s) {
+		glyph, ok := chartGlyphs[r]
+		if ok {
+			for row, line := range glyph {
+				for col, dot := range line {
+					if dot != '#' {
+						continue
+					}
+					fillRect(img, x+col*chartFontScale, y+row*chartFontScale, chartFontScale, chartFontScale, c)
+				}
+			}
+		}
+		x += 4 * chartFontScale
+	}
+}
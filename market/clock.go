@@ -0,0 +1,71 @@
+package market
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间来源（测试中可注入 FakeClock，使依赖当前时间的计算可重复）
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock 使用系统时间
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// FakeClock 手动控制的时钟
+type FakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewFakeClock 创建停在 t 的时钟
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{t: t}
+}
+
+// Now 返回当前设定的时间
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// Set 设置时间
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+// Advance 前进 d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+var (
+	clockMu sync.RWMutex
+	clock   Clock = systemClock{}
+)
+
+// SetClock 设置行情计算使用的时钟（传 nil 恢复系统时间）
+// 仅影响指标、缓存与采样的时间判断；限流、HTTP 重试与 WebSocket 始终使用系统时间
+func SetClock(c Clock) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	if c == nil {
+		c = systemClock{}
+	}
+	clock = c
+}
+
+// clockNow 返回当前时钟时间
+func clockNow() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock.Now()
+}
@@ -0,0 +1,42 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(t0)
+	if got := c.Now(); !got.Equal(t0) {
+		t.Fatalf("Now() = %v, want %v", got, t0)
+	}
+	c.Advance(3 * time.Minute)
+	if got := c.Now(); !got.Equal(t0.Add(3 * time.Minute)) {
+		t.Errorf("Advance 后 Now() = %v", got)
+	}
+	c.Set(t0.Add(time.Hour))
+	if got := c.Now(); !got.Equal(t0.Add(time.Hour)) {
+		t.Errorf("Set 后 Now() = %v", got)
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	t.Cleanup(func() { SetClock(nil) })
+
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(t0)
+	SetClock(c)
+	if got := clockNow(); !got.Equal(t0) {
+		t.Fatalf("clockNow() = %v, want %v", got, t0)
+	}
+	c.Advance(time.Minute)
+	if got := clockNow(); !got.Equal(t0.Add(time.Minute)) {
+		t.Errorf("clockNow() 应跟随 FakeClock: %v", got)
+	}
+
+	SetClock(nil)
+	if got := clockNow(); time.Since(got) > time.Minute {
+		t.Errorf("SetClock(nil) 应恢复系统时间, got %v", got)
+	}
+}
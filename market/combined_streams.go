@@ -17,7 +17,11 @@ type CombinedStreamsClient struct {
 	subscribers map[string]chan []byte
 	reconnect   bool
 	done        chan struct{}
-	batchSize   int // 每批订阅的流数量
+	batchSize   int                 // 每批订阅的流数量
+	streams     map[string]struct{} // 已订阅的流（重连后需重新订阅）
+	minBackoff  time.Duration       // 重连初始等待
+	maxBackoff  time.Duration       // 重连最大等待
+	closeOnce   sync.Once
 }
 
 func NewCombinedStreamsClient(batchSize int) *CombinedStreamsClient {
@@ -26,6 +30,9 @@ func NewCombinedStreamsClient(batchSize int) *CombinedStreamsClient {
 		reconnect:   true,
 		done:        make(chan struct{}),
 		batchSize:   batchSize,
+		streams:     make(map[string]struct{}),
+		minBackoff:  time.Second,
+		maxBackoff:  60 * time.Second,
 	}
 }
 
@@ -99,8 +106,13 @@ func (c *CombinedStreamsClient) subscribeStreams(streams []string) error {
 		"id":     time.Now().UnixNano(),
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	// 写操作需独占连接（gorilla/websocket 不支持并发写）
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, stream := range streams {
+		c.streams[stream] = struct{}{}
+	}
 
 	if c.conn == nil {
 		return fmt.Errorf("WebSocket未连接")
@@ -110,6 +122,27 @@ func (c *CombinedStreamsClient) subscribeStreams(streams []string) error {
 	return c.conn.WriteJSON(subscribeMsg)
 }
 
+// resubscribeAll 重连后按批次重新订阅所有已记录的流
+func (c *CombinedStreamsClient) resubscribeAll() error {
+	c.mu.RLock()
+	streams := make([]string, 0, len(c.streams))
+	for stream := range c.streams {
+		streams = append(streams, stream)
+	}
+	c.mu.RUnlock()
+
+	for i, batch := range c.splitIntoBatches(streams, c.batchSize) {
+		if err := c.subscribeStreams(batch); err != nil {
+			return fmt.Errorf("第 %d 批重新订阅失败: %v", i+1, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(streams) > 0 {
+		log.Printf("组合流重连后已重新订阅 %d 个流", len(streams))
+	}
+	return nil
+}
+
 func (c *CombinedStreamsClient) readMessages() {
 	for {
 		select {
@@ -169,34 +202,62 @@ func (c *CombinedStreamsClient) AddSubscriber(stream string, bufferSize int) <-c
 	return ch
 }
 
+// handleReconnect 指数退避重连，成功后重新订阅所有流
 func (c *CombinedStreamsClient) handleReconnect() {
-	if !c.reconnect {
-		return
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
 	}
+	c.mu.Unlock()
 
-	log.Println("组合流尝试重新连接...")
-	time.Sleep(3 * time.Second)
+	backoff := c.minBackoff
+	for attempt := 1; ; attempt++ {
+		c.mu.RLock()
+		reconnect := c.reconnect
+		c.mu.RUnlock()
+		if !reconnect {
+			return
+		}
+
+		log.Printf("组合流尝试重新连接（第 %d 次，等待 %v）...", attempt, backoff)
+		select {
+		case <-c.done:
+			return
+		case <-time.After(backoff):
+		}
 
-	if err := c.Connect(); err != nil {
-		log.Printf("组合流重新连接失败: %v", err)
-		go c.handleReconnect()
+		if err := c.Connect(); err != nil {
+			log.Printf("组合流重新连接失败: %v", err)
+			backoff *= 2
+			if backoff > c.maxBackoff {
+				backoff = c.maxBackoff
+			}
+			continue
+		}
+		if err := c.resubscribeAll(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+		return
 	}
 }
 
 func (c *CombinedStreamsClient) Close() {
-	c.reconnect = false
-	close(c.done)
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+		c.reconnect = false
+		close(c.done)
 
-	if c.conn != nil {
-		c.conn.Close()
-		c.conn = nil
-	}
+		if c.conn != nil {
+			c.conn.Close()
+			c.conn = nil
+		}
 
-	for stream, ch := range c.subscribers {
-		close(ch)
-		delete(c.subscribers, stream)
-	}
+		for stream, ch := range c.subscribers {
+			close(ch)
+			delete(c.subscribers, stream)
+		}
+	})
 }
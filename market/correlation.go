@@ -0,0 +1,272 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// correlationMinSamples 计算相关性所需的最少对齐收益率样本数
+const correlationMinSamples = 10
+
+// CorrelationWindow 相关性统计窗口：使用 Interval 周期的最近 Periods 根K线收益率
+type CorrelationWindow struct {
+	Interval string `json:"interval"`
+	Periods  int    `json:"periods"`
+}
+
+// Label 窗口标签，如 "1h×24"
+func (w CorrelationWindow) Label() string {
+	return fmt.Sprintf("%s×%d", w.Interval, w.Periods)
+}
+
+// defaultCorrelationWindows 默认统计窗口（约1天与7天）
+var defaultCorrelationWindows = []CorrelationWindow{{Interval: "1h", Periods: 24}, {Interval: "4h", Periods: 42}}
+
+// defaultCorrelationBenchmarks 默认基准交易对
+var defaultCorrelationBenchmarks = []string{"BTCUSDT", "ETHUSDT"}
+
+// CorrelationStat 交易对相对某个基准在某个窗口内的相关系数与 beta
+type CorrelationStat struct {
+	Benchmark   string  `json:"benchmark"`
+	Window      string  `json:"window"`
+	Correlation float64 `json:"correlation"` // 收益率皮尔逊相关系数（-1 ~ 1）
+	Beta        float64 `json:"beta"`        // 相对基准的 beta（cov / var(基准)）
+	Samples     int     `json:"samples"`     // 参与计算的对齐收益率数量
+}
+
+// CorrelationData 交易对相对各基准的相关性
+type CorrelationData struct {
+	Stats []CorrelationStat `json:"stats"`
+}
+
+// Get 返回指定基准与窗口的统计
+func (c *CorrelationData) Get(benchmark string, w CorrelationWindow) (CorrelationStat, bool) {
+	if c == nil {
+		return CorrelationStat{}, false
+	}
+	label := w.Label()
+	for _, s := range c.Stats {
+		if s.Benchmark == benchmark && s.Window == label {
+			return s, true
+		}
+	}
+	return CorrelationStat{}, false
+}
+
+// Best 返回指定基准样本最多的统计（通常是最长的窗口，beta 最稳定），样本不足时返回 false
+func (c *CorrelationData) Best(benchmark string) (CorrelationStat, bool) {
+	var best CorrelationStat
+	if c == nil {
+		return best, false
+	}
+	for _, s := range c.Stats {
+		if s.Benchmark == benchmark && s.Samples > best.Samples {
+			best = s
+		}
+	}
+	return best, best.Samples >= correlationMinSamples
+}
+
+// CorrelationMatrix 多个交易对之间的收益率相关系数矩阵
+// Values[i][j] 为 Symbols[i] 与 Symbols[j] 的相关系数；样本不足时为 0
+type CorrelationMatrix struct {
+	Window  CorrelationWindow `json:"window"`
+	Symbols []string          `json:"symbols"`
+	Values  [][]float64       `json:"values"`
+	Samples [][]int           `json:"samples"`
+}
+
+// Get 返回两个交易对的相关系数
+func (cm *CorrelationMatrix) Get(a, b string) (float64, bool) {
+	i, j := -1, -1
+	for k, s := range cm.Symbols {
+		if s == a {
+			i = k
+		}
+		if s == b {
+			j = k
+		}
+	}
+	if i < 0 || j < 0 || cm.Samples[i][j] < correlationMinSamples {
+		return 0, false
+	}
+	return cm.Values[i][j], true
+}
+
+// klineReturns 按开盘时间索引的对数收益率（剔除未收盘的最后一根K线）
+func klineReturns(klines []Kline, now time.Time) map[int64]float64 {
+	if n := len(klines); n > 0 && klines[n-1].CloseTime > now.UnixMilli() {
+		klines = klines[:n-1]
+	}
+	returns := make(map[int64]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		prev, cur := klines[i-1].Close, klines[i].Close
+		if prev <= 0 || cur <= 0 {
+			continue
+		}
+		returns[klines[i].OpenTime] = math.Log(cur / prev)
+	}
+	return returns
+}
+
+// alignReturns 取两组收益率在共同时间点上最近 periods 个样本（从旧到新）
+func alignReturns(a, b map[int64]float64, periods int) (x, y []float64) {
+	times := make([]int64, 0, len(a))
+	for t := range a {
+		if _, ok := b[t]; ok {
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	if periods > 0 && len(times) > periods {
+		times = times[len(times)-periods:]
+	}
+	x = make([]float64, len(times))
+	y = make([]float64, len(times))
+	for i, t := range times {
+		x[i], y[i] = a[t], b[t]
+	}
+	return x, y
+}
+
+// correlationBeta 计算 x 相对 y 的皮尔逊相关系数与 beta
+func correlationBeta(x, y []float64) (corr, beta float64) {
+	n := float64(len(x))
+	if n == 0 {
+		return 0, 0
+	}
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= n
+	meanY /= n
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varY > 0 {
+		beta = cov / varY
+	}
+	if varX > 0 && varY > 0 {
+		corr = cov / math.Sqrt(varX*varY)
+	}
+	return corr, beta
+}
+
+// calculateCorrelationData 计算交易对相对各基准在各窗口的相关系数与 beta
+// klinesOf 按交易对与周期获取K线；交易对自身为基准或数据不足时跳过
+func calculateCorrelationData(symbol string, benchmarks []string, windows []CorrelationWindow, klinesOf func(symbol, interval string) ([]Kline, error), now time.Time) *CorrelationData {
+	data := &CorrelationData{}
+	for _, w := range windows {
+		own, err := klinesOf(symbol, w.Interval)
+		if err != nil {
+			continue
+		}
+		ownReturns := klineReturns(own, now)
+		for _, bench := range benchmarks {
+			if bench == symbol {
+				continue
+			}
+			benchKlines, err := klinesOf(bench, w.Interval)
+			if err != nil {
+				continue
+			}
+			x, y := alignReturns(ownReturns, klineReturns(benchKlines, now), w.Periods)
+			if len(x) < correlationMinSamples {
+				continue
+			}
+			corr, beta := correlationBeta(x, y)
+			data.Stats = append(data.Stats, CorrelationStat{
+				Benchmark:   bench,
+				Window:      w.Label(),
+				Correlation: corr,
+				Beta:        beta,
+				Samples:     len(x),
+			})
+		}
+	}
+	if len(data.Stats) == 0 {
+		return nil
+	}
+	return data
+}
+
+// correlationWindows 返回配置的相关性窗口
+func (m *Monitor) correlationWindows() []CorrelationWindow {
+	return m.config.CorrelationWindows
+}
+
+// CorrelationMatrix 计算一组交易对在指定窗口内的收益率相关系数矩阵（window 为零值时使用第一个配置窗口）
+func (m *Monitor) CorrelationMatrix(symbols []string, window CorrelationWindow) (*CorrelationMatrix, error) {
+	if window.Interval == "" {
+		window = m.correlationWindows()[0]
+	}
+	now := clockNow()
+	cm := &CorrelationMatrix{Window: window}
+	var returns []map[int64]float64
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		symbol = Normalize(symbol)
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		klines, err := m.GetCurrentKlines(symbol, window.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("获取 %s %s K线失败: %w", symbol, window.Interval, err)
+		}
+		cm.Symbols = append(cm.Symbols, symbol)
+		returns = append(returns, klineReturns(klines, now))
+	}
+
+	n := len(cm.Symbols)
+	cm.Values = make([][]float64, n)
+	cm.Samples = make([][]int, n)
+	for i := range cm.Symbols {
+		cm.Values[i] = make([]float64, n)
+		cm.Samples[i] = make([]int, n)
+	}
+	for i := 0; i < n; i++ {
+		x, _ := alignReturns(returns[i], returns[i], window.Periods)
+		cm.Values[i][i], cm.Samples[i][i] = 1, len(x)
+		for j := i + 1; j < n; j++ {
+			x, y := alignReturns(returns[i], returns[j], window.Periods)
+			cm.Samples[i][j], cm.Samples[j][i] = len(x), len(x)
+			if len(x) < correlationMinSamples {
+				continue
+			}
+			corr, _ := correlationBeta(x, y)
+			cm.Values[i][j], cm.Values[j][i] = corr, corr
+		}
+	}
+	return cm, nil
+}
+
+// GetCorrelationMatrix 使用默认监控器计算相关系数矩阵
+func GetCorrelationMatrix(symbols []string, window CorrelationWindow) (*CorrelationMatrix, error) {
+	m := DefaultMonitor()
+	if m == nil {
+		return nil, ErrMonitorNotInitialized
+	}
+	return m.CorrelationMatrix(symbols, window)
+}
+
+// writeCorrelation 输出相对基准的相关系数与 beta
+func writeCorrelation(sb *strings.Builder, l reportLocale, c *CorrelationData) {
+	if c == nil {
+		return
+	}
+	parts := make([]string, 0, len(c.Stats))
+	for _, s := range c.Stats {
+		parts = append(parts, l.f("correlation_item", s.Benchmark, s.Window, s.Correlation, s.Beta))
+	}
+	sb.WriteString(l.f("correlation_summary", strings.Join(parts, ", ")))
+}
@@ -2,58 +2,126 @@ package market
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"math"
-	"net/http"
+	"nofx/market/events"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
-// Get 获取指定代币的市场数据
+// 获取市场数据失败的类型（经 %w 包装，调用方用 errors.Is 区分）
+var (
+	// ErrNoKlines 某个周期没有可用K线（数据源返回空、回补失败）
+	ErrNoKlines = errors.New("无可用K线")
+	// ErrSymbolNotFound 交易对不存在或已下架（币安 -1121 Invalid symbol）
+	ErrSymbolNotFound = errors.New("交易对不存在")
+	// ErrStaleData 当前价格所在周期（3m）的K线长时间未更新，拒绝用过期价格生成数据
+	ErrStaleData = errors.New("行情数据已过期")
+)
+
+// Get 使用默认监控器获取指定代币的市场数据
 func Get(symbol string) (*Data, error) {
+	return GetWithConfig(symbol, DefaultIndicatorConfig())
+}
+
+// GetWithConfig 使用默认监控器（启用回放时为回放源），按指定指标配置获取市场数据
+func GetWithConfig(symbol string, cfg IndicatorConfig) (*Data, error) {
+	return GetWithSource(symbol, nil, cfg)
+}
+
+// GetWithSource 使用默认监控器（启用回放时为回放源），从指定数据源获取市场数据
+func GetWithSource(symbol string, src Source, cfg IndicatorConfig) (*Data, error) {
+	p, err := currentProvider()
+	if err != nil {
+		return nil, err
+	}
+	return p.GetWithSource(symbol, src, cfg)
+}
+
+// Get 获取指定代币的市场数据（默认指标配置）
+func (m *Monitor) Get(symbol string) (*Data, error) {
+	return m.GetWithConfig(symbol, DefaultIndicatorConfig())
+}
+
+// GetWithConfig 按指定指标配置获取市场数据（数据源按交易对选择，默认币安）
+func (m *Monitor) GetWithConfig(symbol string, cfg IndicatorConfig) (*Data, error) {
+	return m.GetWithSource(symbol, nil, cfg)
+}
+
+// GetWithSource 从指定数据源获取市场数据，src 为 nil 时按 SourceFor 选择
+// 币安使用 WebSocket K线缓存与增量指标引擎；其他交易所通过 REST 获取K线，深度、多空比、强平等币安专有数据不可用
+func (m *Monitor) GetWithSource(symbol string, src Source, cfg IndicatorConfig) (*Data, error) {
+	cfg = cfg.withDefaults()
 	var klines3m, klines4h []Kline
 	var err error
 	// 标准化symbol
 	symbol = Normalize(symbol)
+	if src == nil {
+		src = SourceFor(symbol)
+	}
+	isBinance := src.Name() == SourceBinance
+	klinesOf := func(sym, interval string) ([]Kline, error) {
+		if isBinance {
+			return m.GetCurrentKlines(sym, interval)
+		}
+		return src.Klines(src.Normalize(sym), interval, m.capacityFor(interval))
+	}
+	getKlines := func(interval string) ([]Kline, error) {
+		klines, err := klinesOf(symbol, interval)
+		if err == nil && len(klines) == 0 {
+			err = ErrNoKlines
+		}
+		return klines, err
+	}
+	engineFor := func(interval string, set IndicatorSet) *indicatorEngine {
+		if !isBinance {
+			return nil
+		}
+		return m.indicatorEngine(symbol, interval, set)
+	}
+
 	// 获取3分钟K线数据 (最近10个)
-	klines3m, err = WSMonitorCli.GetCurrentKlines(symbol, "3m") // 多获取一些用于计算
+	klines3m, err = getKlines("3m") // 多获取一些用于计算
 	if err != nil {
-		return nil, fmt.Errorf("获取3分钟K线失败: %v", err)
+		return nil, fmt.Errorf("获取%s 3分钟K线失败: %w", symbol, err)
 	}
 
 	// 获取4小时K线数据 (最近10个)
-	klines4h, err = WSMonitorCli.GetCurrentKlines(symbol, "4h") // 多获取用于计算指标
+	klines4h, err = getKlines("4h") // 多获取用于计算指标
 	if err != nil {
-		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
+		return nil, fmt.Errorf("获取%s 4小时K线失败: %w", symbol, err)
 	}
 
 	// 新增15m数据
-	klines15m, err := WSMonitorCli.GetCurrentKlines(symbol, "15m")
+	klines15m, err := getKlines("15m")
 	if err != nil {
-		return nil, fmt.Errorf("获取15分钟K线失败: %v", err)
+		return nil, fmt.Errorf("获取%s 15分钟K线失败: %w", symbol, err)
 	}
 
 	// 新增1h数据
-	klines1h, err := WSMonitorCli.GetCurrentKlines(symbol, "1h")
+	klines1h, err := getKlines("1h")
 	if err != nil {
-		return nil, fmt.Errorf("获取1小时K线失败: %v", err)
+		return nil, fmt.Errorf("获取%s 1小时K线失败: %w", symbol, err)
 	}
 
 	// 新增1d数据
-	klines1d, err := WSMonitorCli.GetCurrentKlines(symbol, "1d")
+	klines1d, err := getKlines("1d")
 	if err != nil {
-		return nil, fmt.Errorf("获取1天K线失败: %v", err)
+		return nil, fmt.Errorf("获取%s 1天K线失败: %w", symbol, err)
+	}
+	now := m.now()
+	if err := checkKlinesFresh("3m", klines3m, now); err != nil {
+		return nil, fmt.Errorf("%s: %w", symbol, err)
 	}
 
 	// 计算当前指标 (基于3分钟最新数据)
 	currentPrice := klines3m[len(klines3m)-1].Close
-	currentEMA20 := calculateEMA(klines3m, 20)
-	dif, _, _ := calculateMACD(klines3m, 12, 26, 9)
+	currentEMA20 := calculateEMA(klines3m, cfg.CurrentEMA)
+	dif, _, _ := calculateMACD(klines3m, cfg.CurrentMACD.Fast, cfg.CurrentMACD.Slow, cfg.CurrentMACD.Signal)
 	currentMACD := dif
-	currentRSI7 := calculateRSI(klines3m, 7)
+	currentRSI7 := calculateRSI(klines3m, cfg.CurrentRSI)
 
 	// 计算价格变化百分比
 
@@ -99,25 +167,89 @@ func Get(symbol string) (*Data, error) {
 		}
 	}
 
-	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
-	if err != nil {
-		// OI失败不影响整体,使用默认值
-		oiData = &OIData{Latest: 0, Average: 0}
+	// 记录缺失/过期的数据段，随 Data 一起输出
+	quality := &DataQuality{}
+
+	// 获取OI数据与Funding Rate（现货无这两项，直接跳过）
+	futures := sourceMarketType(src).IsFutures()
+	var oiData *OIData
+	fundingRate := 0.0
+	var funding *FundingData
+	if futures {
+		if isBinance {
+			oiData, err = getOpenInterestData(symbol)
+		} else {
+			oiData, err = getSourceOIData(src, symbol, now)
+		}
+		if err != nil {
+			// OI失败不影响整体，记录到数据质量中（不再以0值冒充真实数据）
+			oiData = nil
+			quality.missing(QualitySectionOpenInterest, err)
+		}
+
+		// 含历史费率与下次结算时间
+		if isBinance {
+			funding, err = getFundingData(symbol)
+		} else {
+			funding, err = getSourceFundingData(src, symbol)
+		}
+		if err == nil {
+			fundingRate = funding.Current
+		} else {
+			quality.missing(QualitySectionFunding, err)
+		}
+	}
+	// 价量+OI效率在缺少OI时按OI无变化计算
+	oiChanges := oiData
+	if oiChanges == nil {
+		oiChanges = &OIData{}
 	}
 
-	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
+	// 深度、多空比、强平仅币安可用（失败不影响整体）
+	var depth *DepthData
+	var sentiment *SentimentData
+	var liquidations *LiquidationData
+	if isBinance {
+		// 获取订单簿深度
+		if depth, err = getDepthData(symbol); err != nil {
+			quality.missing(QualitySectionDepth, err)
+		}
+		// 获取多空比（带缓存）
+		if sentiment, err = getSentimentData(symbol); err != nil {
+			quality.missing(QualitySectionSentiment, err)
+		}
+		liquidations = m.LiquidationData(symbol)
+	}
 
 	// 计算各时间框架的指标数据
-	intradayData := calculateIntradaySeries(klines3m)   // 3分钟
-	intraday15m := calculateIntradaySeries(klines15m)   // 15分钟
-	intraday1h := calculateIntradaySeries(klines1h)     // 1小时
-	longerTermData := calculateLongerTermData(klines4h) // 4小时
-	longerTerm1d := calculateLongerTermData(klines1d)   // 1天
-
-	return &Data{
+	// EMA/MACD/RSI 序列使用跨调用复用的增量引擎，已收盘K线只计算一次
+	intradayData := calculateIntradaySeriesWithEngine(klines3m, cfg.Intraday, cfg.SeriesLength, engineFor("3m", cfg.Intraday))       // 3分钟
+	intraday15m := calculateIntradaySeriesWithEngine(klines15m, cfg.Intraday, cfg.SeriesLength, engineFor("15m", cfg.Intraday))      // 15分钟
+	intraday1h := calculateIntradaySeriesWithEngine(klines1h, cfg.Intraday, cfg.SeriesLength, engineFor("1h", cfg.Intraday))         // 1小时
+	longerTermData := calculateLongerTermDataWithEngine(klines4h, cfg.LongerTerm, cfg.SeriesLength, engineFor("4h", cfg.LongerTerm)) // 4小时
+	longerTerm1d := calculateLongerTermDataWithEngine(klines1d, cfg.LongerTerm, cfg.SeriesLength, engineFor("1d", cfg.LongerTerm))   // 1天
+
+	// 记录各组指标的计算来源
+	intradayData.Provenance = newProvenance("3m", klines3m, now)
+	intraday15m.Provenance = newProvenance("15m", klines15m, now)
+	intraday1h.Provenance = newProvenance("1h", klines1h, now)
+	longerTermData.Provenance = newProvenance("4h", klines4h, now)
+	longerTerm1d.Provenance = newProvenance("1d", klines1d, now)
+
+	frames := map[string][]Kline{"3m": klines3m, "15m": klines15m, "1h": klines1h, "4h": klines4h, "1d": klines1d}
+	quality.CheckedAt = now
+	for _, tf := range []string{"3m", "15m", "1h", "4h", "1d"} {
+		quality.checkKlines(tf, frames[tf], now)
+	}
+	quality.checkFunding(funding, now)
+	effort3m := computeEffortResult(priceChange3m, intradayData, oiChanges.Change5m)
+	effort15m := computeEffortResult(priceChange15m, intraday15m, oiChanges.Change15m)
+	effort1h := computeEffortResult(priceChange1h, intraday1h, oiChanges.Change1h)
+	data := &Data{
 		Symbol:            symbol,
+		Timestamp:         now,
+		Source:            src.Name(),
+		Market:            sourceMarketType(src),
 		CurrentPrice:      currentPrice,
 		PriceChange3m:     priceChange3m,
 		PriceChange15m:    priceChange15m, // 新增
@@ -129,25 +261,43 @@ func Get(symbol string) (*Data, error) {
 		CurrentRSI7:       currentRSI7,
 		OpenInterest:      oiData,
 		FundingRate:       fundingRate,
+		Funding:           funding,
+		Basis:             calculateBasisData(symbol, funding, currentPrice, now),
+		Depth:             depth,
+		TakerFlow:         calculateTakerFlow(m.flowWindows(), now, klines3m, klines1h, klines1d),
+		Liquidations:      liquidations,
+		Sentiment:         sentiment,
+		Regime:            calculateRegimeData(frames),
+		Volatility:        calculateVolatilityData(frames),
+		KeyLevels:         calculateKeyLevels(currentPrice, klines1h, klines4h, klines1d, now),
+		Correlation:       calculateCorrelationData(symbol, m.config.CorrelationBenchmarks, m.correlationWindows(), klinesOf, now),
 		IntradaySeries:    intradayData,
 		LongerTermContext: longerTermData,
 		Intraday15m:       intraday15m,  // 新增
 		Intraday1h:        intraday1h,   // 新增
 		LongerTerm1d:      longerTerm1d, // 新增
-		EffortResult3m:    computeEffortResult(priceChange3m, intradayData, oiData.Change5m),
-		EffortResult15m:   computeEffortResult(priceChange15m, intraday15m, oiData.Change15m),
-		EffortResult1h:    computeEffortResult(priceChange1h, intraday1h, oiData.Change1h),
-		EffortLabel3m:     classifyEffortResult(computeEffortResult(priceChange3m, intradayData, oiData.Change5m)),
-		EffortLabel15m:    classifyEffortResult(computeEffortResult(priceChange15m, intraday15m, oiData.Change15m)),
-		EffortLabel1h:     classifyEffortResult(computeEffortResult(priceChange1h, intraday1h, oiData.Change1h)),
-	}, nil
+		EffortResult3m:    effort3m,
+		EffortResult15m:   effort15m,
+		EffortResult1h:    effort1h,
+		EffortLabel3m:     classifyEffortWithKlines(symbol, "3m", klines3m, effort3m),
+		EffortLabel15m:    classifyEffortWithKlines(symbol, "15m", klines15m, effort15m),
+		EffortLabel1h:     classifyEffortWithKlines(symbol, "1h", klines1h, effort1h),
+		CurrentProvenance: intradayData.Provenance,
+		Indicators:        &cfg,
+		Quality:           quality.orNil(),
+		EventRisk:         events.RiskFor(symbol, now),
+	}
+	recordSnapshot(data)
+	evaluateAlerts(data)
+	return data, nil
 }
 
 // computeEffortResult 计算价量+OI协同效率
 // priceChangePercent: 该时间框架的价格百分比变化 (正负)；
 // intraday: 对应的短周期数据(含 VolumeSpikeRatio)；
 // oiChange: 对应时间尺度的 OI 变化率（例如 Change5m）
-// 返回值：效率比 = 价格变化百分比 / (量能放大倍数 * (1 + |OI变化率|))，若分母为0返回0。
+// 返回值：效率比 = 价格变化百分比 / (量能放大倍数^VolumeWeight * (1 + OIWeight*|OI变化率|))，若分母为0返回0。
+// 权重见 EffortConfig（默认均为 1）
 func computeEffortResult(priceChangePercent float64, intraday *IntradayData, oiChange float64) float64 {
 	if intraday == nil {
 		return 0
@@ -156,28 +306,29 @@ func computeEffortResult(priceChangePercent float64, intraday *IntradayData, oiC
 	if vs <= 0 {
 		return 0
 	}
-	denom := vs * (1 + math.Abs(oiChange))
+	cfg := currentEffortConfig()
+	denom := math.Pow(vs, cfg.VolumeWeight) * (1 + cfg.OIWeight*math.Abs(oiChange))
 	if denom == 0 {
 		return 0
 	}
 	return priceChangePercent / denom
 }
 
-// classifyEffortResult 根据效率比返回标签
-// >0.7 高效推进；0.3-0.7 正常；<0.3 低效/潜在吸筹或分配；若为负且绝对值大说明反向压力
-func classifyEffortResult(r float64) string {
+// classifyEffortResultWith 根据效率比与阈值返回标签（固定阈值为 0.3/0.7/0.9，校准方式见 EffortConfig）
+// >High 高效推进；Normal-High 正常；<Normal 低效/潜在吸筹或分配；若为负且绝对值大说明反向压力
+func classifyEffortResultWith(r float64, t effortThresholds) string {
 	switch {
-	case r > 0.9:
+	case r > t.VeryHigh:
 		return "极高效率"
-	case r > 0.7:
+	case r > t.High:
 		return "高效率"
-	case r > 0.3:
+	case r > t.Normal:
 		return "正常"
 	case r > 0:
 		return "低效率"
-	case r <= 0 && r > -0.3:
+	case r <= 0 && r > -t.Normal:
 		return "反向轻压"
-	case r <= -0.3 && r > -0.7:
+	case r <= -t.Normal && r > -t.High:
 		return "反向压力"
 	default:
 		return "强反向压力"
@@ -355,27 +506,34 @@ func calculateATR(klines []Kline, period int) float64 {
 	return atr
 }
 
-// calculateIntradaySeries 计算日内系列数据
+// calculateIntradaySeries 计算日内系列数据（默认指标配置）
 func calculateIntradaySeries(klines []Kline) *IntradayData {
+	cfg := DefaultIndicatorConfig()
+	return calculateIntradaySeriesWithConfig(klines, cfg.Intraday, cfg.SeriesLength)
+}
+
+// calculateIntradaySeriesWithConfig 按指标集合计算日内系列数据（每次从头计算）
+func calculateIntradaySeriesWithConfig(klines []Kline, set IndicatorSet, seriesLength int) *IntradayData {
+	return calculateIntradaySeriesWithEngine(klines, set, seriesLength, nil)
+}
+
+// calculateIntradaySeriesWithEngine 按指标集合计算日内系列数据，eng 非空时 EMA/MACD/RSI 序列使用增量引擎
+func calculateIntradaySeriesWithEngine(klines []Kline, set IndicatorSet, seriesLength int, eng *indicatorEngine) *IntradayData {
 	data := &IntradayData{
-		MidPrices:       make([]float64, 0, 10),
-		EMA20Values:     make([]float64, 0, 10),
-		MACDValues10208: make([]float64, 0, 10),
-		MACDValues12269: make([]float64, 0, 10),
-		RSI7Values:      make([]float64, 0, 10),
-		RSI9Values:      make([]float64, 0, 10),
-		RSI10Values:     make([]float64, 0, 10),
-		RSI14Values:     make([]float64, 0, 10),
-		VolumeValues:    make([]float64, 0, 10),
+		MidPrices:    make([]float64, 0, seriesLength),
+		VolumeValues: make([]float64, 0, seriesLength),
+		EMASeries:    make(map[int][]float64, len(set.EMA)),
+		RSISeries:    make(map[int][]float64, len(set.RSI)),
+		MACDSeries:   make(map[string][]float64, len(set.MACD)),
+		ATR:          make(map[int]float64, len(set.ATR)),
 	}
 	// 计算ATR
-	data.ATR6 = calculateATR(klines, 6)
-	data.ATR10 = calculateATR(klines, 10)
-	data.ATR12 = calculateATR(klines, 12)
-	data.ATR14 = calculateATR(klines, 14)
+	for _, p := range set.ATR {
+		data.ATR[p] = calculateATR(klines, p)
+	}
 
-	// 获取最近10个数据点
-	start := len(klines) - 10
+	// 获取最近N个数据点
+	start := len(klines) - seriesLength
 	if start < 0 {
 		start = 0
 	}
@@ -383,45 +541,51 @@ func calculateIntradaySeries(klines []Kline) *IntradayData {
 	for i := start; i < len(klines); i++ {
 		data.MidPrices = append(data.MidPrices, klines[i].Close)
 		data.VolumeValues = append(data.VolumeValues, klines[i].Volume)
+	}
 
-		// 计算每个点的EMA20
-		if i >= 19 {
-			ema20 := calculateEMA(klines[:i+1], 20)
-			data.EMA20Values = append(data.EMA20Values, ema20)
+	if eng != nil {
+		data.EMASeries, data.RSISeries, data.MACDSeries = eng.compute(klines, seriesLength)
+	}
+	for i := start; eng == nil && i < len(klines); i++ {
+		// 计算每个点的EMA
+		for _, p := range set.EMA {
+			if i >= p-1 {
+				data.EMASeries[p] = append(data.EMASeries[p], calculateEMA(klines[:i+1], p))
+			}
 		}
 
 		// 计算每个点的MACD
-		if i >= 25 {
-			dif, _, _ := calculateMACD(klines[:i+1], 10, 20, 8)
-			macd := dif
-			data.MACDValues10208 = append(data.MACDValues10208, macd)
-		}
-		// 计算每个点的MACD
-		if i >= 25 {
-			dif, _, _ := calculateMACD(klines[:i+1], 12, 26, 9)
-			macd := dif
-			data.MACDValues12269 = append(data.MACDValues12269, macd)
+		for _, mp := range set.MACD {
+			if i >= mp.Slow-1 {
+				dif, _, _ := calculateMACD(klines[:i+1], mp.Fast, mp.Slow, mp.Signal)
+				data.MACDSeries[mp.Key()] = append(data.MACDSeries[mp.Key()], dif)
+			}
 		}
 
 		// 计算每个点的RSI
-		if i >= 7 {
-			rsi7 := calculateRSI(klines[:i+1], 7)
-			data.RSI7Values = append(data.RSI7Values, rsi7)
-		}
-		if i >= 9 {
-			rsi9 := calculateRSI(klines[:i+1], 9)
-			data.RSI9Values = append(data.RSI9Values, rsi9)
-		}
-		if i >= 10 {
-			rsi10 := calculateRSI(klines[:i+1], 10)
-			data.RSI10Values = append(data.RSI10Values, rsi10)
-		}
-		if i >= 14 {
-			rsi14 := calculateRSI(klines[:i+1], 14)
-			data.RSI14Values = append(data.RSI14Values, rsi14)
+		for _, p := range set.RSI {
+			if i >= p {
+				data.RSISeries[p] = append(data.RSISeries[p], calculateRSI(klines[:i+1], p))
+			}
 		}
 	}
 
+	// 默认周期的快捷字段
+	data.ATR6, data.ATR10, data.ATR12, data.ATR14 = data.ATR[6], data.ATR[10], data.ATR[12], data.ATR[14]
+	data.EMA20Values = data.EMASeries[20]
+	data.MACDValues10208 = data.MACDSeries[MACDParams{10, 20, 8}.Key()]
+	data.MACDValues12269 = data.MACDSeries[MACDParams{12, 26, 9}.Key()]
+	data.RSI7Values = data.RSISeries[7]
+	data.RSI9Values = data.RSISeries[9]
+	data.RSI10Values = data.RSISeries[10]
+	data.RSI14Values = data.RSISeries[14]
+
+	// 波动带、趋势强度与VWAP
+	data.Bollinger = calculateBollinger(klines, bollingerPeriod, bollingerMultiplier)
+	data.StochRSIK, data.StochRSID = calculateStochRSI(klines, stochRSIPeriod, stochPeriod, stochSmoothK, stochSmoothD)
+	data.ADX, data.PlusDI, data.MinusDI = calculateADX(klines, adxPeriod)
+	data.VWAP = calculateSessionVWAP(klines)
+
 	// 量能统计：最近一个点与之前的平均比较
 	if len(data.VolumeValues) > 1 {
 		var sum float64
@@ -440,24 +604,43 @@ func calculateIntradaySeries(klines []Kline) *IntradayData {
 	return data
 }
 
-// calculateLongerTermData 计算长期数据
+// calculateLongerTermData 计算长期数据（默认指标配置）
 func calculateLongerTermData(klines []Kline) *LongerTermData {
+	cfg := DefaultIndicatorConfig()
+	return calculateLongerTermDataWithConfig(klines, cfg.LongerTerm, cfg.SeriesLength)
+}
+
+// calculateLongerTermDataWithConfig 按指标集合计算长期数据（每次从头计算）
+func calculateLongerTermDataWithConfig(klines []Kline, set IndicatorSet, seriesLength int) *LongerTermData {
+	return calculateLongerTermDataWithEngine(klines, set, seriesLength, nil)
+}
+
+// calculateLongerTermDataWithEngine 按指标集合计算长期数据，eng 非空时 EMA/MACD/RSI 使用增量引擎
+func calculateLongerTermDataWithEngine(klines []Kline, set IndicatorSet, seriesLength int, eng *indicatorEngine) *LongerTermData {
 	data := &LongerTermData{
-		MACDValues142810: make([]float64, 0, 10),
-		MACDValues12269:  make([]float64, 0, 10),
-		RSI14Values:      make([]float64, 0, 10),
-		RSI21Values:      make([]float64, 0, 10),
+		EMA:        make(map[int]float64, len(set.EMA)),
+		RSISeries:  make(map[int][]float64, len(set.RSI)),
+		MACDSeries: make(map[string][]float64, len(set.MACD)),
+		ATR:        make(map[int]float64, len(set.ATR)),
 	}
 
 	// 计算EMA
-	data.EMA20 = calculateEMA(klines, 20)
-	data.EMA50 = calculateEMA(klines, 50)
+	var emaSeries map[int][]float64
+	if eng != nil {
+		emaSeries, data.RSISeries, data.MACDSeries = eng.compute(klines, seriesLength)
+	}
+	for _, p := range set.EMA {
+		if eng == nil {
+			data.EMA[p] = calculateEMA(klines, p)
+		} else if series := emaSeries[p]; len(series) > 0 {
+			data.EMA[p] = series[len(series)-1]
+		}
+	}
 
 	// 计算ATR
-	data.ATR3 = calculateATR(klines, 3)
-	data.ATR10 = calculateATR(klines, 10)
-	data.ATR12 = calculateATR(klines, 12)
-	data.ATR14 = calculateATR(klines, 14)
+	for _, p := range set.ATR {
+		data.ATR[p] = calculateATR(klines, p)
+	}
 
 	// 计算成交量
 	if len(klines) > 0 {
@@ -471,50 +654,69 @@ func calculateLongerTermData(klines []Kline) *LongerTermData {
 	}
 
 	// 计算MACD和RSI序列
-	start := len(klines) - 10
+	start := len(klines) - seriesLength
 	if start < 0 {
 		start = 0
 	}
 
-	for i := start; i < len(klines); i++ {
-		if i >= 25 {
-			dif, _, _ := calculateMACD(klines[:i+1], 14, 28, 10)
-			macd := dif
-			data.MACDValues142810 = append(data.MACDValues142810, macd)
-		}
-		if i >= 25 {
-			dif, _, _ := calculateMACD(klines[:i+1], 12, 26, 9)
-			macd := dif
-			data.MACDValues12269 = append(data.MACDValues12269, macd)
-		}
-		if i >= 14 {
-			rsi14 := calculateRSI(klines[:i+1], 14)
-			data.RSI14Values = append(data.RSI14Values, rsi14)
+	for i := start; eng == nil && i < len(klines); i++ {
+		for _, mp := range set.MACD {
+			if i >= mp.Slow-1 {
+				dif, _, _ := calculateMACD(klines[:i+1], mp.Fast, mp.Slow, mp.Signal)
+				data.MACDSeries[mp.Key()] = append(data.MACDSeries[mp.Key()], dif)
+			}
 		}
-		if i >= 21 {
-			rsi21 := calculateRSI(klines[:i+1], 21)
-			data.RSI21Values = append(data.RSI21Values, rsi21)
+		for _, p := range set.RSI {
+			if i >= p {
+				data.RSISeries[p] = append(data.RSISeries[p], calculateRSI(klines[:i+1], p))
+			}
 		}
 	}
 
+	// 默认周期的快捷字段
+	data.EMA20, data.EMA50 = data.EMA[20], data.EMA[50]
+	data.ATR3, data.ATR10, data.ATR12, data.ATR14 = data.ATR[3], data.ATR[10], data.ATR[12], data.ATR[14]
+	data.MACDValues142810 = data.MACDSeries[MACDParams{14, 28, 10}.Key()]
+	data.MACDValues12269 = data.MACDSeries[MACDParams{12, 26, 9}.Key()]
+	data.RSI14Values = data.RSISeries[14]
+	data.RSI21Values = data.RSISeries[21]
+
+	// 波动带与趋势强度
+	data.Bollinger = calculateBollinger(klines, bollingerPeriod, bollingerMultiplier)
+	data.StochRSIK, data.StochRSID = calculateStochRSI(klines, stochRSIPeriod, stochPeriod, stochSmoothK, stochSmoothD)
+	data.ADX, data.PlusDI, data.MinusDI = calculateADX(klines, adxPeriod)
+
 	return data
 }
 
 // getOpenInterestData 获取OI数据
 func getOpenInterestData(symbol string) (*OIData, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
+	oi, err := fetchBinanceOpenInterest(symbol)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	// --- 构建历史序列与变化率 ---
+	// 采样持久化到 OIStore（见 SetOIStore），按不同周期分桶得到 5m/15m/1h/4h/1d 序列
+	// 首次请求时先通过 openInterestHist 回补历史
+	ensureOIBackfill(symbol)
+	samples := recordOISample(symbol, oi, clockNow())
+	return buildOIData(oi, samples), nil
+}
+
+// fetchBinanceOpenInterest 获取币安当前持仓量
+func fetchBinanceOpenInterest(symbol string) (float64, error) {
+	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
+
+	body, err := binanceGet(url, 1)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	return ParseOpenInterest(body)
+}
 
+// ParseOpenInterest 解析 /fapi/v1/openInterest 响应
+func ParseOpenInterest(body []byte) (float64, error) {
 	var result struct {
 		OpenInterest string `json:"openInterest"`
 		Symbol       string `json:"symbol"`
@@ -522,19 +724,29 @@ func getOpenInterestData(symbol string) (*OIData, error) {
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
+		return 0, err
 	}
 
 	oi, parseErr := strconv.ParseFloat(result.OpenInterest, 64)
 	if parseErr != nil {
-		return nil, fmt.Errorf("parse openInterest failed: %w", parseErr)
+		return 0, fmt.Errorf("parse openInterest failed: %w", parseErr)
 	}
+	return oi, nil
+}
+
+// buildOIData 根据采样历史计算各周期序列、变化率与趋势评分
+// 各周期按时间对齐降采样，保留点数见 OISeriesPolicy
+func buildOIData(oi float64, samples []OISample) *OIData {
+	var end time.Time
+	if len(samples) > 0 {
+		end = samples[len(samples)-1].Time
+	}
+	series5m := bucketOISeries(samples, "5m", end)
+	series15m := bucketOISeries(samples, "15m", end)
+	series1h := bucketOISeries(samples, "1h", end)
+	series4h := bucketOISeries(samples, "4h", end)
+	series1d := bucketOISeries(samples, "1d", end)
 
-	// --- 构建历史序列与变化率 ---
-	// 说明：当前实现没有本地持久化，此处仅演示：
-	// 从全局map(按symbol)缓存一个短期序列（例如最近 288 * 5m ≈ 1天），
-	// 并基于不同倍率聚合得到 5m/15m/1h/4h/1d 的抽样点。
-	series := updateOISeriesCache(symbol, oi)
 	// 聚合函数：给出不同窗口的最新两个点的变化率
 	calcChange := func(slice []float64) float64 {
 		if len(slice) < 2 {
@@ -548,287 +760,47 @@ func getOpenInterestData(symbol string) (*OIData, error) {
 		return (curr - prev) / prev
 	}
 
-	change5m := calcChange(series.fiveMins)
-	change15m := calcChange(series.fifteenMins)
-	change1h := calcChange(series.oneHours)
-	change4h := calcChange(series.fourHours)
-	change1d := calcChange(series.oneDays)
-
-	trendScore := (change5m + change15m + change1h + change4h + change1d) / 5.0
-
-	return &OIData{
-		Latest:     oi,
-		Average:    oi * 0.999, // TODO: 可替换为真实滑动平均
-		Series5m:   append([]float64(nil), series.fiveMins...),
-		Series15m:  append([]float64(nil), series.fifteenMins...),
-		Series1h:   append([]float64(nil), series.oneHours...),
-		Series4h:   append([]float64(nil), series.fourHours...),
-		Series1d:   append([]float64(nil), series.oneDays...),
-		Change5m:   change5m,
-		Change15m:  change15m,
-		Change1h:   change1h,
-		Change4h:   change4h,
-		Change1d:   change1d,
-		TrendScore: trendScore,
-	}, nil
-}
-
-// --- OI 序列缓存结构与更新逻辑 ---
-type oiSeries struct {
-	fiveMins    []float64
-	fifteenMins []float64
-	oneHours    []float64
-	fourHours   []float64
-	oneDays     []float64
-	last5m      time.Time
-	last15m     time.Time
-	last1h      time.Time
-	last4h      time.Time
-	last1d      time.Time
-}
-
-var oiSeriesCache = struct {
-	mu   sync.Mutex
-	data map[string]*oiSeries
-}{data: make(map[string]*oiSeries)}
-
-func updateOISeriesCache(symbol string, oi float64) *oiSeries {
-	now := time.Now()
-	oiSeriesCache.mu.Lock()
-	defer oiSeriesCache.mu.Unlock()
-
-	s, ok := oiSeriesCache.data[symbol]
-	if !ok {
-		s = &oiSeries{}
-		// 强制初始添加，避免空 slice
-		s.fiveMins = append(s.fiveMins, oi)
-		s.fifteenMins = append(s.fifteenMins, oi)
-		s.oneHours = append(s.oneHours, oi)
-		s.fourHours = append(s.fourHours, oi)
-		s.oneDays = append(s.oneDays, oi)
-		s.last5m = now
-		s.last15m = now
-		s.last1h = now
-		s.last4h = now
-		s.last1d = now
-		oiSeriesCache.data[symbol] = s
-		return s
-	}
-
-	// 5m 序列
-	if now.Sub(s.last5m) >= 5*time.Minute {
-		s.fiveMins = append(s.fiveMins, oi)
-		s.last5m = now
-	}
-	// 15m 序列
-	if now.Sub(s.last15m) >= 15*time.Minute {
-		s.fifteenMins = append(s.fifteenMins, oi)
-		s.last15m = now
-	}
-	// 1h 序列
-	if now.Sub(s.last1h) >= time.Hour {
-		s.oneHours = append(s.oneHours, oi)
-		s.last1h = now
-	}
-	// 4h 序列
-	if now.Sub(s.last4h) >= 4*time.Hour {
-		s.fourHours = append(s.fourHours, oi)
-		s.last4h = now
-	}
-	// 1d 序列
-	if now.Sub(s.last1d) >= 24*time.Hour {
-		s.oneDays = append(s.oneDays, oi)
-		s.last1d = now
-	}
-
-	// 截断长度避免无限增长（保留最近300个点即可）
-	trim := func(arr []float64) []float64 {
-		if len(arr) > 300 {
-			return arr[len(arr)-300:]
-		}
-		return arr
-	}
-	s.fiveMins = trim(s.fiveMins)
-	s.fifteenMins = trim(s.fifteenMins)
-	s.oneHours = trim(s.oneHours)
-	s.fourHours = trim(s.fourHours)
-	s.oneDays = trim(s.oneDays)
-
-	return s
-}
-
-// getFundingRate 获取资金费率
-func getFundingRate(symbol string) (float64, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	var result struct {
-		Symbol          string `json:"symbol"`
-		MarkPrice       string `json:"markPrice"`
-		IndexPrice      string `json:"indexPrice"`
-		LastFundingRate string `json:"lastFundingRate"`
-		NextFundingTime int64  `json:"nextFundingTime"`
-		InterestRate    string `json:"interestRate"`
-		Time            int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
-	}
-
-	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
-	// 旧代码中的 err 检查已无意义（变量被覆盖），改为显式错误处理
-	// 如果需要严格处理，可改为：rateParsed, parseErr := strconv.ParseFloat(...)
-	// 这里保留简单解析，失败返回 0
-	return rate, nil
-}
-
-// Format 格式化输出市场数据
-func Format(data *Data) string {
-	var sb strings.Builder
-
-	// 基础价格信息（包含新增的时间框架价格变化）
-	sb.WriteString(fmt.Sprintf("当前价格 = %.2f, 20期EMA = %.3f, MACD = %.3f, 7期RSI = %.3f\n\n",
-		data.CurrentPrice, data.CurrentEMA20, data.CurrentMACD, data.CurrentRSI7))
-	sb.WriteString(fmt.Sprintf("价格变化: 3分钟=%.2f%%, 15分钟=%.2f%%, 1小时=%.2f%%, 4小时=%.2f%%, 1天=%.2f%%\n",
-		data.PriceChange3m, data.PriceChange15m, data.PriceChange1h, data.PriceChange4h, data.PriceChange1d))
-	sb.WriteString(fmt.Sprintf("协同效率: 3m=%.3f(%s), 15m=%.3f(%s), 1h=%.3f(%s)\n\n",
-		data.EffortResult3m, data.EffortLabel3m,
-		data.EffortResult15m, data.EffortLabel15m,
-		data.EffortResult1h, data.EffortLabel1h))
-
-	// 持仓量和资金费率
-	sb.WriteString(fmt.Sprintf("合约市场数据（%s）:\n\n", data.Symbol))
-	if data.OpenInterest != nil {
-		sb.WriteString(fmt.Sprintf("持仓量: 最新=%.2f, 平均=%.2f\n",
-			data.OpenInterest.Latest, data.OpenInterest.Average))
-		// 新增：OI变化率与趋势
-		sb.WriteString(fmt.Sprintf("OI变化率: 5m=%.3f%%, 15m=%.3f%%, 1h=%.3f%%, 4h=%.3f%%, 1d=%.3f%%\n",
-			data.OpenInterest.Change5m*100,
-			data.OpenInterest.Change15m*100,
-			data.OpenInterest.Change1h*100,
-			data.OpenInterest.Change4h*100,
-			data.OpenInterest.Change1d*100))
-		sb.WriteString(fmt.Sprintf("OI趋势评分: %.3f\n\n", data.OpenInterest.TrendScore))
-	}
-	sb.WriteString(fmt.Sprintf("资金费率: %.2e\n\n", data.FundingRate))
-
-	// 3分钟数据展示（原有）
-	if data.IntradaySeries != nil {
-		sb.WriteString("日内数据（3分钟周期，从旧到新）:\n\n")
-		sb.WriteString(fmt.Sprintf("10期ATR: %.3f \n\n", data.IntradaySeries.ATR10))
-		if len(data.IntradaySeries.VolumeValues) > 0 {
-			sb.WriteString(fmt.Sprintf("成交量序列: %s\n", formatFloatSlice(data.IntradaySeries.VolumeValues)))
-			sb.WriteString(fmt.Sprintf("平均成交量: %.2f, 量能放大倍数: %.2f\n\n", data.IntradaySeries.VolumeAverage, data.IntradaySeries.VolumeSpikeRatio))
-		}
-		if len(data.IntradaySeries.MidPrices) > 0 {
-			sb.WriteString(fmt.Sprintf("中间价: %s\n\n", formatFloatSlice(data.IntradaySeries.MidPrices)))
-		}
-		if len(data.IntradaySeries.EMA20Values) > 0 {
-			sb.WriteString(fmt.Sprintf("20期EMA指标: %s\n\n", formatFloatSlice(data.IntradaySeries.EMA20Values)))
-		}
-		if len(data.IntradaySeries.MACDValues10208) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD(10,20,8)指标: %s\n\n", formatFloatSlice(data.IntradaySeries.MACDValues10208)))
-		}
-		if len(data.IntradaySeries.RSI10Values) > 0 {
-			sb.WriteString(fmt.Sprintf("10期RSI指标: %s\n\n", formatFloatSlice(data.IntradaySeries.RSI10Values)))
-		}
-		if len(data.IntradaySeries.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("14期RSI指标: %s\n\n", formatFloatSlice(data.IntradaySeries.RSI14Values)))
-		}
-	}
-
-	// 新增：15分钟数据展示
-	if data.Intraday15m != nil {
-		sb.WriteString("日内数据（15分钟周期，从旧到新）:\n\n")
-		sb.WriteString(fmt.Sprintf("12期ATR: %.3f \n\n", data.Intraday15m.ATR12))
-		if len(data.Intraday15m.MidPrices) > 0 {
-			sb.WriteString(fmt.Sprintf("中间价: %s\n\n", formatFloatSlice(data.Intraday15m.MidPrices)))
-		}
-		if len(data.Intraday15m.EMA20Values) > 0 {
-			sb.WriteString(fmt.Sprintf("20期EMA指标: %s\n\n", formatFloatSlice(data.Intraday15m.EMA20Values)))
-		}
-		if len(data.Intraday15m.MACDValues12269) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD(12,26,9)指标: %s\n\n", formatFloatSlice(data.Intraday15m.MACDValues12269)))
-		}
-		if len(data.Intraday15m.RSI7Values) > 0 {
-			sb.WriteString(fmt.Sprintf("7期RSI指标: %s\n\n", formatFloatSlice(data.Intraday15m.RSI7Values)))
-		}
-		if len(data.Intraday15m.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("14期RSI指标: %s\n\n", formatFloatSlice(data.Intraday15m.RSI14Values)))
-		}
-	}
+	change5m := calcChange(series5m)
+	change15m := calcChange(series15m)
+	change1h := calcChange(series1h)
+	change4h := calcChange(series4h)
+	change1d := calcChange(series1d)
 
-	// 新增：1小时数据展示
-	if data.Intraday1h != nil {
-		sb.WriteString("日内数据（1小时周期，从旧到新）:\n\n")
-		sb.WriteString(fmt.Sprintf("6期ATR: %.3f vs 14期ATR: %.3f\n\n", data.Intraday1h.ATR6, data.Intraday1h.ATR14))
-
-		if len(data.Intraday1h.MidPrices) > 0 {
-			sb.WriteString(fmt.Sprintf("中间价: %s\n\n", formatFloatSlice(data.Intraday1h.MidPrices)))
-		}
-		if len(data.Intraday1h.EMA20Values) > 0 {
-			sb.WriteString(fmt.Sprintf("20期EMA指标: %s\n\n", formatFloatSlice(data.Intraday1h.EMA20Values)))
-		}
-		if len(data.Intraday1h.MACDValues12269) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD(12,26,9)指标: %s\n\n", formatFloatSlice(data.Intraday1h.MACDValues12269)))
-		}
-		if len(data.Intraday1h.RSI9Values) > 0 {
-			sb.WriteString(fmt.Sprintf("9期RSI指标: %s\n\n", formatFloatSlice(data.Intraday1h.RSI9Values)))
-		}
-		if len(data.Intraday1h.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("14期RSI指标: %s\n\n", formatFloatSlice(data.Intraday1h.RSI14Values)))
-		}
-	}
+	trendPolicy := currentOITrendPolicy()
+	trendScore := oiTrendScore(trendPolicy,
+		map[string]float64{"5m": change5m, "15m": change15m, "1h": change1h, "4h": change4h, "1d": change1d},
+		map[string][]float64{"5m": series5m, "15m": series15m, "1h": series1h, "4h": series4h, "1d": series1d})
 
-	// 4小时数据展示（原有）
-	if data.LongerTermContext != nil {
-		sb.WriteString("长期数据（4小时周期）:\n\n")
-		sb.WriteString(fmt.Sprintf("20期EMA: %.3f vs 50期EMA: %.3f\n\n",
-			data.LongerTermContext.EMA20, data.LongerTermContext.EMA50))
-		sb.WriteString(fmt.Sprintf("3期ATR: %.3f vs 14期ATR: %.3f\n\n",
-			data.LongerTermContext.ATR3, data.LongerTermContext.ATR14))
-		sb.WriteString(fmt.Sprintf("当前成交量: %.3f vs 平均成交量: %.3f\n\n",
-			data.LongerTermContext.CurrentVolume, data.LongerTermContext.AverageVolume))
-		if len(data.LongerTermContext.MACDValues142810) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD(14,28,10)指标: %s\n\n", formatFloatSlice(data.LongerTermContext.MACDValues142810)))
+	// 平均值：最近1小时（12个5分钟点）的均值
+	average := oi
+	if n := len(series5m); n > 0 {
+		window := series5m
+		if n > 12 {
+			window = series5m[n-12:]
 		}
-		if len(data.LongerTermContext.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("14期RSI指标: %s\n\n", formatFloatSlice(data.LongerTermContext.RSI14Values)))
-		}
-		if len(data.LongerTermContext.RSI21Values) > 0 {
-			sb.WriteString(fmt.Sprintf("21期RSI指标: %s\n\n", formatFloatSlice(data.LongerTermContext.RSI21Values)))
+		sum := 0.0
+		for _, v := range window {
+			sum += v
 		}
+		average = sum / float64(len(window))
 	}
 
-	// 新增：1天数据展示
-	if data.LongerTerm1d != nil {
-		sb.WriteString("长期数据（1天周期）:\n\n")
-		sb.WriteString(fmt.Sprintf("20期EMA: %.3f vs 50期EMA: %.3f\n\n",
-			data.LongerTerm1d.EMA20, data.LongerTerm1d.EMA50))
-		sb.WriteString(fmt.Sprintf("3期ATR: %.3f vs 14期ATR: %.3f\n\n",
-			data.LongerTerm1d.ATR3, data.LongerTerm1d.ATR14))
-		sb.WriteString(fmt.Sprintf("当前成交量: %.3f vs 平均成交量: %.3f\n\n",
-			data.LongerTerm1d.CurrentVolume, data.LongerTerm1d.AverageVolume))
-		if len(data.LongerTerm1d.MACDValues12269) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD(12,26,9)指标: %s\n\n", formatFloatSlice(data.LongerTerm1d.MACDValues12269)))
-		}
-		if len(data.LongerTerm1d.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("14期RSI指标: %s\n\n", formatFloatSlice(data.LongerTerm1d.RSI14Values)))
-		}
+	return &OIData{
+		Latest:      oi,
+		Average:     average,
+		Series5m:    series5m,
+		Series15m:   series15m,
+		Series1h:    series1h,
+		Series4h:    series4h,
+		Series1d:    series1d,
+		Change5m:    change5m,
+		Change15m:   change15m,
+		Change1h:    change1h,
+		Change4h:    change4h,
+		Change1d:    change1d,
+		TrendScore:  trendScore,
+		TrendMethod: trendPolicy.Method,
 	}
-
-	return sb.String()
 }
 
 // formatFloatSlice 格式化float64切片为字符串
@@ -840,10 +812,10 @@ func formatFloatSlice(values []float64) string {
 	return "[" + strings.Join(strValues, ", ") + "]"
 }
 
-// Normalize 标准化symbol,确保是USDT交易对
+// Normalize 标准化symbol,确保是USDT交易对（币本位合约代码如 BTCUSD_PERP 保持不变）
 func Normalize(symbol string) string {
 	symbol = strings.ToUpper(symbol)
-	if strings.HasSuffix(symbol, "USDT") {
+	if strings.HasSuffix(symbol, "USDT") || isCoinMSymbol(symbol) {
 		return symbol
 	}
 	return symbol + "USDT"
@@ -0,0 +1,116 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// depthSnapshotLimit 深度快照档位数（币安支持 5/10/20/50/100/500/1000）
+	depthSnapshotLimit = 20
+	// depthTopLevels 计算流动性与失衡比的档位数
+	depthTopLevels = 10
+)
+
+// DepthData 订单簿深度数据
+type DepthData struct {
+	BestBid   float64 `json:"best_bid"`
+	BestAsk   float64 `json:"best_ask"`
+	Spread    float64 `json:"spread"`     // 卖一 - 买一
+	SpreadBps float64 `json:"spread_bps"` // 价差（基点，相对中间价）
+
+	Levels       int     `json:"levels"`        // 参与统计的档位数
+	BidLiquidity float64 `json:"bid_liquidity"` // 前N档买盘名义价值（USDT）
+	AskLiquidity float64 `json:"ask_liquidity"` // 前N档卖盘名义价值（USDT）
+	// Imbalance 失衡比 = (买盘 - 卖盘) / (买盘 + 卖盘)，范围 [-1, 1]，正值代表买盘更厚
+	Imbalance float64 `json:"imbalance"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// getDepthData 获取深度快照并计算价差、流动性与失衡比
+func getDepthData(symbol string) (*DepthData, error) {
+	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/depth?symbol=%s&limit=%d", symbol, depthSnapshotLimit)
+	body, err := binanceGet(url, depthWeight(depthSnapshotLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		LastUpdateID int64       `json:"lastUpdateId"`
+		E            int64       `json:"E"`
+		Bids         [][2]string `json:"bids"`
+		Asks         [][2]string `json:"asks"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	bids, err := parseDepthLevels(result.Bids)
+	if err != nil {
+		return nil, fmt.Errorf("解析买盘失败: %w", err)
+	}
+	asks, err := parseDepthLevels(result.Asks)
+	if err != nil {
+		return nil, fmt.Errorf("解析卖盘失败: %w", err)
+	}
+
+	updatedAt := clockNow()
+	if result.E > 0 {
+		updatedAt = time.UnixMilli(result.E)
+	}
+	return computeDepthData(bids, asks, depthTopLevels, updatedAt), nil
+}
+
+// parseDepthLevels 解析 [价格, 数量] 档位
+func parseDepthLevels(raw [][2]string) ([][2]float64, error) {
+	levels := make([][2]float64, 0, len(raw))
+	for _, l := range raw {
+		price, err := strconv.ParseFloat(l[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		qty, err := strconv.ParseFloat(l[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, [2]float64{price, qty})
+	}
+	return levels, nil
+}
+
+// computeDepthData 根据已排序的买卖盘（买盘价格降序、卖盘价格升序）计算深度指标
+func computeDepthData(bids, asks [][2]float64, topN int, updatedAt time.Time) *DepthData {
+	d := &DepthData{UpdatedAt: updatedAt}
+	if len(bids) == 0 || len(asks) == 0 {
+		return d
+	}
+	d.BestBid, d.BestAsk = bids[0][0], asks[0][0]
+	d.Spread = d.BestAsk - d.BestBid
+	if mid := (d.BestAsk + d.BestBid) / 2; mid > 0 {
+		d.SpreadBps = d.Spread / mid * 10000
+	}
+
+	d.Levels = min(topN, len(bids), len(asks))
+	for i := 0; i < d.Levels; i++ {
+		d.BidLiquidity += bids[i][0] * bids[i][1]
+		d.AskLiquidity += asks[i][0] * asks[i][1]
+	}
+	if total := d.BidLiquidity + d.AskLiquidity; total > 0 {
+		d.Imbalance = (d.BidLiquidity - d.AskLiquidity) / total
+	}
+	return d
+}
+
+// writeDepth 输出订单簿深度信息
+func writeDepth(sb *strings.Builder, l reportLocale, d *DepthData) {
+	if d == nil || d.Levels == 0 {
+		return
+	}
+	sb.WriteString(l.f("depth_book", d.BestBid, d.BestAsk, d.SpreadBps))
+	sb.WriteString(l.f("depth_liquidity",
+		d.Levels, d.BidLiquidity, d.AskLiquidity, d.Imbalance))
+}
@@ -0,0 +1,273 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 协同效率（effort/result）的公式权重与分级阈值校准。
+// 固定阈值（0.3/0.7/0.9）对所有交易对一视同仁，波动大的币种几乎总是"极高效率"；
+// percentile 校准按交易对与周期维护最近的 |效率比| 样本，用其分位数作为分级阈值，
+// 样本不足 MinSamples 时仍使用固定阈值。样本可持久化到文件，重启后无需重新积累。
+
+// 阈值校准方式
+const (
+	EffortCalibrationFixed      = "fixed"
+	EffortCalibrationPercentile = "percentile"
+)
+
+// effortFixedThresholds 固定阈值（正常/高效率/极高效率，负方向取相反数的前两档）
+var effortFixedThresholds = effortThresholds{Normal: 0.3, High: 0.7, VeryHigh: 0.9}
+
+// EffortConfig 协同效率配置
+type EffortConfig struct {
+	// VolumeWeight 量能放大倍数的指数，OIWeight |OI变化率| 的系数：
+	// 效率比 = 价格变化% / (量能放大倍数^VolumeWeight × (1 + OIWeight × |OI变化率|))，默认均为 1
+	VolumeWeight float64
+	OIWeight     float64
+	// Calibration 阈值校准方式：fixed（默认）或 percentile
+	Calibration string
+	// Window 每个交易对/周期保留的样本数（默认 500），MinSamples 启用分位数阈值所需的最少样本（默认 50）
+	Window     int
+	MinSamples int
+	// Percentiles 正常/高效率/极高效率三档对应的 |效率比| 分位数，默认 0.5/0.8/0.9
+	Percentiles [3]float64
+}
+
+// DefaultEffortConfig 默认配置（与原固定公式、固定阈值一致）
+func DefaultEffortConfig() EffortConfig {
+	return EffortConfig{
+		VolumeWeight: 1,
+		OIWeight:     1,
+		Calibration:  EffortCalibrationFixed,
+		Window:       500,
+		MinSamples:   50,
+		Percentiles:  [3]float64{0.5, 0.8, 0.9},
+	}
+}
+
+// Validate 校验配置
+func (c EffortConfig) Validate() error {
+	if c.VolumeWeight < 0 || c.OIWeight < 0 {
+		return fmt.Errorf("协同效率权重不能为负: volume=%v oi=%v", c.VolumeWeight, c.OIWeight)
+	}
+	switch c.Calibration {
+	case EffortCalibrationFixed, EffortCalibrationPercentile:
+	default:
+		return fmt.Errorf("未知的协同效率校准方式: %s", c.Calibration)
+	}
+	if c.Window <= 0 || c.MinSamples <= 0 || c.MinSamples > c.Window {
+		return fmt.Errorf("协同效率样本数无效: window=%d min_samples=%d", c.Window, c.MinSamples)
+	}
+	p := c.Percentiles
+	if !(0 < p[0] && p[0] < p[1] && p[1] < p[2] && p[2] < 1) {
+		return fmt.Errorf("协同效率分位数需满足 0 < 正常 < 高 < 极高 < 1: %v", p)
+	}
+	return nil
+}
+
+// ParseEffortConfig 解析 "calibration=percentile,window=500,min_samples=50,volume_weight=1,oi_weight=1,percentiles=0.5/0.8/0.9"，
+// 未出现的项使用默认值
+func ParseEffortConfig(spec string) (EffortConfig, error) {
+	cfg := DefaultEffortConfig()
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return cfg, fmt.Errorf("无效的协同效率配置 %q，应为 键=值", item)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "calibration":
+			cfg.Calibration = strings.ToLower(value)
+		case "window":
+			cfg.Window, err = strconv.Atoi(value)
+		case "min_samples":
+			cfg.MinSamples, err = strconv.Atoi(value)
+		case "volume_weight":
+			cfg.VolumeWeight, err = strconv.ParseFloat(value, 64)
+		case "oi_weight":
+			cfg.OIWeight, err = strconv.ParseFloat(value, 64)
+		case "percentiles":
+			parts := strings.Split(value, "/")
+			if len(parts) != 3 {
+				return cfg, fmt.Errorf("percentiles 需要 3 个值，如 0.5/0.8/0.9: %q", value)
+			}
+			for i, s := range parts {
+				if cfg.Percentiles[i], err = strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil {
+					break
+				}
+			}
+		default:
+			return cfg, fmt.Errorf("未知的协同效率配置项: %s", key)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("无效的协同效率配置 %q: %w", item, err)
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// effortThresholds 分级阈值
+type effortThresholds struct {
+	Normal, High, VeryHigh float64
+}
+
+// effortWindow 一个交易对/周期的样本窗口
+type effortWindow struct {
+	Values  []float64 `json:"values"`   // |效率比|，从旧到新
+	LastBar int64     `json:"last_bar"` // 最近一次采样的K线开盘时间（同一根K线只采样一次）
+}
+
+// effortCalibrator 按交易对与周期维护样本并计算阈值
+type effortCalibrator struct {
+	mu      sync.Mutex
+	cfg     EffortConfig
+	windows map[string]*effortWindow // "SYMBOL|interval"
+	path    string                   // 持久化文件，空表示不持久化
+	dirty   bool
+}
+
+var effort = &effortCalibrator{cfg: DefaultEffortConfig(), windows: make(map[string]*effortWindow)}
+
+// SetEffortConfig 设置协同效率配置（已有样本按新的窗口长度截断）
+func SetEffortConfig(cfg EffortConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	effort.mu.Lock()
+	defer effort.mu.Unlock()
+	effort.cfg = cfg
+	for _, w := range effort.windows {
+		if n := len(w.Values); n > cfg.Window {
+			w.Values = append([]float64(nil), w.Values[n-cfg.Window:]...)
+		}
+	}
+	return nil
+}
+
+// currentEffortConfig 返回当前配置
+func currentEffortConfig() EffortConfig {
+	effort.mu.Lock()
+	defer effort.mu.Unlock()
+	return effort.cfg
+}
+
+// LoadEffortCalibration 从文件加载校准样本，并在之后的 SaveEffortCalibration 中写回该文件（文件不存在时从零开始）
+func LoadEffortCalibration(path string) error {
+	effort.mu.Lock()
+	defer effort.mu.Unlock()
+	effort.path = path
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取协同效率校准文件失败: %w", err)
+	}
+	var windows map[string]*effortWindow
+	if err := json.Unmarshal(body, &windows); err != nil {
+		return fmt.Errorf("解析协同效率校准文件失败: %w", err)
+	}
+	for key, w := range windows {
+		if w == nil {
+			continue
+		}
+		if n := len(w.Values); n > effort.cfg.Window {
+			w.Values = w.Values[n-effort.cfg.Window:]
+		}
+		effort.windows[key] = w
+	}
+	return nil
+}
+
+// SaveEffortCalibration 将校准样本写回 LoadEffortCalibration 指定的文件（未指定或无变化时不写）
+func SaveEffortCalibration() error {
+	effort.mu.Lock()
+	if effort.path == "" || !effort.dirty {
+		effort.mu.Unlock()
+		return nil
+	}
+	body, err := json.Marshal(effort.windows)
+	path := effort.path
+	effort.dirty = false
+	effort.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再改名，避免进程中断留下不完整的文件
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return fmt.Errorf("写入协同效率校准文件失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// classify 记录样本（每根K线一次）并按当前阈值分级
+func (c *effortCalibrator) classify(symbol, interval string, bar int64, r float64) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg.Calibration != EffortCalibrationPercentile {
+		return classifyEffortResultWith(r, effortFixedThresholds)
+	}
+
+	key := symbol + "|" + interval
+	w, ok := c.windows[key]
+	if !ok {
+		w = &effortWindow{}
+		c.windows[key] = w
+	}
+	if bar > w.LastBar && !math.IsNaN(r) && !math.IsInf(r, 0) {
+		w.Values = append(w.Values, math.Abs(r))
+		if n := len(w.Values); n > c.cfg.Window {
+			w.Values = w.Values[n-c.cfg.Window:]
+		}
+		w.LastBar = bar
+		c.dirty = true
+	}
+	if len(w.Values) < c.cfg.MinSamples {
+		return classifyEffortResultWith(r, effortFixedThresholds)
+	}
+	sorted := append([]float64(nil), w.Values...)
+	sort.Float64s(sorted)
+	p := c.cfg.Percentiles
+	return classifyEffortResultWith(r, effortThresholds{
+		Normal:   percentileSorted(sorted, p[0]),
+		High:     percentileSorted(sorted, p[1]),
+		VeryHigh: percentileSorted(sorted, p[2]),
+	})
+}
+
+// percentileSorted 已排序样本的分位数（线性插值）
+func percentileSorted(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	if lo == hi {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// classifyEffortWithKlines 按周期最新K线分级（K线为空时不采样）
+func classifyEffortWithKlines(symbol, interval string, klines []Kline, r float64) string {
+	var bar int64
+	if len(klines) > 0 {
+		bar = klines[len(klines)-1].OpenTime
+	}
+	return effort.classify(symbol, interval, bar, r)
+}
@@ -0,0 +1,18 @@
+package market
+
+import (
+	"nofx/market/events"
+	"strings"
+)
+
+// writeEventRisk 输出事件风险警告
+func writeEventRisk(sb *strings.Builder, l reportLocale, r *events.Risk) {
+	if r == nil || !r.Active {
+		return
+	}
+	parts := make([]string, 0, len(r.Events))
+	for _, e := range r.Events {
+		parts = append(parts, l.f("event_risk_item", e.Title, e.Time.UTC().Format("01-02 15:04")))
+	}
+	sb.WriteString(l.f("event_risk", l.text("impact:"+r.Level), strings.Join(parts, ", ")))
+}
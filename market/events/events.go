@@ -0,0 +1,289 @@
+// Package events 维护宏观经济事件（CPI、FOMC 等）与交易所维护窗口日历，
+// 供行情数据标记"事件风险"，提示 AI 避免在高影响事件发布前开仓
+package events
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件类别
+const (
+	CategoryMacro       = "macro"       // 宏观数据/央行决议
+	CategoryMaintenance = "maintenance" // 交易所维护
+	CategoryOther       = "other"
+)
+
+// 影响级别
+const (
+	ImpactLow    = "low"
+	ImpactMedium = "medium"
+	ImpactHigh   = "high"
+)
+
+// impactRank 影响级别排序
+var impactRank = map[string]int{ImpactLow: 1, ImpactMedium: 2, ImpactHigh: 3}
+
+// Event 一个计划中的事件
+type Event struct {
+	ID       string    `json:"id,omitempty"`
+	Title    string    `json:"title"`
+	Category string    `json:"category"`
+	Impact   string    `json:"impact"`
+	Time     time.Time `json:"time"`              // 发布/开始时间
+	End      time.Time `json:"end,omitempty"`     // 结束时间（维护窗口），为空时视为瞬时事件
+	Symbols  []string  `json:"symbols,omitempty"` // 受影响交易对，为空表示全市场
+	Source   string    `json:"source,omitempty"`
+}
+
+// end 事件结束时间
+func (e Event) end() time.Time {
+	if e.End.After(e.Time) {
+		return e.End
+	}
+	return e.Time
+}
+
+// affects 事件是否影响该交易对
+func (e Event) affects(symbol string) bool {
+	if len(e.Symbols) == 0 || symbol == "" {
+		return true
+	}
+	for _, s := range e.Symbols {
+		if strings.EqualFold(s, symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// key 去重键（多个来源提供同一事件时只保留一个）
+func (e Event) key() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return e.Title + "|" + e.Time.UTC().Format(time.RFC3339)
+}
+
+// Risk 交易对当前的事件风险
+type Risk struct {
+	Active bool    `json:"active"`
+	Level  string  `json:"level,omitempty"` // 风险窗口内事件的最高影响级别
+	Events []Event `json:"events,omitempty"`
+}
+
+// Config 日历配置
+type Config struct {
+	Sources    []Source      // 事件来源
+	Refresh    time.Duration // 刷新间隔，默认 1h
+	PreWindow  time.Duration // 高影响事件发布前的风险窗口，默认 30m
+	PostWindow time.Duration // 事件结束后的风险窗口，默认 15m
+	MinImpact  string        // 计入风险的最低影响级别，默认 high
+}
+
+func (c Config) withDefaults() Config {
+	if c.Refresh <= 0 {
+		c.Refresh = time.Hour
+	}
+	if c.PreWindow <= 0 {
+		c.PreWindow = 30 * time.Minute
+	}
+	if c.PostWindow <= 0 {
+		c.PostWindow = 15 * time.Minute
+	}
+	if impactRank[c.MinImpact] == 0 {
+		c.MinImpact = ImpactHigh
+	}
+	return c
+}
+
+// Calendar 事件日历：定期从各来源拉取并合并事件
+type Calendar struct {
+	cfg      Config
+	mu       sync.RWMutex
+	events   []Event
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCalendar 创建事件日历（不会自动刷新，需调用 Start 或 Refresh）
+func NewCalendar(cfg Config) *Calendar {
+	return &Calendar{cfg: cfg.withDefaults(), stop: make(chan struct{})}
+}
+
+// Start 后台定期刷新（首次立即刷新）
+func (c *Calendar) Start() {
+	go func() {
+		ticker := time.NewTicker(c.cfg.Refresh)
+		defer ticker.Stop()
+		for {
+			if err := c.Refresh(); err != nil {
+				log.Printf("⚠️  %v", err)
+			}
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止定期刷新
+func (c *Calendar) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// Refresh 从所有来源拉取事件；部分来源失败时保留成功来源的结果并返回错误
+func (c *Calendar) Refresh() error {
+	var all []Event
+	var errs []string
+	for _, src := range c.cfg.Sources {
+		events, err := src.Fetch()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", src.Name(), err))
+			continue
+		}
+		for _, e := range events {
+			if e.Source == "" {
+				e.Source = src.Name()
+			}
+			all = append(all, e)
+		}
+	}
+	if len(errs) > 0 && len(all) == 0 {
+		return fmt.Errorf("刷新事件日历失败: %s", strings.Join(errs, "; "))
+	}
+	c.Set(all)
+	log.Printf("📅 事件日历已刷新: %d 个事件", len(all))
+	if len(errs) > 0 {
+		return fmt.Errorf("部分事件来源失败: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Set 直接替换日历中的事件（统一格式、去重并按时间排序）
+func (c *Calendar) Set(events []Event) {
+	seen := make(map[string]bool, len(events))
+	merged := make([]Event, 0, len(events))
+	for _, e := range events {
+		e = normalizeEvent(e)
+		if e.Time.IsZero() || seen[e.key()] {
+			continue
+		}
+		seen[e.key()] = true
+		merged = append(merged, e)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Time.Before(merged[j].Time) })
+	c.mu.Lock()
+	c.events = merged
+	c.mu.Unlock()
+}
+
+// UpcomingEvents 返回 now 起 window 内开始、或当前正在进行的事件（按开始时间排序）
+func (c *Calendar) UpcomingEvents(now time.Time, window time.Duration) []Event {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	horizon := now.Add(window)
+	var out []Event
+	for _, e := range c.events {
+		if e.Time.After(horizon) {
+			break
+		}
+		if !e.end().Before(now) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// RiskFor 返回交易对在 now 时刻的事件风险：
+// 处于 [事件开始-PreWindow, 事件结束+PostWindow] 且影响级别不低于 MinImpact 的事件视为风险
+// 交易所维护窗口无论配置的最低级别均计入
+func (c *Calendar) RiskFor(symbol string, now time.Time) *Risk {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	risk := &Risk{}
+	for _, e := range c.events {
+		if e.Time.Add(-c.cfg.PreWindow).After(now) {
+			break
+		}
+		if e.end().Add(c.cfg.PostWindow).Before(now) || !e.affects(symbol) {
+			continue
+		}
+		if e.Category != CategoryMaintenance && impactRank[e.Impact] < impactRank[c.cfg.MinImpact] {
+			continue
+		}
+		risk.Active = true
+		risk.Events = append(risk.Events, e)
+		level := e.Impact
+		if e.Category == CategoryMaintenance {
+			level = ImpactHigh
+		}
+		if impactRank[level] > impactRank[risk.Level] {
+			risk.Level = level
+		}
+	}
+	if !risk.Active {
+		return nil
+	}
+	return risk
+}
+
+// normalizeEvent 统一类别、影响级别与交易对大小写
+func normalizeEvent(e Event) Event {
+	e.Category = strings.ToLower(strings.TrimSpace(e.Category))
+	if e.Category == "" {
+		e.Category = CategoryOther
+	}
+	e.Impact = strings.ToLower(strings.TrimSpace(e.Impact))
+	if impactRank[e.Impact] == 0 {
+		e.Impact = ImpactMedium
+	}
+	e.Symbols = append([]string(nil), e.Symbols...)
+	for i, s := range e.Symbols {
+		e.Symbols[i] = strings.ToUpper(strings.TrimSpace(s))
+	}
+	return e
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultCalendar *Calendar
+)
+
+// SetDefault 设置包级函数使用的默认日历（nil 表示关闭事件风险）
+func SetDefault(c *Calendar) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultCalendar = c
+}
+
+// Default 返回默认日历（可能为 nil）
+func Default() *Calendar {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultCalendar
+}
+
+// UpcomingEvents 使用默认日历返回未来 window 内的事件
+func UpcomingEvents(window time.Duration) []Event {
+	c := Default()
+	if c == nil {
+		return nil
+	}
+	return c.UpcomingEvents(time.Now(), window)
+}
+
+// RiskFor 使用默认日历计算交易对的事件风险（未设置日历时返回 nil）
+func RiskFor(symbol string, now time.Time) *Risk {
+	c := Default()
+	if c == nil {
+		return nil
+	}
+	return c.RiskFor(symbol, now)
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Source 事件来源
+type Source interface {
+	Name() string
+	Fetch() ([]Event, error)
+}
+
+// FileSource 从本地 JSON 文件读取事件（内容为 Event 数组）
+type FileSource struct {
+	Path string
+}
+
+// Name 来源名称
+func (s FileSource) Name() string { return "file:" + s.Path }
+
+// Fetch 读取并解析文件
+func (s FileSource) Fetch() ([]Event, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("读取事件文件失败: %w", err)
+	}
+	return parseEvents(data)
+}
+
+// URLSource 从 HTTP 接口拉取事件（响应为 Event 数组或 {"events": [...]}）
+type URLSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// Name 来源名称
+func (s URLSource) Name() string { return s.URL }
+
+// Fetch 请求并解析事件
+func (s URLSource) Fetch() ([]Event, error) {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("请求事件接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取事件接口响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("事件接口返回错误 (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return parseEvents(body)
+}
+
+// StaticSource 固定事件列表（用于手工配置或测试）
+type StaticSource []Event
+
+// Name 来源名称
+func (StaticSource) Name() string { return "static" }
+
+// Fetch 返回事件副本
+func (s StaticSource) Fetch() ([]Event, error) {
+	return append([]Event(nil), s...), nil
+}
+
+// parseEvents 兼容 Event 数组与 {"events": [...]} 两种格式
+func parseEvents(data []byte) ([]Event, error) {
+	var events []Event
+	if err := json.Unmarshal(data, &events); err == nil {
+		return events, nil
+	}
+	var wrapped struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("解析事件失败: %w", err)
+	}
+	return wrapped.Events, nil
+}
+
+// ParseSources 解析逗号分隔的来源配置：http(s):// 开头为接口地址，其余视为本地文件
+func ParseSources(spec string) []Source {
+	var sources []Source
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+		case strings.HasPrefix(part, "http://") || strings.HasPrefix(part, "https://"):
+			sources = append(sources, URLSource{URL: part})
+		default:
+			sources = append(sources, FileSource{Path: part})
+		}
+	}
+	return sources
+}
@@ -0,0 +1,295 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultExchangeInfoRefresh 交易规则默认刷新间隔
+	defaultExchangeInfoRefresh = time.Hour
+	// exchangeInfoRetryInterval 加载失败后按需重试的最小间隔
+	exchangeInfoRetryInterval = time.Minute
+)
+
+// SymbolFilter exchangeInfo 中的交易规则过滤器（仅保留价格/数量/名义价值相关字段）
+type SymbolFilter struct {
+	FilterType string `json:"filterType"`
+	TickSize   string `json:"tickSize,omitempty"` // PRICE_FILTER
+	MinPrice   string `json:"minPrice,omitempty"`
+	MaxPrice   string `json:"maxPrice,omitempty"`
+	StepSize   string `json:"stepSize,omitempty"` // LOT_SIZE / MARKET_LOT_SIZE
+	MinQty     string `json:"minQty,omitempty"`
+	MaxQty     string `json:"maxQty,omitempty"`
+	Notional   string `json:"notional,omitempty"` // MIN_NOTIONAL
+}
+
+// SymbolRules 交易对的下单规则
+type SymbolRules struct {
+	Symbol            string  `json:"symbol"`
+	PricePrecision    int     `json:"price_precision"`
+	QuantityPrecision int     `json:"quantity_precision"`
+	TickSize          float64 `json:"tick_size"`
+	StepSize          float64 `json:"step_size"`        // 限价单数量步长（LOT_SIZE）
+	MarketStepSize    float64 `json:"market_step_size"` // 市价单数量步长（MARKET_LOT_SIZE，缺失时同 StepSize）
+	MinQty            float64 `json:"min_qty"`
+	MaxQty            float64 `json:"max_qty"`
+	MarketMaxQty      float64 `json:"market_max_qty"`
+	MinNotional       float64 `json:"min_notional"`
+	tickDecimals      int
+	stepDecimals      int
+}
+
+// newSymbolRules 从 exchangeInfo 的交易对信息解析下单规则
+func newSymbolRules(info SymbolInfo) SymbolRules {
+	r := SymbolRules{
+		Symbol:            info.Symbol,
+		PricePrecision:    info.PricePrecision,
+		QuantityPrecision: info.QuantityPrecision,
+		tickDecimals:      info.PricePrecision,
+		stepDecimals:      info.QuantityPrecision,
+	}
+	for _, f := range info.Filters {
+		switch f.FilterType {
+		case "PRICE_FILTER":
+			r.TickSize, _ = strconv.ParseFloat(f.TickSize, 64)
+			if r.TickSize > 0 {
+				r.tickDecimals = decimalPlaces(f.TickSize)
+			}
+		case "LOT_SIZE":
+			r.StepSize, _ = strconv.ParseFloat(f.StepSize, 64)
+			r.MinQty, _ = strconv.ParseFloat(f.MinQty, 64)
+			r.MaxQty, _ = strconv.ParseFloat(f.MaxQty, 64)
+			if r.StepSize > 0 {
+				r.stepDecimals = decimalPlaces(f.StepSize)
+			}
+		case "MARKET_LOT_SIZE":
+			r.MarketStepSize, _ = strconv.ParseFloat(f.StepSize, 64)
+			r.MarketMaxQty, _ = strconv.ParseFloat(f.MaxQty, 64)
+		case "MIN_NOTIONAL":
+			r.MinNotional, _ = strconv.ParseFloat(f.Notional, 64)
+		}
+	}
+	if r.MarketStepSize <= 0 {
+		r.MarketStepSize = r.StepSize
+	}
+	return r
+}
+
+// decimalPlaces 步长字符串的小数位数（如 "0.0010" -> 3）
+func decimalPlaces(step string) int {
+	step = strings.TrimRight(step, "0")
+	dot := strings.IndexByte(step, '.')
+	if dot < 0 {
+		return 0
+	}
+	return len(step) - dot - 1
+}
+
+// roundToStep 按步长取整（floor 为 true 时向下取整，否则四舍五入），并按 decimals 消除浮点误差
+func roundToStep(v, step float64, decimals int, floor bool) float64 {
+	if step > 0 {
+		n := v / step
+		if floor {
+			// 容忍浮点误差：0.3/0.1 = 2.9999999999999996
+			n = math.Floor(n + 1e-9)
+		} else {
+			n = math.Round(n)
+		}
+		v = n * step
+	}
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(v*pow) / pow
+}
+
+// RoundPrice 价格按 tickSize 四舍五入
+func (r SymbolRules) RoundPrice(price float64) float64 {
+	return roundToStep(price, r.TickSize, r.tickDecimals, false)
+}
+
+// RoundQty 数量按 stepSize 向下取整（避免超出可用保证金）
+func (r SymbolRules) RoundQty(qty float64) float64 {
+	return roundToStep(qty, r.StepSize, r.stepDecimals, true)
+}
+
+// FormatPrice 按价格精度格式化
+func (r SymbolRules) FormatPrice(price float64) string {
+	return strconv.FormatFloat(r.RoundPrice(price), 'f', r.tickDecimals, 64)
+}
+
+// FormatQty 按数量精度格式化
+func (r SymbolRules) FormatQty(qty float64) string {
+	return strconv.FormatFloat(r.RoundQty(qty), 'f', r.stepDecimals, 64)
+}
+
+// Validate 检查取整后的数量与名义价值是否满足最小下单要求（price 为 0 时跳过名义价值检查）
+func (r SymbolRules) Validate(price, qty float64) error {
+	qty = r.RoundQty(qty)
+	if qty <= 0 || (r.MinQty > 0 && qty < r.MinQty) {
+		return fmt.Errorf("%s 数量 %v 低于最小下单量 %v", r.Symbol, qty, r.MinQty)
+	}
+	if r.MaxQty > 0 && qty > r.MaxQty {
+		return fmt.Errorf("%s 数量 %v 超过最大下单量 %v", r.Symbol, qty, r.MaxQty)
+	}
+	if price > 0 && r.MinNotional > 0 && price*qty < r.MinNotional {
+		return fmt.Errorf("%s 名义价值 %.4f 低于最小值 %.4f", r.Symbol, price*qty, r.MinNotional)
+	}
+	return nil
+}
+
+// ExchangeInfoCache 缓存 /fapi/v1/exchangeInfo 的交易规则并定期刷新
+type ExchangeInfoCache struct {
+	client   *APIClient
+	refresh  time.Duration
+	mu       sync.RWMutex
+	rules    map[string]SymbolRules
+	loadedAt time.Time
+	loadMu   sync.Mutex // 串行化加载，避免并发首次访问时重复请求
+	tried    time.Time  // 最近一次加载尝试时间（受 loadMu 保护）
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewExchangeInfoCache 创建交易规则缓存（refresh<=0 时使用默认 1h）
+func NewExchangeInfoCache(refresh time.Duration) *ExchangeInfoCache {
+	if refresh <= 0 {
+		refresh = defaultExchangeInfoRefresh
+	}
+	return &ExchangeInfoCache{
+		client:  NewAPIClient(),
+		refresh: refresh,
+		rules:   make(map[string]SymbolRules),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Load 立即拉取并替换交易规则
+func (c *ExchangeInfoCache) Load() error {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	return c.loadLocked()
+}
+
+// loadLocked 拉取交易规则（调用方持有 loadMu）
+func (c *ExchangeInfoCache) loadLocked() error {
+	c.tried = clockNow()
+	info, err := c.client.GetExchangeInfo()
+	if err != nil {
+		return fmt.Errorf("获取交易规则失败: %w", err)
+	}
+	rules := make(map[string]SymbolRules, len(info.Symbols))
+	for _, s := range info.Symbols {
+		rules[s.Symbol] = newSymbolRules(s)
+	}
+	c.mu.Lock()
+	c.rules = rules
+	c.loadedAt = clockNow()
+	c.mu.Unlock()
+	log.Printf("📐 已加载 %d 个交易对的下单规则", len(rules))
+	return nil
+}
+
+// Start 后台定期刷新（首次立即加载）
+func (c *ExchangeInfoCache) Start() {
+	go func() {
+		ticker := time.NewTicker(c.refresh)
+		defer ticker.Stop()
+		for {
+			if err := c.Load(); err != nil {
+				log.Printf("⚠️  %v", err)
+			}
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止定期刷新
+func (c *ExchangeInfoCache) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// Rules 返回交易对的下单规则；缓存为空或已过期时同步加载一次
+func (c *ExchangeInfoCache) Rules(symbol string) (SymbolRules, bool) {
+	symbol = Normalize(symbol)
+	c.mu.RLock()
+	r, ok := c.rules[symbol]
+	stale := c.loadedAt.IsZero() || clockNow().Sub(c.loadedAt) > c.refresh
+	c.mu.RUnlock()
+	if ok && !stale {
+		return r, true
+	}
+	c.loadMu.Lock()
+	if clockNow().Sub(c.tried) >= exchangeInfoRetryInterval {
+		if err := c.loadLocked(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+	c.loadMu.Unlock()
+	// 刷新失败或处于重试间隔内时 rules 保持不变，继续使用旧规则
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok = c.rules[symbol]
+	return r, ok
+}
+
+// RoundPrice 按交易对 tickSize 取整价格（无规则时原样返回）
+func (c *ExchangeInfoCache) RoundPrice(symbol string, price float64) float64 {
+	if r, ok := c.Rules(symbol); ok {
+		return r.RoundPrice(price)
+	}
+	return price
+}
+
+// RoundQty 按交易对 stepSize 向下取整数量（无规则时原样返回）
+func (c *ExchangeInfoCache) RoundQty(symbol string, qty float64) float64 {
+	if r, ok := c.Rules(symbol); ok {
+		return r.RoundQty(qty)
+	}
+	return qty
+}
+
+var (
+	defaultExchangeInfoMu sync.Mutex
+	defaultExchangeInfo   *ExchangeInfoCache
+)
+
+// SetDefaultExchangeInfoCache 设置包级 RoundPrice/RoundQty 使用的缓存
+func SetDefaultExchangeInfoCache(c *ExchangeInfoCache) {
+	defaultExchangeInfoMu.Lock()
+	defer defaultExchangeInfoMu.Unlock()
+	defaultExchangeInfo = c
+}
+
+// DefaultExchangeInfoCache 返回默认交易规则缓存（未设置时按需创建，首次使用时加载）
+func DefaultExchangeInfoCache() *ExchangeInfoCache {
+	defaultExchangeInfoMu.Lock()
+	defer defaultExchangeInfoMu.Unlock()
+	if defaultExchangeInfo == nil {
+		defaultExchangeInfo = NewExchangeInfoCache(0)
+	}
+	return defaultExchangeInfo
+}
+
+// GetSymbolRules 使用默认缓存获取交易对下单规则
+func GetSymbolRules(symbol string) (SymbolRules, bool) {
+	return DefaultExchangeInfoCache().Rules(symbol)
+}
+
+// RoundPrice 使用默认缓存按 tickSize 取整价格
+func RoundPrice(symbol string, price float64) float64 {
+	return DefaultExchangeInfoCache().RoundPrice(symbol, price)
+}
+
+// RoundQty 使用默认缓存按 stepSize 向下取整数量
+func RoundQty(symbol string, qty float64) float64 {
+	return DefaultExchangeInfoCache().RoundQty(symbol, qty)
+}
@@ -0,0 +1,264 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// liquidationStream 全市场强平订单流（每个交易对每秒最多推送一条最新强平）
+const liquidationStream = "!forceOrder@arr"
+
+// defaultFlowWindows 默认的强平/主动买卖统计窗口
+var defaultFlowWindows = []time.Duration{15 * time.Minute, time.Hour, 4 * time.Hour}
+
+// FlowWindow 单个统计窗口内的资金流
+type FlowWindow struct {
+	Window time.Duration `json:"window"`
+	// 主动买卖（基于3m K线的 taker buy 成交额，单位USDT）
+	TakerBuyVolume  float64 `json:"taker_buy_volume"`
+	TakerSellVolume float64 `json:"taker_sell_volume"`
+	TakerDelta      float64 `json:"taker_delta"`     // 主动买 - 主动卖
+	TakerBuyRatio   float64 `json:"taker_buy_ratio"` // 主动买 / 总成交额，0.5 为均衡
+}
+
+// TakerFlow 主动买卖量统计
+type TakerFlow struct {
+	Windows []FlowWindow `json:"windows,omitempty"`
+}
+
+// LiquidationWindow 单个统计窗口内的强平统计
+type LiquidationWindow struct {
+	Window time.Duration `json:"window"`
+	// LongLiquidated 多头被强平名义价值（强平单方向为 SELL）
+	LongLiquidated float64 `json:"long_liquidated"`
+	// ShortLiquidated 空头被强平名义价值（强平单方向为 BUY）
+	ShortLiquidated float64 `json:"short_liquidated"`
+	Count           int     `json:"count"`
+}
+
+// LiquidationData 强平数据（来自 forceOrder 流，监控器启动后开始累积）
+type LiquidationData struct {
+	Windows []LiquidationWindow `json:"windows,omitempty"`
+	// Since 开始累积的时间，早于该时间的强平不在统计内
+	Since time.Time `json:"since"`
+}
+
+// liquidationEvent 一条强平记录
+type liquidationEvent struct {
+	Time     time.Time
+	Side     string // BUY=空头被强平, SELL=多头被强平
+	Notional float64
+}
+
+// liquidationBook 各交易对的强平记录（仅保留最长统计窗口内的数据）
+type liquidationBook struct {
+	mu        sync.Mutex
+	events    map[string][]liquidationEvent
+	retention time.Duration
+	since     time.Time
+}
+
+func newLiquidationBook(retention time.Duration) *liquidationBook {
+	return &liquidationBook{
+		events:    make(map[string][]liquidationEvent),
+		retention: retention,
+	}
+}
+
+// add 记录一条强平并裁剪过期数据
+func (b *liquidationBook) add(symbol string, ev liquidationEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	events := append(b.events[symbol], ev)
+	cutoff := ev.Time.Add(-b.retention)
+	idx := 0
+	for idx < len(events) && events[idx].Time.Before(cutoff) {
+		idx++
+	}
+	b.events[symbol] = events[idx:]
+}
+
+// markStarted 记录开始接收强平流的时间
+func (b *liquidationBook) markStarted(t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.since.IsZero() {
+		b.since = t
+	}
+}
+
+// snapshot 按窗口汇总强平数据
+func (b *liquidationBook) snapshot(symbol string, windows []time.Duration, now time.Time) *LiquidationData {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.since.IsZero() {
+		return nil
+	}
+	data := &LiquidationData{Since: b.since}
+	events := b.events[symbol]
+	for _, w := range windows {
+		lw := LiquidationWindow{Window: w}
+		cutoff := now.Add(-w)
+		for _, ev := range events {
+			if ev.Time.Before(cutoff) {
+				continue
+			}
+			lw.Count++
+			if ev.Side == "SELL" {
+				lw.LongLiquidated += ev.Notional
+			} else {
+				lw.ShortLiquidated += ev.Notional
+			}
+		}
+		data.Windows = append(data.Windows, lw)
+	}
+	return data
+}
+
+// forceOrderWSData forceOrder 推送数据
+type forceOrderWSData struct {
+	EventType string `json:"e"`
+	EventTime int64  `json:"E"`
+	Order     struct {
+		Symbol       string `json:"s"`
+		Side         string `json:"S"`
+		OrderType    string `json:"o"`
+		Quantity     string `json:"q"`
+		Price        string `json:"p"`
+		AveragePrice string `json:"ap"`
+		Status       string `json:"X"`
+		FilledQty    string `json:"z"`
+		TradeTime    int64  `json:"T"`
+	} `json:"o"`
+}
+
+// flowWindows 返回配置的统计窗口
+func (m *Monitor) flowWindows() []time.Duration {
+	return m.config.FlowWindows
+}
+
+// subscribeLiquidations 订阅全市场强平流
+func (m *Monitor) subscribeLiquidations() error {
+	if _, loaded := m.subscribed.LoadOrStore(liquidationStream, struct{}{}); loaded {
+		return nil
+	}
+	ch := m.combinedClient.AddSubscriber(liquidationStream, 1000)
+	go m.handleLiquidations(ch)
+	if err := m.combinedClient.subscribeStreams([]string{liquidationStream}); err != nil {
+		return fmt.Errorf("订阅强平流失败: %w", err)
+	}
+	m.liquidations.markStarted(clockNow())
+	return nil
+}
+
+// handleLiquidations 处理强平推送
+func (m *Monitor) handleLiquidations(ch <-chan []byte) {
+	for data := range ch {
+		var ev forceOrderWSData
+		if err := json.Unmarshal(data, &ev); err != nil {
+			log.Printf("解析强平数据失败: %v", err)
+			continue
+		}
+		price, _ := parseFloat(ev.Order.AveragePrice)
+		if price <= 0 {
+			price, _ = parseFloat(ev.Order.Price)
+		}
+		qty, _ := parseFloat(ev.Order.FilledQty)
+		if qty <= 0 {
+			qty, _ = parseFloat(ev.Order.Quantity)
+		}
+		t := time.UnixMilli(ev.Order.TradeTime)
+		if ev.Order.TradeTime == 0 {
+			t = time.UnixMilli(ev.EventTime)
+		}
+		m.liquidations.add(strings.ToUpper(ev.Order.Symbol), liquidationEvent{
+			Time:     t,
+			Side:     strings.ToUpper(ev.Order.Side),
+			Notional: price * qty,
+		})
+	}
+}
+
+// LiquidationData 返回交易对的强平统计（未订阅强平流时返回 nil）
+func (m *Monitor) LiquidationData(symbol string) *LiquidationData {
+	return m.liquidations.snapshot(symbol, m.flowWindows(), clockNow())
+}
+
+// calculateTakerFlow 根据K线的主动买入成交额统计各窗口的主动买卖量
+// sources 按周期从细到粗传入（如 3m、1h、1d），每个窗口使用第一个能覆盖该窗口的周期；包含未收盘K线
+func calculateTakerFlow(windows []time.Duration, now time.Time, sources ...[]Kline) *TakerFlow {
+	flow := &TakerFlow{}
+	for _, w := range windows {
+		cutoff := now.Add(-w).UnixMilli()
+		var klines []Kline
+		for _, src := range sources {
+			if len(src) == 0 {
+				continue
+			}
+			klines = src
+			if src[0].OpenTime <= cutoff {
+				break
+			}
+		}
+		if len(klines) == 0 {
+			continue
+		}
+
+		fw := FlowWindow{Window: w}
+		for i := len(klines) - 1; i >= 0; i-- {
+			k := klines[i]
+			if k.CloseTime < cutoff {
+				break
+			}
+			fw.TakerBuyVolume += k.TakerBuyQuoteVolume
+			fw.TakerSellVolume += k.QuoteVolume - k.TakerBuyQuoteVolume
+		}
+		fw.TakerDelta = fw.TakerBuyVolume - fw.TakerSellVolume
+		if total := fw.TakerBuyVolume + fw.TakerSellVolume; total > 0 {
+			fw.TakerBuyRatio = fw.TakerBuyVolume / total
+		}
+		flow.Windows = append(flow.Windows, fw)
+	}
+	if len(flow.Windows) == 0 {
+		return nil
+	}
+	return flow
+}
+
+// writeFlow 输出主动买卖与强平统计
+func writeFlow(sb *strings.Builder, l reportLocale, taker *TakerFlow, liq *LiquidationData) {
+	if taker != nil && len(taker.Windows) > 0 {
+		parts := make([]string, 0, len(taker.Windows))
+		for _, w := range taker.Windows {
+			parts = append(parts, l.f("flow_taker_item", formatWindow(w.Window), w.TakerBuyRatio, w.TakerDelta))
+		}
+		sb.WriteString(l.f("flow_taker", strings.Join(parts, ", ")))
+	}
+	if liq != nil && len(liq.Windows) > 0 {
+		parts := make([]string, 0, len(liq.Windows))
+		for _, w := range liq.Windows {
+			parts = append(parts, l.f("flow_liquidation_item", formatWindow(w.Window), w.LongLiquidated, w.ShortLiquidated, w.Count))
+		}
+		sb.WriteString(l.f("flow_liquidation", strings.Join(parts, ", ")))
+	}
+	if taker != nil || liq != nil {
+		sb.WriteString("\n")
+	}
+}
+
+// formatWindow 将窗口格式化为 15m/1h/4h 形式
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
@@ -0,0 +1,100 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FormatJSON 将市场数据序列化为稳定的 JSON（字段名见各结构体的 json 标签，map 键按字典序输出）
+// 时长字段（如 Window、Interval）以纳秒整数输出
+func FormatJSON(data *Data) (string, error) {
+	if data == nil {
+		return "null", nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("序列化市场数据失败: %w", err)
+	}
+	return string(b), nil
+}
+
+// FormatCompact 以紧凑表格输出市场数据：首行为概览，随后每个时间框架一行
+// 列固定、以 | 分隔，便于在提示词中节省 token 或由下游按列解析
+func FormatCompact(data *Data) string {
+	if data == nil {
+		return ""
+	}
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("%s price=%.4f ema=%.4f macd=%.4f rsi=%.2f",
+		data.Symbol, data.CurrentPrice, data.CurrentEMA20, data.CurrentMACD, data.CurrentRSI7))
+	if data.Market.IsFutures() {
+		sb.WriteString(fmt.Sprintf(" funding=%.2e", data.FundingRate))
+	} else {
+		sb.WriteString(" market=" + string(data.Market))
+	}
+	if data.OpenInterest != nil {
+		sb.WriteString(fmt.Sprintf(" oi=%.2f oi_trend=%.3f(%s)", data.OpenInterest.Latest, data.OpenInterest.TrendScore, data.OpenInterest.TrendMethod))
+	}
+	if data.Basis != nil {
+		sb.WriteString(fmt.Sprintf(" mark_index=%.3f%% basis=%.3f%%", data.Basis.MarkIndexSpread, data.Basis.Basis))
+	}
+	if data.Depth != nil && data.Depth.Levels > 0 {
+		sb.WriteString(fmt.Sprintf(" spread_bps=%.2f imbalance=%.3f", data.Depth.SpreadBps, data.Depth.Imbalance))
+	}
+	if data.Sentiment != nil {
+		sb.WriteString(fmt.Sprintf(" top_ls=%.3f global_ls=%.3f", data.Sentiment.TopPositionRatio, data.Sentiment.GlobalAccountRatio))
+	}
+	if data.EventRisk != nil && data.EventRisk.Active {
+		sb.WriteString(" event_risk=" + data.EventRisk.Level)
+	}
+	if !data.Quality.OK() {
+		issues := make([]string, 0, len(data.Quality.Issues))
+		for _, issue := range data.Quality.Issues {
+			issues = append(issues, issue.Section+":"+issue.Status)
+		}
+		sb.WriteString(" quality=" + strings.Join(issues, ","))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("tf|chg%|ema20|macd|rsi14|atr14|adx|bb_pctb|vol_spike\n")
+	writeCompactIntraday(&sb, "3m", data.PriceChange3m, data.IntradaySeries)
+	writeCompactIntraday(&sb, "15m", data.PriceChange15m, data.Intraday15m)
+	writeCompactIntraday(&sb, "1h", data.PriceChange1h, data.Intraday1h)
+	writeCompactLongerTerm(&sb, "4h", data.PriceChange4h, data.LongerTermContext)
+	writeCompactLongerTerm(&sb, "1d", data.PriceChange1d, data.LongerTerm1d)
+	return sb.String()
+}
+
+// writeCompactIntraday 输出日内时间框架一行
+func writeCompactIntraday(sb *strings.Builder, tf string, change float64, d *IntradayData) {
+	if d == nil {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("%s|%.2f|%.4f|%.4f|%.2f|%.4f|%.2f|%.3f|%.2f\n",
+		tf, change, lastValue(d.EMA20Values), lastValue(d.MACDValues12269), lastValue(d.RSI14Values),
+		d.ATR14, d.ADX, d.Bollinger.PercentB, d.VolumeSpikeRatio))
+}
+
+// writeCompactLongerTerm 输出长周期时间框架一行（量能列为 当前/平均 成交量）
+func writeCompactLongerTerm(sb *strings.Builder, tf string, change float64, d *LongerTermData) {
+	if d == nil {
+		return
+	}
+	volRatio := 0.0
+	if d.AverageVolume > 0 {
+		volRatio = d.CurrentVolume / d.AverageVolume
+	}
+	sb.WriteString(fmt.Sprintf("%s|%.2f|%.4f|%.4f|%.2f|%.4f|%.2f|%.3f|%.2f\n",
+		tf, change, d.EMA20, lastValue(d.MACDValues12269), lastValue(d.RSI14Values),
+		d.ATR14, d.ADX, d.Bollinger.PercentB, volRatio))
+}
+
+// lastValue 返回序列最后一个值（空序列返回0）
+func lastValue(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1]
+}
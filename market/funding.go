@@ -0,0 +1,185 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// fundingHistoryLimit 保留的历史资金费率条数
+	fundingHistoryLimit = 10
+	// fundingHistoryTTL 历史费率缓存有效期（结算间隔通常为8小时，无需每次请求）
+	fundingHistoryTTL = 5 * time.Minute
+	// defaultFundingInterval 无法从历史推断时使用的结算间隔
+	defaultFundingInterval = 8 * time.Hour
+)
+
+// FundingData 资金费率数据
+type FundingData struct {
+	// Current 当前周期预测费率（premiumIndex.lastFundingRate，下次结算时按此收取）
+	Current         float64   `json:"current"`
+	NextFundingTime time.Time `json:"next_funding_time"`
+	MarkPrice       float64   `json:"mark_price"`
+	IndexPrice      float64   `json:"index_price"`
+
+	// History 最近已结算的资金费率（从旧到新）
+	History []float64 `json:"history,omitempty"`
+	// Average 历史费率均值
+	Average float64 `json:"average"`
+	// Interval 结算间隔（根据历史结算时间推断）
+	Interval time.Duration `json:"interval"`
+	// Annualized 当前费率年化（百分比）
+	Annualized float64 `json:"annualized"`
+	// Momentum 当前费率相对历史均值的变化（当前 - 均值）
+	Momentum float64 `json:"momentum"`
+}
+
+type fundingHistoryEntry struct {
+	rates     []float64
+	interval  time.Duration
+	fetchedAt time.Time
+}
+
+// fundingHistoryCache 各交易对历史资金费率缓存
+var fundingHistoryCache sync.Map
+
+// getFundingData 获取资金费率、历史费率与下次结算时间
+func getFundingData(symbol string) (*FundingData, error) {
+	fd, err := fetchPremiumIndex(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if h, err := getFundingHistory(symbol); err == nil {
+		fd.History = h.rates
+		fd.Interval = h.interval
+	}
+	if fd.Interval <= 0 {
+		fd.Interval = defaultFundingInterval
+	}
+	if len(fd.History) > 0 {
+		sum := 0.0
+		for _, r := range fd.History {
+			sum += r
+		}
+		fd.Average = sum / float64(len(fd.History))
+		fd.Momentum = fd.Current - fd.Average
+	}
+	fd.Annualized = fd.Current * (float64(24*time.Hour) / float64(fd.Interval)) * 365 * 100
+	return fd, nil
+}
+
+// fetchPremiumIndex 获取当前预测费率、标记价格与下次结算时间
+func fetchPremiumIndex(symbol string) (*FundingData, error) {
+	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
+
+	body, err := binanceGet(url, 1)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePremiumIndex(body)
+}
+
+// ParsePremiumIndex 解析 /fapi/v1/premiumIndex 响应（当前费率、标记价格、指数价格与下次结算时间）
+func ParsePremiumIndex(body []byte) (*FundingData, error) {
+	var result struct {
+		Symbol          string `json:"symbol"`
+		MarkPrice       string `json:"markPrice"`
+		IndexPrice      string `json:"indexPrice"`
+		LastFundingRate string `json:"lastFundingRate"`
+		NextFundingTime int64  `json:"nextFundingTime"`
+		InterestRate    string `json:"interestRate"`
+		Time            int64  `json:"time"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	// 解析失败时按 0 处理
+	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
+	mark, _ := strconv.ParseFloat(result.MarkPrice, 64)
+	index, _ := strconv.ParseFloat(result.IndexPrice, 64)
+	fd := &FundingData{Current: rate, MarkPrice: mark, IndexPrice: index}
+	if result.NextFundingTime > 0 {
+		fd.NextFundingTime = time.UnixMilli(result.NextFundingTime)
+	}
+	return fd, nil
+}
+
+// getFundingHistory 获取最近已结算的资金费率（带缓存）
+func getFundingHistory(symbol string) (*fundingHistoryEntry, error) {
+	if v, ok := fundingHistoryCache.Load(symbol); ok {
+		if e := v.(*fundingHistoryEntry); clockNow().Sub(e.fetchedAt) < fundingHistoryTTL {
+			return e, nil
+		}
+	}
+
+	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/fundingRate?symbol=%s&limit=%d", symbol, fundingHistoryLimit)
+	body, err := binanceGet(url, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Symbol      string `json:"symbol"`
+		FundingRate string `json:"fundingRate"`
+		FundingTime int64  `json:"fundingTime"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, err
+	}
+
+	e := &fundingHistoryEntry{fetchedAt: clockNow()}
+	for _, r := range rows {
+		rate, err := strconv.ParseFloat(r.FundingRate, 64)
+		if err != nil {
+			continue
+		}
+		e.rates = append(e.rates, rate)
+	}
+	// 取相邻结算时间差的最小值作为结算间隔（部分币种为4小时或1小时）
+	for i := 1; i < len(rows); i++ {
+		d := time.Duration(rows[i].FundingTime-rows[i-1].FundingTime) * time.Millisecond
+		if d > 0 && (e.interval == 0 || d < e.interval) {
+			e.interval = d.Round(time.Hour)
+		}
+	}
+	fundingHistoryCache.Store(symbol, e)
+	return e, nil
+}
+
+// writeFunding 输出资金费率信息
+func writeFunding(sb *strings.Builder, l reportLocale, fd *FundingData) {
+	if fd == nil {
+		return
+	}
+	line := l.f("funding_detail", fd.Annualized, fd.Interval)
+	if !fd.NextFundingTime.IsZero() {
+		line += l.f("funding_next", time.Until(fd.NextFundingTime).Round(time.Minute))
+	}
+	sb.WriteString(line + "\n")
+	if len(fd.History) > 0 {
+		sb.WriteString(l.f("funding_history", formatRateSlice(fd.History)))
+		sb.WriteString(l.f("funding_average", fd.Average, fd.Momentum))
+	}
+	// 按单位名义价值估算多空持仓的资金费，结算前开仓的持仓成本一目了然
+	now := clockNow()
+	long := EstimateFundingCost(fd, "long", 1, DefaultHoldingHours, now)
+	short := EstimateFundingCost(fd, "short", 1, DefaultHoldingHours, now)
+	sb.WriteString(l.f("funding_cost", DefaultHoldingHours, long.PaymentPct, short.PaymentPct, long.Settlements))
+	sb.WriteString("\n")
+}
+
+// formatRateSlice 以科学计数法格式化费率序列
+func formatRateSlice(values []float64) string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprintf("%.2e", v)
+	}
+	return "[" + strings.Join(out, ", ") + "]"
+}
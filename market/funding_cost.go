@@ -0,0 +1,71 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// DefaultHoldingHours 估算资金费时默认的预期持仓时长
+const DefaultHoldingHours = 8.0
+
+// FundingCost 预期持仓期间的资金费估算（Payment 为正表示支付，为负表示收取）
+type FundingCost struct {
+	Side         string        `json:"side"` // long/short
+	Notional     float64       `json:"notional"`
+	HoldingHours float64       `json:"holding_hours"`
+	Settlements  int           `json:"settlements"`  // 持仓期间经历的结算次数
+	NextIn       time.Duration `json:"next_in"`      // 距下次结算
+	NextPayment  float64       `json:"next_payment"` // 下次结算的资金费（按当前预测费率）
+	Payment      float64       `json:"payment"`      // 持仓期间合计资金费
+	PaymentPct   float64       `json:"payment_pct"`  // 合计资金费占名义价值的百分比
+}
+
+// GetFundingData 获取交易对的资金费率（当前预测费率、历史费率与下次结算时间）
+func GetFundingData(symbol string) (*FundingData, error) {
+	return getFundingData(Normalize(symbol))
+}
+
+// EstimateFundingCost 估算按 notional 名义价值持有 holdingHours 小时的资金费
+// 下次结算按当前预测费率，之后的结算按历史均值（无历史时沿用当前费率）；多头在费率为正时支付，空头相反
+func EstimateFundingCost(fd *FundingData, side string, notional, holdingHours float64, now time.Time) FundingCost {
+	if holdingHours <= 0 {
+		holdingHours = DefaultHoldingHours
+	}
+	c := FundingCost{Side: strings.ToLower(side), Notional: math.Abs(notional), HoldingHours: holdingHours}
+	if fd == nil || c.Notional == 0 {
+		return c
+	}
+
+	interval := fd.Interval
+	if interval <= 0 {
+		interval = defaultFundingInterval
+	}
+	next := fd.NextFundingTime
+	if next.IsZero() || next.Before(now) {
+		next = now.Add(interval)
+	}
+	c.NextIn = next.Sub(now)
+
+	later := fd.Current
+	if len(fd.History) > 0 {
+		later = fd.Average
+	}
+	sign := 1.0
+	if c.Side == "short" {
+		sign = -1
+	}
+	c.NextPayment = sign * fd.Current * c.Notional
+
+	end := now.Add(time.Duration(holdingHours * float64(time.Hour)))
+	for t := next; !t.After(end); t = t.Add(interval) {
+		rate := later
+		if c.Settlements == 0 {
+			rate = fd.Current
+		}
+		c.Payment += sign * rate * c.Notional
+		c.Settlements++
+	}
+	c.PaymentPct = c.Payment / c.Notional * 100
+	return c
+}
@@ -0,0 +1,35 @@
+package market_test
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"nofx/market"
+	"nofx/market/markettest"
+)
+
+// go test ./market -run TestGolden -update 用当前输出覆盖 golden 文件
+var update = flag.Bool("update", false, "用当前输出覆盖 testdata/golden 下的 golden 文件")
+
+func TestGolden(t *testing.T) {
+	fixtures, err := markettest.LoadFixtures(filepath.Join("testdata", "fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("testdata/fixtures 中没有录制样本")
+	}
+	for _, f := range fixtures {
+		t.Run(f.Symbol, func(t *testing.T) {
+			data, err := f.Data(market.DefaultIndicatorConfig())
+			if err != nil {
+				t.Fatalf("计算市场数据失败: %v", err)
+			}
+			path := filepath.Join("testdata", "golden", f.Symbol+".txt")
+			if err := markettest.Golden(path, []byte(market.Format(data)), *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package market
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHashPrecision Hash 使用的有效数字位数，用于消除微小的浮点噪声
+const defaultHashPrecision = 6
+
+// hashSkipFields 不参与哈希的字段：计算来源/时间戳与指标配置不影响行情语义
+var hashSkipFields = map[string]bool{
+	"Provenance":        true,
+	"CurrentProvenance": true,
+	"Indicators":        true,
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Hash 返回行情快照的稳定哈希（浮点按6位有效数字取整，忽略时间戳与计算来源）
+// 两个周期之间行情实质未变时哈希相同，可用于响应缓存与重复决策去重
+func (d *Data) Hash() string {
+	return d.HashWithPrecision(defaultHashPrecision)
+}
+
+// HashWithPrecision 按指定有效数字位数计算哈希（digits<=0 时使用默认值）
+func (d *Data) HashWithPrecision(digits int) string {
+	if digits <= 0 {
+		digits = defaultHashPrecision
+	}
+	var sb strings.Builder
+	writeCanonical(&sb, reflect.ValueOf(d), digits)
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeCanonical 以确定的顺序输出值的规范化文本（map 按键排序，浮点按有效数字取整）
+func writeCanonical(sb *strings.Builder, v reflect.Value, digits int) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			sb.WriteString("nil")
+			return
+		}
+		writeCanonical(sb, v.Elem(), digits)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		sb.WriteByte('{')
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || hashSkipFields[f.Name] || f.Type == timeType {
+				continue
+			}
+			sb.WriteString(f.Name)
+			sb.WriteByte(':')
+			writeCanonical(sb, v.Field(i), digits)
+			sb.WriteByte(';')
+		}
+		sb.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		sb.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			writeCanonical(sb, v.Index(i), digits)
+			sb.WriteByte(',')
+		}
+		sb.WriteByte(']')
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		sb.WriteByte('<')
+		for _, k := range keys {
+			sb.WriteString(fmt.Sprint(k.Interface()))
+			sb.WriteByte('=')
+			writeCanonical(sb, v.MapIndex(k), digits)
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('>')
+	case reflect.Float32, reflect.Float64:
+		sb.WriteString(roundSignificant(v.Float(), digits))
+	default:
+		sb.WriteString(fmt.Sprint(v.Interface()))
+	}
+}
+
+// roundSignificant 按有效数字格式化浮点数（NaN/Inf 与 -0 归一化）
+func roundSignificant(f float64, digits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case f == 0:
+		return "0"
+	}
+	s := strconv.FormatFloat(f, 'g', digits, 64)
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,224 @@
+package market
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HTTPConfig market 包共享 HTTP 客户端配置（所有 REST 请求共用，连接复用）
+type HTTPConfig struct {
+	Timeout             time.Duration // 单次请求超时，默认 10s
+	Retries             int           // 网络错误与 5xx 的重试次数，默认 2
+	RetryBackoff        time.Duration // 首次重试等待，之后每次翻倍，默认 500ms
+	Proxy               string        // 代理地址（如 http://127.0.0.1:7890），为空时读取 HTTP(S)_PROXY 环境变量
+	MaxIdleConnsPerHost int           // 每个域名保留的空闲连接数，默认 16
+}
+
+// DefaultHTTPConfig 默认 HTTP 配置
+func DefaultHTTPConfig() HTTPConfig {
+	return HTTPConfig{
+		Timeout:             10 * time.Second,
+		Retries:             2,
+		RetryBackoff:        500 * time.Millisecond,
+		MaxIdleConnsPerHost: 16,
+	}
+}
+
+func (c HTTPConfig) withDefaults() HTTPConfig {
+	d := DefaultHTTPConfig()
+	if c.Timeout <= 0 {
+		c.Timeout = d.Timeout
+	}
+	if c.Retries < 0 {
+		c.Retries = 0
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = d.RetryBackoff
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = d.MaxIdleConnsPerHost
+	}
+	return c
+}
+
+// NetworkError 网络层错误（连接失败、超时、读取响应失败），可重试
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string { return fmt.Sprintf("请求 %s 失败: %v", e.URL, e.Err) }
+
+// Unwrap 返回底层错误
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// Timeout 是否为超时
+func (e *NetworkError) Timeout() bool {
+	var ne net.Error
+	return errors.As(e.Err, &ne) && ne.Timeout()
+}
+
+// APIError 交易所返回的非 200 响应（Code/Message 解析自 {"code":-1121,"msg":"Invalid symbol."} 形式的响应体）
+type APIError struct {
+	URL        string
+	StatusCode int
+	Code       int
+	Message    string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API返回错误 (status %d, code %d): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("API返回错误 (status %d): %s", e.StatusCode, e.Body)
+}
+
+// binanceCodeInvalidSymbol 币安"交易对不存在"错误码
+const binanceCodeInvalidSymbol = -1121
+
+// Is 使 errors.Is(err, ErrSymbolNotFound) 可以识别交易对不存在的响应
+func (e *APIError) Is(target error) bool {
+	return target == ErrSymbolNotFound && e.Code == binanceCodeInvalidSymbol
+}
+
+// Retryable 服务端错误可重试，客户端错误（参数、交易对不存在等）不可重试
+func (e *APIError) Retryable() bool { return e.StatusCode >= 500 }
+
+// IsNetworkError 错误链中是否包含网络错误
+func IsNetworkError(err error) bool {
+	var ne *NetworkError
+	return errors.As(err, &ne)
+}
+
+// AsAPIError 从错误链中提取交易所 API 错误
+func AsAPIError(err error) (*APIError, bool) {
+	var ae *APIError
+	if errors.As(err, &ae) {
+		return ae, true
+	}
+	return nil, false
+}
+
+var (
+	httpMu     sync.RWMutex
+	httpCfg    = DefaultHTTPConfig()
+	httpClient = newHTTPClient(httpCfg, nil)
+)
+
+// SetHTTPConfig 重新创建共享 HTTP 客户端
+func SetHTTPConfig(cfg HTTPConfig) error {
+	cfg = cfg.withDefaults()
+	var proxy *url.URL
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return fmt.Errorf("无效的代理地址 %s: %w", cfg.Proxy, err)
+		}
+		proxy = u
+	}
+	client := newHTTPClient(cfg, proxy)
+	httpMu.Lock()
+	defer httpMu.Unlock()
+	httpCfg = cfg
+	httpClient = client
+	return nil
+}
+
+// SetHTTPClient 直接替换共享 HTTP 客户端（如测试时注入自定义 Transport），重试参数沿用当前配置
+func SetHTTPClient(client *http.Client) {
+	httpMu.Lock()
+	defer httpMu.Unlock()
+	httpClient = client
+}
+
+// HTTPClient 返回共享 HTTP 客户端
+func HTTPClient() *http.Client {
+	httpMu.RLock()
+	defer httpMu.RUnlock()
+	return httpClient
+}
+
+func currentHTTPConfig() HTTPConfig {
+	httpMu.RLock()
+	defer httpMu.RUnlock()
+	return httpCfg
+}
+
+func newHTTPClient(cfg HTTPConfig, proxy *url.URL) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Timeout: cfg.Timeout, Transport: transport}
+}
+
+// httpGet 通过共享客户端发起 GET 请求：网络错误与 5xx 按指数退避重试，非 200 响应返回 *APIError
+// l 非空时每次尝试前申请 weight 权重，并用响应头校准权重计数
+func httpGet(rawURL string, l *weightLimiter, weight int) ([]byte, error) {
+	cfg := currentHTTPConfig()
+	client := HTTPClient()
+	for attempt := 0; ; attempt++ {
+		if l != nil {
+			if err := l.acquire(weight); err != nil {
+				return nil, err
+			}
+		}
+		body, err := getOnce(client, rawURL, l)
+		if err == nil || attempt >= cfg.Retries || !retryable(err) {
+			return body, err
+		}
+		time.Sleep(cfg.RetryBackoff << attempt)
+	}
+}
+
+func getOnce(client *http.Client, rawURL string, l *weightLimiter) ([]byte, error) {
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, &NetworkError{URL: rawURL, Err: err}
+	}
+	defer resp.Body.Close()
+	if l != nil {
+		l.observe(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &NetworkError{URL: rawURL, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(rawURL, resp.StatusCode, body)
+	}
+	return body, nil
+}
+
+// newAPIError 解析交易所错误响应体
+func newAPIError(rawURL string, status int, body []byte) *APIError {
+	e := &APIError{URL: rawURL, StatusCode: status, Body: string(body)}
+	var payload struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		e.Code, e.Message = payload.Code, payload.Msg
+	}
+	return e
+}
+
+func retryable(err error) bool {
+	if IsNetworkError(err) {
+		return true
+	}
+	if ae, ok := AsAPIError(err); ok {
+		return ae.Retryable()
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package market
+
+import (
+	"fmt"
+	"sync"
+)
+
+// engineHistory 增量引擎为每个指标保留的已收盘K线输出数量（需 >= SeriesLength）
+const engineHistory = 64
+
+// emaCalc 增量EMA：前 period 个值取SMA作为初值，之后按 2/(period+1) 平滑（与 calculateEMA 一致）
+type emaCalc struct {
+	period int
+	count  int
+	sum    float64
+	value  float64
+}
+
+func (e *emaCalc) next(x float64) (float64, bool) {
+	switch n := e.count + 1; {
+	case n < e.period:
+		return 0, false
+	case n == e.period:
+		return (e.sum + x) / float64(e.period), true
+	default:
+		return (x-e.value)*2/float64(e.period+1) + e.value, true
+	}
+}
+
+// update 纳入一个已收盘的值
+func (e *emaCalc) update(x float64) (float64, bool) {
+	v, ok := e.next(x)
+	e.count++
+	e.sum += x
+	e.value = v
+	return v, ok
+}
+
+// rsiCalc 增量 Wilder RSI（与 calculateRSI 一致）
+type rsiCalc struct {
+	period  int
+	changes int
+	hasPrev bool
+	prev    float64
+	gainSum float64
+	lossSum float64
+	avgGain float64
+	avgLoss float64
+}
+
+func (r *rsiCalc) next(x float64) (avgGain, avgLoss float64, ok bool) {
+	if !r.hasPrev {
+		return 0, 0, false
+	}
+	change := x - r.prev
+	gain, loss := 0.0, 0.0
+	if change > 0 {
+		gain = change
+	} else {
+		loss = -change
+	}
+	p := float64(r.period)
+	switch n := r.changes + 1; {
+	case n < r.period:
+		return 0, 0, false
+	case n == r.period:
+		return (r.gainSum + gain) / p, (r.lossSum + loss) / p, true
+	default:
+		return (r.avgGain*(p-1) + gain) / p, (r.avgLoss*(p-1) + loss) / p, true
+	}
+}
+
+// peek 若纳入 x 后的RSI（不修改状态，用于未收盘K线）
+func (r *rsiCalc) peek(x float64) (float64, bool) {
+	g, l, ok := r.next(x)
+	if !ok {
+		return 0, false
+	}
+	return rsiFromAverages(g, l), true
+}
+
+func (r *rsiCalc) update(x float64) (float64, bool) {
+	g, l, ok := r.next(x)
+	if r.hasPrev {
+		change := x - r.prev
+		if change > 0 {
+			r.gainSum += change
+		} else {
+			r.lossSum -= change
+		}
+		r.changes++
+	}
+	r.prev, r.hasPrev = x, true
+	if !ok {
+		return 0, false
+	}
+	r.avgGain, r.avgLoss = g, l
+	return rsiFromAverages(g, l), true
+}
+
+// macdCalc 增量MACD快线（DIF = EMA(fast) - EMA(slow)）
+type macdCalc struct {
+	fast, slow emaCalc
+}
+
+func (m *macdCalc) peek(x float64) (float64, bool) {
+	f, okF := m.fast.next(x)
+	s, okS := m.slow.next(x)
+	return f - s, okF && okS
+}
+
+func (m *macdCalc) update(x float64) (float64, bool) {
+	f, okF := m.fast.update(x)
+	s, okS := m.slow.update(x)
+	return f - s, okF && okS
+}
+
+// indicatorEngine 单个交易对/周期的增量指标状态
+// 已收盘K线只计算一次（O(1)/根），最后一根（可能未收盘）每次仅预览不写入状态
+type indicatorEngine struct {
+	mu       sync.Mutex
+	set      IndicatorSet
+	lastOpen int64 // 已纳入状态的最后一根K线开盘时间
+	ema      map[int]*emaCalc
+	rsi      map[int]*rsiCalc
+	macd     map[string]*macdCalc
+	history  map[string][]float64 // 指标键 -> 已收盘K线的输出（最多 engineHistory 个）
+}
+
+func newIndicatorEngine(set IndicatorSet) *indicatorEngine {
+	e := &indicatorEngine{set: set}
+	e.reset()
+	return e
+}
+
+func (e *indicatorEngine) reset() {
+	e.lastOpen = 0
+	e.ema = make(map[int]*emaCalc, len(e.set.EMA))
+	e.rsi = make(map[int]*rsiCalc, len(e.set.RSI))
+	e.macd = make(map[string]*macdCalc, len(e.set.MACD))
+	e.history = make(map[string][]float64)
+	for _, p := range e.set.EMA {
+		e.ema[p] = &emaCalc{period: p}
+	}
+	for _, p := range e.set.RSI {
+		e.rsi[p] = &rsiCalc{period: p}
+	}
+	for _, mp := range e.set.MACD {
+		e.macd[mp.Key()] = &macdCalc{fast: emaCalc{period: mp.Fast}, slow: emaCalc{period: mp.Slow}}
+	}
+}
+
+func (e *indicatorEngine) push(key string, v float64) {
+	h := append(e.history[key], v)
+	if len(h) > engineHistory {
+		h = h[len(h)-engineHistory:]
+	}
+	e.history[key] = h
+}
+
+// feed 纳入一根已收盘K线
+func (e *indicatorEngine) feed(k Kline) {
+	for p, c := range e.ema {
+		if v, ok := c.update(k.Close); ok {
+			e.push(emaKey(p), v)
+		}
+	}
+	for p, c := range e.rsi {
+		if v, ok := c.update(k.Close); ok {
+			e.push(rsiKey(p), v)
+		}
+	}
+	for key, c := range e.macd {
+		if v, ok := c.update(k.Close); ok {
+			e.push("macd:"+key, v)
+		}
+	}
+	e.lastOpen = k.OpenTime
+}
+
+// sync 将状态推进到 closed 的最后一根；缓存断档（找不到上次位置）时从头重算
+func (e *indicatorEngine) sync(closed []Kline) {
+	start := 0
+	if e.lastOpen != 0 {
+		start = -1
+		for i := len(closed) - 1; i >= 0; i-- {
+			if closed[i].OpenTime == e.lastOpen {
+				start = i + 1
+				break
+			}
+			if closed[i].OpenTime < e.lastOpen {
+				break
+			}
+		}
+		if start < 0 {
+			e.reset()
+			start = 0
+		}
+	}
+	for _, k := range closed[start:] {
+		e.feed(k)
+	}
+}
+
+// compute 返回各指标最近 seriesLength 个点的序列（最后一点基于最新K线，可能未收盘）
+func (e *indicatorEngine) compute(klines []Kline, seriesLength int) (ema, rsi map[int][]float64, macd map[string][]float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ema = make(map[int][]float64, len(e.ema))
+	rsi = make(map[int][]float64, len(e.rsi))
+	macd = make(map[string][]float64, len(e.macd))
+	if len(klines) == 0 {
+		return
+	}
+	e.sync(klines[:len(klines)-1])
+	tip := klines[len(klines)-1].Close
+
+	for p, c := range e.ema {
+		if v, ok := c.next(tip); ok {
+			ema[p] = e.series(emaKey(p), v, seriesLength)
+		}
+	}
+	for p, c := range e.rsi {
+		if v, ok := c.peek(tip); ok {
+			rsi[p] = e.series(rsiKey(p), v, seriesLength)
+		}
+	}
+	for key, c := range e.macd {
+		if v, ok := c.peek(tip); ok {
+			macd[key] = e.series("macd:"+key, v, seriesLength)
+		}
+	}
+	return
+}
+
+// series 已收盘历史的最后 n-1 个值 + 最新值
+func (e *indicatorEngine) series(key string, tip float64, n int) []float64 {
+	h := e.history[key]
+	if n-1 < len(h) {
+		h = h[len(h)-(n-1):]
+	}
+	out := make([]float64, 0, len(h)+1)
+	out = append(out, h...)
+	return append(out, tip)
+}
+
+func emaKey(p int) string { return fmt.Sprintf("ema:%d", p) }
+func rsiKey(p int) string { return fmt.Sprintf("rsi:%d", p) }
+
+// indicatorEngine 获取（不存在时创建）交易对/周期/指标集合对应的增量引擎，跨 Get 调用复用
+func (m *Monitor) indicatorEngine(symbol, interval string, set IndicatorSet) *indicatorEngine {
+	key := fmt.Sprintf("%s|%s|%v", symbol, interval, set)
+	if v, ok := m.indicatorEngines.Load(key); ok {
+		return v.(*indicatorEngine)
+	}
+	v, _ := m.indicatorEngines.LoadOrStore(key, newIndicatorEngine(set))
+	return v.(*indicatorEngine)
+}
@@ -0,0 +1,209 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MACDParams MACD 参数（快线、慢线、信号线周期）
+type MACDParams struct {
+	Fast   int `json:"fast"`
+	Slow   int `json:"slow"`
+	Signal int `json:"signal"`
+}
+
+// Key 序列键，如 "12,26,9"
+func (p MACDParams) Key() string {
+	return fmt.Sprintf("%d,%d,%d", p.Fast, p.Slow, p.Signal)
+}
+
+// IndicatorSet 一组时间框架需要计算的指标及周期
+type IndicatorSet struct {
+	EMA  []int        `json:"ema"`
+	RSI  []int        `json:"rsi"`
+	MACD []MACDParams `json:"macd"`
+	ATR  []int        `json:"atr"`
+}
+
+// IndicatorConfig 指标配置：不同策略可请求不同的指标集合，无需修改代码
+type IndicatorConfig struct {
+	CurrentEMA  int        `json:"current_ema"`  // 当前EMA周期（基于3m），默认20
+	CurrentRSI  int        `json:"current_rsi"`  // 当前RSI周期（基于3m），默认7
+	CurrentMACD MACDParams `json:"current_macd"` // 当前MACD参数（基于3m），默认12,26,9
+
+	Intraday     IndicatorSet `json:"intraday"`      // 3m/15m/1h 序列指标
+	LongerTerm   IndicatorSet `json:"longer_term"`   // 4h/1d 指标
+	SeriesLength int          `json:"series_length"` // 输出序列长度，默认10
+}
+
+// DefaultIndicatorConfig 默认指标配置（与历史硬编码保持一致）
+func DefaultIndicatorConfig() IndicatorConfig {
+	return IndicatorConfig{
+		CurrentEMA:  20,
+		CurrentRSI:  7,
+		CurrentMACD: MACDParams{12, 26, 9},
+		Intraday: IndicatorSet{
+			EMA:  []int{20},
+			RSI:  []int{7, 9, 10, 14},
+			MACD: []MACDParams{{10, 20, 8}, {12, 26, 9}},
+			ATR:  []int{6, 10, 12, 14},
+		},
+		LongerTerm: IndicatorSet{
+			EMA:  []int{20, 50},
+			RSI:  []int{14, 21},
+			MACD: []MACDParams{{14, 28, 10}, {12, 26, 9}},
+			ATR:  []int{3, 10, 12, 14},
+		},
+		SeriesLength: 10,
+	}
+}
+
+// ParseIndicatorConfig 解析交易员配置中的指标配置 JSON（空字符串返回 nil，表示使用默认指标集合）
+func ParseIndicatorConfig(s string) (*IndicatorConfig, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var c IndicatorConfig
+	if err := json.Unmarshal([]byte(s), &c); err != nil {
+		return nil, fmt.Errorf("解析指标配置失败: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate 检查周期取值（0 表示使用默认值）
+func (c IndicatorConfig) Validate() error {
+	if c.CurrentEMA < 0 || c.CurrentRSI < 0 || c.SeriesLength < 0 {
+		return fmt.Errorf("指标周期不能为负数")
+	}
+	for _, s := range []IndicatorSet{c.Intraday, c.LongerTerm} {
+		for _, periods := range [][]int{s.EMA, s.RSI, s.ATR} {
+			for _, p := range periods {
+				if p <= 0 {
+					return fmt.Errorf("指标周期必须大于 0: %d", p)
+				}
+			}
+		}
+		for _, m := range s.MACD {
+			if m.Fast <= 0 || m.Signal <= 0 || m.Fast >= m.Slow {
+				return fmt.Errorf("MACD 参数无效: %s（需快线 < 慢线且均大于 0）", m.Key())
+			}
+		}
+	}
+	return nil
+}
+
+// withDefaults 未设置的字段使用默认值（指标集合为空时使用默认集合）
+func (c IndicatorConfig) withDefaults() IndicatorConfig {
+	def := DefaultIndicatorConfig()
+	if c.CurrentEMA <= 0 {
+		c.CurrentEMA = def.CurrentEMA
+	}
+	if c.CurrentRSI <= 0 {
+		c.CurrentRSI = def.CurrentRSI
+	}
+	if c.CurrentMACD.Fast <= 0 || c.CurrentMACD.Slow <= 0 || c.CurrentMACD.Signal <= 0 {
+		c.CurrentMACD = def.CurrentMACD
+	}
+	if c.Intraday.empty() {
+		c.Intraday = def.Intraday
+	}
+	if c.LongerTerm.empty() {
+		c.LongerTerm = def.LongerTerm
+	}
+	if c.SeriesLength <= 0 {
+		c.SeriesLength = def.SeriesLength
+	}
+	return c
+}
+
+func (s IndicatorSet) empty() bool {
+	return len(s.EMA) == 0 && len(s.RSI) == 0 && len(s.MACD) == 0 && len(s.ATR) == 0
+}
+
+// hasInt 判断周期列表是否包含 p
+func hasInt(periods []int, p int) bool {
+	for _, v := range periods {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCustomIntraday 输出默认配置之外的自定义日内指标
+func writeCustomIntraday(sb *strings.Builder, l reportLocale, data *IntradayData) {
+	def := DefaultIndicatorConfig().Intraday
+	for _, p := range sortedIntKeys(data.EMASeries) {
+		if !hasInt(def.EMA, p) && len(data.EMASeries[p]) > 0 {
+			sb.WriteString(l.f("ema_series", p, formatFloatSlice(data.EMASeries[p])))
+		}
+	}
+	writeCustomMACD(sb, l, data.MACDSeries, def.MACD)
+	for _, p := range sortedIntKeys(data.RSISeries) {
+		if !hasInt(def.RSI, p) && len(data.RSISeries[p]) > 0 {
+			sb.WriteString(l.f("rsi_series", p, formatFloatSlice(data.RSISeries[p])))
+		}
+	}
+	for _, p := range sortedIntKeys(data.ATR) {
+		if !hasInt(def.ATR, p) {
+			sb.WriteString(l.f("atr_value", p, data.ATR[p]))
+		}
+	}
+}
+
+// writeCustomLongerTerm 输出默认配置之外的自定义长期指标
+func writeCustomLongerTerm(sb *strings.Builder, l reportLocale, data *LongerTermData) {
+	def := DefaultIndicatorConfig().LongerTerm
+	for _, p := range sortedIntKeys(data.EMA) {
+		if !hasInt(def.EMA, p) {
+			sb.WriteString(l.f("ema_value", p, data.EMA[p]))
+		}
+	}
+	writeCustomMACD(sb, l, data.MACDSeries, def.MACD)
+	for _, p := range sortedIntKeys(data.RSISeries) {
+		if !hasInt(def.RSI, p) && len(data.RSISeries[p]) > 0 {
+			sb.WriteString(l.f("rsi_series", p, formatFloatSlice(data.RSISeries[p])))
+		}
+	}
+	for _, p := range sortedIntKeys(data.ATR) {
+		if !hasInt(def.ATR, p) {
+			sb.WriteString(l.f("atr_value", p, data.ATR[p]))
+		}
+	}
+}
+
+// writeCustomMACD 输出默认参数之外的MACD序列
+func writeCustomMACD(sb *strings.Builder, l reportLocale, series map[string][]float64, defaults []MACDParams) {
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		isDefault := false
+		for _, d := range defaults {
+			if d.Key() == k {
+				isDefault = true
+				break
+			}
+		}
+		if !isDefault && len(series[k]) > 0 {
+			sb.WriteString(l.f("macd_series", k, formatFloatSlice(series[k])))
+		}
+	}
+}
+
+// sortedIntKeys 返回升序排列的周期键
+func sortedIntKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,246 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 扩展指标的默认参数
+const (
+	bollingerPeriod     = 20
+	bollingerMultiplier = 2.0
+	stochRSIPeriod      = 14 // RSI 周期
+	stochPeriod         = 14 // 随机指标回看周期
+	stochSmoothK        = 3
+	stochSmoothD        = 3
+	adxPeriod           = 14
+)
+
+// Bollinger 布林带
+type Bollinger struct {
+	Upper     float64 `json:"upper"`
+	Middle    float64 `json:"middle"`
+	Lower     float64 `json:"lower"`
+	Bandwidth float64 `json:"bandwidth"` // (上轨-下轨)/中轨
+	PercentB  float64 `json:"percent_b"` // (收盘-下轨)/(上轨-下轨)，>1 突破上轨，<0 跌破下轨
+}
+
+// calculateBollinger 计算布林带（中轨为 period 期 SMA，带宽为 multiplier 倍总体标准差）
+func calculateBollinger(klines []Kline, period int, multiplier float64) Bollinger {
+	var b Bollinger
+	if period <= 0 || len(klines) < period {
+		return b
+	}
+	window := klines[len(klines)-period:]
+	sum := 0.0
+	for _, k := range window {
+		sum += k.Close
+	}
+	mean := sum / float64(period)
+	variance := 0.0
+	for _, k := range window {
+		d := k.Close - mean
+		variance += d * d
+	}
+	std := math.Sqrt(variance / float64(period))
+
+	b.Middle = mean
+	b.Upper = mean + multiplier*std
+	b.Lower = mean - multiplier*std
+	if mean != 0 {
+		b.Bandwidth = (b.Upper - b.Lower) / mean
+	}
+	if width := b.Upper - b.Lower; width > 0 {
+		b.PercentB = (klines[len(klines)-1].Close - b.Lower) / width
+	}
+	return b
+}
+
+// calculateRSISeries 计算完整的 Wilder RSI 序列（与 calculateRSI 算法一致，O(n)）
+// 返回值与 klines[period:] 一一对应
+func calculateRSISeries(klines []Kline, period int) []float64 {
+	if period <= 0 || len(klines) <= period {
+		return nil
+	}
+	gains, losses := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := klines[i].Close - klines[i-1].Close
+		if change > 0 {
+			gains += change
+		} else {
+			losses -= change
+		}
+	}
+	avgGain := gains / float64(period)
+	avgLoss := losses / float64(period)
+
+	series := make([]float64, 0, len(klines)-period)
+	series = append(series, rsiFromAverages(avgGain, avgLoss))
+	for i := period + 1; i < len(klines); i++ {
+		change := klines[i].Close - klines[i-1].Close
+		gain, loss := 0.0, 0.0
+		if change > 0 {
+			gain = change
+		} else {
+			loss = -change
+		}
+		avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		series = append(series, rsiFromAverages(avgGain, avgLoss))
+	}
+	return series
+}
+
+func rsiFromAverages(avgGain, avgLoss float64) float64 {
+	if avgLoss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+avgGain/avgLoss)
+}
+
+// calculateStochRSI 计算随机RSI，返回 %K 与 %D（0-100）
+func calculateStochRSI(klines []Kline, rsiPeriod, stochLen, smoothK, smoothD int) (float64, float64) {
+	rsi := calculateRSISeries(klines, rsiPeriod)
+	if len(rsi) < stochLen+smoothK+smoothD-2 {
+		return 0, 0
+	}
+	// 原始 StochRSI
+	raw := make([]float64, 0, len(rsi)-stochLen+1)
+	for i := stochLen - 1; i < len(rsi); i++ {
+		lo, hi := rsi[i], rsi[i]
+		for _, v := range rsi[i-stochLen+1 : i+1] {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+		if hi-lo == 0 {
+			raw = append(raw, 0)
+			continue
+		}
+		raw = append(raw, (rsi[i]-lo)/(hi-lo)*100)
+	}
+	k := smaSeries(raw, smoothK)
+	d := smaSeries(k, smoothD)
+	if len(k) == 0 || len(d) == 0 {
+		return 0, 0
+	}
+	return k[len(k)-1], d[len(d)-1]
+}
+
+// smaSeries 简单移动平均序列
+func smaSeries(values []float64, period int) []float64 {
+	if period <= 0 || len(values) < period {
+		return nil
+	}
+	out := make([]float64, 0, len(values)-period+1)
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out = append(out, sum/float64(period))
+		}
+	}
+	return out
+}
+
+// calculateADX 计算 ADX 及 +DI/-DI（Wilder 平滑）
+func calculateADX(klines []Kline, period int) (adx, plusDI, minusDI float64) {
+	if period <= 0 || len(klines) < 2*period+1 {
+		return 0, 0, 0
+	}
+	var trSum, plusSum, minusSum float64
+	var dxs []float64
+	for i := 1; i < len(klines); i++ {
+		cur, prev := klines[i], klines[i-1]
+		tr := math.Max(cur.High-cur.Low, math.Max(math.Abs(cur.High-prev.Close), math.Abs(cur.Low-prev.Close)))
+		up := cur.High - prev.High
+		down := prev.Low - cur.Low
+		plusDM, minusDM := 0.0, 0.0
+		if up > down && up > 0 {
+			plusDM = up
+		}
+		if down > up && down > 0 {
+			minusDM = down
+		}
+
+		if i <= period {
+			trSum += tr
+			plusSum += plusDM
+			minusSum += minusDM
+			if i < period {
+				continue
+			}
+		} else {
+			trSum = trSum - trSum/float64(period) + tr
+			plusSum = plusSum - plusSum/float64(period) + plusDM
+			minusSum = minusSum - minusSum/float64(period) + minusDM
+		}
+
+		if trSum == 0 {
+			dxs = append(dxs, 0)
+			continue
+		}
+		plusDI = 100 * plusSum / trSum
+		minusDI = 100 * minusSum / trSum
+		dx := 0.0
+		if s := plusDI + minusDI; s > 0 {
+			dx = 100 * math.Abs(plusDI-minusDI) / s
+		}
+		dxs = append(dxs, dx)
+	}
+	if len(dxs) < period {
+		return 0, plusDI, minusDI
+	}
+	for _, dx := range dxs[:period] {
+		adx += dx
+	}
+	adx /= float64(period)
+	for _, dx := range dxs[period:] {
+		adx = (adx*float64(period-1) + dx) / float64(period)
+	}
+	return adx, plusDI, minusDI
+}
+
+// calculateSessionVWAP 计算当日（UTC 0点起）成交量加权平均价，使用典型价 (H+L+C)/3
+// K线未覆盖到当日开盘（如3m缓存只有最近6小时）时返回0，避免输出不完整的VWAP
+func calculateSessionVWAP(klines []Kline) float64 {
+	if len(klines) == 0 {
+		return 0
+	}
+	last := time.UnixMilli(klines[len(klines)-1].OpenTime).UTC()
+	sessionStart := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC).UnixMilli()
+	if klines[0].OpenTime > sessionStart {
+		return 0
+	}
+	var pv, vol float64
+	for i := len(klines) - 1; i >= 0; i-- {
+		k := klines[i]
+		if k.OpenTime < sessionStart {
+			break
+		}
+		pv += (k.High + k.Low + k.Close) / 3 * k.Volume
+		vol += k.Volume
+	}
+	if vol == 0 {
+		return 0
+	}
+	return pv / vol
+}
+
+// writeBandsAndTrend 输出布林带、StochRSI 与 ADX（数据不足时跳过对应行）
+func writeBandsAndTrend(sb *strings.Builder, l reportLocale, b Bollinger, stochK, stochD, adx, plusDI, minusDI float64) {
+	if b.Middle > 0 {
+		sb.WriteString(l.f("bollinger",
+			b.Upper, b.Middle, b.Lower, b.Bandwidth, b.PercentB))
+	}
+	if stochK > 0 || stochD > 0 {
+		sb.WriteString(fmt.Sprintf("StochRSI(14,14,3,3): K=%.2f, D=%.2f\n\n", stochK, stochD))
+	}
+	if adx > 0 {
+		sb.WriteString(fmt.Sprintf("ADX(14)=%.2f, +DI=%.2f, -DI=%.2f\n\n", adx, plusDI, minusDI))
+	}
+}
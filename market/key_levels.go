@@ -0,0 +1,262 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// swingLookback 摆动高/低点两侧需要的K线数量
+	swingLookback = 3
+	// maxKeyLevelsPerSide 支撑/阻力各保留的最近关键位数量
+	maxKeyLevelsPerSide = 5
+)
+
+// 关键位类型
+const (
+	LevelSwingHigh   = "swing_high_1h"
+	LevelSwingLow    = "swing_low_1h"
+	LevelSwingHigh4h = "swing_high_4h"
+	LevelSwingLow4h  = "swing_low_4h"
+	LevelDailyPivot  = "daily_pivot"
+	LevelWeeklyPivot = "weekly_pivot"
+	LevelRound       = "round_number"
+)
+
+// KeyLevel 单个关键价位
+type KeyLevel struct {
+	Kind        string  `json:"kind"`         // 类型（摆动点/枢轴/整数关口）
+	Label       string  `json:"label"`        // 枢轴位名称（P/R1/S1...），其余类型为空
+	Price       float64 `json:"price"`        // 价位
+	DistancePct float64 `json:"distance_pct"` // 相对当前价格的距离百分比（正值在上方）
+}
+
+// PivotLevels 经典枢轴点
+type PivotLevels struct {
+	P  float64 `json:"p"`
+	R1 float64 `json:"r1"`
+	R2 float64 `json:"r2"`
+	R3 float64 `json:"r3"`
+	S1 float64 `json:"s1"`
+	S2 float64 `json:"s2"`
+	S3 float64 `json:"s3"`
+}
+
+// KeyLevels 支撑阻力与枢轴位
+type KeyLevels struct {
+	Resistances []KeyLevel   `json:"resistances,omitempty"`  // 当前价格上方，由近到远
+	Supports    []KeyLevel   `json:"supports,omitempty"`     // 当前价格下方，由近到远
+	DailyPivot  *PivotLevels `json:"daily_pivot,omitempty"`  // 基于上一根已收盘日线
+	WeeklyPivot *PivotLevels `json:"weekly_pivot,omitempty"` // 基于上一个完整自然周（UTC，周一开始）
+}
+
+// calculateKeyLevels 根据1h/4h摆动点、日/周枢轴与整数关口计算关键位
+func calculateKeyLevels(price float64, klines1h, klines4h, klines1d []Kline, now time.Time) *KeyLevels {
+	if price <= 0 {
+		return nil
+	}
+	levels := &KeyLevels{}
+	var candidates []KeyLevel
+
+	for _, p := range findSwingHighs(klines1h, swingLookback) {
+		candidates = append(candidates, KeyLevel{Kind: LevelSwingHigh, Price: p})
+	}
+	for _, p := range findSwingLows(klines1h, swingLookback) {
+		candidates = append(candidates, KeyLevel{Kind: LevelSwingLow, Price: p})
+	}
+	for _, p := range findSwingHighs(klines4h, swingLookback) {
+		candidates = append(candidates, KeyLevel{Kind: LevelSwingHigh4h, Price: p})
+	}
+	for _, p := range findSwingLows(klines4h, swingLookback) {
+		candidates = append(candidates, KeyLevel{Kind: LevelSwingLow4h, Price: p})
+	}
+
+	if daily, ok := previousDailyBar(klines1d, now); ok {
+		levels.DailyPivot = classicPivots(daily.High, daily.Low, daily.Close)
+		candidates = append(candidates, pivotCandidates(LevelDailyPivot, levels.DailyPivot)...)
+	}
+	if high, low, closePrice, ok := previousWeekHLC(klines1d, now); ok {
+		levels.WeeklyPivot = classicPivots(high, low, closePrice)
+		candidates = append(candidates, pivotCandidates(LevelWeeklyPivot, levels.WeeklyPivot)...)
+	}
+
+	step := roundNumberStep(price)
+	below := math.Floor(price/step) * step
+	for i := 0; i < 2; i++ {
+		candidates = append(candidates,
+			KeyLevel{Kind: LevelRound, Price: below - float64(i)*step},
+			KeyLevel{Kind: LevelRound, Price: below + float64(i+1)*step})
+	}
+
+	for _, c := range candidates {
+		if c.Price <= 0 || c.Price == price {
+			continue
+		}
+		c.DistancePct = (c.Price - price) / price * 100
+		if c.Price > price {
+			levels.Resistances = append(levels.Resistances, c)
+		} else {
+			levels.Supports = append(levels.Supports, c)
+		}
+	}
+	levels.Resistances = nearestDistinct(levels.Resistances, maxKeyLevelsPerSide)
+	levels.Supports = nearestDistinct(levels.Supports, maxKeyLevelsPerSide)
+	return levels
+}
+
+// findSwingHighs 返回两侧各 lookback 根K线内最高的高点（仅使用已确认的摆动点）
+func findSwingHighs(klines []Kline, lookback int) []float64 {
+	var out []float64
+	for i := lookback; i < len(klines)-lookback; i++ {
+		isSwing := true
+		for j := i - lookback; j <= i+lookback; j++ {
+			if j != i && klines[j].High >= klines[i].High {
+				isSwing = false
+				break
+			}
+		}
+		if isSwing {
+			out = append(out, klines[i].High)
+		}
+	}
+	return out
+}
+
+// findSwingLows 返回两侧各 lookback 根K线内最低的低点
+func findSwingLows(klines []Kline, lookback int) []float64 {
+	var out []float64
+	for i := lookback; i < len(klines)-lookback; i++ {
+		isSwing := true
+		for j := i - lookback; j <= i+lookback; j++ {
+			if j != i && klines[j].Low <= klines[i].Low {
+				isSwing = false
+				break
+			}
+		}
+		if isSwing {
+			out = append(out, klines[i].Low)
+		}
+	}
+	return out
+}
+
+// classicPivots 经典枢轴点公式
+func classicPivots(high, low, closePrice float64) *PivotLevels {
+	p := (high + low + closePrice) / 3
+	return &PivotLevels{
+		P:  p,
+		R1: 2*p - low,
+		S1: 2*p - high,
+		R2: p + (high - low),
+		S2: p - (high - low),
+		R3: high + 2*(p-low),
+		S3: low - 2*(high-p),
+	}
+}
+
+// pivotCandidates 将枢轴位展开为关键位候选
+func pivotCandidates(kind string, p *PivotLevels) []KeyLevel {
+	return []KeyLevel{
+		{Kind: kind, Label: "P", Price: p.P},
+		{Kind: kind, Label: "R1", Price: p.R1},
+		{Kind: kind, Label: "R2", Price: p.R2},
+		{Kind: kind, Label: "R3", Price: p.R3},
+		{Kind: kind, Label: "S1", Price: p.S1},
+		{Kind: kind, Label: "S2", Price: p.S2},
+		{Kind: kind, Label: "S3", Price: p.S3},
+	}
+}
+
+// previousDailyBar 返回最近一根已收盘的日线
+func previousDailyBar(klines1d []Kline, now time.Time) (Kline, bool) {
+	nowMs := now.UnixMilli()
+	for i := len(klines1d) - 1; i >= 0; i-- {
+		if klines1d[i].CloseTime < nowMs {
+			return klines1d[i], true
+		}
+	}
+	return Kline{}, false
+}
+
+// previousWeekHLC 由日线聚合上一个完整自然周（UTC，周一开始）的高、低、收
+func previousWeekHLC(klines1d []Kline, now time.Time) (high, low, closePrice float64, ok bool) {
+	now = now.UTC()
+	weekday := (int(now.Weekday()) + 6) % 7 // 周一=0
+	thisWeek := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -weekday)
+	start, end := thisWeek.AddDate(0, 0, -7).UnixMilli(), thisWeek.UnixMilli()
+
+	count := 0
+	for _, k := range klines1d {
+		if k.OpenTime < start || k.OpenTime >= end {
+			continue
+		}
+		if count == 0 || k.High > high {
+			high = k.High
+		}
+		if count == 0 || k.Low < low {
+			low = k.Low
+		}
+		closePrice = k.Close
+		count++
+	}
+	// 数据不足一周（如新上线币种）时不计算
+	return high, low, closePrice, count == 7
+}
+
+// roundNumberStep 整数关口间距：价格数量级的一半（如 67000 -> 5000，2500 -> 500，0.52 -> 0.05）
+func roundNumberStep(price float64) float64 {
+	return math.Pow(10, math.Floor(math.Log10(price))-1) * 5
+}
+
+// keyLevelMergePct 距离差小于该百分比的关键位视为同一价位（保留先出现的类型）
+const keyLevelMergePct = 0.05
+
+// nearestDistinct 按距离当前价格由近到远排序，合并相近价位后保留前 n 个
+func nearestDistinct(levels []KeyLevel, n int) []KeyLevel {
+	sort.SliceStable(levels, func(i, j int) bool {
+		return math.Abs(levels[i].DistancePct) < math.Abs(levels[j].DistancePct)
+	})
+	out := make([]KeyLevel, 0, n)
+	for _, lv := range levels {
+		if len(out) > 0 && math.Abs(lv.DistancePct-out[len(out)-1].DistancePct) < keyLevelMergePct {
+			continue
+		}
+		out = append(out, lv)
+		if len(out) == n {
+			break
+		}
+	}
+	return out
+}
+
+// writeKeyLevels 输出关键位
+func writeKeyLevels(sb *strings.Builder, l reportLocale, k *KeyLevels) {
+	if k == nil || (len(k.Resistances) == 0 && len(k.Supports) == 0) {
+		return
+	}
+	sb.WriteString(l.f("key_levels_resistance", formatKeyLevels(l, k.Resistances)))
+	sb.WriteString(l.f("key_levels_support", formatKeyLevels(l, k.Supports)))
+	if k.DailyPivot != nil {
+		sb.WriteString(l.f("key_levels_daily_pivot", k.DailyPivot.P, k.DailyPivot.R1, k.DailyPivot.S1))
+	}
+	if k.WeeklyPivot != nil {
+		sb.WriteString(l.f("key_levels_weekly_pivot", k.WeeklyPivot.P, k.WeeklyPivot.R1, k.WeeklyPivot.S1))
+	}
+	sb.WriteString("\n")
+}
+
+// formatKeyLevels 格式化关键位列表，如 "67000.000(整数关口, +1.25%)"
+func formatKeyLevels(l reportLocale, levels []KeyLevel) string {
+	parts := make([]string, 0, len(levels))
+	for _, lv := range levels {
+		kind := l.text("level:" + lv.Kind)
+		if lv.Label != "" {
+			kind += " " + lv.Label
+		}
+		parts = append(parts, fmt.Sprintf("%.3f(%s, %+.2f%%)", lv.Price, kind, lv.DistancePct))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,159 @@
+package market
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxAggregateInterval 本地聚合支持的最大周期（1d 及以下与 UTC 零点对齐；1w 起始于周一，不能按毫秒整除）
+const maxAggregateInterval = 24 * time.Hour
+
+// klineAggregate 单个交易对某派生周期的聚合状态
+// prefix 为当前派生K线内已收盘的基础K线汇总，cur 为正在形成的基础K线
+type klineAggregate struct {
+	mu        sync.Mutex
+	bucket    int64 // 派生K线开盘时间
+	prefix    Kline
+	hasPrefix bool
+	cur       Kline
+	hasCur    bool
+}
+
+// derivedIntervals 返回可由基础周期本地聚合的周期（基础周期的整数倍且不超过 1d）
+func derivedIntervals(base string, intervals []string) []string {
+	if base == "" {
+		return nil
+	}
+	baseDur, err := intervalDuration(base)
+	if err != nil || baseDur <= 0 {
+		return nil
+	}
+	var derived []string
+	for _, interval := range intervals {
+		d, err := intervalDuration(interval)
+		if err != nil || d <= baseDur || d > maxAggregateInterval || d%baseDur != 0 {
+			continue
+		}
+		derived = append(derived, interval)
+	}
+	return derived
+}
+
+// isDerived 指定周期是否由基础周期聚合生成
+func (m *Monitor) isDerived(interval string) bool {
+	return slices.Contains(m.derivedIntervals, interval)
+}
+
+// streamIntervals 需要单独订阅 WebSocket 的周期
+func (m *Monitor) streamIntervals() []string {
+	if len(m.derivedIntervals) == 0 {
+		return m.config.Intervals
+	}
+	var streams []string
+	for _, interval := range m.config.Intervals {
+		if !m.isDerived(interval) {
+			streams = append(streams, interval)
+		}
+	}
+	return streams
+}
+
+// streamIntervalFor 返回提供该周期数据的订阅周期（派生周期返回基础周期）
+func (m *Monitor) streamIntervalFor(interval string) string {
+	if m.isDerived(interval) {
+		return m.config.AggregateFrom
+	}
+	return interval
+}
+
+// aggregateKline 用一根基础周期K线更新所有派生周期（需在写入基础周期缓存之前调用）
+func (m *Monitor) aggregateKline(symbol string, k Kline) {
+	for _, interval := range m.derivedIntervals {
+		d, err := intervalDuration(interval)
+		if err != nil {
+			continue
+		}
+		value, _ := m.aggregates.LoadOrStore(symbol+"|"+interval, &klineAggregate{bucket: -1})
+		agg := value.(*klineAggregate)
+		ring := m.klineRing(symbol, interval)
+		ring.Upsert(agg.update(k, d.Milliseconds(), ring, m.klineRing(symbol, m.config.AggregateFrom)))
+	}
+}
+
+// update 合并基础K线并返回当前派生K线
+func (a *klineAggregate) update(k Kline, durMs int64, derived, base *klineRing) Kline {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	bucket := k.OpenTime - k.OpenTime%durMs
+	if bucket != a.bucket {
+		a.seed(bucket, k.OpenTime, derived, base)
+	}
+	if a.hasCur && a.cur.OpenTime != k.OpenTime {
+		// 上一根基础K线已收盘，并入前缀
+		a.prefix = mergeAggregate(a.prefix, a.hasPrefix, a.cur)
+		a.hasPrefix = true
+	}
+	a.cur, a.hasCur = k, true
+
+	out := mergeAggregate(a.prefix, a.hasPrefix, a.cur)
+	out.OpenTime = bucket
+	out.CloseTime = bucket + durMs - 1
+	return out
+}
+
+// seed 进入新的派生K线时，用缓存中已有的数据重建前缀
+// 优先使用基础周期缓存中同一区间内更早的K线；基础缓存不覆盖区间起点时（如 1d），
+// 以派生缓存中 REST 加载的同一根K线为前缀并扣除当前基础K线已计入的成交量
+func (a *klineAggregate) seed(bucket, openTime int64, derived, base *klineRing) {
+	first := a.bucket < 0
+	a.bucket = bucket
+	a.prefix, a.hasPrefix = Kline{}, false
+	a.cur, a.hasCur = Kline{}, false
+
+	baseKlines := base.Snapshot()
+	if len(baseKlines) > 0 && baseKlines[0].OpenTime <= bucket {
+		for _, bk := range baseKlines {
+			if bk.OpenTime >= bucket && bk.OpenTime < openTime {
+				a.prefix = mergeAggregate(a.prefix, a.hasPrefix, bk)
+				a.hasPrefix = true
+			}
+		}
+		return
+	}
+	if !first {
+		return
+	}
+	last := derived.Snapshot()
+	if len(last) == 0 || last[len(last)-1].OpenTime != bucket {
+		return
+	}
+	prefix := last[len(last)-1]
+	for _, bk := range baseKlines {
+		if bk.OpenTime == openTime {
+			prefix.Volume -= bk.Volume
+			prefix.QuoteVolume -= bk.QuoteVolume
+			prefix.TakerBuyBaseVolume -= bk.TakerBuyBaseVolume
+			prefix.TakerBuyQuoteVolume -= bk.TakerBuyQuoteVolume
+			prefix.Trades -= bk.Trades
+		}
+	}
+	a.prefix, a.hasPrefix = prefix, true
+}
+
+// mergeAggregate 将一根K线并入聚合结果（开盘取最早、收盘取最新、高低取极值、成交量累加）
+func mergeAggregate(acc Kline, has bool, k Kline) Kline {
+	if !has {
+		return k
+	}
+	acc.High = max(acc.High, k.High)
+	acc.Low = min(acc.Low, k.Low)
+	acc.Close = k.Close
+	acc.Volume += k.Volume
+	acc.QuoteVolume += k.QuoteVolume
+	acc.TakerBuyBaseVolume += k.TakerBuyBaseVolume
+	acc.TakerBuyQuoteVolume += k.TakerBuyQuoteVolume
+	acc.Trades += k.Trades
+	acc.CloseTime = k.CloseTime
+	return acc
+}
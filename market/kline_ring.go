@@ -0,0 +1,117 @@
+package market
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// defaultKlineRingCapacity 未配置周期的默认容量
+const defaultKlineRingCapacity = 100
+
+// klineRingCapacity 各周期的K线环形缓冲容量
+// 3m 需要至少 21 根计算1小时涨跌，且用于 MACD(26)+10 个输出点；长周期需要 EMA50
+var klineRingCapacity = map[string]int{
+	"1m":  240,
+	"3m":  120,
+	"5m":  120,
+	"15m": 100,
+	"1h":  100,
+	"4h":  100,
+	"1d":  100,
+}
+
+// ringCapacityFor 返回指定周期的环形缓冲容量
+func ringCapacityFor(interval string) int {
+	if c, ok := klineRingCapacity[interval]; ok && c > 0 {
+		return c
+	}
+	return defaultKlineRingCapacity
+}
+
+// klineRing 固定容量的K线环形缓冲区，写满后覆盖最旧的数据，避免长时间运行内存持续增长
+type klineRing struct {
+	mu    sync.RWMutex
+	buf   []Kline
+	start int // 最旧元素下标
+	size  int // 当前元素数量
+}
+
+func newKlineRing(capacity int) *klineRing {
+	if capacity <= 0 {
+		capacity = defaultKlineRingCapacity
+	}
+	return &klineRing{buf: make([]Kline, capacity)}
+}
+
+// Reset 用给定K线（从旧到新）重置缓冲区，超出容量时仅保留最新部分
+func (r *klineRing) Reset(klines []Kline) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(klines) > len(r.buf) {
+		klines = klines[len(klines)-len(r.buf):]
+	}
+	copy(r.buf, klines)
+	r.start = 0
+	r.size = len(klines)
+}
+
+// Upsert 若与最新K线开盘时间相同则原地更新，否则追加（写满时覆盖最旧的一根）
+func (r *klineRing) Upsert(k Kline) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 {
+		last := (r.start + r.size - 1) % len(r.buf)
+		if r.buf[last].OpenTime == k.OpenTime {
+			r.buf[last] = k
+			return
+		}
+	}
+	if r.size < len(r.buf) {
+		r.buf[(r.start+r.size)%len(r.buf)] = k
+		r.size++
+		return
+	}
+	r.buf[r.start] = k
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// Snapshot 返回从旧到新的K线副本（调用方可安全修改）
+func (r *klineRing) Snapshot() []Kline {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]Kline, r.size)
+	for i := 0; i < r.size; i++ {
+		result[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return result
+}
+
+// Len 当前K线数量
+func (r *klineRing) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.size
+}
+
+// Cap 缓冲区容量
+func (r *klineRing) Cap() int {
+	return len(r.buf)
+}
+
+// klineSize 单根K线占用的字节数（用于内存估算）
+var klineSize = int64(unsafe.Sizeof(Kline{}))
+
+// IntervalMemoryStats 单个周期的K线缓存内存统计
+type IntervalMemoryStats struct {
+	Interval       string `json:"interval"`
+	Symbols        int    `json:"symbols"`
+	Klines         int    `json:"klines"`          // 当前已存储的K线数量
+	CapacityKlines int    `json:"capacity_klines"` // 已分配的K线槽位数量
+	Bytes          int64  `json:"bytes"`           // 估算已分配字节数
+}
+
+// MemoryStats K线缓存内存使用情况
+type MemoryStats struct {
+	Intervals  []IntervalMemoryStats `json:"intervals"`
+	TotalBytes int64                 `json:"total_bytes"`
+}
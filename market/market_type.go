@@ -0,0 +1,97 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MarketType 市场类型
+type MarketType string
+
+const (
+	MarketUSDM  MarketType = "usdm"  // U本位永续合约（默认）
+	MarketSpot  MarketType = "spot"  // 现货
+	MarketCoinM MarketType = "coinm" // 币本位合约（dapi，如 BTCUSD_PERP）
+)
+
+// ErrFuturesOnly 数据仅合约市场提供（现货无持仓量、资金费率）
+var ErrFuturesOnly = errors.New("该数据仅合约市场提供")
+
+// ParseMarketType 解析市场类型，空字符串视为U本位合约
+func ParseMarketType(s string) (MarketType, error) {
+	switch MarketType(strings.ToLower(strings.TrimSpace(s))) {
+	case "", MarketUSDM, "futures", "um":
+		return MarketUSDM, nil
+	case MarketSpot:
+		return MarketSpot, nil
+	case MarketCoinM, "cm", "dapi":
+		return MarketCoinM, nil
+	}
+	return "", fmt.Errorf("未知的市场类型: %s（可选 spot|usdm|coinm）", s)
+}
+
+// MarketTypeOf 根据交易对代码推断市场类型（带 _PERP/_YYMMDD 后缀为币本位合约，否则为U本位合约）
+func MarketTypeOf(symbol string) MarketType {
+	if isCoinMSymbol(strings.ToUpper(symbol)) {
+		return MarketCoinM
+	}
+	return MarketUSDM
+}
+
+// NormalizeFor 按市场类型标准化交易对（币本位为 BTCUSD_PERP，其余为 BTCUSDT）
+func NormalizeFor(symbol string, t MarketType) string {
+	if t == MarketCoinM {
+		return coinMSymbol(symbol)
+	}
+	return Normalize(symbol)
+}
+
+// IsFutures 是否为合约市场
+func (t MarketType) IsFutures() bool {
+	return t != MarketSpot
+}
+
+// marketTyper 声明所属市场类型的数据源（未实现时视为U本位合约）
+type marketTyper interface {
+	MarketType() MarketType
+}
+
+// sourceMarketType 数据源所属的市场类型
+func sourceMarketType(src Source) MarketType {
+	if mt, ok := src.(marketTyper); ok {
+		return mt.MarketType()
+	}
+	return MarketUSDM
+}
+
+// SourceForMarket 返回市场类型对应的币安数据源（U本位合约按交易对选择，可能为其他交易所）
+func SourceForMarket(symbol string, t MarketType) (Source, error) {
+	switch t {
+	case "", MarketUSDM:
+		return SourceFor(symbol), nil
+	case MarketSpot:
+		return SourceByName(SourceBinanceSpot)
+	case MarketCoinM:
+		return SourceByName(SourceBinanceCoinM)
+	}
+	return nil, fmt.Errorf("未知的市场类型: %s", t)
+}
+
+// GetWithMarket 按市场类型获取市场数据（现货跳过持仓量、资金费率等合约数据）
+func (m *Monitor) GetWithMarket(symbol string, t MarketType, cfg IndicatorConfig) (*Data, error) {
+	src, err := SourceForMarket(symbol, t)
+	if err != nil {
+		return nil, err
+	}
+	return m.GetWithSource(NormalizeFor(symbol, t), src, cfg)
+}
+
+// GetWithMarket 使用默认监控器（启用回放时为回放源），按市场类型获取市场数据
+func GetWithMarket(symbol string, t MarketType, cfg IndicatorConfig) (*Data, error) {
+	src, err := SourceForMarket(symbol, t)
+	if err != nil {
+		return nil, err
+	}
+	return GetWithSource(NormalizeFor(symbol, t), src, cfg)
+}
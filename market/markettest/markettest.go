@@ -0,0 +1,231 @@
+package markettest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"nofx/market"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 行情回归测试工具：从目录加载录制的币安 REST 原始响应（K线、持仓量、资金费率），
+// 在固定时钟下通过与实盘相同的 GetWithSource 计算指标，再与 golden 文件比对 Format 输出。
+//
+// 目录结构（每个交易对一个目录，目录名即交易对）：
+//
+//	<root>/BTCUSDT/klines_3m.json       /fapi/v1/klines?interval=3m 的响应（15m/1h/4h/1d 同理）
+//	<root>/BTCUSDT/open_interest.json   /fapi/v1/openInterest 的响应（time 字段作为回放时间）
+//	<root>/BTCUSDT/premium_index.json   /fapi/v1/premiumIndex 的响应
+
+// Intervals 录制与加载的K线周期（与 GetWithSource 使用的周期一致）
+var Intervals = []string{"3m", "15m", "1h", "4h", "1d"}
+
+// 各周期录制的K线数量（与 Monitor 的默认缓存容量一致）
+var recordLimits = map[string]int{"3m": 120, "15m": 100, "1h": 100, "4h": 100, "1d": 100}
+
+const (
+	openInterestFile = "open_interest.json"
+	premiumIndexFile = "premium_index.json"
+	fixtureSource    = "fixture"
+	recordBaseURL    = "https://fapi.binance.com"
+)
+
+// Fixture 一个交易对的录制行情
+type Fixture struct {
+	Symbol       string
+	Klines       map[string][]market.Kline
+	OpenInterest float64
+	Funding      *market.FundingData
+	Time         time.Time // 录制时间，计算指标时作为当前时间
+}
+
+// klinesFile K线响应文件名
+func klinesFile(interval string) string {
+	return "klines_" + interval + ".json"
+}
+
+// LoadFixture 加载一个交易对目录
+func LoadFixture(dir string) (*Fixture, error) {
+	f := &Fixture{
+		Symbol: market.Normalize(filepath.Base(dir)),
+		Klines: make(map[string][]market.Kline, len(Intervals)),
+	}
+	for _, interval := range Intervals {
+		body, err := os.ReadFile(filepath.Join(dir, klinesFile(interval)))
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s %s K线失败: %w", f.Symbol, interval, err)
+		}
+		klines, err := market.ParseKlines(body)
+		if err != nil {
+			return nil, fmt.Errorf("解析 %s %s K线失败: %w", f.Symbol, interval, err)
+		}
+		if len(klines) == 0 {
+			return nil, fmt.Errorf("%s %s K线为空", f.Symbol, interval)
+		}
+		f.Klines[interval] = klines
+	}
+
+	body, err := os.ReadFile(filepath.Join(dir, openInterestFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 持仓量失败: %w", f.Symbol, err)
+	}
+	if f.OpenInterest, err = market.ParseOpenInterest(body); err != nil {
+		return nil, fmt.Errorf("解析 %s 持仓量失败: %w", f.Symbol, err)
+	}
+	if f.Time, err = parseResponseTime(body); err != nil {
+		return nil, fmt.Errorf("解析 %s 录制时间失败: %w", f.Symbol, err)
+	}
+
+	body, err = os.ReadFile(filepath.Join(dir, premiumIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 资金费率失败: %w", f.Symbol, err)
+	}
+	if f.Funding, err = market.ParsePremiumIndex(body); err != nil {
+		return nil, fmt.Errorf("解析 %s 资金费率失败: %w", f.Symbol, err)
+	}
+	return f, nil
+}
+
+// LoadFixtures 加载 root 下所有交易对目录（按交易对排序）
+func LoadFixtures(root string) ([]*Fixture, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("读取行情样本目录失败: %w", err)
+	}
+	var fixtures []*Fixture
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		f, err := LoadFixture(filepath.Join(root, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, f)
+	}
+	sort.Slice(fixtures, func(i, j int) bool { return fixtures[i].Symbol < fixtures[j].Symbol })
+	return fixtures, nil
+}
+
+// Source 返回注入了样本数据的内存数据源
+func (f *Fixture) Source() *market.StaticSource {
+	src := market.NewStaticSource(fixtureSource)
+	for interval, klines := range f.Klines {
+		src.SetKlines(f.Symbol, interval, klines)
+	}
+	src.SetOpenInterest(f.Symbol, f.OpenInterest)
+	if f.Funding != nil {
+		src.SetFundingRate(f.Symbol, f.Funding.Current)
+	}
+	return src
+}
+
+// Data 在录制时间下计算市场数据（时钟注入到监控器与数据源，不修改包级时钟，可与其他测试并行）。
+// 持仓量只有一个采样点，OI 变化率为 0；内存数据源只提供当前资金费率，不含标记价格与基差。
+func (f *Fixture) Data(cfg market.IndicatorConfig) (*market.Data, error) {
+	clk := market.NewFakeClock(f.Time)
+	m := market.NewMonitor(market.MonitorConfig{Clock: clk})
+	defer m.Close()
+	return m.GetWithSource(f.Symbol, f.Source().WithClock(clk), cfg)
+}
+
+// Golden 比对 got 与 golden 文件；update 为 true 时改为写入 golden 文件。
+// 不一致时返回的错误包含第一处不同的行。
+func Golden(path string, got []byte, update bool) error {
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, got, 0o644)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取 golden 文件失败（首次运行请使用 update 生成）: %w", err)
+	}
+	if bytes.Equal(want, got) {
+		return nil
+	}
+	return fmt.Errorf("%s 不一致: %s", path, firstDiff(string(want), string(got)))
+}
+
+// firstDiff 描述第一处不同的行
+func firstDiff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("第 %d 行\n  期望: %q\n  实际: %q", i+1, w, g)
+		}
+	}
+	return "行尾不同"
+}
+
+// Record 从币安录制一个交易对的样本到 root/<SYMBOL>（需要网络）
+func Record(root, symbol string) error {
+	symbol = market.Normalize(symbol)
+	dir := filepath.Join(root, symbol)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files := map[string]string{
+		openInterestFile: "/fapi/v1/openInterest?symbol=" + symbol,
+		premiumIndexFile: "/fapi/v1/premiumIndex?symbol=" + symbol,
+	}
+	for _, interval := range Intervals {
+		files[klinesFile(interval)] = fmt.Sprintf("/fapi/v1/klines?symbol=%s&interval=%s&limit=%d", symbol, interval, recordLimits[interval])
+	}
+	for name, path := range files {
+		body, err := fetch(recordBaseURL + path)
+		if err != nil {
+			return fmt.Errorf("录制 %s %s 失败: %w", symbol, name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), body, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetch(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, body)
+	}
+	return body, nil
+}
+
+// parseResponseTime 读取响应中的 time 字段（毫秒时间戳）
+func parseResponseTime(body []byte) (time.Time, error) {
+	var result struct {
+		Time int64 `json:"time"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return time.Time{}, err
+	}
+	if result.Time <= 0 {
+		return time.Time{}, fmt.Errorf("缺少 time 字段")
+	}
+	return time.UnixMilli(result.Time).UTC(), nil
+}
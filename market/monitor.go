@@ -2,30 +2,95 @@ package market
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
-type WSMonitor struct {
-	wsClient       *WSClient
-	combinedClient *CombinedStreamsClient
-	symbols        []string
-	featuresMap    sync.Map
-	alertsChan     chan Alert
-	klineDataMap3m sync.Map // 存储每个交易对的K线历史数据
-	klineDataMap4h sync.Map // 存储每个交易对的K线历史数据
-	tickerDataMap  sync.Map // 存储每个交易对的ticker数据
-    klineDataMap15m sync.Map // 15分钟K线数据
-    klineDataMap1h  sync.Map // 1小时K线数据
-    klineDataMap1d  sync.Map // 1天K线数据
-	batchSize      int
-	filterSymbols  sync.Map // 使用sync.Map来存储需要监控的币种和其状态
-	symbolStats    sync.Map // 存储币种统计信息
-	FilterSymbol   []string //经过筛选的币种
+// defaultMonitorIntervals 默认订阅的K线周期（与 Get 所需周期一致）
+var defaultMonitorIntervals = []string{"3m", "15m", "1h", "4h", "1d"}
+
+// ErrMonitorNotInitialized 未设置默认监控器时 Get 返回的错误
+var ErrMonitorNotInitialized = errors.New("市场数据监控器未初始化，请先调用 NewMonitor 并 SetDefaultMonitor")
+
+// MonitorConfig 行情监控器配置
+type MonitorConfig struct {
+	BatchSize             int                 // 组合流每批订阅的流数量，默认 150
+	Intervals             []string            // 订阅的K线周期，默认 3m/15m/1h/4h/1d
+	RingCapacity          map[string]int      // 按周期覆盖K线环形缓冲容量（未配置的周期使用 klineRingCapacity）
+	ReconnectMinBackoff   time.Duration       // 断线重连初始等待，默认 1s
+	ReconnectMaxBackoff   time.Duration       // 断线重连最大等待，默认 60s
+	MemoryReportInterval  time.Duration       // K线缓存内存报告间隔，默认 30m，<0 表示关闭
+	FlowWindows           []time.Duration     // 强平/主动买卖统计窗口，默认 15m/1h/4h
+	DisableLiquidations   bool                // 不订阅全市场强平流
+	AggTrades             bool                // 为所有监控交易对订阅 aggTrade 实时成交流（默认仅在 TrackPrice/SubscribePrice 时按需订阅）
+	CorrelationWindows    []CorrelationWindow // 相关性/beta 统计窗口，默认 1h×24、4h×42
+	CorrelationBenchmarks []string            // 相关性/beta 基准交易对，默认 BTCUSDT、ETHUSDT
+	KlineSource           KlineSource         // 缓存不足时的K线回补来源，默认币安 REST（测试可注入 StaticSource）
+	AggregateFrom         string              // 基础周期（如 1m/3m）：仅订阅该周期，其余可整除的周期由本地聚合生成；为空时每个周期单独订阅
+	Clock                 Clock               // 计算市场数据时使用的时钟，默认 SetClock 设置的包级时钟（测试可注入 FakeClock）
 }
+
+// withDefaults 填充默认值
+func (c MonitorConfig) withDefaults() MonitorConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 150
+	}
+	if len(c.Intervals) == 0 {
+		c.Intervals = append([]string(nil), defaultMonitorIntervals...)
+	}
+	if c.AggregateFrom != "" && !slices.Contains(c.Intervals, c.AggregateFrom) {
+		c.Intervals = append([]string{c.AggregateFrom}, c.Intervals...)
+	}
+	if c.ReconnectMinBackoff <= 0 {
+		c.ReconnectMinBackoff = time.Second
+	}
+	if c.ReconnectMaxBackoff < c.ReconnectMinBackoff {
+		c.ReconnectMaxBackoff = 60 * time.Second
+	}
+	if c.MemoryReportInterval == 0 {
+		c.MemoryReportInterval = 30 * time.Minute
+	}
+	if len(c.FlowWindows) == 0 {
+		c.FlowWindows = append([]time.Duration(nil), defaultFlowWindows...)
+	}
+	if len(c.CorrelationWindows) == 0 {
+		c.CorrelationWindows = append([]CorrelationWindow(nil), defaultCorrelationWindows...)
+	}
+	if len(c.CorrelationBenchmarks) == 0 {
+		c.CorrelationBenchmarks = append([]string(nil), defaultCorrelationBenchmarks...)
+	}
+	return c
+}
+
+// Monitor 基于币安K线 WebSocket 的行情数据管理器
+// 负责订阅、断线重连（指数退避）、按交易对/周期维护K线环形缓冲区
+type Monitor struct {
+	config           MonitorConfig
+	wsClient         *WSClient
+	combinedClient   *CombinedStreamsClient
+	symbols          []string
+	featuresMap      sync.Map
+	alertsChan       chan Alert
+	klineData        sync.Map // 周期 -> *sync.Map(symbol -> *klineRing)
+	tickerDataMap    sync.Map // 存储每个交易对的ticker数据
+	filterSymbols    sync.Map // 使用sync.Map来存储需要监控的币种和其状态
+	symbolStats      sync.Map // 存储币种统计信息
+	FilterSymbol     []string //经过筛选的币种
+	subscribed       sync.Map // 已注册处理协程的流 stream -> struct{}
+	indicatorEngines sync.Map // "symbol|interval|指标集合" -> *indicatorEngine（增量指标状态）
+	derivedIntervals []string // 由 AggregateFrom 本地聚合的周期
+	aggregates       sync.Map // "symbol|interval" -> *klineAggregate
+	liquidations     *liquidationBook
+	prices           *priceBook
+	closeOnce        sync.Once
+	done             chan struct{}
+}
+
 type SymbolStats struct {
 	LastActiveTime   time.Time
 	AlertCount       int
@@ -34,20 +99,62 @@ type SymbolStats struct {
 	Score            float64 // 综合评分
 }
 
-var WSMonitorCli *WSMonitor
-var subKlineTime = []string{"3m", "4h"} // 管理订阅流的K线周期
+// WSMonitor 兼容旧名称
+type WSMonitor = Monitor
+
+var (
+	defaultMonitorMu sync.RWMutex
+	defaultMonitor   *Monitor
+)
 
-func NewWSMonitor(batchSize int) *WSMonitor {
-	WSMonitorCli = &WSMonitor{
-		wsClient:       NewWSClient(),
-		combinedClient: NewCombinedStreamsClient(batchSize),
-		alertsChan:     make(chan Alert, 1000),
-		batchSize:      batchSize,
+// NewMonitor 创建行情监控器（不会自动启动，需调用 Start）
+func NewMonitor(config MonitorConfig) *Monitor {
+	config = config.withDefaults()
+	retention := time.Duration(0)
+	for _, w := range config.FlowWindows {
+		retention = max(retention, w)
 	}
-	return WSMonitorCli
+	combined := NewCombinedStreamsClient(config.BatchSize)
+	combined.minBackoff = config.ReconnectMinBackoff
+	combined.maxBackoff = config.ReconnectMaxBackoff
+	derived := derivedIntervals(config.AggregateFrom, config.Intervals)
+	if len(derived) > 0 {
+		log.Printf("📐 K线周期 %v 由 %s 本地聚合生成", derived, config.AggregateFrom)
+	}
+	return &Monitor{
+		config:           config,
+		wsClient:         NewWSClient(),
+		combinedClient:   combined,
+		alertsChan:       make(chan Alert, 1000),
+		derivedIntervals: derived,
+		liquidations:     newLiquidationBook(retention),
+		prices:           newPriceBook(),
+		done:             make(chan struct{}),
+	}
+}
+
+// NewWSMonitor 兼容旧接口：创建监控器并设置为默认监控器
+func NewWSMonitor(batchSize int) *Monitor {
+	m := NewMonitor(MonitorConfig{BatchSize: batchSize})
+	SetDefaultMonitor(m)
+	return m
+}
+
+// SetDefaultMonitor 设置包级函数（Get 等）使用的默认监控器
+func SetDefaultMonitor(m *Monitor) {
+	defaultMonitorMu.Lock()
+	defer defaultMonitorMu.Unlock()
+	defaultMonitor = m
+}
+
+// DefaultMonitor 返回默认监控器（可能为 nil）
+func DefaultMonitor() *Monitor {
+	defaultMonitorMu.RLock()
+	defer defaultMonitorMu.RUnlock()
+	return defaultMonitor
 }
 
-func (m *WSMonitor) Initialize(coins []string) error {
+func (m *Monitor) Initialize(coins []string) error {
 	log.Println("初始化WebSocket监控器...")
 	// 获取交易对信息
 	apiClient := NewAPIClient()
@@ -60,7 +167,7 @@ func (m *WSMonitor) Initialize(coins []string) error {
 		// 筛选永续合约交易对 --仅测试时使用
 		//exchangeInfo.Symbols = exchangeInfo.Symbols[0:2]
 		for _, symbol := range exchangeInfo.Symbols {
-			if symbol.Status == "TRADING" && symbol.ContractType == "PERPETUAL" && strings.ToUpper(symbol.Symbol[len(symbol.Symbol)-4:]) == "USDT" {
+			if symbol.Status == "TRADING" && symbol.ContractType == "PERPETUAL" && strings.HasSuffix(strings.ToUpper(symbol.Symbol), "USDT") {
 				m.symbols = append(m.symbols, symbol.Symbol)
 				m.filterSymbols.Store(symbol.Symbol, true)
 			}
@@ -78,7 +185,7 @@ func (m *WSMonitor) Initialize(coins []string) error {
 	return nil
 }
 
-func (m *WSMonitor) initializeHistoricalData() error {
+func (m *Monitor) initializeHistoricalData() error {
 	apiClient := NewAPIClient()
 
 	var wg sync.WaitGroup
@@ -92,57 +199,16 @@ func (m *WSMonitor) initializeHistoricalData() error {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			// 获取历史K线数据
-			klines, err := apiClient.GetKlines(s, "3m", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-				return
-			}
-			if len(klines) > 0 {
-				m.klineDataMap3m.Store(s, klines)
-				log.Printf("已加载 %s 的历史K线数据-3m: %d 条", s, len(klines))
-			}
-
-            // 新增15m数据
-            klines15m, err := apiClient.GetKlines(s, "15m", 100)
-            if err == nil && len(klines15m) > 0 {
-                m.klineDataMap15m.Store(s, klines15m)
-            }
-			if len(klines15m) > 0 {
-				m.klineDataMap15m.Store(s, klines15m)
-				log.Printf("已加载 %s 的历史K线数据-15m: %d 条", s, len(klines15m))
-			}
-
-            // 新增1h数据
-            klines1h, err := apiClient.GetKlines(s, "1h", 100)
-            if err == nil && len(klines1h) > 0 {
-                m.klineDataMap1h.Store(s, klines1h)
-            }
-			if len(klines1h) > 0 {
-				m.klineDataMap1h.Store(s, klines1h)
-				log.Printf("已加载 %s 的历史K线数据-1h: %d 条", s, len(klines1h))
-			}
-
-
-			// 获取历史K线数据
-			klines4h, err := apiClient.GetKlines(s, "4h", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-				return
-			}
-			if len(klines4h) > 0 {
-				m.klineDataMap4h.Store(s, klines4h)
-				log.Printf("已加载 %s 的历史K线数据-4h: %d 条", s, len(klines4h))
-			}
-
-            // 新增1d数据
-            klines1d, err := apiClient.GetKlines(s, "1d", 100)
-            if err == nil && len(klines1d) > 0 {
-                m.klineDataMap1d.Store(s, klines1d)
-            }
-			if len(klines1d) > 0 {
-				m.klineDataMap1d.Store(s, klines1d)
-				log.Printf("已加载 %s 的历史K线数据-1d: %d 条", s, len(klines1d))
+			for _, interval := range m.config.Intervals {
+				klines, err := apiClient.GetKlines(s, interval, m.capacityFor(interval))
+				if err != nil {
+					log.Printf("获取 %s %s 历史数据失败: %v", s, interval, err)
+					continue
+				}
+				if len(klines) > 0 {
+					m.storeKlines(s, interval, klines)
+					log.Printf("已加载 %s 的历史K线数据-%s: %d 条", s, interval, len(klines))
+				}
 			}
 		}(symbol)
 	}
@@ -151,7 +217,7 @@ func (m *WSMonitor) initializeHistoricalData() error {
 	return nil
 }
 
-func (m *WSMonitor) Start(coins []string) {
+func (m *Monitor) Start(coins []string) {
 	log.Printf("启动WebSocket实时监控...")
 	// 初始化交易对
 	err := m.Initialize(coins)
@@ -171,29 +237,48 @@ func (m *WSMonitor) Start(coins []string) {
 		log.Printf("❌ 订阅币种交易对失败: %v", err)
 		return
 	}
+
+	if !m.config.DisableLiquidations {
+		if err := m.subscribeLiquidations(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+
+	if m.config.AggTrades {
+		if err := m.subscribeAggTrades(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+
+	if m.config.MemoryReportInterval > 0 {
+		go m.reportMemoryUsage(m.config.MemoryReportInterval)
+	}
 }
 
-// subscribeSymbol 注册监听
-func (m *WSMonitor) subscribeSymbol(symbol, st string) []string {
+// subscribeSymbol 注册监听（同一流只注册一次处理协程）
+func (m *Monitor) subscribeSymbol(symbol, st string) []string {
 	var streams []string
 	stream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), st)
-	ch := m.combinedClient.AddSubscriber(stream, 100)
 	streams = append(streams, stream)
-	go m.handleKlineData(symbol, ch, st)
+	if _, loaded := m.subscribed.LoadOrStore(stream, struct{}{}); loaded {
+		return streams
+	}
+	ch := m.combinedClient.AddSubscriber(stream, 100)
+	go m.handleKlineData(strings.ToUpper(symbol), ch, st)
 
 	return streams
 }
-func (m *WSMonitor) subscribeAll() error {
+
+func (m *Monitor) subscribeAll() error {
 	// 执行批量订阅
 	log.Println("开始订阅所有交易对...")
 	for _, symbol := range m.symbols {
-		for _, st := range subKlineTime {
+		for _, st := range m.streamIntervals() {
 			m.subscribeSymbol(symbol, st)
 		}
 	}
-    subKlineTime = append(subKlineTime, "15m", "1h", "1d") // 新增时间框架
 
-	for _, st := range subKlineTime {
+	for _, st := range m.streamIntervals() {
 		err := m.combinedClient.BatchSubscribeKlines(m.symbols, st)
 		if err != nil {
 			log.Printf("❌ 订阅 %s K线失败: %v", st, err)
@@ -205,7 +290,7 @@ func (m *WSMonitor) subscribeAll() error {
 	return nil
 }
 
-func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time string) {
+func (m *Monitor) handleKlineData(symbol string, ch <-chan []byte, _time string) {
 	for data := range ch {
 		var klineData KlineWSData
 		if err := json.Unmarshal(data, &klineData); err != nil {
@@ -216,24 +301,32 @@ func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time strin
 	}
 }
 
-// monitor.go
-func (m *WSMonitor) getKlineDataMap(_time string) *sync.Map {
-    switch _time {
-    case "3m":
-        return &m.klineDataMap3m
-    case "15m":
-        return &m.klineDataMap15m
-    case "1h":
-        return &m.klineDataMap1h
-    case "4h":
-        return &m.klineDataMap4h
-    case "1d":
-        return &m.klineDataMap1d
-    default:
-        return &sync.Map{}
-    }
+// getKlineDataMap 获取指定周期的K线缓存（symbol -> *klineRing），不存在时创建
+func (m *Monitor) getKlineDataMap(_time string) *sync.Map {
+	if value, ok := m.klineData.Load(_time); ok {
+		return value.(*sync.Map)
+	}
+	value, _ := m.klineData.LoadOrStore(_time, &sync.Map{})
+	return value.(*sync.Map)
 }
-func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time string) {
+
+// now 返回监控器时钟的当前时间
+func (m *Monitor) now() time.Time {
+	if m.config.Clock != nil {
+		return m.config.Clock.Now()
+	}
+	return clockNow()
+}
+
+// capacityFor 返回指定周期的环形缓冲容量（配置优先）
+func (m *Monitor) capacityFor(_time string) int {
+	if c, ok := m.config.RingCapacity[_time]; ok && c > 0 {
+		return c
+	}
+	return ringCapacityFor(_time)
+}
+
+func (m *Monitor) processKlineUpdate(symbol string, wsData KlineWSData, _time string) {
 	// 转换WebSocket数据为Kline结构
 	kline := Kline{
 		OpenTime:  wsData.Kline.StartTime,
@@ -245,73 +338,122 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 	kline.Low, _ = parseFloat(wsData.Kline.LowPrice)
 	kline.Close, _ = parseFloat(wsData.Kline.ClosePrice)
 	kline.Volume, _ = parseFloat(wsData.Kline.Volume)
-	kline.High, _ = parseFloat(wsData.Kline.HighPrice)
 	kline.QuoteVolume, _ = parseFloat(wsData.Kline.QuoteVolume)
 	kline.TakerBuyBaseVolume, _ = parseFloat(wsData.Kline.TakerBuyBaseVolume)
 	kline.TakerBuyQuoteVolume, _ = parseFloat(wsData.Kline.TakerBuyQuoteVolume)
-	// 更新K线数据
-	var klineDataMap = m.getKlineDataMap(_time)
-	value, exists := klineDataMap.Load(symbol)
-	var klines []Kline
-	if exists {
-		klines = value.([]Kline)
-
-		// 检查是否是新的K线
-		if len(klines) > 0 && klines[len(klines)-1].OpenTime == kline.OpenTime {
-			// 更新当前K线
-			klines[len(klines)-1] = kline
-		} else {
-			// 添加新K线
-			klines = append(klines, kline)
-
-			// 保持数据长度
-			if len(klines) > 100 {
-				klines = klines[1:]
-			}
-		}
-	} else {
-		klines = []Kline{kline}
+	if _time == m.config.AggregateFrom && len(m.derivedIntervals) > 0 {
+		m.aggregateKline(symbol, kline)
 	}
+	// 更新K线数据（环形缓冲区：同一根K线原地更新，新K线覆盖最旧的一根）
+	m.klineRing(symbol, _time).Upsert(kline)
+}
 
-	klineDataMap.Store(symbol, klines)
+// klineRing 获取（不存在时创建）指定交易对与周期的K线环形缓冲区
+func (m *Monitor) klineRing(symbol, _time string) *klineRing {
+	klineDataMap := m.getKlineDataMap(_time)
+	if value, ok := klineDataMap.Load(symbol); ok {
+		return value.(*klineRing)
+	}
+	value, _ := klineDataMap.LoadOrStore(symbol, newKlineRing(m.capacityFor(_time)))
+	return value.(*klineRing)
 }
 
-func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, error) {
-	// 对每一个进来的symbol检测是否存在内类 是否的话就订阅它
+// storeKlines 用整段K线（从旧到新）重置缓存
+func (m *Monitor) storeKlines(symbol, _time string, klines []Kline) {
+	m.klineRing(symbol, _time).Reset(klines)
+}
+
+// SeedKlines 直接写入交易对某周期的K线缓存（从旧到新），用于预热或注入合成K线
+func (m *Monitor) SeedKlines(symbol, interval string, klines []Kline) {
+	m.storeKlines(Normalize(symbol), interval, klines)
+}
+
+// GetCurrentKlines 获取指定交易对与周期的K线（从旧到新的副本）
+// 缓存不存在或不足以预热指标时（新加入的币种、WebSocket尚未追上），透明回退到REST接口
+func (m *Monitor) GetCurrentKlines(symbol string, _time string) ([]Kline, error) {
 	value, exists := m.getKlineDataMap(_time).Load(symbol)
-	if !exists {
-		// 如果Ws数据未初始化完成时,单独使用api获取 - 兼容性代码 (防止在未初始化完成是,已经有交易员运行)
-		apiClient := NewAPIClient()
-		klines, err := apiClient.GetKlines(symbol, _time, 100)
-		if err != nil {
-			return nil, fmt.Errorf("获取%v分钟K线失败: %v", _time, err)
+	if exists {
+		ring := value.(*klineRing)
+		if ring.Len() >= m.warmupFor(_time) {
+			// ✅ FIX: 返回深拷贝而非引用，避免并发竞态条件
+			return ring.Snapshot(), nil
 		}
+	}
 
-		// 动态缓存进缓存
-		m.getKlineDataMap(_time).Store(strings.ToUpper(symbol), klines)
+	// 如果Ws数据未初始化完成时,单独使用api获取 - 兼容性代码 (防止在未初始化完成是,已经有交易员运行)
+	klines, err := m.backfillFromREST(symbol, _time)
+	if err != nil {
+		// 回补失败（或冷却中）但已有部分缓存时，先使用缓存数据
+		if exists {
+			if cached := value.(*klineRing).Snapshot(); len(cached) > 0 {
+				return cached, nil
+			}
+		}
+		return nil, fmt.Errorf("获取%v分钟K线失败: %w", _time, err)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("获取%v分钟K线失败: %w", _time, ErrNoKlines)
+	}
 
-		// 订阅 WebSocket 流
-		subStr := m.subscribeSymbol(symbol, _time)
+	if !exists {
+		// 订阅 WebSocket 流（派生周期订阅基础周期）
+		subStr := m.subscribeSymbol(symbol, m.streamIntervalFor(_time))
 		subErr := m.combinedClient.subscribeStreams(subStr)
 		log.Printf("动态订阅流: %v", subStr)
 		if subErr != nil {
 			log.Printf("警告: 动态订阅%v分钟K线失败: %v (使用API数据)", _time, subErr)
 		}
+	}
+	return klines, nil
+}
 
-		// ✅ FIX: 返回深拷贝而非引用
-		result := make([]Kline, len(klines))
-		copy(result, klines)
-		return result, nil
+// MemoryUsage 统计K线缓存的内存使用情况（按周期汇总）
+func (m *Monitor) MemoryUsage() MemoryStats {
+	var stats MemoryStats
+	for _, interval := range m.config.Intervals {
+		item := IntervalMemoryStats{Interval: interval}
+		m.getKlineDataMap(interval).Range(func(_, value interface{}) bool {
+			ring := value.(*klineRing)
+			item.Symbols++
+			item.Klines += ring.Len()
+			item.CapacityKlines += ring.Cap()
+			return true
+		})
+		item.Bytes = int64(item.CapacityKlines) * klineSize
+		stats.TotalBytes += item.Bytes
+		stats.Intervals = append(stats.Intervals, item)
 	}
+	return stats
+}
 
-	// ✅ FIX: 返回深拷贝而非引用，避免并发竞态条件
-	klines := value.([]Kline)
-	result := make([]Kline, len(klines))
-	copy(result, klines)
-	return result, nil
+// reportMemoryUsage 定期打印K线缓存内存使用情况，便于观察长时间运行的内存变化
+func (m *Monitor) reportMemoryUsage(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+		stats := m.MemoryUsage()
+		for _, item := range stats.Intervals {
+			log.Printf("📊 K线缓存[%s]: 币种=%d, K线=%d/%d, 约 %.2f KB", item.Interval, item.Symbols, item.Klines, item.CapacityKlines, float64(item.Bytes)/1024)
+		}
+		log.Printf("📊 K线缓存合计约 %.2f MB", float64(stats.TotalBytes)/1024/1024)
+	}
 }
 
-func (m *WSMonitor) Close() {
-	m.wsClient.Close()
-	close(m.alertsChan)
+// Close 关闭所有 WebSocket 连接并停止后台协程（可重复调用）
+func (m *Monitor) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.combinedClient.Close()
+		m.wsClient.Close()
+		m.prices.close()
+		close(m.alertsChan)
+		if DefaultMonitor() == m {
+			SetDefaultMonitor(nil)
+		}
+	})
 }
@@ -0,0 +1,79 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// oiHistBaseURL 币安合约数据接口（openInterestHist 仅提供最近30天）
+const oiHistBaseURL = "https://fapi.binance.com/futures/data/openInterestHist"
+
+// oiBackfillPeriods 回补使用的周期与条数：5m 覆盖1天，15m 覆盖2天，1h 覆盖约20天
+var oiBackfillPeriods = []struct {
+	period string
+	limit  int
+}{
+	{"5m", 288},
+	{"15m", 192},
+	{"1h", 500},
+}
+
+// oiBackfilled 已回补过的交易对（每个进程每个交易对只回补一次）
+var oiBackfilled sync.Map
+
+// ensureOIBackfill 首次请求某交易对时，通过 openInterestHist 回补历史序列
+// 使 OI 变化率与 TrendScore 立即可用，而不必等待本地慢慢采样
+func ensureOIBackfill(symbol string) {
+	if _, loaded := oiBackfilled.LoadOrStore(symbol, struct{}{}); loaded {
+		return
+	}
+	var samples []OISample
+	for _, p := range oiBackfillPeriods {
+		s, err := fetchOpenInterestHist(symbol, p.period, p.limit)
+		if err != nil {
+			log.Printf("⚠️  回补 %s OI历史(%s)失败: %v", symbol, p.period, err)
+			continue
+		}
+		samples = append(samples, s...)
+	}
+	if len(samples) == 0 {
+		// 全部失败时允许下次重试
+		oiBackfilled.Delete(symbol)
+		return
+	}
+	if added := mergeOISamples(symbol, samples, clockNow()); added > 0 {
+		log.Printf("📈 %s 已通过 openInterestHist 回补 %d 个OI采样", symbol, added)
+	}
+}
+
+// fetchOpenInterestHist 获取合约持仓量历史
+func fetchOpenInterestHist(symbol, period string, limit int) ([]OISample, error) {
+	url := fmt.Sprintf("%s?symbol=%s&period=%s&limit=%d", oiHistBaseURL, symbol, period, limit)
+	body, err := binanceGet(url, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Symbol          string `json:"symbol"`
+		SumOpenInterest string `json:"sumOpenInterest"`
+		Timestamp       int64  `json:"timestamp"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, err
+	}
+
+	samples := make([]OISample, 0, len(rows))
+	for _, r := range rows {
+		oi, err := strconv.ParseFloat(r.SumOpenInterest, 64)
+		if err != nil || oi <= 0 {
+			continue
+		}
+		samples = append(samples, OISample{Time: time.UnixMilli(r.Timestamp), OpenInterest: oi})
+	}
+	return samples, nil
+}
@@ -0,0 +1,202 @@
+package market
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultOISeriesPoints 未配置周期的序列最多输出点数
+const defaultOISeriesPoints = 300
+
+// oiSeriesIntervals OIData 中输出的序列周期
+var oiSeriesIntervals = []string{"5m", "15m", "1h", "4h", "1d"}
+
+// OISeriesPolicy OI 历史的保留与降采样策略
+type OISeriesPolicy struct {
+	// Retention 各周期序列最多输出的点数（5m/15m/1h/4h/1d），未配置的周期使用 300
+	Retention map[string]int
+	// History 内存中保留/启动时从 OIStore 加载的历史长度，默认 30 天
+	History time.Duration
+}
+
+// defaultOISeriesPolicy 默认策略
+func defaultOISeriesPolicy() OISeriesPolicy {
+	return OISeriesPolicy{History: 30 * 24 * time.Hour}
+}
+
+// pointsFor 返回周期的最多输出点数
+func (p OISeriesPolicy) pointsFor(interval string) int {
+	if n, ok := p.Retention[interval]; ok && n > 0 {
+		return n
+	}
+	return defaultOISeriesPoints
+}
+
+var (
+	oiSeriesPolicyMu sync.RWMutex
+	oiSeriesPolicy   = defaultOISeriesPolicy()
+)
+
+// SetOISeriesPolicy 设置 OI 序列保留策略（History<=0 时使用默认 30 天）
+func SetOISeriesPolicy(p OISeriesPolicy) error {
+	for interval, n := range p.Retention {
+		if _, err := intervalDuration(interval); err != nil {
+			return fmt.Errorf("无效的OI序列周期 %q: %w", interval, err)
+		}
+		if n < 0 {
+			return fmt.Errorf("OI序列周期 %s 的保留点数不能为负: %d", interval, n)
+		}
+	}
+	if p.History <= 0 {
+		p.History = defaultOISeriesPolicy().History
+	}
+	retention := make(map[string]int, len(p.Retention))
+	for k, v := range p.Retention {
+		retention[k] = v
+	}
+	p.Retention = retention
+
+	oiSeriesPolicyMu.Lock()
+	defer oiSeriesPolicyMu.Unlock()
+	oiSeriesPolicy = p
+	return nil
+}
+
+// ParseOISeriesRetention 解析 "5m=288,1h=168" 形式的各周期保留点数
+func ParseOISeriesRetention(spec string) (map[string]int, error) {
+	retention := make(map[string]int)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		interval, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的OI保留配置 %q，应为 周期=点数", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("无效的OI保留点数 %q: %w", item, err)
+		}
+		retention[strings.TrimSpace(interval)] = n
+	}
+	return retention, nil
+}
+
+// currentOISeriesPolicy 返回当前 OI 序列保留策略
+func currentOISeriesPolicy() OISeriesPolicy {
+	oiSeriesPolicyMu.RLock()
+	defer oiSeriesPolicyMu.RUnlock()
+	return oiSeriesPolicy
+}
+
+// OIPoint 降采样后的一个序列点
+type OIPoint struct {
+	Time         time.Time `json:"time"` // 桶起始时间
+	OpenInterest float64   `json:"open_interest"`
+	Filled       bool      `json:"filled,omitempty"` // 桶内无采样，沿用上一个桶的值
+}
+
+// downsampleOI 按时间对齐的桶降采样（取每个桶内最后一次采样）
+// 空桶沿用上一个桶的值，保证相邻点间隔恒为 interval，变化率不会因采样中断而跨越多个周期
+// 只输出截至 end 所在桶的最近 points 个点
+func downsampleOI(samples []OISample, interval time.Duration, points int, end time.Time) []OIPoint {
+	if len(samples) == 0 || interval <= 0 || points <= 0 {
+		return nil
+	}
+	last := end.Truncate(interval)
+	first := last.Add(-time.Duration(points-1) * interval)
+
+	// 起始桶之前最近的一次采样作为初始值（用于填充开头的空桶）
+	idx := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(first) })
+	var out []OIPoint
+	var prev *OIPoint
+	if idx > 0 {
+		prev = &OIPoint{OpenInterest: samples[idx-1].OpenInterest}
+	}
+	for bucket := first; !bucket.After(last); bucket = bucket.Add(interval) {
+		next := bucket.Add(interval)
+		point := OIPoint{Time: bucket}
+		found := false
+		for idx < len(samples) && samples[idx].Time.Before(next) {
+			point.OpenInterest = samples[idx].OpenInterest
+			found = true
+			idx++
+		}
+		if !found {
+			if prev == nil {
+				continue
+			}
+			point.OpenInterest, point.Filled = prev.OpenInterest, true
+		}
+		out = append(out, point)
+		prev = &out[len(out)-1]
+	}
+	return out
+}
+
+// bucketOISeries 按周期降采样并返回数值序列（按当前策略的保留点数）
+func bucketOISeries(samples []OISample, interval string, end time.Time) []float64 {
+	d, err := intervalDuration(interval)
+	if err != nil {
+		return nil
+	}
+	points := downsampleOI(samples, d, currentOISeriesPolicy().pointsFor(interval), end)
+	if len(points) == 0 {
+		return nil
+	}
+	series := make([]float64, len(points))
+	for i, p := range points {
+		series[i] = p.OpenInterest
+	}
+	return series
+}
+
+// OISeriesExport 交易对 OI 缓存的导出（用于诊断）
+type OISeriesExport struct {
+	Symbol  string               `json:"symbol"`
+	Samples []OISample           `json:"samples"` // 内存中的原始采样（5分钟粒度）
+	Series  map[string][]OIPoint `json:"series"`  // 各周期降采样序列
+}
+
+// ExportOISeries 导出交易对内存中的 OI 采样与各周期降采样序列（尚无采样时 ok 为 false）
+func ExportOISeries(symbol string) (*OISeriesExport, bool) {
+	symbol = Normalize(symbol)
+	oiHistoryCache.mu.Lock()
+	h, ok := oiHistoryCache.data[symbol]
+	var samples []OISample
+	if ok {
+		samples = append(samples, h.samples...)
+	}
+	oiHistoryCache.mu.Unlock()
+	if len(samples) == 0 {
+		return nil, false
+	}
+
+	policy := currentOISeriesPolicy()
+	end := samples[len(samples)-1].Time
+	export := &OISeriesExport{Symbol: symbol, Samples: samples, Series: make(map[string][]OIPoint, len(oiSeriesIntervals))}
+	for _, interval := range oiSeriesIntervals {
+		d, _ := intervalDuration(interval)
+		export.Series[interval] = downsampleOI(samples, d, policy.pointsFor(interval), end)
+	}
+	return export, true
+}
+
+// OISeriesSymbols 返回内存中已有 OI 采样的交易对
+func OISeriesSymbols() []string {
+	oiHistoryCache.mu.Lock()
+	defer oiHistoryCache.mu.Unlock()
+	symbols := make([]string, 0, len(oiHistoryCache.data))
+	for symbol, h := range oiHistoryCache.data {
+		if len(h.samples) > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
@@ -0,0 +1,270 @@
+package market
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// oiSampleResolution OI 历史的采样粒度：同一5分钟内只保留最新一次采样
+// 内存保留时长与各周期输出点数见 OISeriesPolicy
+const oiSampleResolution = 5 * time.Minute
+
+// oiPruneInterval 清理存储中超出保留期采样的最小间隔
+const oiPruneInterval = time.Hour
+
+// OISample 一次持仓量采样
+type OISample struct {
+	Time         time.Time
+	OpenInterest float64
+}
+
+// OIStore 持仓量历史存储（可替换为其他实现）
+type OIStore interface {
+	// Save 保存采样（同一交易对同一时间覆盖写入；多条采样在一次事务中写入）
+	Save(symbol string, samples ...OISample) error
+	// Load 读取 since 之后的采样（按时间升序）
+	Load(symbol string, since time.Time) ([]OISample, error)
+	// Prune 删除所有交易对 before 之前的采样，返回删除数量
+	Prune(before time.Time) (int64, error)
+	Close() error
+}
+
+// SQLiteOIStore 基于 SQLite 的持仓量历史存储
+type SQLiteOIStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteOIStore 打开（不存在则创建）OI 历史数据库
+func NewSQLiteOIStore(path string) (*SQLiteOIStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开OI历史数据库失败: %w", err)
+	}
+	_, _ = db.Exec("PRAGMA journal_mode=WAL")
+	_, _ = db.Exec("PRAGMA busy_timeout=5000")
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS oi_history (
+		symbol TEXT NOT NULL,
+		ts INTEGER NOT NULL,
+		open_interest REAL NOT NULL,
+		PRIMARY KEY (symbol, ts)
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建OI历史表失败: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_oi_history_ts ON oi_history(ts)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建OI历史索引失败: %w", err)
+	}
+	return &SQLiteOIStore{db: db}, nil
+}
+
+// Save 保存采样（回补时一次写入上千条，逐条提交过慢，统一在一个事务中写入）
+func (s *SQLiteOIStore) Save(symbol string, samples ...OISample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO oi_history (symbol, ts, open_interest) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, sample := range samples {
+		if _, err := stmt.Exec(symbol, sample.Time.UnixMilli(), sample.OpenInterest); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Load 读取 since 之后的采样
+func (s *SQLiteOIStore) Load(symbol string, since time.Time) ([]OISample, error) {
+	rows, err := s.db.Query(`SELECT ts, open_interest FROM oi_history WHERE symbol = ? AND ts >= ? ORDER BY ts`,
+		symbol, since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var samples []OISample
+	for rows.Next() {
+		var ts int64
+		var oi float64
+		if err := rows.Scan(&ts, &oi); err != nil {
+			return nil, err
+		}
+		samples = append(samples, OISample{Time: time.UnixMilli(ts), OpenInterest: oi})
+	}
+	return samples, rows.Err()
+}
+
+// Prune 删除 before 之前的采样
+func (s *SQLiteOIStore) Prune(before time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM oi_history WHERE ts < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Close 关闭数据库
+func (s *SQLiteOIStore) Close() error {
+	return s.db.Close()
+}
+
+var (
+	oiStoreMu sync.RWMutex
+	oiStore   OIStore
+
+	oiPruneMu   sync.Mutex
+	oiLastPrune time.Time
+)
+
+// SetOIStore 设置持仓量历史存储（nil 表示仅使用内存，重启后历史丢失）
+func SetOIStore(store OIStore) {
+	oiStoreMu.Lock()
+	defer oiStoreMu.Unlock()
+	oiStore = store
+}
+
+func currentOIStore() OIStore {
+	oiStoreMu.RLock()
+	defer oiStoreMu.RUnlock()
+	return oiStore
+}
+
+// pruneOIStore 按 OISeriesPolicy.History 删除存储中过期的采样（内存裁剪见 recordOISample），每 oiPruneInterval 最多执行一次
+func pruneOIStore(store OIStore, now time.Time) {
+	oiPruneMu.Lock()
+	if !oiLastPrune.IsZero() && now.Sub(oiLastPrune) < oiPruneInterval {
+		oiPruneMu.Unlock()
+		return
+	}
+	oiLastPrune = now
+	oiPruneMu.Unlock()
+
+	cutoff := now.Add(-currentOISeriesPolicy().History)
+	n, err := store.Prune(cutoff)
+	if err != nil {
+		log.Printf("⚠️  清理过期OI历史失败: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("🧹 已清理 %d 条 %s 之前的OI历史", n, cutoff.Format("2006-01-02 15:04"))
+	}
+}
+
+// oiHistory 单个交易对的持仓量历史（按 oiSampleResolution 去重，升序）
+type oiHistory struct {
+	samples []OISample
+}
+
+var oiHistoryCache = struct {
+	mu   sync.Mutex
+	data map[string]*oiHistory
+}{data: make(map[string]*oiHistory)}
+
+// ensureOIHistory 首次访问交易对时从存储加载历史
+// 存储读写不持有 oiHistoryCache.mu（全局锁，避免一个交易对的磁盘 I/O 阻塞其他交易对）；
+// 并发加载同一交易对时以先写入缓存的为准
+func ensureOIHistory(symbol string, store OIStore, now time.Time) {
+	oiHistoryCache.mu.Lock()
+	_, ok := oiHistoryCache.data[symbol]
+	oiHistoryCache.mu.Unlock()
+	if ok {
+		return
+	}
+	var samples []OISample
+	if store != nil {
+		loaded, err := store.Load(symbol, now.Add(-currentOISeriesPolicy().History))
+		if err != nil {
+			log.Printf("⚠️  加载 %s OI历史失败: %v", symbol, err)
+		} else {
+			samples = loaded
+		}
+	}
+	oiHistoryCache.mu.Lock()
+	if _, ok := oiHistoryCache.data[symbol]; !ok {
+		oiHistoryCache.data[symbol] = &oiHistory{samples: samples}
+	}
+	oiHistoryCache.mu.Unlock()
+}
+
+// recordOISample 记录一次采样并返回该交易对的历史副本
+// 首次访问时从存储加载历史，因此重启后变化率仍基于真实历史计算
+func recordOISample(symbol string, oi float64, now time.Time) []OISample {
+	store := currentOIStore()
+	ensureOIHistory(symbol, store, now)
+
+	oiHistoryCache.mu.Lock()
+	h := oiHistoryCache.data[symbol]
+	sample := OISample{Time: now.Truncate(oiSampleResolution), OpenInterest: oi}
+	if n := len(h.samples); n > 0 && !h.samples[n-1].Time.Before(sample.Time) {
+		h.samples[n-1].OpenInterest = oi
+	} else {
+		h.samples = append(h.samples, sample)
+	}
+
+	// 裁剪超出保留期的采样
+	cutoff := now.Add(-currentOISeriesPolicy().History)
+	idx := sort.Search(len(h.samples), func(i int) bool { return !h.samples[i].Time.Before(cutoff) })
+	if idx > 0 {
+		h.samples = append([]OISample(nil), h.samples[idx:]...)
+	}
+	history := append([]OISample(nil), h.samples...)
+	oiHistoryCache.mu.Unlock()
+
+	if store != nil {
+		if err := store.Save(symbol, sample); err != nil {
+			log.Printf("⚠️  保存 %s OI采样失败: %v", symbol, err)
+		}
+		pruneOIStore(store, now)
+	}
+	return history
+}
+
+// mergeOISamples 将回补的采样并入历史（同一采样时间已有本地数据时保留本地数据），返回新增数量
+func mergeOISamples(symbol string, backfill []OISample, now time.Time) int {
+	store := currentOIStore()
+	ensureOIHistory(symbol, store, now)
+
+	oiHistoryCache.mu.Lock()
+	h := oiHistoryCache.data[symbol]
+	existing := make(map[int64]bool, len(h.samples))
+	for _, s := range h.samples {
+		existing[s.Time.UnixMilli()] = true
+	}
+	cutoff := now.Add(-currentOISeriesPolicy().History)
+	var added []OISample
+	for _, s := range backfill {
+		s.Time = s.Time.Truncate(oiSampleResolution)
+		if s.Time.Before(cutoff) || existing[s.Time.UnixMilli()] {
+			continue
+		}
+		existing[s.Time.UnixMilli()] = true
+		added = append(added, s)
+	}
+	if len(added) == 0 {
+		oiHistoryCache.mu.Unlock()
+		return 0
+	}
+	h.samples = append(h.samples, added...)
+	sort.Slice(h.samples, func(i, j int) bool { return h.samples[i].Time.Before(h.samples[j].Time) })
+	oiHistoryCache.mu.Unlock()
+
+	if store != nil {
+		if err := store.Save(symbol, added...); err != nil {
+			log.Printf("⚠️  保存 %s OI回补采样失败: %v", symbol, err)
+		}
+	}
+	return len(added)
+}
@@ -0,0 +1,203 @@
+package market
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OI 趋势评分方法（OIData.TrendMethod 记录实际使用的方法）
+const (
+	// OITrendAverage 各周期最新变化率的简单平均（默认）
+	OITrendAverage = "average"
+	// OITrendWeighted 各周期最新变化率按权重加权平均，只统计已有变化率的周期
+	OITrendWeighted = "weighted"
+	// OITrendRegression 指定周期序列最近 N 点的线性回归斜率除以这段时间的平均持仓量（每个周期的相对变化）
+	OITrendRegression = "regression"
+)
+
+// defaultOITrendWeights 加权评分的默认权重：长周期的持仓变化更能反映资金趋势
+var defaultOITrendWeights = map[string]float64{"5m": 1, "15m": 1, "1h": 2, "4h": 3, "1d": 3}
+
+// OITrendPolicy OI 趋势评分方法
+type OITrendPolicy struct {
+	Method string
+	// Weights weighted 方法的各周期权重（5m/15m/1h/4h/1d），未配置时使用 defaultOITrendWeights
+	Weights map[string]float64
+	// Interval、Points regression 方法使用的序列周期与点数，默认 1h、24 点
+	Interval string
+	Points   int
+}
+
+// defaultOITrendPolicy 默认策略（保持原有的简单平均）
+func defaultOITrendPolicy() OITrendPolicy {
+	return OITrendPolicy{Method: OITrendAverage}
+}
+
+// withDefaults 补全未配置的参数
+func (p OITrendPolicy) withDefaults() OITrendPolicy {
+	if p.Method == "" {
+		p.Method = OITrendAverage
+	}
+	if len(p.Weights) == 0 {
+		p.Weights = defaultOITrendWeights
+	}
+	if p.Interval == "" {
+		p.Interval = "1h"
+	}
+	if p.Points <= 0 {
+		p.Points = 24
+	}
+	return p
+}
+
+var (
+	oiTrendPolicyMu sync.RWMutex
+	oiTrendPolicy   = defaultOITrendPolicy()
+)
+
+// SetOITrendPolicy 设置 OI 趋势评分方法
+func SetOITrendPolicy(p OITrendPolicy) error {
+	switch p.Method {
+	case "", OITrendAverage, OITrendWeighted, OITrendRegression:
+	default:
+		return fmt.Errorf("未知的OI趋势评分方法: %s", p.Method)
+	}
+	for interval, w := range p.Weights {
+		if !isOISeriesInterval(interval) {
+			return fmt.Errorf("无效的OI趋势权重周期: %s", interval)
+		}
+		if w < 0 {
+			return fmt.Errorf("OI趋势权重不能为负: %s=%v", interval, w)
+		}
+	}
+	if p.Interval != "" && !isOISeriesInterval(p.Interval) {
+		return fmt.Errorf("无效的OI回归周期: %s", p.Interval)
+	}
+	if p.Points < 0 || (p.Points > 0 && p.Points < 3) {
+		return fmt.Errorf("OI回归至少需要 3 个点: %d", p.Points)
+	}
+	weights := make(map[string]float64, len(p.Weights))
+	for k, v := range p.Weights {
+		weights[k] = v
+	}
+	p.Weights = weights
+
+	oiTrendPolicyMu.Lock()
+	defer oiTrendPolicyMu.Unlock()
+	oiTrendPolicy = p
+	return nil
+}
+
+// ParseOITrendPolicy 解析评分方法配置：
+//
+//	average
+//	weighted 或 weighted:5m=1,15m=1,1h=2,4h=3,1d=3
+//	regression 或 regression:1h=24（周期=点数）
+func ParseOITrendPolicy(spec string) (OITrendPolicy, error) {
+	method, params, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	p := OITrendPolicy{Method: strings.ToLower(strings.TrimSpace(method))}
+	if strings.TrimSpace(params) == "" {
+		return p, nil
+	}
+	switch p.Method {
+	case OITrendWeighted:
+		p.Weights = make(map[string]float64)
+		for _, item := range strings.Split(params, ",") {
+			interval, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok {
+				return p, fmt.Errorf("无效的OI趋势权重 %q，应为 周期=权重", item)
+			}
+			w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return p, fmt.Errorf("无效的OI趋势权重 %q: %w", item, err)
+			}
+			p.Weights[strings.TrimSpace(interval)] = w
+		}
+	case OITrendRegression:
+		interval, value, ok := strings.Cut(strings.TrimSpace(params), "=")
+		if !ok {
+			return p, fmt.Errorf("无效的OI回归配置 %q，应为 周期=点数", params)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return p, fmt.Errorf("无效的OI回归点数 %q: %w", params, err)
+		}
+		p.Interval, p.Points = strings.TrimSpace(interval), n
+	default:
+		return p, fmt.Errorf("OI趋势评分方法 %s 不接受参数", p.Method)
+	}
+	return p, nil
+}
+
+// currentOITrendPolicy 返回当前评分方法（已补全默认参数）
+func currentOITrendPolicy() OITrendPolicy {
+	oiTrendPolicyMu.RLock()
+	defer oiTrendPolicyMu.RUnlock()
+	return oiTrendPolicy.withDefaults()
+}
+
+// isOISeriesInterval 是否为 OIData 输出的序列周期
+func isOISeriesInterval(interval string) bool {
+	for _, iv := range oiSeriesIntervals {
+		if iv == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// oiTrendScore 按策略计算趋势评分；changes 与 series 以周期为键
+func oiTrendScore(p OITrendPolicy, changes map[string]float64, series map[string][]float64) float64 {
+	switch p.Method {
+	case OITrendWeighted:
+		sum, weights := 0.0, 0.0
+		for _, interval := range oiSeriesIntervals {
+			w := p.Weights[interval]
+			if w <= 0 || len(series[interval]) < 2 {
+				continue
+			}
+			sum += w * changes[interval]
+			weights += w
+		}
+		if weights == 0 {
+			return 0
+		}
+		return sum / weights
+	case OITrendRegression:
+		values := series[p.Interval]
+		if len(values) > p.Points {
+			values = values[len(values)-p.Points:]
+		}
+		return normalizedSlope(values)
+	}
+	sum := 0.0
+	for _, interval := range oiSeriesIntervals {
+		sum += changes[interval]
+	}
+	return sum / float64(len(oiSeriesIntervals))
+}
+
+// normalizedSlope 最小二乘斜率除以均值（少于 3 点或均值为 0 时返回 0）
+func normalizedSlope(values []float64) float64 {
+	n := float64(len(values))
+	if len(values) < 3 {
+		return 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	mean := sumY / n
+	if denom == 0 || mean == 0 {
+		return 0
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	return slope / mean
+}
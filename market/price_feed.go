@@ -0,0 +1,255 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// priceStaleAfter 最新成交价超过该时长未更新时 LastPrice 回退到K线价格
+const priceStaleAfter = time.Minute
+
+// PriceTick 一笔归集成交（aggTrade）对应的实时价格
+type PriceTick struct {
+	Symbol     string    `json:"symbol"`
+	Price      float64   `json:"price"`
+	Quantity   float64   `json:"quantity"`
+	BuyerMaker bool      `json:"buyer_maker"` // true 表示主动卖出成交
+	Time       time.Time `json:"time"`
+}
+
+// aggTradeWSData aggTrade 推送数据
+type aggTradeWSData struct {
+	EventType  string `json:"e"`
+	EventTime  int64  `json:"E"`
+	Symbol     string `json:"s"`
+	Price      string `json:"p"`
+	Quantity   string `json:"q"`
+	TradeTime  int64  `json:"T"`
+	BuyerMaker bool   `json:"m"`
+}
+
+// priceSubscriber 一个价格订阅者（symbol 为空表示接收所有交易对）
+type priceSubscriber struct {
+	symbol string
+	ch     chan PriceTick
+}
+
+// priceBook 维护各交易对最新成交价并分发给订阅者
+type priceBook struct {
+	mu     sync.RWMutex
+	last   map[string]PriceTick
+	subs   map[int]*priceSubscriber
+	nextID int
+	closed bool
+}
+
+func newPriceBook() *priceBook {
+	return &priceBook{
+		last: make(map[string]PriceTick),
+		subs: make(map[int]*priceSubscriber),
+	}
+}
+
+// publish 更新最新价并推送给订阅者
+// 订阅者通道已满时丢弃最旧的一条，保证消费者总能拿到最新价格
+func (b *priceBook) publish(tick PriceTick) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	if prev, ok := b.last[tick.Symbol]; ok && tick.Time.Before(prev.Time) {
+		return
+	}
+	b.last[tick.Symbol] = tick
+	for _, sub := range b.subs {
+		if sub.symbol != "" && sub.symbol != tick.Symbol {
+			continue
+		}
+		select {
+		case sub.ch <- tick:
+		default:
+			select {
+			case <-sub.ch:
+			default:
+			}
+			select {
+			case sub.ch <- tick:
+			default:
+			}
+		}
+	}
+}
+
+// lastTick 返回最新成交
+func (b *priceBook) lastTick(symbol string) (PriceTick, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	tick, ok := b.last[symbol]
+	return tick, ok
+}
+
+// subscribe 注册订阅者，返回通道与取消函数（取消后通道被关闭）
+func (b *priceBook) subscribe(symbol string, buffer int) (<-chan PriceTick, func()) {
+	if buffer <= 0 {
+		buffer = 64
+	}
+	ch := make(chan PriceTick, buffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = &priceSubscriber{symbol: symbol, ch: ch}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if sub, ok := b.subs[id]; ok {
+				delete(b.subs, id)
+				close(sub.ch)
+			}
+		})
+	}
+	return ch, cancel
+}
+
+// close 关闭所有订阅者通道
+func (b *priceBook) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for id, sub := range b.subs {
+		close(sub.ch)
+		delete(b.subs, id)
+	}
+}
+
+// TrackPrice 订阅交易对的 aggTrade 流（同一交易对只订阅一次）
+func (m *Monitor) TrackPrice(symbol string) error {
+	symbol = Normalize(symbol)
+	stream := strings.ToLower(symbol) + "@aggTrade"
+	if _, loaded := m.subscribed.LoadOrStore(stream, struct{}{}); loaded {
+		return nil
+	}
+	ch := m.combinedClient.AddSubscriber(stream, 1000)
+	go m.handleAggTrades(symbol, ch)
+	// 未连接时流已被记录，重连后会重新订阅
+	if err := m.combinedClient.subscribeStreams([]string{stream}); err != nil {
+		return fmt.Errorf("订阅 %s 实时成交流失败: %w", symbol, err)
+	}
+	return nil
+}
+
+// handleAggTrades 处理 aggTrade 推送
+func (m *Monitor) handleAggTrades(symbol string, ch <-chan []byte) {
+	for data := range ch {
+		var ev aggTradeWSData
+		if err := json.Unmarshal(data, &ev); err != nil {
+			log.Printf("解析实时成交数据失败: %v", err)
+			continue
+		}
+		price, _ := parseFloat(ev.Price)
+		if price <= 0 {
+			continue
+		}
+		qty, _ := parseFloat(ev.Quantity)
+		t := time.UnixMilli(ev.TradeTime)
+		if ev.TradeTime == 0 {
+			t = time.UnixMilli(ev.EventTime)
+		}
+		m.prices.publish(PriceTick{
+			Symbol:     symbol,
+			Price:      price,
+			Quantity:   qty,
+			BuyerMaker: ev.BuyerMaker,
+			Time:       t,
+		})
+	}
+}
+
+// subscribeAggTrades 为所有监控交易对订阅 aggTrade 流
+func (m *Monitor) subscribeAggTrades() error {
+	for _, symbol := range m.symbols {
+		if err := m.TrackPrice(symbol); err != nil {
+			return err
+		}
+	}
+	log.Printf("已订阅 %d 个交易对的实时成交流", len(m.symbols))
+	return nil
+}
+
+// LastTick 返回交易对最近一笔成交（未订阅或尚无成交时 ok 为 false）
+func (m *Monitor) LastTick(symbol string) (PriceTick, bool) {
+	return m.prices.lastTick(Normalize(symbol))
+}
+
+// LastPrice 返回交易对的实时成交价
+// 尚无成交或超过 priceStaleAfter 未更新时回退到最细周期K线的最新收盘价（未收盘K线的当前价）
+func (m *Monitor) LastPrice(symbol string) (float64, bool) {
+	symbol = Normalize(symbol)
+	if tick, ok := m.prices.lastTick(symbol); ok && clockNow().Sub(tick.Time) <= priceStaleAfter {
+		return tick.Price, true
+	}
+	if len(m.config.Intervals) == 0 {
+		return 0, false
+	}
+	value, ok := m.getKlineDataMap(m.config.Intervals[0]).Load(symbol)
+	if !ok {
+		return 0, false
+	}
+	klines := value.(*klineRing).Snapshot()
+	if len(klines) == 0 {
+		return 0, false
+	}
+	return klines[len(klines)-1].Close, true
+}
+
+// SubscribePrice 订阅交易对的逐笔价格更新（symbol 为空时接收所有已订阅交易对）
+// 指定交易对时会按需订阅其 aggTrade 流；调用返回的 cancel 取消订阅并关闭通道
+func (m *Monitor) SubscribePrice(symbol string, buffer int) (<-chan PriceTick, func()) {
+	if symbol != "" {
+		symbol = Normalize(symbol)
+		if err := m.TrackPrice(symbol); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+	return m.prices.subscribe(symbol, buffer)
+}
+
+// TrackPrice 使用默认监控器订阅交易对的实时成交流
+func TrackPrice(symbol string) error {
+	m := DefaultMonitor()
+	if m == nil {
+		return ErrMonitorNotInitialized
+	}
+	return m.TrackPrice(symbol)
+}
+
+// LastPrice 使用默认监控器获取实时成交价
+func LastPrice(symbol string) (float64, bool) {
+	m := DefaultMonitor()
+	if m == nil {
+		return 0, false
+	}
+	return m.LastPrice(symbol)
+}
+
+// SubscribePrice 使用默认监控器订阅逐笔价格更新
+func SubscribePrice(symbol string, buffer int) (<-chan PriceTick, func(), error) {
+	m := DefaultMonitor()
+	if m == nil {
+		return nil, nil, ErrMonitorNotInitialized
+	}
+	ch, cancel := m.SubscribePrice(symbol, buffer)
+	return ch, cancel, nil
+}
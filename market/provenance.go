@@ -0,0 +1,51 @@
+package market
+
+import (
+	"fmt"
+	"time"
+)
+
+// Provenance 指标计算来源信息，用于事后排查 "为什么12:03时RSI是71"
+type Provenance struct {
+	Interval        string    `json:"interval"`         // K线周期
+	KlineCount      int       `json:"kline_count"`      // 参与计算的K线数量
+	FirstOpenTime   time.Time `json:"first_open_time"`  // 第一根K线开盘时间
+	LastOpenTime    time.Time `json:"last_open_time"`   // 最后一根K线开盘时间
+	LastCloseTime   time.Time `json:"last_close_time"`  // 最后一根K线收盘时间
+	IncludesForming bool      `json:"includes_forming"` // 最后一根K线是否尚未收盘（形成中）
+	ComputedAt      time.Time `json:"computed_at"`      // 计算时间
+}
+
+// newProvenance 根据参与计算的K线生成来源信息
+func newProvenance(interval string, klines []Kline, now time.Time) *Provenance {
+	p := &Provenance{
+		Interval:   interval,
+		KlineCount: len(klines),
+		ComputedAt: now,
+	}
+	if len(klines) == 0 {
+		return p
+	}
+	first := klines[0]
+	last := klines[len(klines)-1]
+	p.FirstOpenTime = time.UnixMilli(first.OpenTime)
+	p.LastOpenTime = time.UnixMilli(last.OpenTime)
+	p.LastCloseTime = time.UnixMilli(last.CloseTime)
+	p.IncludesForming = last.CloseTime >= now.UnixMilli()
+	return p
+}
+
+// String 单行描述，便于日志输出
+func (p *Provenance) String() string {
+	if p == nil {
+		return "(无来源信息)"
+	}
+	forming := "已收盘"
+	if p.IncludesForming {
+		forming = "含未收盘K线"
+	}
+	return fmt.Sprintf("%s K线=%d 区间=[%s, %s] %s 计算于 %s",
+		p.Interval, p.KlineCount,
+		p.FirstOpenTime.Format("01-02 15:04"), p.LastOpenTime.Format("01-02 15:04"),
+		forming, p.ComputedAt.Format("15:04:05"))
+}
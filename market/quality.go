@@ -0,0 +1,143 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 数据段名称（DataQuality 中标识缺失或过期的部分）
+const (
+	QualitySectionOpenInterest = "open_interest"
+	QualitySectionFunding      = "funding"
+	QualitySectionDepth        = "depth"
+	QualitySectionSentiment    = "sentiment"
+	qualitySectionKlinesPrefix = "klines_" // 加周期，如 klines_3m
+)
+
+// 数据问题状态
+const (
+	QualityMissing = "missing" // 获取失败，对应字段为空
+	QualityStale   = "stale"   // 数据已过期
+)
+
+// klineStaleBars 最后一根K线落后当前时间超过该数量的周期时视为过期（WebSocket 停滞、回补失败）
+const klineStaleBars = 2
+
+// klineRejectBars 当前价格所在周期落后超过该数量的周期时不再返回数据（ErrStaleData），
+// 其他周期过期只记录到数据质量中
+const klineRejectBars = 10
+
+// QualityIssue 一项数据质量问题
+type QualityIssue struct {
+	Section string    `json:"section"`
+	Status  string    `json:"status"`
+	Reason  string    `json:"reason,omitempty"`
+	AsOf    time.Time `json:"as_of,omitempty"` // 过期数据的最后更新时间
+}
+
+// DataQuality 本次行情数据的缺失/过期情况（Data.Quality 为 nil 表示各部分均正常）
+type DataQuality struct {
+	CheckedAt time.Time      `json:"checked_at"`
+	Issues    []QualityIssue `json:"issues"`
+}
+
+// add 记录一项问题
+func (q *DataQuality) add(section, status, reason string, asOf time.Time) {
+	q.Issues = append(q.Issues, QualityIssue{Section: section, Status: status, Reason: reason, AsOf: asOf})
+}
+
+// missing 记录获取失败的数据段
+func (q *DataQuality) missing(section string, err error) {
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	q.add(section, QualityMissing, reason, time.Time{})
+}
+
+// OK 是否没有任何问题
+func (q *DataQuality) OK() bool {
+	return q == nil || len(q.Issues) == 0
+}
+
+// Has 指定数据段是否存在问题
+func (q *DataQuality) Has(section string) bool {
+	if q == nil {
+		return false
+	}
+	for _, issue := range q.Issues {
+		if issue.Section == section {
+			return true
+		}
+	}
+	return false
+}
+
+// orNil 没有问题时返回 nil
+func (q *DataQuality) orNil() *DataQuality {
+	if q.OK() {
+		return nil
+	}
+	return q
+}
+
+// checkKlines 检查K线是否为空或过期
+func (q *DataQuality) checkKlines(interval string, klines []Kline, now time.Time) {
+	section := qualitySectionKlinesPrefix + interval
+	if len(klines) == 0 {
+		q.add(section, QualityMissing, "无可用K线", time.Time{})
+		return
+	}
+	d, err := intervalDuration(interval)
+	if err != nil {
+		return
+	}
+	last := time.UnixMilli(klines[len(klines)-1].OpenTime)
+	if now.Sub(last) > klineStaleBars*d {
+		q.add(section, QualityStale, fmt.Sprintf("最新K线开盘于 %s 前", now.Sub(last).Truncate(time.Second)), last)
+	}
+}
+
+// checkKlinesFresh 当前价格所在周期的K线严重过期时返回 ErrStaleData（klines 不能为空）
+func checkKlinesFresh(interval string, klines []Kline, now time.Time) error {
+	d, err := intervalDuration(interval)
+	if err != nil {
+		return nil
+	}
+	last := time.UnixMilli(klines[len(klines)-1].OpenTime)
+	if age := now.Sub(last); age > klineRejectBars*d {
+		return fmt.Errorf("%w: %s 最新K线开盘于 %s 前", ErrStaleData, interval, age.Truncate(time.Second))
+	}
+	return nil
+}
+
+// checkFunding 下次结算时间已过仍未更新视为过期
+func (q *DataQuality) checkFunding(f *FundingData, now time.Time) {
+	if f == nil || f.NextFundingTime.IsZero() {
+		return
+	}
+	if now.Sub(f.NextFundingTime) > time.Minute {
+		asOf := f.NextFundingTime
+		if f.Interval > 0 {
+			asOf = asOf.Add(-f.Interval)
+		}
+		q.add(QualitySectionFunding, QualityStale, "下次结算时间已过", asOf)
+	}
+}
+
+// writeQuality 输出数据质量警告（放在报告开头，提示 AI 哪些数据不可信）
+func writeQuality(sb *strings.Builder, l reportLocale, q *DataQuality) {
+	if q.OK() {
+		return
+	}
+	parts := make([]string, 0, len(q.Issues))
+	for _, issue := range q.Issues {
+		item := fmt.Sprintf("%s=%s", issue.Section, l.text("quality:"+issue.Status))
+		if !issue.AsOf.IsZero() {
+			item += l.f("quality_as_of", issue.AsOf.UTC().Format("01-02 15:04"))
+		}
+		parts = append(parts, item)
+	}
+	sb.WriteString(l.f("quality_warning", strings.Join(parts, ", ")))
+}
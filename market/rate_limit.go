@@ -0,0 +1,229 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 币安 REST 权重限流
+// 币安按 IP、按自然分钟统计请求权重（fapi 上限 2400/分钟），超限返回 429，持续超限返回 418 并封禁 IP。
+// 这里为 fapi/dapi/api 三个域名分别维护本地计数，并以响应头 X-MBX-USED-WEIGHT-1M 校准，
+// 预算用尽时等待到下一分钟；收到 429/418 后在 Retry-After（缺省时指数退避）期间直接拒绝请求。
+
+// RateLimitConfig 币安 REST 请求限流配置
+type RateLimitConfig struct {
+	WeightPerMinute int           // 每分钟权重预算（每个域名），默认 1200，为交易模块预留一半额度
+	MaxWait         time.Duration // 预算用尽时最长等待时间，超过则直接返回错误，默认 30s
+	BackoffBase     time.Duration // 429/418 且无 Retry-After 时的初始退避，默认 5s，连续触发时翻倍
+	BackoffMax      time.Duration // 最大退避，默认 5min
+}
+
+// DefaultRateLimitConfig 默认限流配置
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		WeightPerMinute: 1200,
+		MaxWait:         30 * time.Second,
+		BackoffBase:     5 * time.Second,
+		BackoffMax:      5 * time.Minute,
+	}
+}
+
+func (c RateLimitConfig) withDefaults() RateLimitConfig {
+	d := DefaultRateLimitConfig()
+	if c.WeightPerMinute <= 0 {
+		c.WeightPerMinute = d.WeightPerMinute
+	}
+	if c.MaxWait <= 0 {
+		c.MaxWait = d.MaxWait
+	}
+	if c.BackoffBase <= 0 {
+		c.BackoffBase = d.BackoffBase
+	}
+	if c.BackoffMax <= 0 {
+		c.BackoffMax = d.BackoffMax
+	}
+	return c
+}
+
+// RateLimitError 因限流或封禁而未发出的请求
+type RateLimitError struct {
+	Host  string
+	Until time.Time // 可再次请求的时间
+	Ban   bool      // 是否处于 429/418 退避期（否则为本地预算用尽）
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Ban {
+		return fmt.Sprintf("%s 触发币安限流，退避至 %s", e.Host, e.Until.Format("15:04:05"))
+	}
+	return fmt.Sprintf("%s 本分钟请求权重预算已用尽，需等待至 %s", e.Host, e.Until.Format("15:04:05"))
+}
+
+// RateLimitState 单个域名的限流状态
+type RateLimitState struct {
+	Host        string    `json:"host"`
+	Budget      int       `json:"budget"`
+	Used        int       `json:"used"`        // 本分钟本地计数
+	ServerUsed  int       `json:"server_used"` // 交易所返回的本分钟已用权重
+	BannedUntil time.Time `json:"banned_until,omitempty"`
+}
+
+// weightLimiter 单个域名的权重限流器
+type weightLimiter struct {
+	host string
+
+	mu          sync.Mutex
+	window      int64 // 当前统计的自然分钟（Unix 分钟数）
+	used        int
+	serverUsed  int
+	bannedUntil time.Time
+	strikes     int // 连续触发 429/418 的次数
+}
+
+var (
+	rateLimitMu  sync.RWMutex
+	rateLimitCfg = DefaultRateLimitConfig()
+	limiters     sync.Map // host -> *weightLimiter
+)
+
+// SetRateLimit 设置币安 REST 限流配置（对所有域名生效）
+func SetRateLimit(cfg RateLimitConfig) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimitCfg = cfg.withDefaults()
+}
+
+func currentRateLimit() RateLimitConfig {
+	rateLimitMu.RLock()
+	defer rateLimitMu.RUnlock()
+	return rateLimitCfg
+}
+
+// RateLimitStatus 返回各域名当前的权重使用情况
+func RateLimitStatus() []RateLimitState {
+	budget := currentRateLimit().WeightPerMinute
+	var states []RateLimitState
+	limiters.Range(func(_, v any) bool {
+		l := v.(*weightLimiter)
+		l.mu.Lock()
+		l.rollLocked(time.Now())
+		states = append(states, RateLimitState{Host: l.host, Budget: budget, Used: l.used, ServerUsed: l.serverUsed, BannedUntil: l.bannedUntil})
+		l.mu.Unlock()
+		return true
+	})
+	return states
+}
+
+func limiterFor(host string) *weightLimiter {
+	v, _ := limiters.LoadOrStore(host, &weightLimiter{host: host})
+	return v.(*weightLimiter)
+}
+
+// rollLocked 进入新的自然分钟时清零计数
+func (l *weightLimiter) rollLocked(now time.Time) {
+	if w := now.Unix() / 60; w != l.window {
+		l.window = w
+		l.used = 0
+		l.serverUsed = 0
+	}
+}
+
+// acquire 申请 weight 权重：退避期内直接拒绝；预算不足时等待到下一分钟（超过 MaxWait 则拒绝）
+func (l *weightLimiter) acquire(weight int) error {
+	cfg := currentRateLimit()
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if now.Before(l.bannedUntil) {
+			until := l.bannedUntil
+			l.mu.Unlock()
+			return &RateLimitError{Host: l.host, Until: until, Ban: true}
+		}
+		l.rollLocked(now)
+		if max(l.used, l.serverUsed)+weight <= cfg.WeightPerMinute {
+			l.used += weight
+			l.mu.Unlock()
+			return nil
+		}
+		next := time.Unix((l.window+1)*60, 0)
+		l.mu.Unlock()
+
+		wait := time.Until(next)
+		if wait > cfg.MaxWait {
+			return &RateLimitError{Host: l.host, Until: next}
+		}
+		time.Sleep(wait)
+	}
+}
+
+// observe 根据响应头校准已用权重，并在 429/418 时进入退避
+func (l *weightLimiter) observe(resp *http.Response) {
+	cfg := currentRateLimit()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.rollLocked(now)
+	for _, h := range []string{"X-Mbx-Used-Weight-1m", "X-Mbx-Used-Weight"} {
+		if v := resp.Header.Get(h); v != "" {
+			if used, err := strconv.Atoi(v); err == nil {
+				l.serverUsed = used
+			}
+			break
+		}
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusTeapot {
+		l.strikes = 0
+		return
+	}
+	l.strikes++
+	backoff := cfg.BackoffBase << min(l.strikes-1, 16)
+	if backoff > cfg.BackoffMax {
+		backoff = cfg.BackoffMax
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		backoff = time.Duration(secs) * time.Second
+	}
+	l.bannedUntil = now.Add(backoff)
+	log.Printf("🚦 %s 返回 %d，暂停请求 %v（第 %d 次）", l.host, resp.StatusCode, backoff, l.strikes)
+}
+
+// binanceGet 经权重限流与共享客户端发起币安 GET 请求
+func binanceGet(rawURL string, weight int) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return httpGet(rawURL, limiterFor(u.Host), weight)
+}
+
+// klinesWeight K线接口按 limit 计算的请求权重
+func klinesWeight(limit int) int {
+	switch {
+	case limit < 100:
+		return 1
+	case limit < 500:
+		return 2
+	case limit <= 1000:
+		return 5
+	}
+	return 10
+}
+
+// depthWeight 深度接口按 limit 计算的请求权重
+func depthWeight(limit int) int {
+	switch {
+	case limit <= 50:
+		return 2
+	case limit <= 100:
+		return 5
+	case limit <= 500:
+		return 10
+	}
+	return 20
+}
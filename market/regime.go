@@ -0,0 +1,169 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 市场状态
+const (
+	RegimeTrendingUp   = "trending_up"
+	RegimeTrendingDown = "trending_down"
+	RegimeRanging      = "ranging"
+	RegimeVolatile     = "volatile"
+)
+
+const (
+	// regimeATRWindow ATR百分位的回看窗口
+	regimeATRWindow = 50
+	// regimeTrendADX ADX 高于该值视为趋势明确
+	regimeTrendADX = 25.0
+	// regimeWeakTrendADX ADX 高于该值且均线排列一致时视为弱趋势
+	regimeWeakTrendADX = 20.0
+	// regimeVolatilePct ATR 百分位高于该值且无明确趋势时视为剧烈波动
+	regimeVolatilePct = 0.9
+)
+
+// TimeframeRegime 单个时间框架的市场状态
+type TimeframeRegime struct {
+	Timeframe     string  `json:"timeframe"`
+	Regime        string  `json:"regime"`
+	Confidence    float64 `json:"confidence"`     // 0-1
+	EMAAlignment  int     `json:"ema_alignment"`  // 1: 价格>EMA20>EMA50，-1: 价格<EMA20<EMA50，0: 交错
+	ADX           float64 `json:"adx"`            // 14期ADX
+	ATRPercentile float64 `json:"atr_percentile"` // 当前ATR/价格在近 regimeATRWindow 期中的百分位（0-1）
+}
+
+// RegimeData 多时间框架市场状态
+type RegimeData struct {
+	Timeframes []TimeframeRegime `json:"timeframes,omitempty"`
+	Overall    string            `json:"overall"`    // 按时间框架加权投票的综合状态
+	Confidence float64           `json:"confidence"` // 综合状态的置信度（0-1）
+}
+
+// regimeWeights 综合投票时各时间框架的权重（周期越长权重越高）
+var regimeWeights = map[string]float64{"3m": 1, "15m": 1.5, "1h": 2, "4h": 2.5, "1d": 3}
+
+// calculateRegimeData 计算各时间框架的市场状态及综合状态
+func calculateRegimeData(frames map[string][]Kline) *RegimeData {
+	data := &RegimeData{}
+	votes := make(map[string]float64)
+	totalWeight := 0.0
+	for _, tf := range []string{"3m", "15m", "1h", "4h", "1d"} {
+		r, ok := classifyRegime(tf, frames[tf])
+		if !ok {
+			continue
+		}
+		data.Timeframes = append(data.Timeframes, r)
+		w := regimeWeights[tf]
+		votes[r.Regime] += w * r.Confidence
+		totalWeight += w
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+	for _, regime := range []string{RegimeTrendingUp, RegimeTrendingDown, RegimeRanging, RegimeVolatile} {
+		if votes[regime] > votes[data.Overall] || data.Overall == "" {
+			data.Overall = regime
+		}
+	}
+	data.Confidence = votes[data.Overall] / totalWeight
+	return data
+}
+
+// classifyRegime 根据均线排列、ADX 与 ATR 百分位判断单个时间框架的状态
+func classifyRegime(tf string, klines []Kline) (TimeframeRegime, bool) {
+	if len(klines) < 60 {
+		return TimeframeRegime{}, false
+	}
+	price := klines[len(klines)-1].Close
+	ema20, ema50 := calculateEMA(klines, 20), calculateEMA(klines, 50)
+	adx, plusDI, minusDI := calculateADX(klines, 14)
+
+	r := TimeframeRegime{
+		Timeframe:     tf,
+		ADX:           adx,
+		ATRPercentile: atrPercentile(klines, 14, regimeATRWindow),
+	}
+	switch {
+	case price > ema20 && ema20 > ema50:
+		r.EMAAlignment = 1
+	case price < ema20 && ema20 < ema50:
+		r.EMAAlignment = -1
+	}
+
+	diAgrees := (r.EMAAlignment > 0 && plusDI > minusDI) || (r.EMAAlignment < 0 && minusDI > plusDI)
+	trendStrength := math.Min(adx/50, 1)
+	switch {
+	case r.EMAAlignment != 0 && (adx >= regimeTrendADX || (adx >= regimeWeakTrendADX && diAgrees)):
+		r.Regime = RegimeTrendingUp
+		if r.EMAAlignment < 0 {
+			r.Regime = RegimeTrendingDown
+		}
+		r.Confidence = 0.3 + 0.5*trendStrength
+		if diAgrees {
+			r.Confidence += 0.2
+		}
+	case r.ATRPercentile >= regimeVolatilePct:
+		r.Regime = RegimeVolatile
+		r.Confidence = r.ATRPercentile
+	default:
+		r.Regime = RegimeRanging
+		// ADX 越低、波动越收敛，震荡判断越可靠
+		r.Confidence = 0.5*math.Max(0, (regimeTrendADX-adx)/regimeTrendADX) + 0.5*(1-r.ATRPercentile)
+	}
+	r.Confidence = math.Max(0, math.Min(r.Confidence, 1))
+	return r, true
+}
+
+// atrPercentile 当前 ATR/收盘价 在最近 window 个值中的百分位
+func atrPercentile(klines []Kline, period, window int) float64 {
+	if len(klines) <= period+1 {
+		return 0
+	}
+	var normalized []float64
+	atr := 0.0
+	for i := 1; i < len(klines); i++ {
+		tr := math.Max(klines[i].High-klines[i].Low,
+			math.Max(math.Abs(klines[i].High-klines[i-1].Close), math.Abs(klines[i].Low-klines[i-1].Close)))
+		switch {
+		case i < period:
+			atr += tr
+			continue
+		case i == period:
+			atr = (atr + tr) / float64(period)
+		default:
+			atr = (atr*float64(period-1) + tr) / float64(period)
+		}
+		if klines[i].Close > 0 {
+			normalized = append(normalized, atr/klines[i].Close)
+		}
+	}
+	if len(normalized) == 0 {
+		return 0
+	}
+	if len(normalized) > window {
+		normalized = normalized[len(normalized)-window:]
+	}
+	current := normalized[len(normalized)-1]
+	below := 0
+	for _, v := range normalized {
+		if v <= current {
+			below++
+		}
+	}
+	return float64(below) / float64(len(normalized))
+}
+
+// writeRegime 输出市场状态
+func writeRegime(sb *strings.Builder, l reportLocale, r *RegimeData) {
+	if r == nil {
+		return
+	}
+	parts := make([]string, 0, len(r.Timeframes))
+	for _, tf := range r.Timeframes {
+		parts = append(parts, fmt.Sprintf("%s=%s(%.2f)", tf.Timeframe, l.text("regime:"+tf.Regime), tf.Confidence))
+	}
+	sb.WriteString(l.f("regime_summary", l.text("regime:"+r.Overall), r.Confidence, strings.Join(parts, ", ")))
+}
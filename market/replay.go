@@ -0,0 +1,128 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoSnapshot 回放时间点之前没有该交易对的快照
+var ErrNoSnapshot = errors.New("回放时间点之前没有可用的市场快照")
+
+// Provider 市场数据提供者（实时 Monitor 与历史 Replay 均实现该接口）
+type Provider interface {
+	GetWithSource(symbol string, src Source, cfg IndicatorConfig) (*Data, error)
+}
+
+// Replay 从快照存储回放历史市场数据，通过与 Monitor 相同的 Get 接口提供数据
+// 每次 Get 返回不晚于当前回放时间的最近一条快照；指标配置与数据源参数被忽略（快照已包含计算结果）
+type Replay struct {
+	store    SnapshotStore
+	from, to time.Time
+
+	mu    sync.Mutex
+	now   time.Time
+	cache map[string][]Snapshot
+}
+
+// NewReplay 创建回放源，回放时间初始为 from
+func NewReplay(store SnapshotStore, from, to time.Time) *Replay {
+	return &Replay{store: store, from: from, to: to, now: from, cache: make(map[string][]Snapshot)}
+}
+
+// SetTime 设置回放时间
+func (r *Replay) SetTime(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = t
+}
+
+// Advance 将回放时间向前推进 d
+func (r *Replay) Advance(d time.Duration) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = r.now.Add(d)
+	return r.now
+}
+
+// Now 当前回放时间
+func (r *Replay) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.now
+}
+
+// Snapshots 返回交易对在回放区间内的全部快照（按时间升序）
+func (r *Replay) Snapshots(symbol string) ([]Snapshot, error) {
+	symbol = Normalize(symbol)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loadLocked(symbol)
+}
+
+func (r *Replay) loadLocked(symbol string) ([]Snapshot, error) {
+	if snaps, ok := r.cache[symbol]; ok {
+		return snaps, nil
+	}
+	snaps, err := r.store.Load(symbol, r.from, r.to)
+	if err != nil {
+		return nil, fmt.Errorf("加载 %s 快照失败: %w", symbol, err)
+	}
+	r.cache[symbol] = snaps
+	return snaps, nil
+}
+
+// Get 获取当前回放时间的市场数据
+func (r *Replay) Get(symbol string) (*Data, error) {
+	return r.GetWithSource(symbol, nil, IndicatorConfig{})
+}
+
+// GetWithConfig 获取当前回放时间的市场数据（忽略指标配置）
+func (r *Replay) GetWithConfig(symbol string, cfg IndicatorConfig) (*Data, error) {
+	return r.GetWithSource(symbol, nil, cfg)
+}
+
+// GetWithSource 获取当前回放时间的市场数据（忽略数据源与指标配置）
+func (r *Replay) GetWithSource(symbol string, _ Source, _ IndicatorConfig) (*Data, error) {
+	symbol = Normalize(symbol)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snaps, err := r.loadLocked(symbol)
+	if err != nil {
+		return nil, err
+	}
+	idx := sort.Search(len(snaps), func(i int) bool { return snaps[i].Time.After(r.now) }) - 1
+	if idx < 0 {
+		return nil, fmt.Errorf("%s @ %s: %w", symbol, r.now.Format(time.RFC3339), ErrNoSnapshot)
+	}
+	data := *snaps[idx].Data
+	return &data, nil
+}
+
+var (
+	replayMu     sync.RWMutex
+	activeReplay *Replay
+)
+
+// SetReplay 启用历史回放：包级 Get/GetBatch 等函数改为从回放源取数（nil 恢复使用默认监控器）
+func SetReplay(r *Replay) {
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	activeReplay = r
+}
+
+// currentProvider 包级函数使用的数据提供者：回放优先，其次默认监控器
+func currentProvider() (Provider, error) {
+	replayMu.RLock()
+	r := activeReplay
+	replayMu.RUnlock()
+	if r != nil {
+		return r, nil
+	}
+	if m := DefaultMonitor(); m != nil {
+		return m, nil
+	}
+	return nil, ErrMonitorNotInitialized
+}
@@ -0,0 +1,515 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 报告语言
+const (
+	LocaleZH = "zh"
+	LocaleEN = "en"
+)
+
+// 报告章节（模板通过选择章节及其顺序决定输出内容）
+const (
+	SectionQuality      = "quality"        // 数据缺失/过期警告
+	SectionEventRisk    = "event_risk"     // 宏观事件/交易所维护风险窗口
+	SectionOverview     = "overview"       // 当前指标、价格变化、协同效率
+	SectionRegime       = "regime"         // 多时间框架市场状态
+	SectionVolatility   = "volatility"     // 多时间框架波动率与挤压
+	SectionDerivatives  = "derivatives"    // 持仓量与资金费率
+	SectionDepth        = "depth"          // 订单簿深度
+	SectionFlow         = "flow"           // 主动买卖与强平
+	SectionSentiment    = "sentiment"      // 多空比
+	SectionKeyLevels    = "key_levels"     // 支撑阻力与枢轴位
+	SectionCorrelation  = "correlation"    // 相对基准的相关系数与 beta
+	SectionIntraday3m   = "intraday_3m"    // 3分钟序列
+	SectionIntraday15m  = "intraday_15m"   // 15分钟序列
+	SectionIntraday1h   = "intraday_1h"    // 1小时序列
+	SectionLongerTerm4h = "longer_term_4h" // 4小时长期数据
+	SectionLongerTerm1d = "longer_term_1d" // 1天长期数据
+)
+
+// ReportTemplate 市场报告模板
+type ReportTemplate struct {
+	Name     string   `json:"name"`
+	Locale   string   `json:"locale"`   // zh / en
+	Sections []string `json:"sections"` // 按顺序输出的章节
+}
+
+// reportSection 章节渲染函数
+type reportSection func(sb *strings.Builder, l reportLocale, data *Data)
+
+// reportSections 章节名称 -> 渲染函数
+var reportSections = map[string]reportSection{
+	SectionQuality:     func(sb *strings.Builder, l reportLocale, data *Data) { writeQuality(sb, l, data.Quality) },
+	SectionEventRisk:   func(sb *strings.Builder, l reportLocale, data *Data) { writeEventRisk(sb, l, data.EventRisk) },
+	SectionOverview:    writeOverviewSection,
+	SectionDerivatives: writeDerivativesSection,
+	SectionDepth:       func(sb *strings.Builder, l reportLocale, data *Data) { writeDepth(sb, l, data.Depth) },
+	SectionFlow: func(sb *strings.Builder, l reportLocale, data *Data) {
+		writeFlow(sb, l, data.TakerFlow, data.Liquidations)
+	},
+	SectionSentiment:    func(sb *strings.Builder, l reportLocale, data *Data) { writeSentiment(sb, l, data.Sentiment) },
+	SectionRegime:       func(sb *strings.Builder, l reportLocale, data *Data) { writeRegime(sb, l, data.Regime) },
+	SectionVolatility:   func(sb *strings.Builder, l reportLocale, data *Data) { writeVolatility(sb, l, data.Volatility) },
+	SectionKeyLevels:    func(sb *strings.Builder, l reportLocale, data *Data) { writeKeyLevels(sb, l, data.KeyLevels) },
+	SectionCorrelation:  func(sb *strings.Builder, l reportLocale, data *Data) { writeCorrelation(sb, l, data.Correlation) },
+	SectionIntraday3m:   writeIntraday3mSection,
+	SectionIntraday15m:  writeIntraday15mSection,
+	SectionIntraday1h:   writeIntraday1hSection,
+	SectionLongerTerm4h: writeLongerTerm4hSection,
+	SectionLongerTerm1d: writeLongerTerm1dSection,
+}
+
+// AllReportSections 内置模板使用的完整章节顺序
+var AllReportSections = []string{
+	SectionQuality, SectionEventRisk, SectionOverview, SectionRegime, SectionVolatility, SectionDerivatives, SectionDepth, SectionFlow, SectionSentiment, SectionKeyLevels,
+	SectionCorrelation, SectionIntraday3m, SectionIntraday15m, SectionIntraday1h, SectionLongerTerm4h, SectionLongerTerm1d,
+}
+
+var (
+	reportTemplatesMu sync.RWMutex
+	reportTemplates   = map[string]ReportTemplate{
+		LocaleZH: {Name: LocaleZH, Locale: LocaleZH, Sections: AllReportSections},
+		LocaleEN: {Name: LocaleEN, Locale: LocaleEN, Sections: AllReportSections},
+	}
+)
+
+// RegisterReportTemplate 注册（或覆盖）报告模板
+func RegisterReportTemplate(t ReportTemplate) error {
+	if t.Name == "" {
+		return fmt.Errorf("报告模板名称不能为空")
+	}
+	if t.Locale == "" {
+		t.Locale = LocaleZH
+	}
+	if _, ok := reportMessages[t.Locale]; !ok {
+		return fmt.Errorf("报告模板 %s: 不支持的语言 %s", t.Name, t.Locale)
+	}
+	for _, s := range t.Sections {
+		if _, ok := reportSections[s]; !ok {
+			return fmt.Errorf("报告模板 %s: 未知章节 %s", t.Name, s)
+		}
+	}
+	t.Sections = append([]string(nil), t.Sections...)
+	reportTemplatesMu.Lock()
+	defer reportTemplatesMu.Unlock()
+	reportTemplates[t.Name] = t
+	return nil
+}
+
+// ReportTemplateByName 按名称获取报告模板
+func ReportTemplateByName(name string) (ReportTemplate, bool) {
+	reportTemplatesMu.RLock()
+	defer reportTemplatesMu.RUnlock()
+	t, ok := reportTemplates[name]
+	return t, ok
+}
+
+// Format 格式化输出市场数据（默认中文模板）
+func Format(data *Data) string {
+	t, _ := ReportTemplateByName(LocaleZH)
+	return renderReport(data, t)
+}
+
+// FormatWithTemplate 按指定模板格式化输出市场数据
+func FormatWithTemplate(data *Data, name string) (string, error) {
+	t, ok := ReportTemplateByName(name)
+	if !ok {
+		return "", fmt.Errorf("未知的报告模板: %s", name)
+	}
+	return renderReport(data, t), nil
+}
+
+// renderReport 按模板章节依次渲染
+func renderReport(data *Data, t ReportTemplate) string {
+	var sb strings.Builder
+	l := reportLocale(t.Locale)
+	for _, name := range t.Sections {
+		if section, ok := reportSections[name]; ok {
+			section(&sb, l, data)
+		}
+	}
+	return sb.String()
+}
+
+// reportLocale 报告语言，用于查找文案
+type reportLocale string
+
+// reportMessages 各语言文案（格式串），缺失时回退到中文
+var reportMessages = map[string]map[string]string{
+	LocaleZH: {
+		"overview_current":        "当前价格 = %.2f, %d期EMA = %.3f, MACD = %.3f, %d期RSI = %.3f\n\n",
+		"overview_change":         "价格变化: 3分钟=%.2f%%, 15分钟=%.2f%%, 1小时=%.2f%%, 4小时=%.2f%%, 1天=%.2f%%\n",
+		"overview_effort":         "协同效率: 3m=%.3f(%s), 15m=%.3f(%s), 1h=%.3f(%s)\n\n",
+		"futures_header":          "合约市场数据（%s）:\n\n",
+		"futures_header_source":   "合约市场数据（%s @ %s）:\n\n",
+		"oi_latest":               "持仓量: 最新=%.2f, 平均=%.2f\n",
+		"oi_change":               "OI变化率: 5m=%.3f%%, 15m=%.3f%%, 1h=%.3f%%, 4h=%.3f%%, 1d=%.3f%%\n",
+		"oi_trend":                "OI趋势评分: %.3f (%s)\n\n",
+		"funding_rate":            "资金费率: %.2e\n",
+		"intraday_header":         "日内数据（%s周期，从旧到新）:\n\n",
+		"longer_header":           "长期数据（%s周期）:\n\n",
+		"atr_single":              "%d期ATR: %.3f \n\n",
+		"atr_pair":                "%d期ATR: %.3f vs %d期ATR: %.3f\n\n",
+		"ema_pair":                "%d期EMA: %.3f vs %d期EMA: %.3f\n\n",
+		"volume_series":           "成交量序列: %s\n",
+		"volume_average":          "平均成交量: %.2f, 量能放大倍数: %.2f\n\n",
+		"volume_pair":             "当前成交量: %.3f vs 平均成交量: %.3f\n\n",
+		"mid_prices":              "中间价: %s\n\n",
+		"vwap":                    "当日VWAP: %.3f\n\n",
+		"tf_3m":                   "3分钟",
+		"tf_15m":                  "15分钟",
+		"tf_1h":                   "1小时",
+		"tf_4h":                   "4小时",
+		"tf_1d":                   "1天",
+		"funding_detail":          "资金费率详情: 年化=%.2f%%, 结算间隔=%s",
+		"funding_next":            ", 距下次结算=%s",
+		"funding_history":         "历史资金费率(从旧到新): %s\n",
+		"funding_average":         "历史均值: %.2e, 当前相对均值变化: %.2e\n",
+		"funding_cost":            "持有%.0f小时预计资金费(占名义价值, 正数为支付): 多=%+.4f%%, 空=%+.4f%%（%d次结算）\n",
+		"depth_book":              "订单簿: 买一=%.4f, 卖一=%.4f, 价差=%.2fbps\n",
+		"depth_liquidity":         "前%d档流动性: 买盘=%.0f USDT, 卖盘=%.0f USDT, 失衡比=%.3f\n\n",
+		"flow_taker_item":         "%s 买占比=%.3f 净额=%.0f",
+		"flow_taker":              "主动买卖(USDT): %s\n",
+		"flow_liquidation_item":   "%s 多头爆仓=%.0f 空头爆仓=%.0f(%d笔)",
+		"flow_liquidation":        "强平统计(USDT): %s\n",
+		"sentiment_summary":       "多空比（%s）: 大户持仓多空比=%.3f(多头占比%.1f%%), 账户多空比=%.3f(多头占比%.1f%%)\n",
+		"sentiment_top_series":    "大户持仓多空比序列: %s\n",
+		"sentiment_global_series": "账户多空比序列: %s\n",
+		"ema_series":              "%d期EMA指标: %s\n\n",
+		"rsi_series":              "%d期RSI指标: %s\n\n",
+		"atr_value":               "%d期ATR: %.3f\n\n",
+		"ema_value":               "%d期EMA: %.3f\n\n",
+		"macd_series":             "MACD(%s)指标: %s\n\n",
+		"bollinger":               "布林带(20,2): 上轨=%.3f, 中轨=%.3f, 下轨=%.3f, 带宽=%.4f, %%B=%.3f\n\n",
+		"effort:极高效率":             "极高效率",
+		"effort:高效率":              "高效率",
+		"effort:正常":               "正常",
+		"effort:低效率":              "低效率",
+		"effort:反向轻压":             "反向轻压",
+		"effort:反向压力":             "反向压力",
+		"effort:强反向压力":            "强反向压力",
+		"key_levels_resistance":   "阻力位(由近到远): %s\n",
+		"key_levels_support":      "支撑位(由近到远): %s\n",
+		"key_levels_daily_pivot":  "日枢轴: P=%.3f, R1=%.3f, S1=%.3f\n",
+		"key_levels_weekly_pivot": "周枢轴: P=%.3f, R1=%.3f, S1=%.3f\n",
+		"level:swing_high_1h":     "1h摆动高点",
+		"level:swing_low_1h":      "1h摆动低点",
+		"level:swing_high_4h":     "4h摆动高点",
+		"level:swing_low_4h":      "4h摆动低点",
+		"level:daily_pivot":       "日枢轴",
+		"level:weekly_pivot":      "周枢轴",
+		"level:round_number":      "整数关口",
+		"regime_summary":          "市场状态: 综合=%s(置信度%.2f), 各周期: %s\n\n",
+		"regime:trending_up":      "上升趋势",
+		"regime:trending_down":    "下降趋势",
+		"regime:ranging":          "震荡",
+		"regime:volatile":         "剧烈波动",
+		"correlation_item":        "%s/%s 相关=%.2f beta=%.2f",
+		"correlation_summary":     "相关性(对数收益率): %s\n\n",
+		"volatility_summary":      "波动率: 水平=%s, 各周期(RV年化/ATR占比/ATR百分位): %s\n\n",
+		"volatility_squeeze":      " 挤压中(%d根)",
+		"volatility_released":     " 挤压释放",
+		"volatility:low":          "低",
+		"volatility:normal":       "正常",
+		"volatility:high":         "高",
+		"volatility:extreme":      "极高",
+		"quality_warning":         "⚠️ 数据质量: %s（相关指标不可信，请勿据此决策）\n\n",
+		"quality_as_of":           "(截至%s UTC)",
+		"quality:missing":         "缺失",
+		"quality:stale":           "过期",
+		"event_risk":              "⚠️ 事件风险(%s): %s —— 处于高影响事件窗口内，避免新开仓，已有持仓注意收紧止损\n\n",
+		"event_risk_item":         "%s(%s UTC)",
+		"impact:low":              "低",
+		"impact:medium":           "中",
+		"impact:high":             "高",
+		"basis_summary":           "基差: 标记价=%.4f, 指数价=%.4f, 标记-指数价差=%.3f%%, 最新价基差=%.3f%%",
+		"basis_trend":             ", 15分钟趋势=%s(价差变化 %+.3f, 基差变化 %+.3f)",
+		"basis_stressed":          " ⚠️ 标记价显著偏离指数，警惕插针/操纵",
+		"basis:widening":          "扩大",
+		"basis:narrowing":         "收敛",
+		"basis:stable":            "平稳",
+	},
+	LocaleEN: {
+		"overview_current":        "Current price = %.2f, EMA(%d) = %.3f, MACD = %.3f, RSI(%d) = %.3f\n\n",
+		"overview_change":         "Price change: 3m=%.2f%%, 15m=%.2f%%, 1h=%.2f%%, 4h=%.2f%%, 1d=%.2f%%\n",
+		"overview_effort":         "Effort/result: 3m=%.3f(%s), 15m=%.3f(%s), 1h=%.3f(%s)\n\n",
+		"futures_header":          "Futures market data (%s):\n\n",
+		"futures_header_source":   "Futures market data (%s @ %s):\n\n",
+		"oi_latest":               "Open interest: latest=%.2f, average=%.2f\n",
+		"oi_change":               "OI change: 5m=%.3f%%, 15m=%.3f%%, 1h=%.3f%%, 4h=%.3f%%, 1d=%.3f%%\n",
+		"oi_trend":                "OI trend score: %.3f (%s)\n\n",
+		"funding_rate":            "Funding rate: %.2e\n",
+		"intraday_header":         "Intraday series (%s timeframe, oldest → latest):\n\n",
+		"longer_header":           "Longer-term context (%s timeframe):\n\n",
+		"atr_single":              "ATR(%d): %.3f\n\n",
+		"atr_pair":                "ATR(%d): %.3f vs ATR(%d): %.3f\n\n",
+		"ema_pair":                "EMA(%d): %.3f vs EMA(%d): %.3f\n\n",
+		"volume_series":           "Volume series: %s\n",
+		"volume_average":          "Average volume: %.2f, volume spike ratio: %.2f\n\n",
+		"volume_pair":             "Current volume: %.3f vs average volume: %.3f\n\n",
+		"mid_prices":              "Mid prices: %s\n\n",
+		"vwap":                    "Session VWAP (UTC): %.3f\n\n",
+		"tf_3m":                   "3-minute",
+		"tf_15m":                  "15-minute",
+		"tf_1h":                   "1-hour",
+		"tf_4h":                   "4-hour",
+		"tf_1d":                   "1-day",
+		"funding_detail":          "Funding details: annualized=%.2f%%, interval=%s",
+		"funding_next":            ", next funding in %s",
+		"funding_history":         "Funding history (oldest → latest): %s\n",
+		"funding_average":         "History average: %.2e, current minus average: %.2e\n",
+		"funding_cost":            "Expected funding over %.0fh (%% of notional, positive = paid): long=%+.4f%%, short=%+.4f%% (%d settlements)\n",
+		"depth_book":              "Order book: best bid=%.4f, best ask=%.4f, spread=%.2fbps\n",
+		"depth_liquidity":         "Top %d levels liquidity: bids=%.0f USDT, asks=%.0f USDT, imbalance=%.3f\n\n",
+		"flow_taker_item":         "%s buy_ratio=%.3f delta=%.0f",
+		"flow_taker":              "Taker flow (USDT): %s\n",
+		"flow_liquidation_item":   "%s longs=%.0f shorts=%.0f (%d orders)",
+		"flow_liquidation":        "Liquidations (USDT): %s\n",
+		"sentiment_summary":       "Long/short ratio (%s): top trader positions=%.3f (long %.1f%%), global accounts=%.3f (long %.1f%%)\n",
+		"sentiment_top_series":    "Top trader position ratio series: %s\n",
+		"sentiment_global_series": "Global account ratio series: %s\n",
+		"ema_series":              "EMA(%d) series: %s\n\n",
+		"rsi_series":              "RSI(%d) series: %s\n\n",
+		"atr_value":               "ATR(%d): %.3f\n\n",
+		"ema_value":               "EMA(%d): %.3f\n\n",
+		"macd_series":             "MACD(%s) series: %s\n\n",
+		"bollinger":               "Bollinger(20,2): upper=%.3f, middle=%.3f, lower=%.3f, bandwidth=%.4f, %%B=%.3f\n\n",
+		"effort:极高效率":             "very high efficiency",
+		"effort:高效率":              "high efficiency",
+		"effort:正常":               "normal",
+		"effort:低效率":              "low efficiency",
+		"effort:反向轻压":             "mild counter-pressure",
+		"effort:反向压力":             "counter-pressure",
+		"effort:强反向压力":            "strong counter-pressure",
+		"key_levels_resistance":   "Resistance (nearest first): %s\n",
+		"key_levels_support":      "Support (nearest first): %s\n",
+		"key_levels_daily_pivot":  "Daily pivot: P=%.3f, R1=%.3f, S1=%.3f\n",
+		"key_levels_weekly_pivot": "Weekly pivot: P=%.3f, R1=%.3f, S1=%.3f\n",
+		"level:swing_high_1h":     "1h swing high",
+		"level:swing_low_1h":      "1h swing low",
+		"level:swing_high_4h":     "4h swing high",
+		"level:swing_low_4h":      "4h swing low",
+		"level:daily_pivot":       "daily pivot",
+		"level:weekly_pivot":      "weekly pivot",
+		"level:round_number":      "round number",
+		"regime_summary":          "Market regime: overall=%s (confidence %.2f), by timeframe: %s\n\n",
+		"regime:trending_up":      "trending up",
+		"regime:trending_down":    "trending down",
+		"regime:ranging":          "ranging",
+		"regime:volatile":         "volatile",
+		"correlation_item":        "%s/%s corr=%.2f beta=%.2f",
+		"correlation_summary":     "Correlation (log returns): %s\n\n",
+		"volatility_summary":      "Volatility: level=%s, by timeframe (annualized RV / ATR%% / ATR percentile): %s\n\n",
+		"volatility_squeeze":      " squeeze(%d bars)",
+		"volatility_released":     " squeeze released",
+		"volatility:low":          "low",
+		"volatility:normal":       "normal",
+		"volatility:high":         "high",
+		"volatility:extreme":      "extreme",
+		"quality_warning":         "⚠️ Data quality: %s (treat the affected metrics as unreliable)\n\n",
+		"quality_as_of":           "(as of %s UTC)",
+		"quality:missing":         "missing",
+		"quality:stale":           "stale",
+		"event_risk":              "⚠️ Event risk (%s): %s — inside a high-impact event window; avoid opening new positions and tighten stops on existing ones\n\n",
+		"event_risk_item":         "%s (%s UTC)",
+		"impact:low":              "low",
+		"impact:medium":           "medium",
+		"impact:high":             "high",
+		"basis_summary":           "Basis: mark=%.4f, index=%.4f, mark-index spread=%.3f%%, last-price basis=%.3f%%",
+		"basis_trend":             ", 15m trend=%s (spread change %+.3f, basis change %+.3f)",
+		"basis_stressed":          " ⚠️ mark price deviates sharply from index; beware of wicks/manipulation",
+		"basis:widening":          "widening",
+		"basis:narrowing":         "narrowing",
+		"basis:stable":            "stable",
+	},
+}
+
+// text 返回文案（当前语言缺失时回退到中文，仍缺失时返回 key 本身）
+func (l reportLocale) text(key string) string {
+	if msg, ok := reportMessages[string(l)][key]; ok {
+		return msg
+	}
+	if msg, ok := reportMessages[LocaleZH][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// f 按文案格式化
+func (l reportLocale) f(key string, args ...interface{}) string {
+	return fmt.Sprintf(l.text(key), args...)
+}
+
+// effortLabel 翻译协同效率标签
+func (l reportLocale) effortLabel(label string) string {
+	if msg, ok := reportMessages[string(l)]["effort:"+label]; ok {
+		return msg
+	}
+	return label
+}
+
+// writeOverviewSection 基础价格信息（包含各时间框架价格变化）
+func writeOverviewSection(sb *strings.Builder, l reportLocale, data *Data) {
+	emaPeriod, rsiPeriod := 20, 7
+	if data.Indicators != nil {
+		emaPeriod, rsiPeriod = data.Indicators.CurrentEMA, data.Indicators.CurrentRSI
+	}
+	sb.WriteString(l.f("overview_current",
+		data.CurrentPrice, emaPeriod, data.CurrentEMA20, data.CurrentMACD, rsiPeriod, data.CurrentRSI7))
+	sb.WriteString(l.f("overview_change",
+		data.PriceChange3m, data.PriceChange15m, data.PriceChange1h, data.PriceChange4h, data.PriceChange1d))
+	sb.WriteString(l.f("overview_effort",
+		data.EffortResult3m, l.effortLabel(data.EffortLabel3m),
+		data.EffortResult15m, l.effortLabel(data.EffortLabel15m),
+		data.EffortResult1h, l.effortLabel(data.EffortLabel1h)))
+}
+
+// writeDerivativesSection 持仓量和资金费率
+func writeDerivativesSection(sb *strings.Builder, l reportLocale, data *Data) {
+	if !data.Market.IsFutures() {
+		return // 现货无合约数据
+	}
+	if data.Source != "" && data.Source != SourceBinance {
+		sb.WriteString(l.f("futures_header_source", data.Symbol, data.Source))
+	} else {
+		sb.WriteString(l.f("futures_header", data.Symbol))
+	}
+	if data.OpenInterest != nil {
+		sb.WriteString(l.f("oi_latest", data.OpenInterest.Latest, data.OpenInterest.Average))
+		sb.WriteString(l.f("oi_change",
+			data.OpenInterest.Change5m*100,
+			data.OpenInterest.Change15m*100,
+			data.OpenInterest.Change1h*100,
+			data.OpenInterest.Change4h*100,
+			data.OpenInterest.Change1d*100))
+		sb.WriteString(l.f("oi_trend", data.OpenInterest.TrendScore, data.OpenInterest.TrendMethod))
+	}
+	sb.WriteString(l.f("funding_rate", data.FundingRate))
+	if data.Funding != nil {
+		writeFunding(sb, l, data.Funding)
+	} else {
+		sb.WriteString("\n")
+	}
+	writeBasis(sb, l, data.Basis)
+}
+
+// writeSeries 非空时输出一条序列
+func writeSeries(sb *strings.Builder, l reportLocale, key string, period interface{}, values []float64) {
+	if len(values) > 0 {
+		sb.WriteString(l.f(key, period, formatFloatSlice(values)))
+	}
+}
+
+// writeIntradayTail 日内各周期共用的自定义指标、波动带与趋势强度
+func writeIntradayTail(sb *strings.Builder, l reportLocale, d *IntradayData) {
+	writeCustomIntraday(sb, l, d)
+	writeBandsAndTrend(sb, l, d.Bollinger, d.StochRSIK, d.StochRSID, d.ADX, d.PlusDI, d.MinusDI)
+}
+
+// writeIntraday3mSection 3分钟数据
+func writeIntraday3mSection(sb *strings.Builder, l reportLocale, data *Data) {
+	d := data.IntradaySeries
+	if d == nil {
+		return
+	}
+	sb.WriteString(l.f("intraday_header", l.text("tf_3m")))
+	sb.WriteString(l.f("atr_single", 10, d.ATR10))
+	if len(d.VolumeValues) > 0 {
+		sb.WriteString(l.f("volume_series", formatFloatSlice(d.VolumeValues)))
+		sb.WriteString(l.f("volume_average", d.VolumeAverage, d.VolumeSpikeRatio))
+	}
+	if len(d.MidPrices) > 0 {
+		sb.WriteString(l.f("mid_prices", formatFloatSlice(d.MidPrices)))
+	}
+	writeSeries(sb, l, "ema_series", 20, d.EMA20Values)
+	writeSeries(sb, l, "macd_series", "10,20,8", d.MACDValues10208)
+	writeSeries(sb, l, "rsi_series", 10, d.RSI10Values)
+	writeSeries(sb, l, "rsi_series", 14, d.RSI14Values)
+	writeIntradayTail(sb, l, d)
+	if d.VWAP > 0 {
+		sb.WriteString(l.f("vwap", d.VWAP))
+	}
+}
+
+// writeIntraday15mSection 15分钟数据
+func writeIntraday15mSection(sb *strings.Builder, l reportLocale, data *Data) {
+	d := data.Intraday15m
+	if d == nil {
+		return
+	}
+	sb.WriteString(l.f("intraday_header", l.text("tf_15m")))
+	sb.WriteString(l.f("atr_single", 12, d.ATR12))
+	if len(d.MidPrices) > 0 {
+		sb.WriteString(l.f("mid_prices", formatFloatSlice(d.MidPrices)))
+	}
+	writeSeries(sb, l, "ema_series", 20, d.EMA20Values)
+	writeSeries(sb, l, "macd_series", "12,26,9", d.MACDValues12269)
+	writeSeries(sb, l, "rsi_series", 7, d.RSI7Values)
+	writeSeries(sb, l, "rsi_series", 14, d.RSI14Values)
+	writeIntradayTail(sb, l, d)
+	if d.VWAP > 0 {
+		sb.WriteString(l.f("vwap", d.VWAP))
+	}
+}
+
+// writeIntraday1hSection 1小时数据
+func writeIntraday1hSection(sb *strings.Builder, l reportLocale, data *Data) {
+	d := data.Intraday1h
+	if d == nil {
+		return
+	}
+	sb.WriteString(l.f("intraday_header", l.text("tf_1h")))
+	sb.WriteString(l.f("atr_pair", 6, d.ATR6, 14, d.ATR14))
+	if len(d.MidPrices) > 0 {
+		sb.WriteString(l.f("mid_prices", formatFloatSlice(d.MidPrices)))
+	}
+	writeSeries(sb, l, "ema_series", 20, d.EMA20Values)
+	writeSeries(sb, l, "macd_series", "12,26,9", d.MACDValues12269)
+	writeSeries(sb, l, "rsi_series", 9, d.RSI9Values)
+	writeSeries(sb, l, "rsi_series", 14, d.RSI14Values)
+	writeIntradayTail(sb, l, d)
+}
+
+// writeLongerTermHead 长周期共用的 EMA/ATR/成交量对比
+func writeLongerTermHead(sb *strings.Builder, l reportLocale, tfKey string, d *LongerTermData) {
+	sb.WriteString(l.f("longer_header", l.text(tfKey)))
+	sb.WriteString(l.f("ema_pair", 20, d.EMA20, 50, d.EMA50))
+	sb.WriteString(l.f("atr_pair", 3, d.ATR3, 14, d.ATR14))
+	sb.WriteString(l.f("volume_pair", d.CurrentVolume, d.AverageVolume))
+}
+
+// writeLongerTermTail 长周期共用的自定义指标、波动带与趋势强度
+func writeLongerTermTail(sb *strings.Builder, l reportLocale, d *LongerTermData) {
+	writeCustomLongerTerm(sb, l, d)
+	writeBandsAndTrend(sb, l, d.Bollinger, d.StochRSIK, d.StochRSID, d.ADX, d.PlusDI, d.MinusDI)
+}
+
+// writeLongerTerm4hSection 4小时数据
+func writeLongerTerm4hSection(sb *strings.Builder, l reportLocale, data *Data) {
+	d := data.LongerTermContext
+	if d == nil {
+		return
+	}
+	writeLongerTermHead(sb, l, "tf_4h", d)
+	writeSeries(sb, l, "macd_series", "14,28,10", d.MACDValues142810)
+	writeSeries(sb, l, "rsi_series", 14, d.RSI14Values)
+	writeSeries(sb, l, "rsi_series", 21, d.RSI21Values)
+	writeLongerTermTail(sb, l, d)
+}
+
+// writeLongerTerm1dSection 1天数据
+func writeLongerTerm1dSection(sb *strings.Builder, l reportLocale, data *Data) {
+	d := data.LongerTerm1d
+	if d == nil {
+		return
+	}
+	writeLongerTermHead(sb, l, "tf_1d", d)
+	writeSeries(sb, l, "macd_series", "12,26,9", d.MACDValues12269)
+	writeSeries(sb, l, "rsi_series", 14, d.RSI14Values)
+	writeLongerTermTail(sb, l, d)
+}
@@ -0,0 +1,75 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// warmupKlines 指标预热所需的最少K线数量（EMA50 + MACD(26,9) 的余量）
+const warmupKlines = 60
+
+// restFallbackCooldown 同一交易对/周期两次REST回补之间的最小间隔，避免冷启动时频繁请求
+const restFallbackCooldown = 15 * time.Second
+
+// restFallbackState 记录各 symbol/周期 最近一次REST回补时间
+var restFallbackState sync.Map // "SYMBOL_interval" -> time.Time
+
+// warmupFor 返回指定周期需要的预热K线数量（不超过缓冲容量）
+func (m *Monitor) warmupFor(_time string) int {
+	need := warmupKlines
+	if c := m.capacityFor(_time); need > c {
+		need = c
+	}
+	return need
+}
+
+// backfillFromREST WebSocket缓存为空或不足以预热指标时，通过 /fapi/v1/klines（或 MonitorConfig.KlineSource）回补
+// 回补结果与缓存中更新的K线合并（WebSocket推送的最新K线优先），并写回缓存
+func (m *Monitor) backfillFromREST(symbol, _time string) ([]Kline, error) {
+	symbol = strings.ToUpper(symbol)
+	key := symbol + "_" + _time
+	if last, ok := restFallbackState.Load(key); ok && clockNow().Sub(last.(time.Time)) < restFallbackCooldown {
+		return nil, fmt.Errorf("%s %s REST回补冷却中", symbol, _time)
+	}
+	restFallbackState.Store(key, clockNow())
+
+	var klines []Kline
+	var err error
+	if m.config.KlineSource != nil {
+		klines, err = m.config.KlineSource.Klines(symbol, _time, m.capacityFor(_time))
+	} else {
+		klines, err = NewAPIClient().GetKlines(symbol, _time, m.capacityFor(_time))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ring := m.klineRing(symbol, _time)
+	merged := mergeKlines(klines, ring.Snapshot())
+	ring.Reset(merged)
+	log.Printf("🔄 %s %s 缓存不足，已通过REST回补 %d 根K线", symbol, _time, len(klines))
+	return ring.Snapshot(), nil
+}
+
+// mergeKlines 以REST数据为基础，追加/覆盖缓存中开盘时间不早于REST最后一根的K线
+func mergeKlines(rest, cached []Kline) []Kline {
+	if len(rest) == 0 {
+		return cached
+	}
+	merged := make([]Kline, len(rest), len(rest)+len(cached))
+	copy(merged, rest)
+	lastOpen := rest[len(rest)-1].OpenTime
+	for _, k := range cached {
+		switch {
+		case k.OpenTime == lastOpen:
+			merged[len(merged)-1] = k
+		case k.OpenTime > lastOpen:
+			merged = append(merged, k)
+			lastOpen = k.OpenTime
+		}
+	}
+	return merged
+}
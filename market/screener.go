@@ -0,0 +1,322 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScoreWeights 筛选评分权重：score = VolumeRatio×min(量比,上限) + Volatility×波动率 + Momentum×|1h涨跌| + Trend×成交量趋势
+type ScoreWeights struct {
+	VolumeRatio float64 `json:"volume_ratio"`
+	Volatility  float64 `json:"volatility"`
+	Momentum    float64 `json:"momentum"`
+	Trend       float64 `json:"trend"`
+}
+
+// ScreenerConfig 筛选器配置
+type ScreenerConfig struct {
+	Interval       time.Duration                   // 刷新间隔，默认 5m
+	KlineInterval  string                          // 计算特征使用的K线周期，默认 15m
+	MinQuoteVolume float64                         // 24h 成交额下限（USDT），默认 2000 万
+	MaxSymbols     int                             // 按成交额取前N个交易对计算特征（控制请求量），默认 80
+	Weights        ScoreWeights                    // 默认评分公式的权重
+	Score          func(f *SymbolFeatures) float64 // 自定义评分公式（设置后忽略 Weights）
+}
+
+func (c ScreenerConfig) withDefaults() ScreenerConfig {
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Minute
+	}
+	if c.KlineInterval == "" {
+		c.KlineInterval = "15m"
+	}
+	if c.MinQuoteVolume <= 0 {
+		c.MinQuoteVolume = 20_000_000
+	}
+	if c.MaxSymbols <= 0 {
+		c.MaxSymbols = 80
+	}
+	if c.Weights == (ScoreWeights{}) {
+		c.Weights = ScoreWeights{VolumeRatio: 10, Volatility: 20, Momentum: 10, Trend: 5}
+	}
+	return c
+}
+
+// screenerKlineLimit 计算特征所需的K线数量（SMA20/波动率20 + 4h 涨跌幅）
+const screenerKlineLimit = 40
+
+// volumeRatioCap 量比计分上限，避免单根异常K线主导排名
+const volumeRatioCap = 5.0
+
+// ScoredSymbol 带评分的交易对特征
+type ScoredSymbol struct {
+	SymbolFeatures
+	QuoteVolume24h float64 `json:"quote_volume_24h"`
+	Score          float64 `json:"score"`
+}
+
+// Screener 定期拉取全部合约交易对的24h行情，按成交额预筛后计算 SymbolFeatures 并评分排名
+type Screener struct {
+	cfg    ScreenerConfig
+	client *APIClient
+
+	mu        sync.RWMutex
+	ranked    []ScoredSymbol
+	updatedAt time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewScreener 创建筛选器
+func NewScreener(cfg ScreenerConfig) *Screener {
+	return &Screener{cfg: cfg.withDefaults(), client: NewAPIClient(), stop: make(chan struct{})}
+}
+
+// Start 立即刷新一次，之后按 Interval 定期刷新（非阻塞）
+func (s *Screener) Start() {
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Refresh(); err != nil {
+				log.Printf("⚠️  交易对筛选刷新失败: %v", err)
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止定期刷新
+func (s *Screener) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Refresh 拉取行情、计算特征并重新排名
+func (s *Screener) Refresh() error {
+	tickers, err := s.client.GetTickers24hr()
+	if err != nil {
+		return fmt.Errorf("获取24h行情失败: %w", err)
+	}
+	tradable, err := s.tradableSymbols()
+	if err != nil {
+		log.Printf("⚠️  获取合约列表失败，跳过交易状态过滤: %v", err)
+	}
+
+	type candidate struct {
+		symbol      string
+		quoteVolume float64
+	}
+	var candidates []candidate
+	for _, t := range tickers {
+		if !strings.HasSuffix(t.Symbol, "USDT") || (tradable != nil && !tradable[t.Symbol]) {
+			continue
+		}
+		qv, err := strconv.ParseFloat(t.QuoteVolume, 64)
+		if err != nil || qv < s.cfg.MinQuoteVolume {
+			continue
+		}
+		candidates = append(candidates, candidate{t.Symbol, qv})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].quoteVolume > candidates[j].quoteVolume })
+	if len(candidates) > s.cfg.MaxSymbols {
+		candidates = candidates[:s.cfg.MaxSymbols]
+	}
+
+	now := clockNow()
+	ranked := make([]ScoredSymbol, 0, len(candidates))
+	for _, c := range candidates {
+		klines, err := s.client.GetKlines(c.symbol, s.cfg.KlineInterval, screenerKlineLimit)
+		if err != nil {
+			log.Printf("⚠️  筛选 %s 获取K线失败: %v", c.symbol, err)
+			continue
+		}
+		f, ok := computeSymbolFeatures(c.symbol, klines, s.cfg.KlineInterval, now)
+		if !ok {
+			continue
+		}
+		ranked = append(ranked, ScoredSymbol{SymbolFeatures: *f, QuoteVolume24h: c.quoteVolume, Score: s.score(f)})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+
+	s.mu.Lock()
+	s.ranked = ranked
+	s.updatedAt = now
+	s.mu.Unlock()
+	log.Printf("🔭 交易对筛选完成: %d 个候选，已评分 %d 个", len(candidates), len(ranked))
+	return nil
+}
+
+// tradableSymbols 处于交易状态的U本位永续合约
+func (s *Screener) tradableSymbols() (map[string]bool, error) {
+	info, err := s.client.GetExchangeInfo()
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(info.Symbols))
+	for _, sym := range info.Symbols {
+		if sym.Status == "TRADING" && sym.ContractType == "PERPETUAL" {
+			set[sym.Symbol] = true
+		}
+	}
+	return set, nil
+}
+
+// score 按配置计算评分
+func (s *Screener) score(f *SymbolFeatures) float64 {
+	if s.cfg.Score != nil {
+		return s.cfg.Score(f)
+	}
+	w := s.cfg.Weights
+	return w.VolumeRatio*math.Min(f.VolumeRatio20, volumeRatioCap) +
+		w.Volatility*f.Volatility20 +
+		w.Momentum*math.Abs(f.PriceChange1H) +
+		w.Trend*math.Min(f.VolumeTrend, volumeRatioCap)
+}
+
+// TopN 评分最高的 n 个交易对（n<=0 返回全部）
+func (s *Screener) TopN(n int) []ScoredSymbol {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if n <= 0 || n > len(s.ranked) {
+		n = len(s.ranked)
+	}
+	return append([]ScoredSymbol(nil), s.ranked[:n]...)
+}
+
+// TopSymbols 评分最高的 n 个交易对代码
+func (s *Screener) TopSymbols(n int) []string {
+	top := s.TopN(n)
+	symbols := make([]string, len(top))
+	for i, t := range top {
+		symbols[i] = t.Symbol
+	}
+	return symbols
+}
+
+// UpdatedAt 最近一次刷新完成的时间
+func (s *Screener) UpdatedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updatedAt
+}
+
+// computeSymbolFeatures 由K线计算交易对特征（涨跌幅、波动率为百分比）
+func computeSymbolFeatures(symbol string, klines []Kline, interval string, now time.Time) (*SymbolFeatures, bool) {
+	const window = 20
+	if len(klines) < window+1 {
+		return nil, false
+	}
+	step, err := intervalDuration(interval)
+	if err != nil {
+		return nil, false
+	}
+	n := len(klines)
+	last := klines[n-1]
+	change := func(d time.Duration) float64 {
+		back := int(d / step)
+		if back <= 0 || back >= n || klines[n-1-back].Close == 0 {
+			return 0
+		}
+		prev := klines[n-1-back].Close
+		return (last.Close - prev) / prev * 100
+	}
+	avgVolume := func(from, to int) float64 {
+		if from < 0 {
+			from = 0
+		}
+		if to <= from {
+			return 0
+		}
+		sum := 0.0
+		for _, k := range klines[from:to] {
+			sum += k.Volume
+		}
+		return sum / float64(to-from)
+	}
+	sma := func(period int) float64 {
+		sum := 0.0
+		for _, k := range klines[n-period:] {
+			sum += k.Close
+		}
+		return sum / float64(period)
+	}
+	ratio := func(a, b float64) float64 {
+		if b == 0 {
+			return 0
+		}
+		return a / b
+	}
+
+	high, low := 0.0, math.MaxFloat64
+	var returns []float64
+	for i := n - window; i < n; i++ {
+		high = math.Max(high, klines[i].High)
+		low = math.Min(low, klines[i].Low)
+		if prev := klines[i-1].Close; prev > 0 {
+			returns = append(returns, (klines[i].Close-prev)/prev)
+		}
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns))
+
+	f := &SymbolFeatures{
+		Symbol:           symbol,
+		Timestamp:        now,
+		Price:            last.Close,
+		PriceChange15Min: change(15 * time.Minute),
+		PriceChange1H:    change(time.Hour),
+		PriceChange4H:    change(4 * time.Hour),
+		Volume:           last.Volume,
+		VolumeRatio5:     ratio(last.Volume, avgVolume(n-6, n-1)),
+		VolumeRatio20:    ratio(last.Volume, avgVolume(n-21, n-1)),
+		VolumeTrend:      ratio(avgVolume(n-5, n), avgVolume(n-25, n-5)),
+		RSI14:            calculateRSI(klines, 14),
+		SMA5:             sma(5),
+		SMA10:            sma(10),
+		SMA20:            sma(20),
+		HighLowRatio:     ratio(high, low),
+		Volatility20:     math.Sqrt(variance) * 100,
+	}
+	if high > low {
+		f.PositionInRange = (last.Close - low) / (high - low)
+	}
+	return f, true
+}
+
+var (
+	screenerMu      sync.RWMutex
+	defaultScreener *Screener
+)
+
+// SetDefaultScreener 设置默认筛选器（交易员可从中获取候选币种）
+func SetDefaultScreener(s *Screener) {
+	screenerMu.Lock()
+	defer screenerMu.Unlock()
+	defaultScreener = s
+}
+
+// DefaultScreener 返回默认筛选器（未设置时为 nil）
+func DefaultScreener() *Screener {
+	screenerMu.RLock()
+	defer screenerMu.RUnlock()
+	return defaultScreener
+}
@@ -0,0 +1,137 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sentimentPeriod 多空比统计周期
+	sentimentPeriod = "1h"
+	// sentimentLimit 多空比序列长度
+	sentimentLimit = 12
+	// sentimentRefreshInterval 缓存刷新间隔（币安该数据最快5分钟更新一次）
+	sentimentRefreshInterval = 5 * time.Minute
+)
+
+// SentimentData 多空持仓情绪数据
+type SentimentData struct {
+	Period string `json:"period"`
+
+	// 大户持仓多空比（topLongShortPositionRatio，按持仓量计算）
+	TopPositionRatio  float64   `json:"top_position_ratio"`
+	TopLongPosition   float64   `json:"top_long_position"` // 大户多头持仓占比
+	TopPositionSeries []float64 `json:"top_position_series,omitempty"`
+
+	// 全市场账户多空比（globalLongShortAccountRatio，按账户数计算）
+	GlobalAccountRatio  float64   `json:"global_account_ratio"`
+	GlobalLongAccount   float64   `json:"global_long_account"` // 多头账户占比
+	GlobalAccountSeries []float64 `json:"global_account_series,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type sentimentEntry struct {
+	data      *SentimentData
+	fetchedAt time.Time
+}
+
+// sentimentCache 各交易对情绪数据缓存
+var sentimentCache sync.Map
+
+// longShortRatioPoint 多空比接口返回项
+type longShortRatioPoint struct {
+	Symbol         string `json:"symbol"`
+	LongShortRatio string `json:"longShortRatio"`
+	LongAccount    string `json:"longAccount"`
+	ShortAccount   string `json:"shortAccount"`
+	Timestamp      int64  `json:"timestamp"`
+}
+
+// getSentimentData 获取多空比数据（按 sentimentRefreshInterval 缓存）
+// 拉取失败时返回上一次的缓存数据
+func getSentimentData(symbol string) (*SentimentData, error) {
+	var cached *sentimentEntry
+	if v, ok := sentimentCache.Load(symbol); ok {
+		cached = v.(*sentimentEntry)
+		if clockNow().Sub(cached.fetchedAt) < sentimentRefreshInterval {
+			return cached.data, nil
+		}
+	}
+
+	data, err := fetchSentimentData(symbol)
+	if err != nil {
+		if cached != nil {
+			return cached.data, nil
+		}
+		return nil, err
+	}
+	sentimentCache.Store(symbol, &sentimentEntry{data: data, fetchedAt: clockNow()})
+	return data, nil
+}
+
+// fetchSentimentData 拉取大户持仓多空比与全市场账户多空比
+func fetchSentimentData(symbol string) (*SentimentData, error) {
+	top, err := fetchLongShortRatio("topLongShortPositionRatio", symbol)
+	if err != nil {
+		return nil, fmt.Errorf("获取大户持仓多空比失败: %w", err)
+	}
+	global, err := fetchLongShortRatio("globalLongShortAccountRatio", symbol)
+	if err != nil {
+		return nil, fmt.Errorf("获取账户多空比失败: %w", err)
+	}
+
+	data := &SentimentData{Period: sentimentPeriod, UpdatedAt: clockNow()}
+	data.TopPositionSeries, data.TopPositionRatio, data.TopLongPosition = parseLongShortSeries(top)
+	data.GlobalAccountSeries, data.GlobalAccountRatio, data.GlobalLongAccount = parseLongShortSeries(global)
+	return data, nil
+}
+
+// fetchLongShortRatio 请求 /futures/data 下的多空比接口（结果从旧到新）
+func fetchLongShortRatio(endpoint, symbol string) ([]longShortRatioPoint, error) {
+	url := fmt.Sprintf("https://fapi.binance.com/futures/data/%s?symbol=%s&period=%s&limit=%d", endpoint, symbol, sentimentPeriod, sentimentLimit)
+	body, err := binanceGet(url, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []longShortRatioPoint
+	if err := json.Unmarshal(body, &points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// parseLongShortSeries 返回多空比序列、最新多空比与最新多头占比
+func parseLongShortSeries(points []longShortRatioPoint) (series []float64, latest, longShare float64) {
+	for _, p := range points {
+		ratio, err := strconv.ParseFloat(p.LongShortRatio, 64)
+		if err != nil {
+			continue
+		}
+		series = append(series, ratio)
+		latest = ratio
+		longShare, _ = strconv.ParseFloat(p.LongAccount, 64)
+	}
+	return series, latest, longShare
+}
+
+// writeSentiment 输出多空比情绪数据
+func writeSentiment(sb *strings.Builder, l reportLocale, s *SentimentData) {
+	if s == nil {
+		return
+	}
+	sb.WriteString(l.f("sentiment_summary",
+		s.Period, s.TopPositionRatio, s.TopLongPosition*100, s.GlobalAccountRatio, s.GlobalLongAccount*100))
+	if len(s.TopPositionSeries) > 0 {
+		sb.WriteString(l.f("sentiment_top_series", formatFloatSlice(s.TopPositionSeries)))
+	}
+	if len(s.GlobalAccountSeries) > 0 {
+		sb.WriteString(l.f("sentiment_global_series", formatFloatSlice(s.GlobalAccountSeries)))
+	}
+	sb.WriteString("\n")
+}
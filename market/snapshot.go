@@ -0,0 +1,222 @@
+package market
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Snapshot 一条已记录的市场数据快照
+type Snapshot struct {
+	Symbol string    `json:"symbol"`
+	Time   time.Time `json:"time"`
+	Data   *Data     `json:"data"`
+}
+
+// SnapshotStore 快照存储
+type SnapshotStore interface {
+	// Save 保存快照（同一交易对同一时间覆盖写入）
+	Save(s Snapshot) error
+	// Load 读取 [from, to] 区间内的快照（按时间升序），to 为零值表示不限
+	Load(symbol string, from, to time.Time) ([]Snapshot, error)
+	Close() error
+}
+
+// SQLiteSnapshotStore 基于 SQLite 的快照存储
+type SQLiteSnapshotStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteSnapshotStore 打开（不存在则创建）快照数据库
+func NewSQLiteSnapshotStore(path string) (*SQLiteSnapshotStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开快照数据库失败: %w", err)
+	}
+	_, _ = db.Exec("PRAGMA journal_mode=WAL")
+	_, _ = db.Exec("PRAGMA busy_timeout=5000")
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS market_snapshots (
+		symbol TEXT NOT NULL,
+		ts INTEGER NOT NULL,
+		data TEXT NOT NULL,
+		PRIMARY KEY (symbol, ts)
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建快照表失败: %w", err)
+	}
+	return &SQLiteSnapshotStore{db: db}, nil
+}
+
+// Save 保存快照
+func (s *SQLiteSnapshotStore) Save(snap Snapshot) error {
+	b, err := json.Marshal(snap.Data)
+	if err != nil {
+		return fmt.Errorf("序列化快照失败: %w", err)
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO market_snapshots (symbol, ts, data) VALUES (?, ?, ?)`,
+		snap.Symbol, snap.Time.UnixMilli(), string(b))
+	return err
+}
+
+// Load 读取区间内的快照
+func (s *SQLiteSnapshotStore) Load(symbol string, from, to time.Time) ([]Snapshot, error) {
+	toMs := int64(1<<63 - 1)
+	if !to.IsZero() {
+		toMs = to.UnixMilli()
+	}
+	rows, err := s.db.Query(`SELECT ts, data FROM market_snapshots WHERE symbol = ? AND ts >= ? AND ts <= ? ORDER BY ts`,
+		symbol, from.UnixMilli(), toMs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var snaps []Snapshot
+	for rows.Next() {
+		var ts int64
+		var raw string
+		if err := rows.Scan(&ts, &raw); err != nil {
+			return nil, err
+		}
+		var data Data
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return nil, fmt.Errorf("解析 %s 快照失败: %w", symbol, err)
+		}
+		snaps = append(snaps, Snapshot{Symbol: symbol, Time: time.UnixMilli(ts), Data: &data})
+	}
+	return snaps, rows.Err()
+}
+
+// Close 关闭数据库
+func (s *SQLiteSnapshotStore) Close() error {
+	return s.db.Close()
+}
+
+// JSONSnapshotStore 基于 JSON Lines 文件的快照存储（每个交易对一个文件：<dir>/<SYMBOL>.jsonl）
+type JSONSnapshotStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewJSONSnapshotStore 创建 JSON Lines 快照存储
+func NewJSONSnapshotStore(dir string) (*JSONSnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建快照目录失败: %w", err)
+	}
+	return &JSONSnapshotStore{dir: dir}, nil
+}
+
+func (s *JSONSnapshotStore) path(symbol string) string {
+	return filepath.Join(s.dir, strings.ToUpper(symbol)+".jsonl")
+}
+
+// Save 追加一行快照（同一时间的重复记录在读取时以最后一条为准）
+func (s *JSONSnapshotStore) Save(snap Snapshot) error {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("序列化快照失败: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path(snap.Symbol), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// Load 读取区间内的快照
+func (s *JSONSnapshotStore) Load(symbol string, from, to time.Time) ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.path(symbol))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	byTime := make(map[int64]Snapshot)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var snap Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snap); err != nil {
+			return nil, fmt.Errorf("解析 %s 快照失败: %w", symbol, err)
+		}
+		if snap.Time.Before(from) || (!to.IsZero() && snap.Time.After(to)) {
+			continue
+		}
+		byTime[snap.Time.UnixMilli()] = snap
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	snaps := make([]Snapshot, 0, len(byTime))
+	for _, snap := range byTime {
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Time.Before(snaps[j].Time) })
+	return snaps, nil
+}
+
+// Close 无需释放资源
+func (s *JSONSnapshotStore) Close() error { return nil }
+
+// Recorder 将每次获取到的市场数据写入快照存储
+type Recorder struct {
+	store SnapshotStore
+}
+
+// NewRecorder 创建快照记录器
+func NewRecorder(store SnapshotStore) *Recorder {
+	return &Recorder{store: store}
+}
+
+// Record 记录一条快照（时间取 data.Timestamp，为空时取当前时间）
+func (r *Recorder) Record(data *Data) error {
+	if r == nil || data == nil {
+		return nil
+	}
+	ts := data.Timestamp
+	if ts.IsZero() {
+		ts = clockNow()
+	}
+	return r.store.Save(Snapshot{Symbol: data.Symbol, Time: ts, Data: data})
+}
+
+var (
+	recorderMu sync.RWMutex
+	recorder   *Recorder
+)
+
+// SetRecorder 设置全局快照记录器（nil 表示关闭记录），Monitor 每次计算出市场数据后都会记录
+func SetRecorder(r *Recorder) {
+	recorderMu.Lock()
+	defer recorderMu.Unlock()
+	recorder = r
+}
+
+// recordSnapshot 记录快照（未设置记录器时忽略）
+func recordSnapshot(data *Data) {
+	recorderMu.RLock()
+	r := recorder
+	recorderMu.RUnlock()
+	if r == nil {
+		return
+	}
+	if err := r.Record(data); err != nil {
+		log.Printf("⚠️  记录 %s 市场快照失败: %v", data.Symbol, err)
+	}
+}
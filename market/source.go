@@ -0,0 +1,185 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内置数据源名称
+const (
+	SourceBinance = "binance"
+	SourceOKX     = "okx"
+	SourceBybit   = "bybit"
+)
+
+// Source 行情数据源（交易所）抽象
+// 各方法接收的 symbol 均为经 Normalize 转换后的交易所原生格式
+type Source interface {
+	// Name 数据源名称（如 binance/okx/bybit）
+	Name() string
+	// Klines 获取K线（从旧到新），interval 使用币安格式（3m/15m/1h/4h/1d）
+	Klines(symbol, interval string, limit int) ([]Kline, error)
+	// OpenInterest 获取当前持仓量（以币计）
+	OpenInterest(symbol string) (float64, error)
+	// FundingRate 获取当前资金费率
+	FundingRate(symbol string) (float64, error)
+	// Normalize 将 BTCUSDT 形式的交易对转换为交易所原生格式
+	Normalize(symbol string) string
+}
+
+var (
+	sourcesMu     sync.RWMutex
+	sources       = map[string]Source{}
+	symbolSources = map[string]string{} // 交易对 -> 数据源名称
+)
+
+func init() {
+	RegisterSource(BinanceSource{})
+	RegisterSource(NewOKXSource())
+	RegisterSource(NewBybitSource())
+	RegisterSource(NewBinanceSpotSource())
+	RegisterSource(NewBinanceCoinMSource())
+}
+
+// RegisterSource 注册数据源（同名覆盖）
+func RegisterSource(src Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources[strings.ToLower(src.Name())] = src
+}
+
+// SourceByName 按名称获取数据源，名称为空时返回币安
+func SourceByName(name string) (Source, error) {
+	if name == "" {
+		name = SourceBinance
+	}
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	src, ok := sources[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("未知的行情数据源: %s", name)
+	}
+	return src, nil
+}
+
+// SetSymbolSource 为交易对指定数据源（name 为空时恢复默认）
+func SetSymbolSource(symbol, name string) error {
+	symbol = Normalize(symbol)
+	if name == "" {
+		sourcesMu.Lock()
+		delete(symbolSources, symbol)
+		sourcesMu.Unlock()
+		return nil
+	}
+	if _, err := SourceByName(name); err != nil {
+		return err
+	}
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	symbolSources[symbol] = strings.ToLower(name)
+	return nil
+}
+
+// SourceFor 返回交易对使用的数据源（未指定时为币安，币本位合约代码使用币本位数据源）
+func SourceFor(symbol string) Source {
+	sourcesMu.RLock()
+	name := symbolSources[Normalize(symbol)]
+	sourcesMu.RUnlock()
+	if name == "" && MarketTypeOf(symbol) == MarketCoinM {
+		name = SourceBinanceCoinM
+	}
+	src, err := SourceByName(name)
+	if err != nil {
+		src, _ = SourceByName(SourceBinance)
+	}
+	return src
+}
+
+// BinanceSource 币安U本位合约数据源（默认）
+type BinanceSource struct{}
+
+// Name 数据源名称
+func (BinanceSource) Name() string { return SourceBinance }
+
+// Klines 通过 REST 获取K线
+func (BinanceSource) Klines(symbol, interval string, limit int) ([]Kline, error) {
+	return NewAPIClient().GetKlines(symbol, interval, limit)
+}
+
+// OpenInterest 获取当前持仓量
+func (BinanceSource) OpenInterest(symbol string) (float64, error) {
+	return fetchBinanceOpenInterest(symbol)
+}
+
+// FundingRate 获取当前资金费率
+func (BinanceSource) FundingRate(symbol string) (float64, error) {
+	fd, err := fetchPremiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return fd.Current, nil
+}
+
+// Normalize 币安使用 BTCUSDT 格式
+func (BinanceSource) Normalize(symbol string) string { return Normalize(symbol) }
+
+// getSourceOIData 从非币安数据源获取持仓量，并按 "数据源:交易对" 记录采样历史（避免与币安历史混淆）
+func getSourceOIData(src Source, symbol string, now time.Time) (*OIData, error) {
+	oi, err := src.OpenInterest(src.Normalize(symbol))
+	if err != nil {
+		return nil, err
+	}
+	samples := recordOISample(src.Name()+":"+symbol, oi, now)
+	return buildOIData(oi, samples), nil
+}
+
+// getSourceFundingData 从非币安数据源获取资金费率（仅当前费率，按默认结算间隔年化）
+func getSourceFundingData(src Source, symbol string) (*FundingData, error) {
+	rate, err := src.FundingRate(src.Normalize(symbol))
+	if err != nil {
+		return nil, err
+	}
+	return &FundingData{
+		Current:    rate,
+		Interval:   defaultFundingInterval,
+		Annualized: rate * (float64(24*time.Hour) / float64(defaultFundingInterval)) * 365 * 100,
+	}, nil
+}
+
+// splitQuote 将 BTCUSDT 拆分为 BTC 与 USDT
+func splitQuote(symbol string) (base, quote string) {
+	symbol = strings.ToUpper(symbol)
+	for _, q := range []string{"USDT", "USDC", "USD"} {
+		if strings.HasSuffix(symbol, q) && len(symbol) > len(q) {
+			return strings.TrimSuffix(symbol, q), q
+		}
+	}
+	return symbol, "USDT"
+}
+
+// intervalDuration 币安格式周期对应的时长
+func intervalDuration(interval string) (time.Duration, error) {
+	switch interval {
+	case "1m":
+		return time.Minute, nil
+	case "3m":
+		return 3 * time.Minute, nil
+	case "5m":
+		return 5 * time.Minute, nil
+	case "15m":
+		return 15 * time.Minute, nil
+	case "30m":
+		return 30 * time.Minute, nil
+	case "1h":
+		return time.Hour, nil
+	case "2h":
+		return 2 * time.Hour, nil
+	case "4h":
+		return 4 * time.Hour, nil
+	case "1d":
+		return 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("不支持的K线周期: %s", interval)
+}
@@ -0,0 +1,151 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// bybitBaseURL Bybit 公共接口地址
+const bybitBaseURL = "https://api.bybit.com"
+
+// bybitMaxCandles Bybit 单次K线请求上限
+const bybitMaxCandles = 1000
+
+// bybitCodeParamError Bybit 参数错误（交易对不存在时 retMsg 含 symbol）
+const bybitCodeParamError = 10001
+
+// BybitSource Bybit USDT 永续（linear）数据源
+type BybitSource struct {
+	baseURL string
+}
+
+// NewBybitSource 创建 Bybit 数据源
+func NewBybitSource() *BybitSource {
+	return &BybitSource{baseURL: bybitBaseURL}
+}
+
+// Name 数据源名称
+func (s *BybitSource) Name() string { return SourceBybit }
+
+// Normalize Bybit 与币安同为 BTCUSDT 格式
+func (s *BybitSource) Normalize(symbol string) string { return Normalize(symbol) }
+
+// bybitInterval 币安周期 -> Bybit 周期（分钟数或 D）
+func bybitInterval(interval string) (string, error) {
+	switch interval {
+	case "1m":
+		return "1", nil
+	case "3m":
+		return "3", nil
+	case "5m":
+		return "5", nil
+	case "15m":
+		return "15", nil
+	case "30m":
+		return "30", nil
+	case "1h":
+		return "60", nil
+	case "2h":
+		return "120", nil
+	case "4h":
+		return "240", nil
+	case "1d":
+		return "D", nil
+	}
+	return "", fmt.Errorf("Bybit 不支持的K线周期: %s", interval)
+}
+
+// get 请求 Bybit 公共接口并解析 result.list 字段
+func (s *BybitSource) get(path string, out interface{}) error {
+	body, err := httpGet(s.baseURL+path, nil, 0)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			List json.RawMessage `json:"list"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("解析Bybit响应失败: %w", err)
+	}
+	if envelope.RetCode == bybitCodeParamError && strings.Contains(strings.ToLower(envelope.RetMsg), "symbol") {
+		return fmt.Errorf("Bybit返回错误 (retCode %d): %s: %w", envelope.RetCode, envelope.RetMsg, ErrSymbolNotFound)
+	}
+	if envelope.RetCode != 0 {
+		return fmt.Errorf("Bybit返回错误 (retCode %d): %s", envelope.RetCode, envelope.RetMsg)
+	}
+	return json.Unmarshal(envelope.Result.List, out)
+}
+
+// Klines 获取K线（Bybit 返回从新到旧，这里反转为从旧到新）
+func (s *BybitSource) Klines(symbol, interval string, limit int) ([]Kline, error) {
+	iv, err := bybitInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	step, err := intervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), bybitMaxCandles)
+
+	var rows [][]string
+	path := fmt.Sprintf("/v5/market/kline?category=linear&symbol=%s&interval=%s&limit=%d", symbol, iv, limit)
+	if err := s.get(path, &rows); err != nil {
+		return nil, err
+	}
+
+	klines := make([]Kline, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		r := rows[i]
+		if len(r) < 7 {
+			continue
+		}
+		openTime, _ := strconv.ParseInt(r[0], 10, 64)
+		k := Kline{OpenTime: openTime, CloseTime: openTime + step.Milliseconds() - 1}
+		k.Open, _ = strconv.ParseFloat(r[1], 64)
+		k.High, _ = strconv.ParseFloat(r[2], 64)
+		k.Low, _ = strconv.ParseFloat(r[3], 64)
+		k.Close, _ = strconv.ParseFloat(r[4], 64)
+		k.Volume, _ = strconv.ParseFloat(r[5], 64)
+		k.QuoteVolume, _ = strconv.ParseFloat(r[6], 64)
+		klines = append(klines, k)
+	}
+	return klines, nil
+}
+
+// OpenInterest 获取持仓量（以币计）
+func (s *BybitSource) OpenInterest(symbol string) (float64, error) {
+	var rows []struct {
+		OpenInterest string `json:"openInterest"`
+		Timestamp    string `json:"timestamp"`
+	}
+	path := fmt.Sprintf("/v5/market/open-interest?category=linear&symbol=%s&intervalTime=5min&limit=1", symbol)
+	if err := s.get(path, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("Bybit 未返回 %s 的持仓量", symbol)
+	}
+	return strconv.ParseFloat(rows[0].OpenInterest, 64)
+}
+
+// FundingRate 获取当前资金费率
+func (s *BybitSource) FundingRate(symbol string) (float64, error) {
+	var rows []struct {
+		Symbol      string `json:"symbol"`
+		FundingRate string `json:"fundingRate"`
+	}
+	if err := s.get("/v5/market/tickers?category=linear&symbol="+symbol, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("Bybit 未返回 %s 的资金费率", symbol)
+	}
+	return strconv.ParseFloat(rows[0].FundingRate, 64)
+}
@@ -0,0 +1,186 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SourceBinanceCoinM 币安币本位合约数据源名称
+const SourceBinanceCoinM = "binance_coinm"
+
+// coinMBaseURL 币安币本位合约公共接口地址（USDⓈ-M 为 fapi，币本位为 dapi）
+const coinMBaseURL = "https://dapi.binance.com"
+
+// coinMMaxKlines 币本位单次K线请求上限
+const coinMMaxKlines = 1500
+
+// BinanceCoinMSource 币安币本位合约数据源（BTCUSD_PERP 等，合约面值以美元计）
+type BinanceCoinMSource struct {
+	baseURL string
+
+	contractSizes sync.Map // symbol -> 合约面值（USD）
+}
+
+// NewBinanceCoinMSource 创建币本位合约数据源
+func NewBinanceCoinMSource() *BinanceCoinMSource {
+	return &BinanceCoinMSource{baseURL: coinMBaseURL}
+}
+
+// Name 数据源名称
+func (s *BinanceCoinMSource) Name() string { return SourceBinanceCoinM }
+
+// MarketType 币本位合约
+func (s *BinanceCoinMSource) MarketType() MarketType { return MarketCoinM }
+
+// Normalize BTC/BTCUSDT/BTCUSD -> BTCUSD_PERP（已带合约后缀时保持不变）
+func (s *BinanceCoinMSource) Normalize(symbol string) string {
+	return coinMSymbol(symbol)
+}
+
+// coinMSymbol 转换为币本位永续合约代码
+func coinMSymbol(symbol string) string {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if isCoinMSymbol(symbol) {
+		return symbol
+	}
+	base, _ := splitQuote(symbol)
+	return base + "USD_PERP"
+}
+
+// isCoinMSymbol 是否为币本位合约代码（永续 _PERP 或交割合约 _YYMMDD）
+func isCoinMSymbol(symbol string) bool {
+	return strings.Contains(symbol, "_")
+}
+
+// get 请求 dapi 公共接口并解析响应
+func (s *BinanceCoinMSource) get(path string, q url.Values, weight int, out interface{}) error {
+	body, err := binanceGet(fmt.Sprintf("%s%s?%s", s.baseURL, path, q.Encode()), weight)
+	if err != nil {
+		return fmt.Errorf("币安币本位接口请求失败: %w", err)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// contractSize 合约面值（BTC 为 100 USD，其余多为 10 USD），从 exchangeInfo 读取并缓存
+func (s *BinanceCoinMSource) contractSize(symbol string) (float64, error) {
+	if v, ok := s.contractSizes.Load(symbol); ok {
+		return v.(float64), nil
+	}
+	var info struct {
+		Symbols []struct {
+			Symbol       string  `json:"symbol"`
+			ContractSize float64 `json:"contractSize"`
+		} `json:"symbols"`
+	}
+	if err := s.get("/dapi/v1/exchangeInfo", url.Values{}, 1, &info); err != nil {
+		return 0, fmt.Errorf("获取币本位合约信息失败: %w", err)
+	}
+	for _, sym := range info.Symbols {
+		if sym.ContractSize > 0 {
+			s.contractSizes.Store(sym.Symbol, sym.ContractSize)
+		}
+	}
+	if v, ok := s.contractSizes.Load(symbol); ok {
+		return v.(float64), nil
+	}
+	return 0, fmt.Errorf("未找到币本位合约 %s", symbol)
+}
+
+// Klines 获取K线
+// dapi 的成交量以张计、第8列为以币计的成交量，这里统一为 Volume 以币计、QuoteVolume 以美元计
+func (s *BinanceCoinMSource) Klines(symbol, interval string, limit int) ([]Kline, error) {
+	if limit <= 0 || limit > coinMMaxKlines {
+		limit = coinMMaxKlines
+	}
+	size, err := s.contractSize(symbol)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	q.Set("interval", interval)
+	q.Set("limit", strconv.Itoa(limit))
+	var raw []KlineResponse
+	if err := s.get("/dapi/v1/klines", q, klinesWeight(limit), &raw); err != nil {
+		return nil, fmt.Errorf("获取币本位K线失败: %w", err)
+	}
+	klines := make([]Kline, 0, len(raw))
+	for _, kr := range raw {
+		k, err := parseKline(kr)
+		if err != nil {
+			continue
+		}
+		contracts, takerContracts := k.Volume, k.TakerBuyBaseVolume
+		k.Volume, k.QuoteVolume = k.QuoteVolume, contracts*size
+		k.TakerBuyBaseVolume, k.TakerBuyQuoteVolume = k.TakerBuyQuoteVolume, takerContracts*size
+		klines = append(klines, k)
+	}
+	return klines, nil
+}
+
+// coinMPremiumIndex dapi premiumIndex 返回数组（即使指定了 symbol）
+type coinMPremiumIndex struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	LastFundingRate string `json:"lastFundingRate"`
+}
+
+func (s *BinanceCoinMSource) premiumIndex(symbol string) (*coinMPremiumIndex, error) {
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	var rows []coinMPremiumIndex
+	if err := s.get("/dapi/v1/premiumIndex", q, 10, &rows); err != nil {
+		return nil, fmt.Errorf("获取币本位标记价格失败: %w", err)
+	}
+	for i := range rows {
+		if rows[i].Symbol == symbol {
+			return &rows[i], nil
+		}
+	}
+	return nil, fmt.Errorf("币安未返回 %s 的标记价格", symbol)
+}
+
+// OpenInterest 获取持仓量：dapi 以张计，按 张数×面值÷标记价格 换算为以币计
+func (s *BinanceCoinMSource) OpenInterest(symbol string) (float64, error) {
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	var result struct {
+		OpenInterest string `json:"openInterest"`
+	}
+	if err := s.get("/dapi/v1/openInterest", q, 1, &result); err != nil {
+		return 0, fmt.Errorf("获取币本位持仓量失败: %w", err)
+	}
+	contracts, err := strconv.ParseFloat(result.OpenInterest, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse openInterest failed: %w", err)
+	}
+	size, err := s.contractSize(symbol)
+	if err != nil {
+		return 0, err
+	}
+	pi, err := s.premiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	mark, _ := strconv.ParseFloat(pi.MarkPrice, 64)
+	if mark <= 0 {
+		return 0, fmt.Errorf("%s 标记价格无效", symbol)
+	}
+	return contracts * size / mark, nil
+}
+
+// FundingRate 获取当前资金费率（交割合约为空，按 0 处理）
+func (s *BinanceCoinMSource) FundingRate(symbol string) (float64, error) {
+	pi, err := s.premiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	if pi.LastFundingRate == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(pi.LastFundingRate, 64)
+}
@@ -0,0 +1,144 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// okxBaseURL OKX 公共接口地址
+const okxBaseURL = "https://www.okx.com"
+
+// okxMaxCandles OKX 单次K线请求上限
+const okxMaxCandles = 300
+
+// okxCodeInstrumentNotFound OKX "Instrument ID does not exist" 错误码
+const okxCodeInstrumentNotFound = "51001"
+
+// OKXSource OKX 永续合约数据源
+type OKXSource struct {
+	baseURL string
+}
+
+// NewOKXSource 创建 OKX 数据源
+func NewOKXSource() *OKXSource {
+	return &OKXSource{baseURL: okxBaseURL}
+}
+
+// Name 数据源名称
+func (s *OKXSource) Name() string { return SourceOKX }
+
+// Normalize BTCUSDT -> BTC-USDT-SWAP（已是原生格式时保持不变）
+func (s *OKXSource) Normalize(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	if strings.HasSuffix(symbol, "-SWAP") {
+		return symbol
+	}
+	base, quote := splitQuote(Normalize(symbol))
+	return fmt.Sprintf("%s-%s-SWAP", base, quote)
+}
+
+// okxBar 币安周期 -> OKX 周期（小时及以上使用大写单位）
+func okxBar(interval string) (string, error) {
+	switch interval {
+	case "1m", "3m", "5m", "15m", "30m":
+		return interval, nil
+	case "1h", "2h", "4h":
+		return strings.ToUpper(interval), nil
+	case "1d":
+		return "1Dutc", nil
+	}
+	return "", fmt.Errorf("OKX 不支持的K线周期: %s", interval)
+}
+
+// get 请求 OKX 公共接口并解析 data 字段
+func (s *OKXSource) get(path string, out interface{}) error {
+	body, err := httpGet(s.baseURL+path, nil, 0)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("解析OKX响应失败: %w", err)
+	}
+	if envelope.Code == okxCodeInstrumentNotFound {
+		return fmt.Errorf("OKX返回错误 (code %s): %s: %w", envelope.Code, envelope.Msg, ErrSymbolNotFound)
+	}
+	if envelope.Code != "0" {
+		return fmt.Errorf("OKX返回错误 (code %s): %s", envelope.Code, envelope.Msg)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// Klines 获取K线（OKX 返回从新到旧，这里反转为从旧到新）
+func (s *OKXSource) Klines(symbol, interval string, limit int) ([]Kline, error) {
+	bar, err := okxBar(interval)
+	if err != nil {
+		return nil, err
+	}
+	step, err := intervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), okxMaxCandles)
+
+	var rows [][]string
+	path := fmt.Sprintf("/api/v5/market/candles?instId=%s&bar=%s&limit=%d", symbol, bar, limit)
+	if err := s.get(path, &rows); err != nil {
+		return nil, err
+	}
+
+	klines := make([]Kline, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		r := rows[i]
+		if len(r) < 8 {
+			continue
+		}
+		openTime, _ := strconv.ParseInt(r[0], 10, 64)
+		k := Kline{OpenTime: openTime, CloseTime: openTime + step.Milliseconds() - 1}
+		k.Open, _ = strconv.ParseFloat(r[1], 64)
+		k.High, _ = strconv.ParseFloat(r[2], 64)
+		k.Low, _ = strconv.ParseFloat(r[3], 64)
+		k.Close, _ = strconv.ParseFloat(r[4], 64)
+		k.Volume, _ = strconv.ParseFloat(r[6], 64) // volCcy：以币计
+		k.QuoteVolume, _ = strconv.ParseFloat(r[7], 64)
+		klines = append(klines, k)
+	}
+	return klines, nil
+}
+
+// OpenInterest 获取持仓量（oiCcy，以币计）
+func (s *OKXSource) OpenInterest(symbol string) (float64, error) {
+	var rows []struct {
+		InstID string `json:"instId"`
+		OI     string `json:"oi"`
+		OICcy  string `json:"oiCcy"`
+	}
+	if err := s.get("/api/v5/public/open-interest?instType=SWAP&instId="+symbol, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("OKX 未返回 %s 的持仓量", symbol)
+	}
+	return strconv.ParseFloat(rows[0].OICcy, 64)
+}
+
+// FundingRate 获取当前资金费率
+func (s *OKXSource) FundingRate(symbol string) (float64, error) {
+	var rows []struct {
+		InstID      string `json:"instId"`
+		FundingRate string `json:"fundingRate"`
+	}
+	if err := s.get("/api/v5/public/funding-rate?instId="+symbol, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("OKX 未返回 %s 的资金费率", symbol)
+	}
+	return strconv.ParseFloat(rows[0].FundingRate, 64)
+}
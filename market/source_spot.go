@@ -0,0 +1,86 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// SourceBinanceSpot 币安现货数据源名称
+const SourceBinanceSpot = "binance_spot"
+
+// spotBaseURL 币安现货公共接口地址
+const spotBaseURL = "https://api.binance.com"
+
+// spotMaxKlines 现货单次K线请求上限
+const spotMaxKlines = 1000
+
+// BinanceSpotSource 币安现货数据源（无持仓量与资金费率）
+type BinanceSpotSource struct {
+	baseURL string
+}
+
+// NewBinanceSpotSource 创建币安现货数据源
+func NewBinanceSpotSource() *BinanceSpotSource {
+	return &BinanceSpotSource{baseURL: spotBaseURL}
+}
+
+// Name 数据源名称
+func (s *BinanceSpotSource) Name() string { return SourceBinanceSpot }
+
+// MarketType 现货
+func (s *BinanceSpotSource) MarketType() MarketType { return MarketSpot }
+
+// Normalize 现货与U本位合约同为 BTCUSDT 格式
+func (s *BinanceSpotSource) Normalize(symbol string) string { return Normalize(symbol) }
+
+// get 请求现货公共接口并解析响应
+func (s *BinanceSpotSource) get(path string, q url.Values, weight int, out interface{}) error {
+	body, err := binanceGet(fmt.Sprintf("%s%s?%s", s.baseURL, path, q.Encode()), weight)
+	if err != nil {
+		return fmt.Errorf("币安现货接口请求失败: %w", err)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// Klines 获取现货K线（格式与合约一致）
+func (s *BinanceSpotSource) Klines(symbol, interval string, limit int) ([]Kline, error) {
+	if limit <= 0 || limit > spotMaxKlines {
+		limit = spotMaxKlines
+	}
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	q.Set("interval", interval)
+	q.Set("limit", strconv.Itoa(limit))
+	var raw []KlineResponse
+	if err := s.get("/api/v3/klines", q, 2, &raw); err != nil {
+		return nil, fmt.Errorf("获取现货K线失败: %w", err)
+	}
+	klines := make([]Kline, 0, len(raw))
+	for _, kr := range raw {
+		k, err := parseKline(kr)
+		if err != nil {
+			continue
+		}
+		klines = append(klines, k)
+	}
+	return klines, nil
+}
+
+// Ticker24h 获取现货24小时行情
+func (s *BinanceSpotSource) Ticker24h(symbol string) (*Ticker24hr, error) {
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	var t Ticker24hr
+	if err := s.get("/api/v3/ticker/24hr", q, 2, &t); err != nil {
+		return nil, fmt.Errorf("获取现货24小时行情失败: %w", err)
+	}
+	return &t, nil
+}
+
+// OpenInterest 现货无持仓量
+func (s *BinanceSpotSource) OpenInterest(string) (float64, error) { return 0, ErrFuturesOnly }
+
+// FundingRate 现货无资金费率
+func (s *BinanceSpotSource) FundingRate(string) (float64, error) { return 0, ErrFuturesOnly }
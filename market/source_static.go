@@ -0,0 +1,120 @@
+package market
+
+import (
+	"fmt"
+	"sync"
+)
+
+// KlineSource K线来源（Source 的子集），Monitor 缓存不足时通过它回补
+type KlineSource interface {
+	Klines(symbol, interval string, limit int) ([]Kline, error)
+}
+
+// StaticSource 内存数据源：返回预先注入的K线、持仓量与资金费率，不访问网络
+// K线按当前时钟（WithClock 注入，未注入时为 SetClock 设置的包级时钟）截取，配合 FakeClock 推进时间即可逐根"回放"合成K线序列
+type StaticSource struct {
+	name    string
+	clock   Clock
+	mu      sync.RWMutex
+	klines  map[string][]Kline // "SYMBOL|interval" -> K线（从旧到新）
+	oi      map[string]float64
+	funding map[string]float64
+}
+
+// NewStaticSource 创建内存数据源（name 为空时使用 "static"）
+func NewStaticSource(name string) *StaticSource {
+	if name == "" {
+		name = "static"
+	}
+	return &StaticSource{
+		name:    name,
+		klines:  make(map[string][]Kline),
+		oi:      make(map[string]float64),
+		funding: make(map[string]float64),
+	}
+}
+
+// WithClock 设置截取K线使用的时钟（nil 表示使用包级时钟）
+func (s *StaticSource) WithClock(c Clock) *StaticSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = c
+	return s
+}
+
+// SetKlines 注入交易对某周期的K线（从旧到新）
+func (s *StaticSource) SetKlines(symbol, interval string, klines []Kline) *StaticSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.klines[Normalize(symbol)+"|"+interval] = append([]Kline(nil), klines...)
+	return s
+}
+
+// SetOpenInterest 设置持仓量
+func (s *StaticSource) SetOpenInterest(symbol string, oi float64) *StaticSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.oi[Normalize(symbol)] = oi
+	return s
+}
+
+// SetFundingRate 设置资金费率
+func (s *StaticSource) SetFundingRate(symbol string, rate float64) *StaticSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.funding[Normalize(symbol)] = rate
+	return s
+}
+
+// Name 数据源名称
+func (s *StaticSource) Name() string { return s.name }
+
+// Normalize 使用 BTCUSDT 格式
+func (s *StaticSource) Normalize(symbol string) string { return Normalize(symbol) }
+
+// Klines 返回开盘时间不晚于当前时钟的最近 limit 根K线
+func (s *StaticSource) Klines(symbol, interval string, limit int) ([]Kline, error) {
+	s.mu.RLock()
+	all, ok := s.klines[Normalize(symbol)+"|"+interval]
+	clk := s.clock
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s 未注入 %s %s K线: %w", s.name, symbol, interval, ErrNoKlines)
+	}
+	now := clockNow()
+	if clk != nil {
+		now = clk.Now()
+	}
+	cutoff := now.UnixMilli()
+	end := len(all)
+	for end > 0 && all[end-1].OpenTime > cutoff {
+		end--
+	}
+	start := 0
+	if limit > 0 && end > limit {
+		start = end - limit
+	}
+	return append([]Kline(nil), all[start:end]...), nil
+}
+
+// OpenInterest 返回注入的持仓量
+func (s *StaticSource) OpenInterest(symbol string) (float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	oi, ok := s.oi[Normalize(symbol)]
+	if !ok {
+		return 0, fmt.Errorf("%s 未注入 %s 持仓量", s.name, symbol)
+	}
+	return oi, nil
+}
+
+// FundingRate 返回注入的资金费率
+func (s *StaticSource) FundingRate(symbol string) (float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rate, ok := s.funding[Normalize(symbol)]
+	if !ok {
+		return 0, fmt.Errorf("%s 未注入 %s 资金费率", s.name, symbol)
+	}
+	return rate, nil
+}
@@ -0,0 +1,158 @@
+package market
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var staticT0 = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// synthKlines 生成 n 根开盘时间截至 last（含）的K线，收盘价从 base 起每根加 1
+func synthKlines(last time.Time, interval time.Duration, n int, base float64) []Kline {
+	klines := make([]Kline, n)
+	start := last.Add(-time.Duration(n-1) * interval)
+	for i := range klines {
+		open := start.Add(time.Duration(i) * interval)
+		price := base + float64(i)
+		klines[i] = Kline{
+			OpenTime:  open.UnixMilli(),
+			Open:      price - 0.5,
+			High:      price + 1,
+			Low:       price - 1,
+			Close:     price,
+			Volume:    100,
+			CloseTime: open.Add(interval).UnixMilli() - 1,
+		}
+	}
+	return klines
+}
+
+// newSynthSource 注入所有周期的K线（最新一根开盘于 staticT0+1h，按时钟截取）
+func newSynthSource(name string) *StaticSource {
+	src := NewStaticSource(name)
+	for interval, n := range map[string]int{"3m": 120, "15m": 100, "1h": 100, "4h": 100, "1d": 100} {
+		d, _ := intervalDuration(interval)
+		last := staticT0.Add(time.Hour).Truncate(d)
+		src.SetKlines("BTCUSDT", interval, synthKlines(last, d, n, 100))
+	}
+	return src.SetOpenInterest("BTCUSDT", 1000).SetFundingRate("BTCUSDT", 0.0001)
+}
+
+func TestStaticSourceKlinesFollowClock(t *testing.T) {
+	clk := NewFakeClock(staticT0)
+	src := NewStaticSource("").WithClock(clk)
+	if src.Name() != "static" {
+		t.Errorf("默认名称 = %q", src.Name())
+	}
+	src.SetKlines("btc", "3m", synthKlines(staticT0.Add(30*time.Minute), 3*time.Minute, 20, 100))
+
+	// 时钟停在第 10 根（下标 9）的开盘时间，之后的K线不可见
+	klines, err := src.Klines("BTCUSDT", "3m", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 10 || klines[9].OpenTime != staticT0.UnixMilli() {
+		t.Fatalf("got %d klines, last open %d", len(klines), klines[len(klines)-1].OpenTime)
+	}
+	if klines, _ = src.Klines("BTCUSDT", "3m", 3); len(klines) != 3 || klines[2].Close != 109 {
+		t.Errorf("limit=3 应返回最近 3 根, got %+v", klines)
+	}
+
+	clk.Advance(6 * time.Minute)
+	if klines, _ = src.Klines("BTCUSDT", "3m", 0); len(klines) != 12 {
+		t.Errorf("时钟前进 2 根后应有 12 根, got %d", len(klines))
+	}
+
+	if _, err := src.Klines("BTCUSDT", "1h", 10); !errors.Is(err, ErrNoKlines) {
+		t.Errorf("未注入的周期应返回 ErrNoKlines, got %v", err)
+	}
+}
+
+func TestStaticSourceUsesPackageClock(t *testing.T) {
+	t.Cleanup(func() { SetClock(nil) })
+	SetClock(NewFakeClock(staticT0.Add(-3 * time.Minute)))
+
+	src := NewStaticSource("pkg-clock").SetKlines("BTCUSDT", "3m", synthKlines(staticT0, 3*time.Minute, 5, 100))
+	if klines, _ := src.Klines("BTCUSDT", "3m", 0); len(klines) != 4 {
+		t.Errorf("未注入时钟时应按包级时钟截取, got %d", len(klines))
+	}
+}
+
+func TestStaticSourceOIAndFunding(t *testing.T) {
+	src := NewStaticSource("oi-funding").SetOpenInterest("eth", 5000).SetFundingRate("ETHUSDT", -0.0002)
+	if oi, err := src.OpenInterest("ETHUSDT"); err != nil || oi != 5000 {
+		t.Errorf("OpenInterest = %v, %v", oi, err)
+	}
+	if rate, err := src.FundingRate("eth"); err != nil || rate != -0.0002 {
+		t.Errorf("FundingRate = %v, %v", rate, err)
+	}
+	if _, err := src.OpenInterest("BTCUSDT"); err == nil {
+		t.Error("未注入持仓量应返回错误")
+	}
+	if _, err := src.FundingRate("BTCUSDT"); err == nil {
+		t.Error("未注入资金费率应返回错误")
+	}
+}
+
+func TestGetWithStaticSource(t *testing.T) {
+	clk := NewFakeClock(staticT0)
+	src := newSynthSource("static-get").WithClock(clk)
+	m := NewMonitor(MonitorConfig{Clock: clk})
+	defer m.Close()
+
+	// 每 5 分钟采样一次持仓量：1000, 1100, 1200, 1300
+	var data *Data
+	for i := 0; i < 4; i++ {
+		src.SetOpenInterest("BTCUSDT", 1000+100*float64(i))
+		var err error
+		if data, err = m.GetWithSource("btc", src, DefaultIndicatorConfig()); err != nil {
+			t.Fatalf("第 %d 次获取失败: %v", i, err)
+		}
+		if i < 3 {
+			clk.Advance(5 * time.Minute)
+		}
+	}
+
+	if !data.Timestamp.Equal(clk.Now()) {
+		t.Errorf("Timestamp = %v, want %v", data.Timestamp, clk.Now())
+	}
+	if data.Source != "static-get" || data.Symbol != "BTCUSDT" {
+		t.Errorf("Source/Symbol = %s/%s", data.Source, data.Symbol)
+	}
+	// 3m K线截至 staticT0+15m（最后 120 根中下标 104 的K线，收盘价 204）
+	if data.CurrentPrice != 204 {
+		t.Errorf("CurrentPrice = %v, want 204", data.CurrentPrice)
+	}
+	if data.FundingRate != 0.0001 {
+		t.Errorf("FundingRate = %v", data.FundingRate)
+	}
+
+	oi := data.OpenInterest
+	if oi == nil {
+		t.Fatal("缺少 OI 数据")
+	}
+	if oi.Latest != 1300 {
+		t.Errorf("Latest = %v", oi.Latest)
+	}
+	if want := []float64{1000, 1100, 1200, 1300}; !slices.Equal(oi.Series5m, want) {
+		t.Errorf("Series5m = %v, want %v", oi.Series5m, want)
+	}
+	if want := []float64{1200, 1300}; !slices.Equal(oi.Series15m, want) {
+		t.Errorf("Series15m = %v, want %v", oi.Series15m, want)
+	}
+	if want := 100.0 / 1200; math.Abs(oi.Change5m-want) > 1e-12 {
+		t.Errorf("Change5m = %v, want %v", oi.Change5m, want)
+	}
+}
+
+func TestGetWithStaticSourceRejectsStaleKlines(t *testing.T) {
+	clk := NewFakeClock(staticT0.Add(24 * time.Hour))
+	m := NewMonitor(MonitorConfig{Clock: clk})
+	defer m.Close()
+	if _, err := m.GetWithSource("BTCUSDT", newSynthSource("static-stale").WithClock(clk), DefaultIndicatorConfig()); !errors.Is(err, ErrStaleData) {
+		t.Errorf("K线过期时应返回 ErrStaleData, got %v", err)
+	}
+}
@@ -1,100 +1,162 @@
 package market
 
-import "time"
+import (
+	"nofx/market/events"
+	"time"
+)
 
 // Data 市场数据结构
 type Data struct {
-	Symbol            string
-	CurrentPrice      float64
-	PriceChange3m     float64 // 新增：最近一个3m与前一个3m的价格变化百分比
-	PriceChange1h     float64 // 1小时价格变化百分比
-	PriceChange4h     float64 // 4小时价格变化百分比
-	PriceChange15m    float64 // 新增：15分钟价格变化百分比
-	PriceChange1d     float64 // 新增：1天价格变化百分比
-	CurrentEMA20      float64
-	CurrentMACD       float64
-	CurrentRSI7       float64
-	OpenInterest      *OIData
-	FundingRate       float64
-	IntradaySeries    *IntradayData   // 3分钟数据
-	Intraday15m       *IntradayData   // 新增：15分钟数据
-	Intraday1h        *IntradayData   // 新增：1小时数据
-	LongerTermContext *LongerTermData // 4小时数据
-	LongerTerm1d      *LongerTermData // 新增：1天数据
+	Symbol            string           `json:"symbol"`
+	Timestamp         time.Time        `json:"timestamp"`        // 数据计算时间
+	Source            string           `json:"source"`           // 行情数据源（binance/okx/bybit）
+	Market            MarketType       `json:"market,omitempty"` // 市场类型（usdm/spot/coinm）
+	CurrentPrice      float64          `json:"current_price"`
+	PriceChange3m     float64          `json:"price_change_3m"`  // 新增：最近一个3m与前一个3m的价格变化百分比
+	PriceChange1h     float64          `json:"price_change_1h"`  // 1小时价格变化百分比
+	PriceChange4h     float64          `json:"price_change_4h"`  // 4小时价格变化百分比
+	PriceChange15m    float64          `json:"price_change_15m"` // 新增：15分钟价格变化百分比
+	PriceChange1d     float64          `json:"price_change_1d"`  // 新增：1天价格变化百分比
+	CurrentEMA20      float64          `json:"current_ema20"`
+	CurrentMACD       float64          `json:"current_macd"`
+	CurrentRSI7       float64          `json:"current_rsi7"`
+	OpenInterest      *OIData          `json:"open_interest,omitempty"`
+	FundingRate       float64          `json:"funding_rate"`
+	Funding           *FundingData     `json:"funding,omitempty"`             // 资金费率详情（历史费率、下次结算时间、年化）
+	Basis             *BasisData       `json:"basis,omitempty"`               // 基差与标记/指数价差及短期趋势（需要 premiumIndex 的标记/指数价格）
+	Depth             *DepthData       `json:"depth,omitempty"`               // 订单簿深度（价差、前N档流动性、失衡比）
+	TakerFlow         *TakerFlow       `json:"taker_flow,omitempty"`          // 主动买卖量（按 MonitorConfig.FlowWindows 统计）
+	Liquidations      *LiquidationData `json:"liquidations,omitempty"`        // 强平统计（按 MonitorConfig.FlowWindows 统计）
+	Sentiment         *SentimentData   `json:"sentiment,omitempty"`           // 多空比情绪（大户持仓多空比、账户多空比）
+	KeyLevels         *KeyLevels       `json:"key_levels,omitempty"`          // 支撑阻力（摆动高低点、日/周枢轴、整数关口）
+	Regime            *RegimeData      `json:"regime,omitempty"`              // 多时间框架市场状态（趋势/震荡/剧烈波动）
+	Volatility        *VolatilityData  `json:"volatility,omitempty"`          // 多时间框架波动率（已实现波动率、ATR百分位、布林/肯特纳挤压）
+	Correlation       *CorrelationData `json:"correlation,omitempty"`         // 相对 BTC/ETH 等基准的相关系数与 beta（按 MonitorConfig.CorrelationWindows 统计）
+	IntradaySeries    *IntradayData    `json:"intraday_series,omitempty"`     // 3分钟数据
+	Intraday15m       *IntradayData    `json:"intraday_15m,omitempty"`        // 新增：15分钟数据
+	Intraday1h        *IntradayData    `json:"intraday_1h,omitempty"`         // 新增：1小时数据
+	LongerTermContext *LongerTermData  `json:"longer_term_context,omitempty"` // 4小时数据
+	LongerTerm1d      *LongerTermData  `json:"longer_term_1d,omitempty"`      // 新增：1天数据
 
 	// Effort vs Result 指标 (价量 + OI 共振效率) 越高代表价格推进效率高
-	EffortResult3m  float64
-	EffortResult15m float64
-	EffortResult1h  float64
+	EffortResult3m  float64 `json:"effort_result_3m"`
+	EffortResult15m float64 `json:"effort_result_15m"`
+	EffortResult1h  float64 `json:"effort_result_1h"`
 	// 解释标签 (高效/低效/背离)，便于直接输出
-	EffortLabel3m  string
-	EffortLabel15m string
-	EffortLabel1h  string
+	EffortLabel3m  string `json:"effort_label_3m"`
+	EffortLabel15m string `json:"effort_label_15m"`
+	EffortLabel1h  string `json:"effort_label_1h"`
+
+	// CurrentProvenance 当前指标（CurrentEMA20/MACD/RSI7，基于3m）的计算来源
+	CurrentProvenance *Provenance `json:"current_provenance,omitempty"`
+
+	// Indicators 本次计算使用的指标配置（CurrentEMA20/MACD/RSI7 的实际周期以此为准）
+	Indicators *IndicatorConfig `json:"indicators,omitempty"`
+
+	// Quality 缺失或过期的数据段（nil 表示各部分均正常）
+	Quality *DataQuality `json:"quality,omitempty"`
+
+	// EventRisk 处于宏观事件/交易所维护风险窗口时非空（未配置事件日历时始终为 nil）
+	EventRisk *events.Risk `json:"event_risk,omitempty"`
 }
 
 // OIData Open Interest数据
 type OIData struct {
-	Latest  float64
-	Average float64
+	Latest  float64 `json:"latest"`
+	Average float64 `json:"average"`
 	// 历史序列（不同周期）
-	Series5m  []float64
-	Series15m []float64
-	Series1h  []float64
-	Series4h  []float64
-	Series1d  []float64
+	Series5m  []float64 `json:"series_5m,omitempty"`
+	Series15m []float64 `json:"series_15m,omitempty"`
+	Series1h  []float64 `json:"series_1h,omitempty"`
+	Series4h  []float64 `json:"series_4h,omitempty"`
+	Series1d  []float64 `json:"series_1d,omitempty"`
 
 	// 变化率（相邻最新两点的百分比变化）
-	Change5m  float64
-	Change15m float64
-	Change1h  float64
-	Change4h  float64
-	Change1d  float64
-
-	// 趋势评分（简单地取各周期变化率的平均，后续可替换为线性回归斜率加权）
-	TrendScore float64
+	Change5m  float64 `json:"change_5m"`
+	Change15m float64 `json:"change_15m"`
+	Change1h  float64 `json:"change_1h"`
+	Change4h  float64 `json:"change_4h"`
+	Change1d  float64 `json:"change_1d"`
+
+	// 趋势评分与计算方法（average/weighted/regression，见 OITrendPolicy）
+	TrendScore  float64 `json:"trend_score"`
+	TrendMethod string  `json:"trend_method"`
 }
 
 // IntradayData 日内数据(3分钟,15,1小时)
 type IntradayData struct {
-	ATR6  float64
-	ATR10 float64
-	ATR12 float64
-	ATR14 float64
+	ATR6  float64 `json:"atr6"`
+	ATR10 float64 `json:"atr10"`
+	ATR12 float64 `json:"atr12"`
+	ATR14 float64 `json:"atr14"`
 
-	MidPrices   []float64
-	EMA20Values []float64
+	MidPrices   []float64 `json:"mid_prices,omitempty"`
+	EMA20Values []float64 `json:"ema20_values,omitempty"`
 
-	MACDValues10208 []float64
-	MACDValues12269 []float64
+	MACDValues10208 []float64 `json:"macd_values_10208,omitempty"`
+	MACDValues12269 []float64 `json:"macd_values_12269,omitempty"`
 
-	RSI7Values  []float64
-	RSI9Values  []float64
-	RSI10Values []float64
-	RSI14Values []float64
+	RSI7Values  []float64 `json:"rsi7_values,omitempty"`
+	RSI9Values  []float64 `json:"rsi9_values,omitempty"`
+	RSI10Values []float64 `json:"rsi10_values,omitempty"`
+	RSI14Values []float64 `json:"rsi14_values,omitempty"`
 
 	// 新增：成交量序列与量能指标
-	VolumeValues     []float64 // 最近10个点的成交量
-	VolumeAverage    float64   // 最近10个点平均成交量
-	VolumeSpikeRatio float64   // 最新成交量 / 之前N(默认为9)个平均成交量
+	VolumeValues     []float64 `json:"volume_values,omitempty"` // 最近10个点的成交量
+	VolumeAverage    float64   `json:"volume_average"`          // 最近10个点平均成交量
+	VolumeSpikeRatio float64   `json:"volume_spike_ratio"`      // 最新成交量 / 之前N(默认为9)个平均成交量
+
+	// 波动带与趋势强度
+	Bollinger Bollinger `json:"bollinger"`  // 布林带(20,2)
+	StochRSIK float64   `json:"stoch_rsik"` // StochRSI(14,14,3,3) %K
+	StochRSID float64   `json:"stoch_rsid"` // StochRSI(14,14,3,3) %D
+	ADX       float64   `json:"adx"`        // 14期ADX
+	PlusDI    float64   `json:"plus_di"`    // 14期+DI
+	MinusDI   float64   `json:"minus_di"`   // 14期-DI
+	VWAP      float64   `json:"vwap"`       // 当日（UTC）成交量加权平均价
+
+	// 按 IndicatorConfig 计算的全部指标（上面的固定字段为默认周期的快捷访问）
+	EMASeries  map[int][]float64    `json:"ema_series,omitempty"`  // 周期 -> EMA序列
+	RSISeries  map[int][]float64    `json:"rsi_series,omitempty"`  // 周期 -> RSI序列
+	MACDSeries map[string][]float64 `json:"macd_series,omitempty"` // "fast,slow,signal" -> MACD(DIF)序列
+	ATR        map[int]float64      `json:"atr,omitempty"`         // 周期 -> ATR
+
+	Provenance *Provenance `json:"provenance,omitempty"` // 本组指标的计算来源
 }
 
 // LongerTermData 长期数据(4小时时间框架1天)
 type LongerTermData struct {
-	EMA20 float64
-	EMA50 float64
+	EMA20 float64 `json:"ema20"`
+	EMA50 float64 `json:"ema50"`
+
+	ATR3  float64 `json:"atr3"`
+	ATR10 float64 `json:"atr10"`
+	ATR12 float64 `json:"atr12"`
+	ATR14 float64 `json:"atr14"`
+
+	CurrentVolume float64 `json:"current_volume"`
+	AverageVolume float64 `json:"average_volume"`
+
+	MACDValues142810 []float64 `json:"macd_values_142810,omitempty"`
+	MACDValues12269  []float64 `json:"macd_values_12269,omitempty"`
+	RSI14Values      []float64 `json:"rsi14_values,omitempty"`
+	RSI21Values      []float64 `json:"rsi21_values,omitempty"`
 
-	ATR3  float64
-	ATR10 float64
-	ATR12 float64
-	ATR14 float64
+	// 波动带与趋势强度
+	Bollinger Bollinger `json:"bollinger"`  // 布林带(20,2)
+	StochRSIK float64   `json:"stoch_rsik"` // StochRSI(14,14,3,3) %K
+	StochRSID float64   `json:"stoch_rsid"` // StochRSI(14,14,3,3) %D
+	ADX       float64   `json:"adx"`        // 14期ADX
+	PlusDI    float64   `json:"plus_di"`    // 14期+DI
+	MinusDI   float64   `json:"minus_di"`   // 14期-DI
 
-	CurrentVolume float64
-	AverageVolume float64
+	// 按 IndicatorConfig 计算的全部指标（上面的固定字段为默认周期的快捷访问）
+	EMA        map[int]float64      `json:"ema,omitempty"`         // 周期 -> EMA
+	RSISeries  map[int][]float64    `json:"rsi_series,omitempty"`  // 周期 -> RSI序列
+	MACDSeries map[string][]float64 `json:"macd_series,omitempty"` // "fast,slow,signal" -> MACD(DIF)序列
+	ATR        map[int]float64      `json:"atr,omitempty"`         // 周期 -> ATR
 
-	MACDValues142810 []float64
-	MACDValues12269  []float64
-	RSI14Values      []float64
-	RSI21Values      []float64
+	Provenance *Provenance `json:"provenance,omitempty"` // 本组指标的计算来源
 }
 
 // Binance API 响应结构
@@ -103,13 +165,14 @@ type ExchangeInfo struct {
 }
 
 type SymbolInfo struct {
-	Symbol            string `json:"symbol"`
-	Status            string `json:"status"`
-	BaseAsset         string `json:"baseAsset"`
-	QuoteAsset        string `json:"quoteAsset"`
-	ContractType      string `json:"contractType"`
-	PricePrecision    int    `json:"pricePrecision"`
-	QuantityPrecision int    `json:"quantityPrecision"`
+	Symbol            string         `json:"symbol"`
+	Status            string         `json:"status"`
+	BaseAsset         string         `json:"baseAsset"`
+	QuoteAsset        string         `json:"quoteAsset"`
+	ContractType      string         `json:"contractType"`
+	PricePrecision    int            `json:"pricePrecision"`
+	QuantityPrecision int            `json:"quantityPrecision"`
+	Filters           []SymbolFilter `json:"filters"`
 }
 
 type Kline struct {
@@ -176,6 +239,7 @@ type Config struct {
 	AlertThresholds AlertThresholds `json:"alert_thresholds"`
 	UpdateInterval  int             `json:"update_interval"` // seconds
 	CleanupConfig   CleanupConfig   `json:"cleanup_config"`
+	DedupWindow     time.Duration   `json:"dedup_window"` // 同一交易对同类警报的去重窗口
 }
 
 type AlertThresholds struct {
@@ -184,6 +248,7 @@ type AlertThresholds struct {
 	VolumeTrend      float64 `json:"volume_trend"`
 	RSIOverbought    float64 `json:"rsi_overbought"`
 	RSIOversold      float64 `json:"rsi_oversold"`
+	OIJump           float64 `json:"oi_jump"` // 15分钟持仓量变化（比例）
 }
 type CleanupConfig struct {
 	InactiveTimeout   time.Duration `json:"inactive_timeout"`    // 不活跃超时时间
@@ -199,6 +264,7 @@ var config = Config{
 		VolumeTrend:      2.0,
 		RSIOverbought:    70,
 		RSIOversold:      30,
+		OIJump:           0.03,
 	},
 	CleanupConfig: CleanupConfig{
 		InactiveTimeout:   30 * time.Minute,
@@ -207,4 +273,5 @@ var config = Config{
 		CheckInterval:     5 * time.Minute,
 	},
 	UpdateInterval: 60, // 1 minute
+	DedupWindow:    15 * time.Minute,
 }
@@ -0,0 +1,197 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 波动率水平（按 1h/4h ATR 百分位划分）
+const (
+	VolatilityLow     = "low"
+	VolatilityNormal  = "normal"
+	VolatilityHigh    = "high"
+	VolatilityExtreme = "extreme"
+)
+
+const (
+	// volatilityReturnWindow 已实现波动率使用的收益率数量
+	volatilityReturnWindow = 30
+	// volatilityATRLookback ATR 百分位的回看K线数
+	volatilityATRLookback = 100
+	// squeezeLookback 统计连续挤压时最多回看的K线数
+	squeezeLookback = 30
+	// keltnerMultiplier 肯特纳通道宽度（ATR 倍数）
+	keltnerMultiplier = 1.5
+)
+
+// TimeframeVolatility 单个时间框架的波动率指标
+type TimeframeVolatility struct {
+	Timeframe       string  `json:"timeframe"`
+	RealizedVol     float64 `json:"realized_vol"`     // 年化已实现波动率（最近 volatilityReturnWindow 个对数收益率），如 0.65 表示 65%
+	ATRPct          float64 `json:"atr_pct"`          // 14期ATR占价格百分比
+	ATRPercentile   float64 `json:"atr_percentile"`   // 当前 ATR/价格 在最近 volatilityATRLookback 期中的百分位（0-1）
+	Squeeze         bool    `json:"squeeze"`          // 布林带(20,2)收缩进肯特纳通道(20,1.5ATR)内
+	SqueezeBars     int     `json:"squeeze_bars"`     // 连续挤压的K线数
+	SqueezeReleased bool    `json:"squeeze_released"` // 上一根处于挤压、当前已释放（常见突破信号）
+}
+
+// VolatilityData 多时间框架波动率
+type VolatilityData struct {
+	Timeframes []TimeframeVolatility `json:"timeframes,omitempty"`
+	Level      string                `json:"level"` // 综合波动率水平（low/normal/high/extreme），用于按波动率缩放仓位
+}
+
+// Get 返回指定时间框架的波动率
+func (v *VolatilityData) Get(timeframe string) (TimeframeVolatility, bool) {
+	if v == nil {
+		return TimeframeVolatility{}, false
+	}
+	for _, tf := range v.Timeframes {
+		if tf.Timeframe == timeframe {
+			return tf, true
+		}
+	}
+	return TimeframeVolatility{}, false
+}
+
+// calculateVolatilityData 计算各时间框架的波动率指标及综合水平
+func calculateVolatilityData(frames map[string][]Kline) *VolatilityData {
+	data := &VolatilityData{}
+	for _, tf := range []string{"3m", "15m", "1h", "4h", "1d"} {
+		if v, ok := calculateTimeframeVolatility(tf, frames[tf]); ok {
+			data.Timeframes = append(data.Timeframes, v)
+		}
+	}
+	if len(data.Timeframes) == 0 {
+		return nil
+	}
+	data.Level = classifyVolatilityLevel(data)
+	return data
+}
+
+// calculateTimeframeVolatility 计算单个时间框架的波动率指标
+func calculateTimeframeVolatility(tf string, klines []Kline) (TimeframeVolatility, bool) {
+	if len(klines) < 21 {
+		return TimeframeVolatility{}, false
+	}
+	v := TimeframeVolatility{
+		Timeframe:     tf,
+		RealizedVol:   realizedVolatility(klines, tf, volatilityReturnWindow),
+		ATRPercentile: atrPercentile(klines, 14, volatilityATRLookback),
+	}
+	if price := klines[len(klines)-1].Close; price > 0 {
+		v.ATRPct = calculateATR(klines, 14) / price * 100
+	}
+
+	start := max(20, len(klines)-squeezeLookback)
+	prevSqueeze := false
+	for i := start; i <= len(klines); i++ {
+		squeeze := inSqueeze(klines[:i])
+		if i == len(klines) {
+			v.Squeeze = squeeze
+			v.SqueezeReleased = prevSqueeze && !squeeze
+		}
+		if squeeze {
+			v.SqueezeBars++
+		} else {
+			v.SqueezeBars = 0
+		}
+		prevSqueeze = squeeze
+	}
+	return v, true
+}
+
+// inSqueeze 布林带(20,2) 是否完全位于肯特纳通道(EMA20 ± 1.5×ATR20) 内
+func inSqueeze(klines []Kline) bool {
+	if len(klines) < 21 {
+		return false
+	}
+	bb := calculateBollinger(klines, 20, 2)
+	mid := calculateEMA(klines, 20)
+	atr := calculateATR(klines, 20)
+	if bb.Middle == 0 || atr == 0 {
+		return false
+	}
+	return bb.Upper < mid+keltnerMultiplier*atr && bb.Lower > mid-keltnerMultiplier*atr
+}
+
+// realizedVolatility 最近 window 个对数收益率的标准差，按周期年化
+func realizedVolatility(klines []Kline, interval string, window int) float64 {
+	var returns []float64
+	for i := 1; i < len(klines); i++ {
+		if klines[i-1].Close > 0 && klines[i].Close > 0 {
+			returns = append(returns, math.Log(klines[i].Close/klines[i-1].Close))
+		}
+	}
+	if len(returns) > window {
+		returns = returns[len(returns)-window:]
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+
+	d, err := intervalDuration(interval)
+	if err != nil || d <= 0 {
+		return std
+	}
+	return std * math.Sqrt(float64(365*24*time.Hour)/float64(d))
+}
+
+// classifyVolatilityLevel 以 1h 与 4h 的 ATR 百分位均值划分波动率水平（缺失时使用全部周期）
+func classifyVolatilityLevel(data *VolatilityData) string {
+	sum, n := 0.0, 0
+	for _, tf := range data.Timeframes {
+		if tf.Timeframe == "1h" || tf.Timeframe == "4h" {
+			sum += tf.ATRPercentile
+			n++
+		}
+	}
+	if n == 0 {
+		for _, tf := range data.Timeframes {
+			sum += tf.ATRPercentile
+			n++
+		}
+	}
+	pct := sum / float64(n)
+	switch {
+	case pct >= 0.95:
+		return VolatilityExtreme
+	case pct >= 0.75:
+		return VolatilityHigh
+	case pct <= 0.25:
+		return VolatilityLow
+	default:
+		return VolatilityNormal
+	}
+}
+
+// writeVolatility 输出波动率
+func writeVolatility(sb *strings.Builder, l reportLocale, v *VolatilityData) {
+	if v == nil {
+		return
+	}
+	parts := make([]string, 0, len(v.Timeframes))
+	for _, tf := range v.Timeframes {
+		item := fmt.Sprintf("%s RV=%.0f%% ATR=%.2f%%(P%.0f)", tf.Timeframe, tf.RealizedVol*100, tf.ATRPct, tf.ATRPercentile*100)
+		switch {
+		case tf.Squeeze:
+			item += l.f("volatility_squeeze", tf.SqueezeBars)
+		case tf.SqueezeReleased:
+			item += l.text("volatility_released")
+		}
+		parts = append(parts, item)
+	}
+	sb.WriteString(l.f("volatility_summary", l.text("volatility:"+v.Level), strings.Join(parts, ", ")))
+}
@@ -27,16 +27,30 @@ const (
 	ProviderSiliconFlow Provider = "siliconflow"
 )
 
+// APIKeyState 密钥候选列表与当前使用的密钥
+type APIKeyState struct {
+	APIKey  string
+	APIKeys []string // 支持多密钥；启动时随机选择一个
+}
+
 // Client AI API配置
 type Client struct {
-	Provider   Provider
-	APIKey     string
-	APIKeys    []string // 支持多密钥；启动时随机选择一个
+	Provider Provider
+	// 指针嵌入：复制 Client（如 WithModel）得到的副本共享同一份密钥状态，余额不足移除的密钥对所有副本生效
+	*APIKeyState
 	BaseURL    string
 	Model      string
 	Timeout    time.Duration
 	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
 	MaxTokens  int  // AI响应的最大token数
+	// ContextWindowTokens 模型上下文窗口（0 表示按模型名自动推断，见 ContextWindow()）
+	ContextWindowTokens int
+	// Mock 模拟服务商（Provider 为 ProviderMock 时使用，见 SetMockProvider）
+	Mock *MockProvider
+	// Usage 用量记录器（nil 时使用全局 DefaultUsageTracker）
+	Usage *UsageTracker
+	// Scheduler 调用排队调度器（nil 时使用全局 DefaultScheduler），按 Tags["trader_id"] 公平出队
+	Scheduler *Scheduler
 	// PersistRemovedKey 当某个密钥被判定余额不足而移除时回调，负责持久化到数据库
 	PersistRemovedKey func(provider Provider, removedKey string, remaining []string) error
 	// 如果后续需要缓存余额，可在这里加一个字段，例如 lastBalance string / lastBalanceAt time.Time
@@ -60,14 +74,22 @@ func New() *Client {
 
 	// 默认配置
 	return &Client{
-		Provider:  ProviderDeepSeek,
-		BaseURL:   "https://api.deepseek.com/v1",
-		Model:     "deepseek-chat",
-		Timeout:   120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
-		MaxTokens: maxTokens,
+		Provider:    ProviderDeepSeek,
+		APIKeyState: &APIKeyState{},
+		BaseURL:     "https://api.deepseek.com/v1",
+		Model:       "deepseek-chat",
+		Timeout:     120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
+		MaxTokens:   maxTokens,
 	}
 }
 
+// WithModel 返回使用指定模型的客户端副本（其余配置与密钥状态与原客户端共享）
+func (client *Client) WithModel(model string) *Client {
+	c := *client
+	c.Model = model
+	return &c
+}
+
 // SetDeepSeekAPIKey 设置DeepSeek API密钥
 // customURL 为空时使用默认URL，customModel 为空时使用默认模型
 func (client *Client) SetDeepSeekAPIKey(apiKey string, customURL string, customModel string) {
@@ -140,15 +162,46 @@ func (client *Client) SetClient(Client Client) {
 
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return client.CallWithOptions(systemPrompt, userPrompt, CallOptions{})
+}
+
+// CallWithOptions 使用 system + user prompt 调用AI API，并指定本次调用的采样参数
+// 推理模型的思考过程默认不包含在返回值中（opts.KeepReasoning 时以 <think> 标签放在回答之前）
+func (client *Client) CallWithOptions(systemPrompt, userPrompt string, opts CallOptions) (string, error) {
+	answer, reasoning, err := client.call(systemPrompt, userPrompt, opts)
+	if opts.KeepReasoning {
+		return withReasoning(answer, reasoning), err
+	}
+	return answer, err
+}
+
+// call 调用AI API，分别返回回答与思考过程
+func (client *Client) call(systemPrompt, userPrompt string, opts CallOptions) (string, string, error) {
+	if client.Provider == ProviderMock {
+		if client.Mock == nil {
+			return "", "", fmt.Errorf("模拟服务商未设置，请先调用 SetMockProvider()")
+		}
+		start := time.Now()
+		content, err := client.Mock.Complete(systemPrompt, userPrompt, opts)
+		client.recordUsage(systemPrompt, userPrompt, content, nil, start, 0, opts, err == nil)
+		answer, reasoning := splitReasoning(content)
+		return answer, reasoning, err
+	}
 	if client.APIKey == "" {
-		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
+		return "", "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
 	}
+	// 全局并发已满时排队，按交易员轮询出队；排队时长不计入调用耗时
+	release, wait := client.scheduler().Acquire(opts.Tags[TagTraderID])
+	defer release()
 	// 按需求：报错后不再重试（行情可能已变化）
-	return client.callOnce(systemPrompt, userPrompt)
+	start := time.Now()
+	answer, reasoning, usage, err := client.callOnce(systemPrompt, userPrompt, opts)
+	client.recordUsage(systemPrompt, userPrompt, reasoning+answer, usage, start, wait, opts, err == nil)
+	return answer, reasoning, err
 }
 
-// callOnce 单次调用AI API（内部使用）
-func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
+// callOnce 单次调用AI API（内部使用），返回回答、思考过程与服务商上报的用量（可能为 nil）
+func (client *Client) callOnce(systemPrompt, userPrompt string, opts CallOptions) (string, string, *apiUsage, error) {
 	// 如果没有激活key，但有候选列表，则随机选择一个
 	if len(client.APIKeys) > 0 { // 每次调用前都随机挑选一个，满足“每次调用随机使用其中一个”
 		client.selectRandomKey()
@@ -173,37 +226,50 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		}
 	}
 
+	// 估算prompt大小，超出上下文窗口时自动裁剪市场数据
+	maxTokens := client.MaxTokens
+	if opts.MaxTokens > 0 {
+		maxTokens = opts.MaxTokens
+	}
+	userPrompt = client.applyTokenBudget(systemPrompt, userPrompt, maxTokens)
+
 	// 构建 messages 数组
-	messages := []map[string]string{}
+	messages := []map[string]interface{}{}
 
 	// 如果有 system prompt，添加 system message
 	if systemPrompt != "" {
-		messages = append(messages, map[string]string{
+		messages = append(messages, map[string]interface{}{
 			"role":    "system",
 			"content": systemPrompt,
 		})
 	}
 
-	// 添加 user message
-	messages = append(messages, map[string]string{
+	// 添加 user message（附加图片时为 content parts）
+	content, err := userContent(userPrompt, opts.Images)
+	if err != nil {
+		return "", "", nil, err
+	}
+	messages = append(messages, map[string]interface{}{
 		"role":    "user",
-		"content": userPrompt,
+		"content": content,
 	})
 
 	// 构建请求体
 	requestBody := map[string]interface{}{
-		"model":       client.Model,
-		"messages":    messages,
-		"temperature": 0.5, // 降低temperature以提高JSON格式稳定性
-		"max_tokens":  client.MaxTokens,
+		"model":    client.Model,
+		"messages": messages,
 	}
+	// 采样参数（temperature 默认 0.5，降低temperature以提高JSON格式稳定性）
+	opts.applyTo(requestBody, client.MaxTokens)
+	// o1/o3 等推理模型使用 max_completion_tokens，且不接受采样参数
+	adaptReasoningParams(client.Model, requestBody)
 
 	// 注意：response_format 参数仅 OpenAI 支持，DeepSeek/Qwen 不支持
 	// 我们通过强化 prompt 和后处理来确保 JSON 格式正确
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
+		return "", "", nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 	if debugHTTPEnabled() {
 		// 尝试美化打印请求体（截断以避免过长日志）
@@ -228,7 +294,7 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return "", "", nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -264,14 +330,14 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 				log.Printf("🧪 [MCP][HINT] 检测到 EOF，可尝试设置 MCP_HTTP2=off 以禁用HTTP/2，或开启 MCP_DEBUG_TRACE=on 查看握手/连接细节")
 			}
 		}
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return "", "", nil, NormalizeTransportError(client.Provider, err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
+		return "", "", nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 	if debugHTTPEnabled() {
 		dur := time.Since(t0)
@@ -287,27 +353,34 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 				log.Printf("🧹 [MCP] 检测到余额不足，已移除当前API Key: %s", maskAPIKey(removed))
 			}
 		}
-		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, bodyStr)
+		providerErr := NormalizeError(client.Provider, resp.StatusCode, body)
+		log.Printf("❌ [MCP] %s 返回错误: status=%d code=%s category=%s | %s", client.Provider, resp.StatusCode, providerErr.Code, providerErr.Category, providerErr.MessageZH)
+		return "", "", nil, providerErr
 	}
 
 	// 解析响应
 	var result struct {
 		Choices []struct {
 			Message struct {
-				Content string `json:"content"`
+				Content          string `json:"content"`
+				ReasoningContent string `json:"reasoning_content"` // deepseek-reasoner 等
+				Reasoning        string `json:"reasoning"`         // 部分 OpenAI 兼容网关
 			} `json:"message"`
 		} `json:"choices"`
+		Usage *apiUsage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("解析响应失败: %w", err)
+		return "", "", nil, fmt.Errorf("解析响应失败: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("API返回空响应")
+		return "", "", nil, fmt.Errorf("API返回空响应")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	msg := result.Choices[0].Message
+	answer, inline := splitReasoning(msg.Content)
+	return answer, joinReasoning(msg.ReasoningContent, msg.Reasoning, inline), result.Usage, nil
 }
 
 // isRetryableError 判断错误是否可重试
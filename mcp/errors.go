@@ -0,0 +1,222 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorCategory 归一化后的错误类别
+type ErrorCategory string
+
+const (
+	ErrCategoryAuth                ErrorCategory = "auth"                 // 密钥无效/无权限
+	ErrCategoryInsufficientBalance ErrorCategory = "insufficient_balance" // 余额不足
+	ErrCategoryRateLimit           ErrorCategory = "rate_limit"           // 触发限流
+	ErrCategoryContextLength       ErrorCategory = "context_length"       // 超出上下文长度
+	ErrCategoryInvalidRequest      ErrorCategory = "invalid_request"      // 请求参数错误
+	ErrCategoryModelNotFound       ErrorCategory = "model_not_found"      // 模型不存在
+	ErrCategoryContentFilter       ErrorCategory = "content_filter"       // 内容安全拦截
+	ErrCategoryServer              ErrorCategory = "server"               // 服务端错误/过载
+	ErrCategoryNetwork             ErrorCategory = "network"              // 网络错误
+	ErrCategoryTimeout             ErrorCategory = "timeout"              // 请求超时
+	ErrCategoryUnknown             ErrorCategory = "unknown"
+)
+
+// categoryMessages 各类别的中英文提示（可直接用于日志与通知）
+var categoryMessages = map[ErrorCategory][2]string{
+	ErrCategoryAuth:                {"Invalid API key or insufficient permission; check the key configured for this model.", "API密钥无效或无权限，请检查该模型配置的密钥"},
+	ErrCategoryInsufficientBalance: {"Account balance is insufficient; top up or switch to another key.", "账户余额不足，请充值或更换密钥"},
+	ErrCategoryRateLimit:           {"Rate limit exceeded; reduce call frequency or retry later.", "请求过于频繁触发限流，请降低调用频率或稍后重试"},
+	ErrCategoryContextLength:       {"Prompt exceeds the model context window; reduce candidate coins or market data.", "提示词超出模型上下文长度，请减少候选币种或市场数据"},
+	ErrCategoryInvalidRequest:      {"Invalid request parameters; check model name and sampling options.", "请求参数错误，请检查模型名称与采样参数"},
+	ErrCategoryModelNotFound:       {"Model does not exist or is not available for this key.", "模型不存在或当前密钥无权使用该模型"},
+	ErrCategoryContentFilter:       {"Request was blocked by the provider content filter.", "请求被服务商内容安全策略拦截"},
+	ErrCategoryServer:              {"Provider server error or overload; retry later.", "服务商服务器错误或过载，请稍后重试"},
+	ErrCategoryNetwork:             {"Network error while contacting the provider; check connectivity or proxy.", "连接服务商时发生网络错误，请检查网络或代理"},
+	ErrCategoryTimeout:             {"Request to the provider timed out.", "请求服务商超时"},
+	ErrCategoryUnknown:             {"Unexpected error returned by the provider.", "服务商返回未知错误"},
+}
+
+// ProviderError 归一化后的AI服务商错误
+type ProviderError struct {
+	Provider   Provider      `json:"provider"`
+	StatusCode int           `json:"status_code"` // HTTP状态码（网络错误时为0）
+	Code       string        `json:"code"`        // 服务商原始错误码
+	Category   ErrorCategory `json:"category"`
+	MessageEN  string        `json:"message_en"`
+	MessageZH  string        `json:"message_zh"`
+	Raw        string        `json:"raw"` // 原始响应体或底层错误文本
+	cause      error
+}
+
+// Error 保留原始响应体，便于按关键字检索日志
+func (e *ProviderError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("发送请求失败: [%s] %s: %s", e.Category, e.MessageZH, e.Raw)
+	}
+	return fmt.Sprintf("API返回错误 (status %d): [%s] %s | %s", e.StatusCode, e.Category, e.MessageZH, e.Raw)
+}
+
+// Unwrap 返回底层错误（网络错误时）
+func (e *ProviderError) Unwrap() error { return e.cause }
+
+// Message 按语言返回提示信息（"en" 返回英文，其余返回中文）
+func (e *ProviderError) Message(lang string) string {
+	if strings.HasPrefix(strings.ToLower(lang), "en") {
+		return e.MessageEN
+	}
+	return e.MessageZH
+}
+
+// Retryable 是否值得稍后重试
+func (e *ProviderError) Retryable() bool {
+	switch e.Category {
+	case ErrCategoryRateLimit, ErrCategoryServer, ErrCategoryNetwork, ErrCategoryTimeout:
+		return true
+	}
+	return false
+}
+
+// AsProviderError 从错误链中提取 ProviderError
+func AsProviderError(err error) (*ProviderError, bool) {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe, true
+	}
+	return nil, false
+}
+
+// providerErrorPayload 兼容各服务商的错误响应格式
+//   - OpenAI / DeepSeek / Qwen兼容模式: {"error":{"message":"...","type":"...","code":"..."}}
+//   - SiliconFlow: {"code":30001,"message":"...","data":null}
+//   - Qwen DashScope 原生: {"code":"InvalidApiKey","message":"...","request_id":"..."}
+type providerErrorPayload struct {
+	Error   json.RawMessage `json:"error"`
+	Code    json.RawMessage `json:"code"`
+	Message string          `json:"message"`
+}
+
+type providerErrorDetail struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// NormalizeError 将服务商的非200响应映射为 ProviderError
+func NormalizeError(provider Provider, statusCode int, body []byte) *ProviderError {
+	code, message, errType := parseProviderError(body)
+	category := classifyProviderError(statusCode, code, errType, message+" "+string(body))
+	msgs := categoryMessages[category]
+	return &ProviderError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Code:       code,
+		Category:   category,
+		MessageEN:  msgs[0],
+		MessageZH:  msgs[1],
+		Raw:        string(body),
+	}
+}
+
+// NormalizeTransportError 将网络层错误映射为 ProviderError
+func NormalizeTransportError(provider Provider, err error) *ProviderError {
+	category := ErrCategoryNetwork
+	var netErr net.Error
+	if (errors.As(err, &netErr) && netErr.Timeout()) || strings.Contains(strings.ToLower(err.Error()), "timeout") {
+		category = ErrCategoryTimeout
+	}
+	msgs := categoryMessages[category]
+	return &ProviderError{
+		Provider:  provider,
+		Category:  category,
+		MessageEN: msgs[0],
+		MessageZH: msgs[1],
+		Raw:       err.Error(),
+		cause:     err,
+	}
+}
+
+// parseProviderError 解析错误码、错误信息、错误类型
+func parseProviderError(body []byte) (code, message, errType string) {
+	var payload providerErrorPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", strings.TrimSpace(string(body)), ""
+	}
+	if len(payload.Error) > 0 {
+		var detail providerErrorDetail
+		if err := json.Unmarshal(payload.Error, &detail); err == nil {
+			return rawCodeString(detail.Code), detail.Message, detail.Type
+		}
+		// 部分服务商 error 字段直接为字符串
+		var s string
+		if err := json.Unmarshal(payload.Error, &s); err == nil {
+			return rawCodeString(payload.Code), s, ""
+		}
+	}
+	return rawCodeString(payload.Code), payload.Message, ""
+}
+
+// rawCodeString 错误码可能为字符串或数字
+func rawCodeString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String()
+	}
+	return string(raw)
+}
+
+// classifyProviderError 根据状态码、错误码与关键字归类
+func classifyProviderError(statusCode int, code, errType, text string) ErrorCategory {
+	lower := strings.ToLower(text + " " + code + " " + errType)
+
+	// 关键字优先：同一状态码在不同服务商含义不同（如余额不足 DeepSeek 为402，SiliconFlow 为403）
+	switch {
+	case isInsufficientBalance(text) || strings.Contains(lower, "insufficient_quota") || strings.Contains(lower, "arrearage"):
+		return ErrCategoryInsufficientBalance
+	case strings.Contains(lower, "context length") || strings.Contains(lower, "context_length") ||
+		strings.Contains(lower, "maximum context") || strings.Contains(lower, "too many tokens") ||
+		strings.Contains(lower, "range of input length"):
+		return ErrCategoryContextLength
+	case strings.Contains(lower, "model_not_found") || strings.Contains(lower, "model not exist") ||
+		strings.Contains(lower, "model does not exist") || strings.Contains(lower, "model not found"):
+		return ErrCategoryModelNotFound
+	case strings.Contains(lower, "data_inspection_failed") || strings.Contains(lower, "content_filter") ||
+		strings.Contains(lower, "inappropriate content"):
+		return ErrCategoryContentFilter
+	case strings.Contains(lower, "invalidapikey") || strings.Contains(lower, "invalid_api_key") ||
+		strings.Contains(lower, "invalid api key") || strings.Contains(lower, "authentication"):
+		return ErrCategoryAuth
+	case strings.Contains(lower, "rate limit") || strings.Contains(lower, "rate_limit") ||
+		strings.Contains(lower, "throttling") || strings.Contains(lower, "too many requests"):
+		return ErrCategoryRateLimit
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrCategoryAuth
+	case statusCode == http.StatusPaymentRequired:
+		return ErrCategoryInsufficientBalance
+	case statusCode == http.StatusTooManyRequests:
+		return ErrCategoryRateLimit
+	case statusCode == http.StatusNotFound:
+		return ErrCategoryModelNotFound
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return ErrCategoryTimeout
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity:
+		return ErrCategoryInvalidRequest
+	case statusCode >= 500:
+		return ErrCategoryServer
+	}
+	return ErrCategoryUnknown
+}
@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// 多模态输入：为 user 消息附加图片（如 market.RenderChartPNG 生成的K线图），
+// 按 OpenAI 兼容格式以 content parts 发送（text + image_url），需要模型支持图像输入。
+
+// Image 附加到 user 消息的图片，URL 与 Data 二选一
+type Image struct {
+	URL      string // 图片地址（http(s) 或 data: URL）
+	Data     []byte // 图片内容，以 base64 data URL 发送
+	MIMEType string // Data 的类型，默认 image/png
+	Detail   string // 识别精度 low/high/auto（为空时不下发）
+}
+
+// ImageURL 以地址引用图片
+func ImageURL(url string) Image {
+	return Image{URL: url}
+}
+
+// ImageData 以 base64 内嵌图片（mimeType 为空时按 PNG 处理）
+func ImageData(data []byte, mimeType string) Image {
+	return Image{Data: data, MIMEType: mimeType}
+}
+
+// CallWithImages 调用AI API，在 user 消息中附加图片
+func (client *Client) CallWithImages(systemPrompt, userPrompt string, images []Image, opts CallOptions) (string, error) {
+	opts.Images = append(append([]Image(nil), opts.Images...), images...)
+	return client.CallWithOptions(systemPrompt, userPrompt, opts)
+}
+
+// url 返回下发给服务商的图片地址
+func (img Image) url() (string, error) {
+	if img.URL != "" {
+		return img.URL, nil
+	}
+	if len(img.Data) == 0 {
+		return "", fmt.Errorf("图片缺少 URL 或内容")
+	}
+	mimeType := img.MIMEType
+	if mimeType == "" {
+		mimeType = "image/png"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(img.Data), nil
+}
+
+// userContent 构建 user 消息内容：无图片时为纯文本，有图片时为 text + image_url 的 content parts
+func userContent(text string, images []Image) (interface{}, error) {
+	if len(images) == 0 {
+		return text, nil
+	}
+	parts := []map[string]interface{}{{"type": "text", "text": text}}
+	for i, img := range images {
+		url, err := img.url()
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 张图片无效: %w", i+1, err)
+		}
+		imageURL := map[string]interface{}{"url": url}
+		if img.Detail != "" {
+			imageURL["detail"] = img.Detail
+		}
+		parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": imageURL})
+	}
+	return parts, nil
+}
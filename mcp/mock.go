@@ -0,0 +1,160 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ProviderMock 本地模拟服务商：不访问网络，返回预设/脚本化的响应，用于端到端测试
+const ProviderMock Provider = "mock"
+
+// MockResponse 一条预设响应
+// Match 非空时表示关键字规则：system 或 user prompt 包含 Match 即命中；Error 非空时返回错误
+type MockResponse struct {
+	Match    string `json:"match,omitempty"`
+	Response string `json:"response"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MockCall 记录一次调用的输入，便于断言
+type MockCall struct {
+	SystemPrompt string
+	UserPrompt   string
+	Options      CallOptions
+}
+
+// mockFile 响应脚本文件格式
+//
+//	{
+//	  "default": "...",                          // 无其他命中时返回
+//	  "script":  [{"response": "..."}, ...],     // 按顺序依次返回（每条仅使用一次）
+//	  "rules":   [{"match": "BTCUSDT", "response": "..."}]
+//	}
+type mockFile struct {
+	Default string         `json:"default"`
+	Script  []MockResponse `json:"script"`
+	Rules   []MockResponse `json:"rules"`
+}
+
+// MockProvider 返回预设或脚本化响应的模拟服务商
+// 响应优先级：脚本队列 > 关键字规则 > 默认响应
+type MockProvider struct {
+	mu       sync.Mutex
+	script   []MockResponse
+	rules    []MockResponse
+	fallback *MockResponse
+	calls    []MockCall
+}
+
+// NewMockProvider 创建空的模拟服务商
+func NewMockProvider() *MockProvider {
+	return &MockProvider{}
+}
+
+// Enqueue 追加一条按顺序返回的响应
+func (m *MockProvider) Enqueue(response string) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script = append(m.script, MockResponse{Response: response})
+	return m
+}
+
+// EnqueueError 追加一条按顺序返回的错误
+func (m *MockProvider) EnqueueError(err error) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script = append(m.script, MockResponse{Error: err.Error()})
+	return m
+}
+
+// Register 注册关键字规则：prompt 包含 match 时返回 response
+func (m *MockProvider) Register(match, response string) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = append(m.rules, MockResponse{Match: match, Response: response})
+	return m
+}
+
+// SetDefault 设置兜底响应
+func (m *MockProvider) SetDefault(response string) *MockProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallback = &MockResponse{Response: response}
+	return m
+}
+
+// LoadFile 从文件加载响应：.json 按脚本格式解析，其余文件内容整体作为默认响应
+func (m *MockProvider) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取模拟响应文件失败: %w", err)
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		m.SetDefault(string(data))
+		return nil
+	}
+	var f mockFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("解析模拟响应文件失败: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script = append(m.script, f.Script...)
+	m.rules = append(m.rules, f.Rules...)
+	if f.Default != "" {
+		m.fallback = &MockResponse{Response: f.Default}
+	}
+	return nil
+}
+
+// Calls 返回已记录的调用
+func (m *MockProvider) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockCall(nil), m.calls...)
+}
+
+// Complete 记录调用并返回匹配的响应
+func (m *MockProvider) Complete(systemPrompt, userPrompt string, opts CallOptions) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, MockCall{SystemPrompt: systemPrompt, UserPrompt: userPrompt, Options: opts})
+
+	var resp *MockResponse
+	if len(m.script) > 0 {
+		resp = &m.script[0]
+		m.script = m.script[1:]
+	} else {
+		for i := range m.rules {
+			if strings.Contains(systemPrompt, m.rules[i].Match) || strings.Contains(userPrompt, m.rules[i].Match) {
+				resp = &m.rules[i]
+				break
+			}
+		}
+	}
+	if resp == nil {
+		resp = m.fallback
+	}
+	if resp == nil {
+		return "", errors.New("模拟服务商没有可用的响应（请先 Enqueue/Register/SetDefault 或加载响应文件）")
+	}
+	if resp.Error != "" {
+		return "", errors.New(resp.Error)
+	}
+	return resp.Response, nil
+}
+
+// SetMockProvider 将客户端切换为模拟服务商（不再访问网络）
+func (client *Client) SetMockProvider(mock *MockProvider) {
+	client.Provider = ProviderMock
+	client.Model = string(ProviderMock)
+	client.BaseURL = ""
+	client.Mock = mock
+	log.Printf("🧪 [MCP] 使用模拟服务商（不访问网络）")
+}
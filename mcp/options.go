@@ -0,0 +1,65 @@
+package mcp
+
+// 默认采样参数（与历史行为保持一致）
+const defaultTemperature = 0.5
+
+// CallOptions 单次调用的采样参数
+// 指针字段为 nil 时表示不下发该参数（由服务端使用默认值），Temperature 为 nil 时回落到 0.5
+type CallOptions struct {
+	Temperature      *float64 // 采样温度
+	TopP             *float64 // 核采样概率
+	Stop             []string // 停止序列
+	FrequencyPenalty *float64 // 频率惩罚
+	Seed             *int64   // 随机种子（部分服务商支持，用于结果复现）
+	MaxTokens        int      // >0 时覆盖 Client.MaxTokens
+	// Tags 成本归属标签（如 trader_id/symbol/cycle_id），随用量记录保存，不下发给服务商
+	Tags map[string]string
+	// Images 附加到 user 消息的图片（需要模型支持图像输入，见 CallWithImages）
+	Images []Image
+	// KeepReasoning 为 true 时 CallWithOptions 返回的文本保留推理模型的思考过程（<think> 标签包裹），默认去掉
+	KeepReasoning bool
+}
+
+// DecisionCallOptions 交易决策调用的推荐参数：较低温度以提高JSON格式稳定性
+func DecisionCallOptions() CallOptions {
+	return CallOptions{Temperature: Float64(defaultTemperature)}
+}
+
+// AnalysisCallOptions 复盘/分析类调用的推荐参数：适当提高温度以获得更丰富的表述
+func AnalysisCallOptions() CallOptions {
+	return CallOptions{Temperature: Float64(0.7), TopP: Float64(0.9)}
+}
+
+// Float64 返回 float64 指针，便于构造 CallOptions
+func Float64(v float64) *float64 { return &v }
+
+// Int64 返回 int64 指针，便于构造 CallOptions
+func Int64(v int64) *int64 { return &v }
+
+// applyTo 将采样参数写入请求体
+func (o CallOptions) applyTo(requestBody map[string]interface{}, defaultMaxTokens int) {
+	temperature := defaultTemperature
+	if o.Temperature != nil {
+		temperature = *o.Temperature
+	}
+	requestBody["temperature"] = temperature
+
+	maxTokens := defaultMaxTokens
+	if o.MaxTokens > 0 {
+		maxTokens = o.MaxTokens
+	}
+	requestBody["max_tokens"] = maxTokens
+
+	if o.TopP != nil {
+		requestBody["top_p"] = *o.TopP
+	}
+	if len(o.Stop) > 0 {
+		requestBody["stop"] = o.Stop
+	}
+	if o.FrequencyPenalty != nil {
+		requestBody["frequency_penalty"] = *o.FrequencyPenalty
+	}
+	if o.Seed != nil {
+		requestBody["seed"] = *o.Seed
+	}
+}
@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 推理模型支持：
+//   - deepseek-reasoner 等在 message.reasoning_content（部分网关为 message.reasoning）中单独返回思考过程；
+//   - QwQ、R1 蒸馏模型等把思考过程放在正文的 <think>...</think> 中；
+//   - OpenAI o1/o3/o4/gpt-5 系列要求 max_completion_tokens 代替 max_tokens，且不接受 temperature/top_p 等采样参数。
+// 默认从返回文本中去掉思考过程（避免干扰 JSON 提取），CallWithReasoning 单独返回。
+
+// reThinkBlock 匹配正文中的 <think>...</think>（包括只有闭合标签、开头标签被服务端吞掉的情况）
+var reThinkBlock = regexp.MustCompile(`(?s)^\s*(?:<think>)?(.*?)</think>`)
+
+// maxCompletionTokensModels 需要 max_completion_tokens、且只支持默认采样参数的模型（按模型名前缀匹配）
+var maxCompletionTokensModels = []string{"o1", "o3", "o4", "gpt-5"}
+
+// reasoningUnsupportedParams 上述模型会拒绝的采样参数
+var reasoningUnsupportedParams = []string{"temperature", "top_p", "frequency_penalty"}
+
+// CallWithReasoning 调用AI并分别返回可见回答与思考过程（模型未返回思考过程时 reasoning 为空）
+func (client *Client) CallWithReasoning(systemPrompt, userPrompt string, opts CallOptions) (answer, reasoning string, err error) {
+	return client.call(systemPrompt, userPrompt, opts)
+}
+
+// splitReasoning 从正文中拆出 <think> 思考过程，返回去掉思考过程后的回答
+func splitReasoning(content string) (answer, reasoning string) {
+	m := reThinkBlock.FindStringSubmatchIndex(content)
+	if m == nil {
+		return content, ""
+	}
+	return strings.TrimSpace(content[m[1]:]), strings.TrimSpace(content[m[2]:m[3]])
+}
+
+// joinReasoning 合并服务商单独返回的思考过程与正文中的思考过程
+func joinReasoning(parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
+
+// withReasoning 把思考过程以 <think> 标签放回回答之前（CallOptions.KeepReasoning 时使用）
+func withReasoning(answer, reasoning string) string {
+	if reasoning == "" {
+		return answer
+	}
+	return "<think>\n" + reasoning + "\n</think>\n\n" + answer
+}
+
+// usesMaxCompletionTokens 模型是否要求 max_completion_tokens
+func usesMaxCompletionTokens(model string) bool {
+	model = strings.ToLower(model)
+	for _, prefix := range maxCompletionTokensModels {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// adaptReasoningParams 按模型调整请求参数：max_tokens 改名为 max_completion_tokens 并去掉不支持的采样参数
+func adaptReasoningParams(model string, requestBody map[string]interface{}) {
+	if !usesMaxCompletionTokens(model) {
+		return
+	}
+	if v, ok := requestBody["max_tokens"]; ok {
+		requestBody["max_completion_tokens"] = v
+		delete(requestBody, "max_tokens")
+	}
+	for _, key := range reasoningUnsupportedParams {
+		delete(requestBody, key)
+	}
+}
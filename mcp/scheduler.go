@@ -0,0 +1,173 @@
+package mcp
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// AI 调用排队：多个交易员同时发起调用时，限制全局并发数，并按交易员轮询出队，
+// 避免某个调用频繁的交易员占满并发、其他交易员长时间等待，或瞬时并发触发服务商限流。
+// 同一交易员的调用按到达顺序执行；不同交易员之间每次各出一个（round robin）。
+
+// queueWaitLogThreshold 排队超过该时长时打印日志
+const queueWaitLogThreshold = 5 * time.Second
+
+// Scheduler 全局并发上限 + 按交易员公平出队的调度器（并发安全）
+type Scheduler struct {
+	mu      sync.Mutex
+	limit   int                            // 最大并发调用数（<=0 表示不限制）
+	running int                            // 正在执行的调用数
+	queues  map[string][]chan struct{}     // 各交易员的等待队列（按到达顺序）
+	order   []string                       // 有等待调用的交易员，按轮询顺序
+	waits   map[string]SchedulerWaitTotals // 各交易员的排队统计（键为空表示未标注交易员）
+}
+
+// SchedulerWaitTotals 排队耗时统计
+type SchedulerWaitTotals struct {
+	Calls     int           `json:"calls"`      // 经过调度器的调用数
+	Queued    int           `json:"queued"`     // 其中需要排队的调用数
+	TotalWait time.Duration `json:"total_wait"` // 累计排队时长
+	MaxWait   time.Duration `json:"max_wait"`   // 最长排队时长
+}
+
+// SchedulerStats 调度器当前状态
+type SchedulerStats struct {
+	Limit    int                            `json:"limit"`
+	Running  int                            `json:"running"`
+	Waiting  map[string]int                 `json:"waiting"` // 各交易员当前排队中的调用数
+	ByTrader map[string]SchedulerWaitTotals `json:"by_trader"`
+	Total    SchedulerWaitTotals            `json:"total"`
+}
+
+// NewScheduler 创建调度器，limit<=0 表示不限制并发（仍统计排队时长，恒为 0）
+func NewScheduler(limit int) *Scheduler {
+	return &Scheduler{
+		limit:  limit,
+		queues: make(map[string][]chan struct{}),
+		waits:  make(map[string]SchedulerWaitTotals),
+	}
+}
+
+// defaultScheduler 所有未单独指定调度器的客户端共享（默认不限制并发）
+var defaultScheduler = NewScheduler(0)
+
+// DefaultScheduler 返回全局调度器
+func DefaultScheduler() *Scheduler {
+	return defaultScheduler
+}
+
+// SetLimit 修改最大并发数（提高上限时立即放行排队中的调用）
+func (s *Scheduler) SetLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+	s.dispatch()
+}
+
+// Acquire 为 trader 申请一个调用名额，返回释放函数与排队时长；释放函数必须调用且只生效一次
+func (s *Scheduler) Acquire(trader string) (release func(), wait time.Duration) {
+	start := time.Now()
+	s.mu.Lock()
+	if s.free() && len(s.order) == 0 {
+		s.running++
+		s.recordWait(trader, 0)
+		s.mu.Unlock()
+		return s.releaseFunc(), 0
+	}
+	ready := make(chan struct{})
+	if len(s.queues[trader]) == 0 {
+		s.order = append(s.order, trader)
+	}
+	s.queues[trader] = append(s.queues[trader], ready)
+	s.mu.Unlock()
+
+	<-ready
+	wait = time.Since(start)
+	s.mu.Lock()
+	s.recordWait(trader, wait)
+	s.mu.Unlock()
+	if wait >= queueWaitLogThreshold {
+		log.Printf("⏳ [MCP] 交易员 %s 的AI调用排队 %v", trader, wait.Round(time.Millisecond))
+	}
+	return s.releaseFunc(), wait
+}
+
+// Stats 返回当前状态与排队统计的副本
+func (s *Scheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := SchedulerStats{
+		Limit:    s.limit,
+		Running:  s.running,
+		Waiting:  make(map[string]int, len(s.queues)),
+		ByTrader: make(map[string]SchedulerWaitTotals, len(s.waits)),
+	}
+	for trader, q := range s.queues {
+		out.Waiting[trader] = len(q)
+	}
+	for trader, w := range s.waits {
+		out.ByTrader[trader] = w
+		out.Total.Calls += w.Calls
+		out.Total.Queued += w.Queued
+		out.Total.TotalWait += w.TotalWait
+		out.Total.MaxWait = max(out.Total.MaxWait, w.MaxWait)
+	}
+	return out
+}
+
+// free 是否还有空闲名额（调用方持有锁）
+func (s *Scheduler) free() bool {
+	return s.limit <= 0 || s.running < s.limit
+}
+
+// dispatch 按轮询顺序放行排队的调用，直到名额用完（调用方持有锁）
+func (s *Scheduler) dispatch() {
+	for s.free() && len(s.order) > 0 {
+		trader := s.order[0]
+		s.order = s.order[1:]
+		q := s.queues[trader]
+		ready := q[0]
+		if len(q) > 1 {
+			s.queues[trader] = q[1:]
+			s.order = append(s.order, trader) // 还有等待的调用，排到队尾
+		} else {
+			delete(s.queues, trader)
+		}
+		s.running++
+		close(ready)
+	}
+}
+
+// releaseFunc 返回只生效一次的释放函数
+func (s *Scheduler) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.running--
+			s.dispatch()
+		})
+	}
+}
+
+// recordWait 累加排队统计（调用方持有锁）
+func (s *Scheduler) recordWait(trader string, wait time.Duration) {
+	w := s.waits[trader]
+	w.Calls++
+	if wait > 0 {
+		w.Queued++
+	}
+	w.TotalWait += wait
+	w.MaxWait = max(w.MaxWait, wait)
+	s.waits[trader] = w
+}
+
+// scheduler 返回客户端使用的调度器（未设置时使用全局调度器）
+func (client *Client) scheduler() *Scheduler {
+	if client.Scheduler != nil {
+		return client.Scheduler
+	}
+	return defaultScheduler
+}
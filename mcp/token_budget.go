@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 默认上下文窗口（未知模型时使用，偏保守）
+const defaultContextWindow = 32768
+
+// promptSafetyMargin 估算误差与消息封装开销的预留token
+const promptSafetyMargin = 512
+
+// modelContextWindows 常见模型的上下文窗口（按模型名前缀匹配，越具体的前缀越靠前）
+var modelContextWindows = []struct {
+	prefix string
+	window int
+}{
+	{"deepseek-reasoner", 65536},
+	{"deepseek", 65536},
+	{"qwen3-max", 262144},
+	{"qwen-long", 1000000},
+	{"qwen", 131072},
+	{"gpt-4o", 128000},
+	{"gpt-4.1", 1000000},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"moonshot", 131072},
+	{"kimi", 131072},
+	{"glm", 131072},
+}
+
+// EstimateTokens 估算文本的token数量
+// 采用启发式规则：CJK字符约1个token/字，其余字符约4字节/token，结果向上取整
+func EstimateTokens(s string) int {
+	cjk := 0
+	other := 0
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+			continue
+		}
+		other += len(string(r))
+	}
+	return cjk + (other+3)/4
+}
+
+// ContextWindow 返回当前模型的上下文窗口大小
+// 优先级：Client.ContextWindowTokens > 环境变量 AI_CONTEXT_WINDOW > 模型名匹配 > 默认值
+func (client *Client) ContextWindow() int {
+	if client.ContextWindowTokens > 0 {
+		return client.ContextWindowTokens
+	}
+	if env := os.Getenv("AI_CONTEXT_WINDOW"); env != "" {
+		if parsed, err := strconv.Atoi(env); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	model := strings.ToLower(client.Model)
+	for _, item := range modelContextWindows {
+		if strings.HasPrefix(model, item.prefix) {
+			return item.window
+		}
+	}
+	return defaultContextWindow
+}
+
+// PromptBudget 计算 user prompt 可用的token预算（扣除 system prompt、输出预留与安全余量；maxTokens<=0 时使用 Client.MaxTokens）
+func (client *Client) PromptBudget(systemPrompt string, maxTokens int) int {
+	if maxTokens <= 0 {
+		maxTokens = client.MaxTokens
+	}
+	return client.ContextWindow() - maxTokens - promptSafetyMargin - EstimateTokens(systemPrompt)
+}
+
+// truncateMiddle 按预算截断中间部分（以字符近似token）
+func truncateMiddle(s string, budget int) string {
+	runes := []rune(s)
+	// 保守地按 1 token/字符 计算
+	if budget <= 0 || len(runes) <= budget {
+		return s
+	}
+	headLen := budget * 2 / 3
+	tailLen := budget - headLen
+	return string(runes[:headLen]) + "\n…(内容过长，已截断)…\n" + string(runes[len(runes)-tailLen:])
+}
+
+// applyTokenBudget 兜底检查 user prompt，仍超出上下文窗口时截断中间部分，避免被服务端拒绝
+// （按内容结构的裁剪由调用方完成，如决策 prompt 先裁剪候选币种，见 decision 包）
+func (client *Client) applyTokenBudget(systemPrompt, userPrompt string, maxTokens int) string {
+	budget := client.PromptBudget(systemPrompt, maxTokens)
+	before := EstimateTokens(userPrompt)
+	if budget <= 0 || before <= budget {
+		return userPrompt
+	}
+	fitted := truncateMiddle(userPrompt, budget)
+	log.Printf("✂️  [MCP] Prompt 超出上下文预算 (估算 %d > 预算 %d, 窗口 %d)，已截断至约 %d tokens",
+		before, budget, client.ContextWindow(), EstimateTokens(fitted))
+	return fitted
+}
@@ -0,0 +1,305 @@
+package mcp
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 常用的成本归属标签键
+const (
+	TagTraderID   = "trader_id"
+	TagSymbol     = "symbol"
+	TagCycleID    = "cycle_id"
+	TagExperiment = "experiment" // A/B 实验分组（交易员ID:实验名/组名）
+)
+
+// recordOnlyTags 每次调用取值都不同的高基数标签：只随调用记录保存（可用 Records 按值过滤），不进入 ByTag 汇总，
+// 避免汇总随运行时间无限增长
+var recordOnlyTags = map[string]bool{TagCycleID: true}
+
+// maxUsageRecords 内存中保留的调用记录上限（超出后丢弃最旧的记录，汇总统计不受影响）
+const maxUsageRecords = 5000
+
+// UsageRecord 单次AI调用的用量记录
+type UsageRecord struct {
+	Time             time.Time         `json:"time"`
+	Provider         Provider          `json:"provider"`
+	Model            string            `json:"model"`
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	TotalTokens      int               `json:"total_tokens"`
+	Estimated        bool              `json:"estimated"` // 服务商未返回 usage 时按字符数估算
+	Duration         time.Duration     `json:"duration"`
+	QueueWait        time.Duration     `json:"queue_wait"` // 调用前在调度器中的排队时长
+	Success          bool              `json:"success"`
+	CostUSD          float64           `json:"cost_usd,omitempty"` // 按 SetModelPricing 配置的单价估算，未配置单价时为 0
+	Tags             map[string]string `json:"tags,omitempty"`
+}
+
+// UsageTotals 一组调用的累计用量
+type UsageTotals struct {
+	Calls            int     `json:"calls"`
+	Errors           int     `json:"errors"`
+	PromptTokens     float64 `json:"prompt_tokens"`
+	CompletionTokens float64 `json:"completion_tokens"`
+	TotalTokens      float64 `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	QueueWaitSeconds float64 `json:"queue_wait_seconds"` // 累计排队时长
+}
+
+// ModelPricing 模型单价（美元 / 百万 token）
+type ModelPricing struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+var (
+	pricingMu    sync.RWMutex
+	modelPricing = make(map[string]ModelPricing)
+)
+
+// SetModelPricing 设置模型单价（按模型名匹配），之后记录的调用按此估算费用
+func SetModelPricing(model string, p ModelPricing) {
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	modelPricing[model] = p
+}
+
+// estimateCost 按模型单价估算一次调用的费用
+func estimateCost(r UsageRecord) float64 {
+	return EstimateCost(r.Model, r.PromptTokens, r.CompletionTokens)
+}
+
+// EstimateCost 按 SetModelPricing 配置的单价估算费用（美元，未配置单价时为 0）
+func EstimateCost(model string, promptTokens, completionTokens int) float64 {
+	pricingMu.RLock()
+	p, ok := modelPricing[model]
+	pricingMu.RUnlock()
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*p.InputPerMillion + float64(completionTokens)*p.OutputPerMillion) / 1e6
+}
+
+// UsageStats 用量汇总：总计 + 按标签键/值拆分（如 ByTag["trader_id"]["trader_a"]；cycle_id 等高基数标签不汇总）
+// 标签值含逗号（如 symbol="BTCUSDT,ETHUSDT"）时，该次调用的用量在各值之间平均分摊
+type UsageStats struct {
+	Total   UsageTotals                       `json:"total"`
+	ByModel map[string]UsageTotals            `json:"by_model"`
+	ByTag   map[string]map[string]UsageTotals `json:"by_tag"`
+}
+
+// UsageTracker 记录并汇总AI调用用量（并发安全）
+type UsageTracker struct {
+	mu      sync.Mutex
+	records []UsageRecord
+	stats   UsageStats
+}
+
+// NewUsageTracker 创建用量记录器
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{
+		stats: UsageStats{
+			ByModel: make(map[string]UsageTotals),
+			ByTag:   make(map[string]map[string]UsageTotals),
+		},
+	}
+}
+
+// defaultUsageTracker 所有未单独指定记录器的客户端共享，便于跨交易员汇总
+var defaultUsageTracker = NewUsageTracker()
+
+// DefaultUsageTracker 返回全局用量记录器
+func DefaultUsageTracker() *UsageTracker {
+	return defaultUsageTracker
+}
+
+// Record 追加一条用量记录并更新汇总
+func (t *UsageTracker) Record(r UsageRecord) {
+	if r.CostUSD == 0 {
+		r.CostUSD = estimateCost(r)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.records = append(t.records, r)
+	if len(t.records) > maxUsageRecords {
+		t.records = append([]UsageRecord(nil), t.records[len(t.records)-maxUsageRecords:]...)
+	}
+
+	t.stats.Total = t.stats.Total.add(r, 1)
+	t.stats.ByModel[r.Model] = t.stats.ByModel[r.Model].add(r, 1)
+	for key, value := range r.Tags {
+		values := splitTagValue(value)
+		if len(values) == 0 || recordOnlyTags[key] {
+			continue
+		}
+		share := 1 / float64(len(values))
+		byValue := t.stats.ByTag[key]
+		if byValue == nil {
+			byValue = make(map[string]UsageTotals)
+			t.stats.ByTag[key] = byValue
+		}
+		for _, v := range values {
+			byValue[v] = byValue[v].add(r, share)
+		}
+	}
+}
+
+// Records 返回满足所有给定标签条件的记录副本（filter 为空时返回全部）
+func (t *UsageTracker) Records(filter map[string]string) []UsageRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]UsageRecord, 0, len(t.records))
+	for _, r := range t.records {
+		if matchTags(r.Tags, filter) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// Stats 返回汇总统计的副本
+func (t *UsageTracker) Stats() UsageStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := UsageStats{
+		Total:   t.stats.Total,
+		ByModel: make(map[string]UsageTotals, len(t.stats.ByModel)),
+		ByTag:   make(map[string]map[string]UsageTotals, len(t.stats.ByTag)),
+	}
+	for k, v := range t.stats.ByModel {
+		out.ByModel[k] = v
+	}
+	for key, byValue := range t.stats.ByTag {
+		m := make(map[string]UsageTotals, len(byValue))
+		for v, totals := range byValue {
+			m[v] = totals
+		}
+		out.ByTag[key] = m
+	}
+	return out
+}
+
+// StatsByTag 返回指定标签键下各值的汇总，按总token数从高到低排序的值列表一并返回
+func (t *UsageTracker) StatsByTag(key string) (map[string]UsageTotals, []string) {
+	byValue := t.Stats().ByTag[key]
+	values := make([]string, 0, len(byValue))
+	for v := range byValue {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return byValue[values[i]].TotalTokens > byValue[values[j]].TotalTokens
+	})
+	return byValue, values
+}
+
+// Reset 清空记录与汇总
+func (t *UsageTracker) Reset() {
+	fresh := NewUsageTracker()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = nil
+	t.stats = fresh.stats
+}
+
+// add 按分摊比例累加一条记录
+func (u UsageTotals) add(r UsageRecord, share float64) UsageTotals {
+	u.Calls++
+	if !r.Success {
+		u.Errors++
+	}
+	u.PromptTokens += float64(r.PromptTokens) * share
+	u.CompletionTokens += float64(r.CompletionTokens) * share
+	u.TotalTokens += float64(r.TotalTokens) * share
+	u.CostUSD += r.CostUSD * share
+	u.QueueWaitSeconds += r.QueueWait.Seconds() * share
+	return u
+}
+
+// splitTagValue 拆分逗号分隔的多值标签
+func splitTagValue(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// matchTags 判断记录标签是否满足过滤条件（多值标签包含该值即视为命中）
+func matchTags(tags, filter map[string]string) bool {
+	for key, want := range filter {
+		found := false
+		for _, v := range splitTagValue(tags[key]) {
+			if v == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// WithTags 返回附加了成本归属标签的副本（与已有标签合并，后者覆盖前者）
+func (o CallOptions) WithTags(tags map[string]string) CallOptions {
+	merged := make(map[string]string, len(o.Tags)+len(tags))
+	for k, v := range o.Tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	o.Tags = merged
+	return o
+}
+
+// usageTracker 返回客户端使用的记录器（未设置时使用全局记录器）
+func (client *Client) usageTracker() *UsageTracker {
+	if client.Usage != nil {
+		return client.Usage
+	}
+	return defaultUsageTracker
+}
+
+// recordUsage 记录一次调用（usage 为 nil 时按字符数估算token）
+func (client *Client) recordUsage(systemPrompt, userPrompt, content string, usage *apiUsage, start time.Time, queueWait time.Duration, opts CallOptions, success bool) {
+	r := UsageRecord{
+		Time:      start,
+		Provider:  client.Provider,
+		Model:     client.Model,
+		Duration:  time.Since(start),
+		QueueWait: queueWait,
+		Success:   success,
+	}
+	if len(opts.Tags) > 0 {
+		r.Tags = make(map[string]string, len(opts.Tags))
+		for k, v := range opts.Tags {
+			r.Tags[k] = v
+		}
+	}
+	if usage != nil && usage.TotalTokens > 0 {
+		r.PromptTokens = usage.PromptTokens
+		r.CompletionTokens = usage.CompletionTokens
+		r.TotalTokens = usage.TotalTokens
+	} else {
+		r.PromptTokens = EstimateTokens(systemPrompt) + EstimateTokens(userPrompt)
+		r.CompletionTokens = EstimateTokens(content)
+		r.TotalTokens = r.PromptTokens + r.CompletionTokens
+		r.Estimated = true
+	}
+	client.usageTracker().Record(r)
+}
+
+// apiUsage OpenAI 兼容响应中的 usage 字段
+type apiUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
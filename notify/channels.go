@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpClient 各渠道共用（超时由调用方的 context 控制）
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// discordMaxContent Discord 单条消息的最大长度
+const discordMaxContent = 2000
+
+// Telegram 通过 Bot API 发送到指定会话
+type Telegram struct {
+	token  string
+	chatID int64
+	apiURL string // 默认 https://api.telegram.org
+}
+
+// NewTelegram 创建 Telegram 渠道
+func NewTelegram(botToken string, chatID int64) (*Telegram, error) {
+	if strings.TrimSpace(botToken) == "" || chatID == 0 {
+		return nil, fmt.Errorf("telegram 需要 bot_token 与 chat_id")
+	}
+	return &Telegram{token: botToken, chatID: chatID, apiURL: "https://api.telegram.org"}, nil
+}
+
+func (t *Telegram) Name() string { return ChannelTelegram }
+
+// Send 实现 Channel（纯文本，避免 Markdown 转义问题）
+func (t *Telegram) Send(ctx context.Context, e Event) error {
+	body, _ := json.Marshal(map[string]any{
+		"chat_id":                  t.chatID,
+		"text":                     e.Text(),
+		"disable_web_page_preview": true,
+	})
+	return postJSON(ctx, t.apiURL+"/bot"+t.token+"/sendMessage", body)
+}
+
+// Discord 通过频道 webhook 发送
+type Discord struct {
+	url string
+}
+
+// NewDiscord 创建 Discord 渠道
+func NewDiscord(webhookURL string) (*Discord, error) {
+	if err := checkURL(webhookURL); err != nil {
+		return nil, err
+	}
+	return &Discord{url: webhookURL}, nil
+}
+
+func (d *Discord) Name() string { return ChannelDiscord }
+
+// Send 实现 Channel
+func (d *Discord) Send(ctx context.Context, e Event) error {
+	content := e.Text()
+	if r := []rune(content); len(r) > discordMaxContent {
+		content = string(r[:discordMaxContent-1]) + "…"
+	}
+	body, _ := json.Marshal(map[string]string{"content": content})
+	return postJSON(ctx, d.url, body)
+}
+
+// Webhook 以 JSON 形式 POST 完整的 Event
+type Webhook struct {
+	url string
+}
+
+// NewWebhook 创建通用 webhook 渠道
+func NewWebhook(webhookURL string) (*Webhook, error) {
+	if err := checkURL(webhookURL); err != nil {
+		return nil, err
+	}
+	return &Webhook{url: webhookURL}, nil
+}
+
+func (w *Webhook) Name() string { return ChannelWebhook }
+
+// Send 实现 Channel
+func (w *Webhook) Send(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, w.url, body)
+}
+
+// checkURL 校验 webhook 地址
+func checkURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook_url 需要 http(s) 地址: %q", s)
+	}
+	return nil
+}
+
+// postJSON 发送 JSON 请求，非 2xx 视为失败
+func postJSON(ctx context.Context, target string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// 错误信息中可能包含 bot token，只保留主机名
+		if u, perr := url.Parse(target); perr == nil {
+			return fmt.Errorf("请求 %s 失败: %w", u.Host, ctxErr(ctx, err))
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// ctxErr 超时/取消时返回 context 错误（不含 URL）
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err
+	}
+	return err
+}
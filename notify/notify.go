@@ -0,0 +1,292 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件通知：把开平仓、对账不一致、AI 密钥因余额不足被移除、行情警报等关键事件推送到
+// Telegram / Discord / 通用 webhook。发送是异步的（缓冲满时丢弃），不阻塞交易流程。
+// 未配置任何渠道时 Send 为空操作，调用方无需判断。
+
+// 事件类型
+const (
+	EventPositionOpened    = "position_opened"
+	EventPositionClosed    = "position_closed"
+	EventReconcileMismatch = "reconcile_mismatch"
+	EventAIKeyRemoved      = "ai_key_removed"
+	EventMarketAlert       = "market_alert"
+	EventTradingPaused     = "trading_paused"
+	EventTradingResumed    = "trading_resumed"
+)
+
+// 渠道类型
+const (
+	ChannelTelegram = "telegram"
+	ChannelDiscord  = "discord"
+	ChannelWebhook  = "webhook"
+)
+
+const (
+	queueSize     = 100
+	retryCount    = 3
+	retryInterval = 3 * time.Second
+	sendTimeout   = 10 * time.Second
+)
+
+// Event 一条通知
+type Event struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	TraderID string            `json:"trader_id,omitempty"`
+	Symbol   string            `json:"symbol,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"` // 附加字段（按键名排序展示）
+	Time     time.Time         `json:"time"`
+}
+
+// Text 渲染为纯文本（Telegram/Discord 使用）
+func (e Event) Text() string {
+	var b strings.Builder
+	if e.Title != "" {
+		b.WriteString(e.Title)
+		b.WriteString("\n")
+	}
+	if e.Message != "" {
+		b.WriteString(e.Message)
+		b.WriteString("\n")
+	}
+	if e.TraderID != "" {
+		fmt.Fprintf(&b, "trader: %s\n", e.TraderID)
+	}
+	if e.Symbol != "" {
+		fmt.Fprintf(&b, "symbol: %s\n", e.Symbol)
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", k, e.Fields[k])
+	}
+	b.WriteString(e.Time.Format("2006-01-02 15:04:05"))
+	return b.String()
+}
+
+// Channel 通知渠道
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, e Event) error
+}
+
+// ChannelConfig 单个渠道配置
+type ChannelConfig struct {
+	Type       string   `json:"type"`                  // telegram | discord | webhook
+	BotToken   string   `json:"bot_token,omitempty"`   // telegram
+	ChatID     int64    `json:"chat_id,omitempty"`     // telegram
+	WebhookURL string   `json:"webhook_url,omitempty"` // discord / webhook
+	Events     []string `json:"events,omitempty"`      // 只推送这些事件类型（为空表示全部）
+}
+
+// Config 通知配置（config.json 的 notify 字段）
+type Config struct {
+	Channels []ChannelConfig `json:"channels"`
+}
+
+// route 渠道及其事件过滤
+type route struct {
+	channel Channel
+	events  map[string]bool // nil 表示全部
+}
+
+func (r route) accepts(eventType string) bool {
+	return r.events == nil || r.events[eventType]
+}
+
+// Notifier 异步分发通知到各渠道
+type Notifier struct {
+	routes []route
+	queue  chan Event
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex // 保护 closed，避免向已关闭的队列发送
+	closed bool
+}
+
+// New 按配置创建通知器并启动发送协程（无渠道时返回 nil, nil）
+func New(cfg Config) (*Notifier, error) {
+	var routes []route
+	for i, cc := range cfg.Channels {
+		ch, err := newChannel(cc)
+		if err != nil {
+			return nil, fmt.Errorf("通知渠道 #%d (%s) 配置无效: %w", i+1, cc.Type, err)
+		}
+		r := route{channel: ch}
+		if len(cc.Events) > 0 {
+			r.events = make(map[string]bool, len(cc.Events))
+			for _, ev := range cc.Events {
+				r.events[ev] = true
+			}
+		}
+		routes = append(routes, r)
+	}
+	if len(routes) == 0 {
+		return nil, nil
+	}
+	return newNotifier(routes), nil
+}
+
+// NewWithChannels 使用已构造的渠道创建通知器（所有事件都推送）
+func NewWithChannels(channels ...Channel) *Notifier {
+	routes := make([]route, 0, len(channels))
+	for _, ch := range channels {
+		routes = append(routes, route{channel: ch})
+	}
+	return newNotifier(routes)
+}
+
+func newNotifier(routes []route) *Notifier {
+	n := &Notifier{routes: routes, queue: make(chan Event, queueSize)}
+	n.wg.Add(1)
+	go n.run()
+	return n
+}
+
+// newChannel 按类型创建渠道
+func newChannel(cc ChannelConfig) (Channel, error) {
+	switch strings.ToLower(cc.Type) {
+	case ChannelTelegram:
+		return NewTelegram(cc.BotToken, cc.ChatID)
+	case ChannelDiscord:
+		return NewDiscord(cc.WebhookURL)
+	case ChannelWebhook:
+		return NewWebhook(cc.WebhookURL)
+	default:
+		return nil, fmt.Errorf("未知渠道类型 %q（可选 telegram|discord|webhook）", cc.Type)
+	}
+}
+
+// Send 投递一条通知（非阻塞，缓冲满时丢弃）
+func (n *Notifier) Send(e Event) {
+	if n == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.queue <- e:
+	default:
+		log.Printf("⚠️ [通知] 发送队列已满，丢弃 %s: %s", e.Type, e.Title)
+	}
+}
+
+// Close 发送完队列中的通知后停止
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.queue)
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+	for e := range n.queue {
+		for _, r := range n.routes {
+			if r.accepts(e.Type) {
+				sendWithRetry(r.channel, e)
+			}
+		}
+	}
+}
+
+// sendWithRetry 发送到单个渠道，失败时重试
+func sendWithRetry(ch Channel, e Event) {
+	var err error
+	for i := 0; i < retryCount; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = ch.Send(ctx, e)
+		cancel()
+		if err == nil {
+			return
+		}
+		if i < retryCount-1 {
+			time.Sleep(retryInterval)
+		}
+	}
+	log.Printf("⚠️ [通知] %s 发送失败（已重试%d次）: %v", ch.Name(), retryCount, err)
+}
+
+// defaultNotifier 进程内共享的通知器（Init 之前为 nil，Send 为空操作）
+var (
+	defaultMu       sync.RWMutex
+	defaultNotifier *Notifier
+)
+
+// Init 按配置初始化全局通知器（替换并关闭之前的通知器）
+func Init(cfg Config) error {
+	n, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	SetDefault(n)
+	return nil
+}
+
+// SetDefault 替换全局通知器（nil 表示关闭通知）
+func SetDefault(n *Notifier) {
+	defaultMu.Lock()
+	old := defaultNotifier
+	defaultNotifier = n
+	defaultMu.Unlock()
+	if old != nil && old != n {
+		old.Close()
+	}
+}
+
+// Enabled 是否配置了接收该事件类型的渠道
+func Enabled(eventType string) bool {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	if defaultNotifier == nil {
+		return false
+	}
+	for _, r := range defaultNotifier.routes {
+		if r.accepts(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send 通过全局通知器发送
+func Send(e Event) {
+	defaultMu.RLock()
+	n := defaultNotifier
+	defaultMu.RUnlock()
+	n.Send(e)
+}
+
+// Close 关闭全局通知器（发送完剩余通知）
+func Close() {
+	SetDefault(nil)
+}
@@ -0,0 +1,148 @@
+package performance
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 信心度校准：比较 AI 开仓时给出的信心度（0-100）与实际胜率，判断信心度是否有参考价值。
+// Brier 分数 = 平均 (信心度/100 - 是否盈利)²，越低越好；作为参照，始终报告实际总胜率时的分数一并给出，
+// 信心度没有区分能力时两者接近。
+
+// calibrationBucketWidth 信心度分桶宽度（百分点）
+const calibrationBucketWidth = 10
+
+// calibrationMinTrades 有信心度的已平仓笔数少于该值时不生成提示
+const calibrationMinTrades = 10
+
+// calibrationMinBucketTrades 提示中只列出笔数不少于该值的分桶
+const calibrationMinBucketTrades = 3
+
+// calibrationGapThreshold 平均信心度与实际胜率相差超过该百分点时视为过度/不足自信
+const calibrationGapThreshold = 10
+
+// CalibrationBucket 一个信心度区间的统计
+type CalibrationBucket struct {
+	Low           int     `json:"low"`  // 区间下限（含）
+	High          int     `json:"high"` // 区间上限（含）
+	Trades        int     `json:"trades"`
+	Wins          int     `json:"wins"`
+	AvgConfidence float64 `json:"avg_confidence"` // 百分比
+	WinRate       float64 `json:"win_rate"`       // 百分比
+	Gap           float64 `json:"gap"`            // 平均信心度 - 实际胜率（正数为过度自信）
+}
+
+// Calibration 信心度校准报告（只统计记录了信心度的结果）
+type Calibration struct {
+	Trades        int                 `json:"trades"`
+	Brier         float64             `json:"brier"`
+	BaseRateBrier float64             `json:"base_rate_brier"` // 始终报告实际总胜率时的 Brier 分数
+	AvgConfidence float64             `json:"avg_confidence"`  // 百分比
+	WinRate       float64             `json:"win_rate"`        // 百分比
+	Buckets       []CalibrationBucket `json:"buckets"`         // 按信心度从低到高，只含有结果的区间
+}
+
+// Calibrate 统计最近 window 笔有信心度的结果（window<=0 表示全部）
+func Calibrate(outcomes []Outcome, window int) Calibration {
+	var rated []Outcome
+	for _, o := range outcomes {
+		if o.Confidence > 0 {
+			rated = append(rated, o)
+		}
+	}
+	if window > 0 && len(rated) > window {
+		rated = rated[len(rated)-window:]
+	}
+	c := Calibration{Buckets: []CalibrationBucket{}}
+	if len(rated) == 0 {
+		return c
+	}
+
+	buckets := make(map[int]*CalibrationBucket)
+	var confSum, brierSum float64
+	wins := 0
+	for _, o := range rated {
+		p := math.Min(o.Confidence, 100) / 100
+		y := 0.0
+		if o.Win() {
+			y = 1
+			wins++
+		}
+		confSum += p
+		brierSum += (p - y) * (p - y)
+
+		low := min(int(p*100)/calibrationBucketWidth*calibrationBucketWidth, 100-calibrationBucketWidth)
+		b := buckets[low]
+		if b == nil {
+			b = &CalibrationBucket{Low: low, High: low + calibrationBucketWidth - 1}
+			if b.High == 99 {
+				b.High = 100
+			}
+			buckets[low] = b
+		}
+		b.Trades++
+		b.AvgConfidence += p * 100
+		if y == 1 {
+			b.Wins++
+		}
+	}
+
+	n := float64(len(rated))
+	base := float64(wins) / n
+	c.Trades = len(rated)
+	c.Brier = brierSum / n
+	c.BaseRateBrier = base * (1 - base)
+	c.AvgConfidence = confSum / n * 100
+	c.WinRate = base * 100
+	for low := 0; low < 100; low += calibrationBucketWidth {
+		b := buckets[low]
+		if b == nil {
+			continue
+		}
+		b.AvgConfidence /= float64(b.Trades)
+		b.WinRate = float64(b.Wins) / float64(b.Trades) * 100
+		b.Gap = b.AvgConfidence - b.WinRate
+		c.Buckets = append(c.Buckets, *b)
+	}
+	return c
+}
+
+// Text 格式化为提示词中的信心度校准提示（样本不足时为空）
+func (c Calibration) Text() string {
+	if c.Trades < calibrationMinTrades {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "## 信心度校准（最近%d笔有信心度的交易）\n", c.Trades)
+	fmt.Fprintf(&b, "平均信心度%.0f%% 实际胜率%.0f%% | Brier分数%.3f（越低越好；始终按实际胜率报告为%.3f）\n",
+		c.AvgConfidence, c.WinRate, c.Brier, c.BaseRateBrier)
+	for _, bk := range c.Buckets {
+		if bk.Trades < calibrationMinBucketTrades {
+			continue
+		}
+		fmt.Fprintf(&b, "- 信心度%d-%d: %d笔 平均%.0f%% 实际胜率%.0f%%%s\n",
+			bk.Low, bk.High, bk.Trades, bk.AvgConfidence, bk.WinRate, gapText(bk.Gap))
+	}
+	gap := c.AvgConfidence - c.WinRate
+	switch {
+	case gap > calibrationGapThreshold:
+		b.WriteString("提示: 信心度整体偏高，请按实际胜率下调\n")
+	case gap < -calibrationGapThreshold:
+		b.WriteString("提示: 信心度整体偏低，可适当上调\n")
+	case c.Brier >= c.BaseRateBrier:
+		b.WriteString("提示: 信心度目前没有区分盈亏的能力，请只在信号明确时给出高信心度\n")
+	}
+	return b.String()
+}
+
+// gapText 区间的偏差描述
+func gapText(gap float64) string {
+	switch {
+	case gap > calibrationGapThreshold:
+		return fmt.Sprintf(" → 高估%.0f个百分点", gap)
+	case gap < -calibrationGapThreshold:
+		return fmt.Sprintf(" → 低估%.0f个百分点", -gap)
+	}
+	return ""
+}
@@ -0,0 +1,340 @@
+package performance
+
+import (
+	"fmt"
+	"log"
+	"nofx/decisions"
+	"nofx/logger"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 决策结果跟踪：把每次开仓决策与其最终平仓关联起来，计算实际结果，并生成近期表现摘要供后续 AI 提示词使用。
+//
+// 决策日志中的平仓动作（close_*、auto_close_*、partial_close）直接关联；止损/止盈等由交易所侧触发、日志中
+// 没有平仓动作的持仓，通过对账数据库（log_reconcile 的 orders/trades）中开仓之后成交的平仓单补齐。
+// 有对账数据时盈亏与手续费取交易所成交记录，否则按日志价格估算。
+
+// 结果来源
+const (
+	SourceExchange = "exchange" // 对账数据库中的成交记录
+	SourceLog      = "log"      // 决策日志中的价格估算
+)
+
+// closeQtyTolerance 平仓数量达到开仓数量的该比例即视为已全部平仓（避免精度误差）
+const closeQtyTolerance = 0.99
+
+// Outcome 一次开仓决策的最终结果
+type Outcome struct {
+	Symbol        string        `json:"symbol"`
+	Side          string        `json:"side"` // long/short
+	OpenTime      time.Time     `json:"open_time"`
+	CloseTime     time.Time     `json:"close_time"`
+	Holding       time.Duration `json:"holding"`
+	OpenOrderIDs  []int64       `json:"open_order_ids"`
+	CloseOrderIDs []int64       `json:"close_order_ids"`
+	EntryPrice    float64       `json:"entry_price"`
+	ExitPrice     float64       `json:"exit_price"`
+	Quantity      float64       `json:"quantity"`
+	Leverage      int           `json:"leverage"`
+	Confidence    float64       `json:"confidence,omitempty"` // 开仓时 AI 给出的信心度 0-100（0 表示未记录）
+	Experiment    string        `json:"experiment,omitempty"` // 开仓周期参与的 A/B 实验
+	Arm           string        `json:"arm,omitempty"`        // 开仓周期所在的实验组
+	RealizedPnL   float64       `json:"realized_pnl"`
+	Fees          float64       `json:"fees"`
+	NetPnL        float64       `json:"net_pnl"`    // 已实现盈亏 - 手续费
+	ReturnPct     float64       `json:"return_pct"` // 净盈亏占开仓名义价值的百分比
+	CloseReason   string        `json:"close_reason"`
+	Source        string        `json:"source"`
+}
+
+// Win 是否盈利
+func (o Outcome) Win() bool {
+	return o.NetPnL > 0
+}
+
+// position 尚未平仓的开仓
+type position struct {
+	o           Outcome
+	closedQty   float64
+	exitValue   float64 // 已平仓部分的 数量 × 价格
+	closeReason string
+}
+
+// Tracker 单个交易员的决策结果跟踪（store 为 nil 时只使用决策日志）
+type Tracker struct {
+	traderID string
+	store    *Store
+}
+
+// NewTracker 创建跟踪器
+func NewTracker(traderID string, store *Store) *Tracker {
+	return &Tracker{traderID: traderID, store: store}
+}
+
+// Outcomes 关联决策记录（按时间正序）中的开仓与平仓，返回已平仓的结果（按平仓时间正序）
+func (t *Tracker) Outcomes(records []*logger.DecisionRecord) []Outcome {
+	var outcomes []Outcome
+	open := make(map[string]*position) // symbol_side
+	finish := func(key string, closeTime time.Time) {
+		p := open[key]
+		delete(open, key)
+		outcomes = append(outcomes, t.settle(p, closeTime))
+	}
+	// resolveByExchange 日志中没有平仓动作时，用对账数据库查找 until 之前的交易所侧平仓
+	resolveByExchange := func(key string, until time.Time) bool {
+		p := open[key]
+		if t.store == nil {
+			return false
+		}
+		fills, err := t.store.closingFills(t.traderID, p.o.Symbol, p.o.Side, p.o.OpenTime, until)
+		if err != nil {
+			log.Printf("⚠ [performance] 查询 %s %s 平仓单失败: %v", p.o.Symbol, p.o.Side, err)
+			return false
+		}
+		var qty, value float64
+		var ids []int64
+		var last time.Time
+		for _, f := range fills {
+			if slices.Contains(p.o.CloseOrderIDs, f.orderID) {
+				continue // 日志中已记录的部分平仓
+			}
+			qty += f.qty
+			value += f.qty * f.price
+			ids = append(ids, f.orderID)
+			last = f.time
+		}
+		if len(ids) == 0 || qty < (p.o.Quantity-p.closedQty)*closeQtyTolerance {
+			return false
+		}
+		p.o.CloseOrderIDs = append(p.o.CloseOrderIDs, ids...)
+		p.closedQty += qty
+		p.exitValue += value
+		p.closeReason = "exchange"
+		finish(key, last)
+		return true
+	}
+
+	for _, rec := range records {
+		var plans []decisions.Item // 旧记录的动作中没有信心度时从 DecisionJSON 补齐（按需解析一次）
+		plansParsed := false
+		confidenceOf := func(act logger.DecisionAction) float64 {
+			if act.Confidence > 0 {
+				return float64(act.Confidence)
+			}
+			if !plansParsed {
+				plans, _ = rec.Plans()
+				plansParsed = true
+			}
+			for _, it := range plans {
+				if it.Symbol == act.Symbol && it.Action == act.Action {
+					return it.Confidence
+				}
+			}
+			return 0
+		}
+		for _, act := range rec.Decisions {
+			if !act.Success {
+				continue
+			}
+			ts := act.Timestamp
+			if ts.IsZero() {
+				ts = rec.Timestamp
+			}
+			switch act.Action {
+			case "open_long", "open_short":
+				side := strings.TrimPrefix(act.Action, "open_")
+				key := act.Symbol + "_" + side
+				if p := open[key]; p != nil && !resolveByExchange(key, ts) {
+					// 仍持有时视为加仓：按数量加权入场价
+					total := p.o.Quantity + act.Quantity
+					if total > 0 {
+						p.o.EntryPrice = (p.o.EntryPrice*p.o.Quantity + act.Price*act.Quantity) / total
+					}
+					p.o.Quantity = total
+					p.o.OpenOrderIDs = appendID(p.o.OpenOrderIDs, act.OrderID)
+					continue
+				}
+				open[key] = &position{o: Outcome{
+					Symbol:       act.Symbol,
+					Side:         side,
+					OpenTime:     ts,
+					OpenOrderIDs: appendID(nil, act.OrderID),
+					EntryPrice:   act.Price,
+					Quantity:     act.Quantity,
+					Leverage:     act.Leverage,
+					Confidence:   confidenceOf(act),
+					Experiment:   rec.Experiment,
+					Arm:          rec.ExperimentArm,
+				}}
+			case "close_long", "close_short", "auto_close_long", "auto_close_short":
+				side := act.Action[strings.LastIndex(act.Action, "_")+1:]
+				key := act.Symbol + "_" + side
+				p := open[key]
+				if p == nil {
+					continue
+				}
+				p.o.CloseOrderIDs = appendID(p.o.CloseOrderIDs, act.OrderID)
+				p.exitValue += (p.o.Quantity - p.closedQty) * act.Price
+				p.closedQty = p.o.Quantity
+				p.closeReason = act.Action
+				finish(key, ts)
+			case "partial_close":
+				key := act.Symbol + "_long"
+				if open[key] == nil {
+					key = act.Symbol + "_short"
+				}
+				p := open[key]
+				if p == nil {
+					continue
+				}
+				p.o.CloseOrderIDs = appendID(p.o.CloseOrderIDs, act.OrderID)
+				p.closedQty += act.Quantity
+				p.exitValue += act.Quantity * act.Price
+				p.closeReason = act.Action
+				if p.closedQty >= p.o.Quantity*closeQtyTolerance {
+					finish(key, ts)
+				}
+			}
+		}
+	}
+
+	// 仍未平仓的按对账数据库查找交易所侧平仓（查不到视为仍持有）
+	keys := make([]string, 0, len(open))
+	for k := range open {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	now := time.Now()
+	for _, k := range keys {
+		resolveByExchange(k, now)
+	}
+
+	sort.SliceStable(outcomes, func(i, j int) bool { return outcomes[i].CloseTime.Before(outcomes[j].CloseTime) })
+	return outcomes
+}
+
+// settle 计算已平仓结果：优先使用对账数据库的成交盈亏与手续费
+func (t *Tracker) settle(p *position, closeTime time.Time) Outcome {
+	o := p.o
+	o.CloseTime = closeTime
+	o.Holding = closeTime.Sub(o.OpenTime)
+	o.CloseReason = p.closeReason
+	if p.closedQty > 0 {
+		o.ExitPrice = p.exitValue / p.closedQty
+	}
+	o.Source = SourceLog
+	dir := 1.0
+	if o.Side == "short" {
+		dir = -1
+	}
+	o.RealizedPnL = (o.ExitPrice - o.EntryPrice) * o.Quantity * dir
+
+	if t.store != nil && len(o.CloseOrderIDs) > 0 {
+		if s, err := t.store.orderFills(t.traderID, o.Symbol, o.CloseOrderIDs); err != nil {
+			log.Printf("⚠ [performance] 查询 %s 平仓成交失败: %v", o.Symbol, err)
+		} else if s.qty > 0 {
+			o.Source = SourceExchange
+			o.RealizedPnL = s.realizedPnL
+			o.ExitPrice = s.value / s.qty
+			o.Fees = s.commission
+			if open, err := t.store.orderFills(t.traderID, o.Symbol, o.OpenOrderIDs); err == nil && open.qty > 0 {
+				o.Fees += open.commission
+				o.EntryPrice = open.value / open.qty
+			}
+		}
+	}
+	o.NetPnL = o.RealizedPnL - o.Fees
+	if notional := o.EntryPrice * o.Quantity; notional > 0 {
+		o.ReturnPct = o.NetPnL / notional * 100
+	}
+	return o
+}
+
+func appendID(ids []int64, id int64) []int64 {
+	if id == 0 {
+		return ids
+	}
+	return append(ids, id)
+}
+
+// Summary 近期表现摘要
+type Summary struct {
+	Trades       int           `json:"trades"`
+	Wins         int           `json:"wins"`
+	Losses       int           `json:"losses"`
+	WinRate      float64       `json:"win_rate"` // 百分比
+	NetPnL       float64       `json:"net_pnl"`
+	AvgWin       float64       `json:"avg_win"`
+	AvgLoss      float64       `json:"avg_loss"`      // 负数
+	ProfitFactor float64       `json:"profit_factor"` // 总盈利 / 总亏损（没有亏损时为 0）
+	AvgHolding   time.Duration `json:"avg_holding"`
+	Recent       []Outcome     `json:"recent"` // 最近的结果（从新到旧）
+}
+
+// Summarize 汇总最近 window 笔结果（window<=0 表示全部），摘要中列出最近 recent 笔
+func Summarize(outcomes []Outcome, window, recent int) Summary {
+	if window > 0 && len(outcomes) > window {
+		outcomes = outcomes[len(outcomes)-window:]
+	}
+	var s Summary
+	var grossWin, grossLoss float64
+	var holding time.Duration
+	for _, o := range outcomes {
+		s.Trades++
+		s.NetPnL += o.NetPnL
+		holding += o.Holding
+		if o.Win() {
+			s.Wins++
+			grossWin += o.NetPnL
+		} else {
+			s.Losses++
+			grossLoss -= o.NetPnL
+		}
+	}
+	if s.Trades == 0 {
+		return s
+	}
+	s.WinRate = float64(s.Wins) / float64(s.Trades) * 100
+	s.AvgHolding = holding / time.Duration(s.Trades)
+	if s.Wins > 0 {
+		s.AvgWin = grossWin / float64(s.Wins)
+	}
+	if s.Losses > 0 {
+		s.AvgLoss = -grossLoss / float64(s.Losses)
+	}
+	if grossLoss > 0 {
+		s.ProfitFactor = grossWin / grossLoss
+	}
+	for i := len(outcomes) - 1; i >= 0 && len(s.Recent) < recent; i-- {
+		s.Recent = append(s.Recent, outcomes[i])
+	}
+	return s
+}
+
+// Text 格式化为提示词中的近期表现反馈（没有已平仓结果时为空）
+func (s Summary) Text() string {
+	if s.Trades == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("## 近期交易结果反馈\n")
+	fmt.Fprintf(&b, "最近%d笔: 胜率%.0f%% (%d胜/%d负) | 净盈亏%+.2f USDT | 平均盈利%+.2f 平均亏损%+.2f | 盈亏因子%.2f | 平均持仓%s\n",
+		s.Trades, s.WinRate, s.Wins, s.Losses, s.NetPnL, s.AvgWin, s.AvgLoss, s.ProfitFactor, formatHolding(s.AvgHolding))
+	for _, o := range s.Recent {
+		fmt.Fprintf(&b, "- %s %s | 入场%.4f → 出场%.4f | 净盈亏%+.2f (%+.2f%%) | 持仓%s | %s\n",
+			o.Symbol, strings.ToUpper(o.Side), o.EntryPrice, o.ExitPrice, o.NetPnL, o.ReturnPct,
+			formatHolding(o.Holding), o.CloseReason)
+	}
+	return b.String()
+}
+
+// formatHolding 持仓时长（分钟/小时）
+func formatHolding(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%d分钟", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%.1f小时", d.Hours())
+}
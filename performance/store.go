@@ -0,0 +1,173 @@
+package performance
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultReconcileDB 对账工具 -db 的默认路径
+var DefaultReconcileDB = filepath.Join("tools", "log_reconcile", "reconcile.db")
+
+// Store 只读访问对账数据库（log_reconcile 写入的 orders/trades）
+type Store struct {
+	db        *sql.DB
+	hasOrders bool // 对账工具尚未拉单/拉成交时对应的表不存在
+	hasTrades bool
+}
+
+// OpenStore 以只读方式打开对账数据库（文件不存在时返回错误，不会创建）
+func OpenStore(path string) (*Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("对账数据库不可用: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("打开对账数据库失败: %w", err)
+	}
+	_, _ = db.Exec("PRAGMA busy_timeout=5000")
+	s := &Store{db: db}
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name IN ('orders', 'trades')`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("读取对账数据库失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("读取对账数据库失败: %w", err)
+		}
+		s.hasOrders = s.hasOrders || name == "orders"
+		s.hasTrades = s.hasTrades || name == "trades"
+	}
+	return s, nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// fill 一张平仓单的成交汇总
+type fill struct {
+	orderID int64
+	qty     float64
+	price   float64
+	time    time.Time
+}
+
+// closingFills 查询开仓之后、until 之前已成交的平仓单（按成交时间正序）
+// 双向持仓按 position_side 匹配，单向持仓要求 reduce_only 或 close_position
+func (s *Store) closingFills(traderID, symbol, side string, from, until time.Time) ([]fill, error) {
+	if !s.hasOrders {
+		return nil, nil
+	}
+	closeSide, posSide := "SELL", "LONG"
+	if side == "short" {
+		closeSide, posSide = "BUY", "SHORT"
+	}
+	rows, err := s.db.Query(`SELECT order_id, executed_qty, avg_price, COALESCE(NULLIF(update_time, 0), time)
+		FROM orders
+		WHERE trader_id = ? AND symbol = ? AND status = 'FILLED' AND side = ?
+		  AND (position_side = ? OR (position_side IN ('', 'BOTH') AND (reduce_only = 1 OR close_position = 1)))
+		  AND COALESCE(NULLIF(update_time, 0), time) > ? AND COALESCE(NULLIF(update_time, 0), time) <= ?
+		ORDER BY COALESCE(NULLIF(update_time, 0), time)`,
+		traderID, symbol, closeSide, posSide, from.UnixMilli(), until.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fills []fill
+	for rows.Next() {
+		var f fill
+		var ts int64
+		if err := rows.Scan(&f.orderID, &f.qty, &f.price, &ts); err != nil {
+			return nil, err
+		}
+		f.time = time.UnixMilli(ts)
+		fills = append(fills, f)
+	}
+	return fills, rows.Err()
+}
+
+// orderSummary 若干订单的成交汇总
+type orderSummary struct {
+	qty         float64
+	value       float64 // 数量 × 价格
+	realizedPnL float64
+	commission  float64
+}
+
+// orderFills 汇总订单的成交记录（trades 表，没有成交记录时 qty 为 0）
+func (s *Store) orderFills(traderID, symbol string, orderIDs []int64) (orderSummary, error) {
+	var sum orderSummary
+	if !s.hasTrades || len(orderIDs) == 0 {
+		return sum, nil
+	}
+	args := []any{traderID, symbol}
+	for _, id := range orderIDs {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(orderIDs)), ",")
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(qty), 0), COALESCE(SUM(qty * price), 0), COALESCE(SUM(realized_pnl), 0), COALESCE(SUM(commission), 0)
+		FROM trades WHERE trader_id = ? AND symbol = ? AND order_id IN (`+placeholders+`)`, args...).
+		Scan(&sum.qty, &sum.value, &sum.realizedPnL, &sum.commission)
+	return sum, err
+}
+
+// storeRetryInterval 对账数据库打开失败后的重试间隔（对账工具可能稍后才生成数据库）
+const storeRetryInterval = 10 * time.Minute
+
+// 进程内共享的对账数据库（多个交易员共用，首次使用时打开）
+var (
+	sharedMu    sync.Mutex
+	sharedPath  string
+	sharedStore *Store
+	sharedErr   error
+	sharedTried time.Time
+)
+
+// SetReconcileDB 设置对账数据库路径（空字符串使用 DefaultReconcileDB），关闭之前打开的数据库
+func SetReconcileDB(path string) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if sharedStore != nil {
+		sharedStore.Close()
+	}
+	sharedPath, sharedStore, sharedErr, sharedTried = path, nil, nil, time.Time{}
+}
+
+// CloseShared 关闭共享的对账数据库（进程退出时调用）
+func CloseShared() error {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	var err error
+	if sharedStore != nil {
+		err = sharedStore.Close()
+	}
+	sharedStore, sharedErr, sharedTried = nil, nil, time.Time{}
+	return err
+}
+
+// SharedStore 返回共享的对账数据库（打开失败时返回 nil 与错误，storeRetryInterval 后再重试）
+func SharedStore() (*Store, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if sharedStore == nil && time.Since(sharedTried) >= storeRetryInterval {
+		sharedTried = time.Now()
+		path := sharedPath
+		if path == "" {
+			path = DefaultReconcileDB
+		}
+		sharedStore, sharedErr = OpenStore(path)
+	}
+	return sharedStore, sharedErr
+}
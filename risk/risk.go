@@ -0,0 +1,310 @@
+package risk
+
+import (
+	"fmt"
+	"log"
+	"nofx/decision"
+	"nofx/market"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 风控层：位于 AI 决策与下单之间，按硬性限制拒绝开仓决策。
+//
+// 平仓、调整止损止盈、持有等降低或不增加风险的动作总是放行；开仓依次检查暂停交易、最大持仓数、最大杠杆、
+// 单币种最大名义价值、相关性敞口与资金费成本（临近结算时开出需支付资金费的仓位）。日亏损超过限制时暂停交易 HaltDuration，暂停期间拒绝所有开仓。
+// 每条拒绝都以 Override 返回，由调用方写入决策日志。
+
+// 规则名称（写入 Override.Rule）
+const (
+	RuleHalted             = "halted"              // 日亏损触发的暂停交易期间
+	RuleKillSwitch         = "kill_switch"         // 紧急停止（人工暂停）期间
+	RuleDailyLoss          = "daily_loss"          // 日亏损超过限制（触发暂停）
+	RuleMaxPositions       = "max_positions"       // 持仓数量已达上限
+	RuleMaxLeverage        = "max_leverage"        // 杠杆超过上限
+	RuleMaxNotional        = "max_notional"        // 单币种名义价值超过上限
+	RuleCorrelatedExposure = "correlated_exposure" // 同方向高相关持仓的合计名义价值超过上限
+	RuleFundingCost        = "funding_cost"        // 临近结算且预期持仓期间的资金费超过上限
+)
+
+// defaultCorrelationThreshold 视为高相关的相关系数下限
+const defaultCorrelationThreshold = 0.8
+
+// Limits 风控限制（0 表示不限制）
+type Limits struct {
+	MaxPositions          int                      // 最大同时持仓数
+	MaxLeverage           int                      // 最大杠杆
+	MaxNotionalPerSymbol  float64                  // 单币种最大名义价值（USDT，含已有持仓）
+	MaxDailyLossPct       float64                  // 日亏损上限（相对当日 UTC 零点后首次记录的净值，百分比）
+	HaltDuration          time.Duration            // 触发日亏损后的暂停时长（0 时暂停到次日 UTC 零点）
+	MaxCorrelatedExposure float64                  // 同方向高相关持仓的合计名义价值上限（USDT）
+	CorrelationThreshold  float64                  // 高相关阈值（默认 0.8）
+	CorrelationWindow     market.CorrelationWindow // 相关性窗口（零值使用行情监控的第一个配置窗口）
+	MaxFundingCostPct     float64                  // 预期持仓期间资金费占名义价值的百分比上限
+	FundingWindow         time.Duration            // 距下次结算不超过该时长时才检查资金费（0 表示总是检查）
+	HoldingHours          float64                  // 估算资金费的预期持仓时长（默认 market.DefaultHoldingHours）
+}
+
+// Position 当前持仓
+type Position struct {
+	Symbol   string
+	Side     string // long/short
+	Notional float64
+	Leverage int
+}
+
+// Override 一条被风控拒绝的决策
+type Override struct {
+	Symbol string `json:"symbol"`
+	Action string `json:"action"`
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// CorrelationFunc 返回两个交易对的相关系数，无法计算时返回 false
+type CorrelationFunc func(a, b string) (float64, bool)
+
+// Manager 单个交易员的风控状态
+type Manager struct {
+	id     string
+	limits Limits
+	store  Store // 日亏损统计与暂停状态的持久化（nil 时只在内存中）
+
+	mu          sync.Mutex
+	day         string  // 当前统计日（UTC）
+	dayStart    float64 // 当日首次记录的净值
+	haltUntil   time.Time
+	correlation func(symbols []string) (CorrelationFunc, error)
+	funding     func(symbol string) (*market.FundingData, error)
+	paused      func() (string, bool) // 紧急停止状态（返回原因）
+}
+
+// NewManager 创建交易员的风控管理器，store 不为 nil 时恢复上次保存的当日起始净值与暂停状态
+func NewManager(traderID string, limits Limits, store Store) *Manager {
+	if limits.CorrelationThreshold <= 0 {
+		limits.CorrelationThreshold = defaultCorrelationThreshold
+	}
+	m := &Manager{id: traderID, limits: limits, store: store}
+	m.loadState()
+	m.correlation = func(symbols []string) (CorrelationFunc, error) {
+		cm, err := market.GetCorrelationMatrix(symbols, m.limits.CorrelationWindow)
+		if err != nil {
+			return nil, err
+		}
+		return cm.Get, nil
+	}
+	m.funding = market.GetFundingData
+	return m
+}
+
+// SetPauseCheck 设置紧急停止检查：返回 true 时拒绝所有开仓
+func (m *Manager) SetPauseCheck(fn func() (reason string, paused bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.paused = fn
+}
+
+// HaltedUntil 暂停交易的截止时间（未暂停时 ok 为 false）
+func (m *Manager) HaltedUntil(now time.Time) (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.haltUntil, now.Before(m.haltUntil)
+}
+
+// UpdateEquity 记录最新净值并检查日亏损；新触发暂停时返回对应的 Override
+// 当日起始净值或暂停截止时间变化时写入存储
+func (m *Manager) UpdateEquity(equity float64, now time.Time) *Override {
+	m.mu.Lock()
+	override, changed := m.updateEquityLocked(equity, now)
+	st := persistedState{Day: m.day, DayStart: m.dayStart, HaltUntil: m.haltUntil}
+	m.mu.Unlock()
+
+	if changed {
+		m.saveState(st)
+	}
+	return override
+}
+
+// updateEquityLocked UpdateEquity 的状态更新部分（调用方持有 m.mu），changed 表示需要持久化
+func (m *Manager) updateEquityLocked(equity float64, now time.Time) (override *Override, changed bool) {
+	day := now.UTC().Format("2006-01-02")
+	if day != m.day || m.dayStart <= 0 {
+		m.day, m.dayStart = day, equity
+		changed = true
+	}
+	if m.limits.MaxDailyLossPct <= 0 || m.dayStart <= 0 || now.Before(m.haltUntil) {
+		return nil, changed
+	}
+	lossPct := (m.dayStart - equity) / m.dayStart * 100
+	if lossPct < m.limits.MaxDailyLossPct {
+		return nil, changed
+	}
+	if m.limits.HaltDuration > 0 {
+		m.haltUntil = now.Add(m.limits.HaltDuration)
+	} else {
+		y, mo, d := now.UTC().Date()
+		m.haltUntil = time.Date(y, mo, d+1, 0, 0, 0, 0, time.UTC)
+	}
+	return &Override{
+		Rule: RuleDailyLoss,
+		Reason: fmt.Sprintf("当日亏损 %.2f%%（%.2f → %.2f USDT）超过上限 %.2f%%，暂停开仓至 %s",
+			lossPct, m.dayStart, equity, m.limits.MaxDailyLossPct, m.haltUntil.Format(time.RFC3339)),
+	}, true
+}
+
+// isOpen 是否为开仓动作
+func isOpen(action string) bool {
+	return action == "open_long" || action == "open_short"
+}
+
+// Check 按顺序检查决策（调用方应先排好“先平后开”的顺序），返回放行的决策与被拒绝的记录
+func (m *Manager) Check(decisions []decision.Decision, positions []Position, now time.Time) ([]decision.Decision, []Override) {
+	haltUntil, halted := m.HaltedUntil(now)
+	m.mu.Lock()
+	pausedFn := m.paused
+	m.mu.Unlock()
+	var pauseReason string
+	paused := false
+	if pausedFn != nil {
+		pauseReason, paused = pausedFn()
+	}
+	open := make(map[string]Position, len(positions))
+	for _, p := range positions {
+		open[p.Symbol+"_"+p.Side] = p
+	}
+	corr := m.correlationFor(decisions, positions)
+
+	var allowed []decision.Decision
+	var overrides []Override
+	for _, d := range decisions {
+		if !isOpen(d.Action) {
+			// 平仓后从持仓中移除，后续开仓按平仓后的持仓检查
+			switch d.Action {
+			case "close_long":
+				delete(open, d.Symbol+"_long")
+			case "close_short":
+				delete(open, d.Symbol+"_short")
+			}
+			allowed = append(allowed, d)
+			continue
+		}
+		side := strings.TrimPrefix(d.Action, "open_")
+		reject := func(rule, format string, args ...any) {
+			overrides = append(overrides, Override{Symbol: d.Symbol, Action: d.Action, Rule: rule, Reason: fmt.Sprintf(format, args...)})
+		}
+		switch {
+		case paused:
+			reject(RuleKillSwitch, "%s", pauseReason)
+			continue
+		case halted:
+			reject(RuleHalted, "日亏损风控暂停开仓中（至 %s）", haltUntil.Format(time.RFC3339))
+			continue
+		case m.limits.MaxPositions > 0 && len(open) >= m.limits.MaxPositions:
+			reject(RuleMaxPositions, "当前持仓 %d 个，已达上限 %d", len(open), m.limits.MaxPositions)
+			continue
+		case m.limits.MaxLeverage > 0 && d.Leverage > m.limits.MaxLeverage:
+			reject(RuleMaxLeverage, "杠杆 %dx 超过上限 %dx", d.Leverage, m.limits.MaxLeverage)
+			continue
+		}
+		if m.limits.MaxNotionalPerSymbol > 0 {
+			total := d.PositionSizeUSD
+			for _, p := range open {
+				if p.Symbol == d.Symbol {
+					total += p.Notional
+				}
+			}
+			if total > m.limits.MaxNotionalPerSymbol {
+				reject(RuleMaxNotional, "%s 名义价值 %.2f USDT（含已有持仓）超过上限 %.2f USDT", d.Symbol, total, m.limits.MaxNotionalPerSymbol)
+				continue
+			}
+		}
+		if m.limits.MaxCorrelatedExposure > 0 && corr != nil {
+			total, related := d.PositionSizeUSD, []string(nil)
+			for _, p := range open {
+				if p.Side != side {
+					continue
+				}
+				if p.Symbol != d.Symbol {
+					if c, ok := corr(d.Symbol, p.Symbol); !ok || c < m.limits.CorrelationThreshold {
+						continue
+					}
+				}
+				total += p.Notional
+				related = append(related, p.Symbol)
+			}
+			if total > m.limits.MaxCorrelatedExposure {
+				reject(RuleCorrelatedExposure, "与 %s 同向高相关（≥%.2f），合计名义价值 %.2f USDT 超过上限 %.2f USDT",
+					strings.Join(related, ","), m.limits.CorrelationThreshold, total, m.limits.MaxCorrelatedExposure)
+				continue
+			}
+		}
+		if cost, ok := m.fundingCost(d, side, now); ok {
+			reject(RuleFundingCost, "%s 距下次资金费结算 %s，预计持有 %.0f 小时需支付资金费 %.2f USDT（%.3f%%），超过上限 %.3f%%",
+				d.Symbol, cost.NextIn.Round(time.Minute), cost.HoldingHours, cost.Payment, cost.PaymentPct, m.limits.MaxFundingCostPct)
+			continue
+		}
+		// 放行的开仓计入持仓，约束同一批次中后续的开仓
+		open[d.Symbol+"_"+side] = Position{Symbol: d.Symbol, Side: side, Notional: d.PositionSizeUSD, Leverage: d.Leverage}
+		allowed = append(allowed, d)
+	}
+	return allowed, overrides
+}
+
+// fundingCost 开仓临近结算、下次结算需支付资金费且预期持仓期间的资金费超过上限时返回估算（获取费率失败时放行）
+func (m *Manager) fundingCost(d decision.Decision, side string, now time.Time) (market.FundingCost, bool) {
+	if m.limits.MaxFundingCostPct <= 0 {
+		return market.FundingCost{}, false
+	}
+	fd, err := m.funding(d.Symbol)
+	if err != nil {
+		log.Printf("⚠ 获取 %s 资金费率失败，跳过资金费检查: %v", d.Symbol, err)
+		return market.FundingCost{}, false
+	}
+	notional := d.PositionSizeUSD
+	if notional <= 0 {
+		notional = 1 // 仓位由下单时计算，按单位名义价值比较百分比
+	}
+	cost := market.EstimateFundingCost(fd, side, notional, m.limits.HoldingHours, now)
+	if cost.NextPayment <= 0 || (m.limits.FundingWindow > 0 && cost.NextIn > m.limits.FundingWindow) {
+		return cost, false
+	}
+	return cost, cost.PaymentPct > m.limits.MaxFundingCostPct
+}
+
+// correlationFor 为本批开仓与现有持仓计算相关系数（未配置相关性敞口或没有开仓时返回 nil；计算失败时跳过该项检查）
+func (m *Manager) correlationFor(decisions []decision.Decision, positions []Position) CorrelationFunc {
+	if m.limits.MaxCorrelatedExposure <= 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			symbols = append(symbols, s)
+		}
+	}
+	hasOpen := false
+	for _, d := range decisions {
+		if isOpen(d.Action) {
+			hasOpen = true
+			add(d.Symbol)
+		}
+	}
+	if !hasOpen {
+		return nil
+	}
+	for _, p := range positions {
+		add(p.Symbol)
+	}
+	if len(symbols) < 2 {
+		return func(a, b string) (float64, bool) { return 0, false }
+	}
+	corr, err := m.correlation(symbols)
+	if err != nil {
+		log.Printf("⚠ 计算相关性失败，本周期跳过相关性敞口检查: %v", err)
+		return nil
+	}
+	return corr
+}
@@ -0,0 +1,64 @@
+package risk
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// 日亏损统计（当日起始净值）与暂停截止时间按交易员写入 system_config，
+// 进程重启后恢复，避免重启即清空当日亏损统计或提前解除暂停。
+
+// stateKeyPrefix system_config 中风控状态的键前缀（完整键为 risk_state:<交易员ID>）
+const stateKeyPrefix = "risk_state"
+
+// Store 持久化风控状态（config.Database 满足该接口）
+type Store interface {
+	GetSystemConfig(key string) (string, error)
+	SetSystemConfig(key, value string) error
+}
+
+// persistedState 持久化的风控状态
+type persistedState struct {
+	Day       string    `json:"day"`
+	DayStart  float64   `json:"day_start"`
+	HaltUntil time.Time `json:"halt_until,omitempty"`
+}
+
+func stateKey(traderID string) string {
+	return stateKeyPrefix + ":" + traderID
+}
+
+// loadState 从存储恢复风控状态（无记录或无法解析时保持初始状态）
+func (m *Manager) loadState() {
+	if m.store == nil || m.id == "" {
+		return
+	}
+	value, err := m.store.GetSystemConfig(stateKey(m.id))
+	if err != nil || value == "" {
+		return
+	}
+	var st persistedState
+	if err := json.Unmarshal([]byte(value), &st); err != nil {
+		log.Printf("⚠️  [%s] 风控状态无法解析，重新开始统计: %v", m.id, err)
+		return
+	}
+	m.day, m.dayStart, m.haltUntil = st.Day, st.DayStart, st.HaltUntil
+	if time.Now().Before(st.HaltUntil) {
+		log.Printf("⏸ [%s] 已恢复日亏损暂停状态，暂停开仓至 %s", m.id, st.HaltUntil.Format(time.RFC3339))
+	}
+}
+
+// saveState 写入风控状态（调用方不持有 m.mu）
+func (m *Manager) saveState(st persistedState) {
+	if m.store == nil || m.id == "" {
+		return
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return
+	}
+	if err := m.store.SetSystemConfig(stateKey(m.id), string(data)); err != nil {
+		log.Printf("⚠️  [%s] 保存风控状态失败: %v", m.id, err)
+	}
+}
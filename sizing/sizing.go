@@ -0,0 +1,143 @@
+package sizing
+
+import (
+	"fmt"
+	"math"
+	"nofx/market"
+)
+
+// 仓位计算：把 AI 的开仓决策换算为符合交易所规则的下单数量。
+//
+// 配置了每笔风险（RiskPerTradePct）时，名义价值 = 净值 × 风险比例 ÷ 止损距离 × 价格，止损距离取
+// ATR × 倍数与 AI 止损距离中较大者（止损被触发时的亏损不超过风险预算）；否则沿用 AI 的 position_size_usd。
+// AI 没有给出止损（或止损在错误一侧）时按 ATR 距离设置止损。最后按可用保证金封顶，
+// 按 stepSize 向下取整并检查最小下单量与最小名义价值（不为满足下限而放大仓位）。
+
+const (
+	defaultATRMultiplier = 2.0
+	marginBuffer         = 0.95 // 可用保证金的使用上限（预留手续费与价格波动）
+)
+
+// 计算方式（写入 Result.Method）
+const (
+	MethodATRRisk = "atr_risk" // 按每笔风险与止损距离计算
+	MethodAISize  = "ai_size"  // 沿用 AI 给出的名义价值
+)
+
+// Config 仓位计算配置
+type Config struct {
+	RiskPerTradePct float64 // 每笔风险占净值的百分比（0 表示沿用 AI 的名义价值）
+	ATRMultiplier   float64 // 止损距离的 ATR 倍数（默认 2）
+}
+
+// Input 一次开仓的计算输入
+type Input struct {
+	Symbol       string
+	Side         string  // long/short
+	Price        float64 // 当前价格
+	Equity       float64 // 账户净值
+	Available    float64 // 可用保证金
+	Leverage     int
+	StopLoss     float64 // AI 给出的止损价（0 表示未给出）
+	ATR          float64 // 止损距离参考的 ATR（0 表示不可用）
+	RequestedUSD float64 // AI 给出的名义价值
+	MaxNotional  float64 // 名义价值上限（0 表示不限制，通常为风控的单币种上限）
+}
+
+// Result 计算结果
+type Result struct {
+	Quantity     float64 // 已按交易所规则取整的下单数量
+	Notional     float64 // Quantity × Price
+	StopLoss     float64 // 实际使用的止损价（已按 tickSize 取整）
+	StopDistance float64 // 止损距离（价格）
+	Method       string
+	Capped       bool // 是否被名义价值上限、可用保证金或最大下单量封顶
+}
+
+// ATR 选取止损距离参考的 ATR14：优先 1 小时，其次 4 小时、3 分钟
+func ATR(data *market.Data) float64 {
+	if data == nil {
+		return 0
+	}
+	if data.Intraday1h != nil && data.Intraday1h.ATR14 > 0 {
+		return data.Intraday1h.ATR14
+	}
+	if data.LongerTermContext != nil && data.LongerTermContext.ATR14 > 0 {
+		return data.LongerTermContext.ATR14
+	}
+	if data.IntradaySeries != nil && data.IntradaySeries.ATR14 > 0 {
+		return data.IntradaySeries.ATR14
+	}
+	return 0
+}
+
+// Size 计算下单数量；rules 为 nil 时不做交易所规则取整与校验
+func Size(in Input, cfg Config, rules *market.SymbolRules) (Result, error) {
+	if in.Price <= 0 {
+		return Result{}, fmt.Errorf("%s 价格无效: %v", in.Symbol, in.Price)
+	}
+	if in.Leverage <= 0 {
+		in.Leverage = 1
+	}
+	mult := cfg.ATRMultiplier
+	if mult <= 0 {
+		mult = defaultATRMultiplier
+	}
+
+	res := Result{StopLoss: in.StopLoss, Method: MethodAISize}
+	atrDist := in.ATR * mult
+	aiDist := 0.0
+	if in.StopLoss > 0 && ((in.Side == "long" && in.StopLoss < in.Price) || (in.Side == "short" && in.StopLoss > in.Price)) {
+		aiDist = math.Abs(in.Price - in.StopLoss)
+	} else if atrDist > 0 {
+		// 没有有效的止损：按 ATR 距离设置
+		res.StopLoss = in.Price - atrDist
+		if in.Side == "short" {
+			res.StopLoss = in.Price + atrDist
+		}
+	} else {
+		res.StopLoss = 0
+	}
+	res.StopDistance = math.Max(aiDist, atrDist)
+
+	notional := in.RequestedUSD
+	if cfg.RiskPerTradePct > 0 && res.StopDistance > 0 && in.Equity > 0 {
+		riskUSD := in.Equity * cfg.RiskPerTradePct / 100
+		notional = riskUSD / res.StopDistance * in.Price
+		res.Method = MethodATRRisk
+	}
+	if notional <= 0 {
+		return res, fmt.Errorf("%s 无法确定仓位大小（AI 未给出 position_size_usd，且没有可用的止损距离）", in.Symbol)
+	}
+	if in.MaxNotional > 0 && notional > in.MaxNotional {
+		notional = in.MaxNotional
+		res.Capped = true
+	}
+	if in.Available > 0 {
+		if maxNotional := in.Available * float64(in.Leverage) * marginBuffer; notional > maxNotional {
+			notional = maxNotional
+			res.Capped = true
+		}
+	}
+
+	qty := notional / in.Price
+	if rules != nil {
+		if rules.MarketMaxQty > 0 && qty > rules.MarketMaxQty {
+			qty = rules.MarketMaxQty
+			res.Capped = true
+		}
+		qty = rules.RoundQty(qty)
+		if res.StopLoss > 0 {
+			res.StopLoss = rules.RoundPrice(res.StopLoss)
+		}
+		if err := rules.Validate(in.Price, qty); err != nil {
+			return res, fmt.Errorf("计算出的仓位不满足下单规则: %w", err)
+		}
+	}
+	if qty <= 0 {
+		return res, fmt.Errorf("%s 计算出的数量为 0", in.Symbol)
+	}
+	res.Quantity = qty
+	res.Notional = qty * in.Price
+	return res, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"nofx/backtest"
+	"nofx/market"
+)
+
+// 回测工具：按历史K线回放决策日志中的AI决策，输出净值、回撤、胜率与各币种统计
+//
+//	go run ./tools/backtest -decision_dir decision_logs/trader_1 -interval 3m -out result.json
+func main() {
+	var decisionDir string
+	var symbolsSpec string
+	var fromSpec, toSpec string
+	var interval string
+	var balance, feeRate, slippageBps float64
+	var snapshotPath string
+	var outPath string
+	flag.StringVar(&decisionDir, "decision_dir", "decision_logs", "决策日志目录（decision_*.json）")
+	flag.StringVar(&symbolsSpec, "symbols", "", "回测交易对，逗号分隔（默认取决策日志中出现过的交易对）")
+	flag.StringVar(&fromSpec, "from", "", "开始时间 2006-01-02 或 2006-01-02T15:04（默认取决策日志最早时间）")
+	flag.StringVar(&toSpec, "to", "", "结束时间（默认取决策日志最晚时间）")
+	flag.StringVar(&interval, "interval", "3m", "K线周期")
+	flag.Float64Var(&balance, "balance", 1000, "初始资金（USDT）")
+	flag.Float64Var(&feeRate, "fee", 0.0004, "单边手续费率")
+	flag.Float64Var(&slippageBps, "slippage_bps", 2, "成交滑点（基点，负数表示不计滑点）")
+	flag.StringVar(&snapshotPath, "snapshots", "", "市场快照存储（.db 为 SQLite，否则为 JSONL 目录），设置后策略可获得完整市场数据")
+	flag.StringVar(&outPath, "out", "", "回测结果 JSON 输出路径")
+	flag.Parse()
+
+	from, err := parseTime(fromSpec)
+	if err != nil {
+		log.Fatalf("❌ 解析 -from 失败: %v", err)
+	}
+	to, err := parseTime(toSpec)
+	if err != nil {
+		log.Fatalf("❌ 解析 -to 失败: %v", err)
+	}
+
+	records, err := backtest.LoadDecisionRecords(decisionDir, from, to)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if len(records) == 0 {
+		log.Fatalf("❌ %s 中没有符合条件的决策记录", decisionDir)
+	}
+	strategy := backtest.NewRecordedStrategy(records)
+	first, last := strategy.TimeRange()
+	if from.IsZero() {
+		from = first
+	}
+	if to.IsZero() {
+		to = last
+	}
+
+	symbols := strategy.Symbols()
+	if symbolsSpec != "" {
+		symbols = strings.Split(symbolsSpec, ",")
+	}
+	log.Printf("🧪 回测 %d 条决策记录，交易对 %v，区间 %s ~ %s", len(records), symbols, from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	engine := backtest.NewEngine(backtest.Config{
+		InitialBalance: balance,
+		FeeRate:        feeRate,
+		SlippageBps:    slippageBps,
+		Interval:       interval,
+		From:           from,
+		To:             to,
+	}, strategy)
+	if err := engine.LoadKlines(symbols); err != nil {
+		log.Fatalf("❌ 加载K线失败: %v", err)
+	}
+
+	if snapshotPath != "" {
+		var store market.SnapshotStore
+		if strings.HasSuffix(snapshotPath, ".db") {
+			store, err = market.NewSQLiteSnapshotStore(snapshotPath)
+		} else {
+			store, err = market.NewJSONSnapshotStore(snapshotPath)
+		}
+		if err != nil {
+			log.Fatalf("❌ 打开快照存储失败: %v", err)
+		}
+		defer store.Close()
+		engine.SetReplay(market.NewReplay(store, from, to))
+	}
+
+	result, err := engine.Run()
+	if err != nil {
+		log.Fatalf("❌ 回测失败: %v", err)
+	}
+	fmt.Print(result.Summary())
+
+	if outPath != "" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatalf("❌ 序列化回测结果失败: %v", err)
+		}
+		if err := os.WriteFile(outPath, data, 0644); err != nil {
+			log.Fatalf("❌ 写入回测结果失败: %v", err)
+		}
+		log.Printf("✅ 回测结果已保存: %s", outPath)
+	}
+}
+
+// parseTime 解析日期或日期时间（本地时区），空字符串返回零值
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法识别的时间格式: %s", s)
+}
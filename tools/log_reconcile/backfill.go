@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 历史订单回补（-backfill_from）
+//
+// 初次拉单只取最近 7 天，更早开仓的仓位找不到订单。回补从配置的起始日期按 7 天窗口（allOrders 单次查询上限）
+// 由旧到新拉取，直到本地已有的最早订单时间（没有订单时到当前时间）。进度保存在 reconcile_state 的 backfill_* 列，
+// 每完成一个窗口提交一次，中断后下次运行从断点继续；起始日期改早时从新的起始日期重新回补到原目标。
+
+const (
+	// backfillWindow 单次查询的时间窗口（币安 allOrders 要求 endTime-startTime 不超过 7 天）
+	backfillWindow = 7 * 24 * time.Hour
+	// backfillMinWindow 单页订单数达到上限时二分窗口的最小粒度
+	backfillMinWindow = time.Minute
+)
+
+// parseBackfillFrom 解析回补起始日期（2006-01-02，UTC）；留空表示不回补
+func parseBackfillFrom(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("解析 -backfill_from 失败（格式 2006-01-02）: %w", err)
+	}
+	if t.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("-backfill_from %s 晚于当前时间", s)
+	}
+	return t, nil
+}
+
+// backfillState 回补进度（毫秒时间戳）
+type backfillState struct {
+	From   int64 // 已生效的起始时间
+	Cursor int64 // 已完成到的时间
+	Until  int64 // 回补终点（开始回补时本地最早订单时间）
+}
+
+func (s backfillState) done() bool {
+	return s.Until > 0 && s.Cursor >= s.Until
+}
+
+// loadBackfillState 读取回补进度，并按本次起始日期确定起点与终点
+func loadBackfillState(db *sql.DB, traderID, symbol string, from time.Time) (backfillState, error) {
+	var st backfillState
+	var f, c, u sql.NullInt64
+	err := db.QueryRow(`SELECT backfill_from, backfill_cursor, backfill_until FROM reconcile_state WHERE trader_id = ? AND symbol = ?`, traderID, symbol).Scan(&f, &c, &u)
+	if err != nil && err != sql.ErrNoRows {
+		return st, fmt.Errorf("读取回补进度失败: %w", err)
+	}
+	st = backfillState{From: f.Int64, Cursor: c.Int64, Until: u.Int64}
+	fromMs := from.UnixMilli()
+	switch {
+	case st.Until == 0:
+		// 首次回补：终点取本地最早订单（其后由常规增量拉取覆盖）
+		var earliest sql.NullInt64
+		if err := db.QueryRow(`SELECT MIN(time) FROM orders WHERE trader_id = ? AND symbol = ?`, traderID, symbol).Scan(&earliest); err != nil {
+			return st, fmt.Errorf("读取最早订单时间失败: %w", err)
+		}
+		st.Until = time.Now().UnixMilli()
+		if earliest.Valid && earliest.Int64 > 0 {
+			st.Until = earliest.Int64
+		}
+		st.From, st.Cursor = fromMs, fromMs
+	case fromMs < st.From:
+		// 起始日期改早：从新的起点重新回补（已回补过的部分由唯一约束去重）
+		st.From, st.Cursor = fromMs, fromMs
+	}
+	return st, nil
+}
+
+// backfillOrdersForSymbol 按 7 天窗口回补历史订单，每个窗口提交一次进度
+func backfillOrdersForSymbol(db *sql.DB, client exchangeClient, traderID, symbol string, from time.Time) error {
+	st, err := loadBackfillState(db, traderID, symbol, from)
+	if err != nil {
+		return err
+	}
+	if st.done() {
+		return nil
+	}
+	started := time.Now()
+	total := 0
+	for st.Cursor < st.Until {
+		end := min(st.Cursor+backfillWindow.Milliseconds()-1, st.Until)
+		orders, raw, err := fetchOrdersWindow(client, symbol, st.Cursor, end)
+		if err != nil {
+			return fmt.Errorf("回补 %s ~ %s 失败: %w", time.UnixMilli(st.Cursor).Format("2006-01-02"), time.UnixMilli(end).Format("2006-01-02"), err)
+		}
+		st.Cursor = end + 1
+		if err := saveBackfillChunk(db, traderID, symbol, orders, raw, st); err != nil {
+			return err
+		}
+		total += len(orders)
+		metrics.add(metricOrdersFetched, float64(len(orders)), "trader", traderID, "exchange", client.Name())
+	}
+	log.Printf("✓ [%s] %s 历史回补完成 %s 起 %d 条, 用时 %v", traderID, symbol, time.UnixMilli(st.From).Format("2006-01-02"), total, time.Since(started).Round(time.Second))
+	return nil
+}
+
+// fetchOrdersWindow 拉取一个时间窗口的订单；币安单页达到上限时二分窗口，避免漏单
+func fetchOrdersWindow(client exchangeClient, symbol string, start, end int64) ([]BinanceOrder, []map[string]any, error) {
+	orders, raw, err := client.AllOrders(symbol, 0, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client.Name() != ExchangeBinance || len(orders) < allOrdersLimit || end-start <= backfillMinWindow.Milliseconds() {
+		return orders, raw, nil
+	}
+	mid := start + (end-start)/2
+	left, leftRaw, err := fetchOrdersWindow(client, symbol, start, mid)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, rightRaw, err := fetchOrdersWindow(client, symbol, mid+1, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(left, right...), append(leftRaw, rightRaw...), nil
+}
+
+// saveBackfillChunk 写入一个窗口的订单并更新进度（同一事务，中断后不会跳过未写入的窗口）
+func saveBackfillChunk(db *sql.DB, traderID, symbol string, orders []BinanceOrder, raw []map[string]any, st backfillState) error {
+	return writeTx(db, func(tx *sql.Tx) error {
+		if err := saveOrders(tx, traderID, symbol, orders, raw); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO reconcile_state(trader_id, symbol, backfill_from, backfill_cursor, backfill_until) VALUES(?,?,?,?,?)
+			ON CONFLICT(trader_id, symbol) DO UPDATE SET backfill_from = excluded.backfill_from, backfill_cursor = excluded.backfill_cursor, backfill_until = excluded.backfill_until`,
+			traderID, symbol, st.From, st.Cursor, st.Until)
+		if err != nil {
+			return fmt.Errorf("更新回补进度失败: %w", err)
+		}
+		return nil
+	})
+}
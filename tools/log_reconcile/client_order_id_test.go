@@ -0,0 +1,77 @@
+package main
+
+import (
+	"nofx/logger"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 主程序写入的决策日志带 client_order_id 时，开仓按 clientOrderId 精确匹配，
+// 即使时间窗口内有更接近的其他开仓订单（如手动下单）也不会误配
+func TestReconcileAppLogByClientOrderID(t *testing.T) {
+	const traderID = "trader1"
+	dir := t.TempDir()
+	at := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	appID := "nofx_o_mc3x9k2a_1"
+
+	lg := logger.NewDecisionLogger(filepath.Join(dir, traderID))
+	if err := lg.LogDecision(&logger.DecisionRecord{
+		Success: true,
+		Decisions: []logger.DecisionAction{{
+			Action:        "open_long",
+			Symbol:        "BTCUSDT",
+			Quantity:      0.1,
+			Leverage:      10,
+			Price:         60000,
+			ClientOrderID: appID,
+			Timestamp:     at,
+			Success:       true,
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	manual := hedgeOrder(101, "BUY", "LONG", at.Add(time.Second), "0.1")
+	manual.ClientOrderID = "web_manual"
+	app := hedgeOrder(102, "BUY", "LONG", at.Add(20*time.Second), "0.1")
+	app.ClientOrderID = appID
+	orders := map[string][]BinanceOrder{traderID + "_BTCUSDT_LONG": {manual, app}}
+
+	src, err := openLogSource("", dir, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.close()
+	tols, err := loadTolerances("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := newRunReport("reconcile", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileTrader(src, traderID, orders, newCorrectionGate(nil), tols, false, nil, rep, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := src.records(traderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []DecisionAction
+	for _, r := range records {
+		if rec, ok := parseRecord(r); ok {
+			got = append(got, rec.Decisions...)
+		}
+	}
+	if len(got) != 1 {
+		t.Fatalf("应只有一条开仓记录, got %d", len(got))
+	}
+	if got[0].ClientOrderID != appID {
+		t.Errorf("client_order_id 应原样保留, got %q", got[0].ClientOrderID)
+	}
+	if got[0].OrderID != 102 {
+		t.Errorf("应按 clientOrderId 匹配订单 102（而不是时间最近的 101）, got %d", got[0].OrderID)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Severity 校正的严重级别
+type Severity string
+
+const (
+	SeverityInfo  Severity = "info"  // 仅元数据修正，如 OrderID 不一致
+	SeverityMinor Severity = "minor" // 数值修正，如价格/数量偏差
+	SeverityMajor Severity = "major" // 语义修改，如开/平仓改为 wait、补全缺失的平仓
+)
+
+// PolicyMode 各严重级别的处理方式
+type PolicyMode string
+
+const (
+	PolicyAuto    PolicyMode = "auto"    // 自动写回日志
+	PolicyReport  PolicyMode = "report"  // 仅写入报告，不修改日志
+	PolicyApprove PolicyMode = "approve" // 逐条交互确认
+)
+
+// CorrectionPolicy 严重级别 → 处理方式
+type CorrectionPolicy map[Severity]PolicyMode
+
+// defaultCorrectionPolicy 默认全部自动应用（与历史行为一致）
+func defaultCorrectionPolicy() CorrectionPolicy {
+	return CorrectionPolicy{
+		SeverityInfo:  PolicyAuto,
+		SeverityMinor: PolicyAuto,
+		SeverityMajor: PolicyAuto,
+	}
+}
+
+// parseCorrectionPolicy 解析形如 "info=auto,minor=report,major=approve" 的策略配置，未指定的级别保持默认
+func parseCorrectionPolicy(s string) (CorrectionPolicy, error) {
+	policy := defaultCorrectionPolicy()
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("无效的策略项: %s（应为 级别=方式）", part)
+		}
+		sev := Severity(strings.ToLower(strings.TrimSpace(kv[0])))
+		mode := PolicyMode(strings.ToLower(strings.TrimSpace(kv[1])))
+		switch sev {
+		case SeverityInfo, SeverityMinor, SeverityMajor:
+		default:
+			return nil, fmt.Errorf("未知严重级别: %s（可选 info|minor|major）", sev)
+		}
+		switch mode {
+		case PolicyAuto, PolicyReport, PolicyApprove:
+		default:
+			return nil, fmt.Errorf("未知处理方式: %s（可选 auto|report|approve）", mode)
+		}
+		policy[sev] = mode
+	}
+	return policy, nil
+}
+
+// Correction 一条拟执行的校正
+type Correction struct {
+	TraderID    string
+	Symbol      string
+	Action      string
+	Severity    Severity
+	Description string
+}
+
+// correctionGate 按策略决定每条校正是否应用，并记录统计
+type correctionGate struct {
+	policy  CorrectionPolicy
+	reader  *bufio.Reader
+	applied map[Severity]int
+	skipped map[Severity]int
+	dryRun  bool // 预演模式：approve 级别不再询问，按将会应用处理以便预览
+}
+
+func newCorrectionGate(policy CorrectionPolicy) *correctionGate {
+	if policy == nil {
+		policy = defaultCorrectionPolicy()
+	}
+	return &correctionGate{
+		policy:  policy,
+		reader:  bufio.NewReader(os.Stdin),
+		applied: make(map[Severity]int),
+		skipped: make(map[Severity]int),
+	}
+}
+
+// allow 返回该校正是否应写回日志
+func (g *correctionGate) allow(c Correction) bool {
+	ok := false
+	switch g.policy[c.Severity] {
+	case PolicyAuto:
+		ok = true
+	case PolicyApprove:
+		ok = g.dryRun || g.ask(c)
+	}
+	if ok {
+		g.applied[c.Severity]++
+	} else {
+		g.skipped[c.Severity]++
+	}
+	return ok
+}
+
+// ask 交互确认（输入 y/yes 应用，其余跳过；stdin 不可用时视为跳过）
+func (g *correctionGate) ask(c Correction) bool {
+	fmt.Printf("❓ [%s] %s\n   应用该校正? [y/N]: ", strings.ToUpper(string(c.Severity)), c.Description)
+	line, err := g.reader.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Println()
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// 校正在报告中的处理状态
+const (
+	CorrectionStateApplied  = "已应用"
+	CorrectionStateReported = "仅报告"
+	CorrectionStateDryRun   = "预演"
+)
+
+// state 校正的处理状态：已应用/仅报告/预演
+func (g *correctionGate) state(applied bool) string {
+	switch {
+	case !applied:
+		return CorrectionStateReported
+	case g.dryRun:
+		return CorrectionStateDryRun
+	}
+	return CorrectionStateApplied
+}
+
+// label 报告中使用的前缀，如 "[MAJOR][已应用]"
+func (g *correctionGate) label(c Correction, applied bool) string {
+	return fmt.Sprintf("[%s][%s]", strings.ToUpper(string(c.Severity)), g.state(applied))
+}
+
+// logSummary 输出各级别的应用/跳过统计
+func (g *correctionGate) logSummary() {
+	for _, sev := range []Severity{SeverityInfo, SeverityMinor, SeverityMajor} {
+		if g.applied[sev]+g.skipped[sev] == 0 {
+			continue
+		}
+		log.Printf("📋 校正统计 [%s] 策略=%s 应用=%d 跳过=%d", sev, g.policy[sev], g.applied[sev], g.skipped[sev])
+	}
+}
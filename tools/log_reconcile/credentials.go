@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"nofx/config"
+)
+
+// config.db 凭据静态加密
+//
+// 加密格式、密钥来源（NOFX_CREDENTIAL_KEY / NOFX_CREDENTIAL_KEY_CMD）与透明解密都在 config 包中实现，
+// 主程序与本工具读取交易所配置时都会解密，加密后的 config.db 可以继续供主程序使用。
+
+// encryptConfigCredentials 把 config.db 中明文保存的凭据加密（已加密的跳过），在一个事务中写回
+// dryRun 时只统计待加密的数量
+func encryptConfigCredentials(configDBPath string, dryRun bool) error {
+	cfgDB, err := config.OpenDatabase(configDBPath)
+	if err != nil {
+		return err
+	}
+	defer cfgDB.Close()
+	count, err := cfgDB.EncryptExchangeCredentials(dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		log.Printf("🔐 [预演] %s 待加密凭据 %d 项", configDBPath, count)
+		return nil
+	}
+	log.Printf("🔐 %s 已加密凭据 %d 项", configDBPath, count)
+	return nil
+}
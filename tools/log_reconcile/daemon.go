@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 常驻模式（-daemon）
+//
+// 在一个进程内按调度周期依次执行 scan-symbols → fetch-orders-db → reconcile，
+// 各阶段的最近一次运行状态通过 HTTP GET /status 以 JSON 输出，对账健康指标通过 GET /metrics 以 Prometheus 文本格式输出。
+// 调度支持 "@every 30m"、"@hourly"、"@daily" 与 5 段 cron 表达式（分 时 日 月 周）。
+
+// 常驻模式的阶段
+const (
+	PhaseScanSymbols = "scan-symbols"
+	PhaseFetchOrders = "fetch-orders-db"
+	PhaseReconcile   = "reconcile"
+)
+
+// daemonPhases 每个周期依次执行的阶段
+var daemonPhases = []string{PhaseScanSymbols, PhaseFetchOrders, PhaseReconcile}
+
+// schedule 调度：返回 t 之后的下一次运行时间
+type schedule interface {
+	next(t time.Time) time.Time
+}
+
+// everySchedule 固定间隔
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s everySchedule) next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// cronSchedule 5 段 cron（各字段为允许取值的位集）
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // 日/周字段为 *（两者都受限时按 cron 惯例取并集）
+}
+
+// cronMaxLookahead 查找下一次运行时间的最大范围（避免 2 月 30 日之类永不触发的表达式死循环）
+const cronMaxLookahead = 366 * 24 * time.Hour
+
+func (s cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronMaxLookahead)
+	for ; t.Before(limit); t = t.Add(time.Minute) {
+		if s.month&(1<<uint(t.Month())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.minute&(1<<uint(t.Minute())) == 0 {
+			continue
+		}
+		domOK := s.dom&(1<<uint(t.Day())) != 0
+		dowOK := s.dow&(1<<uint(t.Weekday())) != 0
+		var dayOK bool
+		switch {
+		case s.domAny && s.dowAny:
+			dayOK = true
+		case s.domAny:
+			dayOK = dowOK
+		case s.dowAny:
+			dayOK = domOK
+		default:
+			dayOK = domOK || dowOK
+		}
+		if dayOK {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseSchedule 解析调度表达式
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "@hourly":
+		spec = "0 * * * *"
+	case spec == "@daily" || spec == "@midnight":
+		spec = "0 0 * * *"
+	case strings.HasPrefix(spec, "@every "):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("无效的调度间隔 %q: %w", spec, err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("调度间隔不能小于 1 分钟: %s", d)
+		}
+		return everySchedule{interval: d}, nil
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("无效的调度表达式 %q（应为 @every <间隔>、@hourly、@daily 或 5 段 cron）", spec)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseCronField(f, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("无效的 cron 字段 %q: %w", f, err)
+		}
+		sets[i] = set
+	}
+	// 周字段 7 等同于 0（周日）
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: strings.HasPrefix(fields[2], "*"), dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField 解析单个 cron 字段（支持 *、数字、a-b 范围、逗号列表与 /步长）
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长 %q", stepStr)
+			}
+			step = n
+		}
+		start, end := lo, hi
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err1, err2 error
+			start, err1 = strconv.Atoi(a)
+			end, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("无效的范围 %q", rng)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("无效的取值 %q", rng)
+			}
+			start, end = n, n
+			if hasStep {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("取值超出范围 %d-%d", lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// PhaseStatus 单个阶段的运行状态
+type PhaseStatus struct {
+	Name         string    `json:"name"`
+	Running      bool      `json:"running"`
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+	LastStart    time.Time `json:"last_start,omitzero"`
+	LastEnd      time.Time `json:"last_end,omitzero"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastSuccess  time.Time `json:"last_success,omitzero"`
+}
+
+// DaemonStatus /status 返回的整体状态
+type DaemonStatus struct {
+	StartedAt time.Time     `json:"started_at"`
+	Schedule  string        `json:"schedule"`
+	Cycles    int           `json:"cycles"`
+	Running   bool          `json:"running"`
+	NextRun   time.Time     `json:"next_run,omitzero"`
+	Phases    []PhaseStatus `json:"phases"`
+}
+
+// daemonConfig 常驻模式参数（沿用单次 action 的对应参数）
+type daemonConfig struct {
+	schedule      string
+	statusAddr    string
+	logs          *logSource
+	configDBPath  string
+	userID        string
+	exchangeID    string
+	base          binanceEndpoint
+	okxPassphrase string
+	policy        CorrectionPolicy
+	tolerances    *matchTolerances
+	reportFormat  string
+	reportDir     string
+	synthOrphans  bool // 孤立订单补写为 decision_reconcile_* 文件
+}
+
+// daemon 常驻调度器
+type daemon struct {
+	cfg   daemonConfig
+	db    *sql.DB
+	pool  *fetchPool
+	sched schedule
+
+	mu     sync.Mutex
+	status DaemonStatus
+}
+
+func newDaemon(db *sql.DB, pool *fetchPool, cfg daemonConfig) (*daemon, error) {
+	sched, err := parseSchedule(cfg.schedule)
+	if err != nil {
+		return nil, err
+	}
+	// 常驻模式无法交互确认，approve 级别按仅报告处理
+	policy := make(CorrectionPolicy, len(cfg.policy))
+	for sev, mode := range cfg.policy {
+		if mode == PolicyApprove {
+			log.Printf("ℹ 常驻模式不支持交互确认，%s 级别的 approve 按 report 处理", sev)
+			mode = PolicyReport
+		}
+		policy[sev] = mode
+	}
+	cfg.policy = policy
+
+	metrics = newReconcileMetrics()
+	d := &daemon{cfg: cfg, db: db, pool: pool, sched: sched}
+	d.status = DaemonStatus{StartedAt: time.Now(), Schedule: cfg.schedule}
+	for _, name := range daemonPhases {
+		d.status.Phases = append(d.status.Phases, PhaseStatus{Name: name})
+	}
+	return d, nil
+}
+
+// run 立即执行一个周期，之后按调度循环，收到 SIGINT/SIGTERM 后在当前周期结束时退出
+func (d *daemon) run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var srv *http.Server
+	if d.cfg.statusAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/status", d.handleStatus)
+		mux.HandleFunc("/metrics", metrics.handle)
+		srv = &http.Server{Addr: d.cfg.statusAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			log.Printf("🌐 状态接口: http://%s/status，指标: http://%s/metrics", d.cfg.statusAddr, d.cfg.statusAddr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("⚠ 状态接口启动失败: %v", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+		}()
+	}
+
+	log.Printf("🕒 常驻模式启动，调度: %s", d.cfg.schedule)
+	for {
+		d.runCycle()
+		next := d.sched.next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("调度表达式 %q 在一年内没有下一次运行时间", d.cfg.schedule)
+		}
+		d.mu.Lock()
+		d.status.NextRun = next
+		d.mu.Unlock()
+		log.Printf("⏭ 下一次运行: %s", next.Format("2006-01-02 15:04:05"))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Printf("🛑 收到退出信号，常驻模式结束")
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// runCycle 依次执行各阶段（某阶段失败不影响后续阶段，对账使用已缓存的订单）
+func (d *daemon) runCycle() {
+	d.mu.Lock()
+	d.status.Running = true
+	d.status.Cycles++
+	cycle := d.status.Cycles
+	d.mu.Unlock()
+	log.Printf("▶ 第 %d 轮对账开始", cycle)
+	st := time.Now()
+
+	reports := make(map[string]*runReport) // 各阶段的运行汇总（用于指标）
+	failedPhase := false
+	for i, name := range daemonPhases {
+		d.updatePhase(i, func(p *PhaseStatus) {
+			p.Running = true
+			p.LastStart = time.Now()
+		})
+		start := time.Now()
+		err := d.runPhase(name, reports)
+		d.updatePhase(i, func(p *PhaseStatus) {
+			p.Running = false
+			p.Runs++
+			p.LastEnd = time.Now()
+			p.LastDuration = time.Since(start).Round(time.Millisecond).String()
+			p.LastError = ""
+			if err != nil {
+				p.Failures++
+				p.LastError = err.Error()
+			} else {
+				p.LastSuccess = p.LastEnd
+			}
+		})
+		if err != nil {
+			failedPhase = true
+			metrics.add(metricPhaseFailures, 1, "phase", name)
+			log.Printf("⚠ 阶段 %s 失败: %v", name, err)
+		}
+	}
+	if !failedPhase {
+		d.recordHealth(reports, st)
+	}
+	metrics.add(metricCycles, 1)
+
+	d.mu.Lock()
+	d.status.Running = false
+	d.mu.Unlock()
+	log.Printf("■ 第 %d 轮对账结束，用时 %v", cycle, time.Since(st).Round(time.Second))
+}
+
+// runPhase 执行单个阶段，拉单与对账阶段的汇总写入 reports
+func (d *daemon) runPhase(name string, reports map[string]*runReport) error {
+	switch name {
+	case PhaseScanSymbols:
+		return scanSymbols(d.db, d.cfg.logs)
+	case PhaseFetchOrders:
+		// 只收集拉取失败，不输出报告文件
+		rep, err := newRunReport(PhaseFetchOrders, ReportFormatTxt, "", nil)
+		if err != nil {
+			return err
+		}
+		reports[name] = rep
+		d.pool.report = rep
+		defer func() { d.pool.report = nil }()
+		return fetchOrdersFromConfigDB(d.db, d.cfg.configDBPath, d.cfg.userID, d.cfg.exchangeID, d.pool, d.cfg.base, d.cfg.okxPassphrase)
+	case PhaseReconcile:
+		rep, err := newRunReport(PhaseReconcile, d.cfg.reportFormat, d.cfg.reportDir, nil)
+		if err != nil {
+			return err
+		}
+		reports[name] = rep
+		defer metrics.observeReport(rep)
+		defer notifyMismatches(rep)
+		jr, err := beginJournal(d.db, PhaseReconcile)
+		if err != nil {
+			return err
+		}
+		defer jr.finish()
+		return reconcileLogs(d.db, d.cfg.logs, newCorrectionGate(d.cfg.policy), d.cfg.tolerances, d.cfg.synthOrphans, nil, rep, jr)
+	}
+	return fmt.Errorf("未知阶段: %s", name)
+}
+
+// recordHealth 本轮拉单与对账均无失败的交易员记为已完整对账（时间取本轮开始，即订单数据的新鲜度）
+func (d *daemon) recordHealth(reports map[string]*runReport, cycleStart time.Time) {
+	fetched, reconciled := reports[PhaseFetchOrders], reports[PhaseReconcile]
+	if reconciled == nil {
+		return
+	}
+	for traderID, s := range reconciled.traders {
+		if s.Failures > 0 {
+			continue
+		}
+		if fetched != nil {
+			if f, ok := fetched.traders[traderID]; ok && f.Failures > 0 {
+				continue
+			}
+		}
+		metrics.reconciled(traderID, cycleStart)
+	}
+}
+
+func (d *daemon) updatePhase(i int, fn func(p *PhaseStatus)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(&d.status.Phases[i])
+}
+
+// snapshot 返回状态副本
+func (d *daemon) snapshot() DaemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.status
+	s.Phases = append([]PhaseStatus(nil), d.status.Phases...)
+	return s
+}
+
+func (d *daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(d.snapshot())
+}
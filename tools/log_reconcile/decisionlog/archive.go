@@ -0,0 +1,341 @@
+package decisionlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// 归档
+//
+// 旧记录按记录时间（UTC）的月份压缩为 <trader_id>/archive/<YYYY-MM>.jsonl.gz，每行一条 {"name","time","data"}，
+// data 为原始 JSON 文本（逐字节保留，回滚日志中的内容哈希仍然有效）；archive/index.json 记录每个归档的记录名与时间范围。
+// FileStore 读取时合并目录中的文件与归档，同名时以文件为准（归档后被校正的记录会写回为单独的文件）。
+// 归档写入并回读校验、索引更新之后才删除原文件；中途失败只会留下重复的记录（以文件为准），再次归档时合并。
+
+// ArchiveDir 交易员目录下的归档子目录
+const ArchiveDir = "archive"
+
+const (
+	archiveIndexName    = "index.json"
+	archiveIndexVersion = 1
+	archiveMonthLayout  = "2006-01"
+)
+
+// archiveEntry 归档中的一条记录
+type archiveEntry struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Data string    `json:"data"`
+}
+
+// archiveIndex 交易员的归档索引
+type archiveIndex struct {
+	Version  int           `json:"version"`
+	Archives []archiveInfo `json:"archives"`
+}
+
+// archiveInfo 单个月份归档
+type archiveInfo struct {
+	File    string    `json:"file"`
+	Month   string    `json:"month"`
+	Records int       `json:"records"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"` // 最晚一条记录的时间（含）
+	SHA256  string    `json:"sha256"`
+	Names   []string  `json:"names"` // 按名称排序
+}
+
+// overlaps 归档时间范围是否与 r 相交
+func (a archiveInfo) overlaps(r TimeRange) bool {
+	return (r.To.IsZero() || a.From.Before(r.To)) && (r.From.IsZero() || !a.To.Before(r.From))
+}
+
+// has 归档是否包含记录
+func (a archiveInfo) has(name string) bool {
+	_, ok := slices.BinarySearch(a.Names, name)
+	return ok
+}
+
+// CompactStats 单个交易员的归档结果
+type CompactStats struct {
+	Files    int      // 归档的文件数
+	Bytes    int64    // 归档文件的原始大小
+	Archives []string // 写入（或预演时拟写入）的归档文件
+	Skipped  int      // 时间未知或不是合法 JSON 而保留的文件
+}
+
+// archiveDir 交易员的归档目录
+func (s *FileStore) archiveDir(traderID string) string {
+	return filepath.Join(s.Dir(traderID), ArchiveDir)
+}
+
+// loadIndex 读取归档索引（没有归档时为空索引）
+func (s *FileStore) loadIndex(traderID string) (*archiveIndex, error) {
+	data, err := os.ReadFile(filepath.Join(s.archiveDir(traderID), archiveIndexName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &archiveIndex{Version: archiveIndexVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var idx archiveIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("解析归档索引失败: %w", err)
+	}
+	if idx.Version > archiveIndexVersion {
+		return nil, fmt.Errorf("归档索引版本 v%d 高于当前支持的 v%d", idx.Version, archiveIndexVersion)
+	}
+	return &idx, nil
+}
+
+// readArchive 读取并解压一个归档
+func (s *FileStore) readArchive(traderID string, info archiveInfo) ([]archiveEntry, error) {
+	f, err := os.Open(filepath.Join(s.archiveDir(traderID), info.File))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := decodeArchive(f)
+	if err != nil {
+		return nil, fmt.Errorf("读取归档 %s 失败: %w", info.File, err)
+	}
+	return entries, nil
+}
+
+// decodeArchive 解码 gzip 压缩的 JSONL
+func decodeArchive(r io.Reader) ([]archiveEntry, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(zr)
+	var entries []archiveEntry
+	for {
+		var e archiveEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// encodeArchive 编码为 gzip 压缩的 JSONL（记录按名称排序）
+func encodeArchive(entries []archiveEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	enc.SetEscapeHTML(false)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// listArchived 归档中时间范围内、且没有同名文件的记录
+func (s *FileStore) listArchived(traderID string, r TimeRange, loose map[string]bool) ([]Record, error) {
+	idx, err := s.loadIndex(traderID)
+	if err != nil {
+		return nil, err
+	}
+	var recs []Record
+	for _, info := range idx.Archives {
+		if !info.overlaps(r) {
+			continue
+		}
+		entries, err := s.readArchive(traderID, info)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if loose[e.Name] || !r.Contains(e.Time) {
+				continue
+			}
+			recs = append(recs, Record{TraderID: traderID, Name: e.Name, Time: e.Time, Data: []byte(e.Data)})
+		}
+	}
+	return recs, nil
+}
+
+// getArchived 从归档读取一条记录
+func (s *FileStore) getArchived(traderID, name string) ([]byte, bool, error) {
+	idx, err := s.loadIndex(traderID)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, info := range idx.Archives {
+		if !info.has(name) {
+			continue
+		}
+		entries, err := s.readArchive(traderID, info)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, e := range entries {
+			if e.Name == name {
+				return []byte(e.Data), true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+// Compact 把记录时间早于 before 的文件按月归档并删除原文件；dryRun 时只统计不写入
+// 时间未知或内容不是合法 JSON 的文件保留原样（由 validate-logs 报告）
+func (s *FileStore) Compact(traderID string, before time.Time, dryRun bool) (CompactStats, error) {
+	var st CompactStats
+	paths, err := Files(s.Dir(traderID))
+	if err != nil {
+		return st, err
+	}
+	type looseFile struct {
+		path  string
+		entry archiveEntry
+	}
+	months := make(map[string][]looseFile)
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return st, err
+		}
+		t := RecordTime(data)
+		if t.IsZero() || !json.Valid(data) {
+			st.Skipped++
+			continue
+		}
+		if !t.Before(before) {
+			continue
+		}
+		month := t.UTC().Format(archiveMonthLayout)
+		months[month] = append(months[month], looseFile{path: p, entry: archiveEntry{Name: filepath.Base(p), Time: t, Data: string(data)}})
+		st.Bytes += int64(len(data))
+	}
+	if len(months) == 0 {
+		return st, nil
+	}
+	idx, err := s.loadIndex(traderID)
+	if err != nil {
+		return st, err
+	}
+	keys := make([]string, 0, len(months))
+	for m := range months {
+		keys = append(keys, m)
+	}
+	sort.Strings(keys)
+	for _, month := range keys {
+		files := months[month]
+		info := archiveInfo{File: month + ".jsonl.gz", Month: month}
+		st.Archives = append(st.Archives, filepath.Join(s.archiveDir(traderID), info.File))
+		st.Files += len(files)
+		if dryRun {
+			continue
+		}
+		// 与已有归档合并，同名以文件为准
+		merged := make(map[string]archiveEntry)
+		pos := slices.IndexFunc(idx.Archives, func(a archiveInfo) bool { return a.Month == month })
+		if pos >= 0 {
+			existing, err := s.readArchive(traderID, idx.Archives[pos])
+			if err != nil {
+				return st, err
+			}
+			for _, e := range existing {
+				merged[e.Name] = e
+			}
+		}
+		for _, f := range files {
+			merged[f.entry.Name] = f.entry
+		}
+		entries := make([]archiveEntry, 0, len(merged))
+		for _, e := range merged {
+			entries = append(entries, e)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		if err := s.writeArchive(traderID, &info, entries); err != nil {
+			return st, err
+		}
+		if pos >= 0 {
+			idx.Archives[pos] = info
+		} else {
+			idx.Archives = append(idx.Archives, info)
+			sort.Slice(idx.Archives, func(i, j int) bool { return idx.Archives[i].Month < idx.Archives[j].Month })
+		}
+		if err := s.writeIndex(traderID, idx); err != nil {
+			return st, err
+		}
+		for _, f := range files {
+			if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return st, fmt.Errorf("删除已归档文件失败: %w", err)
+			}
+		}
+		syncDir(s.Dir(traderID))
+	}
+	return st, nil
+}
+
+// writeArchive 写入归档并回读校验，填充 info 的统计字段
+func (s *FileStore) writeArchive(traderID string, info *archiveInfo, entries []archiveEntry) error {
+	data, err := encodeArchive(entries)
+	if err != nil {
+		return err
+	}
+	check, err := decodeArchive(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("归档 %s 校验失败: %w", info.File, err)
+	}
+	if len(check) != len(entries) {
+		return fmt.Errorf("归档 %s 校验失败: 写入 %d 条，读回 %d 条", info.File, len(entries), len(check))
+	}
+	dir := s.archiveDir(traderID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeAtomic(filepath.Join(dir, info.File), data, true); err != nil {
+		return fmt.Errorf("写入归档 %s 失败: %w", info.File, err)
+	}
+	sum := sha256.Sum256(data)
+	info.SHA256 = hex.EncodeToString(sum[:])
+	info.Records = len(entries)
+	info.Names = make([]string, len(entries))
+	for i, e := range entries {
+		info.Names[i] = e.Name
+		if info.From.IsZero() || e.Time.Before(info.From) {
+			info.From = e.Time
+		}
+		if e.Time.After(info.To) {
+			info.To = e.Time
+		}
+	}
+	return nil
+}
+
+// writeIndex 原子写入归档索引
+func (s *FileStore) writeIndex(traderID string, idx *archiveIndex) error {
+	idx.Version = archiveIndexVersion
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeAtomic(filepath.Join(s.archiveDir(traderID), archiveIndexName), data, true); err != nil {
+		return fmt.Errorf("写入归档索引失败: %w", err)
+	}
+	return nil
+}
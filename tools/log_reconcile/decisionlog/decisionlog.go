@@ -0,0 +1,180 @@
+// Package decisionlog 决策日志的读写
+//
+// 决策日志按交易员分目录保存（<root>/<trader_id>/*.json），每个文件是一条决策记录；
+// 也可以存放在 SQLite 或对象存储中（见 Store）。
+// 写入先写同目录下的临时文件（.<name>.tmp，不以 .json 结尾，读取方不会读到）并 fsync，再原子重命名为目标文件，
+// 最后 fsync 目录：进程崩溃或断电只会留下临时文件，不会出现写了一半的 JSON。
+// 新建文件不覆盖已有文件（主程序可能同时在同一目录写日志）；改写已有文件时保留 decisions 以外的所有字段。
+// 新建的记录带顶层 schema_version（见 SchemaVersion），与 validate-logs 的结构版本一致。
+package decisionlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion 新建记录写入的结构版本
+const SchemaVersion = 2
+
+// schemaVersionField 结构版本的顶层字段名
+const schemaVersionField = "schema_version"
+
+// fileMode 日志文件权限
+const fileMode = 0644
+
+// Reader 决策日志根目录的读取器
+type Reader struct {
+	root string
+}
+
+// NewReader 读取 root 下各交易员的决策日志
+func NewReader(root string) *Reader {
+	return &Reader{root: root}
+}
+
+// Traders 按名称排序的交易员ID（root 下的子目录）
+func (r *Reader) Traders() ([]string, error) {
+	entries, err := os.ReadDir(r.root)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, nil
+}
+
+// Dir 交易员的日志目录
+func (r *Reader) Dir(traderID string) string {
+	return filepath.Join(r.root, traderID)
+}
+
+// Files 目录下按文件名排序的 *.json 日志路径（不含写入中的临时文件）
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Writer 单个交易员目录的写入器
+type Writer struct {
+	dir string
+}
+
+// NewWriter 打开交易员日志目录（不存在时创建）
+func NewWriter(dir string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	return &Writer{dir: dir}, nil
+}
+
+// Path 目录下文件的完整路径
+func (w *Writer) Path(name string) string {
+	return filepath.Join(w.dir, name)
+}
+
+// Encode 序列化新记录并写入 schema_version（键按字母排序，两空格缩进）
+func Encode(record any) ([]byte, error) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := decodeObject(b)
+	if !ok {
+		return nil, fmt.Errorf("决策记录必须是 JSON 对象")
+	}
+	obj[schemaVersionField] = SchemaVersion
+	return json.MarshalIndent(obj, "", "  ")
+}
+
+// Render 保留原内容中 decisions 以外的字段，只替换 decisions（原内容不是对象时只输出 decisions）
+// 改动前后都经过 Render 时序列化方式一致，diff 只包含真正变化的字段
+func Render(data []byte, decisions any) ([]byte, error) {
+	obj, ok := decodeObject(data)
+	if !ok {
+		obj = make(map[string]any)
+	}
+	obj["decisions"] = decisions
+	return json.MarshalIndent(obj, "", "  ")
+}
+
+// decodeObject 解析为对象，数字保持原样（大整数ID不丢精度）
+func decodeObject(data []byte) (map[string]any, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]any
+	if dec.Decode(&obj) != nil || obj == nil {
+		return nil, false
+	}
+	return obj, true
+}
+
+// writeAtomic 写临时文件并 fsync 后重命名为 path；overwrite 为 false 时目标已存在则返回 fs.ErrExist
+func writeAtomic(path string, data []byte, overwrite bool) error {
+	dir, name := filepath.Split(path)
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // 成功重命名后为空操作
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, fileMode); err != nil {
+		return err
+	}
+	if overwrite {
+		err = os.Rename(tmpPath, path)
+	} else {
+		// 硬链接在目标已存在时失败，不会覆盖其他进程刚写入的文件
+		if err = os.Link(tmpPath, path); err != nil && errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%s: %w", path, fs.ErrExist)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir 持久化目录项（部分平台不支持对目录 fsync，忽略错误）
+func syncDir(dir string) {
+	if dir == "" {
+		dir = "."
+	}
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,164 @@
+package decisionlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 决策记录存储
+//
+// Store 屏蔽记录的存放方式：按交易员分目录的 JSON 文件（默认，与主程序的日志目录一致）、单个 SQLite 数据库、
+// S3 兼容的对象存储。记录以 (交易员ID, 记录名) 定位，记录名沿用文件名（如 decision_20251120_103000_cycle12.json），
+// 只有以 .json 结尾的记录参与列举（.bak 备份等不会被读到）。
+// 记录时间取顶层 timestamp，缺失时（如 decision_reconcile_* 补全记录）取动作中最早的时间；
+// SQLite 按时间建索引，按时间范围查询不必读取全部记录。
+
+// Record 一条决策记录
+type Record struct {
+	TraderID string
+	Name     string
+	Time     time.Time // 记录时间（无法解析时为零值）
+	Data     []byte    // 原始 JSON
+}
+
+// TimeRange 记录时间范围 [From, To)，零值表示不限
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Contains 时间是否在范围内（时间未知的记录总是包含在内）
+func (r TimeRange) Contains(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || t.Before(r.To))
+}
+
+// IsZero 是否不限时间
+func (r TimeRange) IsZero() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+// Store 决策记录存储
+type Store interface {
+	// Traders 按名称排序的交易员ID
+	Traders() ([]string, error)
+	// List 交易员在时间范围内的记录，按记录名排序
+	List(traderID string, r TimeRange) ([]Record, error)
+	// Get 读取一条记录，不存在时返回 fs.ErrNotExist
+	Get(traderID, name string) ([]byte, error)
+	// Create 新建记录，已存在时返回 fs.ErrExist
+	Create(rec Record) error
+	// Put 写入记录（覆盖已有记录）
+	Put(rec Record) error
+	// Delete 删除记录（不存在时不报错）
+	Delete(traderID, name string) error
+	// Location 记录的位置描述（文件存储为文件路径），用于日志、预演与回滚日志
+	Location(traderID, name string) string
+	// Locate 由位置描述解析出交易员ID与记录名，不属于该存储时 ok 为 false
+	Locate(location string) (traderID, name string, ok bool)
+	Close() error
+}
+
+// Open 按地址打开存储：
+//
+//	decision_logs                          按交易员分目录的 JSON 文件
+//	sqlite:decision_logs.db                SQLite 数据库（不存在时创建）
+//	s3://bucket/prefix?region=..&endpoint=..  S3 兼容的对象存储（凭据取自 AWS_ACCESS_KEY_ID 等环境变量）
+func Open(uri string) (Store, error) {
+	switch {
+	case uri == "":
+		return nil, fmt.Errorf("决策记录存储地址为空")
+	case strings.HasPrefix(uri, "sqlite:"):
+		return OpenSQLite(strings.TrimPrefix(uri, "sqlite:"))
+	case strings.HasPrefix(uri, "s3://"):
+		return OpenS3(uri)
+	}
+	return NewFileStore(uri), nil
+}
+
+// Copy 把 src 中时间范围内的全部记录写入 dst（覆盖同名记录），返回复制的记录数
+func Copy(dst, src Store, r TimeRange) (int, error) {
+	traders, err := src.Traders()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, traderID := range traders {
+		recs, err := src.List(traderID, r)
+		if err != nil {
+			return n, fmt.Errorf("读取 %s 失败: %w", traderID, err)
+		}
+		for _, rec := range recs {
+			if err := dst.Put(rec); err != nil {
+				return n, fmt.Errorf("写入 %s 失败: %w", src.Location(traderID, rec.Name), err)
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// RecordTime 解析记录时间：顶层 timestamp，缺失或为零值时取动作中最早的时间
+func RecordTime(data []byte) time.Time {
+	var rec struct {
+		Timestamp time.Time `json:"timestamp"`
+		Decisions []struct {
+			Timestamp time.Time `json:"timestamp"`
+		} `json:"decisions"`
+	}
+	if json.Unmarshal(data, &rec) != nil {
+		return time.Time{}
+	}
+	if !rec.Timestamp.IsZero() {
+		return rec.Timestamp
+	}
+	var earliest time.Time
+	for _, d := range rec.Decisions {
+		if !d.Timestamp.IsZero() && (earliest.IsZero() || d.Timestamp.Before(earliest)) {
+			earliest = d.Timestamp
+		}
+	}
+	return earliest
+}
+
+// nameTimePattern 主程序日志文件名中的时间：decision_YYYYMMDD_HHMMSS_cycleN.json
+var nameTimePattern = regexp.MustCompile(`^decision_(\d{8}_\d{6})_cycle\d+\.json$`)
+
+// nameTimeSlack 文件名按主程序本地时区格式化，按名称预筛选时放宽的时间
+const nameTimeSlack = 26 * time.Hour
+
+// maybeInRange 按记录名预筛选（不读取内容）；名称中没有时间时返回 true
+func maybeInRange(name string, r TimeRange) bool {
+	if r.IsZero() {
+		return true
+	}
+	m := nameTimePattern.FindStringSubmatch(name)
+	if m == nil {
+		return true
+	}
+	t, err := time.Parse("20060102_150405", m[1])
+	if err != nil {
+		return true
+	}
+	return (r.From.IsZero() || t.After(r.From.Add(-nameTimeSlack))) && (r.To.IsZero() || t.Before(r.To.Add(nameTimeSlack)))
+}
+
+// isRecordName 参与列举的记录名
+func isRecordName(name string) bool {
+	return strings.HasSuffix(name, ".json") && !strings.HasPrefix(name, ".")
+}
+
+// validKey 交易员ID与记录名不能包含路径分隔符
+func validKey(traderID, name string) error {
+	for _, s := range []string{traderID, name} {
+		if s == "" || s == "." || s == ".." || strings.ContainsAny(s, `/\`) {
+			return fmt.Errorf("无效的记录位置: %q/%q", traderID, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package decisionlog
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileStore 按交易员分目录的 JSON 文件（<root>/<trader_id>/*.json），以及按月压缩的归档（见 Compact）
+type FileStore struct {
+	reader *Reader
+	root   string
+}
+
+// NewFileStore 以 root 为决策日志根目录
+func NewFileStore(root string) *FileStore {
+	return &FileStore{reader: NewReader(root), root: root}
+}
+
+// Dir 交易员的日志目录
+func (s *FileStore) Dir(traderID string) string {
+	return s.reader.Dir(traderID)
+}
+
+func (s *FileStore) Traders() ([]string, error) {
+	return s.reader.Traders()
+}
+
+// List 时间范围内的记录（含归档）；文件名带时间的先按名称预筛选，其余需读取内容判断
+func (s *FileStore) List(traderID string, r TimeRange) ([]Record, error) {
+	paths, err := Files(s.Dir(traderID))
+	if err != nil {
+		return nil, err
+	}
+	loose := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		loose[filepath.Base(p)] = true
+	}
+	recs, err := s.listArchived(traderID, r, loose)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		name := filepath.Base(p)
+		if !maybeInRange(name, r) {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		t := RecordTime(data)
+		if !r.Contains(t) {
+			continue
+		}
+		recs = append(recs, Record{TraderID: traderID, Name: name, Time: t, Data: data})
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Name < recs[j].Name })
+	return recs, nil
+}
+
+func (s *FileStore) Get(traderID, name string) ([]byte, error) {
+	if err := validKey(traderID, name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.Location(traderID, name))
+	if !errors.Is(err, fs.ErrNotExist) {
+		return data, err
+	}
+	archived, ok, aerr := s.getArchived(traderID, name)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if !ok {
+		return nil, err
+	}
+	return archived, nil
+}
+
+func (s *FileStore) Create(rec Record) error {
+	w, err := s.writer(rec)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(w.Path(rec.Name), rec.Data, false)
+}
+
+func (s *FileStore) Put(rec Record) error {
+	w, err := s.writer(rec)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(w.Path(rec.Name), rec.Data, true)
+}
+
+// writer 校验位置并打开交易员目录
+func (s *FileStore) writer(rec Record) (*Writer, error) {
+	if err := validKey(rec.TraderID, rec.Name); err != nil {
+		return nil, err
+	}
+	return NewWriter(s.Dir(rec.TraderID))
+}
+
+func (s *FileStore) Delete(traderID, name string) error {
+	if err := validKey(traderID, name); err != nil {
+		return err
+	}
+	if err := os.Remove(s.Location(traderID, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	// 归档只在重新归档时改写，已归档的记录不能单独删除
+	if _, ok, err := s.getArchived(traderID, name); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("%s 已归档，不能删除", s.Location(traderID, name))
+	}
+	return nil
+}
+
+func (s *FileStore) Location(traderID, name string) string {
+	return filepath.Join(s.root, traderID, name)
+}
+
+// Locate 解析 <root>/<trader_id>/<name> 形式的文件路径（相对路径按当前目录解析）
+func (s *FileStore) Locate(location string) (string, string, bool) {
+	root, err1 := filepath.Abs(s.root)
+	path, err2 := filepath.Abs(location)
+	if err1 != nil || err2 != nil {
+		return "", "", false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", "", false
+	}
+	traderID, name, ok := strings.Cut(filepath.ToSlash(rel), "/")
+	if !ok || validKey(traderID, name) != nil {
+		return "", "", false
+	}
+	return traderID, name, true
+}
+
+func (s *FileStore) Close() error { return nil }
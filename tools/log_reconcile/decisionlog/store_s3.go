@@ -0,0 +1,347 @@
+package decisionlog
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Timeout 单个对象存储请求的超时
+const s3Timeout = 30 * time.Second
+
+// S3Store S3 兼容对象存储中的决策记录（对象键 <prefix>/<trader_id>/<name>）
+// 请求按 AWS Signature V4 签名；指定 endpoint 时（MinIO 等）使用 path-style 地址
+type S3Store struct {
+	bucket    string
+	prefix    string // 不含首尾斜杠
+	region    string
+	endpoint  *url.URL // path-style 时为服务地址，否则为 https://<bucket>.s3.<region>.amazonaws.com
+	pathStyle bool
+	accessKey string
+	secretKey string
+	token     string
+	client    *http.Client
+}
+
+// OpenS3 解析 s3://bucket/prefix?region=..&endpoint=..，凭据取自 AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN
+func OpenS3(uri string) (*S3Store, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("无效的 S3 地址: %s（应为 s3://bucket/prefix）", uri)
+	}
+	q := u.Query()
+	s := &S3Store{
+		bucket:    u.Host,
+		prefix:    strings.Trim(u.Path, "/"),
+		region:    q.Get("region"),
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		client:    &http.Client{Timeout: s3Timeout},
+	}
+	if s.region == "" {
+		s.region = os.Getenv("AWS_REGION")
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("S3 存储需要环境变量 AWS_ACCESS_KEY_ID 与 AWS_SECRET_ACCESS_KEY")
+	}
+	if ep := q.Get("endpoint"); ep != "" {
+		if s.endpoint, err = url.Parse(ep); err != nil || s.endpoint.Host == "" {
+			return nil, fmt.Errorf("无效的 S3 endpoint: %s", ep)
+		}
+		s.pathStyle = true
+	} else {
+		s.endpoint = &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", s.bucket, s.region)}
+	}
+	return s, nil
+}
+
+// key 对象键
+func (s *S3Store) key(parts ...string) string {
+	if s.prefix != "" {
+		parts = append([]string{s.prefix}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+// listResult ListObjectsV2 响应
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list 列举前缀下的对象（delimiter 非空时同时返回下一级“目录”）
+func (s *S3Store) list(prefix, delimiter string) (keys, prefixes []string, err error) {
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			q.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		body, err := s.do(http.MethodGet, "", q, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		var res listResult
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return nil, nil, fmt.Errorf("解析对象列表失败: %w", err)
+		}
+		for _, c := range res.Contents {
+			keys = append(keys, c.Key)
+		}
+		for _, p := range res.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return keys, prefixes, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+func (s *S3Store) Traders() ([]string, error) {
+	root := s.key("")
+	_, prefixes, err := s.list(root, "/")
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, p := range prefixes {
+		if id := strings.Trim(strings.TrimPrefix(p, root), "/"); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// List 列举交易员的对象，按名称预筛选后逐个读取（对象存储没有时间索引）
+func (s *S3Store) List(traderID string, r TimeRange) ([]Record, error) {
+	dir := s.key(traderID, "")
+	keys, _, err := s.list(dir, "/")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	var recs []Record
+	for _, k := range keys {
+		name := strings.TrimPrefix(k, dir)
+		if !isRecordName(name) || !maybeInRange(name, r) {
+			continue
+		}
+		data, err := s.Get(traderID, name)
+		if err != nil {
+			return nil, err
+		}
+		t := RecordTime(data)
+		if !r.Contains(t) {
+			continue
+		}
+		recs = append(recs, Record{TraderID: traderID, Name: name, Time: t, Data: data})
+	}
+	return recs, nil
+}
+
+func (s *S3Store) Get(traderID, name string) ([]byte, error) {
+	if err := validKey(traderID, name); err != nil {
+		return nil, err
+	}
+	return s.do(http.MethodGet, s.key(traderID, name), nil, nil, nil)
+}
+
+// Create 使用条件写入（If-None-Match: *），对象已存在时服务端返回 412
+func (s *S3Store) Create(rec Record) error {
+	if err := validKey(rec.TraderID, rec.Name); err != nil {
+		return err
+	}
+	_, err := s.do(http.MethodPut, s.key(rec.TraderID, rec.Name), nil, rec.Data, http.Header{"If-None-Match": {"*"}})
+	return err
+}
+
+func (s *S3Store) Put(rec Record) error {
+	if err := validKey(rec.TraderID, rec.Name); err != nil {
+		return err
+	}
+	_, err := s.do(http.MethodPut, s.key(rec.TraderID, rec.Name), nil, rec.Data, nil)
+	return err
+}
+
+func (s *S3Store) Delete(traderID, name string) error {
+	if err := validKey(traderID, name); err != nil {
+		return err
+	}
+	_, err := s.do(http.MethodDelete, s.key(traderID, name), nil, nil, nil)
+	return err
+}
+
+// Location s3://<bucket>/<key>
+func (s *S3Store) Location(traderID, name string) string {
+	return "s3://" + s.bucket + "/" + s.key(traderID, name)
+}
+
+func (s *S3Store) Locate(location string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(location, "s3://"+s.bucket+"/"+s.key(""))
+	if !ok {
+		return "", "", false
+	}
+	traderID, name, ok := strings.Cut(rest, "/")
+	if !ok || validKey(traderID, name) != nil {
+		return "", "", false
+	}
+	return traderID, name, true
+}
+
+func (s *S3Store) Close() error { return nil }
+
+// do 发送签名请求；404 映射为 fs.ErrNotExist，412 映射为 fs.ErrExist
+func (s *S3Store) do(method, key string, query url.Values, body []byte, header http.Header) ([]byte, error) {
+	p := "/" + key
+	if s.pathStyle {
+		p = path.Join("/", s.endpoint.Path, s.bucket) + "/" + key
+	}
+	// 请求地址与签名使用同一份编码结果
+	encPath := uriEncode(p, false)
+	rawURL := s.endpoint.Scheme + "://" + s.endpoint.Host + encPath
+	if len(query) > 0 {
+		rawURL += "?" + canonicalQuery(query)
+	}
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, encPath, body, time.Now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound && key != "":
+		return nil, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return nil, fmt.Errorf("%s: %w", key, fs.ErrExist)
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("S3 %s %s 返回 %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+// sign AWS Signature V4（服务名 s3，签名载荷的 SHA-256）
+func (s *S3Store) sign(req *http.Request, encPath string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256Hex(body)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payload)
+	if s.token != "" {
+		req.Header.Set("x-amz-security-token", s.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		encPath,
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKey, scope, signed, sig))
+}
+
+// canonicalQuery 按键排序并按 SigV4 规则编码的查询串（同时用作请求的查询串）
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode SigV4 的 URI 编码：只保留 A-Za-z0-9-._~（路径中保留 /）
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
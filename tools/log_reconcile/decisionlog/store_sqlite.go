@@ -0,0 +1,171 @@
+package decisionlog
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema 记录表：record_time 为记录时间毫秒（未知为 0），按 (交易员, 时间) 建索引
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS decision_records(
+	trader_id TEXT,
+	name TEXT,
+	record_time INTEGER,
+	data BLOB,
+	updated_at INTEGER,
+	PRIMARY KEY(trader_id, name)
+);
+CREATE INDEX IF NOT EXISTS idx_decision_records_time ON decision_records(trader_id, record_time);`
+
+// SQLiteStore 单个 SQLite 数据库中的决策记录
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// OpenSQLite 打开（必要时创建）记录数据库
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("SQLite 存储缺少数据库路径")
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开 %s 失败: %w", path, err)
+	}
+	_, _ = db.Exec("PRAGMA journal_mode=WAL")
+	_, _ = db.Exec("PRAGMA busy_timeout=5000")
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化 %s 失败: %w", path, err)
+	}
+	return &SQLiteStore{db: db, path: path}, nil
+}
+
+func (s *SQLiteStore) Traders() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT trader_id FROM decision_records ORDER BY trader_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// List 按时间索引查询；时间未知（record_time = 0）的记录总是返回
+func (s *SQLiteStore) List(traderID string, r TimeRange) ([]Record, error) {
+	query := `SELECT name, record_time, data FROM decision_records WHERE trader_id = ? AND name LIKE '%.json'`
+	args := []any{traderID}
+	var conds []string
+	if !r.From.IsZero() {
+		conds = append(conds, "record_time >= ?")
+		args = append(args, r.From.UnixMilli())
+	}
+	if !r.To.IsZero() {
+		conds = append(conds, "record_time < ?")
+		args = append(args, r.To.UnixMilli())
+	}
+	if len(conds) > 0 {
+		query += " AND (record_time = 0 OR (" + strings.Join(conds, " AND ") + "))"
+	}
+	rows, err := s.db.Query(query+" ORDER BY name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recs []Record
+	for rows.Next() {
+		rec := Record{TraderID: traderID}
+		var ms int64
+		if err := rows.Scan(&rec.Name, &ms, &rec.Data); err != nil {
+			return nil, err
+		}
+		if ms != 0 {
+			rec.Time = time.UnixMilli(ms).UTC()
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+func (s *SQLiteStore) Get(traderID, name string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM decision_records WHERE trader_id = ? AND name = ?`, traderID, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", s.Location(traderID, name), fs.ErrNotExist)
+	}
+	return data, err
+}
+
+func (s *SQLiteStore) Create(rec Record) error {
+	if err := validKey(rec.TraderID, rec.Name); err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`INSERT INTO decision_records(trader_id, name, record_time, data, updated_at) VALUES(?,?,?,?,?)
+		ON CONFLICT(trader_id, name) DO NOTHING`, rec.TraderID, rec.Name, recordMillis(rec), rec.Data, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s: %w", s.Location(rec.TraderID, rec.Name), fs.ErrExist)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Put(rec Record) error {
+	if err := validKey(rec.TraderID, rec.Name); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO decision_records(trader_id, name, record_time, data, updated_at) VALUES(?,?,?,?,?)`,
+		rec.TraderID, rec.Name, recordMillis(rec), rec.Data, time.Now().UnixMilli())
+	return err
+}
+
+func (s *SQLiteStore) Delete(traderID, name string) error {
+	_, err := s.db.Exec(`DELETE FROM decision_records WHERE trader_id = ? AND name = ?`, traderID, name)
+	return err
+}
+
+// Location sqlite:<数据库>/<trader_id>/<name>
+func (s *SQLiteStore) Location(traderID, name string) string {
+	return "sqlite:" + s.path + "/" + traderID + "/" + name
+}
+
+func (s *SQLiteStore) Locate(location string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(location, "sqlite:"+s.path+"/")
+	if !ok {
+		return "", "", false
+	}
+	traderID, name, ok := strings.Cut(rest, "/")
+	if !ok || validKey(traderID, name) != nil {
+		return "", "", false
+	}
+	return traderID, name, true
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// recordMillis 记录时间毫秒：未设置时从内容解析，仍未知为 0
+func recordMillis(rec Record) int64 {
+	t := rec.Time
+	if t.IsZero() {
+		t = RecordTime(rec.Data)
+	}
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// 预演模式（-dry_run）
+//
+// reconcile / partial-close-reconcile 照常计算全部校正，但不重命名、不改写、不新建任何文件：
+//   - diff 格式：每个将被修改或新建的文件输出一段 unified diff；
+//   - json 格式：结束时输出 JSON 变更列表（修改的决策字段、新建的文件内容）。
+
+const (
+	DryRunFormatDiff = "diff"
+	DryRunFormatJSON = "json"
+)
+
+// diffContext unified diff 的上下文行数
+const diffContext = 3
+
+// FieldChange 决策动作的单个字段变更
+type FieldChange struct {
+	Index int    `json:"index"` // decisions 数组下标
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// FileChange 一个文件的拟执行变更
+type FileChange struct {
+	File    string        `json:"file"`
+	Op      string        `json:"op"`                // modify | create
+	Changes []FieldChange `json:"changes,omitempty"` // modify：决策字段变更
+	Content string        `json:"content,omitempty"` // create：新文件内容
+}
+
+// dryRun 收集并输出拟执行的变更（nil 表示正常执行）
+type dryRun struct {
+	format  string
+	out     io.Writer
+	changes []FileChange
+}
+
+func newDryRun(format string) (*dryRun, error) {
+	switch format {
+	case "", DryRunFormatDiff:
+		format = DryRunFormatDiff
+	case DryRunFormatJSON:
+	default:
+		return nil, fmt.Errorf("未知预演输出格式: %s（可选 diff|json）", format)
+	}
+	return &dryRun{format: format, out: os.Stdout}, nil
+}
+
+// modify 记录对已有决策文件的修改
+func (d *dryRun) modify(path string, before, after []byte, oldActs, newActs []DecisionAction) {
+	if d.format == DryRunFormatDiff {
+		fmt.Fprint(d.out, unifiedDiff(path, path, string(before), string(after)))
+		return
+	}
+	d.changes = append(d.changes, FileChange{File: path, Op: "modify", Changes: diffActions(oldActs, newActs)})
+}
+
+// create 记录将新建的文件
+func (d *dryRun) create(path string, content []byte) {
+	if d.format == DryRunFormatDiff {
+		fmt.Fprint(d.out, unifiedDiff("/dev/null", path, "", string(content)))
+		return
+	}
+	d.changes = append(d.changes, FileChange{File: path, Op: "create", Content: string(content)})
+}
+
+// flush 输出 JSON 变更列表（diff 格式已实时输出）
+func (d *dryRun) flush() error {
+	if d.format != DryRunFormatJSON {
+		return nil
+	}
+	changes := d.changes
+	if changes == nil {
+		changes = []FileChange{}
+	}
+	b, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化变更列表失败: %w", err)
+	}
+	_, err = fmt.Fprintln(d.out, string(b))
+	return err
+}
+
+// diffActions 比较校正前后的决策动作，列出变化的字段
+func diffActions(before, after []DecisionAction) []FieldChange {
+	var changes []FieldChange
+	for i := range after {
+		if i >= len(before) {
+			break
+		}
+		a, b := before[i], after[i]
+		add := func(field string, from, to any) {
+			changes = append(changes, FieldChange{Index: i, Field: field, From: from, To: to})
+		}
+		if a.Action != b.Action {
+			add("action", a.Action, b.Action)
+		}
+		if a.Quantity != b.Quantity {
+			add("quantity", a.Quantity, b.Quantity)
+		}
+		if a.Price != b.Price {
+			add("price", a.Price, b.Price)
+		}
+		if a.OrderID != b.OrderID {
+			add("order_id", a.OrderID, b.OrderID)
+		}
+		if !a.Timestamp.Equal(b.Timestamp) {
+			add("timestamp", a.Timestamp.Format(time.RFC3339Nano), b.Timestamp.Format(time.RFC3339Nano))
+		}
+	}
+	return changes
+}
+
+// unifiedDiff 生成按行比较的 unified diff（基于最长公共子序列，决策文件通常只有数百行）
+func unifiedDiff(fromName, toName, before, after string) string {
+	a, b := splitLines(before), splitLines(after)
+	ops := diffLines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(ops); {
+		// 找到下一处变更，向前后扩展 diffContext 行上下文，合并间隔较近的变更
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start >= len(ops) {
+			break
+		}
+		lo := max(0, start-diffContext)
+		hi := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				hi = i
+			} else if i-hi > 2*diffContext {
+				break
+			}
+		}
+		hi = min(len(ops), hi+diffContext+1)
+
+		aStart, bStart, aLen, bLen := ops[lo].aLine, ops[lo].bLine, 0, 0
+		for _, op := range ops[lo:hi] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+		for _, op := range ops[lo:hi] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+		start = hi
+	}
+	return sb.String()
+}
+
+// hunkRange 格式化 hunk 头中的行范围（行号从 1 开始，空范围指向前一行）
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// diffOp 一行比较结果（' ' 相同，'-' 删除，'+' 新增）；aLine/bLine 为该行之前两侧已经过的行数
+type diffOp struct {
+	kind  byte
+	text  string
+	aLine int
+	bLine int
+}
+
+// diffLines 计算两组行的最短编辑序列
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], aLine: i, bLine: j})
+			i++
+			j++
+		case i < n && (j >= m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: a[i], aLine: i, bLine: j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], aLine: i, bLine: j})
+			j++
+		}
+	}
+	return ops
+}
+
+// splitLines 按行拆分（忽略末尾换行）
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 交易所适配器
+//
+// 拉单、成交与收益记录按交易所接口获取后统一转换为币安格式（BinanceOrder/BinanceTrade/BinanceIncome）写入同一组表，
+// 对账逻辑无需区分交易所。config.db 中交易所的 id/name 包含 okx、bybit 时使用对应适配器，其余按币安处理。
+
+// 交易所类型
+const (
+	ExchangeBinance = "binance"
+	ExchangeOKX     = "okx"
+	ExchangeBybit   = "bybit"
+)
+
+// incrementalOverlap 按时间增量拉取时向前回看的重叠时长（重复记录由唯一约束去重）
+const incrementalOverlap = time.Hour
+
+// exchangeClient 交易所适配器
+// 各方法返回的记录与原始 JSON 一一对应；时间窗口内需要翻页的接口由适配器自行翻页
+type exchangeClient interface {
+	// Name 交易所类型（ExchangeBinance/ExchangeOKX/ExchangeBybit）
+	Name() string
+	// APIKey 用于按密钥限制并发
+	APIKey() string
+	// SetLimiter 设置共享的请求权重限制器（非币安交易所使用各自的限速，忽略该设置）
+	SetLimiter(l *weightLimiter)
+	// IDCursor 是否支持按订单/成交ID增量拉取；不支持时按上次拉取时间回看
+	IDCursor() bool
+	// AllOrders 历史订单（fromOrderID>0 且 IDCursor 时按ID增量，否则按时间窗口）
+	AllOrders(symbol string, fromOrderID, startTime, endTime int64) ([]BinanceOrder, []map[string]any, error)
+	// UserTrades 成交明细（fromID>0 且 IDCursor 时按ID增量，否则按时间窗口）
+	UserTrades(symbol string, fromID, startTime, endTime int64) ([]BinanceTrade, []map[string]any, error)
+	// Income 账户收益记录（按时间升序）
+	Income(startTime, endTime int64) ([]BinanceIncome, []map[string]any, error)
+}
+
+// exchangeKind 根据 config.db 中交易所的 id/name 判断类型
+func exchangeKind(id, name string) string {
+	s := strings.ToLower(id + " " + name)
+	switch {
+	case strings.Contains(s, ExchangeOKX):
+		return ExchangeOKX
+	case strings.Contains(s, ExchangeBybit):
+		return ExchangeBybit
+	}
+	return ExchangeBinance
+}
+
+// 币安合约站点
+const (
+	binanceFapiURL    = "https://fapi.binance.com"
+	binanceDapiURL    = "https://dapi.binance.com"
+	binanceTestnetURL = "https://testnet.binancefuture.com" // U 本位与币本位测试网共用
+)
+
+// binanceEndpoint 币安接口地址：product 为 fapi（U 本位）或 dapi（币本位），url 为站点根地址
+type binanceEndpoint struct {
+	product string
+	url     string
+}
+
+func (e binanceEndpoint) String() string {
+	return e.product + "@" + e.url
+}
+
+// resolveBinanceEndpoint 由 -base/-base_url/-testnet 确定币安接口地址
+// baseURL 用于地区镜像或代理（路径仍按 product 拼接 /fapi/v1 或 /dapi/v1），不能与 testnet 同时使用
+func resolveBinanceEndpoint(base, baseURL string, testnet bool) (binanceEndpoint, error) {
+	base = strings.ToLower(strings.TrimSpace(base))
+	if base != "fapi" && base != "dapi" {
+		return binanceEndpoint{}, fmt.Errorf("-base 只支持 fapi 或 dapi: %q", base)
+	}
+	ep := binanceEndpoint{product: base, url: binanceDapiURL}
+	if base == "fapi" {
+		ep.url = binanceFapiURL
+	}
+	baseURL = strings.TrimSpace(baseURL)
+	switch {
+	case baseURL != "" && testnet:
+		return binanceEndpoint{}, fmt.Errorf("-base_url 与 -testnet 不能同时使用")
+	case testnet:
+		ep.url = binanceTestnetURL
+	case baseURL != "":
+		u, err := url.Parse(baseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return binanceEndpoint{}, fmt.Errorf("-base_url 需要 http(s)://host 形式: %q", baseURL)
+		}
+		ep.url = strings.TrimRight(baseURL, "/")
+	}
+	return ep, nil
+}
+
+// newExchangeClient 按交易所类型创建适配器（base 仅对币安生效）
+func newExchangeClient(kind, apiKey, secretKey, passphrase string, base binanceEndpoint) exchangeClient {
+	switch kind {
+	case ExchangeOKX:
+		return newOKXClient(apiKey, secretKey, passphrase)
+	case ExchangeBybit:
+		return newBybitClient(apiKey, secretKey)
+	}
+	return newSignedClient(apiKey, secretKey, base)
+}
+
+// stableID 把交易所的字符串ID转换为 int64：纯数字直接解析，否则（如 Bybit 的 UUID）取 FNV-64a 哈希
+// 原始ID保存在 raw_json 中
+func stableID(s string) int64 {
+	if s == "" {
+		return 0
+	}
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v
+	}
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return int64(h.Sum64() & math.MaxInt64)
+}
+
+// formatFloat 数值转为与币安接口一致的字符串
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// splitQuote BTCUSDT -> BTC, USDT（未识别的计价资产按 USDT 处理）
+func splitQuote(symbol string) (string, string) {
+	for _, quote := range []string{"USDT", "USDC", "USD"} {
+		if strings.HasSuffix(symbol, quote) && len(symbol) > len(quote) {
+			return strings.TrimSuffix(symbol, quote), quote
+		}
+	}
+	return symbol, "USDT"
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bybit V5 适配器（USDT 永续，category=linear，统一账户）
+//
+// 订单、成交与资金流水接口的时间窗口最长 7 天，超出时按 7 天分段；每段按 cursor 翻页。
+// Bybit 的订单/成交ID为 UUID，按 stableID 转为 int64，原始ID保存在 raw_json 中。
+
+const (
+	bybitBaseURL = "https://api.bybit.com"
+	// bybitRecvWindow 签名有效窗口（毫秒）
+	bybitRecvWindow = "5000"
+	// bybitMaxWindow 单次查询的最大时间窗口
+	bybitMaxWindow = 7 * 24 * time.Hour
+	// bybitRequestsPerMinute 每个 API Key 的请求速率（私有接口约 10 次/秒，保留余量）
+	bybitRequestsPerMinute = 300
+)
+
+// bybitClient Bybit 签名客户端
+type bybitClient struct {
+	apiKey    string
+	secretKey string
+	baseURL   string
+	client    *http.Client
+	limiter   *weightLimiter
+}
+
+func newBybitClient(apiKey, secretKey string) *bybitClient {
+	return &bybitClient{
+		apiKey:    apiKey,
+		secretKey: secretKey,
+		baseURL:   bybitBaseURL,
+		client:    &http.Client{Timeout: 15 * time.Second},
+		limiter:   newWeightLimiter(bybitRequestsPerMinute),
+	}
+}
+
+func (c *bybitClient) Name() string              { return ExchangeBybit }
+func (c *bybitClient) APIKey() string            { return c.apiKey }
+func (c *bybitClient) SetLimiter(*weightLimiter) {}
+func (c *bybitClient) IDCursor() bool            { return false }
+
+// get 签名请求并解析 result 字段
+func (c *bybitClient) get(path string, params url.Values, out any) error {
+	ctx := context.Background()
+	if err := c.limiter.wait(ctx, 1); err != nil {
+		return err
+	}
+	qs := params.Encode()
+	ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	sig := hmacSHA256Hex(ts+c.apiKey+bybitRecvWindow+qs, c.secretKey)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+qs, nil)
+	req.Header.Set("X-BAPI-API-KEY", c.apiKey)
+	req.Header.Set("X-BAPI-TIMESTAMP", ts)
+	req.Header.Set("X-BAPI-RECV-WINDOW", bybitRecvWindow)
+	req.Header.Set("X-BAPI-SIGN", sig)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var envelope struct {
+		RetCode int             `json:"retCode"`
+		RetMsg  string          `json:"retMsg"`
+		Result  json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("解析Bybit响应失败: %w", err)
+	}
+	if envelope.RetCode != 0 {
+		return fmt.Errorf("Bybit返回错误 (retCode %d): %s", envelope.RetCode, envelope.RetMsg)
+	}
+	return json.Unmarshal(envelope.Result, out)
+}
+
+// pages 把 [startTime, endTime] 按 7 天从新到旧分段，每段按 cursor 翻页取完（结果整体从新到旧）
+func (c *bybitClient) pages(path string, params url.Values, limit int, startTime, endTime int64) ([]map[string]any, error) {
+	if endTime <= 0 {
+		endTime = time.Now().UnixMilli()
+	}
+	if startTime <= 0 || startTime > endTime {
+		startTime = endTime - bybitMaxWindow.Milliseconds()
+	}
+	params.Set("limit", strconv.Itoa(limit))
+	var all []map[string]any
+	for to := endTime; to > startTime; to -= bybitMaxWindow.Milliseconds() {
+		from := max(to-bybitMaxWindow.Milliseconds()+1, startTime)
+		params.Set("startTime", strconv.FormatInt(from, 10))
+		params.Set("endTime", strconv.FormatInt(to, 10))
+		params.Del("cursor")
+		for {
+			var result struct {
+				List           []map[string]any `json:"list"`
+				NextPageCursor string           `json:"nextPageCursor"`
+			}
+			if err := c.get(path, params, &result); err != nil {
+				return nil, err
+			}
+			all = append(all, result.List...)
+			if result.NextPageCursor == "" || len(result.List) == 0 || result.NextPageCursor == params.Get("cursor") {
+				break
+			}
+			params.Set("cursor", result.NextPageCursor)
+		}
+	}
+	return all, nil
+}
+
+// bybitPosSide positionIdx 0/1/2 -> BOTH/LONG/SHORT
+func bybitPosSide(idx float64) string {
+	switch idx {
+	case 1:
+		return "LONG"
+	case 2:
+		return "SHORT"
+	}
+	return "BOTH"
+}
+
+// bybitOrderStatus Bybit 订单状态 -> 币安订单状态
+func bybitOrderStatus(s string) string {
+	switch s {
+	case "Filled":
+		return "FILLED"
+	case "PartiallyFilled":
+		return "PARTIALLY_FILLED"
+	case "Cancelled", "PartiallyFilledCanceled":
+		return "CANCELED"
+	case "Deactivated":
+		return "EXPIRED"
+	case "Rejected":
+		return "REJECTED"
+	case "New", "Untriggered", "Triggered", "Created":
+		return "NEW"
+	}
+	return strings.ToUpper(s)
+}
+
+// AllOrders 历史订单（/v5/order/history，按时间窗口；fromOrderID 不使用）
+func (c *bybitClient) AllOrders(symbol string, _ int64, startTime, endTime int64) ([]BinanceOrder, []map[string]any, error) {
+	params := url.Values{"category": {"linear"}, "symbol": {strings.ToUpper(symbol)}}
+	raw, err := c.pages("/v5/order/history", params, 50, startTime, endTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []BinanceOrder
+	var rawKept []map[string]any
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var o struct {
+			OrderID        string  `json:"orderId"`
+			OrderLinkID    string  `json:"orderLinkId"`
+			Symbol         string  `json:"symbol"`
+			Side           string  `json:"side"`
+			PositionIdx    float64 `json:"positionIdx"`
+			OrderStatus    string  `json:"orderStatus"`
+			AvgPrice       string  `json:"avgPrice"`
+			Price          string  `json:"price"`
+			Qty            string  `json:"qty"`
+			CumExecQty     string  `json:"cumExecQty"`
+			OrderType      string  `json:"orderType"`
+			StopOrderType  string  `json:"stopOrderType"`
+			TriggerPrice   string  `json:"triggerPrice"`
+			ReduceOnly     bool    `json:"reduceOnly"`
+			CloseOnTrigger bool    `json:"closeOnTrigger"`
+			CreatedTime    string  `json:"createdTime"`
+			UpdatedTime    string  `json:"updatedTime"`
+		}
+		if json.Unmarshal(b, &o) != nil {
+			continue
+		}
+		created, _ := strconv.ParseInt(o.CreatedTime, 10, 64)
+		updated, _ := strconv.ParseInt(o.UpdatedTime, 10, 64)
+		list = append(list, BinanceOrder{
+			AvgPrice:      o.AvgPrice,
+			ClientOrderID: o.OrderLinkID,
+			ExecutedQty:   o.CumExecQty,
+			OrderID:       stableID(o.OrderID),
+			OrigQty:       o.Qty,
+			OrigType:      strings.ToUpper(o.StopOrderType),
+			Price:         o.Price,
+			ReduceOnly:    o.ReduceOnly || o.CloseOnTrigger,
+			Side:          strings.ToUpper(o.Side),
+			PositionSide:  bybitPosSide(o.PositionIdx),
+			Status:        bybitOrderStatus(o.OrderStatus),
+			StopPrice:     o.TriggerPrice,
+			Symbol:        o.Symbol,
+			Time:          created,
+			Type:          strings.ToUpper(o.OrderType),
+			UpdateTime:    updated,
+		})
+		rawKept = append(rawKept, r)
+	}
+	return list, rawKept, nil
+}
+
+// UserTrades 成交明细（/v5/execution/list，仅 execType=Trade；按时间窗口）
+func (c *bybitClient) UserTrades(symbol string, _ int64, startTime, endTime int64) ([]BinanceTrade, []map[string]any, error) {
+	params := url.Values{"category": {"linear"}, "symbol": {strings.ToUpper(symbol)}}
+	raw, err := c.pages("/v5/execution/list", params, 100, startTime, endTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []BinanceTrade
+	var rawKept []map[string]any
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var e struct {
+			ExecID    string `json:"execId"`
+			OrderID   string `json:"orderId"`
+			Symbol    string `json:"symbol"`
+			Side      string `json:"side"`
+			ExecPrice string `json:"execPrice"`
+			ExecQty   string `json:"execQty"`
+			ExecValue string `json:"execValue"`
+			ExecFee   string `json:"execFee"`
+			ExecType  string `json:"execType"`
+			IsMaker   bool   `json:"isMaker"`
+			ExecTime  string `json:"execTime"`
+		}
+		if json.Unmarshal(b, &e) != nil || e.ExecType != "Trade" {
+			continue
+		}
+		execTime, _ := strconv.ParseInt(e.ExecTime, 10, 64)
+		list = append(list, BinanceTrade{
+			ID:              stableID(e.ExecID),
+			OrderID:         stableID(e.OrderID),
+			Symbol:          e.Symbol,
+			Side:            strings.ToUpper(e.Side),
+			Price:           e.ExecPrice,
+			Qty:             e.ExecQty,
+			QuoteQty:        e.ExecValue,
+			Commission:      e.ExecFee,
+			CommissionAsset: "USDT",
+			Maker:           e.IsMaker,
+			Time:            execTime,
+		})
+		rawKept = append(rawKept, r)
+	}
+	// 接口从新到旧返回，反转为升序
+	slices.Reverse(list)
+	slices.Reverse(rawKept)
+	return list, rawKept, nil
+}
+
+// Income 资金流水（/v5/account/transaction-log）：TRADE 拆为 REALIZED_PNL（cashFlow）与 COMMISSION，SETTLEMENT 为 FUNDING_FEE
+func (c *bybitClient) Income(startTime, endTime int64) ([]BinanceIncome, []map[string]any, error) {
+	params := url.Values{"accountType": {"UNIFIED"}, "category": {"linear"}}
+	raw, err := c.pages("/v5/account/transaction-log", params, 50, startTime, endTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []BinanceIncome
+	var rawKept []map[string]any
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var t struct {
+			ID              string `json:"id"`
+			Symbol          string `json:"symbol"`
+			Type            string `json:"type"`
+			CashFlow        string `json:"cashFlow"`
+			Fee             string `json:"fee"`
+			Funding         string `json:"funding"`
+			Currency        string `json:"currency"`
+			TradeID         string `json:"tradeId"`
+			TransactionTime string `json:"transactionTime"`
+		}
+		if json.Unmarshal(b, &t) != nil {
+			continue
+		}
+		ts, _ := strconv.ParseInt(t.TransactionTime, 10, 64)
+		// Bybit 的 fee/funding 为正表示支出，收益记录按币安口径取反
+		add := func(incomeType string, amount float64) {
+			if amount == 0 {
+				return
+			}
+			list = append(list, BinanceIncome{
+				Symbol:     t.Symbol,
+				IncomeType: incomeType,
+				Income:     formatFloat(amount),
+				Asset:      t.Currency,
+				Time:       ts,
+				TranID:     stableID(t.ID),
+				TradeID:    t.TradeID,
+			})
+			rawKept = append(rawKept, r)
+		}
+		switch t.Type {
+		case "TRADE":
+			add(IncomeRealizedPnl, parseFloat(t.CashFlow))
+			add(IncomeCommission, -parseFloat(t.Fee))
+		case "SETTLEMENT":
+			add(IncomeFundingFee, -parseFloat(t.Funding))
+		}
+	}
+	slices.Reverse(list)
+	slices.Reverse(rawKept)
+	return list, rawKept, nil
+}
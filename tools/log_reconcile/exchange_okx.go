@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OKX V5 适配器（USDT 永续，instType=SWAP）
+//
+// 订单、成交与账单均使用近三个月的归档接口，按 after 游标从新到旧翻页，成交与账单再反转为升序。
+// OKX 数量单位为张，按合约面值 ctVal 换算为币数量，与决策日志一致。
+
+const (
+	okxBaseURL = "https://www.okx.com"
+	// okxPageLimit 单页最大条数
+	okxPageLimit = 100
+	// okxRequestsPerMinute 每个 API Key 的请求速率（私有接口约 10 次/秒，保留余量）
+	okxRequestsPerMinute = 300
+)
+
+// okxClient OKX 签名客户端
+type okxClient struct {
+	apiKey     string
+	secretKey  string
+	passphrase string
+	baseURL    string
+	client     *http.Client
+	limiter    *weightLimiter
+
+	mu     sync.Mutex
+	ctVals map[string]float64 // instId → 合约面值
+}
+
+func newOKXClient(apiKey, secretKey, passphrase string) *okxClient {
+	return &okxClient{
+		apiKey:     apiKey,
+		secretKey:  secretKey,
+		passphrase: passphrase,
+		baseURL:    okxBaseURL,
+		client:     &http.Client{Timeout: 15 * time.Second},
+		limiter:    newWeightLimiter(okxRequestsPerMinute),
+		ctVals:     make(map[string]float64),
+	}
+}
+
+func (c *okxClient) Name() string              { return ExchangeOKX }
+func (c *okxClient) APIKey() string            { return c.apiKey }
+func (c *okxClient) SetLimiter(*weightLimiter) {}
+func (c *okxClient) IDCursor() bool            { return false }
+
+// okxInstID BTCUSDT -> BTC-USDT-SWAP
+func okxInstID(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	if strings.HasSuffix(symbol, "-SWAP") {
+		return symbol
+	}
+	base, quote := splitQuote(symbol)
+	return fmt.Sprintf("%s-%s-SWAP", base, quote)
+}
+
+// okxSymbol BTC-USDT-SWAP -> BTCUSDT
+func okxSymbol(instID string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(instID, "-SWAP"), "-", "")
+}
+
+// get 签名请求并解析 data 字段
+func (c *okxClient) get(path string, params url.Values, out any) error {
+	if c.passphrase == "" {
+		return fmt.Errorf("OKX 需要 passphrase（config.db exchanges.passphrase 或 -okx_passphrase）")
+	}
+	ctx := context.Background()
+	if err := c.limiter.wait(ctx, 1); err != nil {
+		return err
+	}
+	requestPath := path
+	if len(params) > 0 {
+		requestPath += "?" + params.Encode()
+	}
+	ts := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	mac := hmac.New(sha256.New, []byte(c.secretKey))
+	mac.Write([]byte(ts + http.MethodGet + requestPath))
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+requestPath, nil)
+	req.Header.Set("OK-ACCESS-KEY", c.apiKey)
+	req.Header.Set("OK-ACCESS-SIGN", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("OK-ACCESS-TIMESTAMP", ts)
+	req.Header.Set("OK-ACCESS-PASSPHRASE", c.passphrase)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var envelope struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("解析OKX响应失败: %w", err)
+	}
+	if envelope.Code != "0" {
+		return fmt.Errorf("OKX返回错误 (code %s): %s", envelope.Code, envelope.Msg)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// pages 按 after 游标翻页取完时间窗口内的全部记录（idField 为游标字段）
+func (c *okxClient) pages(path string, params url.Values, idField string) ([]map[string]any, error) {
+	params.Set("limit", strconv.Itoa(okxPageLimit))
+	var all []map[string]any
+	for {
+		var page []map[string]any
+		if err := c.get(path, params, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < okxPageLimit {
+			break
+		}
+		last, _ := page[len(page)-1][idField].(string)
+		if last == "" || last == params.Get("after") {
+			break
+		}
+		params.Set("after", last)
+	}
+	return all, nil
+}
+
+// okxTimeWindow begin/end 参数（毫秒，0 表示不限制）
+func okxTimeWindow(startTime, endTime int64) url.Values {
+	params := url.Values{}
+	if startTime > 0 {
+		params.Set("begin", strconv.FormatInt(startTime, 10))
+	}
+	if endTime > 0 {
+		params.Set("end", strconv.FormatInt(endTime, 10))
+	}
+	return params
+}
+
+// ctVal 合约面值（张 → 币），按 instId 缓存
+func (c *okxClient) ctVal(instID string) (float64, error) {
+	c.mu.Lock()
+	v, ok := c.ctVals[instID]
+	c.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	var rows []struct {
+		InstID string `json:"instId"`
+		CtVal  string `json:"ctVal"`
+	}
+	if err := c.get("/api/v5/public/instruments", url.Values{"instType": {"SWAP"}, "instId": {instID}}, &rows); err != nil {
+		return 0, fmt.Errorf("获取 %s 合约面值失败: %w", instID, err)
+	}
+	if len(rows) == 0 || parseFloat(rows[0].CtVal) <= 0 {
+		return 0, fmt.Errorf("未找到合约 %s", instID)
+	}
+	v = parseFloat(rows[0].CtVal)
+	c.mu.Lock()
+	c.ctVals[instID] = v
+	c.mu.Unlock()
+	return v, nil
+}
+
+// okxPosSide long/short/net -> LONG/SHORT/BOTH
+func okxPosSide(s string) string {
+	switch s {
+	case "long":
+		return "LONG"
+	case "short":
+		return "SHORT"
+	}
+	return "BOTH"
+}
+
+// okxOrderStatus OKX 订单状态 -> 币安订单状态
+func okxOrderStatus(s string) string {
+	switch s {
+	case "filled":
+		return "FILLED"
+	case "partially_filled":
+		return "PARTIALLY_FILLED"
+	case "canceled", "mmp_canceled":
+		return "CANCELED"
+	case "live":
+		return "NEW"
+	}
+	return strings.ToUpper(s)
+}
+
+// AllOrders 历史订单（orders-history-archive，按时间窗口；fromOrderID 不使用）
+func (c *okxClient) AllOrders(symbol string, _ int64, startTime, endTime int64) ([]BinanceOrder, []map[string]any, error) {
+	instID := okxInstID(symbol)
+	ctVal, err := c.ctVal(instID)
+	if err != nil {
+		return nil, nil, err
+	}
+	params := okxTimeWindow(startTime, endTime)
+	params.Set("instType", "SWAP")
+	params.Set("instId", instID)
+	raw, err := c.pages("/api/v5/trade/orders-history-archive", params, "ordId")
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []BinanceOrder
+	var rawKept []map[string]any
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var o struct {
+			OrdID      string `json:"ordId"`
+			ClOrdID    string `json:"clOrdId"`
+			Side       string `json:"side"`
+			PosSide    string `json:"posSide"`
+			State      string `json:"state"`
+			AvgPx      string `json:"avgPx"`
+			Px         string `json:"px"`
+			Sz         string `json:"sz"`
+			AccFillSz  string `json:"accFillSz"`
+			OrdType    string `json:"ordType"`
+			ReduceOnly string `json:"reduceOnly"`
+			CTime      string `json:"cTime"`
+			UTime      string `json:"uTime"`
+		}
+		if json.Unmarshal(b, &o) != nil {
+			continue
+		}
+		cTime, _ := strconv.ParseInt(o.CTime, 10, 64)
+		uTime, _ := strconv.ParseInt(o.UTime, 10, 64)
+		list = append(list, BinanceOrder{
+			AvgPrice:      o.AvgPx,
+			ClientOrderID: o.ClOrdID,
+			ExecutedQty:   formatFloat(parseFloat(o.AccFillSz) * ctVal),
+			OrderID:       stableID(o.OrdID),
+			OrigQty:       formatFloat(parseFloat(o.Sz) * ctVal),
+			OrigType:      strings.ToUpper(o.OrdType),
+			Price:         o.Px,
+			ReduceOnly:    o.ReduceOnly == "true",
+			Side:          strings.ToUpper(o.Side),
+			PositionSide:  okxPosSide(o.PosSide),
+			Status:        okxOrderStatus(o.State),
+			Symbol:        okxSymbol(instID),
+			Time:          cTime,
+			Type:          strings.ToUpper(o.OrdType),
+			UpdateTime:    uTime,
+		})
+		rawKept = append(rawKept, r)
+	}
+	return list, rawKept, nil
+}
+
+// UserTrades 成交明细（fills-history，按时间窗口；成交ID使用 billId）
+func (c *okxClient) UserTrades(symbol string, _ int64, startTime, endTime int64) ([]BinanceTrade, []map[string]any, error) {
+	instID := okxInstID(symbol)
+	ctVal, err := c.ctVal(instID)
+	if err != nil {
+		return nil, nil, err
+	}
+	params := okxTimeWindow(startTime, endTime)
+	params.Set("instType", "SWAP")
+	params.Set("instId", instID)
+	raw, err := c.pages("/api/v5/trade/fills-history", params, "billId")
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []BinanceTrade
+	var rawKept []map[string]any
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var f struct {
+			BillID   string `json:"billId"`
+			OrdID    string `json:"ordId"`
+			Side     string `json:"side"`
+			PosSide  string `json:"posSide"`
+			FillPx   string `json:"fillPx"`
+			FillSz   string `json:"fillSz"`
+			Fee      string `json:"fee"`
+			FeeCcy   string `json:"feeCcy"`
+			FillPnl  string `json:"fillPnl"`
+			ExecType string `json:"execType"`
+			Ts       string `json:"ts"`
+		}
+		if json.Unmarshal(b, &f) != nil {
+			continue
+		}
+		ts, _ := strconv.ParseInt(f.Ts, 10, 64)
+		qty := parseFloat(f.FillSz) * ctVal
+		list = append(list, BinanceTrade{
+			ID:              stableID(f.BillID),
+			OrderID:         stableID(f.OrdID),
+			Symbol:          okxSymbol(instID),
+			Side:            strings.ToUpper(f.Side),
+			PositionSide:    okxPosSide(f.PosSide),
+			Price:           f.FillPx,
+			Qty:             formatFloat(qty),
+			QuoteQty:        formatFloat(qty * parseFloat(f.FillPx)),
+			Commission:      formatFloat(-parseFloat(f.Fee)), // OKX 手续费为负数表示扣除
+			CommissionAsset: f.FeeCcy,
+			RealizedPnl:     f.FillPnl,
+			Maker:           f.ExecType == "M",
+			Time:            ts,
+		})
+		rawKept = append(rawKept, r)
+	}
+	// 接口从新到旧返回，反转为升序
+	slices.Reverse(list)
+	slices.Reverse(rawKept)
+	return list, rawKept, nil
+}
+
+// Income 账单（bills-archive）：交易账单拆为 REALIZED_PNL 与 COMMISSION，资金费账单为 FUNDING_FEE
+func (c *okxClient) Income(startTime, endTime int64) ([]BinanceIncome, []map[string]any, error) {
+	params := okxTimeWindow(startTime, endTime)
+	params.Set("instType", "SWAP")
+	raw, err := c.pages("/api/v5/account/bills-archive", params, "billId")
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []BinanceIncome
+	var rawKept []map[string]any
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var bill struct {
+			BillID string `json:"billId"`
+			InstID string `json:"instId"`
+			Type   string `json:"type"`
+			Pnl    string `json:"pnl"`
+			Fee    string `json:"fee"`
+			BalChg string `json:"balChg"`
+			Ccy    string `json:"ccy"`
+			Ts     string `json:"ts"`
+		}
+		if json.Unmarshal(b, &bill) != nil {
+			continue
+		}
+		ts, _ := strconv.ParseInt(bill.Ts, 10, 64)
+		add := func(incomeType, amount string) {
+			if parseFloat(amount) == 0 {
+				return
+			}
+			list = append(list, BinanceIncome{
+				Symbol:     okxSymbol(bill.InstID),
+				IncomeType: incomeType,
+				Income:     amount,
+				Asset:      bill.Ccy,
+				Time:       ts,
+				TranID:     stableID(bill.BillID),
+			})
+			rawKept = append(rawKept, r)
+		}
+		switch bill.Type {
+		case "2": // 交易
+			add(IncomeRealizedPnl, bill.Pnl)
+			add(IncomeCommission, bill.Fee)
+		case "8": // 资金费
+			add(IncomeFundingFee, bill.BalChg)
+		}
+	}
+	// 接口从新到旧返回，反转为升序
+	slices.Reverse(list)
+	slices.Reverse(rawKept)
+	return list, rawKept, nil
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 并发拉单
+//
+// 订单拉取按 (交易员, 交易对) 拆成任务，按客户端分组并行执行（fetch-orders-db 中每个交易员一个客户端，同时最多 workers 个）：
+//   - 每个客户端使用独立的按币安权重计费的令牌桶（allOrders: fapi 权重 5，dapi 权重 20），总预算按同时运行的客户端数均分，
+//     并根据响应头 X-MBX-USED-WEIGHT-1M / Retry-After 主动降速，避免与实盘交易员争抢 IP 权重；
+//   - 同一 API Key 同时在途的请求数受 perKey 限制，且两次请求之间至少间隔 minInterval；
+//   - SQLite 写事务交给单个写入协程依次执行（见 writeTx），读取与网络请求并行；
+//   - 开启 withTrades 时同一任务在订单之后拉取 userTrades 成交（权重同 allOrders）；
+//   - 全部任务完成后按交易员记录一次当前杠杆与保证金模式（positionRisk，见 leverage.go）；
+//   - 设置 progress 时跳过已完成的任务并持久化每个任务的完成状态（-resume 续传，见 fetch_progress.go）。
+
+const (
+	// defaultFetchWorkers 默认 worker 数量
+	defaultFetchWorkers = 4
+	// defaultFetchPerKey 默认每个 API Key 的并发上限
+	defaultFetchPerKey = 2
+	// defaultWeightPerMinute 默认每分钟可用权重（币安 IP 上限 2400，保留一半给实盘）
+	defaultWeightPerMinute = 1200
+	// usedWeightPauseRatio 响应头报告的已用权重超过上限的该比例时暂停到下一分钟
+	usedWeightPauseRatio = 0.8
+	// binanceWeightLimit 币安每分钟 IP 权重上限
+	binanceWeightLimit = 2400
+)
+
+// dbWriteReq 交给写入协程执行的一个事务
+type dbWriteReq struct {
+	db   *sql.DB
+	fn   func(tx *sql.Tx) error
+	done chan error
+}
+
+// dbWrites 所有写事务由同一个协程依次执行，避免并发写入时 SQLite 频繁 busy
+var dbWrites = make(chan dbWriteReq)
+
+var startDBWriter = sync.OnceFunc(func() {
+	go func() {
+		for req := range dbWrites {
+			req.done <- runTx(req.db, req.fn)
+		}
+	}()
+})
+
+// writeTx 在写入协程中执行事务并等待结果（fn 中不能再调用 writeTx）
+func writeTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	startDBWriter()
+	done := make(chan error, 1)
+	dbWrites <- dbWriteReq{db: db, fn: fn, done: done}
+	return <-done
+}
+
+// runTx 开启事务执行 fn，成功则提交
+func runTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// weightLimiter 按请求权重计费的令牌桶（每分钟补满 perMinute）
+type weightLimiter struct {
+	mu         sync.Mutex
+	perMinute  float64
+	tokens     float64
+	last       time.Time
+	pauseUntil time.Time
+}
+
+func newWeightLimiter(perMinute int) *weightLimiter {
+	if perMinute <= 0 {
+		perMinute = defaultWeightPerMinute
+	}
+	return &weightLimiter{perMinute: float64(perMinute), tokens: float64(perMinute), last: time.Now()}
+}
+
+// reserve 扣除权重，返回需要等待的时长（0 表示可立即发送）
+func (l *weightLimiter) reserve(weight int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Before(l.pauseUntil) {
+		return l.pauseUntil.Sub(now)
+	}
+	l.tokens = min(l.perMinute, l.tokens+now.Sub(l.last).Minutes()*l.perMinute)
+	l.last = now
+	if l.tokens >= float64(weight) {
+		l.tokens -= float64(weight)
+		return 0
+	}
+	missing := float64(weight) - l.tokens
+	return time.Duration(missing / l.perMinute * float64(time.Minute))
+}
+
+// wait 阻塞直到可以发送指定权重的请求
+func (l *weightLimiter) wait(ctx context.Context, weight int) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		d := l.reserve(weight)
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// pause 暂停所有请求到指定时间之后
+func (l *weightLimiter) pause(d time.Duration) {
+	if l == nil || d <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pauseUntil) {
+		l.pauseUntil = until
+		l.tokens = 0
+	}
+}
+
+// observe 根据响应头调整速率：429/418 按 Retry-After 暂停，已用权重接近上限时暂停到下一分钟
+func (l *weightLimiter) observe(resp *http.Response) {
+	if l == nil || resp == nil {
+		return
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+		retry := time.Minute
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && sec > 0 {
+			retry = time.Duration(sec) * time.Second
+		}
+		log.Printf("⏸ 触发币安限频(HTTP %d)，暂停 %v", resp.StatusCode, retry)
+		l.pause(retry)
+		return
+	}
+	used, err := strconv.Atoi(resp.Header.Get("X-MBX-USED-WEIGHT-1M"))
+	if err != nil || float64(used) < binanceWeightLimit*usedWeightPauseRatio {
+		return
+	}
+	now := time.Now()
+	next := now.Truncate(time.Minute).Add(time.Minute)
+	log.Printf("⏸ 已用权重 %d 接近上限，暂停到 %s", used, next.Format("15:04:05"))
+	l.pause(next.Sub(now))
+}
+
+// keyGate 单个 API Key 的并发与间隔限制
+type keyGate struct {
+	slots chan struct{}
+	mu    sync.Mutex
+	next  time.Time
+}
+
+// acquire 占用并发槽位并等待最小间隔
+func (g *keyGate) acquire(minInterval time.Duration) {
+	g.slots <- struct{}{}
+	if minInterval <= 0 {
+		return
+	}
+	g.mu.Lock()
+	now := time.Now()
+	start := g.next
+	if start.Before(now) {
+		start = now
+	}
+	g.next = start.Add(minInterval)
+	g.mu.Unlock()
+	time.Sleep(time.Until(start))
+}
+
+func (g *keyGate) release() {
+	<-g.slots
+}
+
+// fetchTask 一个 (交易员, 交易对) 拉单任务
+type fetchTask struct {
+	traderID string
+	symbol   string
+	client   exchangeClient
+}
+
+// fetchPool 并发拉单配置
+type fetchPool struct {
+	workers     int
+	perKey      int
+	minInterval time.Duration // 同一 API Key 两次请求的最小间隔
+	// weightPerMinute 每分钟权重总预算（按同时运行的客户端数均分）
+	weightPerMinute int
+	withTrades      bool // 订单之后同时拉取 userTrades 成交
+	withIncome      bool // 全部任务完成后按交易员拉取 income 收益记录
+	// backfillFrom 非零时在增量拉取后回补该日期以来的历史订单
+	backfillFrom time.Time
+	// report 非 nil 时把拉取失败计入运行汇总（worker 中在 mu 内写入）
+	report *runReport
+	// progress 非 nil 时记录任务完成状态，续传时跳过已完成的任务
+	progress *fetchProgress
+
+	mu    sync.Mutex
+	gates map[string]*keyGate
+}
+
+func newFetchPool(workers, perKey, weightPerMinute int, minInterval time.Duration) *fetchPool {
+	if workers <= 0 {
+		workers = defaultFetchWorkers
+	}
+	if perKey <= 0 {
+		perKey = defaultFetchPerKey
+	}
+	if weightPerMinute <= 0 {
+		weightPerMinute = defaultWeightPerMinute
+	}
+	return &fetchPool{
+		workers:         workers,
+		perKey:          perKey,
+		minInterval:     minInterval,
+		weightPerMinute: weightPerMinute,
+		gates:           make(map[string]*keyGate),
+	}
+}
+
+// gate 返回 API Key 对应的限制器
+func (p *fetchPool) gate(apiKey string) *keyGate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	g, ok := p.gates[apiKey]
+	if !ok {
+		g = &keyGate{slots: make(chan struct{}, p.perKey)}
+		p.gates[apiKey] = g
+	}
+	return g
+}
+
+// fetchLane 使用同一客户端的一组任务（fetch-orders-db 中每个交易员各有一个客户端）
+type fetchLane struct {
+	client exchangeClient
+	tasks  []fetchTask
+}
+
+// groupByClient 按客户端分组任务（保持首次出现的顺序）
+func groupByClient(tasks []fetchTask) []*fetchLane {
+	var lanes []*fetchLane
+	index := make(map[exchangeClient]*fetchLane)
+	for _, t := range tasks {
+		l, ok := index[t.client]
+		if !ok {
+			l = &fetchLane{client: t.client}
+			index[t.client] = l
+			lanes = append(lanes, l)
+		}
+		l.tasks = append(l.tasks, t)
+	}
+	return lanes
+}
+
+// run 并发执行任务，返回处理数与失败数
+// 每个客户端一个协程（同时最多 workers 个），各自使用独立的权重预算，交易对多的交易员不会阻塞其他交易员
+func (p *fetchPool) run(db *sql.DB, tasks []fetchTask) (processed, failed int) {
+	if len(tasks) == 0 {
+		return 0, 0
+	}
+	st := time.Now()
+	// 收益与杠杆按交易员拉取，续传时仍对全部任务执行
+	lanes := groupByClient(p.progress.pending(tasks))
+	concurrent := max(1, min(p.workers, len(lanes)))
+	// 总预算按同时运行的客户端数均分（在启动协程前设置，协程中只读）；各客户端仍按响应头的 IP 已用权重降速
+	for _, l := range groupByClient(tasks) {
+		l.client.SetLimiter(newWeightLimiter(p.weightPerMinute / concurrent))
+	}
+	total := 0
+	for _, l := range lanes {
+		total += len(l.tasks)
+	}
+	meter := newProgressMeter(total)
+	sem := make(chan struct{}, concurrent)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, lane := range lanes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			p.runLane(db, lane, func(task fetchTask, err error) {
+				msg := ""
+				if err != nil {
+					msg = fmt.Sprintf("⚠ 拉取 [%s] %s 失败: %v", task.traderID, task.symbol, err)
+					log.Println(msg)
+					metrics.add(metricAPIErrors, 1, "exchange", task.client.Name())
+				}
+				p.progress.record(task, err)
+				meter.step()
+				mu.Lock()
+				defer mu.Unlock()
+				processed++
+				if err != nil {
+					failed++
+					if p.report != nil {
+						p.report.failure(task.traderID, msg)
+					}
+				}
+			})
+		}()
+	}
+	wg.Wait()
+	log.Printf("⏱ 并发拉取 %d 个任务完成（客户端 %d 个，同时 %d 个，每Key并发=%d），失败 %d，用时 %v", processed, len(lanes), concurrent, p.perKey, failed, time.Since(st).Round(time.Second))
+	if p.withIncome {
+		p.fetchIncome(db, tasks)
+	}
+	p.fetchLeverage(db, tasks)
+	return processed, failed
+}
+
+// runLane 执行一个客户端的任务（同一 API Key 最多 perKey 个并发），每个任务完成后回调 done
+func (p *fetchPool) runLane(db *sql.DB, lane *fetchLane, done func(task fetchTask, err error)) {
+	queue := make(chan fetchTask)
+	var wg sync.WaitGroup
+	for i := 0; i < min(p.perKey, len(lane.tasks)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				g := p.gate(task.client.APIKey())
+				g.acquire(p.minInterval)
+				err := p.fetchOne(db, task)
+				g.release()
+				done(task, err)
+			}
+		}()
+	}
+	for _, task := range lane.tasks {
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// fetchOne 拉取一个 (交易员, 交易对) 的订单、挂单、回补与成交
+func (p *fetchPool) fetchOne(db *sql.DB, task fetchTask) error {
+	err := fetchOrdersForSymbol(db, task.client, task.traderID, task.symbol)
+	// 支持查询挂单的交易所额外刷新当前条件单（止损/止盈核对用）
+	if l, ok := task.client.(openOrderLister); ok && err == nil {
+		err = fetchOpenOrdersForSymbol(db, l, task.traderID, task.symbol)
+	}
+	if err == nil && !p.backfillFrom.IsZero() {
+		err = backfillOrdersForSymbol(db, task.client, task.traderID, task.symbol, p.backfillFrom)
+	}
+	if err == nil && p.withTrades {
+		err = fetchTradesForSymbol(db, task.client, task.traderID, task.symbol)
+	}
+	return err
+}
+
+// fetchIncome 按交易员拉取收益记录（income 按账户返回，每个交易员只需一次，串行执行）
+func (p *fetchPool) fetchIncome(db *sql.DB, tasks []fetchTask) {
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		if seen[task.traderID] {
+			continue
+		}
+		seen[task.traderID] = true
+		if err := fetchIncomeForTrader(db, task.client, task.traderID); err != nil {
+			msg := fmt.Sprintf("⚠ 拉取 [%s] 收益记录失败: %v", task.traderID, err)
+			log.Println(msg)
+			metrics.add(metricAPIErrors, 1, "exchange", task.client.Name())
+			if p.report != nil {
+				p.report.failure(task.traderID, msg)
+			}
+		}
+	}
+}
+
+// fetchLeverage 按交易员记录当前杠杆设置（仅支持 positionRisk 的交易所，串行执行）
+func (p *fetchPool) fetchLeverage(db *sql.DB, tasks []fetchTask) {
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		l, ok := task.client.(positionRiskLister)
+		if !ok || seen[task.traderID] {
+			continue
+		}
+		seen[task.traderID] = true
+		if err := fetchLeverageForTrader(db, l, task.traderID); err != nil {
+			msg := fmt.Sprintf("⚠ 记录 [%s] 杠杆设置失败: %v", task.traderID, err)
+			log.Println(msg)
+			metrics.add(metricAPIErrors, 1, "exchange", task.client.Name())
+			if p.report != nil {
+				p.report.failure(task.traderID, msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 拉单进度与续传（-resume）
+//
+// fetch-orders / fetch-orders-db 每次运行在 fetch_runs 登记一个 run_id，每完成一个 (交易员, 交易对, 账户) 任务
+// 就写入 fetch_progress。进程中断（崩溃、Ctrl+C、机器重启）后运行状态停留在 running，
+// 带 -resume 重新执行同一动作时续用最近一次未完成的运行，跳过已完成的任务；失败的任务不记录，续传时重试。
+// 账户以 API Key 的 SHA-256 前缀区分（回退模式下同一交易对可能由多个账户拉取），不保存密钥本身。
+
+// RunStatusIncomplete 拉单运行结束但有失败任务（可续传）
+const RunStatusIncomplete = "incomplete"
+
+// fetchProgressSchema 拉单运行与已完成任务
+const fetchProgressSchema = `CREATE TABLE IF NOT EXISTS fetch_runs(
+	run_id TEXT PRIMARY KEY,
+	action TEXT,
+	started_at INTEGER,
+	finished_at INTEGER,
+	status TEXT
+);
+CREATE TABLE IF NOT EXISTS fetch_progress(
+	run_id TEXT,
+	trader_id TEXT,
+	symbol TEXT,
+	account TEXT,
+	completed_at INTEGER,
+	PRIMARY KEY(run_id, trader_id, symbol, account)
+);`
+
+// progressLogInterval 进度输出的最小间隔
+const progressLogInterval = 5 * time.Second
+
+// fetchProgress 一次拉单运行的持久化进度（nil 表示不记录，如常驻模式）
+type fetchProgress struct {
+	db    *sql.DB
+	runID string
+
+	mu     sync.Mutex
+	done   map[string]bool // 已完成的任务键
+	failed int
+}
+
+// beginFetchProgress 登记一次拉单运行；resume 时续用该动作最近一次未完成的运行
+func beginFetchProgress(db *sql.DB, action string, resume bool) (*fetchProgress, error) {
+	p := &fetchProgress{db: db, done: make(map[string]bool)}
+	if resume {
+		err := db.QueryRow(`SELECT run_id FROM fetch_runs WHERE action = ? AND status <> ? ORDER BY started_at DESC LIMIT 1`, action, RunStatusDone).Scan(&p.runID)
+		switch {
+		case err == sql.ErrNoRows:
+			log.Printf("ℹ 没有可续传的 %s 运行，开始新的运行", action)
+		case err != nil:
+			return nil, fmt.Errorf("查询未完成的拉单运行失败: %w", err)
+		default:
+			if err := p.loadDone(); err != nil {
+				return nil, err
+			}
+			if _, err := db.Exec(`UPDATE fetch_runs SET status = ?, finished_at = NULL WHERE run_id = ?`, RunStatusRunning, p.runID); err != nil {
+				return nil, fmt.Errorf("更新运行状态失败: %w", err)
+			}
+			log.Printf("↻ 续传拉单运行 %s（已完成 %d 个任务）", p.runID, len(p.done))
+			return p, nil
+		}
+	}
+	now := time.Now()
+	p.runID = fmt.Sprintf("%s_%s", action, now.Format("20060102_150405.000"))
+	if _, err := db.Exec(`INSERT INTO fetch_runs(run_id, action, started_at, status) VALUES(?,?,?,?)`, p.runID, action, now.UnixMilli(), RunStatusRunning); err != nil {
+		return nil, fmt.Errorf("登记拉单运行失败: %w", err)
+	}
+	return p, nil
+}
+
+// loadDone 读取运行已完成的任务
+func (p *fetchProgress) loadDone() error {
+	rows, err := p.db.Query(`SELECT trader_id, symbol, account FROM fetch_progress WHERE run_id = ?`, p.runID)
+	if err != nil {
+		return fmt.Errorf("读取拉单进度失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var traderID, symbol, account string
+		if rows.Scan(&traderID, &symbol, &account) == nil {
+			p.done[traderID+"|"+symbol+"|"+account] = true
+		}
+	}
+	return rows.Err()
+}
+
+// pending 过滤掉已完成的任务
+func (p *fetchProgress) pending(tasks []fetchTask) []fetchTask {
+	if p == nil || len(p.done) == 0 {
+		return tasks
+	}
+	var res []fetchTask
+	for _, t := range tasks {
+		if !p.done[progressKey(t)] {
+			res = append(res, t)
+		}
+	}
+	if skipped := len(tasks) - len(res); skipped > 0 {
+		log.Printf("↻ 跳过已完成的任务 %d 个，剩余 %d 个", skipped, len(res))
+	}
+	return res
+}
+
+// record 记录任务结果：成功的任务写入进度，失败的只计数（续传时重试）
+func (p *fetchProgress) record(task fetchTask, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.failed++
+		return
+	}
+	key := progressKey(task)
+	p.done[key] = true
+	e := writeTx(p.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO fetch_progress(run_id, trader_id, symbol, account, completed_at) VALUES(?,?,?,?,?)`,
+			p.runID, task.traderID, task.symbol, accountTag(task.client.APIKey()), time.Now().UnixMilli())
+		return err
+	})
+	if e != nil {
+		log.Printf("⚠ 记录拉单进度失败 [%s] %s: %v", task.traderID, task.symbol, e)
+	}
+}
+
+// finish 结束运行：全部成功为 done，有失败任务为 incomplete
+func (p *fetchProgress) finish() {
+	if p == nil {
+		return
+	}
+	status := RunStatusDone
+	if p.failed > 0 {
+		status = RunStatusIncomplete
+	}
+	if _, err := p.db.Exec(`UPDATE fetch_runs SET finished_at = ?, status = ? WHERE run_id = ?`, time.Now().UnixMilli(), status, p.runID); err != nil {
+		log.Printf("⚠ 更新拉单运行状态失败 %s: %v", p.runID, err)
+		return
+	}
+	if p.failed > 0 {
+		log.Printf("↻ 拉单运行 %s 有 %d 个任务失败，可使用 -resume 重试未完成的任务", p.runID, p.failed)
+	}
+}
+
+// progressKey 任务键：交易员|交易对|账户
+func progressKey(t fetchTask) string {
+	return t.traderID + "|" + t.symbol + "|" + accountTag(t.client.APIKey())
+}
+
+// accountTag API Key 的 SHA-256 前缀（区分账户，不保存密钥）
+func accountTag(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:6])
+}
+
+// progressMeter 控制台进度输出（按间隔节流，最后一个任务必定输出）
+type progressMeter struct {
+	mu    sync.Mutex
+	total int
+	done  int
+	start time.Time
+	last  time.Time
+}
+
+func newProgressMeter(total int) *progressMeter {
+	now := time.Now()
+	return &progressMeter{total: total, start: now, last: now}
+}
+
+// step 完成一个任务
+func (m *progressMeter) step() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.done++
+	now := time.Now()
+	if m.done < m.total && now.Sub(m.last) < progressLogInterval {
+		return
+	}
+	m.last = now
+	elapsed := now.Sub(m.start)
+	eta := "-"
+	if m.done < m.total {
+		eta = (elapsed / time.Duration(m.done) * time.Duration(m.total-m.done)).Round(time.Second).String()
+	}
+	const width = 20
+	filled := m.done * width / m.total
+	log.Printf("⏳ [%s%s] %d/%d (%.0f%%) 已用 %v，预计剩余 %s",
+		strings.Repeat("#", filled), strings.Repeat("-", width-filled), m.done, m.total, float64(m.done)*100/float64(m.total), elapsed.Round(time.Second), eta)
+}
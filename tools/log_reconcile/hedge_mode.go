@@ -0,0 +1,178 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// 双向持仓（Hedge Mode）支持
+//
+// 双向持仓模式下同一交易对可同时持有 LONG 与 SHORT：
+//   - 订单的 positionSide 为 LONG/SHORT（单向模式为 BOTH）；
+//   - 平仓单不能携带 reduceOnly，币安返回的 reduceOnly 恒为 false，
+//     只能通过 side + positionSide 判断：LONG+SELL / SHORT+BUY 为平仓。
+// 因此双向持仓订单一律严格按 positionSide 归属，并用决策日志中的仓位历史校验。
+
+// isHedgeOrder 订单是否来自双向持仓模式
+func isHedgeOrder(o *BinanceOrder) bool {
+	ps := strings.ToUpper(o.PositionSide)
+	return ps == "LONG" || ps == "SHORT"
+}
+
+// orderOpensPosition 订单是否为指定方向（LONG/SHORT）的开仓单
+func orderOpensPosition(o *BinanceOrder, posSide string) bool {
+	posSide = strings.ToUpper(posSide)
+	if isHedgeOrder(o) {
+		if strings.ToUpper(o.PositionSide) != posSide {
+			return false
+		}
+		return !o.ClosePosition && !isHedgeCloseSide(posSide, o.Side)
+	}
+	if o.ClosePosition || o.ReduceOnly {
+		return false
+	}
+	return matchOpenSide(strings.ToLower(posSide), o.Side)
+}
+
+// orderClosesPosition 订单是否为指定方向（LONG/SHORT）的平仓/减仓单
+func orderClosesPosition(o *BinanceOrder, posSide string) bool {
+	posSide = strings.ToUpper(posSide)
+	if isHedgeOrder(o) {
+		return strings.ToUpper(o.PositionSide) == posSide && isHedgeCloseSide(posSide, o.Side)
+	}
+	if !(o.ClosePosition || o.ReduceOnly) {
+		return false
+	}
+	return matchCloseSide(strings.ToLower(posSide), o.Side)
+}
+
+// orderReducesPartially 订单是否可视为部分平仓（单向模式要求 reduceOnly；双向模式不含 closePosition 的平仓单）
+func orderReducesPartially(o *BinanceOrder, posSide string) bool {
+	if !orderClosesPosition(o, posSide) {
+		return false
+	}
+	if isHedgeOrder(o) {
+		return !o.ClosePosition
+	}
+	return o.ReduceOnly
+}
+
+// isHedgeCloseSide 双向持仓下平仓方向：LONG 仓位 SELL，SHORT 仓位 BUY
+func isHedgeCloseSide(posSide, orderSide string) bool {
+	if posSide == "LONG" {
+		return strings.ToUpper(orderSide) == "SELL"
+	}
+	return strings.ToUpper(orderSide) == "BUY"
+}
+
+// positionInterval 决策日志中的一段持仓（Close 为零值表示仍未平仓）
+type positionInterval struct {
+	Open  time.Time
+	Close time.Time
+}
+
+// positionHistory 按 symbol_side 记录的仓位历史，用于校验订单归属
+type positionHistory struct {
+	intervals map[string][]positionInterval
+	tolerance time.Duration // 判断持仓时开/平仓时间的容差
+}
+
+func newPositionHistory(tolerance time.Duration) *positionHistory {
+	return &positionHistory{intervals: make(map[string][]positionInterval), tolerance: tolerance}
+}
+
+// observe 按时间顺序记录一条成功的决策动作
+func (h *positionHistory) observe(act DecisionAction) {
+	switch {
+	case act.Action == "open_long" || act.Action == "open_short":
+		key := act.Symbol + "_" + sideFromAction(act.Action)
+		list := h.intervals[key]
+		// 已有未平仓的同向仓位时视为加仓，不新开区间
+		if n := len(list); n > 0 && list[n-1].Close.IsZero() {
+			return
+		}
+		h.intervals[key] = append(list, positionInterval{Open: act.Timestamp})
+	case isCloseAction(act.Action):
+		key := act.Symbol + "_" + sideFromAction(act.Action)
+		list := h.intervals[key]
+		if n := len(list); n > 0 && list[n-1].Close.IsZero() {
+			list[n-1].Close = act.Timestamp
+		}
+	}
+}
+
+// sort 按开仓时间排序（日志文件可能乱序）
+func (h *positionHistory) sort() {
+	for key := range h.intervals {
+		list := h.intervals[key]
+		sort.Slice(list, func(i, j int) bool { return list[i].Open.Before(list[j].Open) })
+	}
+}
+
+// isOpenAt 指定方向在 t 时刻（含容差）是否持仓
+func (h *positionHistory) isOpenAt(symbol, posSide string, t time.Time) bool {
+	tolerance := h.tolerance
+	for _, iv := range h.intervals[symbol+"_"+strings.ToUpper(posSide)] {
+		if t.Before(iv.Open.Add(-tolerance)) {
+			continue
+		}
+		if iv.Close.IsZero() || !t.After(iv.Close.Add(tolerance)) {
+			return true
+		}
+	}
+	return false
+}
+
+// openSidesAt 返回 t 时刻持仓的方向；历史中无该交易对记录时返回 nil（无法判断）
+func (h *positionHistory) openSidesAt(symbol string, t time.Time) []string {
+	known := false
+	var sides []string
+	for _, side := range []string{"LONG", "SHORT"} {
+		if len(h.intervals[symbol+"_"+side]) > 0 {
+			known = true
+		}
+		if h.isOpenAt(symbol, side, t) {
+			sides = append(sides, side)
+		}
+	}
+	if !known {
+		return nil
+	}
+	return sides
+}
+
+// symbolUsesHedgeMode 该交易员在该交易对上是否存在双向持仓订单
+func symbolUsesHedgeMode(group map[string][]BinanceOrder, traderID, symbol string) bool {
+	for _, side := range []string{"LONG", "SHORT"} {
+		if len(group[traderID+"_"+symbol+"_"+side]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePartialCloseSide 部分平仓未记录方向时，按订单 positionSide 判断所属仓位
+// candidates 为当时持仓的方向；只有一个时直接返回，多个时取时间窗口 window 内最近的匹配订单的方向，无法判断返回空
+func resolvePartialCloseSide(group map[string][]BinanceOrder, traderID, symbol string, ts time.Time, window time.Duration, candidates []string) string {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	best := ""
+	bestDelta := window.Milliseconds() + 1
+	for _, side := range candidates {
+		for _, list := range getOrderLists(group, traderID, symbol, side) {
+			for i := range list {
+				o := &list[i]
+				if !orderReducesPartially(o, side) {
+					continue
+				}
+				if delta := abs64(o.Time - ts.UnixMilli()); delta < bestDelta {
+					bestDelta = delta
+					best = side
+				}
+			}
+		}
+	}
+	return best
+}
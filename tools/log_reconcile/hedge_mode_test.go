@@ -0,0 +1,162 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const hedgeTrader = "trader1"
+
+var hedgeT0 = time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func hedgeOrder(id int64, side, posSide string, at time.Time, qty string) BinanceOrder {
+	return BinanceOrder{
+		OrderID:      id,
+		Symbol:       "BTCUSDT",
+		Side:         side,
+		PositionSide: posSide,
+		Status:       "FILLED",
+		OrigQty:      qty,
+		ExecutedQty:  qty,
+		AvgPrice:     "60000",
+		Time:         at.UnixMilli(),
+	}
+}
+
+func TestHedgeOrderClassification(t *testing.T) {
+	closeAll := hedgeOrder(3, "SELL", "LONG", hedgeT0, "0.2")
+	closeAll.ClosePosition = true
+	oneWayReduce := hedgeOrder(4, "SELL", "BOTH", hedgeT0, "0.1")
+	oneWayReduce.ReduceOnly = true
+	oneWayPlain := hedgeOrder(5, "SELL", "BOTH", hedgeT0, "0.1")
+
+	tests := []struct {
+		name    string
+		order   BinanceOrder
+		posSide string
+		opens   bool
+		closes  bool
+		partial bool
+	}{
+		{"双向开多", hedgeOrder(1, "BUY", "LONG", hedgeT0, "0.2"), "LONG", true, false, false},
+		{"双向开多不属于空仓", hedgeOrder(1, "BUY", "LONG", hedgeT0, "0.2"), "SHORT", false, false, false},
+		{"双向减多（无 reduceOnly）", hedgeOrder(2, "SELL", "LONG", hedgeT0, "0.1"), "LONG", false, true, true},
+		{"双向减多不属于空仓", hedgeOrder(2, "SELL", "LONG", hedgeT0, "0.1"), "SHORT", false, false, false},
+		{"双向开空", hedgeOrder(6, "SELL", "SHORT", hedgeT0, "0.3"), "SHORT", true, false, false},
+		{"双向减空", hedgeOrder(7, "BUY", "SHORT", hedgeT0, "0.1"), "SHORT", false, true, true},
+		{"双向全平多", closeAll, "LONG", false, true, false},
+		{"单向 reduceOnly 卖出", oneWayReduce, "LONG", false, true, true},
+		{"单向 reduceOnly 卖出不是开空", oneWayReduce, "SHORT", false, false, false},
+		{"单向普通卖出为开空", oneWayPlain, "SHORT", true, false, false},
+		{"单向普通卖出不是平多", oneWayPlain, "LONG", false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.order
+			if got := orderOpensPosition(&o, tt.posSide); got != tt.opens {
+				t.Errorf("orderOpensPosition = %v, want %v", got, tt.opens)
+			}
+			if got := orderClosesPosition(&o, tt.posSide); got != tt.closes {
+				t.Errorf("orderClosesPosition = %v, want %v", got, tt.closes)
+			}
+			if got := orderReducesPartially(&o, tt.posSide); got != tt.partial {
+				t.Errorf("orderReducesPartially = %v, want %v", got, tt.partial)
+			}
+		})
+	}
+}
+
+func TestPositionHistorySimultaneousSides(t *testing.T) {
+	h := newPositionHistory(time.Minute)
+	acts := []DecisionAction{
+		{Action: "open_long", Symbol: "BTCUSDT", Timestamp: hedgeT0},
+		{Action: "open_short", Symbol: "BTCUSDT", Timestamp: hedgeT0.Add(10 * time.Minute)},
+		{Action: "open_long", Symbol: "BTCUSDT", Timestamp: hedgeT0.Add(20 * time.Minute)}, // 加仓
+		{Action: "close_long", Symbol: "BTCUSDT", Timestamp: hedgeT0.Add(60 * time.Minute)},
+	}
+	for _, act := range acts {
+		h.observe(act)
+	}
+	h.sort()
+
+	if n := len(h.intervals["BTCUSDT_LONG"]); n != 1 {
+		t.Fatalf("加仓不应新开区间: got %d intervals", n)
+	}
+	tests := []struct {
+		at   time.Time
+		want []string
+	}{
+		{hedgeT0.Add(5 * time.Minute), []string{"LONG"}},
+		{hedgeT0.Add(30 * time.Minute), []string{"LONG", "SHORT"}},
+		{hedgeT0.Add(90 * time.Minute), []string{"SHORT"}},
+	}
+	for _, tt := range tests {
+		if got := h.openSidesAt("BTCUSDT", tt.at); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("openSidesAt(%s) = %v, want %v", tt.at.Format(time.TimeOnly), got, tt.want)
+		}
+	}
+	if got := h.openSidesAt("ETHUSDT", hedgeT0); got != nil {
+		t.Errorf("无记录的交易对应返回 nil, got %v", got)
+	}
+}
+
+func TestResolvePartialCloseSideHedge(t *testing.T) {
+	ts := hedgeT0.Add(30 * time.Minute)
+	group := map[string][]BinanceOrder{
+		hedgeTrader + "_BTCUSDT_LONG": {
+			hedgeOrder(1, "BUY", "LONG", hedgeT0, "0.2"),
+			hedgeOrder(2, "SELL", "LONG", ts.Add(-20*time.Minute), "0.05"),
+		},
+		hedgeTrader + "_BTCUSDT_SHORT": {
+			hedgeOrder(3, "SELL", "SHORT", hedgeT0.Add(10*time.Minute), "0.3"),
+			hedgeOrder(4, "BUY", "SHORT", ts.Add(2*time.Minute), "0.1"),
+		},
+	}
+	if !symbolUsesHedgeMode(group, hedgeTrader, "BTCUSDT") {
+		t.Fatal("存在 LONG/SHORT 订单时应识别为双向持仓")
+	}
+	both := []string{"LONG", "SHORT"}
+	window := 30 * time.Minute
+
+	if got := resolvePartialCloseSide(group, hedgeTrader, "BTCUSDT", ts, window, both); got != "SHORT" {
+		t.Errorf("应取时间最近的减仓单方向 SHORT, got %q", got)
+	}
+	if got := resolvePartialCloseSide(group, hedgeTrader, "BTCUSDT", ts.Add(-20*time.Minute), window, both); got != "LONG" {
+		t.Errorf("应取时间最近的减仓单方向 LONG, got %q", got)
+	}
+	// 开仓单不作为减仓依据
+	if got := resolvePartialCloseSide(group, hedgeTrader, "BTCUSDT", hedgeT0.Add(-40*time.Minute), window, both); got != "" {
+		t.Errorf("窗口内只有开仓单时应无法判断, got %q", got)
+	}
+	if got := resolvePartialCloseSide(group, hedgeTrader, "BTCUSDT", ts, window, []string{"LONG"}); got != "LONG" {
+		t.Errorf("只有一个持仓方向时直接返回, got %q", got)
+	}
+}
+
+func TestFindCycleCloseHedgePartialFill(t *testing.T) {
+	open := DecisionAction{Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.2, Timestamp: hedgeT0, Success: true}
+	rule := defaultTolerance("close_long")
+
+	partial := hedgeOrder(11, "SELL", "LONG", hedgeT0.Add(10*time.Minute), "0.05")
+	partial.OrigQty = "0.2"
+	partial.Status = "PARTIALLY_FILLED"
+	shortClose := hedgeOrder(12, "BUY", "SHORT", hedgeT0.Add(15*time.Minute), "0.2")
+	fullClose := hedgeOrder(13, "SELL", "LONG", hedgeT0.Add(20*time.Minute), "0.2")
+	candidates := []BinanceOrder{partial, shortClose, fullClose}
+
+	// 非最后区间：部分成交与空仓平仓单都不能说明多仓已平
+	cyc := positionCycle{Open: open, To: hedgeT0.Add(time.Hour), Qty: 0.2}
+	if got := findCycleClose(candidates, "LONG", cyc, rule, map[int64]bool{}); got == nil || got.OrderID != 13 {
+		t.Fatalf("应匹配完全成交的多仓平仓单 13, got %+v", got)
+	}
+	// 已使用的订单不再匹配
+	if got := findCycleClose(candidates, "LONG", cyc, rule, map[int64]bool{13: true}); got != nil {
+		t.Errorf("订单 13 已使用时不应匹配, got %d", got.OrderID)
+	}
+	// 空仓只匹配 SHORT+BUY
+	shortCyc := positionCycle{Open: DecisionAction{Action: "open_short", Symbol: "BTCUSDT", Timestamp: hedgeT0}, Last: true}
+	if got := findCycleClose(candidates, "SHORT", shortCyc, rule, map[int64]bool{}); got == nil || got.OrderID != 12 {
+		t.Errorf("空仓应匹配订单 12, got %+v", got)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"nofx/tools/log_reconcile/decisionlog"
+	"time"
+)
+
+// 校正日志与回滚（rollback）
+//
+// reconcile 每次运行分配一个 run_id，所有对决策日志的改写/新建都记录到 correction_journal：
+// 文件路径、操作、改动前后的 SHA-256 与改动前内容（.bak 会被后续运行覆盖，不能作为回滚依据）。
+// -action rollback 按 run_id 恢复该次运行的全部改动：先校验文件仍是该次运行写入的内容，
+// 再逐个恢复（每条记录的写入都是原子的）；任一条失败都会把已恢复的记录写回回滚前的内容。
+// 文件列记录的是决策记录的位置（文件存储为文件路径），回滚时在当前 -decision_dir/-decision_store 中定位。
+
+// 文件操作
+const (
+	JournalOpModify = "modify"
+	JournalOpCreate = "create"
+)
+
+// 运行状态
+const (
+	RunStatusRunning    = "running"
+	RunStatusDone       = "done"
+	RunStatusRolledBack = "rolled_back"
+)
+
+// journalSchema 校正运行与文件改动日志
+const journalSchema = `CREATE TABLE IF NOT EXISTS correction_runs(
+	run_id TEXT PRIMARY KEY,
+	action TEXT,
+	started_at INTEGER,
+	finished_at INTEGER,
+	status TEXT
+);
+CREATE TABLE IF NOT EXISTS correction_journal(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id TEXT,
+	file TEXT,
+	op TEXT,
+	hash_before TEXT,
+	hash_after TEXT,
+	content_before BLOB,
+	created_at INTEGER,
+	rolled_back INTEGER DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_journal_run ON correction_journal(run_id);`
+
+// journal 一次运行的文件改动记录（nil 表示不记录，如预演）
+type journal struct {
+	db    *sql.DB
+	runID string
+	count int
+}
+
+// beginJournal 登记一次运行
+func beginJournal(db *sql.DB, action string) (*journal, error) {
+	now := time.Now()
+	runID := fmt.Sprintf("%s_%s", action, now.Format("20060102_150405.000"))
+	_, err := db.Exec(`INSERT INTO correction_runs(run_id, action, started_at, status) VALUES(?,?,?,?)`, runID, action, now.UnixMilli(), RunStatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("登记运行失败: %w", err)
+	}
+	return &journal{db: db, runID: runID}, nil
+}
+
+// modify 记录对已有文件的改写
+func (j *journal) modify(path string, before, after []byte) {
+	j.record(path, JournalOpModify, before, after)
+}
+
+// create 记录新建的文件
+func (j *journal) create(path string, content []byte) {
+	j.record(path, JournalOpCreate, nil, content)
+}
+
+func (j *journal) record(path, op string, before, after []byte) {
+	if j == nil {
+		return
+	}
+	var hashBefore string
+	if op == JournalOpModify {
+		hashBefore = contentHash(before)
+	}
+	_, err := j.db.Exec(`INSERT INTO correction_journal(run_id, file, op, hash_before, hash_after, content_before, created_at) VALUES(?,?,?,?,?,?,?)`,
+		j.runID, path, op, hashBefore, contentHash(after), before, time.Now().UnixMilli())
+	if err != nil {
+		log.Printf("⚠ 记录校正日志失败 %s: %v", path, err)
+		return
+	}
+	j.count++
+	metrics.filePatched(path, op)
+}
+
+// finish 结束运行
+func (j *journal) finish() {
+	if j == nil {
+		return
+	}
+	_, err := j.db.Exec(`UPDATE correction_runs SET finished_at = ?, status = ? WHERE run_id = ?`, time.Now().UnixMilli(), RunStatusDone, j.runID)
+	if err != nil {
+		log.Printf("⚠ 更新运行状态失败 %s: %v", j.runID, err)
+		return
+	}
+	if j.count > 0 {
+		log.Printf("🧾 本次运行 %s 改动 %d 个文件，可使用 -action rollback -run_id %s 回滚", j.runID, j.count, j.runID)
+	}
+}
+
+func contentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// journalEntry 待回滚的一条改动
+type journalEntry struct {
+	id         int64
+	file       string
+	op         string
+	hashAfter  string
+	contentOld []byte
+}
+
+// latestRollbackRun 最近一次有改动且未回滚的运行
+func latestRollbackRun(db *sql.DB) (string, error) {
+	var runID string
+	err := db.QueryRow(`SELECT r.run_id FROM correction_runs r
+		WHERE r.status <> ? AND EXISTS (SELECT 1 FROM correction_journal j WHERE j.run_id = r.run_id AND j.rolled_back = 0)
+		ORDER BY r.started_at DESC LIMIT 1`, RunStatusRolledBack).Scan(&runID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("没有可回滚的运行")
+	}
+	return runID, err
+}
+
+// rollbackRun 回滚一次运行的全部文件改动（force 时跳过“文件已被再次修改”的校验）
+func rollbackRun(db *sql.DB, src *logSource, runID string, force bool) error {
+	if runID == "" {
+		latest, err := latestRollbackRun(db)
+		if err != nil {
+			return err
+		}
+		runID = latest
+	}
+	var status string
+	if err := db.QueryRow(`SELECT status FROM correction_runs WHERE run_id = ?`, runID).Scan(&status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("未找到运行: %s", runID)
+		}
+		return err
+	}
+	if status == RunStatusRolledBack {
+		return fmt.Errorf("运行 %s 已回滚", runID)
+	}
+
+	rows, err := db.Query(`SELECT id, file, op, hash_after, content_before FROM correction_journal WHERE run_id = ? AND rolled_back = 0 ORDER BY id DESC`, runID)
+	if err != nil {
+		return err
+	}
+	var entries []journalEntry
+	for rows.Next() {
+		var e journalEntry
+		if err := rows.Scan(&e.id, &e.file, &e.op, &e.hashAfter, &e.contentOld); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if len(entries) == 0 {
+		return fmt.Errorf("运行 %s 没有需要回滚的改动", runID)
+	}
+	// 同一文件在一次运行内可能被多次改写，倒序处理后只保留最早一次改动前的内容
+	seen := make(map[string]bool)
+	var plan []journalEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !seen[entries[i].file] {
+			seen[entries[i].file] = true
+			plan = append(plan, entries[i])
+		}
+	}
+	latestHash := make(map[string]string)
+	for _, e := range entries { // entries 按 id 倒序，第一条即最后一次写入
+		if _, ok := latestHash[e.file]; !ok {
+			latestHash[e.file] = e.hashAfter
+		}
+	}
+
+	// 1) 校验文件仍是本次运行写入的内容，并保存当前内容用于撤回
+	type target struct {
+		traderID, name string
+		current        []byte
+	}
+	targets := make(map[string]target, len(plan))
+	for _, e := range plan {
+		traderID, name, ok := src.store.Locate(e.file)
+		if !ok {
+			return fmt.Errorf("%s 不属于当前决策记录存储，请使用该次运行的 -decision_dir/-decision_store", e.file)
+		}
+		current, err := src.store.Get(traderID, name)
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", e.file, err)
+		}
+		if contentHash(current) != latestHash[e.file] && !force {
+			return fmt.Errorf("%s 在运行 %s 之后已被修改，拒绝回滚（确认覆盖请加 -force）", e.file, runID)
+		}
+		targets[e.file] = target{traderID: traderID, name: name, current: current}
+	}
+
+	// 2) 恢复：改写的记录写回改动前内容，新建的记录删除；失败时把已恢复的记录写回当前内容
+	var applied []target
+	undo := func() {
+		for i := len(applied) - 1; i >= 0; i-- {
+			t := applied[i]
+			if err := src.store.Put(decisionlog.Record{TraderID: t.traderID, Name: t.name, Data: t.current}); err != nil {
+				log.Printf("⚠ 撤回 %s 失败: %v", src.location(t.traderID, t.name), err)
+			}
+		}
+	}
+	for _, e := range plan {
+		t := targets[e.file]
+		var err error
+		if e.op == JournalOpModify {
+			err = src.store.Put(decisionlog.Record{TraderID: t.traderID, Name: t.name, Data: e.contentOld})
+		} else {
+			err = src.store.Delete(t.traderID, t.name)
+		}
+		if err != nil {
+			undo()
+			return fmt.Errorf("回滚 %s 失败: %w", e.file, err)
+		}
+		applied = append(applied, t)
+	}
+
+	// 3) 记录回滚状态
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("文件已恢复，但更新回滚状态失败: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE correction_journal SET rolled_back = 1 WHERE run_id = ?`, runID); err != nil {
+		return fmt.Errorf("文件已恢复，但更新回滚状态失败: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE correction_runs SET status = ? WHERE run_id = ?`, RunStatusRolledBack, runID); err != nil {
+		return fmt.Errorf("文件已恢复，但更新回滚状态失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("文件已恢复，但更新回滚状态失败: %w", err)
+	}
+	for _, e := range plan {
+		if e.op == JournalOpCreate {
+			log.Printf("↩ 已删除补全文件 %s", e.file)
+		} else {
+			log.Printf("↩ 已恢复 %s", e.file)
+		}
+	}
+	log.Printf("✓ 运行 %s 已回滚（%d 个文件）", runID, len(plan))
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 仓位台账（ledger）
+//
+// 按时间顺序回放决策，为每个 交易员/交易对/方向 建立仓位序列：open →（加仓 open）→ partial_close* → close，
+// 平仓后再次开仓视为新仓位。回放同时校验不变量并输出结构化的违规记录：
+//   - 无持仓时平仓 / 部分平仓；
+//   - 平仓数量超过剩余数量（剩余数量不得为负）；
+//   - 加仓杠杆与开仓杠杆不一致；
+//   - 开仓数量无效、同时持有多空时部分平仓无法判断方向。
+
+// 违规类型
+const (
+	ViolationCloseWithoutOpen   = "close_without_open"
+	ViolationPartialWithoutOpen = "partial_without_open"
+	ViolationNegativeQuantity   = "negative_quantity"
+	ViolationLeverageMismatch   = "leverage_mismatch"
+	ViolationInvalidQuantity    = "invalid_quantity"
+	ViolationAmbiguousPartial   = "ambiguous_partial_close"
+)
+
+// ledgerQtyTolerance 数量比较的相对容差（交易所数量精度导致的舍入）
+const ledgerQtyTolerance = 0.01
+
+// Violation 一条台账违规
+type Violation struct {
+	TraderID string    `json:"trader_id"`
+	Symbol   string    `json:"symbol"`
+	Side     string    `json:"side,omitempty"`
+	Code     string    `json:"code"`
+	Time     time.Time `json:"time"`
+	File     string    `json:"file,omitempty"`
+	Index    int       `json:"index"` // 文件中 decisions 的下标
+	Message  string    `json:"message"`
+}
+
+// LedgerEvent 仓位上的一次动作
+type LedgerEvent struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Quantity float64   `json:"quantity"`
+	Price    float64   `json:"price"`
+	Leverage int       `json:"leverage,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Index    int       `json:"index"`
+}
+
+// LedgerPosition 一段完整的仓位（Closed 为 false 表示到日志末尾仍持仓）
+type LedgerPosition struct {
+	Symbol    string         `json:"symbol"`
+	Side      string         `json:"side"`
+	Seq       int            `json:"seq"` // 同一 交易对/方向 的第几段仓位（从 1 开始）
+	Leverage  int            `json:"leverage,omitempty"`
+	Opened    float64        `json:"opened"`    // 累计开仓数量（含加仓）
+	Remaining float64        `json:"remaining"` // 剩余数量
+	Closed    bool           `json:"closed"`
+	OpenTime  time.Time      `json:"open_time"`
+	CloseTime time.Time      `json:"close_time,omitzero"`
+	Events    []LedgerEvent  `json:"events"`
+	Open      DecisionAction `json:"-"` // 首次开仓动作
+}
+
+// ledgerAction 带来源位置的决策动作
+type ledgerAction struct {
+	DecisionAction
+	file  string
+	index int
+}
+
+// positionLedger 单个交易员的仓位台账
+type positionLedger struct {
+	traderID   string
+	positions  []*LedgerPosition
+	open       map[string]*LedgerPosition // key = symbol_side
+	seq        map[string]int
+	violations []Violation
+}
+
+// buildLedger 按时间回放成功的决策动作（时间相同时保持文件内顺序）
+func buildLedger(traderID string, acts []ledgerAction) *positionLedger {
+	sorted := append([]ledgerAction(nil), acts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+	l := &positionLedger{traderID: traderID, open: make(map[string]*LedgerPosition), seq: make(map[string]int)}
+	for _, a := range sorted {
+		l.apply(a)
+	}
+	return l
+}
+
+func (l *positionLedger) violate(a ledgerAction, side, code, format string, args ...any) {
+	l.violations = append(l.violations, Violation{
+		TraderID: l.traderID,
+		Symbol:   a.Symbol,
+		Side:     side,
+		Code:     code,
+		Time:     a.Timestamp,
+		File:     a.file,
+		Index:    a.index,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *positionLedger) apply(a ledgerAction) {
+	ev := LedgerEvent{Time: a.Timestamp, Action: a.Action, Quantity: a.Quantity, Price: a.Price, Leverage: a.Leverage, File: a.file, Index: a.index}
+	switch {
+	case a.Action == "open_long" || a.Action == "open_short":
+		side := sideFromAction(a.Action)
+		key := a.Symbol + "_" + side
+		if a.Quantity <= 0 {
+			l.violate(a, side, ViolationInvalidQuantity, "开仓数量无效: %.4f", a.Quantity)
+		}
+		p, ok := l.open[key]
+		if !ok {
+			l.seq[key]++
+			p = &LedgerPosition{Symbol: a.Symbol, Side: side, Seq: l.seq[key], Leverage: a.Leverage, OpenTime: a.Timestamp, Open: a.DecisionAction}
+			l.open[key] = p
+			l.positions = append(l.positions, p)
+		} else if p.Leverage > 0 && a.Leverage > 0 && p.Leverage != a.Leverage {
+			l.violate(a, side, ViolationLeverageMismatch, "加仓杠杆 %dx 与开仓杠杆 %dx 不一致", a.Leverage, p.Leverage)
+		} else if p.Leverage == 0 {
+			p.Leverage = a.Leverage
+		}
+		p.Opened += max(a.Quantity, 0)
+		p.Remaining += max(a.Quantity, 0)
+		p.Events = append(p.Events, ev)
+
+	case a.Action == "partial_close":
+		var sides []string
+		for _, side := range []string{"LONG", "SHORT"} {
+			if _, ok := l.open[a.Symbol+"_"+side]; ok {
+				sides = append(sides, side)
+			}
+		}
+		switch len(sides) {
+		case 0:
+			l.violate(a, "", ViolationPartialWithoutOpen, "部分平仓时没有持仓")
+			return
+		case 2:
+			l.violate(a, "", ViolationAmbiguousPartial, "同时持有多空，无法判断部分平仓方向")
+			return
+		}
+		p := l.open[a.Symbol+"_"+sides[0]]
+		p.Events = append(p.Events, ev)
+		if a.Quantity <= 0 {
+			return // 未记录数量，无法更新剩余
+		}
+		l.reduce(a, p, a.Quantity)
+
+	case isCloseAction(a.Action):
+		side := sideFromAction(a.Action)
+		key := a.Symbol + "_" + side
+		p, ok := l.open[key]
+		if !ok {
+			l.violate(a, side, ViolationCloseWithoutOpen, "%s 时没有 %s 持仓", a.Action, side)
+			return
+		}
+		p.Events = append(p.Events, ev)
+		if a.Quantity > 0 {
+			l.reduce(a, p, a.Quantity)
+		}
+		p.Remaining = 0
+		p.Closed = true
+		p.CloseTime = a.Timestamp
+		delete(l.open, key)
+	}
+}
+
+// reduce 扣减剩余数量，超出容差时记录违规
+func (l *positionLedger) reduce(a ledgerAction, p *LedgerPosition, qty float64) {
+	if qty > p.Remaining*(1+ledgerQtyTolerance)+1e-9 {
+		l.violate(a, p.Side, ViolationNegativeQuantity, "%s 数量 %.4f 超过剩余持仓 %.4f（第 %d 段仓位）", a.Action, qty, p.Remaining, p.Seq)
+	}
+	p.Remaining = max(p.Remaining-qty, 0)
+}
+
+// openPositions 到日志末尾仍未平仓的仓位
+func (l *positionLedger) openPositions() []*LedgerPosition {
+	var out []*LedgerPosition
+	for _, p := range l.positions {
+		if !p.Closed {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// loadLedgerActions 读取交易员所有成功的决策动作，返回动作与记录数
+func loadLedgerActions(src *logSource, traderID string) ([]ledgerAction, int, error) {
+	records, err := src.records(traderID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var acts []ledgerAction
+	for _, r := range records {
+		rec, ok := parseRecord(r)
+		if !ok {
+			continue
+		}
+		fp := src.location(traderID, r.Name)
+		for i, act := range rec.Decisions {
+			if act.Success {
+				acts = append(acts, ledgerAction{DecisionAction: act, file: fp, index: i})
+			}
+		}
+	}
+	return acts, len(records), nil
+}
+
+// validateLedgers 为所有交易员重建仓位台账并输出违规（只读，不修改日志）
+func validateLedgers(src *logSource, rep *runReport) error {
+	log.Println("=== 开始仓位台账校验 ===")
+	traders, err := src.traders()
+	if err != nil {
+		return err
+	}
+	for _, traderID := range traders {
+		acts, files, err := loadLedgerActions(src, traderID)
+		if err != nil {
+			msg := fmt.Sprintf("⚠ 读取 %s 失败: %v", src.location(traderID, ""), err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
+			continue
+		}
+		stats := rep.stats(traderID)
+		stats.Files = files
+		stats.Actions = len(acts)
+		ledger := buildLedger(traderID, acts)
+		stats.Summary = fmt.Sprintf("仓位 %d 段（未平 %d）, 违规 %d", len(ledger.positions), len(ledger.openPositions()), len(ledger.violations))
+
+		var lines []string
+		for _, v := range ledger.violations {
+			rep.violation(v)
+			lines = append(lines, formatViolation(v))
+		}
+		if len(lines) == 0 {
+			log.Printf("✓ [%s] 仓位台账校验通过（%d 段仓位）", traderID, len(ledger.positions))
+			continue
+		}
+		rep.writeTrader(src.reportDir(traderID), traderID, "ledger_report", []string{
+			"=== 仓位台账校验报告 ===",
+			fmt.Sprintf("生成时间: %s", time.Now().Format("2006-01-02 15:04:05")),
+			fmt.Sprintf("Trader ID: %s", traderID),
+			stats.Summary,
+			"",
+		}, lines)
+		for _, msg := range lines {
+			log.Println(msg)
+		}
+	}
+	return rep.finish()
+}
+
+// formatViolation 违规的单行文本
+func formatViolation(v Violation) string {
+	side := v.Side
+	if side == "" {
+		side = "-"
+	}
+	return fmt.Sprintf("⚠ [%s] %s %s [%s] %s (时间: %s, 文件: %s#%d)",
+		v.TraderID, v.Symbol, side, v.Code, v.Message, v.Time.Format("2006-01-02 15:04:05"), filepath.Base(v.File), v.Index)
+}
+
+// positionCycle 仓位中以一次开仓（含加仓）为起点的区间
+// 日志漏记平仓时，之后的开仓会被当成加仓并入同一段仓位，因此按开仓事件拆分区间分别核对
+type positionCycle struct {
+	Open DecisionAction // 区间起点的开仓动作
+	To   time.Time      // 下一次开仓时间（最后一个区间为零值）
+	Qty  float64        // 区间结束时按日志的剩余数量
+	Last bool           // 仓位的最后一个区间
+}
+
+// cycles 按开仓事件把仓位拆分为区间（按时间顺序）
+func (p *LedgerPosition) cycles() []positionCycle {
+	var out []positionCycle
+	remaining := 0.0
+	for _, ev := range p.Events {
+		if ev.Action == "open_long" || ev.Action == "open_short" {
+			if n := len(out); n > 0 {
+				out[n-1].To = ev.Time
+			}
+			open := DecisionAction{Action: ev.Action, Symbol: p.Symbol, Quantity: ev.Quantity, Leverage: ev.Leverage, Price: ev.Price, Timestamp: ev.Time, Success: true}
+			remaining += max(ev.Quantity, 0)
+			out = append(out, positionCycle{Open: open, Qty: remaining})
+			continue
+		}
+		if n := len(out); n > 0 && ev.Quantity > 0 {
+			remaining = max(remaining-ev.Quantity, 0)
+			out[n-1].Qty = remaining
+		}
+	}
+	if n := len(out); n > 0 {
+		out[n-1].Last = true
+	}
+	return out
+}
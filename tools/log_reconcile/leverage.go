@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 杠杆与保证金模式核对
+//
+// 交易所只能查询当前的杠杆与保证金模式（positionRisk），没有历史记录。拉单时对已扫描的交易对记录一次观测，
+// 与上次观测一致时只延长观测区间 [first_seen, last_seen]，变化时新增一段。决策时间落在某段观测区间内时，
+// 该时刻交易所的设置是确定的，开仓记录的 Leverage 与之不同则报告（常见于设置杠杆失败但仍按默认杠杆开仓）；
+// 落在两段观测之间或首次观测之前的决策无法确定当时的设置，不做判断。
+
+// leverageSchema 杠杆观测表
+const leverageSchema = `CREATE TABLE IF NOT EXISTS leverage_snapshots(
+	trader_id TEXT,
+	symbol TEXT,
+	leverage INTEGER,
+	margin_type TEXT,
+	first_seen INTEGER,
+	last_seen INTEGER,
+	PRIMARY KEY(trader_id, symbol, first_seen)
+);`
+
+// BinancePositionRisk positionRisk 接口返回的一条持仓设置
+type BinancePositionRisk struct {
+	Symbol       string `json:"symbol"`
+	Leverage     string `json:"leverage"`
+	MarginType   string `json:"marginType"`
+	PositionSide string `json:"positionSide"`
+	PositionAmt  string `json:"positionAmt"`
+}
+
+// positionRiskLister 支持查询当前杠杆与保证金模式的交易所
+type positionRiskLister interface {
+	PositionRisk() ([]BinancePositionRisk, error)
+}
+
+// leverageSnapshot 一段杠杆设置不变的观测区间（毫秒时间戳）
+type leverageSnapshot struct {
+	Leverage   int
+	MarginType string
+	FirstSeen  int64
+	LastSeen   int64
+}
+
+// PositionRisk 当前各交易对的杠杆与保证金模式（fapi: /fapi/v2/positionRisk 权重 5；dapi: /dapi/v1/positionRisk 权重 1）
+func (c *binanceREST) PositionRisk() ([]BinancePositionRisk, error) {
+	weight, path := 1, c.path("positionRisk")
+	if c.usdm() {
+		weight, path = 5, "/fapi/v2/positionRisk"
+	}
+	ctx := context.Background()
+	if err := c.limiter.wait(ctx, weight); err != nil {
+		return nil, err
+	}
+	qs := fmt.Sprintf("recvWindow=5000&timestamp=%d", time.Now().UnixMilli())
+	sig := hmacSHA256Hex(qs, c.secretKey)
+	url := fmt.Sprintf("%s%s?%s&signature=%s", c.baseURL, path, qs, sig)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req.Header.Set("X-MBX-APIKEY", c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	c.limiter.observe(resp)
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var list []BinancePositionRisk
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// fetchLeverageForTrader 记录交易员已扫描交易对的当前杠杆与保证金模式
+func fetchLeverageForTrader(db *sql.DB, client positionRiskLister, traderID string) error {
+	risks, err := client.PositionRisk()
+	if err != nil {
+		return fmt.Errorf("查询杠杆设置失败: %w", err)
+	}
+	tracked := make(map[string]bool)
+	rows, err := db.Query(`SELECT symbol FROM symbols WHERE trader_id = ?`, traderID)
+	if err != nil {
+		return fmt.Errorf("读取交易对失败: %w", err)
+	}
+	for rows.Next() {
+		var s string
+		if rows.Scan(&s) == nil {
+			tracked[s] = true
+		}
+	}
+	rows.Close()
+
+	now := time.Now().UnixMilli()
+	seen := make(map[string]bool)
+	changed := 0
+	if err := writeTx(db, func(tx *sql.Tx) error {
+		for _, r := range risks {
+			// 双向持仓每个交易对有 LONG/SHORT 两条，杠杆与保证金模式相同
+			if !tracked[r.Symbol] || seen[r.Symbol] {
+				continue
+			}
+			seen[r.Symbol] = true
+			lev, err := strconv.Atoi(r.Leverage)
+			if err != nil || lev <= 0 {
+				continue
+			}
+			margin := strings.ToLower(r.MarginType)
+			var lastLev int
+			var lastMargin string
+			var firstSeen int64
+			err = tx.QueryRow(`SELECT leverage, margin_type, first_seen FROM leverage_snapshots WHERE trader_id = ? AND symbol = ? ORDER BY first_seen DESC LIMIT 1`, traderID, r.Symbol).Scan(&lastLev, &lastMargin, &firstSeen)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("读取杠杆观测失败: %w", err)
+			}
+			if err == nil && lastLev == lev && lastMargin == margin {
+				_, err = tx.Exec(`UPDATE leverage_snapshots SET last_seen = ? WHERE trader_id = ? AND symbol = ? AND first_seen = ?`, now, traderID, r.Symbol, firstSeen)
+			} else {
+				changed++
+				_, err = tx.Exec(`INSERT OR REPLACE INTO leverage_snapshots(trader_id, symbol, leverage, margin_type, first_seen, last_seen) VALUES(?,?,?,?,?,?)`, traderID, r.Symbol, lev, margin, now, now)
+			}
+			if err != nil {
+				return fmt.Errorf("写入杠杆观测失败: %w", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	log.Printf("✓ [%s] 杠杆设置 %d 个交易对（变化 %d）", traderID, len(seen), changed)
+	return nil
+}
+
+// loadLeverageSnapshots 按 trader_id+symbol 分组杠杆观测（按时间排序）
+func loadLeverageSnapshots(db *sql.DB) (map[string][]leverageSnapshot, error) {
+	rows, err := db.Query(`SELECT trader_id, symbol, leverage, margin_type, first_seen, last_seen FROM leverage_snapshots ORDER BY first_seen`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[string][]leverageSnapshot)
+	for rows.Next() {
+		var traderID, symbol string
+		var s leverageSnapshot
+		if err := rows.Scan(&traderID, &symbol, &s.Leverage, &s.MarginType, &s.FirstSeen, &s.LastSeen); err != nil {
+			continue
+		}
+		key := traderID + "_" + symbol
+		res[key] = append(res[key], s)
+	}
+	return res, rows.Err()
+}
+
+// leverageAt 时间点生效的杠杆设置：只有落在某段观测区间内才能确定
+func leverageAt(snaps []leverageSnapshot, t int64) (leverageSnapshot, bool) {
+	for _, s := range snaps {
+		if t >= s.FirstSeen && t <= s.LastSeen {
+			return s, true
+		}
+	}
+	return leverageSnapshot{}, false
+}
+
+// checkTraderLeverage 核对交易员开仓记录的杠杆与交易所当时的设置（只报告）
+func checkTraderLeverage(src *logSource, traderID string, snaps map[string][]leverageSnapshot, rep *runReport) error {
+	acts, _, err := loadLedgerActions(src, traderID)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	checked, unknown := 0, 0
+	for _, a := range acts {
+		if (a.Action != "open_long" && a.Action != "open_short") || a.Leverage <= 0 {
+			continue
+		}
+		s, ok := leverageAt(snaps[traderID+"_"+a.Symbol], a.Timestamp.UnixMilli())
+		if !ok {
+			unknown++
+			continue
+		}
+		checked++
+		if s.Leverage == a.Leverage {
+			continue
+		}
+		msg := fmt.Sprintf("⚙ [%s] %s %s 杠杆不一致: 日志 %dx, 交易所 %dx（%s，%s ~ %s 观测），决策时间 %s",
+			traderID, a.Symbol, a.Action, a.Leverage, s.Leverage, marginTypeName(s.MarginType),
+			time.UnixMilli(s.FirstSeen).Format("01-02 15:04"), time.UnixMilli(s.LastSeen).Format("01-02 15:04"), a.Timestamp.Format("2006-01-02 15:04:05"))
+		rep.issue(traderID, msg)
+		lines = append(lines, msg)
+	}
+	if checked+unknown > 0 {
+		log.Printf("⚙ [%s] 杠杆核对: 核对 %d 条，不一致 %d 条，无观测 %d 条", traderID, checked, len(lines), unknown)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	rep.writeTrader(src.reportDir(traderID), traderID, "leverage_report", []string{"=== 杠杆核对报告 ===", fmt.Sprintf("生成时间: %s", time.Now().Format("2006-01-02 15:04:05")), ""}, lines)
+	for _, msg := range lines {
+		log.Println(msg)
+	}
+	return nil
+}
+
+// marginTypeName 保证金模式的中文名
+func marginTypeName(t string) string {
+	switch t {
+	case "cross", "crossed":
+		return "全仓"
+	case "isolated":
+		return "逐仓"
+	}
+	return t
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/tools/log_reconcile/decisionlog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 决策记录来源（-decision_store / -log_from / -log_to）
+//
+// 各动作通过 decisionlog.Store 读写决策记录：默认是 -decision_dir 下按交易员分目录的 JSON 文件，
+// 也可以是单个 SQLite 数据库或 S3 兼容的对象存储。-log_from/-log_to 限定读取的记录时间，
+// SQLite 按时间索引查询，不必读取全部记录。
+
+// logSource 决策记录存储与读取的时间范围
+type logSource struct {
+	store decisionlog.Store
+	rng   decisionlog.TimeRange
+}
+
+// openLogSource 打开存储（storeURI 为空时使用 decisionDir 文件目录）并解析时间范围
+func openLogSource(storeURI, decisionDir, fromSpec, toSpec string) (*logSource, error) {
+	from, err := parseLogTime("-log_from", fromSpec)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseLogTime("-log_to", toSpec)
+	if err != nil {
+		return nil, err
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, fmt.Errorf("-log_from 必须早于 -log_to")
+	}
+	if storeURI == "" {
+		storeURI = decisionDir
+	}
+	store, err := decisionlog.Open(storeURI)
+	if err != nil {
+		return nil, err
+	}
+	src := &logSource{store: store, rng: decisionlog.TimeRange{From: from, To: to}}
+	if !src.rng.IsZero() {
+		log.Printf("ℹ 只读取记录时间在 [%s, %s) 内的决策记录", formatLogTime(from), formatLogTime(to))
+	}
+	return src, nil
+}
+
+// parseLogTime 解析日期（2006-01-02，UTC）或 RFC3339 时间，空串为不限
+func parseLogTime(name, s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("解析 %s 失败（格式 2006-01-02 或 RFC3339）: %w", name, err)
+	}
+	return t, nil
+}
+
+func formatLogTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (s *logSource) close() {
+	if err := s.store.Close(); err != nil {
+		log.Printf("⚠ 关闭决策记录存储失败: %v", err)
+	}
+}
+
+// traders 存储中的交易员ID
+func (s *logSource) traders() ([]string, error) {
+	ids, err := s.store.Traders()
+	if err != nil {
+		return nil, fmt.Errorf("读取决策目录失败: %w", err)
+	}
+	return ids, nil
+}
+
+// records 交易员在时间范围内的记录（按记录名排序）
+func (s *logSource) records(traderID string) ([]decisionlog.Record, error) {
+	return s.store.List(traderID, s.rng)
+}
+
+// location 记录位置（文件存储为文件路径）
+func (s *logSource) location(traderID, name string) string {
+	return s.store.Location(traderID, name)
+}
+
+// reportDir 旧版文本报告目录：文件存储为交易员日志目录，其余存储写入默认报告目录下的交易员子目录
+func (s *logSource) reportDir(traderID string) string {
+	if fs, ok := s.store.(*decisionlog.FileStore); ok {
+		return fs.Dir(traderID)
+	}
+	return filepath.Join(defaultReportDir, traderID)
+}
+
+// parseRecord 解析对账需要的字段，格式错误的记录返回 false（由 validate-logs 报告）
+func parseRecord(rec decisionlog.Record) (DecisionRecordPart, bool) {
+	var part DecisionRecordPart
+	if json.Unmarshal(rec.Data, &part) != nil {
+		return part, false
+	}
+	return part, true
+}
+
+// replaceDecisions 只替换记录的 decisions（其余字段保留），原内容先备份为 <name>.bak；返回改动前后的内容
+func (s *logSource) replaceDecisions(traderID, name string, acts []DecisionAction) (before, after []byte, err error) {
+	if before, err = s.store.Get(traderID, name); err != nil {
+		return nil, nil, err
+	}
+	if after, err = decisionlog.Render(before, acts); err != nil {
+		return before, nil, err
+	}
+	if err := s.store.Put(decisionlog.Record{TraderID: traderID, Name: name + ".bak", Data: before}); err != nil {
+		return before, nil, fmt.Errorf("备份失败: %w", err)
+	}
+	if err := s.store.Put(decisionlog.Record{TraderID: traderID, Name: name, Data: after}); err != nil {
+		return before, nil, err
+	}
+	return before, after, nil
+}
+
+// copyLogs 把当前存储中时间范围内的记录复制到目标存储（同名记录覆盖），用于在文件、SQLite 与对象存储之间迁移
+func copyLogs(src *logSource, target string) error {
+	if target == "" {
+		return fmt.Errorf("copy-logs 需要 -copy_to")
+	}
+	dst, err := decisionlog.Open(target)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	n, err := decisionlog.Copy(dst, src.store, src.rng)
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ 已复制 %d 条决策记录到 %s", n, target)
+	return nil
+}
+
+// defaultCompactBefore 未指定 -compact_before 时的归档截止时间：上个月月初（UTC），当月与上月的记录保留为单独的文件
+func defaultCompactBefore(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// compactLogs 把记录时间早于 beforeSpec 的决策日志文件按月归档（仅文件存储）
+func compactLogs(src *logSource, beforeSpec string, dryRun bool, rep *runReport) error {
+	store, ok := src.store.(*decisionlog.FileStore)
+	if !ok {
+		return fmt.Errorf("compact-logs 只支持文件存储")
+	}
+	before, err := parseLogTime("-compact_before", beforeSpec)
+	if err != nil {
+		return err
+	}
+	if before.IsZero() {
+		before = defaultCompactBefore(time.Now())
+	}
+	traders, err := src.traders()
+	if err != nil {
+		return err
+	}
+	log.Printf("ℹ 归档记录时间早于 %s 的决策日志", formatLogTime(before))
+	for _, traderID := range traders {
+		st, err := store.Compact(traderID, before, dryRun)
+		if err != nil {
+			msg := fmt.Sprintf("⚠ [%s] 归档失败: %v", traderID, err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
+			continue
+		}
+		stats := rep.stats(traderID)
+		stats.Files = st.Files
+		stats.Summary = fmt.Sprintf("归档 %d 个文件（%d 字节）到 %d 个月度归档，保留时间未知的文件 %d 个", st.Files, st.Bytes, len(st.Archives), st.Skipped)
+		if st.Files == 0 {
+			continue
+		}
+		verb := "已归档"
+		if dryRun {
+			verb = "[预演] 将归档"
+		}
+		log.Printf("🗜 [%s] %s %d 个文件（%d 字节）: %s", traderID, verb, st.Files, st.Bytes, strings.Join(st.Archives, ", "))
+	}
+	return rep.finish()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 日志格式（-log_format）
+//
+// text 为原有的带符号前缀的文本日志；json 时每条日志输出一行 JSON：
+// time/level/msg 之外附带 action（本次运行的 -action）、trader、symbol 与 result。
+// trader 取消息中第一个 [..]，symbol 取形如 BTCUSDT 的交易对，result 由消息前缀符号推断，前缀符号从 msg 中去掉。
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// logResults 消息前缀符号 → result 与级别
+var logResults = []struct {
+	prefix string
+	result string
+	level  slog.Level
+}{
+	{"✓", "ok", slog.LevelInfo},
+	{"✅", "ok", slog.LevelInfo},
+	{"⚠", "warning", slog.LevelWarn},
+	{"❌", "error", slog.LevelError},
+	{"🛑", "stopped", slog.LevelWarn},
+	{"➕", "corrected", slog.LevelInfo},
+	{"✏", "corrected", slog.LevelInfo},
+	{"🔧", "corrected", slog.LevelInfo},
+	{"📝", "mismatch", slog.LevelWarn},
+	{"🔀", "mismatch", slog.LevelWarn},
+	{"↩", "rolled_back", slog.LevelInfo},
+	{"⟲", "rolled_back", slog.LevelInfo},
+	{"⏭", "skipped", slog.LevelInfo},
+	{"⏸", "skipped", slog.LevelInfo},
+}
+
+var (
+	logBracketRe = regexp.MustCompile(`\[([^\]\s]+)\]`)
+	logLabelRe   = regexp.MustCompile(`^(?:\[[^\]]*\])+\s*`)
+	logSymbolRe  = regexp.MustCompile(`\b[A-Z0-9]{2,20}(?:USDT|USDC|BUSD|USD)\b`)
+)
+
+// setupLogging 按格式设置标准库 log 的输出
+func setupLogging(format, action string) error {
+	switch format {
+	case "", LogFormatText:
+		return nil
+	case LogFormatJSON:
+		h := &structuredLogHandler{Handler: slog.NewJSONHandler(os.Stderr, nil)}
+		// slog.SetDefault 之后 log.Printf 等也经由该 Handler 输出
+		slog.SetDefault(slog.New(h).With("action", action))
+		return nil
+	}
+	return fmt.Errorf("未知日志格式: %s（可选 text|json）", format)
+}
+
+// structuredLogHandler 从文本消息中提取 trader/symbol/result 字段
+type structuredLogHandler struct {
+	slog.Handler
+}
+
+func (h *structuredLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	msg, result, level := classifyLogMessage(r.Message)
+	out := slog.NewRecord(r.Time, max(r.Level, level), msg, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(a)
+		return true
+	})
+	if trader := logTrader(msg); trader != "" {
+		out.AddAttrs(slog.String("trader", trader))
+	}
+	if sym := logSymbolRe.FindString(msg); sym != "" {
+		out.AddAttrs(slog.String("symbol", sym))
+	}
+	out.AddAttrs(slog.String("result", result))
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *structuredLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &structuredLogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *structuredLogHandler) WithGroup(name string) slog.Handler {
+	return &structuredLogHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// classifyLogMessage 去掉前缀符号并推断 result；无前缀时含“失败”视为 error
+// 校正描述前的 [MAJOR][预演] 等标签保留，符号取标签之后的第一个
+func classifyLogMessage(msg string) (string, string, slog.Level) {
+	msg = strings.TrimSpace(msg)
+	label := logLabelRe.FindString(msg)
+	if label != "" && logTrader(label) != "" {
+		label = "" // 开头的方括号是交易员，不是标签
+	}
+	body := msg[len(label):]
+	result, level := "info", slog.LevelInfo
+	for _, lr := range logResults {
+		if strings.HasPrefix(body, lr.prefix) {
+			result, level = lr.result, lr.level
+			break
+		}
+	}
+	// 去掉前缀的符号、变体选择符与空格（只去非 ASCII 符号，保留 === 等文本）
+	stripped := strings.TrimLeftFunc(body, func(r rune) bool {
+		return r > unicode.MaxASCII && (unicode.IsSymbol(r) || unicode.Is(unicode.Mn, r) || r == 'ℹ') || unicode.IsSpace(r)
+	})
+	if result == "info" && stripped == body && strings.Contains(body, "失败") {
+		result, level = "error", slog.LevelError
+	}
+	return label + stripped, result, level
+}
+
+// logTrader 消息中第一个不是标签（严重级别、中文标签）的 [..] 内容
+func logTrader(msg string) string {
+	for _, m := range logBracketRe.FindAllStringSubmatch(msg, -1) {
+		v := m[1]
+		if isSeverityLabel(v) || strings.IndexFunc(v, func(r rune) bool { return unicode.Is(unicode.Han, r) }) >= 0 {
+			continue
+		}
+		return v
+	}
+	return ""
+}
+
+// isSeverityLabel [MAJOR]/[minor] 等校正级别标签
+func isSeverityLabel(s string) bool {
+	switch Severity(strings.ToLower(s)) {
+	case SeverityInfo, SeverityMinor, SeverityMajor:
+		return true
+	}
+	return false
+}
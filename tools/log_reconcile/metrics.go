@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 对账健康指标（常驻模式 GET /metrics，Prometheus 文本格式 0.0.4）
+//
+// 计数器在拉单、写日志、对账时累加；trader_lag_seconds 为距该交易员最近一次完整对账（拉单与对账均无失败）的秒数，
+// 持续增长说明日志与交易所状态在漂移。非常驻模式不创建指标，metrics 为 nil，各方法为空操作。
+
+// metrics 常驻模式的全局指标（nil 表示不采集）
+var metrics *reconcileMetrics
+
+// 指标名
+const (
+	metricOrdersFetched      = "log_reconcile_orders_fetched_total"
+	metricAPIErrors          = "log_reconcile_api_errors_total"
+	metricMismatches         = "log_reconcile_mismatches_total"
+	metricDecisionsCorrected = "log_reconcile_decisions_corrected_total"
+	metricFilesPatched       = "log_reconcile_files_patched_total"
+	metricCycles             = "log_reconcile_cycles_total"
+	metricPhaseFailures      = "log_reconcile_phase_failures_total"
+	metricTraderLag          = "log_reconcile_trader_lag_seconds"
+	metricLastSuccess        = "log_reconcile_trader_last_success_timestamp_seconds"
+)
+
+// metricDefs 指标类型与说明（按输出顺序）
+var metricDefs = []struct {
+	name string
+	typ  string
+	help string
+}{
+	{metricOrdersFetched, "counter", "Orders fetched from exchanges and stored, by trader and exchange."},
+	{metricAPIErrors, "counter", "Failed exchange fetches (orders, trades or income), by exchange."},
+	{metricMismatches, "counter", "Report-only mismatches and ledger violations found, by trader."},
+	{metricDecisionsCorrected, "counter", "Decision corrections applied, by trader and severity."},
+	{metricFilesPatched, "counter", "Decision log files rewritten or created, by trader and operation."},
+	{metricCycles, "counter", "Completed daemon cycles."},
+	{metricPhaseFailures, "counter", "Failed daemon phases, by phase."},
+	{metricTraderLag, "gauge", "Seconds since the trader was last fully reconciled without failures."},
+	{metricLastSuccess, "gauge", "Unix time of the trader's last full reconciliation without failures."},
+}
+
+// reconcileMetrics 计数器与各交易员最近一次完整对账时间
+type reconcileMetrics struct {
+	mu          sync.Mutex
+	counters    map[string]map[string]float64 // 指标名 -> 标签串 -> 值
+	lastSuccess map[string]time.Time
+}
+
+func newReconcileMetrics() *reconcileMetrics {
+	return &reconcileMetrics{counters: make(map[string]map[string]float64), lastSuccess: make(map[string]time.Time)}
+}
+
+// add 累加计数器，labels 为 键、值 交替
+func (m *reconcileMetrics) add(name string, v float64, labels ...string) {
+	if m == nil || v == 0 {
+		return
+	}
+	key := formatLabels(labels...)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counters[name] == nil {
+		m.counters[name] = make(map[string]float64)
+	}
+	m.counters[name][key] += v
+}
+
+// filePatched 记录一次日志文件改写/新建（交易员取文件所在目录名）
+func (m *reconcileMetrics) filePatched(path, op string) {
+	m.add(metricFilesPatched, 1, "trader", filepath.Base(filepath.Dir(path)), "op", op)
+}
+
+// observeReport 累加一次对账的不一致与已应用校正
+func (m *reconcileMetrics) observeReport(rep *runReport) {
+	if m == nil {
+		return
+	}
+	for _, e := range rep.entries {
+		switch e.Kind {
+		case ReportKindIssue, ReportKindViolation:
+			m.add(metricMismatches, 1, "trader", e.TraderID)
+		case ReportKindCorrection:
+			if e.Status == CorrectionStateApplied {
+				m.add(metricDecisionsCorrected, 1, "trader", e.TraderID, "severity", e.Severity)
+			}
+		}
+	}
+}
+
+// reconciled 记录交易员完整对账的时间（at 为本轮拉单开始时间，即数据的新鲜度）
+func (m *reconcileMetrics) reconciled(traderID string, at time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSuccess[traderID] = at
+}
+
+// writeTo 按 Prometheus 文本格式输出
+func (m *reconcileMetrics) writeTo(w io.Writer, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	traders := make([]string, 0, len(m.lastSuccess))
+	for t := range m.lastSuccess {
+		traders = append(traders, t)
+	}
+	sort.Strings(traders)
+	for _, def := range metricDefs {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", def.name, def.help, def.name, def.typ)
+		switch def.name {
+		case metricTraderLag:
+			for _, t := range traders {
+				fmt.Fprintf(w, "%s%s %g\n", def.name, formatLabels("trader", t), now.Sub(m.lastSuccess[t]).Seconds())
+			}
+		case metricLastSuccess:
+			for _, t := range traders {
+				fmt.Fprintf(w, "%s%s %d\n", def.name, formatLabels("trader", t), m.lastSuccess[t].Unix())
+			}
+		default:
+			series := m.counters[def.name]
+			keys := make([]string, 0, len(series))
+			for k := range series {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(w, "%s%s %g\n", def.name, k, series[k])
+			}
+		}
+	}
+}
+
+func (m *reconcileMetrics) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.writeTo(w, time.Now())
+}
+
+// formatLabels {k="v",...}（无标签时为空串）
+func formatLabels(kv ...string) string {
+	if len(kv) < 2 {
+		return ""
+	}
+	parts := make([]string, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, kv[i], escapeLabel(kv[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// escapeLabel 转义标签值中的 \ " 与换行
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 数据库结构迁移
+//
+// schema_version 记录已执行的迁移版本，启动时按版本号顺序执行未执行过的迁移，每个迁移在独立事务中完成。
+// 版本 1~4 对应引入版本管理前的建表语句（CREATE IF NOT EXISTS，对已有数据库无副作用）；
+// 已发布的迁移不再修改，新增列、索引或回填数据一律追加新版本，不要直接改动上面的建表常量。
+
+// schemaMigration 一次结构变更
+type schemaMigration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// schemaVersionTable 迁移记录表
+const schemaVersionTable = `CREATE TABLE IF NOT EXISTS schema_version(
+	version INTEGER PRIMARY KEY,
+	name TEXT,
+	applied_at INTEGER
+);`
+
+// schemaMigrations 全部迁移（版本号递增）
+var schemaMigrations = []schemaMigration{
+	{version: 1, name: "symbols/orders/reconcile_state", up: execSQL(createSchema)},
+	{version: 2, name: "trades/trade_state", up: execSQL(tradesSchema)},
+	{version: 3, name: "income/income_state", up: execSQL(incomeSchema)},
+	{version: 4, name: "correction_runs/correction_journal", up: execSQL(journalSchema)},
+	{version: 5, name: "orders.stop_price", up: func(tx *sql.Tx) error {
+		if err := addColumn(tx, "orders", "stop_price", "REAL DEFAULT 0"); err != nil {
+			return err
+		}
+		// 回填：币安为 stopPrice，Bybit 为 triggerPrice
+		_, err := tx.Exec(`UPDATE orders SET stop_price = COALESCE(CAST(NULLIF(json_extract(raw_json, '$.stopPrice'), '') AS REAL),
+			CAST(NULLIF(json_extract(raw_json, '$.triggerPrice'), '') AS REAL), 0)
+			WHERE json_valid(raw_json)`)
+		return err
+	}},
+	{version: 6, name: "reconcile_state.backfill_*", up: func(tx *sql.Tx) error {
+		for _, col := range []string{"backfill_from", "backfill_cursor", "backfill_until"} {
+			if err := addColumn(tx, "reconcile_state", col, "INTEGER DEFAULT 0"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{version: 7, name: "leverage_snapshots", up: execSQL(leverageSchema)},
+	{version: 8, name: "orders.client_order_id", up: func(tx *sql.Tx) error {
+		if err := addColumn(tx, "orders", "client_order_id", "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		// 回填：币安为 clientOrderId；OKX/Bybit 适配器已把 clOrdId/orderLinkId 转为 clientOrderId
+		if _, err := tx.Exec(`UPDATE orders SET client_order_id = COALESCE(json_extract(raw_json, '$.clientOrderId'), json_extract(raw_json, '$.clOrdId'),
+			json_extract(raw_json, '$.orderLinkId'), '') WHERE json_valid(raw_json)`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_orders_client_id ON orders(trader_id, client_order_id)`)
+		return err
+	}},
+	{version: 9, name: "fetch_runs", up: execSQL(fetchProgressSchema)},
+}
+
+// execSQL 执行固定的建表语句
+func execSQL(stmt string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmt)
+		return err
+	}
+}
+
+// addColumn 列不存在时追加（SQLite 不支持 ADD COLUMN IF NOT EXISTS）
+func addColumn(tx *sql.Tx, table, column, def string) error {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	exists := false
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil && strings.EqualFold(name, column) {
+			exists = true
+		}
+	}
+	rows.Close()
+	if exists {
+		return nil
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, def))
+	return err
+}
+
+// latestSchemaVersion 当前代码对应的结构版本
+func latestSchemaVersion() int {
+	return schemaMigrations[len(schemaMigrations)-1].version
+}
+
+// schemaVersion 数据库已执行的最高迁移版本（未做过迁移为 0）
+func schemaVersion(db *sql.DB) (int, error) {
+	var v int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("读取结构版本失败: %w", err)
+	}
+	return v, nil
+}
+
+// migrateSchema 执行未执行过的迁移
+// 数据库版本高于代码版本（由更新的工具写入）时拒绝运行，避免按旧结构读写
+func migrateSchema(db *sql.DB) error {
+	if _, err := db.Exec(schemaVersionTable); err != nil {
+		return fmt.Errorf("创建 schema_version 失败: %w", err)
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if latest := latestSchemaVersion(); current > latest {
+		return fmt.Errorf("数据库结构版本 v%d 高于当前工具支持的 v%d，请升级工具", current, latest)
+	}
+	for _, m := range schemaMigrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("迁移 v%d (%s) 失败: %w", m.version, m.name, err)
+		}
+		log.Printf("🗄 数据库结构升级到 v%d: %s", m.version, m.name)
+	}
+	return nil
+}
+
+// applyMigration 在事务中执行一个迁移并记录版本
+func applyMigration(db *sql.DB, m schemaMigration) error {
+	return writeTx(db, func(tx *sql.Tx) error {
+		if err := m.up(tx); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_version(version, name, applied_at) VALUES(?,?,?)`, m.version, m.name, time.Now().UnixMilli()); err != nil {
+			return fmt.Errorf("记录版本失败: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/notify"
+	"os"
+	"sort"
+)
+
+// 对账结果通知（-notify_config）
+//
+// 对账发现不一致或产生校正时，通过 nofx/notify 推送一条汇总（按交易员列出计数）。
+// 配置文件可直接使用主程序的 config.json（读取其中的 notify 字段），也可以是只包含 notify 配置的 JSON。
+
+// initNotify 读取通知配置并初始化全局通知器（path 为空时不推送）
+func initNotify(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取通知配置失败: %w", err)
+	}
+	var wrapper struct {
+		Notify *notify.Config `json:"notify"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return fmt.Errorf("解析通知配置失败: %w", err)
+	}
+	cfg := wrapper.Notify
+	if cfg == nil {
+		cfg = &notify.Config{}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("解析通知配置失败: %w", err)
+		}
+	}
+	if len(cfg.Channels) == 0 {
+		log.Printf("ℹ %s 中没有配置通知渠道", path)
+	}
+	return notify.Init(*cfg)
+}
+
+// notifyMismatches 本次运行有不一致或校正时推送汇总（预演不推送）
+func notifyMismatches(rep *runReport) {
+	sum := rep.summary()
+	if sum.DryRun || (sum.Mismatches == 0 && sum.Corrections == 0) {
+		return
+	}
+	fields := make(map[string]string)
+	ids := make([]string, 0, len(rep.traders))
+	for id := range rep.traders {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		t := rep.traders[id]
+		if t.Issues == 0 && t.Corrections == 0 && t.Failures == 0 {
+			continue
+		}
+		fields[id] = fmt.Sprintf("不一致=%d 校正=%d(已应用 %d) 失败=%d", t.Issues, t.Corrections, t.Applied, t.Failures)
+	}
+	notify.Send(notify.Event{
+		Type:    notify.EventReconcileMismatch,
+		Title:   fmt.Sprintf("🧾 对账 %s 发现 %d 项不一致、%d 条校正", sum.Action, sum.Mismatches, sum.Corrections),
+		Message: fmt.Sprintf("已应用 %d 条，待处理 %d 条，处理失败 %d 项", sum.Applied, sum.Pending, sum.Failures),
+		Fields:  fields,
+	})
+}
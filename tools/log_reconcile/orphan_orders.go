@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 孤立订单检测
+//
+// 常规对账从决策出发查找订单；这里反向检查：交易所已成交的开仓单（非 reduceOnly）与平仓单，
+// 在容差时间窗口内没有同一交易对、同一方向的决策，也未被日志引用，视为手动下单或异常下单。
+// 默认只报告；开启 -synthesize_orphans 时按严重级别 major 走校正策略，补写 decision_reconcile_* 文件。
+
+// orphanOrder 没有对应决策的成交订单
+type orphanOrder struct {
+	Order  BinanceOrder
+	Side   string // 仓位方向 LONG/SHORT
+	Action string // 推断的决策动作
+}
+
+// classifyOrder 推断成交订单对应的决策动作与仓位方向（无法归属时返回空）
+func classifyOrder(o *BinanceOrder) (action, side string) {
+	for _, s := range []string{"LONG", "SHORT"} {
+		if orderOpensPosition(o, s) {
+			return "open_" + strings.ToLower(s), s
+		}
+		if orderClosesPosition(o, s) {
+			// 与补全平仓一致：closePosition 视为全平，其余减仓单按 partial_close
+			if o.ClosePosition {
+				return "close_" + strings.ToLower(s), s
+			}
+			return "partial_close", s
+		}
+	}
+	return "", ""
+}
+
+// orphanMatchesDecision 窗口内是否有同一交易对、同一方向的决策
+func orphanMatchesDecision(acts []ledgerAction, o *BinanceOrder, action, side string, window int64) bool {
+	for _, a := range acts {
+		if a.Symbol != o.Symbol || abs64(o.Time-a.Timestamp.UnixMilli()) > window {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(action, "open_"):
+			if a.Action == action {
+				return true
+			}
+		case a.Action == "partial_close":
+			// 部分平仓日志可能未记录方向，窗口内的任意减仓单都可能与之对应
+			return true
+		case isCloseAction(a.Action) && sideFromAction(a.Action) == side:
+			return true
+		}
+	}
+	return false
+}
+
+// findOrphanOrders 查找交易员没有对应决策的成交订单（按时间排序）
+// 只检查第一条决策之前一个窗口起的订单，更早的订单不属于日志覆盖范围
+func findOrphanOrders(orders map[string][]BinanceOrder, traderID string, acts []ledgerAction, logged map[int64]bool, tols *matchTolerances) []orphanOrder {
+	if len(acts) == 0 {
+		return nil
+	}
+	first := acts[0].Timestamp
+	for _, a := range acts[1:] {
+		if a.Timestamp.Before(first) {
+			first = a.Timestamp
+		}
+	}
+	prefix := traderID + "_"
+	seen := make(map[int64]bool)
+	var res []orphanOrder
+	for key, list := range orders {
+		// 键为 trader_symbol_positionSide，交易对不含下划线
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || strings.Count(rest, "_") != 1 {
+			continue
+		}
+		for i := range list {
+			o := list[i]
+			if logged[o.OrderID] || seen[o.OrderID] {
+				continue
+			}
+			action, side := classifyOrder(&o)
+			if action == "" {
+				continue
+			}
+			rule := tols.forAction(traderID, action)
+			if o.Time < first.UnixMilli()-rule.windowMs() || !rule.accepts(&o) {
+				continue
+			}
+			seen[o.OrderID] = true
+			if orphanMatchesDecision(acts, &o, action, side, rule.windowMs()) {
+				continue
+			}
+			res = append(res, orphanOrder{Order: o, Side: side, Action: action})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Order.Time < res[j].Order.Time })
+	return res
+}
+
+// describeOrphan 报告用的订单描述（条件单触发时注明类型）
+func describeOrphan(traderID string, oo orphanOrder) string {
+	o := &oo.Order
+	kind := o.Type
+	if triggerAction(o) != "" {
+		kind = cmp.Or(o.OrigType, o.Type) + " 条件单触发"
+	}
+	return fmt.Sprintf("👻 [%s] %s_%s 孤立订单: %s %s 在 %s 成交 (订单ID: %d, 数量: %s, 价格: %.4f, 类型: %s)，窗口内无对应决策，疑似手动或异常下单",
+		traderID, o.Symbol, oo.Side, oo.Action, o.Side, time.UnixMilli(o.Time).Format("2006-01-02 15:04:05"), o.OrderID, o.ExecutedQty, safePrice(o), kind) + fillSummary(o)
+}
@@ -2,12 +2,11 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"math"
-	"os"
-	"path/filepath"
+	"nofx/decisions"
+	"nofx/tools/log_reconcile/decisionlog"
 	"sort"
 	"strings"
 	"time"
@@ -26,16 +25,9 @@ type PartialCloseAction struct {
 	Timestamp       time.Time `json:"timestamp"`
 	Success         bool      `json:"success"`
 	Error           string    `json:"error"`
-}
 
-// DecisionJSONItem 决策JSON中的单个决策项
-type DecisionJSONItem struct {
-	Symbol          string  `json:"symbol"`
-	Action          string  `json:"action"`
-	ClosePercentage float64 `json:"close_percentage,omitempty"`
-	Confidence      float64 `json:"confidence,omitempty"`
-	Reasoning       string  `json:"reasoning"`
-	Price           float64 `json:"price,omitempty"`
+	record string // 所在的决策记录名
+	index  int    // 在记录 decisions 中的下标
 }
 
 // PositionTracker 仓位跟踪器
@@ -51,8 +43,9 @@ type PositionTracker struct {
 	FullCloseQty  float64
 }
 
-// reconcilePartialClose 对账部分平仓
-func reconcilePartialClose(db *sql.DB, decisionDir string) error {
+// reconcilePartialClose 对账部分平仓，数量/价格偏差与 OrderID 不一致按校正策略写回日志
+// （dry 非 nil 时只输出拟执行的变更，不修改任何文件；jr 记录改写的决策文件，供 rollback 使用）
+func reconcilePartialClose(db *sql.DB, src *logSource, gate *correctionGate, tols *matchTolerances, dry *dryRun, rep *runReport, jr *journal) error {
 	log.Println("=== 开始部分平仓对账 ===")
 
 	// 读取订单缓存
@@ -62,74 +55,66 @@ func reconcilePartialClose(db *sql.DB, decisionDir string) error {
 	}
 
 	// 遍历 trader 子目录
-	entries, err := os.ReadDir(decisionDir)
+	traders, err := src.traders()
 	if err != nil {
-		return fmt.Errorf("读取决策目录失败: %w", err)
+		return err
 	}
 
-	for _, ent := range entries {
-		if !ent.IsDir() {
-			continue
-		}
-		traderID := ent.Name()
-		traderPath := filepath.Join(decisionDir, traderID)
-		if err := reconcilePartialCloseForTrader(traderPath, traderID, ordersMap); err != nil {
-			log.Printf("⚠ 对账 %s 部分平仓失败: %v", traderPath, err)
+	for _, traderID := range traders {
+		traderPath := src.location(traderID, "")
+		if err := reconcilePartialCloseForTrader(src, traderID, ordersMap, gate, tols.forAction(traderID, "partial_close"), dry, rep, jr); err != nil {
+			msg := fmt.Sprintf("⚠ 对账 %s 部分平仓失败: %v", traderPath, err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
 		}
 	}
+	gate.logSummary()
+	if err := rep.finish(); err != nil {
+		return err
+	}
 
+	if dry != nil {
+		return dry.flush()
+	}
 	return nil
 }
 
-// reconcilePartialCloseForTrader 针对单个 trader 处理部分平仓
-func reconcilePartialCloseForTrader(dir string, traderID string, orders map[string][]BinanceOrder) error {
-	files, err := os.ReadDir(dir)
+// reconcilePartialCloseForTrader 针对单个 trader 处理部分平仓（rule 为该交易员 partial_close 的匹配容差）
+func reconcilePartialCloseForTrader(src *logSource, traderID string, orders map[string][]BinanceOrder, gate *correctionGate, rule MatchTolerance, dry *dryRun, rep *runReport, jr *journal) error {
+	// 收集所有日志记录（按记录名即时间排序）
+	records, err := src.records(traderID)
 	if err != nil {
 		return err
 	}
-
-	// 收集所有日志文件
-	var logFiles []string
-	for _, f := range files {
-		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
-			continue
-		}
-		logFiles = append(logFiles, filepath.Join(dir, f.Name()))
-	}
-
-	// 按时间排序文件
-	sort.Strings(logFiles)
+	stats := rep.stats(traderID)
+	stats.Files = len(records)
 
 	// 构建仓位时间线
 	positions := make(map[string]*PositionTracker) // key = symbol_side
 
 	// 构建决策映射 (timestamp_symbol -> DecisionJSON)
-	decisionMap := make(map[string][]DecisionJSONItem)
+	decisionMap := make(map[string][]decisions.Item)
+	recordActions := make(map[string][]DecisionAction) // 记录名 -> 全部动作（写回校正时使用）
 
-	for _, fp := range logFiles {
-		data, err := os.ReadFile(fp)
-		if err != nil {
-			continue
-		}
-		var rec DecisionRecordPart
-		if json.Unmarshal(data, &rec) != nil {
+	for _, r := range records {
+		rec, ok := parseRecord(r)
+		if !ok {
 			continue
 		}
+		recordActions[r.Name] = rec.Decisions
 
 		// 解析 decision_json 字段
-		if rec.DecisionJSON != "" {
-			var decisionItems []DecisionJSONItem
-			if err := json.Unmarshal([]byte(rec.DecisionJSON), &decisionItems); err == nil {
-				// 使用时间戳作为key
-				tsKey := rec.Timestamp.Format("2006-01-02T15:04:05")
-				decisionMap[tsKey] = decisionItems
-			}
+		if decisionItems := parseDecisionPlans(rec.DecisionJSON); decisionItems != nil {
+			// 使用时间戳作为key
+			tsKey := rec.Timestamp.Format("2006-01-02T15:04:05")
+			decisionMap[tsKey] = decisionItems
 		}
 
-		for _, act := range rec.Decisions {
+		for idx, act := range rec.Decisions {
 			if !act.Success {
 				continue
 			}
+			stats.Actions++
 
 			// 开仓
 			if act.Action == "open_long" || act.Action == "open_short" {
@@ -159,8 +144,19 @@ func reconcilePartialCloseForTrader(dir string, traderID string, orders map[stri
 					}
 				}
 
-				// 尝试两种可能
+				// 当时持仓的方向；双向持仓同时持有多空时按订单 positionSide 判断归属
+				var openSides []string
 				for _, side := range []string{"LONG", "SHORT"} {
+					if pos, exists := positions[act.Symbol+"_"+side]; exists && pos.FullCloseTime.IsZero() {
+						openSides = append(openSides, side)
+					}
+				}
+				side := resolvePartialCloseSide(orders, traderID, act.Symbol, act.Timestamp, rule.Window, openSides)
+				if side == "" && len(openSides) > 1 {
+					log.Printf("⚠ [%s] %s partial_close 同时持有多空且无法按订单判断方向，跳过 (时间: %s)",
+						traderID, act.Symbol, act.Timestamp.Format("2006-01-02 15:04:05"))
+				}
+				if side != "" {
 					key := act.Symbol + "_" + side
 					if pos, exists := positions[key]; exists && pos.FullCloseTime.IsZero() {
 						partialClose := PartialCloseAction{
@@ -172,10 +168,11 @@ func reconcilePartialCloseForTrader(dir string, traderID string, orders map[stri
 							OrderID:         act.OrderID,
 							Timestamp:       act.Timestamp,
 							Success:         act.Success,
+							record:          r.Name,
+							index:           idx,
 						}
 						pos.PartialCloses = append(pos.PartialCloses, partialClose)
 						pos.TotalClosed += act.Quantity
-						break
 					}
 				}
 			}
@@ -192,15 +189,33 @@ func reconcilePartialCloseForTrader(dir string, traderID string, orders map[stri
 		}
 	}
 
-	// 对账部分平仓
+	// 对账部分平仓；校正按策略决定是否写回，改动按记录汇总后统一写入
 	var issues []string
+	changed := make(map[string][]DecisionAction) // 记录名 -> 校正后的动作
+	record := func(c Correction) bool {
+		applied := gate.allow(c)
+		rep.correction(c, gate.state(applied), applied)
+		issues = append(issues, gate.label(c, applied)+" "+c.Description)
+		return applied
+	}
+	correct := func(pc PartialCloseAction, fix func(*DecisionAction)) {
+		acts, ok := changed[pc.record]
+		if !ok {
+			acts = append([]DecisionAction(nil), recordActions[pc.record]...)
+		}
+		fix(&acts[pc.index])
+		changed[pc.record] = acts
+	}
 	for key, pos := range positions {
 		if len(pos.PartialCloses) == 0 {
 			continue // 没有部分平仓，跳过
 		}
 
-		ordKey := traderID + "_" + key
-		ordList := orders[ordKey]
+		// 优先使用对应 positionSide 的订单，单向模式回退 BOTH
+		var ordList []BinanceOrder
+		for _, l := range getOrderLists(orders, traderID, pos.Symbol, pos.Side) {
+			ordList = append(ordList, l...)
+		}
 		if len(ordList) == 0 {
 			continue
 		}
@@ -209,52 +224,64 @@ func reconcilePartialCloseForTrader(dir string, traderID string, orders map[stri
 		for i, pc := range pos.PartialCloses {
 			matched := false
 			for _, o := range ordList {
-				// 时间匹配：±30分钟（使用 decisions 中的实际成交时间）
+				// 时间匹配：默认 ±30分钟（使用 decisions 中的实际成交时间）
 				// decisions[].timestamp 是实际下单成交时间，更接近币安订单时间
-				if math.Abs(float64(o.Time-pc.Timestamp.UnixMilli())) > 30*60*1000 {
+				if math.Abs(float64(o.Time-pc.Timestamp.UnixMilli())) > float64(rule.windowMs()) {
 					continue
 				}
-				// 必须是 reduceOnly 或 closePosition
-				if !o.ReduceOnly && !o.ClosePosition {
+				// 必须是该仓位的平仓/减仓单（双向持仓按 positionSide+side 判断）
+				if !orderClosesPosition(&o, pos.Side) {
 					continue
 				}
-				// 必须是 FILLED
-				if strings.ToUpper(o.Status) != "FILLED" {
-					continue
-				}
-				// Side 匹配
-				if !matchCloseSide(pos.Side, o.Side) {
+				// 订单状态可接受，且有成交数量与价格
+				if !rule.accepts(&o) {
 					continue
 				}
 
 				qty := parseFloat(o.ExecutedQty)
 				price := safePrice(&o)
-				if qty <= 0 || price <= 0 {
-					continue
-				}
 
 				// 检查是否匹配
 				qtyDev := deviation(pc.Quantity, qty)
 				priceDev := deviation(pc.Price, price)
 
-				if qtyDev > 0.05 || priceDev > 0.05 {
-					issues = append(issues, fmt.Sprintf(
-						"📝 [%s] %s partial_close #%d 数据偏差: 数量 %.4f→%.4f (%.2f%%), 价格 %.4f→%.4f (%.2f%%), 时间: %s",
-						traderID, key, i+1, pc.Quantity, qty, qtyDev*100, pc.Price, price, priceDev*100,
-						pc.Timestamp.Format("2006-01-02 15:04:05")))
-				} else if pc.OrderID != o.OrderID {
-					issues = append(issues, fmt.Sprintf(
-						"🔧 [%s] %s partial_close #%d OrderID不匹配: %d→%d, 时间: %s",
-						traderID, key, i+1, pc.OrderID, o.OrderID, pc.Timestamp.Format("2006-01-02 15:04:05")))
+				orderID := o.OrderID
+				if qtyDev > rule.QtyDev || priceDev > rule.PriceDev {
+					if record(Correction{
+						TraderID: traderID,
+						Symbol:   pc.Symbol,
+						Action:   pc.Action,
+						Severity: SeverityMinor,
+						Description: fmt.Sprintf("📝 [%s] %s partial_close #%d 数据偏差: 数量 %.4f→%.4f (%.2f%%), 价格 %.4f→%.4f (%.2f%%), 时间: %s",
+							traderID, key, i+1, pc.Quantity, qty, qtyDev*100, pc.Price, price, priceDev*100,
+							pc.Timestamp.Format("2006-01-02 15:04:05")),
+					}) {
+						correct(pc, func(a *DecisionAction) {
+							a.Quantity, a.Price, a.OrderID = qty, price, orderID
+						})
+					}
+				} else if pc.OrderID != orderID {
+					if record(Correction{
+						TraderID: traderID,
+						Symbol:   pc.Symbol,
+						Action:   pc.Action,
+						Severity: SeverityInfo,
+						Description: fmt.Sprintf("🔧 [%s] %s partial_close #%d OrderID不匹配: %d→%d, 时间: %s",
+							traderID, key, i+1, pc.OrderID, orderID, pc.Timestamp.Format("2006-01-02 15:04:05")),
+					}) {
+						correct(pc, func(a *DecisionAction) { a.OrderID = orderID })
+					}
 				}
 				matched = true
 				break
 			}
 
 			if !matched {
-				issues = append(issues, fmt.Sprintf(
+				msg := fmt.Sprintf(
 					"⚠ [%s] %s partial_close #%d 未找到匹配订单: 数量 %.4f, 价格 %.4f, 时间: %s",
-					traderID, key, i+1, pc.Quantity, pc.Price, pc.Timestamp.Format("2006-01-02 15:04:05")))
+					traderID, key, i+1, pc.Quantity, pc.Price, pc.Timestamp.Format("2006-01-02 15:04:05"))
+				rep.issue(traderID, msg)
+				issues = append(issues, msg)
 			}
 		}
 
@@ -263,29 +290,62 @@ func reconcilePartialCloseForTrader(dir string, traderID string, orders map[stri
 		if !pos.FullCloseTime.IsZero() {
 			// 有完全平仓记录，检查是否匹配剩余数量
 			qtyDev := deviation(expectedRemaining, pos.FullCloseQty)
-			if qtyDev > 0.05 {
-				issues = append(issues, fmt.Sprintf(
+			if qtyDev > rule.QtyDev {
+				msg := fmt.Sprintf(
 					"⚠ [%s] %s 累计平仓数量不匹配: 开仓 %.4f - 部分平仓 %.4f = 预期剩余 %.4f, 实际完全平仓 %.4f (偏差 %.2f%%)",
-					traderID, key, pos.OpenQty, pos.TotalClosed, expectedRemaining, pos.FullCloseQty, qtyDev*100))
+					traderID, key, pos.OpenQty, pos.TotalClosed, expectedRemaining, pos.FullCloseQty, qtyDev*100)
+				rep.issue(traderID, msg)
+				issues = append(issues, msg)
 			}
 		}
 	}
 
+	// 写回已批准的校正（与 reconcile 相同：原内容备份为 .bak，只替换 decisions）
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fp := src.location(traderID, name)
+		acts := changed[name]
+		if dry != nil {
+			data, err := src.store.Get(traderID, name)
+			if err != nil {
+				log.Printf("⚠ 读取文件失败 %s: %v", fp, err)
+				continue
+			}
+			before, err := decisionlog.Render(data, recordActions[name])
+			if err != nil {
+				log.Printf("⚠ 生成校正内容失败 %s: %v", fp, err)
+				continue
+			}
+			updated, err := decisionlog.Render(data, acts)
+			if err != nil {
+				log.Printf("⚠ 生成校正内容失败 %s: %v", fp, err)
+				continue
+			}
+			dry.modify(fp, before, updated, recordActions[name], acts)
+			continue
+		}
+		if before, after, err := src.replaceDecisions(traderID, name, acts); err != nil {
+			msg := fmt.Sprintf("⚠ 覆盖文件失败 %s: %v", fp, err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
+		} else {
+			jr.modify(fp, before, after)
+			log.Printf("✏ 已校正文件 %s", fp)
+		}
+	}
+
 	// 输出报告
 	if len(issues) > 0 {
-		reportPath := filepath.Join(dir, fmt.Sprintf("partial_close_report_%s.txt", time.Now().Format("20060102_150405")))
-		reportContent := strings.Join(append([]string{
+		rep.writeTrader(src.reportDir(traderID), traderID, "partial_close_report", []string{
 			"=== 部分平仓对账报告 ===",
 			fmt.Sprintf("生成时间: %s", time.Now().Format("2006-01-02 15:04:05")),
 			fmt.Sprintf("Trader ID: %s", traderID),
 			"",
-		}, issues...), "\n")
-
-		if err := os.WriteFile(reportPath, []byte(reportContent), 0644); err != nil {
-			log.Printf("⚠ 写入部分平仓报告失败: %v", err)
-		} else {
-			log.Printf("📊 [%s] 已生成部分平仓报告: %s (%d 条)", traderID, reportPath, len(issues))
-		}
+		}, issues)
 
 		// 输出到日志
 		for _, msg := range issues {
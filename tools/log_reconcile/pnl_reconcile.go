@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 已实现盈亏对账（pnl-reconcile）
+//
+// 拉单时同时拉取 /fapi/v1/income（REALIZED_PNL、COMMISSION、FUNDING_FEE）存入 income 表；
+// pnl-reconcile 按决策日志重建每个仓位（开仓均价、各次平仓价格与数量）计算已实现盈亏，
+// 与仓位存续期间交易所记录的 REALIZED_PNL 合计比对，偏差超出容差时写入报告。
+
+// 收益类型
+const (
+	IncomeRealizedPnl = "REALIZED_PNL"
+	IncomeCommission  = "COMMISSION"
+	IncomeFundingFee  = "FUNDING_FEE"
+)
+
+const (
+	// incomeLimit 单次请求的最大记录数
+	incomeLimit = 1000
+	// incomeInitialLookback 首次拉取的回看时长
+	incomeInitialLookback = 30 * 24 * time.Hour
+	// pnlMatchWindow 平仓后计入仓位的收益记录时间窗口（决策时间与成交时间存在延迟）
+	pnlMatchWindow = 5 * time.Minute
+	// pnlAbsTolerance 盈亏偏差绝对容差（计价资产）
+	pnlAbsTolerance = 1.0
+	// pnlRelTolerance 盈亏偏差相对容差（相对交易所已实现盈亏）
+	pnlRelTolerance = 0.05
+)
+
+// incomeSchema 收益表与增量状态
+const incomeSchema = `CREATE TABLE IF NOT EXISTS income(
+	trader_id TEXT,
+	symbol TEXT,
+	tran_id INTEGER,
+	income_type TEXT,
+	income REAL,
+	asset TEXT,
+	info TEXT,
+	trade_id TEXT,
+	time INTEGER,
+	raw_json TEXT,
+	UNIQUE(trader_id, tran_id, income_type)
+);
+CREATE INDEX IF NOT EXISTS idx_income_symbol ON income(trader_id, symbol, time);
+CREATE TABLE IF NOT EXISTS income_state(
+	trader_id TEXT PRIMARY KEY,
+	last_time INTEGER,
+	last_fetch_time INTEGER
+);`
+
+// BinanceIncome income 接口返回的一条收益记录
+type BinanceIncome struct {
+	Symbol     string `json:"symbol"`
+	IncomeType string `json:"incomeType"`
+	Income     string `json:"income"`
+	Asset      string `json:"asset"`
+	Info       string `json:"info"`
+	Time       int64  `json:"time"`
+	TranID     int64  `json:"tranId"`
+	TradeID    string `json:"tradeId"`
+}
+
+// incomeRecord 库中的收益记录
+type incomeRecord struct {
+	Type   string
+	Income float64
+	Time   int64
+}
+
+// isTrackedIncome 只保存对账需要的收益类型
+func isTrackedIncome(t string) bool {
+	return t == IncomeRealizedPnl || t == IncomeCommission || t == IncomeFundingFee
+}
+
+// incomeWeight income 的请求权重（fapi 为 30，dapi 为 20）
+func (c *binanceREST) incomeWeight() int {
+	if c.usdm() {
+		return 30
+	}
+	return 20
+}
+
+// Income 调用 income 接口（按时间窗口，结果按时间升序）
+func (c *binanceREST) Income(startTime, endTime int64) ([]BinanceIncome, []map[string]any, error) {
+	ctx := context.Background()
+	if err := c.limiter.wait(ctx, c.incomeWeight()); err != nil {
+		return nil, nil, err
+	}
+
+	var params []string
+	if startTime > 0 {
+		params = append(params, fmt.Sprintf("startTime=%d", startTime))
+	}
+	if endTime > 0 {
+		params = append(params, fmt.Sprintf("endTime=%d", endTime))
+	}
+	params = append(params, fmt.Sprintf("limit=%d", incomeLimit))
+	params = append(params, "recvWindow=5000")
+	params = append(params, fmt.Sprintf("timestamp=%d", time.Now().UnixMilli()))
+	qs := strings.Join(params, "&")
+	sig := hmacSHA256Hex(qs, c.secretKey)
+	path := c.path("income")
+	url := fmt.Sprintf("%s%s?%s&signature=%s", c.baseURL, path, qs, sig)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req.Header.Set("X-MBX-APIKEY", c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	c.limiter.observe(resp)
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var raw []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	list := make([]BinanceIncome, 0, len(raw))
+	rawKept := make([]map[string]any, 0, len(raw))
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var in BinanceIncome
+		if json.Unmarshal(b, &in) == nil {
+			list = append(list, in)
+			rawKept = append(rawKept, r)
+		}
+	}
+	return list, rawKept, nil
+}
+
+// fetchIncomeForTrader 增量拉取账户收益记录并写入 income 表（income 按账户返回，不区分交易对）
+func fetchIncomeForTrader(db *sql.DB, client exchangeClient, traderID string) error {
+	st := time.Now()
+	var lastTime sql.NullInt64
+	_ = db.QueryRow(`SELECT last_time FROM income_state WHERE trader_id = ?`, traderID).Scan(&lastTime)
+
+	end := time.Now().UnixMilli()
+	start := end - incomeInitialLookback.Milliseconds()
+	if lastTime.Valid && lastTime.Int64 > 0 {
+		// 同一毫秒可能有多条记录，从上次最后时间（含）开始，重复记录由唯一约束去重
+		start = lastTime.Int64
+	}
+
+	var all []BinanceIncome
+	var rawAll []map[string]any
+	for {
+		list, raw, err := client.Income(start, end)
+		if err != nil {
+			return err
+		}
+		all = append(all, list...)
+		rawAll = append(rawAll, raw...)
+		if len(list) < incomeLimit {
+			break
+		}
+		next := list[len(list)-1].Time
+		if next <= start {
+			// 整页都在同一毫秒，跳过该毫秒避免死循环
+			next = start + 1
+		}
+		start = next
+	}
+	if len(all) == 0 {
+		return nil
+	}
+
+	saved := 0
+	if err := writeTx(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`INSERT OR IGNORE INTO income(trader_id, symbol, tran_id, income_type, income, asset, info, trade_id, time, raw_json)
+			VALUES(?,?,?,?,?,?,?,?,?,?)`)
+		if err != nil {
+			return fmt.Errorf("准备语句失败: %w", err)
+		}
+		defer stmt.Close()
+
+		var maxTime int64
+		for i, in := range all {
+			maxTime = max(maxTime, in.Time)
+			if !isTrackedIncome(in.IncomeType) {
+				continue
+			}
+			b, _ := json.Marshal(rawAll[i])
+			if _, e := stmt.Exec(traderID, in.Symbol, in.TranID, in.IncomeType, parseFloat(in.Income), in.Asset, in.Info, in.TradeID, in.Time, string(b)); e != nil {
+				log.Printf("⚠ 写入收益记录失败 [%s] tran_id=%d: %v", traderID, in.TranID, e)
+				continue
+			}
+			saved++
+		}
+		_, err = tx.Exec(`INSERT OR REPLACE INTO income_state(trader_id, last_time, last_fetch_time) VALUES(?,?,?)`,
+			traderID, maxTime, time.Now().UnixMilli())
+		if err != nil {
+			return fmt.Errorf("更新收益状态失败: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	log.Printf("✓ [%s] 增量拉取收益记录 %d 条（保存 %d 条）, 用时 %v", traderID, len(all), saved, time.Since(st))
+	return nil
+}
+
+// loadIncomeGrouped 按 trader_id+symbol 分组收益记录（按时间排序）
+func loadIncomeGrouped(db *sql.DB) (map[string][]incomeRecord, error) {
+	rows, err := db.Query(`SELECT trader_id, symbol, income_type, income, time FROM income ORDER BY time`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[string][]incomeRecord)
+	for rows.Next() {
+		var traderID, symbol string
+		var r incomeRecord
+		if err := rows.Scan(&traderID, &symbol, &r.Type, &r.Income, &r.Time); err != nil {
+			continue
+		}
+		key := traderID + "_" + symbol
+		res[key] = append(res[key], r)
+	}
+	return res, rows.Err()
+}
+
+// pnlPosition 由决策日志重建的一个仓位
+type pnlPosition struct {
+	Symbol    string
+	Side      string // LONG/SHORT
+	OpenTime  time.Time
+	CloseTime time.Time
+	Qty       float64 // 当前剩余数量
+	AvgPrice  float64 // 开仓均价（加仓时加权）
+	Realized  float64 // 按决策价格计算的已实现盈亏
+	Closes    int
+	Skipped   int // 缺少价格/数量而无法计入的平仓
+}
+
+// close 按平仓价格与数量累计已实现盈亏
+func (p *pnlPosition) close(price, qty float64) {
+	if price <= 0 || qty <= 0 {
+		p.Skipped++
+		return
+	}
+	qty = math.Min(qty, p.Qty)
+	if p.Side == "LONG" {
+		p.Realized += (price - p.AvgPrice) * qty
+	} else {
+		p.Realized += (p.AvgPrice - price) * qty
+	}
+	p.Qty -= qty
+	p.Closes++
+}
+
+// pnlSummary 仓位期间交易所收益合计
+type pnlSummary struct {
+	Realized   float64
+	Commission float64
+	Funding    float64
+	Records    int
+}
+
+// sumIncome 汇总 [from, to] 内的收益记录
+func sumIncome(records []incomeRecord, from, to time.Time) pnlSummary {
+	var s pnlSummary
+	lo, hi := from.UnixMilli(), to.UnixMilli()
+	idx := sort.Search(len(records), func(i int) bool { return records[i].Time >= lo })
+	for ; idx < len(records) && records[idx].Time <= hi; idx++ {
+		r := records[idx]
+		switch r.Type {
+		case IncomeRealizedPnl:
+			s.Realized += r.Income
+			s.Records++
+		case IncomeCommission:
+			s.Commission += r.Income
+		case IncomeFundingFee:
+			s.Funding += r.Income
+		}
+	}
+	return s
+}
+
+// reconcilePnl 对账已实现盈亏（只生成报告，不修改决策日志；dry 非 nil 时不写报告文件）
+func reconcilePnl(db *sql.DB, src *logSource, dry *dryRun, rep *runReport) error {
+	log.Println("=== 开始已实现盈亏对账 ===")
+	incomes, err := loadIncomeGrouped(db)
+	if err != nil {
+		return fmt.Errorf("加载收益记录失败: %w", err)
+	}
+	if len(incomes) == 0 {
+		log.Printf("ℹ income 表为空，请先执行 fetch-orders-db（或 fetch-orders）拉取收益记录")
+	}
+	traders, err := src.traders()
+	if err != nil {
+		return err
+	}
+	for _, traderID := range traders {
+		traderPath := src.location(traderID, "")
+		if err := reconcilePnlForTrader(src, traderID, incomes, rep); err != nil {
+			msg := fmt.Sprintf("⚠ 盈亏对账 %s 失败: %v", traderPath, err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
+		}
+	}
+	if err := rep.finish(); err != nil {
+		return err
+	}
+	if dry != nil {
+		return dry.flush()
+	}
+	return nil
+}
+
+// reconcilePnlForTrader 重建单个交易员的仓位并与交易所已实现盈亏比对
+func reconcilePnlForTrader(src *logSource, traderID string, incomes map[string][]incomeRecord, rep *runReport) error {
+	stats := rep.stats(traderID)
+	positions, err := rebuildPnlPositions(src, traderID, stats)
+	if err != nil {
+		return err
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+
+	var lines []string
+	issues := 0
+	var totalLog, totalExchange, totalCommission, totalFunding float64
+	for _, p := range positions {
+		s := sumIncome(incomes[traderID+"_"+p.Symbol], p.OpenTime, p.CloseTime.Add(pnlMatchWindow))
+		totalLog += p.Realized
+		totalExchange += s.Realized
+		totalCommission += s.Commission
+		totalFunding += s.Funding
+
+		period := fmt.Sprintf("%s ~ %s", p.OpenTime.Format("2006-01-02 15:04:05"), p.CloseTime.Format("2006-01-02 15:04:05"))
+		diff := p.Realized - s.Realized
+		switch {
+		case s.Records == 0:
+			issues++
+			lines = append(lines, fmt.Sprintf("⚠ [%s] %s %s (%s) 交易所无已实现盈亏记录，日志计算 %.4f",
+				traderID, p.Symbol, p.Side, period, p.Realized))
+			rep.issue(traderID, lines[len(lines)-1])
+		case math.Abs(diff) > math.Max(pnlAbsTolerance, math.Abs(s.Realized)*pnlRelTolerance):
+			issues++
+			lines = append(lines, fmt.Sprintf("⚠ [%s] %s %s (%s) 已实现盈亏不一致: 日志 %.4f, 交易所 %.4f, 偏差 %.4f (手续费 %.4f, 资金费 %.4f)",
+				traderID, p.Symbol, p.Side, period, p.Realized, s.Realized, diff, s.Commission, s.Funding))
+			rep.issue(traderID, lines[len(lines)-1])
+		}
+		if p.Skipped > 0 {
+			lines = append(lines, fmt.Sprintf("ℹ [%s] %s %s (%s) 有 %d 次平仓缺少价格或数量，未计入日志盈亏",
+				traderID, p.Symbol, p.Side, period, p.Skipped))
+			rep.note(traderID, lines[len(lines)-1])
+		}
+	}
+
+	summary := fmt.Sprintf("📊 [%s] 已平仓位 %d 个, 不一致 %d 个: 日志盈亏 %.4f, 交易所盈亏 %.4f, 手续费 %.4f, 资金费 %.4f, 交易所净盈亏 %.4f",
+		traderID, len(positions), issues, totalLog, totalExchange, totalCommission, totalFunding, totalExchange+totalCommission+totalFunding)
+	log.Print(summary)
+	stats.Summary = fmt.Sprintf("仓位 %d, 日志盈亏 %.4f, 交易所盈亏 %.4f, 手续费 %.4f, 资金费 %.4f",
+		len(positions), totalLog, totalExchange, totalCommission, totalFunding)
+	if len(lines) == 0 {
+		log.Printf("✓ [%s] 已实现盈亏对账通过，无异常", traderID)
+		return nil
+	}
+
+	rep.writeTrader(src.reportDir(traderID), traderID, "pnl_reconcile_report", []string{
+		"=== 已实现盈亏对账报告 ===",
+		fmt.Sprintf("生成时间: %s", time.Now().Format("2006-01-02 15:04:05")),
+		fmt.Sprintf("Trader ID: %s", traderID),
+		summary,
+		"",
+	}, lines)
+	for _, msg := range lines {
+		log.Println(msg)
+	}
+	return nil
+}
+
+// rebuildPnlPositions 按时间顺序回放决策日志，返回已完全平仓的仓位
+func rebuildPnlPositions(src *logSource, traderID string, stats *TraderStats) ([]*pnlPosition, error) {
+	records, err := src.records(traderID)
+	if err != nil {
+		return nil, err
+	}
+	var acts []DecisionAction
+	for _, r := range records {
+		stats.Files++
+		rec, ok := parseRecord(r)
+		if !ok {
+			continue
+		}
+		for _, act := range rec.Decisions {
+			if act.Success {
+				acts = append(acts, act)
+				stats.Actions++
+			}
+		}
+	}
+	sort.SliceStable(acts, func(i, j int) bool { return acts[i].Timestamp.Before(acts[j].Timestamp) })
+
+	open := make(map[string]*pnlPosition) // key = symbol_side
+	var closed []*pnlPosition
+	finish := func(key string, p *pnlPosition, at time.Time) {
+		p.CloseTime = at
+		closed = append(closed, p)
+		delete(open, key)
+	}
+	for _, act := range acts {
+		switch {
+		case act.Action == "open_long" || act.Action == "open_short":
+			side := sideFromAction(act.Action)
+			key := act.Symbol + "_" + side
+			p, ok := open[key]
+			if !ok {
+				p = &pnlPosition{Symbol: act.Symbol, Side: side, OpenTime: act.Timestamp}
+				open[key] = p
+			}
+			// 加仓按数量加权开仓均价
+			if total := p.Qty + act.Quantity; total > 0 {
+				p.AvgPrice = (p.AvgPrice*p.Qty + act.Price*act.Quantity) / total
+				p.Qty = total
+			}
+		case act.Action == "partial_close":
+			// 只在单一持仓方向时计入（同时持有多空无法从日志判断归属）
+			var key string
+			for _, side := range []string{"LONG", "SHORT"} {
+				if _, ok := open[act.Symbol+"_"+side]; ok {
+					if key != "" {
+						key = ""
+						break
+					}
+					key = act.Symbol + "_" + side
+				}
+			}
+			if p, ok := open[key]; ok {
+				p.close(act.Price, act.Quantity)
+			}
+		case isCloseAction(act.Action):
+			key := act.Symbol + "_" + sideFromAction(act.Action)
+			p, ok := open[key]
+			if !ok {
+				continue
+			}
+			qty := act.Quantity
+			if qty <= 0 {
+				// 全平未记录数量时按剩余数量计
+				qty = p.Qty
+			}
+			p.close(act.Price, qty)
+			finish(key, p, act.Timestamp)
+		}
+	}
+	sort.Slice(closed, func(i, j int) bool { return closed[i].CloseTime.Before(closed[j].CloseTime) })
+	return closed, nil
+}
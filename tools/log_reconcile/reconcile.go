@@ -11,10 +11,13 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"io/fs"
 	"log"
 	"math"
 	"net/http"
+	"nofx/config"
+	"nofx/decisions"
+	"nofx/notify"
+	"nofx/tools/log_reconcile/decisionlog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -34,15 +37,16 @@ type DecisionRecordPart struct {
 
 // DecisionAction from logger
 type DecisionAction struct {
-	Action    string    `json:"action"`
-	Symbol    string    `json:"symbol"`
-	Quantity  float64   `json:"quantity"`
-	Leverage  int       `json:"leverage"`
-	Price     float64   `json:"price"`
-	OrderID   int64     `json:"order_id"`
-	Timestamp time.Time `json:"timestamp"`
-	Success   bool      `json:"success"`
-	Error     string    `json:"error"`
+	Action        string    `json:"action"`
+	Symbol        string    `json:"symbol"`
+	Quantity      float64   `json:"quantity"`
+	Leverage      int       `json:"leverage"`
+	Price         float64   `json:"price"`
+	OrderID       int64     `json:"order_id"`
+	ClientOrderID string    `json:"client_order_id,omitempty"` // 下单时指定的 clientOrderId（有则优先精确匹配订单）
+	Timestamp     time.Time `json:"timestamp"`
+	Success       bool      `json:"success"`
+	Error         string    `json:"error"`
 }
 
 // BinanceOrder 简化结构（含原始 JSON）
@@ -72,14 +76,17 @@ type BinanceOrder struct {
 	WorkingType      string `json:"workingType"`
 	PriceMatch       string `json:"priceMatch"`
 	SelfTradePrevent string `json:"selfTradePreventionMode"`
+
+	// Fill 来自 trades 表的成交汇总（无成交记录时为 nil）
+	Fill *orderFill `json:"-"`
 }
 
 // 常量
 const (
 	defaultInterval = 3 * time.Second
-	// 订单与决策时间匹配窗口（毫秒）
-	timeToleranceMs = 30 * 60 * 1000
-	createSchema    = `CREATE TABLE IF NOT EXISTS symbols(
+	// allOrdersLimit 币安 allOrders 单次返回的订单数
+	allOrdersLimit = 100
+	createSchema   = `CREATE TABLE IF NOT EXISTS symbols(
 	trader_id TEXT,
 	symbol TEXT,
 	first_seen INTEGER,
@@ -116,27 +123,143 @@ CREATE TABLE IF NOT EXISTS reconcile_state(
 func main() {
 	var action string
 	var decisionDir string
+	var decisionStore string
+	var logFrom string
+	var logTo string
+	var copyTo string
+	var compactBefore string
 	var dbPath string
 	var apiKey string
 	var secretKey string
 	var intervalSec int
+	var workers int
+	var perKey int
+	var weightPerMin int
 	var base string
+	var baseURL string
+	var testnet bool
 	var configDBPath string
+	var notifyConfig string
 	var userID string
 	var exchangeID string
-
-	flag.StringVar(&action, "action", "scan-symbols", "scan-symbols|fetch-orders|fetch-orders-db|reconcile|partial-close-reconcile")
+	var policySpec string
+	var dryRunFlag bool
+	var dryRunFormat string
+	var withTrades bool
+	var withIncome bool
+	var reportFormat string
+	var reportDir string
+	var daemonMode bool
+	var runID string
+	var force bool
+	var scheduleSpec string
+	var statusAddr string
+	var toleranceConfig string
+	var okxPassphrase string
+	var timeWindow string
+	var qtyDev float64
+	var priceDev float64
+	var acceptStatus string
+	var backfillFromSpec string
+	var logFormat string
+	var synthOrphans bool
+	var resume bool
+	var streamURL string
+
+	flag.StringVar(&action, "action", "scan-symbols", "scan-symbols|fetch-orders|fetch-orders-db|reconcile|partial-close-reconcile|pnl-reconcile|ledger|validate-logs|stats|rollback|encrypt-credentials|listen|copy-logs|compact-logs")
 	flag.StringVar(&decisionDir, "decision_dir", "decision_logs", "决策日志根目录")
+	flag.StringVar(&decisionStore, "decision_store", "", "决策记录存储: 目录 | sqlite:<文件> | s3://<bucket>/<prefix>?region=..&endpoint=..（留空使用 -decision_dir）")
+	flag.StringVar(&logFrom, "log_from", "", "只读取记录时间不早于该时间的决策记录（2006-01-02 UTC 或 RFC3339）")
+	flag.StringVar(&logTo, "log_to", "", "只读取记录时间早于该时间的决策记录（2006-01-02 UTC 或 RFC3339）")
+	flag.StringVar(&copyTo, "copy_to", "", "copy-logs 的目标存储（格式同 -decision_store）")
+	flag.StringVar(&compactBefore, "compact_before", "", "compact-logs 归档记录时间早于该时间的文件（2006-01-02 UTC 或 RFC3339，默认上个月月初）")
 	flag.StringVar(&dbPath, "db", filepath.Join("tools", "log_reconcile", "reconcile.db"), "数据库文件路径")
 	flag.StringVar(&apiKey, "api_key", "", "币安 API Key")
 	flag.StringVar(&secretKey, "secret_key", "", "币安 Secret Key")
-	flag.IntVar(&intervalSec, "interval_sec", 3, "拉取间隔秒")
-	flag.StringVar(&base, "base", "fapi", "fapi 或 dapi")
+	flag.IntVar(&intervalSec, "interval_sec", 0, "同一 API Key 两次拉取之间的最小间隔秒（0 表示仅受权重限速约束）")
+	flag.IntVar(&workers, "workers", defaultFetchWorkers, "同时拉取的客户端数量（fetch-orders-db 中每个交易员一个客户端）")
+	flag.IntVar(&perKey, "per_key", defaultFetchPerKey, "每个 API Key 的最大并发请求数")
+	flag.IntVar(&weightPerMin, "weight_per_min", defaultWeightPerMinute, "拉单每分钟可用的币安请求权重（IP 上限 2400）")
+	flag.BoolVar(&withTrades, "with_trades", true, "拉单时同时拉取 userTrades 成交（对账使用成交均价、手续费与已实现盈亏）")
+	flag.BoolVar(&withIncome, "with_income", true, "拉单后同时拉取账户收益记录（REALIZED_PNL/COMMISSION/FUNDING_FEE，供 pnl-reconcile 使用）")
+	flag.StringVar(&base, "base", "fapi", "币安合约类型: fapi 或 dapi（OKX/Bybit 固定为 USDT 永续）")
+	flag.StringVar(&baseURL, "base_url", "", "币安接口根地址（地区镜像或代理，如 https://fapi.example.com），留空使用官方地址")
+	flag.BoolVar(&testnet, "testnet", false, "使用币安合约测试网 "+binanceTestnetURL+"（不能与 -base_url 同时使用）")
+	flag.StringVar(&streamURL, "stream_url", "", "listen 使用的用户数据流地址（如 wss://fstream.binance.com），留空按 -base/-testnet 推断，自定义 -base_url 时必填")
+	flag.StringVar(&okxPassphrase, "okx_passphrase", "", "OKX API passphrase（config.db 的 exchanges 未保存 passphrase 时使用）")
+	flag.StringVar(&backfillFromSpec, "backfill_from", "", "历史订单回补起始日期（如 2025-09-01，UTC），按 7 天窗口回补到本地最早订单，进度可续传；留空只拉最近 7 天")
 	flag.StringVar(&configDBPath, "config_db", "config.db", "配置数据库文件路径(读取交易员与密钥)")
+	flag.StringVar(&notifyConfig, "notify_config", "", "通知配置文件（如主程序的 config.json，读取其中的 notify 字段），发现不一致时推送汇总")
 	flag.StringVar(&userID, "user_id", "default", "配置库中的用户ID")
 	flag.StringVar(&exchangeID, "exchange_id", "", "回退模式下使用的交易所ID（如: binance），当没有交易员绑定时生效")
+	flag.StringVar(&policySpec, "policy", "", "校正策略，按严重级别配置处理方式，如: info=auto,minor=auto,major=approve（方式: auto|report|approve，默认全部 auto）")
+	flag.BoolVar(&dryRunFlag, "dry_run", false, "预演模式：reconcile/partial-close-reconcile/pnl-reconcile/encrypt-credentials/compact-logs 只输出拟执行的变更，不修改任何文件")
+	flag.StringVar(&dryRunFormat, "dry_run_format", DryRunFormatDiff, "预演输出格式: diff（unified diff）| json（变更列表）")
+	flag.StringVar(&reportFormat, "report_format", ReportFormatTxt, "对账报告格式: txt|csv|json|html")
+	flag.StringVar(&reportDir, "report_dir", "", "对账报告目录，每次运行新建 <action>_<时间> 子目录（txt 格式留空时写入各交易员日志目录；其余格式默认 "+defaultReportDir+"）")
+	flag.BoolVar(&daemonMode, "daemon", false, "常驻模式：按 -schedule 循环执行 scan-symbols → fetch-orders-db → reconcile（忽略 -action）")
+	flag.StringVar(&scheduleSpec, "schedule", "@every 30m", "常驻模式调度: @every <间隔> | @hourly | @daily | 5 段 cron（分 时 日 月 周）")
+	flag.StringVar(&statusAddr, "status_addr", "127.0.0.1:8790", "常驻模式状态接口监听地址（GET /status，留空不启动）")
+	flag.StringVar(&runID, "run_id", "", "rollback 要回滚的运行ID（留空为最近一次有改动且未回滚的运行）")
+	flag.BoolVar(&force, "force", false, "rollback 时跳过“文件在该次运行后已被修改”的校验")
+	flag.StringVar(&toleranceConfig, "tolerance_config", "", "匹配容差配置文件（.json 或 .yaml），可按动作、按交易员覆盖")
+	flag.StringVar(&timeWindow, "time_window", "", "订单与决策的时间匹配窗口，如 30m（默认 30m，覆盖配置文件 default）")
+	flag.Float64Var(&qtyDev, "qty_dev", 0, "数量偏差阈值，如 0.01 表示 1%（默认开/平仓 0.01、partial_close 0.05）")
+	flag.Float64Var(&priceDev, "price_dev", 0, "价格偏差阈值（默认开/平仓 0.01、partial_close 0.05）")
+	flag.StringVar(&acceptStatus, "accept_status", "", "可接受的订单状态，逗号分隔，如 FILLED,PARTIALLY_FILLED（默认开/平仓仅 FILLED）")
+	flag.BoolVar(&synthOrphans, "synthesize_orphans", false, "reconcile 时把没有对应决策的成交订单（手动/异常下单）补写为 decision_reconcile_* 文件（默认只报告）")
+	flag.BoolVar(&resume, "resume", false, "fetch-orders/fetch-orders-db 续传最近一次未完成的拉单运行，跳过已完成的交易员/交易对")
+	flag.StringVar(&logFormat, "log_format", LogFormatText, "日志格式: text | json（每行一条 JSON，含 action/trader/symbol/result 字段）")
 	flag.Parse()
 
+	logAction := action
+	if daemonMode {
+		logAction = "daemon"
+	}
+	if err := setupLogging(logFormat, logAction); err != nil {
+		log.Fatalf("%v", err)
+	}
+	policy, err := parseCorrectionPolicy(policySpec)
+	if err != nil {
+		log.Fatalf("解析校正策略失败: %v", err)
+	}
+	// 只有显式设置的容差参数才覆盖配置文件
+	override := &toleranceRule{TimeWindow: timeWindow, AcceptStatus: parseStatusList(acceptStatus)}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "qty_dev":
+			override.QtyDeviation = &qtyDev
+		case "price_dev":
+			override.PriceDeviation = &priceDev
+		}
+	})
+	tols, err := loadTolerances(toleranceConfig, override)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	backfillFrom, err := parseBackfillFrom(backfillFromSpec)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	endpoint, err := resolveBinanceEndpoint(base, baseURL, testnet)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	var dry *dryRun
+	if dryRunFlag {
+		if daemonMode {
+			log.Fatalf("常驻模式不支持 -dry_run")
+		}
+		if dry, err = newDryRun(dryRunFormat); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	rep, err := newRunReport(action, reportFormat, reportDir, dry)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		log.Fatalf("创建目录失败: %v", err)
 	}
@@ -156,84 +279,205 @@ func main() {
 		log.Fatalf("初始化表失败: %v", err)
 	}
 
+	src, err := openLogSource(decisionStore, decisionDir, logFrom, logTo)
+	if err != nil {
+		log.Fatalf("打开决策记录存储失败: %v", err)
+	}
+	defer src.close()
+
+	if err := initNotify(notifyConfig); err != nil {
+		log.Fatalf("%v", err)
+	}
+	defer notify.Close()
+
+	if daemonMode {
+		pool := newFetchPool(workers, perKey, weightPerMin, time.Duration(intervalSec)*time.Second)
+		pool.withTrades = withTrades
+		pool.withIncome = withIncome
+		pool.backfillFrom = backfillFrom
+		d, err := newDaemon(db, pool, daemonConfig{
+			schedule:      scheduleSpec,
+			statusAddr:    statusAddr,
+			logs:          src,
+			configDBPath:  configDBPath,
+			userID:        userID,
+			exchangeID:    exchangeID,
+			base:          endpoint,
+			okxPassphrase: okxPassphrase,
+			policy:        policy,
+			tolerances:    tols,
+			reportFormat:  reportFormat,
+			reportDir:     reportDir,
+			synthOrphans:  synthOrphans,
+		})
+		if err != nil {
+			log.Fatalf("启动常驻模式失败: %v", err)
+		}
+		if err := d.run(); err != nil {
+			log.Fatalf("常驻模式异常退出: %v", err)
+		}
+		return
+	}
+	defer finishRun(db, rep)
+
 	switch action {
 	case "scan-symbols":
-		if err := scanSymbols(db, decisionDir); err != nil {
+		if err := scanSymbols(db, src); err != nil {
 			log.Fatalf("扫描失败: %v", err)
 		}
 	case "fetch-orders":
 		if apiKey == "" || secretKey == "" {
 			log.Fatalf("fetch-orders 需要 api_key 与 secret_key")
 		}
-		if err := fetchOrdersLoop(db, apiKey, secretKey, time.Duration(intervalSec)*time.Second, base); err != nil {
+		pool := newFetchPool(workers, perKey, weightPerMin, time.Duration(intervalSec)*time.Second)
+		pool.withTrades = withTrades
+		pool.withIncome = withIncome
+		pool.backfillFrom = backfillFrom
+		pool.report = rep
+		if pool.progress, err = beginFetchProgress(db, action, resume); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := fetchOrdersLoop(db, apiKey, secretKey, pool, endpoint); err != nil {
 			log.Fatalf("拉取订单失败: %v", err)
 		}
+		pool.progress.finish()
 	case "fetch-orders-db":
-		if err := fetchOrdersFromConfigDB(db, configDBPath, userID, exchangeID, time.Duration(intervalSec)*time.Second, base); err != nil {
+		pool := newFetchPool(workers, perKey, weightPerMin, time.Duration(intervalSec)*time.Second)
+		pool.withTrades = withTrades
+		pool.withIncome = withIncome
+		pool.backfillFrom = backfillFrom
+		pool.report = rep
+		if pool.progress, err = beginFetchProgress(db, action, resume); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := fetchOrdersFromConfigDB(db, configDBPath, userID, exchangeID, pool, endpoint, okxPassphrase); err != nil {
 			log.Fatalf("从配置库拉取订单失败: %v", err)
 		}
+		pool.progress.finish()
 	case "reconcile":
-		if err := reconcileLogs(db, decisionDir); err != nil {
+		gate := newCorrectionGate(policy)
+		gate.dryRun = dry != nil
+		var jr *journal
+		if dry == nil {
+			if jr, err = beginJournal(db, action); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		err := reconcileLogs(db, src, gate, tols, synthOrphans, dry, rep, jr)
+		jr.finish()
+		if err != nil {
 			log.Fatalf("对账失败: %v", err)
 		}
 	case "partial-close-reconcile":
-		if err := reconcilePartialClose(db, decisionDir); err != nil {
+		gate := newCorrectionGate(policy)
+		gate.dryRun = dry != nil
+		var jr *journal
+		if dry == nil {
+			if jr, err = beginJournal(db, action); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		err := reconcilePartialClose(db, src, gate, tols, dry, rep, jr)
+		jr.finish()
+		if err != nil {
 			log.Fatalf("部分平仓对账失败: %v", err)
 		}
+	case "pnl-reconcile":
+		if err := reconcilePnl(db, src, dry, rep); err != nil {
+			log.Fatalf("盈亏对账失败: %v", err)
+		}
+	case "stats":
+		if err := runStats(db, src, rep); err != nil {
+			log.Fatalf("绩效统计失败: %v", err)
+		}
+	case "validate-logs":
+		if err := validateLogs(src, rep); err != nil {
+			log.Fatalf("日志格式校验失败: %v", err)
+		}
+	case "ledger":
+		if err := validateLedgers(src, rep); err != nil {
+			log.Fatalf("仓位台账校验失败: %v", err)
+		}
+		if dry != nil {
+			_ = dry.flush()
+		}
+	case "rollback":
+		if err := rollbackRun(db, src, runID, force); err != nil {
+			log.Fatalf("回滚失败: %v", err)
+		}
+	case "encrypt-credentials":
+		if err := encryptConfigCredentials(configDBPath, dry != nil); err != nil {
+			log.Fatalf("加密凭据失败: %v", err)
+		}
+	case "listen":
+		wsURL, err := resolveStreamURL(endpoint, streamURL)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := listenUserStreams(db, configDBPath, userID, endpoint, wsURL); err != nil {
+			log.Fatalf("监听用户数据流失败: %v", err)
+		}
+	case "copy-logs":
+		if err := copyLogs(src, copyTo); err != nil {
+			log.Fatalf("复制决策记录失败: %v", err)
+		}
+	case "compact-logs":
+		if err := compactLogs(src, compactBefore, dry != nil, rep); err != nil {
+			log.Fatalf("归档决策日志失败: %v", err)
+		}
 	default:
 		log.Fatalf("未知 action: %s", action)
 	}
 }
 
+// finishRun 输出运行汇总并按结果设置退出码（os.Exit 不执行其余 defer，先关闭数据库）
+func finishRun(db *sql.DB, rep *runReport) {
+	notifyMismatches(rep)
+	notify.Close()
+	sum := rep.summary()
+	if err := sum.write(os.Stdout); err != nil {
+		log.Printf("⚠ 输出运行汇总失败: %v", err)
+	}
+	db.Close()
+	os.Exit(sum.ExitCode)
+}
+
 func initSchema(db *sql.DB) error {
-	_, err := db.Exec(createSchema)
-	return err
+	return migrateSchema(db)
 }
 
 // scanSymbols 扫描日志目录收集开仓交易对
-func scanSymbols(db *sql.DB, decisionDir string) error {
+func scanSymbols(db *sql.DB, src *logSource) error {
 	totalCollected := 0 // 总共遇到的符号次数
-	err := filepath.WalkDir(decisionDir, func(path string, d fs.DirEntry, err error) error {
+	traders, err := src.traders()
+	if err != nil {
+		return err
+	}
+	for _, traderID := range traders {
+		recs, err := src.records(traderID)
 		if err != nil {
 			return err
 		}
-		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
-			return nil
-		}
-		// 提取 trader_id (目录名)
-		relPath, _ := filepath.Rel(decisionDir, path)
-		parts := strings.Split(filepath.ToSlash(relPath), "/")
-		if len(parts) < 2 {
-			return nil // 不在子目录中,跳过
-		}
-		traderID := parts[0]
-
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-		var rec DecisionRecordPart
-		if json.Unmarshal(data, &rec) != nil {
-			return nil
-		}
-		for _, act := range rec.Decisions {
-			if !act.Success {
+		for _, r := range recs {
+			rec, ok := parseRecord(r)
+			if !ok {
 				continue
 			}
-			if act.Action == "open_long" || act.Action == "open_short" {
-				symbol := strings.TrimSpace(act.Symbol)
-				if symbol == "" {
+			for _, act := range rec.Decisions {
+				if !act.Success {
 					continue
 				}
-				totalCollected++
-				_, _ = db.Exec(`INSERT OR IGNORE INTO symbols(trader_id, symbol, first_seen) VALUES(?,?,?)`,
-					traderID, symbol, time.Now().UnixMilli())
+				if act.Action == "open_long" || act.Action == "open_short" {
+					symbol := strings.TrimSpace(act.Symbol)
+					if symbol == "" {
+						continue
+					}
+					totalCollected++
+					_, _ = db.Exec(`INSERT OR IGNORE INTO symbols(trader_id, symbol, first_seen) VALUES(?,?,?)`,
+						traderID, symbol, time.Now().UnixMilli())
+				}
 			}
 		}
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 
 	// 统计去重后的实际符号数
@@ -245,63 +489,50 @@ func scanSymbols(db *sql.DB, decisionDir string) error {
 	return nil
 }
 
-// fetchOrdersLoop 按顺序轮询 symbols 表
-func fetchOrdersLoop(db *sql.DB, apiKey, secretKey string, interval time.Duration, base string) error {
+// fetchOrdersLoop 并发拉取 symbols 表中所有交易对的订单
+func fetchOrdersLoop(db *sql.DB, apiKey, secretKey string, pool *fetchPool, base binanceEndpoint) error {
 	rows, err := db.Query(`SELECT trader_id, symbol FROM symbols ORDER BY trader_id, symbol`)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 	client := newSignedClient(apiKey, secretKey, base)
+	var tasks []fetchTask
 	for rows.Next() {
 		var traderID, symbol string
 		if err := rows.Scan(&traderID, &symbol); err != nil {
 			continue
 		}
-		if err := fetchOrdersForSymbol(db, client, traderID, symbol); err != nil {
-			log.Printf("⚠ 拉取 [%s] %s 失败: %v", traderID, symbol, err)
-		}
-		log.Printf("等待 %v 后继续...", interval)
-		time.Sleep(interval)
+		tasks = append(tasks, fetchTask{traderID: traderID, symbol: symbol, client: client})
+	}
+	// 先读完游标再并发拉取，避免读游标与写事务相互阻塞
+	if err := rows.Close(); err != nil {
+		return err
 	}
+	pool.run(db, tasks)
 	return nil
 }
 
 // fetchOrdersFromConfigDB 读取 config.db 中的交易员与密钥，按交易员隔离拉取其 symbols 的订单
-func fetchOrdersFromConfigDB(reconcileDB *sql.DB, configDBPath, userID, exchangeID string, interval time.Duration, base string) error {
-	cfgDB, err := sql.Open("sqlite", configDBPath)
+// okxPassphrase 为 config.db 未保存 passphrase 时 OKX 使用的 passphrase
+func fetchOrdersFromConfigDB(reconcileDB *sql.DB, configDBPath, userID, exchangeID string, pool *fetchPool, base binanceEndpoint, okxPassphrase string) error {
+	cfgDB, err := config.OpenDatabase(configDBPath)
 	if err != nil {
-		return fmt.Errorf("打开配置数据库失败: %w", err)
+		return err
 	}
 	defer cfgDB.Close()
 
-	// 读取所有使用 binance 的交易员及其密钥（忽略空密钥）
-	rows, err := cfgDB.Query(`
- 		SELECT t.id AS trader_id, e.api_key, e.secret_key
- 		FROM traders t
- 		JOIN exchanges e ON t.exchange_id = e.id AND t.user_id = e.user_id
- 		WHERE t.user_id = ?
- 		  AND (LOWER(e.id)='binance' OR LOWER(e.name) LIKE '%binance%' OR LOWER(e.type) IN ('binance','cex'))
- 		  AND COALESCE(e.api_key,'') <> '' AND COALESCE(e.secret_key,'') <> ''
- 		ORDER BY t.id
- 	`, userID)
+	creds, failedTasks, err := queryTraderCredentials(cfgDB, userID)
 	if err != nil {
-		return fmt.Errorf("查询交易员密钥失败: %w", err)
+		return err
 	}
-	defer rows.Close()
 
 	log.Printf("🔎 从配置库读取交易员与密钥: db=%s, user_id=%s, base=%s", configDBPath, userID, base)
 	foundTraders := 0
 	processedSymbols := 0
-	failedTasks := 0
+	var tasks []fetchTask
 
-	for rows.Next() {
-		var traderID, apiKey, secretKey string
-		if err := rows.Scan(&traderID, &apiKey, &secretKey); err != nil {
-			failedTasks++
-			log.Printf("⚠ 读取交易员行失败: %v", err)
-			continue
-		}
+	for _, c := range creds {
+		traderID, exID, exName, apiKey, secretKey, passphrase := c.traderID, c.exchangeID, c.exchangeName, c.apiKey, c.secretKey, c.passphrase
 		foundTraders++
 		// 查询该交易员的所有已扫描 symbol
 		var symCount int
@@ -314,14 +545,18 @@ func fetchOrdersFromConfigDB(reconcileDB *sql.DB, configDBPath, userID, exchange
 			log.Printf("ℹ 交易员 %s 尚未扫描到任何符号，请先执行: go run ./tools/log_reconcile -action scan-symbols", traderID)
 			continue
 		}
-		log.Printf("▶ 开始拉取交易员 %s（%d 个符号）", traderID, symCount)
+		kind := exchangeKind(exID, exName)
+		if passphrase == "" {
+			passphrase = okxPassphrase
+		}
+		log.Printf("▶ 加入交易员 %s（%s，%d 个符号）", traderID, kind, symCount)
 
 		symRows, err := reconcileDB.Query(`SELECT symbol FROM symbols WHERE trader_id = ? ORDER BY symbol`, traderID)
 		if err != nil {
 			log.Printf("⚠ 读取交易员 %s 的符号失败: %v", traderID, err)
 			continue
 		}
-		client := newSignedClient(apiKey, secretKey, base)
+		client := newExchangeClient(kind, apiKey, secretKey, passphrase, base)
 		for symRows.Next() {
 			var symbol string
 			if err := symRows.Scan(&symbol); err != nil {
@@ -329,81 +564,24 @@ func fetchOrdersFromConfigDB(reconcileDB *sql.DB, configDBPath, userID, exchange
 				log.Printf("⚠ 解析符号行失败: %v", err)
 				continue
 			}
-			if err := fetchOrdersForSymbol(reconcileDB, client, traderID, symbol); err != nil {
-				log.Printf("⚠ 拉取 [%s] %s 失败: %v", traderID, symbol, err)
-				failedTasks++
-			}
-			log.Printf("等待 %v 后继续...", interval)
-			time.Sleep(interval)
-			processedSymbols++
+			tasks = append(tasks, fetchTask{traderID: traderID, symbol: symbol, client: client})
 		}
 		_ = symRows.Close()
 	}
+	// 各交易员使用各自的密钥，可在同一个池中并发拉取
+	processed, failed := pool.run(reconcileDB, tasks)
+	processedSymbols += processed
+	failedTasks += failed
 
 	if foundTraders == 0 {
-		log.Printf("ℹ 未找到绑定到交易员的 Binance 密钥，尝试回退到按交易所拉取...")
+		log.Printf("ℹ 未找到绑定到交易员的交易所密钥，尝试回退到按 Binance 账户拉取...")
 		// 回退：直接使用 exchanges 中的 binance 账户对所有已扫描的 trader_id 拉取
-		var exRows *sql.Rows
-		var errEx error
-		exs := make([]struct{ id, api, sec string }, 0)
-		if strings.TrimSpace(exchangeID) != "" {
-			exRows, errEx = cfgDB.Query(`SELECT id, api_key, secret_key FROM exchanges WHERE user_id = ? AND id = ? AND COALESCE(api_key,'')<>'' AND COALESCE(secret_key,'')<>''`, userID, exchangeID)
-		} else {
-			exRows, errEx = cfgDB.Query(`
-				SELECT id, api_key, secret_key FROM exchanges 
-				WHERE user_id = ? 
-				  AND (LOWER(id)='binance' OR LOWER(name) LIKE '%binance%' OR LOWER(type) IN ('binance','cex'))
-				  AND COALESCE(api_key,'')<>'' AND COALESCE(secret_key,'')<>''
-				ORDER BY id`, userID)
-			if errEx == nil {
-				// 若按指定 user_id 未找到，放宽为任意 user_id
-				hasAny := false
-				defer func() {
-					if !hasAny && exRows != nil {
-						_ = exRows.Close()
-					}
-				}()
-				tmpList := make([]struct{ id, api, sec string }, 0)
-				for exRows.Next() {
-					hasAny = true
-					var id, a, s string
-					_ = exRows.Scan(&id, &a, &s)
-					tmpList = append(tmpList, struct{ id, api, sec string }{id, a, s})
-				}
-				_ = exRows.Close()
-				if !hasAny {
-					log.Printf("ℹ 未在 user_id=%s 下找到 Binance 账户，尝试跨用户查找...", userID)
-					exRows, errEx = cfgDB.Query(`
-						SELECT id, api_key, secret_key FROM exchanges 
-						WHERE (LOWER(id)='binance' OR LOWER(name) LIKE '%binance%' OR LOWER(type) IN ('binance','cex'))
-						  AND COALESCE(api_key,'')<>'' AND COALESCE(secret_key,'')<>''
-						ORDER BY user_id, id`)
-				} else {
-					// 复用已读取的列表，避免重复游标遍历
-					for _, it := range tmpList {
-						exs = append(exs, it)
-					}
-					// 置 exRows 为 nil 表示已填充 exs
-					exRows = nil
-				}
-			}
-		}
+		exs, errEx := binanceAccounts(cfgDB, userID, exchangeID)
 		if errEx != nil {
 			log.Printf("⚠ 查询交易所密钥失败: %v", errEx)
 			log.Printf("✅ 完成: 交易员=%d, 符号处理=%d, 错误=%d", foundTraders, processedSymbols, failedTasks)
 			return nil
 		}
-		if exRows != nil {
-			defer exRows.Close()
-		}
-		if exRows != nil {
-			for exRows.Next() {
-				var id, a, s string
-				if err := exRows.Scan(&id, &a, &s); err == nil {
-					exs = append(exs, struct{ id, api, sec string }{id, a, s})
-				}
-			}
-		}
 		log.Printf("🔐 匹配到 Binance 账户数: %d", len(exs))
 		if len(exs) == 0 {
 			log.Printf("ℹ 未在 exchanges 找到可用的 Binance 密钥。请配置 api_key/secret_key 或在命令行指定 -exchange_id。")
@@ -413,48 +591,49 @@ func fetchOrdersFromConfigDB(reconcileDB *sql.DB, configDBPath, userID, exchange
 
 		// 如果未指定 exchange_id，则依次使用所有匹配的 Binance 账户逐个处理（有几条用几条）
 		for _, chosen := range exs {
-			if strings.TrimSpace(exchangeID) != "" && chosen.id != exchangeID {
-				continue
-			}
-			log.Printf("↩ 回退使用交易所[%s]的密钥对所有已扫描交易员拉取", chosen.id)
+			apiKey, secretKey := chosen.APIKey, chosen.SecretKey
+			log.Printf("↩ 回退使用交易所[%s]的密钥对所有已扫描交易员拉取", chosen.ID)
 			// 获取已扫描的 trader_id 列表
 			idRows, err := reconcileDB.Query(`SELECT DISTINCT trader_id FROM symbols ORDER BY trader_id`)
 			if err != nil {
 				log.Printf("⚠ 读取已扫描的交易员列表失败: %v", err)
 				continue
 			}
-			client := newSignedClient(chosen.api, chosen.sec, base)
+			client := newSignedClient(apiKey, secretKey, base)
+			var traderIDs []string
 			for idRows.Next() {
 				var traderID string
 				if err := idRows.Scan(&traderID); err != nil {
 					failedTasks++
 					continue
 				}
+				traderIDs = append(traderIDs, traderID)
+			}
+			_ = idRows.Close()
+
+			// 同一账户的任务在一个池中并发执行；不同账户依次执行，避免同一 (交易员, 交易对) 的增量状态被并发覆盖
+			var fallbackTasks []fetchTask
+			for _, traderID := range traderIDs {
 				symRows, err := reconcileDB.Query(`SELECT symbol FROM symbols WHERE trader_id = ? ORDER BY symbol`, traderID)
 				if err != nil {
 					log.Printf("⚠ 读取交易员 %s 的符号失败: %v", traderID, err)
 					failedTasks++
 					continue
 				}
-				cnt := 0
 				for symRows.Next() {
 					var symbol string
 					if err := symRows.Scan(&symbol); err != nil {
 						failedTasks++
 						continue
 					}
-					if err := fetchOrdersForSymbol(reconcileDB, client, traderID, symbol); err != nil {
-						log.Printf("⚠ 拉取 [%s] %s 失败: %v", traderID, symbol, err)
-						failedTasks++
-					}
-					time.Sleep(interval)
-					processedSymbols++
-					cnt++
+					fallbackTasks = append(fallbackTasks, fetchTask{traderID: traderID, symbol: symbol, client: client})
 				}
 				_ = symRows.Close()
-				log.Printf("⟲ 完成交易员 %s 的拉取（%d 个符号）@%s", traderID, cnt, chosen.id)
 			}
-			_ = idRows.Close()
+			processed, failed := pool.run(reconcileDB, fallbackTasks)
+			processedSymbols += processed
+			failedTasks += failed
+			log.Printf("⟲ 完成 %d 个交易员的拉取（%d 个符号）@%s", len(traderIDs), processed, chosen.ID)
 		}
 	}
 
@@ -462,15 +641,123 @@ func fetchOrdersFromConfigDB(reconcileDB *sql.DB, configDBPath, userID, exchange
 	return nil
 }
 
-// fetchOrdersForSymbol 调用 allOrders
-func fetchOrdersForSymbol(db *sql.DB, client *binanceREST, traderID, symbol string) error {
+// traderCredential config.db 中交易员绑定的交易所密钥（加密保存的凭据已由 config.Database 解密）
+type traderCredential struct {
+	traderID     string
+	exchangeID   string
+	exchangeName string
+	apiKey       string
+	secretKey    string
+	passphrase   string
+}
+
+// queryTraderCredentials 读取所有使用 binance/okx/bybit 的交易员及其密钥（忽略空密钥），返回被跳过的交易员数
+func queryTraderCredentials(cfgDB *config.Database, userID string) ([]traderCredential, int, error) {
+	traders, err := cfgDB.GetTraders(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询交易员失败: %w", err)
+	}
+	exchanges, err := cfgDB.GetExchanges(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询交易所密钥失败: %w", err)
+	}
+	byID := make(map[string]*config.ExchangeConfig, len(exchanges))
+	for _, e := range exchanges {
+		byID[e.ID] = e
+	}
+	sort.Slice(traders, func(i, j int) bool { return traders[i].ID < traders[j].ID })
+
+	var res []traderCredential
+	failed := 0
+	for _, t := range traders {
+		e, ok := byID[t.ExchangeID]
+		if !ok {
+			failed++
+			log.Printf("⚠ 交易员 %s 绑定的交易所 %s 不存在", t.ID, t.ExchangeID)
+			continue
+		}
+		if !hasKeys(e) || !(isBinanceAccount(e) || matchesAny(e, "okx", "bybit")) {
+			continue
+		}
+		res = append(res, traderCredential{
+			traderID:     t.ID,
+			exchangeID:   e.ID,
+			exchangeName: e.Name,
+			apiKey:       e.APIKey,
+			secretKey:    e.SecretKey,
+			passphrase:   e.Passphrase,
+		})
+	}
+	return res, failed, nil
+}
+
+// binanceAccounts 回退拉取使用的 Binance 账户：指定 exchangeID 时只取该账户，
+// 否则取 user_id 下所有 Binance 账户，找不到时放宽为任意 user_id
+func binanceAccounts(cfgDB *config.Database, userID, exchangeID string) ([]*config.ExchangeConfig, error) {
+	exchanges, err := cfgDB.GetExchanges(userID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(exchangeID) != "" {
+		var res []*config.ExchangeConfig
+		for _, e := range exchanges {
+			if e.ID == exchangeID && hasKeys(e) {
+				res = append(res, e)
+			}
+		}
+		return res, nil
+	}
+	pick := func(exchanges []*config.ExchangeConfig) []*config.ExchangeConfig {
+		var res []*config.ExchangeConfig
+		for _, e := range exchanges {
+			if isBinanceAccount(e) && hasKeys(e) {
+				res = append(res, e)
+			}
+		}
+		return res
+	}
+	if res := pick(exchanges); len(res) > 0 {
+		return res, nil
+	}
+	log.Printf("ℹ 未在 user_id=%s 下找到 Binance 账户，尝试跨用户查找...", userID)
+	all, err := cfgDB.GetAllExchanges()
+	if err != nil {
+		return nil, err
+	}
+	return pick(all), nil
+}
+
+// hasKeys 是否配置了 api_key 与 secret_key
+func hasKeys(e *config.ExchangeConfig) bool {
+	return e.APIKey != "" && e.SecretKey != ""
+}
+
+// isBinanceAccount 是否为币安（或通用 cex）账户
+func isBinanceAccount(e *config.ExchangeConfig) bool {
+	typ := strings.ToLower(e.Type)
+	return strings.EqualFold(e.ID, "binance") || strings.Contains(strings.ToLower(e.Name), "binance") || typ == "binance" || typ == "cex"
+}
+
+// matchesAny 交易所 id 或名称是否包含任一关键字（不区分大小写）
+func matchesAny(e *config.ExchangeConfig, keywords ...string) bool {
+	id, name := strings.ToLower(e.ID), strings.ToLower(e.Name)
+	for _, k := range keywords {
+		if strings.Contains(id, k) || strings.Contains(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchOrdersForSymbol 通过交易所适配器拉取历史订单
+func fetchOrdersForSymbol(db *sql.DB, client exchangeClient, traderID, symbol string) error {
 	st := time.Now()
 	// 读取增量状态
-	var lastOrderID sql.NullInt64
-	row := db.QueryRow(`SELECT last_order_id FROM reconcile_state WHERE trader_id = ? AND symbol = ?`, traderID, symbol)
-	_ = row.Scan(&lastOrderID)
+	var lastOrderID, lastFetch sql.NullInt64
+	row := db.QueryRow(`SELECT last_order_id, last_fetch_time FROM reconcile_state WHERE trader_id = ? AND symbol = ?`, traderID, symbol)
+	_ = row.Scan(&lastOrderID, &lastFetch)
 
-	log.Printf("拉取订单: traderID=%s, symbol=%s, base=%s, lastOrderID=%v", traderID, symbol, client.baseURL, func() any {
+	log.Printf("拉取订单: traderID=%s, symbol=%s, exchange=%s, lastOrderID=%v", traderID, symbol, client.Name(), func() any {
 		if lastOrderID.Valid {
 			return lastOrderID.Int64
 		}
@@ -480,8 +767,17 @@ func fetchOrdersForSymbol(db *sql.DB, client *binanceREST, traderID, symbol stri
 	var all []BinanceOrder
 	var rawAll []map[string]any
 	// 若有 lastOrderID 直接使用 orderId 参数获取后续订单
-	if lastOrderID.Valid && lastOrderID.Int64 > 0 {
-		orders, raw, err := client.allOrders(symbol, lastOrderID.Int64, 0, 0)
+	if lastOrderID.Valid && lastOrderID.Int64 > 0 && client.IDCursor() {
+		orders, raw, err := client.AllOrders(symbol, lastOrderID.Int64, 0, 0)
+		if err != nil {
+			return err
+		}
+		all = append(all, orders...)
+		rawAll = append(rawAll, raw...)
+	} else if lastFetch.Valid && lastFetch.Int64 > 0 && !client.IDCursor() {
+		// 不支持按订单ID增量的交易所：从上次拉取时间（含重叠）拉到现在
+		start := lastFetch.Int64 - incrementalOverlap.Milliseconds()
+		orders, raw, err := client.AllOrders(symbol, 0, start, time.Now().UnixMilli())
 		if err != nil {
 			return err
 		}
@@ -491,7 +787,7 @@ func fetchOrdersForSymbol(db *sql.DB, client *binanceREST, traderID, symbol stri
 		// 初次：按时间窗口分段（最多最近 30 天向后，接口每次最大 7 天）
 		end := time.Now().UnixMilli()
 		start := end - 7*24*3600*1000 // 最近 7 天即可，避免过多权重
-		orders, raw, err := client.allOrders(symbol, 0, start, end)
+		orders, raw, err := client.AllOrders(symbol, 0, start, end)
 		if err != nil {
 			return err
 		}
@@ -504,92 +800,119 @@ func fetchOrdersForSymbol(db *sql.DB, client *binanceREST, traderID, symbol stri
 		return nil
 	}
 
-	// 使用事务批量写入，避免数据库锁定
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("开启事务失败: %w", err)
+	// 使用事务批量写入，避免数据库锁定（并发拉取时串行化写入）
+	if err := writeTx(db, func(tx *sql.Tx) error {
+		if err := saveOrders(tx, traderID, symbol, all, rawAll); err != nil {
+			return err
+		}
+
+		// 更新状态
+		// 只更新增量列，保留回补进度
+		_, err := tx.Exec(`INSERT INTO reconcile_state(trader_id, symbol, last_order_id, last_fetch_time) VALUES(?,?,?,?)
+			ON CONFLICT(trader_id, symbol) DO UPDATE SET last_order_id = excluded.last_order_id, last_fetch_time = excluded.last_fetch_time`,
+			traderID, symbol, latestOrderID(all), time.Now().UnixMilli())
+		if err != nil {
+			return fmt.Errorf("更新状态失败: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
 	}
-	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO orders(trader_id, symbol, order_id, side, position_side, status, avg_price, executed_qty, orig_qty, reduce_only, close_position, type, time, update_time, raw_json)
-		VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`)
+	metrics.add(metricOrdersFetched, float64(len(all)), "trader", traderID, "exchange", client.Name())
+	log.Printf("✓ [%s] %s 增量拉取 %d 条, 用时 %v", traderID, symbol, len(all), time.Since(st))
+	return nil
+}
+
+// saveOrders 在事务内写入（覆盖）订单，raw 与 orders 一一对应
+func saveOrders(tx *sql.Tx, traderID, symbol string, orders []BinanceOrder, raw []map[string]any) error {
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO orders(trader_id, symbol, order_id, side, position_side, status, avg_price, executed_qty, orig_qty, reduce_only, close_position, type, time, update_time, raw_json, stop_price, client_order_id)
+		VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`)
 	if err != nil {
 		return fmt.Errorf("准备语句失败: %w", err)
 	}
 	defer stmt.Close()
 
-	for i, ord := range all {
-		b, _ := json.Marshal(rawAll[i])
+	for i, ord := range orders {
+		b, _ := json.Marshal(raw[i])
 		avg := parseFloat(ord.AvgPrice)
 		exec := parseFloat(ord.ExecutedQty)
 		orig := parseFloat(ord.OrigQty)
 		_, e := stmt.Exec(traderID, symbol, ord.OrderID, ord.Side, ord.PositionSide, ord.Status, avg, exec, orig,
-			boolToInt(ord.ReduceOnly), boolToInt(ord.ClosePosition), ord.Type, ord.Time, ord.UpdateTime, string(b))
+			boolToInt(ord.ReduceOnly), boolToInt(ord.ClosePosition), ord.Type, ord.Time, ord.UpdateTime, string(b), parseFloat(ord.StopPrice), ord.ClientOrderID)
 		if e != nil {
 			log.Printf("⚠ 写入订单失败 [%s] %s order_id=%d: %v", traderID, symbol, ord.OrderID, e)
 		}
 	}
-
-	// 更新状态
-	_, err = tx.Exec(`INSERT OR REPLACE INTO reconcile_state(trader_id, symbol, last_order_id, last_fetch_time) VALUES(?,?,?,?)`,
-		traderID, symbol, latestOrderID(all), time.Now().UnixMilli())
-	if err != nil {
-		return fmt.Errorf("更新状态失败: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
-	}
-
-	log.Printf("✓ [%s] %s 增量拉取 %d 条, 用时 %v", traderID, symbol, len(all), time.Since(st))
 	return nil
 }
 
-// reconcileLogs placeholder
-func reconcileLogs(db *sql.DB, decisionDir string) error {
+// reconcileLogs 按校正策略对账所有交易员的决策日志
+// dry 非 nil 时只输出拟执行的变更，不修改任何文件
+// jr 记录改写/新建的决策文件，供 rollback 使用
+// tols 为匹配容差（nil 使用内置默认）
+// synthOrphans 为 true 时把没有对应决策的成交订单补写为 decision_reconcile_* 文件，否则只报告
+func reconcileLogs(db *sql.DB, src *logSource, gate *correctionGate, tols *matchTolerances, synthOrphans bool, dry *dryRun, rep *runReport, jr *journal) error {
 	// 读取订单缓存
 	ordersMap, err := loadOrdersGrouped(db)
 	if err != nil {
 		return err
 	}
+	leverages, err := loadLeverageSnapshots(db)
+	if err != nil {
+		log.Printf("⚠ 读取杠杆观测失败，跳过杠杆核对: %v", err)
+	}
 
 	// 遍历 trader 子目录（decision_logs 下的目录）
-	entries, err := os.ReadDir(decisionDir)
+	traders, err := src.traders()
 	if err != nil {
-		return fmt.Errorf("读取决策目录失败: %w", err)
+		return err
 	}
 
-	for _, ent := range entries {
-		if !ent.IsDir() {
-			continue
-		}
-		traderID := ent.Name()
-		traderPath := filepath.Join(decisionDir, traderID)
-		if err := reconcileTrader(traderPath, traderID, ordersMap); err != nil {
-			log.Printf("⚠ 对账 %s 失败: %v", traderPath, err)
+	for _, traderID := range traders {
+		traderPath := src.location(traderID, "")
+		if err := reconcileTrader(src, traderID, ordersMap, gate, tols, synthOrphans, dry, rep, jr); err != nil {
+			msg := fmt.Sprintf("⚠ 对账 %s 失败: %v", traderPath, err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
+		} else if err := checkTraderLeverage(src, traderID, leverages, rep); err != nil {
+			msg := fmt.Sprintf("⚠ 杠杆核对 %s 失败: %v", traderPath, err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
 		}
 	}
+	gate.logSummary()
+	if err := rep.finish(); err != nil {
+		return err
+	}
+	if dry != nil {
+		return dry.flush()
+	}
 	return nil
 }
 
 // loadOrdersGrouped 按 trader_id+symbol+position_side 分组订单（已按时间排序）
 func loadOrdersGrouped(db *sql.DB) (map[string][]BinanceOrder, error) {
 	// 读取订单缓存
-	rows, err := db.Query(`SELECT trader_id, symbol, order_id, side, position_side, status, avg_price, executed_qty, orig_qty, reduce_only, close_position, type, time, update_time, raw_json FROM orders`)
+	rows, err := db.Query(`SELECT trader_id, symbol, order_id, side, position_side, status, avg_price, executed_qty, orig_qty, reduce_only, close_position, type, time, update_time, raw_json, stop_price, client_order_id FROM orders`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	fills, err := loadOrderFills(db)
+	if err != nil {
+		log.Printf("⚠ 读取成交记录失败，使用订单均价: %v", err)
+	}
 	res := make(map[string][]BinanceOrder)
 	for rows.Next() {
 		// 重建部分字段
 		var o BinanceOrder
 		var traderID, symbol string
-		var avg, exec, orig float64
+		var avg, exec, orig, stop float64
 		var reduceOnly, closePos int
 		var raw string
 		// 重建部分字段
-		if err := rows.Scan(&traderID, &symbol, &o.OrderID, &o.Side, &o.PositionSide, &o.Status, &avg, &exec, &orig, &reduceOnly, &closePos, &o.Type, &o.Time, &o.UpdateTime, &raw); err != nil {
+		if err := rows.Scan(&traderID, &symbol, &o.OrderID, &o.Side, &o.PositionSide, &o.Status, &avg, &exec, &orig, &reduceOnly, &closePos, &o.Type, &o.Time, &o.UpdateTime, &raw, &stop, &o.ClientOrderID); err != nil {
 			continue
 		}
 		o.Symbol = symbol
@@ -597,17 +920,29 @@ func loadOrdersGrouped(db *sql.DB) (map[string][]BinanceOrder, error) {
 		o.ExecutedQty = strconv.FormatFloat(exec, 'f', -1, 64)
 		o.OrigQty = strconv.FormatFloat(orig, 'f', -1, 64)
 		o.AvgPrice = strconv.FormatFloat(avg, 'f', -1, 64)
-		// 如果 AvgPrice 为 0，尝试从 raw_json 解析 price
-		if avg == 0 && raw != "" {
+		if stop > 0 {
+			o.StopPrice = strconv.FormatFloat(stop, 'f', -1, 64)
+		}
+		if raw != "" {
 			var rawData map[string]interface{}
 			if json.Unmarshal([]byte(raw), &rawData) == nil {
-				if priceStr, ok := rawData["price"].(string); ok {
+				// 如果 AvgPrice 为 0，尝试从 raw_json 解析 price
+				if priceStr, ok := rawData["price"].(string); ok && avg == 0 {
 					o.Price = priceStr
 				}
+				// 条件单类型：币安为 origType，Bybit 为 stopOrderType
+				for _, k := range []string{"origType", "stopOrderType"} {
+					if v, ok := rawData[k].(string); ok && v != "" {
+						o.OrigType = strings.ToUpper(v)
+						break
+					}
+				}
 			}
 		}
 		o.ReduceOnly = reduceOnly == 1
 		o.ClosePosition = closePos == 1
+		// 有成交记录时以实际成交为准
+		applyFill(&o, fills[fillKey(traderID, symbol, o.OrderID)])
 		// key = trader_id + symbol + position_side
 		key := traderID + "_" + symbol + "_" + strings.ToUpper(o.PositionSide)
 		res[key] = append(res[key], o)
@@ -620,165 +955,169 @@ func loadOrdersGrouped(db *sql.DB) (map[string][]BinanceOrder, error) {
 }
 
 // reconcileTrader 针对单个 trader 日志目录执行校验与补全
-func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrder) error {
-	files, err := os.ReadDir(dir)
+func reconcileTrader(src *logSource, traderID string, orders map[string][]BinanceOrder, gate *correctionGate, tols *matchTolerances, synthOrphans bool, dry *dryRun, rep *runReport, jr *journal) error {
+	// 收集日志记录
+	records, err := src.records(traderID)
 	if err != nil {
 		return err
 	}
-	// 收集日志记录
-	var logFiles []string
-	for _, f := range files {
-		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
-			continue
-		}
-		logFiles = append(logFiles, filepath.Join(dir, f.Name()))
-	}
+	stats := rep.stats(traderID)
+	stats.Files = len(records)
 	// 解析并构建开/平仓状态
-	openPositions := make(map[string]DecisionAction) // key=symbol_side
-	closedPositions := make(map[string]bool)
-	fileActions := make(map[string][]DecisionAction) // 文件到动作列表
-
-	for _, fp := range logFiles {
-		data, err := os.ReadFile(fp)
-		if err != nil {
-			continue
-		}
-		var rec DecisionRecordPart
-		if json.Unmarshal(data, &rec) != nil {
+	var ledgerActs []ledgerAction
+	fileNames := make(map[string]string)                                            // 文件到记录名
+	fileActions := make(map[string][]DecisionAction)                                // 文件到动作列表
+	filePlans := make(map[string][]decisions.Item)                                  // 文件到 decision_json（止损/止盈目标价）
+	history := newPositionHistory(tols.forAction(traderID, "partial_close").Window) // 仓位历史（用于双向持仓订单归属校验）
+
+	for _, r := range records {
+		rec, ok := parseRecord(r)
+		if !ok {
 			continue
 		}
+		fp := src.location(traderID, r.Name)
+		fileNames[fp] = r.Name
+		filePlans[fp] = parseDecisionPlans(rec.DecisionJSON)
 		for i, act := range rec.Decisions {
 			if !act.Success {
 				continue
 			}
 			fileActions[fp] = append(fileActions[fp], act)
-			if act.Action == "open_long" || act.Action == "open_short" {
-				key := act.Symbol + "_" + sideFromAction(act.Action)
-				openPositions[key] = act
-			} else if isCloseAction(act.Action) {
-				key := act.Symbol + "_" + sideFromAction(act.Action)
-				closedPositions[key] = true
-			}
-			// partial_close 暂不特殊处理
-			_ = i
+			stats.Actions++
+			history.observe(act)
+			ledgerActs = append(ledgerActs, ledgerAction{DecisionAction: act, file: fp, index: i})
 		}
 	}
-
-	// 查找缺失的平仓
-	for key, openAct := range openPositions {
-		if closedPositions[key] {
-			continue
+	// 仓位台账：平仓后再次开仓视为新仓位；每段仓位再按开仓拆分区间，逐个区间查找缺失的平仓
+	ledger := buildLedger(traderID, ledgerActs)
+	for _, v := range ledger.violations {
+		rep.violation(v)
+		log.Println(formatViolation(v))
+	}
+	loggedOrders := make(map[int64]bool) // 日志已引用的订单，不再作为补全候选
+	for _, a := range ledgerActs {
+		if a.OrderID > 0 {
+			loggedOrders[a.OrderID] = true
 		}
-		// 根据 trader_id+key 获取订单候选
-		ordKey := traderID + "_" + key
-		ordList := orders[ordKey]
-		if len(ordList) == 0 {
+	}
+	history.sort()
+
+	// 报告条目（含严重级别与是否已应用）
+	var openMismatches []string
+	record := func(c Correction) bool {
+		applied := gate.allow(c)
+		rep.correction(c, gate.state(applied), applied)
+		openMismatches = append(openMismatches, gate.label(c, applied)+" "+c.Description)
+		return applied
+	}
+
+	// 查找缺失的平仓（按仓位、区间的时间顺序）
+	for _, p := range ledger.positions {
+		key := p.Symbol + "_" + p.Side
+		candidates := mergeOrderLists(getOrderLists(orders, traderID, p.Symbol, p.Side))
+		if len(candidates) == 0 {
 			continue
 		}
-		// 选择开仓时间后最近的一个 closePosition 或 reduceOnly 订单
-		var best *BinanceOrder
-		for i := range ordList {
-			o := ordList[i]
-			if o.Time < openAct.Timestamp.UnixMilli() {
+		for _, cyc := range p.cycles() {
+			// 已按日志平仓的仓位，最后一个区间无需补全
+			if cyc.Last && p.Closed {
 				continue
 			}
-			// 判断是否是平仓候选
-			if !(o.ClosePosition || o.ReduceOnly) {
+			best := findCycleClose(candidates, p.Side, cyc, tols.forAction(traderID, closeActionName(cyc.Open.Action)), loggedOrders)
+			if best == nil {
 				continue
 			}
-			// Side 应与开仓对应的平仓方向相反
-			if !matchCloseSide(openAct.Action, o.Side) {
-				continue
+			loggedOrders[best.OrderID] = true
+			openAct := cyc.Open
+			// 生成补全文件
+			// 如果是 reduceOnly 且非 closePosition，按业务语义更贴近 "partial_close"
+			// 双向持仓平仓单不带 reduceOnly，按成交数量是否小于区间持仓数量判断
+			actionName := closeActionName(openAct.Action)
+			if isHedgeOrder(best) {
+				if !best.ClosePosition && cyc.Qty > 0 && parseFloat(best.ExecutedQty) < cyc.Qty*0.99 {
+					actionName = "partial_close"
+				}
+			} else if best.ReduceOnly && !best.ClosePosition {
+				actionName = "partial_close"
 			}
-			// 🔧 只使用已完全成交的订单 (FILLED)
-			if strings.ToUpper(o.Status) != "FILLED" {
-				continue
+			closeAction := DecisionAction{
+				Action:    actionName,
+				Symbol:    openAct.Symbol,
+				Quantity:  parseFloat(best.ExecutedQty),
+				Price:     safePrice(best),
+				OrderID:   best.OrderID,
+				Timestamp: time.UnixMilli(best.Time),
+				Success:   true,
 			}
-			// 🔧 确保有成交数量和价格
-			qty := parseFloat(o.ExecutedQty)
-			price := safePrice(&o)
-			if qty <= 0 || price <= 0 {
+			where := ""
+			if !cyc.Last {
+				where = fmt.Sprintf("（第 %d 段仓位 %s 开仓后、%s 再次开仓前）", p.Seq, openAct.Timestamp.Format("01-02 15:04:05"), cyc.To.Format("01-02 15:04:05"))
+			}
+			if !record(Correction{
+				TraderID:    traderID,
+				Symbol:      openAct.Symbol,
+				Action:      actionName,
+				Severity:    SeverityMajor,
+				Description: fmt.Sprintf("➕ [%s] %s 缺少平仓记录%s，补全 %s (订单ID: %d, 数量: %.4f, 价格: %.4f)", traderID, key, where, actionName, best.OrderID, closeAction.Quantity, closeAction.Price) + fillSummary(best),
+			}) {
 				continue
 			}
-			best = &o
-			break
-		}
-		if best == nil {
-			continue
-		}
-		// 生成补全文件
-		// 如果是 reduceOnly 且非 closePosition，按业务语义更贴近 "partial_close"
-		actionName := closeActionName(openAct.Action)
-		if best.ReduceOnly && !best.ClosePosition {
-			actionName = "partial_close"
-		}
-		closeAction := DecisionAction{
-			Action:    actionName,
-			Symbol:    openAct.Symbol,
-			Quantity:  parseFloat(best.ExecutedQty),
-			Price:     safePrice(best),
-			OrderID:   best.OrderID,
-			Timestamp: time.UnixMilli(best.Time),
-			Success:   true,
-		}
-		// 写入新文件 decision_reconcile_*
-		fname := fmt.Sprintf("decision_reconcile_%s_%d.json", time.Now().Format("20060102_150405"), best.OrderID)
-		path := filepath.Join(dir, fname)
-		rec := DecisionRecordPart{Decisions: []DecisionAction{closeAction}}
-		b, _ := json.MarshalIndent(rec, "", "  ")
-		if err := os.WriteFile(path, b, 0644); err != nil {
-			log.Printf("⚠ 写入补全文件失败 %s: %v", path, err)
-		} else {
-			log.Printf("➕ 已补全平仓: %s → %s", key, path)
+			if path, ok := writeReconcileFile(src, traderID, closeAction, dry, rep, jr); ok && dry == nil {
+				log.Printf("➕ 已补全平仓: %s → %s", key, path)
+			}
 		}
 	}
 
 	// 校正已有的开仓行为
-	var openMismatches []string
 	for fp, acts := range fileActions {
 		changed := false
+		origActs := append([]DecisionAction(nil), acts...)
 		for i, act := range acts {
 
 			// 处理开仓
 			if act.Action == "open_long" || act.Action == "open_short" {
 				// 订单候选：优先使用对应方向，其次回退 BOTH
 				lists := getOrderLists(orders, traderID, act.Symbol, sideFromAction(act.Action))
-				var candidate *BinanceOrder
-				bestDelta := int64(1<<62 - 1)
-				for _, ordList := range lists {
-					for idx := range ordList {
-						o := ordList[idx]
-						// 开仓方向匹配：open_long -> BUY/LONG, open_short -> SELL/SHORT
-						if !matchOpenSide(act.Action, o.Side) {
-							continue
-						}
-						// 时间容差
-						delta := abs64(o.Time - act.Timestamp.UnixMilli())
-						if delta > timeToleranceMs {
-							continue
-						}
-						// 开仓订单不应该是 reduceOnly 或 closePosition
-						if o.ClosePosition || o.ReduceOnly {
-							continue
-						}
-						// 必须完全成交，且有数量与价格
-						if strings.ToUpper(o.Status) != "FILLED" {
-							continue
-						}
-						qty := parseFloat(o.ExecutedQty)
-						price := safePrice(&o)
-						if qty <= 0 || price <= 0 {
-							continue
-						}
-						if delta < bestDelta {
-							bestDelta = delta
-							candidate = &o
+				rule := tols.forAction(traderID, act.Action)
+				// clientOrderId 命中时精确匹配，不再按时间窗口查找
+				candidate, exact := matchByClientOrderID(lists, act.ClientOrderID, func(o *BinanceOrder) bool {
+					return orderOpensPosition(o, sideFromAction(act.Action)) && rule.accepts(o)
+				})
+				if !exact {
+					bestDelta := int64(1<<62 - 1)
+					for _, ordList := range lists {
+						for idx := range ordList {
+							o := ordList[idx]
+							// 开仓方向匹配：open_long -> BUY/LONG, open_short -> SELL/SHORT
+							// 开仓订单不应该是 reduceOnly 或 closePosition（双向持仓严格按 positionSide）
+							if !orderOpensPosition(&o, sideFromAction(act.Action)) {
+								continue
+							}
+							// 时间容差
+							delta := abs64(o.Time - act.Timestamp.UnixMilli())
+							if delta > rule.windowMs() {
+								continue
+							}
+							// 状态可接受（默认必须完全成交），且有数量与价格
+							if !rule.accepts(&o) {
+								continue
+							}
+							if delta < bestDelta {
+								bestDelta = delta
+								candidate = &o
+							}
 						}
 					}
 				}
 				if candidate == nil {
-					openMismatches = append(openMismatches, fmt.Sprintf("⚠ [%s] %s %s 未找到匹配的开仓订单 (决策时间: %s, 价格: %.4f, 数量: %.4f) → 改为 wait",
-						traderID, act.Symbol, act.Action, act.Timestamp.Format("2006-01-02 15:04:05"), act.Price, act.Quantity))
+					applied := record(Correction{
+						TraderID: traderID,
+						Symbol:   act.Symbol,
+						Action:   act.Action,
+						Severity: SeverityMajor,
+						Description: fmt.Sprintf("⚠ [%s] %s %s 未找到匹配的开仓订单 (决策时间: %s, 价格: %.4f, 数量: %.4f%s) → 改为 wait",
+							traderID, act.Symbol, act.Action, act.Timestamp.Format("2006-01-02 15:04:05"), act.Price, act.Quantity, clientOrderIDNote(act.ClientOrderID, exact)),
+					})
 					// 输出调试信息：显示所有候选订单的时间差异
 					log.Printf("⏰ [调试] %s %s 时间对比:", act.Symbol, act.Action)
 					log.Printf("   决策记录时间: %s", act.Timestamp.Format("2006-01-02 15:04:05"))
@@ -794,6 +1133,9 @@ func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrd
 								diffMinutes, o.Side, o.Status)
 						}
 					}
+					if !applied {
+						continue
+					}
 					// 🔧 将无法匹配的开仓操作改为 wait
 					acts[i].Action = "wait"
 					acts[i].OrderID = 0
@@ -807,9 +1149,17 @@ func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrd
 				// 检查偏差
 				qtyDev := deviation(act.Quantity, qty)
 				priceDev := deviation(act.Price, price)
-				if qtyDev > 0.01 || priceDev > 0.01 {
-					openMismatches = append(openMismatches, fmt.Sprintf("📝 [%s] %s %s 数据偏差: 数量 %.4f→%.4f (%.2f%%), 价格 %.4f→%.4f (%.2f%%)",
-						traderID, act.Symbol, act.Action, act.Quantity, qty, qtyDev*100, act.Price, price, priceDev*100))
+				if qtyDev > rule.QtyDev || priceDev > rule.PriceDev {
+					if !record(Correction{
+						TraderID: traderID,
+						Symbol:   act.Symbol,
+						Action:   act.Action,
+						Severity: SeverityMinor,
+						Description: fmt.Sprintf("📝 [%s] %s %s 数据偏差: 数量 %.4f→%.4f (%.2f%%), 价格 %.4f→%.4f (%.2f%%)",
+							traderID, act.Symbol, act.Action, act.Quantity, qty, qtyDev*100, act.Price, price, priceDev*100) + fillSummary(candidate),
+					}) {
+						continue
+					}
 					acts[i].Quantity = qty
 					acts[i].Price = price
 					acts[i].OrderID = candidate.OrderID
@@ -817,8 +1167,15 @@ func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrd
 					changed = true
 				} else if act.OrderID != candidate.OrderID {
 					// 价格数量一致但 OrderID 不同
-					openMismatches = append(openMismatches, fmt.Sprintf("🔧 [%s] %s %s OrderID 不匹配: %d→%d",
-						traderID, act.Symbol, act.Action, act.OrderID, candidate.OrderID))
+					if !record(Correction{
+						TraderID:    traderID,
+						Symbol:      act.Symbol,
+						Action:      act.Action,
+						Severity:    SeverityInfo,
+						Description: fmt.Sprintf("🔧 [%s] %s %s OrderID 不匹配: %d→%d", traderID, act.Symbol, act.Action, act.OrderID, candidate.OrderID),
+					}) {
+						continue
+					}
 					acts[i].OrderID = candidate.OrderID
 					changed = true
 				}
@@ -826,39 +1183,44 @@ func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrd
 			// 处理平仓
 			if isCloseAction(act.Action) {
 				lists := getOrderLists(orders, traderID, act.Symbol, sideFromAction(act.Action))
-				var candidate *BinanceOrder
-				bestDelta := int64(1<<62 - 1)
-				for _, ordList := range lists {
-					for idx := range ordList {
-						o := ordList[idx]
-						if !matchCloseSide(act.Action, o.Side) {
-							continue
-						}
-						delta := abs64(o.Time - act.Timestamp.UnixMilli())
-						if delta > timeToleranceMs {
-							continue
-						}
-						if !(o.ClosePosition || o.ReduceOnly) {
-							continue
-						}
-						if strings.ToUpper(o.Status) != "FILLED" {
-							continue
-						}
-						qty := parseFloat(o.ExecutedQty)
-						price := safePrice(&o)
-						if qty <= 0 || price <= 0 {
-							continue
-						}
-						if delta < bestDelta {
-							bestDelta = delta
-							candidate = &o
+				rule := tols.forAction(traderID, act.Action)
+				candidate, exact := matchByClientOrderID(lists, act.ClientOrderID, func(o *BinanceOrder) bool {
+					return orderClosesPosition(o, sideFromAction(act.Action)) && rule.accepts(o)
+				})
+				if !exact {
+					bestDelta := int64(1<<62 - 1)
+					for _, ordList := range lists {
+						for idx := range ordList {
+							o := ordList[idx]
+							if !orderClosesPosition(&o, sideFromAction(act.Action)) {
+								continue
+							}
+							delta := abs64(o.Time - act.Timestamp.UnixMilli())
+							if delta > rule.windowMs() {
+								continue
+							}
+							if !rule.accepts(&o) {
+								continue
+							}
+							if delta < bestDelta {
+								bestDelta = delta
+								candidate = &o
+							}
 						}
 					}
 				}
 				if candidate == nil {
 					// 🔧 将无法匹配的平仓操作改为 wait
-					openMismatches = append(openMismatches, fmt.Sprintf("⚠ [%s] %s %s 未找到匹配的平仓订单 (决策时间: %s) → 改为 wait",
-						traderID, act.Symbol, act.Action, act.Timestamp.Format("2006-01-02 15:04:05")))
+					if !record(Correction{
+						TraderID: traderID,
+						Symbol:   act.Symbol,
+						Action:   act.Action,
+						Severity: SeverityMajor,
+						Description: fmt.Sprintf("⚠ [%s] %s %s 未找到匹配的平仓订单 (决策时间: %s%s) → 改为 wait",
+							traderID, act.Symbol, act.Action, act.Timestamp.Format("2006-01-02 15:04:05"), clientOrderIDNote(act.ClientOrderID, exact)),
+					}) {
+						continue
+					}
 					acts[i].Action = "wait"
 					acts[i].OrderID = 0
 					acts[i].Quantity = 0
@@ -868,7 +1230,17 @@ func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrd
 				}
 				qty := parseFloat(candidate.ExecutedQty)
 				price := safePrice(candidate)
-				if deviation(act.Quantity, qty) > 0.01 || deviation(act.Price, price) > 0.01 {
+				if rule.deviates(act.Quantity, qty, act.Price, price) {
+					if !record(Correction{
+						TraderID: traderID,
+						Symbol:   act.Symbol,
+						Action:   act.Action,
+						Severity: SeverityMinor,
+						Description: fmt.Sprintf("📝 [%s] %s %s 数据偏差: 数量 %.4f→%.4f, 价格 %.4f→%.4f",
+							traderID, act.Symbol, act.Action, act.Quantity, qty, act.Price, price) + fillSummary(candidate),
+					}) {
+						continue
+					}
 					acts[i].Quantity = qty
 					acts[i].Price = price
 					acts[i].OrderID = candidate.OrderID
@@ -879,48 +1251,76 @@ func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrd
 
 			// 处理 partial_close - 也需要匹配实际订单
 			if act.Action == "partial_close" {
-				// 同时在 LONG/SHORT 列表中寻找 reduce_only 的部分平仓成交
-				listsLong := getOrderLists(orders, traderID, act.Symbol, "LONG")
-				listsShort := getOrderLists(orders, traderID, act.Symbol, "SHORT")
+				// 在当时持仓的方向中寻找部分平仓成交：单向模式要求 reduce_only，
+				// 双向持仓严格按 positionSide 归属，并用仓位历史限定候选方向
+				sides := []string{"LONG", "SHORT"}
+				hedge := symbolUsesHedgeMode(orders, traderID, act.Symbol)
+				if hedge {
+					if openSides := history.openSidesAt(act.Symbol, act.Timestamp); len(openSides) > 0 {
+						sides = openSides
+					}
+				}
+				rule := tols.forAction(traderID, act.Action)
 				var candidate *BinanceOrder
+				candidateSide := ""
 				bestDelta := int64(1<<62 - 1)
-				check := func(ordList []BinanceOrder, closeAction string) {
+				check := func(ordList []BinanceOrder, side string) {
 					for idx := range ordList {
 						o := ordList[idx]
-						if !matchCloseSide(closeAction, o.Side) {
+						if !orderReducesPartially(&o, side) {
 							continue
 						}
 						delta := abs64(o.Time - act.Timestamp.UnixMilli())
-						if delta > timeToleranceMs {
-							continue
-						}
-						if !o.ReduceOnly {
-							continue
-						}
-						// 接受 FILLED，或 PARTIALLY_FILLED/CANCELED 但有成交数量的部分平仓
-						statusU := strings.ToUpper(o.Status)
-						qty := parseFloat(o.ExecutedQty)
-						if !(statusU == "FILLED" || ((statusU == "PARTIALLY_FILLED" || statusU == "CANCELED") && qty > 0)) {
+						if delta > rule.windowMs() {
 							continue
 						}
-						price := safePrice(&o)
-						if qty <= 0 || price <= 0 {
+						// 默认接受 FILLED，或 PARTIALLY_FILLED/CANCELED 但有成交数量的部分平仓
+						if !rule.accepts(&o) {
 							continue
 						}
 						if delta < bestDelta {
 							bestDelta = delta
 							candidate = &o
+							candidateSide = side
 						}
 					}
 				}
-				for _, l := range listsLong {
-					check(l, "close_long")
+				exact := false
+				for _, side := range sides {
+					o, ok := matchByClientOrderID(getOrderLists(orders, traderID, act.Symbol, side), act.ClientOrderID, func(o *BinanceOrder) bool {
+						return orderReducesPartially(o, side) && rule.accepts(o)
+					})
+					if ok {
+						candidate, candidateSide, exact = o, side, true
+						break
+					}
+				}
+				if !exact {
+					for _, side := range sides {
+						for _, l := range getOrderLists(orders, traderID, act.Symbol, side) {
+							check(l, side)
+						}
+					}
 				}
-				for _, l := range listsShort {
-					check(l, "close_short")
+				if candidate != nil && hedge && !history.isOpenAt(act.Symbol, candidateSide, act.Timestamp) {
+					record(Correction{
+						TraderID:    traderID,
+						Symbol:      act.Symbol,
+						Action:      act.Action,
+						Severity:    SeverityInfo,
+						Description: fmt.Sprintf("🔀 [%s] %s partial_close 匹配到 %s 订单 (ID: %d)，但仓位历史中该时刻无 %s 持仓，请核对", traderID, act.Symbol, candidateSide, candidate.OrderID, candidateSide),
+					})
 				}
 				if candidate == nil {
-					openMismatches = append(openMismatches, fmt.Sprintf("⚠ [%s] %s partial_close 未找到匹配订单 → 改为 wait", traderID, act.Symbol))
+					if !record(Correction{
+						TraderID:    traderID,
+						Symbol:      act.Symbol,
+						Action:      act.Action,
+						Severity:    SeverityMajor,
+						Description: fmt.Sprintf("⚠ [%s] %s partial_close 未找到匹配订单%s → 改为 wait", traderID, act.Symbol, clientOrderIDNote(act.ClientOrderID, exact)),
+					}) {
+						continue
+					}
 					acts[i].Action = "wait"
 					acts[i].OrderID = 0
 					acts[i].Quantity = 0
@@ -930,35 +1330,104 @@ func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrd
 				}
 			}
 
-			// 处理其他操作类型 (hold, update_stop_loss, update_take_profit 等)
-			// 这些操作不需要实际订单,但如果标记为 success 但没有对应的真实交易操作,也改为 wait
-			if !needsOrderMatch(act.Action) && act.Action != "wait" && act.Action != "hold" {
-				// update_stop_loss, update_take_profit 等操作应该有对应的修改订单记录
-				// 但由于 allOrders 接口可能不包含这些修改,暂时保留原样
-				// 如果未来需要验证,可以在这里添加逻辑
+			// 处理止损/止盈调整：应有对应的 STOP_MARKET/TAKE_PROFIT_MARKET 条件单，且触发价与决策一致
+			if isTriggerAction(act.Action) {
+				rule := tols.forAction(traderID, act.Action)
+				target := planTrigger(filePlans[fp], act.Symbol, act.Action)
+				sides := []string{"LONG", "SHORT"}
+				if openSides := history.openSidesAt(act.Symbol, act.Timestamp); len(openSides) > 0 {
+					sides = openSides
+				}
+				o, side, priceOK := findTriggerOrder(orders, traderID, act, sides, target, rule)
+				if o == nil {
+					if !record(Correction{
+						TraderID:    traderID,
+						Symbol:      act.Symbol,
+						Action:      act.Action,
+						Severity:    SeverityMajor,
+						Description: fmt.Sprintf("⚠ [%s] %s %s 未找到对应的条件单 (目标触发价: %.4f, 时间: %s) → 改为 wait", traderID, act.Symbol, act.Action, target, act.Timestamp.Format("2006-01-02 15:04:05")),
+					}) {
+						continue
+					}
+					acts[i].Action = "wait"
+					acts[i].OrderID = 0
+					acts[i].Quantity = 0
+					acts[i].Price = 0
+					changed = true
+					continue
+				}
+				if !priceOK {
+					// 日志中没有触发价字段可校正，只报告
+					record(Correction{
+						TraderID:    traderID,
+						Symbol:      act.Symbol,
+						Action:      act.Action,
+						Severity:    SeverityMinor,
+						Description: fmt.Sprintf("📝 [%s] %s %s 条件单触发价不一致: 决策 %.4f, 订单 %s (ID: %d, %s, 状态: %s)，请核对", traderID, act.Symbol, act.Action, target, o.StopPrice, o.OrderID, side, o.Status),
+					})
+				}
 			}
 		}
-		if changed {
-			// 备份原文件
-			_ = os.Rename(fp, fp+".bak")
-			// 读取原文件其余字段并只替换 decisions
-			if err := writeUpdatedFilePreserve(fp+".bak", fp, acts); err != nil {
-				log.Printf("⚠ 覆盖文件失败 %s: %v", fp, err)
+		if changed && dry != nil {
+			data, err := src.store.Get(traderID, fileNames[fp])
+			if err != nil {
+				log.Printf("⚠ 读取文件失败 %s: %v", fp, err)
+				continue
+			}
+			// 校正前后按相同方式序列化（键序、缩进一致），diff 只包含真正变化的字段
+			before, err := decisionlog.Render(data, origActs)
+			if err != nil {
+				log.Printf("⚠ 生成校正内容失败 %s: %v", fp, err)
+				continue
+			}
+			updated, err := decisionlog.Render(data, acts)
+			if err != nil {
+				log.Printf("⚠ 生成校正内容失败 %s: %v", fp, err)
+				continue
+			}
+			dry.modify(fp, before, updated, origActs, acts)
+		} else if changed {
+			// 原内容备份为 .bak，只替换 decisions，其余字段保留
+			if before, after, err := src.replaceDecisions(traderID, fileNames[fp], acts); err != nil {
+				msg := fmt.Sprintf("⚠ 覆盖文件失败 %s: %v", fp, err)
+				rep.failure(traderID, msg)
+				log.Println(msg)
 			} else {
+				jr.modify(fp, before, after)
 				log.Printf("✏ 已校正文件 %s", fp)
 			}
 		}
 	}
 
+	// 反向检查：成交订单没有对应决策（手动或异常下单）
+	for _, oo := range findOrphanOrders(orders, traderID, ledgerActs, loggedOrders, tols) {
+		desc := describeOrphan(traderID, oo)
+		if !synthOrphans {
+			rep.issue(traderID, desc)
+			openMismatches = append(openMismatches, desc)
+			continue
+		}
+		o := &oo.Order
+		if !record(Correction{TraderID: traderID, Symbol: o.Symbol, Action: oo.Action, Severity: SeverityMajor, Description: desc + " → 补写决策"}) {
+			continue
+		}
+		act := DecisionAction{
+			Action:    oo.Action,
+			Symbol:    o.Symbol,
+			Quantity:  parseFloat(o.ExecutedQty),
+			Price:     safePrice(o),
+			OrderID:   o.OrderID,
+			Timestamp: time.UnixMilli(o.Time),
+			Success:   true,
+		}
+		if path, ok := writeReconcileFile(src, traderID, act, dry, rep, jr); ok && dry == nil {
+			log.Printf("👻 已补写孤立订单: %s_%s %s → %s", o.Symbol, oo.Side, oo.Action, path)
+		}
+	}
+
 	// 输出开仓不匹配报告
 	if len(openMismatches) > 0 {
-		reportPath := filepath.Join(dir, fmt.Sprintf("open_mismatch_report_%s.txt", time.Now().Format("20060102_150405")))
-		reportContent := strings.Join(append([]string{"=== 开仓数据核对报告 ===", fmt.Sprintf("生成时间: %s", time.Now().Format("2006-01-02 15:04:05")), ""}, openMismatches...), "\n")
-		if err := os.WriteFile(reportPath, []byte(reportContent), 0644); err != nil {
-			log.Printf("⚠ 写入开仓不匹配报告失败: %v", err)
-		} else {
-			log.Printf("📊 已生成开仓不匹配报告: %s (%d 条)", reportPath, len(openMismatches))
-		}
+		rep.writeTrader(src.reportDir(traderID), traderID, "open_mismatch_report", []string{"=== 开仓数据核对报告 ===", fmt.Sprintf("生成时间: %s", time.Now().Format("2006-01-02 15:04:05")), ""}, openMismatches)
 		// 同时输出到日志
 		for _, msg := range openMismatches {
 			log.Println(msg)
@@ -968,6 +1437,28 @@ func reconcileTrader(dir string, traderID string, orders map[string][]BinanceOrd
 	return nil
 }
 
+// writeReconcileFile 写入补全文件 decision_reconcile_*（预演时只记录拟新建的文件），写入失败计入运行汇总
+func writeReconcileFile(src *logSource, traderID string, act DecisionAction, dry *dryRun, rep *runReport, jr *journal) (string, bool) {
+	fname := fmt.Sprintf("decision_reconcile_%s_%d.json", time.Now().Format("20060102_150405"), act.OrderID)
+	path := src.location(traderID, fname)
+	b, err := decisionlog.Encode(DecisionRecordPart{Decisions: []DecisionAction{act}})
+	if err == nil && dry != nil {
+		dry.create(path, b)
+		return path, true
+	}
+	if err == nil {
+		err = src.store.Create(decisionlog.Record{TraderID: traderID, Name: fname, Data: b})
+	}
+	if err != nil {
+		msg := fmt.Sprintf("⚠ 写入补全文件失败 %s: %v", path, err)
+		rep.failure(traderID, msg)
+		log.Println(msg)
+		return path, false
+	}
+	jr.create(path, b)
+	return path, true
+}
+
 // ---------- 辅助 ----------
 func sideFromAction(action string) string {
 	if strings.Contains(action, "long") {
@@ -1044,36 +1535,83 @@ func getOrderLists(group map[string][]BinanceOrder, traderID, symbol, posSide st
 	return res
 }
 
-func abs64(v int64) int64 {
-	if v < 0 {
-		return -v
+// matchByClientOrderID 按 clientOrderId 查找订单：找到时 found 为 true，订单满足 ok 才作为候选返回
+// （clientOrderId 唯一确定订单，方向或状态不符时不再回退到时间窗口匹配）；未记录 id 或订单表中没有该 id 时回退
+func matchByClientOrderID(lists [][]BinanceOrder, clientOrderID string, ok func(o *BinanceOrder) bool) (candidate *BinanceOrder, found bool) {
+	if clientOrderID == "" {
+		return nil, false
 	}
-	return v
+	for _, lst := range lists {
+		for idx := range lst {
+			if lst[idx].ClientOrderID != clientOrderID {
+				continue
+			}
+			o := lst[idx]
+			if ok(&o) {
+				return &o, true
+			}
+			return nil, true
+		}
+	}
+	return nil, false
 }
 
-// ========= 工具函数 =========
+// clientOrderIDNote clientOrderId 命中但订单不可用时附加到报告的说明
+func clientOrderIDNote(clientOrderID string, exact bool) string {
+	if !exact {
+		return ""
+	}
+	return fmt.Sprintf(", clientOrderId %s 对应订单方向或状态不符", clientOrderID)
+}
 
-// writeUpdatedFilePreserve 读取 src JSON，保留除 decisions 外的所有顶层字段，仅替换 decisions 后写入 dst
-func writeUpdatedFilePreserve(srcPath, dstPath string, newActs []DecisionAction) error {
-	data, err := os.ReadFile(srcPath)
-	if err != nil {
-		return err
+// mergeOrderLists 合并多个订单列表并按时间排序
+func mergeOrderLists(lists [][]BinanceOrder) []BinanceOrder {
+	var res []BinanceOrder
+	for _, lst := range lists {
+		res = append(res, lst...)
 	}
-	var obj map[string]any
-	if err := json.Unmarshal(data, &obj); err != nil {
-		// 回退：若不是对象结构，直接写最小结构
-		rec := DecisionRecordPart{Decisions: newActs}
-		b, _ := json.MarshalIndent(rec, "", "  ")
-		return os.WriteFile(dstPath, b, 0644)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Time < res[j].Time })
+	return res
+}
+
+// findCycleClose 在区间 [开仓, 下一次开仓) 内查找日志未记录的平仓订单
+// 非最后区间只接受完全平仓（closePosition 或成交数量覆盖区间持仓），否则无法说明下一次开仓是新仓位；
+// 最后区间（仓位仍未平）取开仓后第一个平仓订单
+func findCycleClose(candidates []BinanceOrder, side string, cyc positionCycle, rule MatchTolerance, used map[int64]bool) *BinanceOrder {
+	from := cyc.Open.Timestamp.UnixMilli()
+	for i := range candidates {
+		o := &candidates[i]
+		if o.Time < from {
+			continue
+		}
+		if !cyc.Last && o.Time >= cyc.To.UnixMilli() {
+			break
+		}
+		if used[o.OrderID] || !orderClosesPosition(o, side) {
+			continue
+		}
+		// 🔧 只使用可接受状态（默认 FILLED）且有成交数量和价格的订单
+		if !rule.accepts(o) {
+			continue
+		}
+		qty := parseFloat(o.ExecutedQty)
+		if !cyc.Last && !o.ClosePosition && (cyc.Qty <= 0 || qty < cyc.Qty*0.99) {
+			continue
+		}
+		return o
 	}
-	obj["decisions"] = newActs
-	b, err := json.MarshalIndent(obj, "", "  ")
-	if err != nil {
-		return err
+	return nil
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
 	}
-	return os.WriteFile(dstPath, b, 0644)
+	return v
 }
 
+// ========= 工具函数 =========
+
 func parseFloat(s string) float64 { f, _ := strconv.ParseFloat(s, 64); return f }
 
 func boolToInt(b bool) int {
@@ -1106,22 +1644,49 @@ func latestOrderID(list []BinanceOrder) int64 {
 type binanceREST struct {
 	apiKey    string
 	secretKey string
+	product   string // fapi | dapi
 	baseURL   string
 	client    *http.Client
+	limiter   *weightLimiter // 共享的权重限制器（nil 表示不限速）
+}
+
+func newSignedClient(apiKey, secretKey string, base binanceEndpoint) *binanceREST {
+	return &binanceREST{apiKey: apiKey, secretKey: secretKey, product: base.product, baseURL: base.url, client: &http.Client{Timeout: 15 * time.Second}}
 }
 
-func newSignedClient(apiKey, secretKey, base string) *binanceREST {
-	url := "https://dapi.binance.com" // USDⓈ-M: fapi  / 币本位交割合约: dapi
-	if base == "fapi" {
-		url = "https://fapi.binance.com"
+// usdm 是否为 U 本位合约（fapi）；币本位为 dapi
+func (c *binanceREST) usdm() bool {
+	return c.product == "fapi"
+}
+
+// path 接口路径，如 /fapi/v1/allOrders
+func (c *binanceREST) path(name string) string {
+	return "/" + c.product + "/v1/" + name
+}
+
+func (c *binanceREST) Name() string                { return ExchangeBinance }
+func (c *binanceREST) APIKey() string              { return c.apiKey }
+func (c *binanceREST) SetLimiter(l *weightLimiter) { c.limiter = l }
+func (c *binanceREST) IDCursor() bool              { return true }
+
+// allOrdersWeight allOrders 的请求权重（fapi 为 5，dapi 带 symbol 时为 20）
+func (c *binanceREST) allOrdersWeight() int {
+	if c.usdm() {
+		return 5
 	}
-	return &binanceREST{apiKey: apiKey, secretKey: secretKey, baseURL: url, client: &http.Client{Timeout: 15 * time.Second}}
+	return 20
 }
 
-func (c *binanceREST) allOrders(symbol string, orderID, startTime, endTime int64) ([]BinanceOrder, []map[string]any, error) {
+// AllOrders 调用 allOrders（orderID>0 时返回该订单及之后的订单）
+func (c *binanceREST) AllOrders(symbol string, orderID, startTime, endTime int64) ([]BinanceOrder, []map[string]any, error) {
 	if symbol == "" {
 		return nil, nil, errors.New("symbol 不能为空")
 	}
+	// 先等待限速再签名，避免等待过久导致 timestamp 超出 recvWindow
+	ctx := context.Background()
+	if err := c.limiter.wait(ctx, c.allOrdersWeight()); err != nil {
+		return nil, nil, err
+	}
 
 	params := []string{fmt.Sprintf("symbol=%s", symbol)}
 	if orderID > 0 {
@@ -1134,25 +1699,23 @@ func (c *binanceREST) allOrders(symbol string, orderID, startTime, endTime int64
 		params = append(params, fmt.Sprintf("endTime=%d", endTime))
 	}
 	// 默认限制 100，并设置一个合理的 recvWindow
-	params = append(params, "limit=100")
+	params = append(params, fmt.Sprintf("limit=%d", allOrdersLimit))
 	params = append(params, "recvWindow=5000")
 	params = append(params, fmt.Sprintf("timestamp=%d", time.Now().UnixMilli()))
 	qs := strings.Join(params, "&")
 	// 签名
 	sig := hmacSHA256Hex(qs, c.secretKey)
-	path := "/dapi/v1/allOrders"
-	if strings.Contains(c.baseURL, "fapi") {
-		path = "/fapi/v1/allOrders"
-	}
+	path := c.path("allOrders")
 	url := fmt.Sprintf("%s%s?%s&signature=%s", c.baseURL, path, qs, sig)
 
-	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	req.Header.Set("X-MBX-APIKEY", c.apiKey)
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer resp.Body.Close()
+	c.limiter.observe(resp)
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 对账报告输出（-report_format / -report_dir）
+//
+//   - txt 且未指定 -report_dir：与历史行为一致，文本报告写入各交易员日志目录；
+//   - 其余情况：每次运行在报告目录下新建 <action>_<时间> 子目录，
+//     txt 为每个交易员一个文本文件 + summary.txt，csv 为 entries.csv + traders.csv，
+//     json 为 report.json，html 为 index.html 汇总页（不一致项、校正项与各交易员统计）。
+
+const (
+	ReportFormatTxt  = "txt"
+	ReportFormatCSV  = "csv"
+	ReportFormatJSON = "json"
+	ReportFormatHTML = "html"
+)
+
+// defaultReportDir 非 txt 格式未指定 -report_dir 时的报告目录（不能放在决策目录下，否则会被当作交易员目录遍历）
+var defaultReportDir = filepath.Join("tools", "log_reconcile", "reports")
+
+// 报告条目类型
+const (
+	ReportKindCorrection = "correction" // 校正（含严重级别与处理状态）
+	ReportKindIssue      = "issue"      // 仅报告的不一致项
+	ReportKindViolation  = "violation"  // 仓位台账违规（Data 为 Violation）
+	ReportKindNote       = "note"       // 提示信息
+	ReportKindFailure    = "failure"    // 处理失败（读写文件、对账出错等）
+)
+
+// ReportEntry 报告中的一条记录
+type ReportEntry struct {
+	TraderID string `json:"trader_id"`
+	Kind     string `json:"kind"`
+	Severity string `json:"severity,omitempty"`
+	Status   string `json:"status,omitempty"` // 已应用/仅报告/预演
+	Message  string `json:"message"`
+	Data     any    `json:"data,omitempty"` // 结构化详情
+}
+
+// TraderStats 单个交易员的对账统计
+type TraderStats struct {
+	TraderID    string `json:"trader_id"`
+	Files       int    `json:"files"`       // 扫描的决策日志文件数
+	Actions     int    `json:"actions"`     // 成功执行的决策动作数
+	Corrections int    `json:"corrections"` // 校正条数
+	Applied     int    `json:"applied"`     // 已应用（预演时为将会应用）的校正条数
+	Issues      int    `json:"issues"`      // 仅报告的不一致项
+	Failures    int    `json:"failures"`    // 处理失败数
+	Summary     string `json:"summary,omitempty"`
+}
+
+// runReport 一次运行的报告收集器
+type runReport struct {
+	action  string
+	format  string
+	dir     string // 本次运行的报告目录（legacy 时为空）
+	started time.Time
+	dry     *dryRun
+	traders map[string]*TraderStats
+	order   []string
+	entries []ReportEntry
+}
+
+func newRunReport(action, format, reportDir string, dry *dryRun) (*runReport, error) {
+	switch format {
+	case "":
+		format = ReportFormatTxt
+	case ReportFormatTxt, ReportFormatCSV, ReportFormatJSON, ReportFormatHTML:
+	default:
+		return nil, fmt.Errorf("未知报告格式: %s（可选 txt|csv|json|html）", format)
+	}
+	r := &runReport{action: action, format: format, started: time.Now(), dry: dry, traders: make(map[string]*TraderStats)}
+	if format != ReportFormatTxt && reportDir == "" {
+		reportDir = defaultReportDir
+	}
+	if reportDir != "" {
+		r.dir = filepath.Join(reportDir, fmt.Sprintf("%s_%s", action, r.started.Format("20060102_150405")))
+	}
+	return r, nil
+}
+
+// legacy 是否沿用写入交易员日志目录的文本报告
+func (r *runReport) legacy() bool {
+	return r.dir == ""
+}
+
+// stats 返回交易员统计（首次访问时创建）
+func (r *runReport) stats(traderID string) *TraderStats {
+	s, ok := r.traders[traderID]
+	if !ok {
+		s = &TraderStats{TraderID: traderID}
+		r.traders[traderID] = s
+		r.order = append(r.order, traderID)
+	}
+	return s
+}
+
+// correction 记录一条校正
+func (r *runReport) correction(c Correction, status string, applied bool) {
+	s := r.stats(c.TraderID)
+	s.Corrections++
+	if applied {
+		s.Applied++
+	}
+	r.entries = append(r.entries, ReportEntry{TraderID: c.TraderID, Kind: ReportKindCorrection, Severity: string(c.Severity), Status: status, Message: c.Description})
+}
+
+// issue 记录一条仅报告的不一致项
+func (r *runReport) issue(traderID, msg string) {
+	r.stats(traderID).Issues++
+	r.entries = append(r.entries, ReportEntry{TraderID: traderID, Kind: ReportKindIssue, Message: msg})
+}
+
+// violation 记录一条仓位台账违规
+func (r *runReport) violation(v Violation) {
+	r.stats(v.TraderID).Issues++
+	r.entries = append(r.entries, ReportEntry{TraderID: v.TraderID, Kind: ReportKindViolation, Severity: v.Code, Message: formatViolation(v), Data: v})
+}
+
+// failure 记录一条处理失败
+func (r *runReport) failure(traderID, msg string) {
+	r.stats(traderID).Failures++
+	r.entries = append(r.entries, ReportEntry{TraderID: traderID, Kind: ReportKindFailure, Message: msg})
+}
+
+// note 记录一条提示
+func (r *runReport) note(traderID, msg string) {
+	r.stats(traderID)
+	r.entries = append(r.entries, ReportEntry{TraderID: traderID, Kind: ReportKindNote, Message: msg})
+}
+
+// writeTrader 输出单个交易员的文本报告：legacy 时写入交易员日志目录，txt 格式写入本次运行目录，其余格式在 finish 中统一输出
+func (r *runReport) writeTrader(dir, traderID, name string, header, lines []string) {
+	if len(lines) == 0 || (!r.legacy() && r.format != ReportFormatTxt) {
+		return
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.txt", name, time.Now().Format("20060102_150405")))
+	if !r.legacy() {
+		path = filepath.Join(r.dir, fmt.Sprintf("%s_%s.txt", safeFileName(traderID), name))
+	}
+	content := strings.Join(append(append([]string(nil), header...), lines...), "\n")
+	if err := r.write(path, []byte(content)); err != nil {
+		log.Printf("⚠ 写入报告失败 %s: %v", path, err)
+		return
+	}
+	if r.dry == nil {
+		log.Printf("📊 [%s] 已生成报告: %s (%d 条)", traderID, path, len(lines))
+	}
+}
+
+// finish 输出本次运行的汇总（legacy 时无汇总）
+func (r *runReport) finish() error {
+	if r.legacy() {
+		return nil
+	}
+	var files map[string][]byte
+	var err error
+	switch r.format {
+	case ReportFormatTxt:
+		files = map[string][]byte{"summary.txt": r.renderSummaryText()}
+	case ReportFormatCSV:
+		files, err = r.renderCSV()
+	case ReportFormatJSON:
+		var b []byte
+		b, err = r.renderJSON()
+		files = map[string][]byte{"report.json": b}
+	case ReportFormatHTML:
+		var b []byte
+		b, err = r.renderHTML()
+		files = map[string][]byte{"index.html": b}
+	}
+	if err != nil {
+		return fmt.Errorf("生成报告失败: %w", err)
+	}
+	for name, content := range files {
+		path := filepath.Join(r.dir, name)
+		if err := r.write(path, content); err != nil {
+			return fmt.Errorf("写入报告失败 %s: %w", path, err)
+		}
+		if r.dry == nil {
+			log.Printf("📊 已生成报告: %s", path)
+		}
+	}
+	return nil
+}
+
+// write 写入报告文件（预演时只记录拟新建的文件）
+func (r *runReport) write(path string, content []byte) error {
+	if r.dry != nil {
+		r.dry.create(path, content)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// sortedStats 按首次出现顺序返回交易员统计
+func (r *runReport) sortedStats() []*TraderStats {
+	out := make([]*TraderStats, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.traders[id])
+	}
+	return out
+}
+
+func (r *runReport) renderSummaryText() []byte {
+	lines := []string{
+		fmt.Sprintf("=== 对账汇总 (%s) ===", r.action),
+		fmt.Sprintf("生成时间: %s", r.started.Format("2006-01-02 15:04:05")),
+		"",
+	}
+	for _, s := range r.sortedStats() {
+		line := fmt.Sprintf("[%s] 文件 %d, 动作 %d, 校正 %d (应用 %d), 不一致 %d, 失败 %d", s.TraderID, s.Files, s.Actions, s.Corrections, s.Applied, s.Issues, s.Failures)
+		if s.Summary != "" {
+			line += " | " + s.Summary
+		}
+		lines = append(lines, line)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func (r *runReport) renderCSV() (map[string][]byte, error) {
+	var entries, traders bytes.Buffer
+	w := csv.NewWriter(&entries)
+	_ = w.Write([]string{"trader_id", "kind", "severity", "status", "message"})
+	for _, e := range r.entries {
+		_ = w.Write([]string{e.TraderID, e.Kind, e.Severity, e.Status, e.Message})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	w = csv.NewWriter(&traders)
+	_ = w.Write([]string{"trader_id", "files", "actions", "corrections", "applied", "issues", "failures", "summary"})
+	for _, s := range r.sortedStats() {
+		_ = w.Write([]string{s.TraderID, strconv.Itoa(s.Files), strconv.Itoa(s.Actions), strconv.Itoa(s.Corrections), strconv.Itoa(s.Applied), strconv.Itoa(s.Issues), strconv.Itoa(s.Failures), s.Summary})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return map[string][]byte{"entries.csv": entries.Bytes(), "traders.csv": traders.Bytes()}, nil
+}
+
+// reportDocument json/html 报告的完整内容
+type reportDocument struct {
+	Action      string         `json:"action"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	DryRun      bool           `json:"dry_run"`
+	Traders     []*TraderStats `json:"traders"`
+	Entries     []ReportEntry  `json:"entries"`
+}
+
+func (r *runReport) document() reportDocument {
+	entries := r.entries
+	if entries == nil {
+		entries = []ReportEntry{}
+	}
+	return reportDocument{Action: r.action, GeneratedAt: r.started, DryRun: r.dry != nil, Traders: r.sortedStats(), Entries: entries}
+}
+
+func (r *runReport) renderJSON() ([]byte, error) {
+	return json.MarshalIndent(r.document(), "", "  ")
+}
+
+var reportHTMLTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>对账报告 {{.Action}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",sans-serif;margin:24px;color:#222}
+table{border-collapse:collapse;margin:12px 0 24px;width:100%}
+th,td{border:1px solid #ddd;padding:6px 8px;text-align:left;font-size:13px;vertical-align:top}
+th{background:#f5f5f5}
+td.num{text-align:right}
+.major{color:#c0392b;font-weight:bold}.minor{color:#d35400}.info{color:#2980b9}
+.muted{color:#888}
+</style>
+</head>
+<body>
+<h1>对账报告：{{.Action}}</h1>
+<p class="muted">生成时间 {{.GeneratedAt.Format "2006-01-02 15:04:05"}}{{if .DryRun}} · 预演（未修改任何文件）{{end}}</p>
+<h2>交易员统计</h2>
+<table>
+<tr><th>交易员</th><th>文件</th><th>动作</th><th>校正</th><th>已应用</th><th>不一致</th><th>失败</th><th>摘要</th></tr>
+{{range .Traders}}<tr><td>{{.TraderID}}</td><td class="num">{{.Files}}</td><td class="num">{{.Actions}}</td><td class="num">{{.Corrections}}</td><td class="num">{{.Applied}}</td><td class="num">{{.Issues}}</td><td class="num">{{.Failures}}</td><td>{{.Summary}}</td></tr>
+{{else}}<tr><td colspan="8" class="muted">无</td></tr>
+{{end}}</table>
+<h2>校正</h2>
+<table>
+<tr><th>交易员</th><th>级别</th><th>状态</th><th>说明</th></tr>
+{{range .Entries}}{{if eq .Kind "correction"}}<tr><td>{{.TraderID}}</td><td class="{{.Severity}}">{{.Severity}}</td><td>{{.Status}}</td><td>{{.Message}}</td></tr>
+{{end}}{{end}}</table>
+<h2>不一致与提示</h2>
+<table>
+<tr><th>交易员</th><th>类型</th><th>说明</th></tr>
+{{range .Entries}}{{if ne .Kind "correction"}}<tr><td>{{.TraderID}}</td><td>{{.Kind}}</td><td>{{.Message}}</td></tr>
+{{end}}{{end}}</table>
+</body>
+</html>
+`))
+
+func (r *runReport) renderHTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := reportHTMLTemplate.Execute(&buf, r.document()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// safeFileName 替换文件名中不安全的字符
+func safeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, s)
+}
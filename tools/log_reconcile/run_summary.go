@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// 运行汇总与退出码
+//
+// 非常驻模式结束时向标准输出打印一行 JSON 汇总，并按结果设置退出码，便于 CI/cron 判断：
+// 有处理失败（拉取失败、读写文件失败、对账出错）优先返回 ExitFailure，其次有不一致或校正返回 ExitMismatch。
+
+// 退出码
+const (
+	ExitOK       = 0
+	ExitFatal    = 1 // 参数错误、数据库无法打开等（log.Fatalf）
+	ExitMismatch = 2 // 发现不一致或产生校正
+	ExitFailure  = 3 // 部分交易员/交易对处理失败
+)
+
+// 汇总状态
+const (
+	SummaryStatusOK       = "ok"
+	SummaryStatusMismatch = "mismatch"
+	SummaryStatusFailure  = "failure"
+)
+
+// RunSummary 一次运行的机器可读汇总
+type RunSummary struct {
+	Action      string `json:"action"`
+	Status      string `json:"status"`
+	ExitCode    int    `json:"exit_code"`
+	DryRun      bool   `json:"dry_run"`
+	Traders     int    `json:"traders"`
+	Mismatches  int    `json:"mismatches"`  // 仅报告的不一致项与台账违规
+	Corrections int    `json:"corrections"` // 校正条数
+	Applied     int    `json:"applied"`     // 已应用（预演时为将会应用）的校正
+	Pending     int    `json:"pending"`     // 未应用（仅报告/待审批）的校正
+	Failures    int    `json:"failures"`    // 处理失败数
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+// summary 按各交易员统计生成汇总
+func (r *runReport) summary() RunSummary {
+	s := RunSummary{Action: r.action, DryRun: r.dry != nil, Traders: len(r.traders), DurationMs: time.Since(r.started).Milliseconds()}
+	for _, t := range r.traders {
+		s.Mismatches += t.Issues
+		s.Corrections += t.Corrections
+		s.Applied += t.Applied
+		s.Failures += t.Failures
+	}
+	s.Pending = s.Corrections - s.Applied
+	switch {
+	case s.Failures > 0:
+		s.Status, s.ExitCode = SummaryStatusFailure, ExitFailure
+	case s.Mismatches > 0 || s.Corrections > 0:
+		s.Status, s.ExitCode = SummaryStatusMismatch, ExitMismatch
+	default:
+		s.Status, s.ExitCode = SummaryStatusOK, ExitOK
+	}
+	return s
+}
+
+// write 输出一行 JSON
+func (s RunSummary) write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
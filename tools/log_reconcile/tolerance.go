@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// 匹配容差（tolerance）
+//
+// 决策动作与交易所订单的匹配规则：时间窗口、数量/价格偏差阈值、可接受的订单状态。
+// 规则按以下顺序逐层覆盖（只覆盖显式设置的字段）：
+//   内置默认（按动作）→ 配置文件 default → 配置文件 actions[动作] → traders[交易员].default → traders[交易员].actions[动作]
+// 命令行参数 -time_window/-qty_dev/-price_dev/-accept_status 覆盖配置文件的 default。
+
+// MatchTolerance 解析后的匹配规则
+type MatchTolerance struct {
+	Window       time.Duration // 订单时间与决策时间的最大差值
+	QtyDev       float64       // 数量相对偏差阈值，超过即校正
+	PriceDev     float64       // 价格相对偏差阈值
+	AcceptStatus []string      // 可接受的订单状态（大写），且必须有成交数量与价格
+}
+
+// toleranceRule 配置文件中的一条规则（未设置的字段沿用上一层）
+type toleranceRule struct {
+	TimeWindow     string   `json:"time_window,omitempty" yaml:"time_window,omitempty"` // 如 "30m"
+	QtyDeviation   *float64 `json:"qty_deviation,omitempty" yaml:"qty_deviation,omitempty"`
+	PriceDeviation *float64 `json:"price_deviation,omitempty" yaml:"price_deviation,omitempty"`
+	AcceptStatus   []string `json:"accept_status,omitempty" yaml:"accept_status,omitempty"`
+}
+
+// toleranceScope 一组规则：全部动作的默认值 + 按动作覆盖
+type toleranceScope struct {
+	Default *toleranceRule            `json:"default,omitempty" yaml:"default,omitempty"`
+	Actions map[string]*toleranceRule `json:"actions,omitempty" yaml:"actions,omitempty"`
+}
+
+// ToleranceConfig 容差配置文件（JSON 或 YAML）
+type ToleranceConfig struct {
+	Default *toleranceRule            `json:"default,omitempty" yaml:"default,omitempty"`
+	Actions map[string]*toleranceRule `json:"actions,omitempty" yaml:"actions,omitempty"`
+	Traders map[string]toleranceScope `json:"traders,omitempty" yaml:"traders,omitempty"`
+}
+
+// defaultTolerance 内置默认（与历史行为一致）：±30 分钟、1% 偏差、仅接受 FILLED；
+// partial_close 偏差 5%，并接受有成交数量的 PARTIALLY_FILLED/CANCELED
+// update_stop_loss/update_take_profit 只比对条件单触发价（偏差 0.5%），接受未触发、已触发、已撤销或已过期的条件单
+func defaultTolerance(action string) MatchTolerance {
+	switch action {
+	case "partial_close":
+		return MatchTolerance{Window: 30 * time.Minute, QtyDev: 0.05, PriceDev: 0.05, AcceptStatus: []string{"FILLED", "PARTIALLY_FILLED", "CANCELED"}}
+	case "update_stop_loss", "update_take_profit":
+		return MatchTolerance{Window: 30 * time.Minute, QtyDev: 0.01, PriceDev: 0.005, AcceptStatus: []string{"NEW", "FILLED", "CANCELED", "EXPIRED"}}
+	}
+	return MatchTolerance{Window: 30 * time.Minute, QtyDev: 0.01, PriceDev: 0.01, AcceptStatus: []string{"FILLED"}}
+}
+
+// matchTolerances 按 交易员/动作 解析匹配规则（nil 时使用内置默认）
+type matchTolerances struct {
+	cfg ToleranceConfig
+}
+
+// loadTolerances 读取配置文件（可为空）并叠加命令行覆盖项
+func loadTolerances(path string, override *toleranceRule) (*matchTolerances, error) {
+	var cfg ToleranceConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取容差配置失败: %w", err)
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			err = json.Unmarshal(data, &cfg)
+		default:
+			err = yaml.Unmarshal(data, &cfg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析容差配置 %s 失败: %w", path, err)
+		}
+	}
+	if override != nil {
+		if cfg.Default == nil {
+			cfg.Default = &toleranceRule{}
+		}
+		merged := *cfg.Default
+		if override.TimeWindow != "" {
+			merged.TimeWindow = override.TimeWindow
+		}
+		if override.QtyDeviation != nil {
+			merged.QtyDeviation = override.QtyDeviation
+		}
+		if override.PriceDeviation != nil {
+			merged.PriceDeviation = override.PriceDeviation
+		}
+		if len(override.AcceptStatus) > 0 {
+			merged.AcceptStatus = override.AcceptStatus
+		}
+		cfg.Default = &merged
+	}
+	t := &matchTolerances{cfg: cfg}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// validate 启动时校验所有规则，避免对账中途才发现配置错误
+func (t *matchTolerances) validate() error {
+	check := func(where string, r *toleranceRule) error {
+		if r == nil {
+			return nil
+		}
+		if _, err := r.apply(defaultTolerance("")); err != nil {
+			return fmt.Errorf("容差配置 %s: %w", where, err)
+		}
+		return nil
+	}
+	if err := check("default", t.cfg.Default); err != nil {
+		return err
+	}
+	for action, r := range t.cfg.Actions {
+		if err := check("actions."+action, r); err != nil {
+			return err
+		}
+	}
+	for traderID, scope := range t.cfg.Traders {
+		if err := check("traders."+traderID+".default", scope.Default); err != nil {
+			return err
+		}
+		for action, r := range scope.Actions {
+			if err := check("traders."+traderID+".actions."+action, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply 把规则中显式设置的字段覆盖到 base 上
+func (r *toleranceRule) apply(base MatchTolerance) (MatchTolerance, error) {
+	if r == nil {
+		return base, nil
+	}
+	if r.TimeWindow != "" {
+		d, err := time.ParseDuration(r.TimeWindow)
+		if err != nil || d <= 0 {
+			return base, fmt.Errorf("无效的 time_window: %q", r.TimeWindow)
+		}
+		base.Window = d
+	}
+	if r.QtyDeviation != nil {
+		if *r.QtyDeviation < 0 {
+			return base, fmt.Errorf("qty_deviation 不能为负: %v", *r.QtyDeviation)
+		}
+		base.QtyDev = *r.QtyDeviation
+	}
+	if r.PriceDeviation != nil {
+		if *r.PriceDeviation < 0 {
+			return base, fmt.Errorf("price_deviation 不能为负: %v", *r.PriceDeviation)
+		}
+		base.PriceDev = *r.PriceDeviation
+	}
+	if len(r.AcceptStatus) > 0 {
+		var statuses []string
+		for _, s := range r.AcceptStatus {
+			s = strings.ToUpper(strings.TrimSpace(s))
+			switch s {
+			case "FILLED", "PARTIALLY_FILLED", "CANCELED", "EXPIRED":
+			default:
+				return base, fmt.Errorf("未知订单状态: %s（可选 FILLED|PARTIALLY_FILLED|CANCELED|EXPIRED）", s)
+			}
+			statuses = append(statuses, s)
+		}
+		base.AcceptStatus = statuses
+	}
+	return base, nil
+}
+
+// forAction 解析 交易员+动作 的匹配规则（配置已在加载时校验）
+func (t *matchTolerances) forAction(traderID, action string) MatchTolerance {
+	tol := defaultTolerance(action)
+	if t == nil {
+		return tol
+	}
+	layers := []*toleranceRule{t.cfg.Default, t.cfg.Actions[action]}
+	if scope, ok := t.cfg.Traders[traderID]; ok {
+		layers = append(layers, scope.Default, scope.Actions[action])
+	}
+	for _, r := range layers {
+		tol, _ = r.apply(tol)
+	}
+	return tol
+}
+
+// windowMs 时间窗口（毫秒）
+func (m MatchTolerance) windowMs() int64 {
+	return m.Window.Milliseconds()
+}
+
+// accepts 订单状态可接受且有成交数量与价格
+func (m MatchTolerance) accepts(o *BinanceOrder) bool {
+	if !m.acceptsStatus(o) {
+		return false
+	}
+	return parseFloat(o.ExecutedQty) > 0 && safePrice(o) > 0
+}
+
+// acceptsStatus 只检查订单状态（条件单未触发时没有成交）
+func (m MatchTolerance) acceptsStatus(o *BinanceOrder) bool {
+	return slices.Contains(m.AcceptStatus, strings.ToUpper(o.Status))
+}
+
+// deviates 数量或价格偏差超过阈值
+func (m MatchTolerance) deviates(wantQty, gotQty, wantPrice, gotPrice float64) bool {
+	return deviation(wantQty, gotQty) > m.QtyDev || deviation(wantPrice, gotPrice) > m.PriceDev
+}
+
+// parseStatusList 解析逗号分隔的订单状态
+func parseStatusList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
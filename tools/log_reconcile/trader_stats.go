@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 交易员绩效统计（stats）
+//
+// 按决策日志回放已平仓的仓位（与 pnl-reconcile 相同），每个仓位的盈亏优先取交易所记录
+// （仓位期间的 REALIZED_PNL + COMMISSION + FUNDING_FEE），没有收益记录时使用日志价格计算的盈亏。
+// 在此基础上统计胜率、平均持仓时长、盈亏比、最大回撤（按平仓时间累计净盈亏的峰谷差）与按交易对的盈亏，
+// -report_format csv 输出 performance.csv / performance_symbols.csv / positions.csv，json 输出 performance.json（含各仓位）。
+
+// PositionResult 单个已平仓仓位的结果
+type PositionResult struct {
+	TraderID  string    `json:"trader_id"`
+	Symbol    string    `json:"symbol"`
+	Side      string    `json:"side"`
+	OpenTime  time.Time `json:"open_time"`
+	CloseTime time.Time `json:"close_time"`
+	PnL       float64   `json:"pnl"`
+	Source    string    `json:"source"` // exchange | log
+}
+
+// SymbolPerformance 单个交易对的统计
+type SymbolPerformance struct {
+	Symbol string  `json:"symbol"`
+	Trades int     `json:"trades"`
+	Wins   int     `json:"wins"`
+	PnL    float64 `json:"pnl"`
+}
+
+// TraderPerformance 单个交易员的绩效统计
+type TraderPerformance struct {
+	TraderID       string              `json:"trader_id"`
+	Trades         int                 `json:"trades"`
+	Wins           int                 `json:"wins"`
+	Losses         int                 `json:"losses"`
+	WinRate        float64             `json:"win_rate"`
+	NetPnL         float64             `json:"net_pnl"`
+	GrossProfit    float64             `json:"gross_profit"`
+	GrossLoss      float64             `json:"gross_loss"`
+	ProfitFactor   *float64            `json:"profit_factor"` // 无亏损时为 null
+	MaxDrawdown    float64             `json:"max_drawdown"`
+	AvgHoldingSec  float64             `json:"avg_holding_sec"`
+	ExchangeTrades int                 `json:"exchange_trades"` // 盈亏取自交易所记录的仓位数
+	Symbols        []SymbolPerformance `json:"symbols"`
+	Positions      []PositionResult    `json:"positions"`
+}
+
+// performanceOf 由按平仓时间排序的仓位结果计算统计
+func performanceOf(traderID string, results []PositionResult) TraderPerformance {
+	p := TraderPerformance{TraderID: traderID, Trades: len(results), Positions: results, Symbols: []SymbolPerformance{}}
+	bySymbol := make(map[string]*SymbolPerformance)
+	var equity, peak, holding float64
+	for _, r := range results {
+		p.NetPnL += r.PnL
+		switch {
+		case r.PnL > 0:
+			p.Wins++
+			p.GrossProfit += r.PnL
+		case r.PnL < 0:
+			p.Losses++
+			p.GrossLoss -= r.PnL
+		}
+		if r.Source == "exchange" {
+			p.ExchangeTrades++
+		}
+		holding += r.CloseTime.Sub(r.OpenTime).Seconds()
+		equity += r.PnL
+		peak = max(peak, equity)
+		p.MaxDrawdown = max(p.MaxDrawdown, peak-equity)
+
+		s, ok := bySymbol[r.Symbol]
+		if !ok {
+			s = &SymbolPerformance{Symbol: r.Symbol}
+			bySymbol[r.Symbol] = s
+		}
+		s.Trades++
+		s.PnL += r.PnL
+		if r.PnL > 0 {
+			s.Wins++
+		}
+	}
+	if p.Trades > 0 {
+		p.WinRate = float64(p.Wins) / float64(p.Trades)
+		p.AvgHoldingSec = holding / float64(p.Trades)
+	}
+	if p.GrossLoss > 0 {
+		pf := p.GrossProfit / p.GrossLoss
+		p.ProfitFactor = &pf
+	}
+	for _, s := range bySymbol {
+		p.Symbols = append(p.Symbols, *s)
+	}
+	// 按盈亏从高到低
+	sort.Slice(p.Symbols, func(i, j int) bool {
+		if p.Symbols[i].PnL != p.Symbols[j].PnL {
+			return p.Symbols[i].PnL > p.Symbols[j].PnL
+		}
+		return p.Symbols[i].Symbol < p.Symbols[j].Symbol
+	})
+	return p
+}
+
+// traderPerformance 回放交易员的已平仓仓位并计算统计
+func traderPerformance(src *logSource, traderID string, incomes map[string][]incomeRecord, stats *TraderStats) (TraderPerformance, error) {
+	positions, err := rebuildPnlPositions(src, traderID, stats)
+	if err != nil {
+		return TraderPerformance{}, err
+	}
+	results := make([]PositionResult, 0, len(positions))
+	for _, pos := range positions {
+		r := PositionResult{TraderID: traderID, Symbol: pos.Symbol, Side: pos.Side, OpenTime: pos.OpenTime, CloseTime: pos.CloseTime, PnL: pos.Realized, Source: "log"}
+		if s := sumIncome(incomes[traderID+"_"+pos.Symbol], pos.OpenTime, pos.CloseTime.Add(pnlMatchWindow)); s.Records > 0 {
+			r.PnL, r.Source = s.Realized+s.Commission+s.Funding, "exchange"
+		}
+		results = append(results, r)
+	}
+	return performanceOf(traderID, results), nil
+}
+
+// runStats 统计所有交易员的绩效并输出（只读，不修改日志）
+func runStats(db *sql.DB, src *logSource, rep *runReport) error {
+	log.Println("=== 开始交易员绩效统计 ===")
+	incomes, err := loadIncomeGrouped(db)
+	if err != nil {
+		return fmt.Errorf("加载收益记录失败: %w", err)
+	}
+	if len(incomes) == 0 {
+		log.Printf("ℹ income 表为空，盈亏按日志价格计算（不含手续费与资金费）")
+	}
+	traders, err := src.traders()
+	if err != nil {
+		return err
+	}
+	var perfs []TraderPerformance
+	for _, traderID := range traders {
+		stats := rep.stats(traderID)
+		p, err := traderPerformance(src, traderID, incomes, stats)
+		if err != nil {
+			msg := fmt.Sprintf("⚠ 统计 %s 失败: %v", traderID, err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
+			continue
+		}
+		stats.Summary = formatPerformance(p)
+		log.Printf("📈 [%s] %s", traderID, stats.Summary)
+		perfs = append(perfs, p)
+	}
+	if err := writePerformance(rep, perfs); err != nil {
+		return err
+	}
+	return rep.finish()
+}
+
+// formatPerformance 单行摘要
+func formatPerformance(p TraderPerformance) string {
+	if p.Trades == 0 {
+		return "无已平仓仓位"
+	}
+	pf := "∞"
+	if p.ProfitFactor != nil {
+		pf = fmt.Sprintf("%.2f", *p.ProfitFactor)
+	}
+	return fmt.Sprintf("仓位 %d, 胜率 %.1f%%, 净盈亏 %.4f, 盈亏比 %s, 最大回撤 %.4f, 平均持仓 %v",
+		p.Trades, p.WinRate*100, p.NetPnL, pf, p.MaxDrawdown, (time.Duration(p.AvgHoldingSec) * time.Second).Round(time.Minute))
+}
+
+// writePerformance 按报告格式导出统计（csv/json；txt/html 只在汇总中显示摘要）
+func writePerformance(rep *runReport, perfs []TraderPerformance) error {
+	files := make(map[string][]byte)
+	switch rep.format {
+	case ReportFormatJSON:
+		if perfs == nil {
+			perfs = []TraderPerformance{}
+		}
+		b, err := json.MarshalIndent(perfs, "", "  ")
+		if err != nil {
+			return err
+		}
+		files["performance.json"] = b
+	case ReportFormatCSV:
+		var traders, symbols, positions bytes.Buffer
+		tw, sw, pw := csv.NewWriter(&traders), csv.NewWriter(&symbols), csv.NewWriter(&positions)
+		_ = tw.Write([]string{"trader_id", "trades", "wins", "losses", "win_rate", "net_pnl", "gross_profit", "gross_loss", "profit_factor", "max_drawdown", "avg_holding_hours", "exchange_trades"})
+		_ = sw.Write([]string{"trader_id", "symbol", "trades", "wins", "pnl"})
+		_ = pw.Write([]string{"trader_id", "symbol", "side", "open_time", "close_time", "pnl", "source"})
+		for _, p := range perfs {
+			pf := ""
+			if p.ProfitFactor != nil {
+				pf = formatNum(*p.ProfitFactor)
+			}
+			_ = tw.Write([]string{p.TraderID, strconv.Itoa(p.Trades), strconv.Itoa(p.Wins), strconv.Itoa(p.Losses), formatNum(p.WinRate), formatNum(p.NetPnL),
+				formatNum(p.GrossProfit), formatNum(p.GrossLoss), pf, formatNum(p.MaxDrawdown), formatNum(p.AvgHoldingSec / 3600), strconv.Itoa(p.ExchangeTrades)})
+			for _, s := range p.Symbols {
+				_ = sw.Write([]string{p.TraderID, s.Symbol, strconv.Itoa(s.Trades), strconv.Itoa(s.Wins), formatNum(s.PnL)})
+			}
+			for _, r := range p.Positions {
+				_ = pw.Write([]string{r.TraderID, r.Symbol, r.Side, r.OpenTime.Format(time.RFC3339), r.CloseTime.Format(time.RFC3339), formatNum(r.PnL), r.Source})
+			}
+		}
+		for _, w := range []*csv.Writer{tw, sw, pw} {
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return err
+			}
+		}
+		files["performance.csv"] = traders.Bytes()
+		files["performance_symbols.csv"] = symbols.Bytes()
+		files["positions.csv"] = positions.Bytes()
+	default:
+		return nil
+	}
+	for name, content := range files {
+		path := filepath.Join(rep.dir, name)
+		if err := rep.write(path, content); err != nil {
+			return fmt.Errorf("写入统计失败 %s: %w", path, err)
+		}
+		if rep.dry == nil {
+			log.Printf("📈 已导出统计: %s", path)
+		}
+	}
+	return nil
+}
+
+// formatNum CSV 中的数值（最多 6 位小数，去掉末尾的 0）
+func formatNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e6)/1e6, 'f', -1, 64)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"nofx/decisions"
+	"strings"
+	"time"
+)
+
+// 止损/止盈条件单核对
+//
+// update_stop_loss/update_take_profit 在日志中只记录当时的市价，目标触发价来自同一记录的 decision_json
+// （new_stop_loss/new_take_profit）。交易员调整止损/止盈时会撤销旧条件单并下一张新的 STOP_MARKET/TAKE_PROFIT_MARKET，
+// 因此在决策时间窗口内应能找到同一交易对、平仓方向、触发价一致的条件单。
+// allOrders 按订单ID增量拉取，早先创建、仍未触发的条件单状态不会刷新，拉单时额外通过 openOrders 覆盖当前挂单。
+
+// openOrderLister 支持查询当前挂单（含条件单）的交易所
+type openOrderLister interface {
+	OpenOrders(symbol string) ([]BinanceOrder, []map[string]any, error)
+}
+
+// isTriggerAction 止损/止盈调整动作
+func isTriggerAction(action string) bool {
+	return action == "update_stop_loss" || action == "update_take_profit"
+}
+
+// triggerAction 条件单类型对应的调整动作（非条件单返回空）
+func triggerAction(o *BinanceOrder) string {
+	for _, t := range []string{o.OrigType, o.Type} {
+		switch strings.ToUpper(t) {
+		case "STOP_MARKET", "STOP", "STOPLOSS", "PARTIALSTOPLOSS":
+			return "update_stop_loss"
+		case "TAKE_PROFIT_MARKET", "TAKE_PROFIT", "TAKEPROFIT", "PARTIALTAKEPROFIT":
+			return "update_take_profit"
+		}
+	}
+	return ""
+}
+
+// parseDecisionPlans 解析记录的 decision_json（数组或单个对象，解析失败返回 nil）
+func parseDecisionPlans(s string) []decisions.Item {
+	if s == "" {
+		return nil
+	}
+	items, err := decisions.Decode(s)
+	if err != nil {
+		return nil
+	}
+	return items
+}
+
+// planTrigger 从 decision_json 中查找该交易对本次调整的目标触发价（未记录返回 0）
+func planTrigger(plans []decisions.Item, symbol, action string) float64 {
+	for _, d := range plans {
+		if d.Symbol != symbol || d.Action != action {
+			continue
+		}
+		if action == "update_stop_loss" {
+			return d.NewStopLoss
+		}
+		return d.NewTakeProfit
+	}
+	return 0
+}
+
+// findTriggerOrder 查找与止损/止盈调整对应的条件单
+// 优先返回触发价在偏差范围内、时间最接近的订单；只有触发价不一致的订单时返回最接近的一张，priceOK=false
+func findTriggerOrder(orders map[string][]BinanceOrder, traderID string, act DecisionAction, sides []string, target float64, rule MatchTolerance) (best *BinanceOrder, side string, priceOK bool) {
+	bestDelta := int64(1<<62 - 1)
+	seen := make(map[int64]bool)
+	for _, s := range sides {
+		for _, l := range getOrderLists(orders, traderID, act.Symbol, s) {
+			for idx := range l {
+				o := l[idx]
+				if seen[o.OrderID] || triggerAction(&o) != act.Action || !rule.acceptsStatus(&o) {
+					continue
+				}
+				// 止损/止盈单必须是该仓位的平仓方向（closePosition 或 reduceOnly，双向持仓按 positionSide）
+				if !orderClosesPosition(&o, s) {
+					continue
+				}
+				delta := abs64(o.Time - act.Timestamp.UnixMilli())
+				if delta > rule.windowMs() {
+					continue
+				}
+				seen[o.OrderID] = true
+				ok := target <= 0 || deviation(target, parseFloat(o.StopPrice)) <= rule.PriceDev
+				// 触发价一致的订单优先，其次按时间最接近
+				if best == nil || (ok && !priceOK) || (ok == priceOK && delta < bestDelta) {
+					best, side, priceOK, bestDelta = &o, s, ok, delta
+				}
+			}
+		}
+	}
+	return best, side, priceOK
+}
+
+// fetchOpenOrdersForSymbol 拉取当前挂单并覆盖写入 orders（刷新未触发条件单的状态）
+func fetchOpenOrdersForSymbol(db *sql.DB, client openOrderLister, traderID, symbol string) error {
+	orders, raw, err := client.OpenOrders(symbol)
+	if err != nil {
+		return fmt.Errorf("拉取挂单失败: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+	if err := writeTx(db, func(tx *sql.Tx) error {
+		return saveOrders(tx, traderID, symbol, orders, raw)
+	}); err != nil {
+		return err
+	}
+	log.Printf("✓ [%s] %s 当前挂单 %d 条", traderID, symbol, len(orders))
+	return nil
+}
+
+// OpenOrders 当前挂单（/fapi/v1/openOrders，带 symbol 时权重 1）
+func (c *binanceREST) OpenOrders(symbol string) ([]BinanceOrder, []map[string]any, error) {
+	if symbol == "" {
+		return nil, nil, fmt.Errorf("symbol 不能为空")
+	}
+	ctx := context.Background()
+	if err := c.limiter.wait(ctx, 1); err != nil {
+		return nil, nil, err
+	}
+	qs := fmt.Sprintf("symbol=%s&recvWindow=5000&timestamp=%d", symbol, time.Now().UnixMilli())
+	sig := hmacSHA256Hex(qs, c.secretKey)
+	path := c.path("openOrders")
+	url := fmt.Sprintf("%s%s?%s&signature=%s", c.baseURL, path, qs, sig)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req.Header.Set("X-MBX-APIKEY", c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	c.limiter.observe(resp)
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var raw []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	var list []BinanceOrder
+	var rawKept []map[string]any
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var bo BinanceOrder
+		if json.Unmarshal(b, &bo) == nil {
+			list = append(list, bo)
+			rawKept = append(rawKept, r)
+		}
+	}
+	return list, rawKept, nil
+}
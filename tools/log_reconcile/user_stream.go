@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"nofx/config"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 用户数据流监听（listen）
+//
+// 为 config.db 中每个绑定币安账户的交易员创建 listenKey（POST /fapi/v1/listenKey），连接 <stream>/ws/<listenKey>，
+// 把 ORDER_TRADE_UPDATE 事件实时写入 orders（成交事件同时写入 trades），对账无需等待下一次 allOrders 轮询。
+// listenKey 每 30 分钟续期一次（有效期 60 分钟）；连接断开、服务端 24 小时断线或收到 listenKeyExpired 时重新创建并重连，
+// 退避从 1 秒倍增到 1 分钟。事件只包含订单的当前状态，创建时间沿用表中已有记录；断线期间遗漏的事件由常规拉单补齐。
+// OKX/Bybit 交易员不支持，跳过。Ctrl+C / SIGTERM 时关闭连接并删除 listenKey。
+
+// 币安合约用户数据流站点
+const (
+	binanceFstreamURL        = "wss://fstream.binance.com"
+	binanceDstreamURL        = "wss://dstream.binance.com"
+	binanceTestnetFstreamURL = "wss://stream.binancefuture.com"
+	binanceTestnetDstreamURL = "wss://dstream.binancefuture.com"
+)
+
+const (
+	// listenKeyKeepalive listenKey 续期间隔（有效期 60 分钟）
+	listenKeyKeepalive = 30 * time.Minute
+	// streamMaxBackoff 重连退避上限
+	streamMaxBackoff = time.Minute
+)
+
+// resolveStreamURL 用户数据流根地址：-stream_url 优先，否则按接口地址推断（自定义 -base_url 时必须指定）
+func resolveStreamURL(ep binanceEndpoint, override string) (string, error) {
+	if override != "" {
+		if !strings.HasPrefix(override, "ws://") && !strings.HasPrefix(override, "wss://") {
+			return "", fmt.Errorf("-stream_url 需要 ws(s)://host 形式: %q", override)
+		}
+		return strings.TrimRight(override, "/"), nil
+	}
+	switch ep.url {
+	case binanceFapiURL:
+		return binanceFstreamURL, nil
+	case binanceDapiURL:
+		return binanceDstreamURL, nil
+	case binanceTestnetURL:
+		if ep.product == "dapi" {
+			return binanceTestnetDstreamURL, nil
+		}
+		return binanceTestnetFstreamURL, nil
+	}
+	return "", fmt.Errorf("自定义 -base_url 时需要同时指定 -stream_url")
+}
+
+// listenKeyRequest 创建（POST）、续期（PUT）或删除（DELETE）listenKey，权重 1，只需 API Key
+func (c *binanceREST) listenKeyRequest(ctx context.Context, method, listenKey string) (string, error) {
+	if err := c.limiter.wait(ctx, 1); err != nil {
+		return "", err
+	}
+	url := c.baseURL + c.path("listenKey")
+	if listenKey != "" {
+		url += "?listenKey=" + listenKey
+	}
+	req, _ := http.NewRequestWithContext(ctx, method, url, nil)
+	req.Header.Set("X-MBX-APIKEY", c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	c.limiter.observe(resp)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var res struct {
+		ListenKey string `json:"listenKey"`
+	}
+	if method == http.MethodPost {
+		if err := json.Unmarshal(body, &res); err != nil || res.ListenKey == "" {
+			return "", fmt.Errorf("listenKey 响应无法解析: %s", strings.TrimSpace(string(body)))
+		}
+	}
+	return res.ListenKey, nil
+}
+
+// orderTradeUpdate ORDER_TRADE_UPDATE 事件中的订单字段
+type orderTradeUpdate struct {
+	Symbol        string `json:"s"`
+	ClientOrderID string `json:"c"`
+	Side          string `json:"S"`
+	Type          string `json:"o"`
+	TimeInForce   string `json:"f"`
+	OrigQty       string `json:"q"`
+	Price         string `json:"p"`
+	AvgPrice      string `json:"ap"`
+	StopPrice     string `json:"sp"`
+	ExecType      string `json:"x"`
+	Status        string `json:"X"`
+	OrderID       int64  `json:"i"`
+	LastQty       string `json:"l"`
+	CumQty        string `json:"z"`
+	LastPrice     string `json:"L"`
+	FeeAsset      string `json:"N"`
+	Fee           string `json:"n"`
+	TradeTime     int64  `json:"T"`
+	TradeID       int64  `json:"t"`
+	Maker         bool   `json:"m"`
+	ReduceOnly    bool   `json:"R"`
+	WorkingType   string `json:"wt"`
+	OrigType      string `json:"ot"`
+	PositionSide  string `json:"ps"`
+	ClosePosition bool   `json:"cp"`
+	RealizedPnl   string `json:"rp"`
+}
+
+// userStreamEvent 用户数据流事件
+type userStreamEvent struct {
+	Event     string           `json:"e"`
+	EventTime int64            `json:"E"`
+	Order     orderTradeUpdate `json:"o"`
+}
+
+// toOrder 转换为 allOrders 格式的订单（Time 暂用事件时间，写入时保留已有记录的创建时间）
+func (u orderTradeUpdate) toOrder() BinanceOrder {
+	return BinanceOrder{
+		AvgPrice:      u.AvgPrice,
+		ClientOrderID: u.ClientOrderID,
+		ExecutedQty:   u.CumQty,
+		OrderID:       u.OrderID,
+		OrigQty:       u.OrigQty,
+		OrigType:      u.OrigType,
+		Price:         u.Price,
+		ReduceOnly:    u.ReduceOnly,
+		Side:          u.Side,
+		PositionSide:  u.PositionSide,
+		Status:        u.Status,
+		StopPrice:     u.StopPrice,
+		ClosePosition: u.ClosePosition,
+		Symbol:        u.Symbol,
+		Time:          u.TradeTime,
+		TimeInForce:   u.TimeInForce,
+		Type:          u.Type,
+		UpdateTime:    u.TradeTime,
+		WorkingType:   u.WorkingType,
+	}
+}
+
+// saveOrderUpdate 写入一条订单事件：保留已有订单的创建时间，成交事件同时写入 trades，并登记交易对供后续拉单
+func saveOrderUpdate(db *sql.DB, traderID string, u orderTradeUpdate) error {
+	ord := u.toOrder()
+	return writeTx(db, func(tx *sql.Tx) error {
+		var created int64
+		err := tx.QueryRow(`SELECT time FROM orders WHERE trader_id = ? AND symbol = ? AND order_id = ?`, traderID, u.Symbol, u.OrderID).Scan(&created)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("读取订单失败: %w", err)
+		}
+		if created > 0 {
+			ord.Time = created
+		}
+		var raw map[string]any
+		b, _ := json.Marshal(ord)
+		_ = json.Unmarshal(b, &raw)
+		if err := saveOrders(tx, traderID, u.Symbol, []BinanceOrder{ord}, []map[string]any{raw}); err != nil {
+			return err
+		}
+		if u.ExecType == "TRADE" && u.TradeID > 0 {
+			tradeRaw, _ := json.Marshal(u)
+			_, err := tx.Exec(`INSERT OR REPLACE INTO trades(trader_id, symbol, trade_id, order_id, side, position_side, price, qty, quote_qty, commission, commission_asset, realized_pnl, maker, time, raw_json)
+				VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+				traderID, u.Symbol, u.TradeID, u.OrderID, u.Side, u.PositionSide, parseFloat(u.LastPrice), parseFloat(u.LastQty), parseFloat(u.LastPrice)*parseFloat(u.LastQty),
+				parseFloat(u.Fee), u.FeeAsset, parseFloat(u.RealizedPnl), boolToInt(u.Maker), u.TradeTime, string(tradeRaw))
+			if err != nil {
+				return fmt.Errorf("写入成交失败: %w", err)
+			}
+		}
+		_, err = tx.Exec(`INSERT OR IGNORE INTO symbols(trader_id, symbol, first_seen) VALUES(?,?,?)`, traderID, u.Symbol, u.TradeTime)
+		return err
+	})
+}
+
+// userStream 单个交易员的用户数据流
+type userStream struct {
+	db        *sql.DB
+	traderID  string
+	client    *binanceREST
+	streamURL string
+}
+
+// run 保持连接直到 ctx 结束，断线后按退避重连
+func (s *userStream) run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		st := time.Now()
+		err := s.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// 连接维持较久后断开（如服务端 24 小时断线）不视为连续失败
+		if time.Since(st) > streamMaxBackoff {
+			backoff = time.Second
+		}
+		log.Printf("⚠ [%s] 用户数据流断开: %v，%v 后重连", s.traderID, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, streamMaxBackoff)
+	}
+}
+
+// session 创建 listenKey 并读取事件，直到连接断开、listenKey 过期或 ctx 结束
+func (s *userStream) session(ctx context.Context) error {
+	listenKey, err := s.client.listenKeyRequest(ctx, http.MethodPost, "")
+	if err != nil {
+		return fmt.Errorf("创建 listenKey 失败: %w", err)
+	}
+	defer func() {
+		// 退出时删除 listenKey（ctx 可能已结束，使用独立的超时）
+		dctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = s.client.listenKeyRequest(dctx, http.MethodDelete, listenKey)
+	}()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.streamURL+"/ws/"+listenKey, nil)
+	if err != nil {
+		return fmt.Errorf("连接用户数据流失败: %w", err)
+	}
+	defer conn.Close()
+	log.Printf("📡 [%s] 已连接用户数据流", s.traderID)
+
+	// 续期与关闭：ctx 结束或续期失败时关闭连接，使 ReadMessage 返回
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(listenKeyKeepalive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				if _, err := s.client.listenKeyRequest(ctx, http.MethodPut, listenKey); err != nil {
+					log.Printf("⚠ [%s] listenKey 续期失败: %v", s.traderID, err)
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var ev userStreamEvent
+		if err := json.Unmarshal(msg, &ev); err != nil {
+			log.Printf("⚠ [%s] 无法解析事件: %v", s.traderID, err)
+			continue
+		}
+		switch ev.Event {
+		case "ORDER_TRADE_UPDATE":
+			u := ev.Order
+			if err := saveOrderUpdate(s.db, s.traderID, u); err != nil {
+				log.Printf("⚠ [%s] %s 写入订单事件失败 (订单ID: %d): %v", s.traderID, u.Symbol, u.OrderID, err)
+				continue
+			}
+			metrics.add(metricOrdersFetched, 1, "trader", s.traderID, "exchange", ExchangeBinance)
+			log.Printf("📡 [%s] %s 订单 %d %s %s %s (成交 %s/%s)", s.traderID, u.Symbol, u.OrderID, u.Side, u.ExecType, u.Status, u.CumQty, u.OrigQty)
+		case "listenKeyExpired":
+			return fmt.Errorf("listenKey 已过期")
+		}
+	}
+}
+
+// listenUserStreams 为 config.db 中的币安交易员监听用户数据流，直到收到中断信号
+func listenUserStreams(db *sql.DB, configDBPath, userID string, base binanceEndpoint, streamURL string) error {
+	cfgDB, err := config.OpenDatabase(configDBPath)
+	if err != nil {
+		return err
+	}
+	creds, _, err := queryTraderCredentials(cfgDB, userID)
+	cfgDB.Close()
+	if err != nil {
+		return err
+	}
+	limiter := newWeightLimiter(defaultWeightPerMinute)
+	var streams []*userStream
+	for _, c := range creds {
+		if kind := exchangeKind(c.exchangeID, c.exchangeName); kind != ExchangeBinance {
+			log.Printf("ℹ 交易员 %s 使用 %s，不支持用户数据流，跳过", c.traderID, kind)
+			continue
+		}
+		client := newSignedClient(c.apiKey, "", base)
+		client.SetLimiter(limiter)
+		streams = append(streams, &userStream{db: db, traderID: c.traderID, client: client, streamURL: streamURL})
+	}
+	if len(streams) == 0 {
+		return fmt.Errorf("config.db 中没有可监听的币安交易员（user_id=%s）", userID)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("📡 开始监听 %d 个交易员的用户数据流（%s），Ctrl+C 退出", len(streams), streamURL)
+	var wg sync.WaitGroup
+	for _, s := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run(ctx)
+		}()
+	}
+	wg.Wait()
+	log.Printf("📡 已停止监听")
+	return nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 成交（userTrades）匹配
+//
+// allOrders 的 avgPrice 对部分订单类型为 0，且不含手续费与已实现盈亏。
+// 拉单时同时拉取 /fapi/v1/userTrades 存入 trades 表，对账时按订单聚合成交：
+// 成交量加权均价、累计成交数量、手续费与已实现盈亏覆盖订单上的数值。
+
+// tradesSchema 成交表与增量状态
+const tradesSchema = `CREATE TABLE IF NOT EXISTS trades(
+	trader_id TEXT,
+	symbol TEXT,
+	trade_id INTEGER,
+	order_id INTEGER,
+	side TEXT,
+	position_side TEXT,
+	price REAL,
+	qty REAL,
+	quote_qty REAL,
+	commission REAL,
+	commission_asset TEXT,
+	realized_pnl REAL,
+	maker INTEGER,
+	time INTEGER,
+	raw_json TEXT,
+	UNIQUE(trader_id, symbol, trade_id)
+);
+CREATE INDEX IF NOT EXISTS idx_trades_order ON trades(trader_id, symbol, order_id);
+CREATE TABLE IF NOT EXISTS trade_state(
+	trader_id TEXT,
+	symbol TEXT,
+	last_trade_id INTEGER,
+	last_fetch_time INTEGER,
+	PRIMARY KEY(trader_id, symbol)
+);`
+
+// userTradesLimit 单次请求的最大成交条数
+const userTradesLimit = 1000
+
+// BinanceTrade userTrades 返回的一笔成交
+type BinanceTrade struct {
+	ID              int64  `json:"id"`
+	OrderID         int64  `json:"orderId"`
+	Symbol          string `json:"symbol"`
+	Side            string `json:"side"`
+	PositionSide    string `json:"positionSide"`
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	QuoteQty        string `json:"quoteQty"`
+	BaseQty         string `json:"baseQty"` // dapi 使用
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	RealizedPnl     string `json:"realizedPnl"`
+	Maker           bool   `json:"maker"`
+	Buyer           bool   `json:"buyer"`
+	Time            int64  `json:"time"`
+}
+
+// orderFill 单个订单的成交汇总
+type orderFill struct {
+	Qty             float64
+	Notional        float64 // Σ price × qty
+	Commission      float64
+	CommissionAsset string
+	RealizedPnl     float64
+	Trades          int
+	LastTime        int64
+}
+
+// AvgPrice 成交量加权均价
+func (f *orderFill) AvgPrice() float64 {
+	if f == nil || f.Qty <= 0 {
+		return 0
+	}
+	return f.Notional / f.Qty
+}
+
+// userTradesWeight userTrades 的请求权重（fapi 为 5，dapi 带 symbol 时为 20）
+func (c *binanceREST) userTradesWeight() int {
+	return c.allOrdersWeight()
+}
+
+// UserTrades 调用 userTrades（fromID>0 时按成交ID增量拉取，否则按时间窗口）
+func (c *binanceREST) UserTrades(symbol string, fromID, startTime, endTime int64) ([]BinanceTrade, []map[string]any, error) {
+	if symbol == "" {
+		return nil, nil, fmt.Errorf("symbol 不能为空")
+	}
+	ctx := context.Background()
+	if err := c.limiter.wait(ctx, c.userTradesWeight()); err != nil {
+		return nil, nil, err
+	}
+
+	params := []string{fmt.Sprintf("symbol=%s", symbol)}
+	if fromID > 0 {
+		params = append(params, fmt.Sprintf("fromId=%d", fromID))
+	} else {
+		if startTime > 0 {
+			params = append(params, fmt.Sprintf("startTime=%d", startTime))
+		}
+		if endTime > 0 {
+			params = append(params, fmt.Sprintf("endTime=%d", endTime))
+		}
+	}
+	params = append(params, fmt.Sprintf("limit=%d", userTradesLimit))
+	params = append(params, "recvWindow=5000")
+	params = append(params, fmt.Sprintf("timestamp=%d", time.Now().UnixMilli()))
+	qs := strings.Join(params, "&")
+	sig := hmacSHA256Hex(qs, c.secretKey)
+	path := c.path("userTrades")
+	url := fmt.Sprintf("%s%s?%s&signature=%s", c.baseURL, path, qs, sig)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req.Header.Set("X-MBX-APIKEY", c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	c.limiter.observe(resp)
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var raw []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	trades := make([]BinanceTrade, 0, len(raw))
+	rawKept := make([]map[string]any, 0, len(raw))
+	for _, r := range raw {
+		b, _ := json.Marshal(r)
+		var t BinanceTrade
+		if json.Unmarshal(b, &t) == nil {
+			trades = append(trades, t)
+			rawKept = append(rawKept, r)
+		}
+	}
+	return trades, rawKept, nil
+}
+
+// fetchTradesForSymbol 增量拉取交易对成交并写入 trades 表
+func fetchTradesForSymbol(db *sql.DB, client exchangeClient, traderID, symbol string) error {
+	st := time.Now()
+	var lastTradeID, lastFetch sql.NullInt64
+	_ = db.QueryRow(`SELECT last_trade_id, last_fetch_time FROM trade_state WHERE trader_id = ? AND symbol = ?`, traderID, symbol).Scan(&lastTradeID, &lastFetch)
+
+	var all []BinanceTrade
+	var rawAll []map[string]any
+	if lastFetch.Valid && lastFetch.Int64 > 0 && !client.IDCursor() {
+		// 不支持按成交ID增量的交易所：从上次拉取时间（含重叠）拉到现在
+		trades, raw, err := client.UserTrades(symbol, 0, lastFetch.Int64-incrementalOverlap.Milliseconds(), time.Now().UnixMilli())
+		if err != nil {
+			return err
+		}
+		all = append(all, trades...)
+		rawAll = append(rawAll, raw...)
+	} else if lastTradeID.Valid && lastTradeID.Int64 > 0 && client.IDCursor() {
+		// 按成交ID翻页，直到不足一页
+		fromID := lastTradeID.Int64 + 1
+		for {
+			trades, raw, err := client.UserTrades(symbol, fromID, 0, 0)
+			if err != nil {
+				return err
+			}
+			all = append(all, trades...)
+			rawAll = append(rawAll, raw...)
+			if len(trades) < userTradesLimit {
+				break
+			}
+			fromID = latestTradeID(trades) + 1
+		}
+	} else {
+		// 初次：最近 7 天（接口单次时间窗口上限）
+		end := time.Now().UnixMilli()
+		start := end - 7*24*3600*1000
+		trades, raw, err := client.UserTrades(symbol, 0, start, end)
+		if err != nil {
+			return err
+		}
+		all = append(all, trades...)
+		rawAll = append(rawAll, raw...)
+	}
+	if len(all) == 0 {
+		return nil
+	}
+
+	if err := writeTx(db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`INSERT OR REPLACE INTO trades(trader_id, symbol, trade_id, order_id, side, position_side, price, qty, quote_qty, commission, commission_asset, realized_pnl, maker, time, raw_json)
+			VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`)
+		if err != nil {
+			return fmt.Errorf("准备语句失败: %w", err)
+		}
+		defer stmt.Close()
+
+		for i, t := range all {
+			b, _ := json.Marshal(rawAll[i])
+			qty := parseFloat(t.Qty)
+			if qty == 0 {
+				qty = parseFloat(t.BaseQty)
+			}
+			_, e := stmt.Exec(traderID, symbol, t.ID, t.OrderID, t.Side, t.PositionSide, parseFloat(t.Price), qty, parseFloat(t.QuoteQty),
+				parseFloat(t.Commission), t.CommissionAsset, parseFloat(t.RealizedPnl), boolToInt(t.Maker), t.Time, string(b))
+			if e != nil {
+				log.Printf("⚠ 写入成交失败 [%s] %s trade_id=%d: %v", traderID, symbol, t.ID, e)
+			}
+		}
+		_, err = tx.Exec(`INSERT OR REPLACE INTO trade_state(trader_id, symbol, last_trade_id, last_fetch_time) VALUES(?,?,?,?)`,
+			traderID, symbol, latestTradeID(all), time.Now().UnixMilli())
+		if err != nil {
+			return fmt.Errorf("更新成交状态失败: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	log.Printf("✓ [%s] %s 增量拉取成交 %d 条, 用时 %v", traderID, symbol, len(all), time.Since(st))
+	return nil
+}
+
+func latestTradeID(list []BinanceTrade) int64 {
+	var m int64
+	for _, t := range list {
+		if t.ID > m {
+			m = t.ID
+		}
+	}
+	return m
+}
+
+// loadOrderFills 按 trader_id+symbol+order_id 汇总成交
+func loadOrderFills(db *sql.DB) (map[string]*orderFill, error) {
+	rows, err := db.Query(`SELECT trader_id, symbol, order_id, price, qty, commission, commission_asset, realized_pnl, time FROM trades`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fills := make(map[string]*orderFill)
+	for rows.Next() {
+		var traderID, symbol, asset string
+		var orderID, ts int64
+		var price, qty, commission, pnl float64
+		if err := rows.Scan(&traderID, &symbol, &orderID, &price, &qty, &commission, &asset, &pnl, &ts); err != nil {
+			continue
+		}
+		key := fillKey(traderID, symbol, orderID)
+		f, ok := fills[key]
+		if !ok {
+			f = &orderFill{CommissionAsset: asset}
+			fills[key] = f
+		}
+		f.Qty += qty
+		f.Notional += price * qty
+		f.Commission += commission
+		f.RealizedPnl += pnl
+		f.Trades++
+		f.LastTime = max(f.LastTime, ts)
+	}
+	return fills, rows.Err()
+}
+
+func fillKey(traderID, symbol string, orderID int64) string {
+	return traderID + "_" + symbol + "_" + strconv.FormatInt(orderID, 10)
+}
+
+// applyFill 用成交汇总覆盖订单的成交均价与数量（以实际成交为准）
+func applyFill(o *BinanceOrder, f *orderFill) {
+	if f == nil || f.Qty <= 0 {
+		return
+	}
+	o.AvgPrice = strconv.FormatFloat(f.AvgPrice(), 'f', -1, 64)
+	o.ExecutedQty = strconv.FormatFloat(f.Qty, 'f', -1, 64)
+	o.Fill = f
+}
+
+// fillSummary 校正描述中附带的成交信息
+func fillSummary(o *BinanceOrder) string {
+	if o == nil || o.Fill == nil {
+		return ""
+	}
+	return fmt.Sprintf(" [成交 %d 笔, 手续费 %.6f %s, 已实现盈亏 %.4f]", o.Fill.Trades, o.Fill.Commission, o.Fill.CommissionAsset, o.Fill.RealizedPnl)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 决策日志格式校验（validate-logs）
+//
+// 对账各环节用 json.Unmarshal 读取日志，解析失败或字段类型不符的文件会被静默跳过。
+// validate-logs 按版本化的结构定义逐个检查，列出格式错误的文件与字段，只读，不修改日志。
+// 日志结构变化（新增必填字段、动作）时追加新版本，旧版本保留以便核对历史日志。
+
+// jsonKind 字段的 JSON 类型
+type jsonKind string
+
+const (
+	kindString jsonKind = "string"
+	kindNumber jsonKind = "number"
+	kindBool   jsonKind = "bool"
+	kindArray  jsonKind = "array"
+	kindObject jsonKind = "object"
+)
+
+// logSchema 决策日志结构定义
+type logSchema struct {
+	Version        int
+	RecordFields   map[string]jsonKind // 记录顶层字段类型（未列出的字段不检查）
+	RecordRequired []string
+	ActionFields   map[string]jsonKind // decisions 元素字段类型
+	ActionRequired []string
+	Actions        []string // 合法的动作名
+}
+
+// logSchemaV1 初始结构
+var logSchemaV1 = logSchema{
+	Version: 1,
+	RecordFields: map[string]jsonKind{
+		"timestamp": kindString, "cycle_number": kindNumber, "system_prompt": kindString, "input_prompt": kindString,
+		"cot_trace": kindString, "decision_json": kindString, "account_state": kindObject, "positions": kindArray,
+		"candidate_coins": kindArray, "decisions": kindArray, "execution_log": kindArray, "success": kindBool, "error_message": kindString,
+	},
+	RecordRequired: []string{"timestamp", "decisions"},
+	ActionFields: map[string]jsonKind{
+		"action": kindString, "symbol": kindString, "quantity": kindNumber, "leverage": kindNumber, "price": kindNumber,
+		"order_id": kindNumber, "timestamp": kindString, "success": kindBool, "error": kindString,
+	},
+	ActionRequired: []string{"action", "timestamp", "success"},
+	Actions: []string{
+		"open_long", "open_short", "close_long", "close_short", "auto_close_long", "auto_close_short",
+		"partial_close", "update_stop_loss", "update_take_profit", "hold", "wait",
+	},
+}
+
+// logSchemas 各版本结构定义（版本号递增）
+var logSchemas = []logSchema{
+	logSchemaV1,
+	// v2: 动作新增可选的 client_order_id
+	logSchemaV1.withActionFields(2, map[string]jsonKind{"client_order_id": kindString}),
+}
+
+// withActionFields 在现有结构上增加动作字段，生成新版本（不修改原结构）
+func (s logSchema) withActionFields(version int, fields map[string]jsonKind) logSchema {
+	next := s
+	next.Version = version
+	next.ActionFields = make(map[string]jsonKind, len(s.ActionFields)+len(fields))
+	maps.Copy(next.ActionFields, s.ActionFields)
+	maps.Copy(next.ActionFields, fields)
+	return next
+}
+
+// latestLogSchema 当前日志结构版本
+func latestLogSchema() logSchema {
+	return logSchemas[len(logSchemas)-1]
+}
+
+const (
+	// logEarliestTime 早于该时间的时间戳视为异常（日志功能上线前）
+	logEarliestTime = "2024-01-01T00:00:00Z"
+	// logFutureSlack 允许晚于当前时间的时钟偏差
+	logFutureSlack = 24 * time.Hour
+	// logActionSkew 动作时间与记录时间的最大间隔
+	logActionSkew = time.Hour
+)
+
+// logProblem 单个文件中的一处格式问题
+type logProblem struct {
+	Path    string // 字段路径，如 decisions[2].timestamp
+	Message string
+}
+
+func (p logProblem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// validateLogFile 按结构定义校验单个日志文件
+// 对账补全生成的 decision_reconcile_* 文件只有 decisions，不要求记录时间
+func validateLogFile(data []byte, name string, schema logSchema, now time.Time) []logProblem {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var root any
+	if err := dec.Decode(&root); err != nil {
+		return []logProblem{{Message: "JSON 解析失败: " + describeJSONError(data, err)}}
+	}
+	if dec.More() {
+		return []logProblem{{Message: "JSON 之后还有多余内容"}}
+	}
+	rec, ok := root.(map[string]any)
+	if !ok {
+		return []logProblem{{Message: fmt.Sprintf("顶层应为 object，实际为 %s", kindOf(root))}}
+	}
+	supplement := strings.HasPrefix(name, "decision_reconcile_")
+	var problems []logProblem
+	add := func(path, format string, args ...any) {
+		problems = append(problems, logProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, f := range schema.RecordRequired {
+		if _, ok := rec[f]; !ok && !(supplement && f == "timestamp") {
+			add(f, "缺少必填字段")
+		}
+	}
+	checkKinds(rec, schema.RecordFields, "", add)
+
+	var recTime time.Time
+	if s, ok := rec["timestamp"].(string); ok && !supplement {
+		recTime = checkTimestamp(s, "timestamp", now, add)
+	}
+
+	acts, _ := rec["decisions"].([]any)
+	for i, a := range acts {
+		path := fmt.Sprintf("decisions[%d]", i)
+		act, ok := a.(map[string]any)
+		if !ok {
+			add(path, "应为 object，实际为 %s", kindOf(a))
+			continue
+		}
+		for _, f := range schema.ActionRequired {
+			if _, ok := act[f]; !ok {
+				add(path+"."+f, "缺少必填字段")
+			}
+		}
+		checkKinds(act, schema.ActionFields, path+".", add)
+
+		action, _ := act["action"].(string)
+		if _, ok := act["action"].(string); ok && !slices.Contains(schema.Actions, action) {
+			add(path+".action", "未知动作 %q", action)
+		}
+		if symbol, _ := act["symbol"].(string); symbol == "" && (needsOrderMatch(action) || isTriggerAction(action)) {
+			add(path+".symbol", "%s 缺少交易对", action)
+		}
+		for _, f := range []string{"quantity", "price", "leverage", "order_id"} {
+			if n, ok := act[f].(json.Number); ok {
+				if v, err := n.Float64(); err == nil && v < 0 {
+					add(path+"."+f, "不应为负数: %s", n)
+				}
+				if f == "leverage" || f == "order_id" {
+					if _, err := n.Int64(); err != nil {
+						add(path+"."+f, "应为整数: %s", n)
+					}
+				}
+			}
+		}
+		if s, ok := act["timestamp"].(string); ok {
+			ts := checkTimestamp(s, path+".timestamp", now, add)
+			if !ts.IsZero() && !recTime.IsZero() {
+				if d := ts.Sub(recTime); d > logActionSkew || d < -logActionSkew {
+					add(path+".timestamp", "与记录时间相差 %v（上限 %v）", d.Round(time.Second), logActionSkew)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// checkKinds 检查已出现字段的 JSON 类型（null 视为缺省值）
+func checkKinds(obj map[string]any, fields map[string]jsonKind, prefix string, add func(path, format string, args ...any)) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := obj[k]
+		if !ok || v == nil {
+			continue
+		}
+		if got := kindOf(v); got != fields[k] {
+			add(prefix+k, "类型应为 %s，实际为 %s", fields[k], got)
+		}
+	}
+}
+
+// checkTimestamp 解析 RFC3339 时间并检查范围，返回解析结果（失败为零值）
+func checkTimestamp(s, path string, now time.Time, add func(path, format string, args ...any)) time.Time {
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		add(path, "时间格式错误: %q", s)
+		return time.Time{}
+	}
+	earliest, _ := time.Parse(time.RFC3339, logEarliestTime)
+	switch {
+	case ts.IsZero():
+		add(path, "时间为零值")
+		return time.Time{}
+	case ts.Before(earliest):
+		add(path, "时间过早: %s", s)
+	case ts.After(now.Add(logFutureSlack)):
+		add(path, "时间晚于当前时间: %s", s)
+	}
+	return ts
+}
+
+// kindOf 解码结果的 JSON 类型名
+func kindOf(v any) jsonKind {
+	switch v.(type) {
+	case string:
+		return kindString
+	case json.Number, float64:
+		return kindNumber
+	case bool:
+		return kindBool
+	case []any:
+		return kindArray
+	case map[string]any:
+		return kindObject
+	case nil:
+		return "null"
+	}
+	return jsonKind(fmt.Sprintf("%T", v))
+}
+
+// describeJSONError 语法错误附带行列号
+func describeJSONError(data []byte, err error) string {
+	var syn *json.SyntaxError
+	if !errors.As(err, &syn) {
+		return err.Error()
+	}
+	offset := min(int(syn.Offset), len(data))
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	col := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return fmt.Sprintf("%v（第 %d 行第 %d 列）", err, line, col)
+}
+
+// validateLogs 校验所有交易员的决策日志格式（只读）
+func validateLogs(src *logSource, rep *runReport) error {
+	schema := latestLogSchema()
+	log.Printf("=== 开始决策日志格式校验（结构版本 v%d）===", schema.Version)
+	traders, err := src.traders()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, traderID := range traders {
+		records, err := src.records(traderID)
+		if err != nil {
+			msg := fmt.Sprintf("⚠ 读取 %s 失败: %v", src.location(traderID, ""), err)
+			rep.failure(traderID, msg)
+			log.Println(msg)
+			continue
+		}
+		stats := rep.stats(traderID)
+		var lines []string
+		bad := 0
+		for _, r := range records {
+			name := r.Name
+			stats.Files++
+			problems := validateLogFile(r.Data, name, schema, now)
+			if len(problems) == 0 {
+				continue
+			}
+			bad++
+			for _, p := range problems {
+				msg := fmt.Sprintf("✗ [%s] %s %s", traderID, name, p)
+				rep.issue(traderID, msg)
+				lines = append(lines, msg)
+			}
+		}
+		stats.Summary = fmt.Sprintf("文件 %d, 格式错误 %d（结构版本 v%d）", stats.Files, bad, schema.Version)
+		if bad == 0 {
+			log.Printf("✓ [%s] 决策日志格式校验通过（%d 个文件）", traderID, stats.Files)
+			continue
+		}
+		rep.writeTrader(src.reportDir(traderID), traderID, "log_validation_report", []string{
+			"=== 决策日志格式校验报告 ===",
+			fmt.Sprintf("生成时间: %s", now.Format("2006-01-02 15:04:05")),
+			fmt.Sprintf("Trader ID: %s", traderID),
+			stats.Summary,
+			"",
+		}, lines)
+		for _, msg := range lines {
+			log.Println(msg)
+		}
+	}
+	return rep.finish()
+}
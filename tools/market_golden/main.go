@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"nofx/market"
+	"nofx/market/markettest"
+)
+
+// 行情回归检查：用录制的币安响应计算指标，与 golden 文件比对 market.Format 输出。
+// 修改指标计算或输出格式后运行，输出变化即说明行为发生了变化；确认变化符合预期后加 -update 更新 golden 文件。
+//
+//	go run ./tools/market_golden                      # 比对，不一致时退出码为 1
+//	go run ./tools/market_golden -update              # 重新生成 golden 文件
+//	go run ./tools/market_golden -record BTCUSDT      # 从币安重新录制样本（需要网络），之后需 -update
+func main() {
+	var fixturesDir, goldenDir, recordSpec string
+	var update bool
+	flag.StringVar(&fixturesDir, "fixtures", "market/testdata/fixtures", "录制样本目录（每个交易对一个子目录）")
+	flag.StringVar(&goldenDir, "golden", "market/testdata/golden", "golden 文件目录")
+	flag.StringVar(&recordSpec, "record", "", "从币安录制样本的交易对，逗号分隔")
+	flag.BoolVar(&update, "update", false, "用当前输出覆盖 golden 文件")
+	flag.Parse()
+
+	if recordSpec != "" {
+		for _, symbol := range strings.Split(recordSpec, ",") {
+			if symbol = strings.TrimSpace(symbol); symbol == "" {
+				continue
+			}
+			if err := markettest.Record(fixturesDir, symbol); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			log.Printf("📼 已录制 %s", market.Normalize(symbol))
+		}
+		return
+	}
+
+	fixtures, err := markettest.LoadFixtures(fixturesDir)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if len(fixtures) == 0 {
+		log.Fatalf("❌ %s 中没有录制样本", fixturesDir)
+	}
+
+	failed := 0
+	for _, f := range fixtures {
+		data, err := f.Data(market.DefaultIndicatorConfig())
+		if err != nil {
+			log.Printf("❌ %s 计算市场数据失败: %v", f.Symbol, err)
+			failed++
+			continue
+		}
+		path := filepath.Join(goldenDir, f.Symbol+".txt")
+		if err := markettest.Golden(path, []byte(market.Format(data)), update); err != nil {
+			log.Printf("❌ %v", err)
+			failed++
+			continue
+		}
+		if update {
+			log.Printf("✏️  已更新 %s", path)
+		} else {
+			log.Printf("✓ %s", f.Symbol)
+		}
+	}
+	if failed > 0 {
+		log.Printf("❌ %d/%d 个交易对不一致", failed, len(fixtures))
+		os.Exit(1)
+	}
+}
@@ -25,7 +25,7 @@ import (
 )
 
 // 全局日志前缀
-var name = "[AsterTrader] "
+const name = "[AsterTrader] "
 
 // AsterTrader Aster交易平台实现
 type AsterTrader struct {
@@ -1038,6 +1038,16 @@ func (t *AsterTrader) SetTakeProfit(symbol string, positionSide string, quantity
 
 // CancelStopLossOrders 仅取消止损单（不影响止盈单）
 func (t *AsterTrader) CancelStopLossOrders(symbol string) error {
+	return t.cancelStopLossOrders(symbol, "")
+}
+
+// CancelStopLossOrdersForSide 仅取消指定持仓方向的止损单
+func (t *AsterTrader) CancelStopLossOrdersForSide(symbol, positionSide string) error {
+	return t.cancelStopLossOrders(symbol, positionSide)
+}
+
+// cancelStopLossOrders 取消止损单，positionSide 为空时取消所有方向
+func (t *AsterTrader) cancelStopLossOrders(symbol, wantSide string) error {
 	// 获取该币种的所有未完成订单
 	params := map[string]interface{}{
 		"symbol": symbol,
@@ -1053,7 +1063,7 @@ func (t *AsterTrader) CancelStopLossOrders(symbol string) error {
 		return fmt.Errorf(name+"解析订单数据失败: %w", err)
 	}
 
-	// 过滤出止损单并取消（取消所有方向的止损单，包括LONG和SHORT）
+	// 过滤出止损单并取消（未指定方向时取消LONG和SHORT两个方向的止损单）
 	canceledCount := 0
 	var cancelErrors []error
 	for _, order := range orders {
@@ -1063,6 +1073,9 @@ func (t *AsterTrader) CancelStopLossOrders(symbol string) error {
 		if orderType == "STOP_MARKET" || orderType == "STOP" {
 			orderID, _ := order["orderId"].(float64)
 			positionSide, _ := order["positionSide"].(string)
+			if !matchesPositionSide(positionSide, wantSide) {
+				continue
+			}
 			cancelParams := map[string]interface{}{
 				"symbol":  symbol,
 				"orderId": int64(orderID),
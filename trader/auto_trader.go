@@ -1,16 +1,26 @@
 package trader
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"nofx/account"
 	cconfig "nofx/config"
 	"nofx/decision"
+	"nofx/decisions"
+	"nofx/killswitch"
 	"nofx/logger"
 	"nofx/market"
 	"nofx/mcp"
+	"nofx/notify"
+	"nofx/performance"
 	"nofx/pool"
+	"nofx/risk"
+	"nofx/sizing"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -32,7 +42,13 @@ type AutoTraderConfig struct {
 	AIModel string // AI模型: "qwen" 或 "deepseek"
 
 	// 交易平台选择
-	Exchange string // "binance", "hyperliquid" 或 "aster"
+	Exchange string // "binance", "hyperliquid", "aster" 或 "paper"（模拟盘）
+
+	// 行情数据源（"binance"、"okx" 或 "bybit"，空则按交易对选择，默认币安）
+	MarketDataSource string
+
+	// 追加到候选币种的筛选器 TopN 数量（需启用 market 默认筛选器，0 表示不使用）
+	ScreenerTopN int
 
 	// 币安API配置
 	BinanceAPIKey    string
@@ -77,6 +93,29 @@ type AutoTraderConfig struct {
 	MaxDrawdown     float64       // 最大回撤百分比（提示）
 	StopTradingTime time.Duration // 触发风控后暂停时长
 
+	// 风控限制（0 表示不限制；日亏损使用 MaxDailyLoss 与 StopTradingTime）
+	MaxPositions          int     // 最大同时持仓数
+	MaxNotionalPerSymbol  float64 // 单币种最大名义价值（USDT）
+	MaxCorrelatedExposure float64 // 同方向高相关持仓的合计名义价值上限（USDT）
+
+	// 资金费成本（MaxFundingCostPct 为 0 时不检查）
+	MaxFundingCostPct float64       // 预期持仓期间资金费占名义价值的百分比上限
+	FundingWindow     time.Duration // 距下次结算不超过该时长时才检查
+	HoldingHours      float64       // 预期持仓时长（小时）
+
+	// 是否在提示词中附加 Delta 中性对冲建议（仅建议，不会自动下单）
+	HedgeInPrompt bool
+
+	// 仓位计算（RiskPerTradePct 为 0 时沿用 AI 的 position_size_usd，仍按交易所规则取整）
+	RiskPerTradePct   float64 // 每笔风险占净值的百分比
+	ATRStopMultiplier float64 // 止损距离的 ATR 倍数（默认 2）
+
+	// 保本/移动止损规则（未启用时不启动监控）
+	TrailingStop TrailingStopRules
+
+	// 指标集合与周期（nil 时使用默认指标集合）
+	IndicatorConfig *market.IndicatorConfig
+
 	// 仓位模式
 	IsCrossMargin bool // true=全仓模式, false=逐仓模式
 
@@ -95,9 +134,12 @@ type AutoTrader struct {
 	aiModel               string // AI模型名称
 	exchange              string // 交易平台名称
 	config                AutoTraderConfig
-	trader                Trader // 使用Trader接口（支持多平台）
+	trader                Trader         // 使用Trader接口（支持多平台）
+	exposure              account.Source // 组合敞口快照来源
 	mcpClient             *mcp.Client
 	decisionLogger        *logger.DecisionLogger // 决策日志记录器
+	risk                  *risk.Manager          // 决策执行前的风控检查
+	trailing              *trailingStop          // 保本/移动止损
 	initialBalance        float64
 	dailyPnL              float64
 	customPrompt          string   // 自定义交易策略prompt
@@ -108,6 +150,9 @@ type AutoTrader struct {
 	lastResetTime         time.Time
 	stopUntil             time.Time
 	isRunning             bool
+	runMu                 sync.Mutex         // 保护 isRunning / stopCh / runDone
+	stopCh                chan struct{}      // 通知主循环退出（每次 Run 新建）
+	runDone               chan struct{}      // 主循环退出（含进行中的周期）后关闭
 	startTime             time.Time          // 系统启动时间
 	callCount             int                // AI调用次数
 	positionFirstSeenTime map[string]int64   // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
@@ -118,6 +163,16 @@ type AutoTrader struct {
 	lastBalanceSyncTime   time.Time          // 上次余额同步时间
 	database              interface{}        // 数据库引用（用于自动更新余额）
 	userID                string             // 用户ID
+
+	drawdownPositions     map[string]drawdownPosition // 回撤监控持仓快照（仅监控goroutine访问）
+	lastTickDrawdownCheck time.Time                   // 上次由实时成交触发回撤检查的时间
+
+	pauseMu        sync.Mutex       // 保护 pause / flattenedSince / flattening
+	pause          killswitch.State // 最近一次检查到的紧急停止状态
+	flattenedSince time.Time        // 已执行紧急平仓的暂停开始时间
+	flattening     bool             // 紧急平仓进行中
+
+	orderMu sync.Mutex // 串行化交易所下单/撤单（决策执行、紧急平仓与移动止损监控）
 }
 
 // NewAutoTrader 创建自动交易器
@@ -140,7 +195,17 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 	mcpClient := mcp.New()
 
 	// 初始化AI
-	if config.AIModel == "custom" {
+	if config.AIModel == string(mcp.ProviderMock) {
+		// 模拟服务商：从 MCP_MOCK_FILE 加载预设响应（用于离线端到端测试）
+		mock := mcp.NewMockProvider()
+		if path := os.Getenv("MCP_MOCK_FILE"); path != "" {
+			if err := mock.LoadFile(path); err != nil {
+				return nil, fmt.Errorf("加载模拟AI响应失败: %w", err)
+			}
+		}
+		mcpClient.SetMockProvider(mock)
+		log.Printf("🤖 [%s] 使用模拟AI（响应文件: %s）", config.Name, os.Getenv("MCP_MOCK_FILE"))
+	} else if config.AIModel == "custom" {
 		// 使用自定义API
 		mcpClient.SetCustomAPI(config.CustomAPIURL, config.CustomAPIKey, config.CustomModelName)
 		log.Printf("🤖 [%s] 使用自定义AI API: %s (模型: %s)", config.Name, config.CustomAPIURL, config.CustomModelName)
@@ -162,9 +227,16 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		}
 	}
 
-	// 设置密钥移除持久化回调：余额不足时自动更新数据库中的AI模型APIKey列表
+	// 设置密钥移除持久化回调：余额不足时推送通知，并自动更新数据库中的AI模型APIKey列表
 	if db, ok := database.(*cconfig.Database); ok {
 		mcpClient.PersistRemovedKey = func(provider mcp.Provider, removedKey string, remaining []string) error {
+			notify.Send(notify.Event{
+				Type:     notify.EventAIKeyRemoved,
+				Title:    "🧹 AI密钥余额不足，已移除",
+				Message:  fmt.Sprintf("%s 的一个 API Key 余额不足已被移除，剩余 %d 个", provider, len(remaining)),
+				TraderID: config.ID,
+				Fields:   map[string]string{"trader_name": config.Name},
+			})
 			// 获取当前用户的所有AI模型，找到匹配的provider
 			models, err := db.GetAIModels(userID)
 			if err != nil {
@@ -200,6 +272,13 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		config.Exchange = "binance"
 	}
 
+	// 校验行情数据源
+	if config.MarketDataSource != "" {
+		if _, err := market.SourceByName(config.MarketDataSource); err != nil {
+			return nil, err
+		}
+	}
+
 	// 根据配置创建对应的交易器
 	var trader Trader
 	var err error
@@ -227,10 +306,19 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		if err != nil {
 			return nil, fmt.Errorf("初始化Aster交易器失败: %w", err)
 		}
+	case "paper":
+		log.Printf("🏦 [%s] 使用模拟盘交易（不会向交易所下单）", config.Name)
+		trader = NewPaperTrader(config.InitialBalance)
 	default:
 		return nil, fmt.Errorf("不支持的交易平台: %s", config.Exchange)
 	}
 
+	// 币安直接读取账户接口（含维持保证金），其他交易所由余额与持仓计算
+	exposure := account.FromTrader(trader)
+	if ft, ok := trader.(*FuturesTrader); ok {
+		exposure = account.NewBinance(ft.client)
+	}
+
 	// 验证初始金额配置
 	if config.InitialBalance <= 0 {
 		return nil, fmt.Errorf("初始金额必须大于0，请在配置中设置InitialBalance")
@@ -247,15 +335,28 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		systemPromptTemplate = "adaptive"
 	}
 
-	return &AutoTrader{
-		id:                    config.ID,
-		name:                  config.Name,
-		aiModel:               config.AIModel,
-		exchange:              config.Exchange,
-		config:                config,
-		trader:                trader,
-		mcpClient:             mcpClient,
-		decisionLogger:        decisionLogger,
+	at := &AutoTrader{
+		id:             config.ID,
+		name:           config.Name,
+		aiModel:        config.AIModel,
+		exchange:       config.Exchange,
+		config:         config,
+		trader:         trader,
+		exposure:       exposure,
+		mcpClient:      mcpClient,
+		decisionLogger: decisionLogger,
+		risk: risk.NewManager(config.ID, risk.Limits{
+			MaxPositions:          config.MaxPositions,
+			MaxLeverage:           max(config.BTCETHLeverage, config.AltcoinLeverage),
+			MaxNotionalPerSymbol:  config.MaxNotionalPerSymbol,
+			MaxDailyLossPct:       config.MaxDailyLoss,
+			HaltDuration:          config.StopTradingTime,
+			MaxCorrelatedExposure: config.MaxCorrelatedExposure,
+			MaxFundingCostPct:     config.MaxFundingCostPct,
+			FundingWindow:         config.FundingWindow,
+			HoldingHours:          config.HoldingHours,
+		}, riskStore(database)),
+		trailing:              newTrailingStop(config.TrailingStop),
 		initialBalance:        config.InitialBalance,
 		systemPromptTemplate:  systemPromptTemplate,
 		defaultCoins:          config.DefaultCoins,
@@ -272,12 +373,30 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		lastBalanceSyncTime:   time.Now(), // 初始化为当前时间
 		database:              database,
 		userID:                userID,
-	}, nil
+	}
+	// 紧急停止期间风控拒绝所有开仓（每次检查时读取，接口/文件修改立即生效）
+	at.risk.SetPauseCheck(func() (string, bool) {
+		st := killswitch.Status(at.id)
+		return st.Describe(), st.Active
+	})
+	return at, nil
 }
 
-// Run 运行自动交易主循环
+// Run 运行自动交易主循环，直到 Stop 被调用；同一交易器不能重复运行
 func (at *AutoTrader) Run() error {
+	at.runMu.Lock()
+	if at.isRunning {
+		at.runMu.Unlock()
+		return fmt.Errorf("交易器 %s 已在运行中", at.name)
+	}
 	at.isRunning = true
+	at.stopCh = make(chan struct{})
+	at.stopMonitorCh = make(chan struct{}) // 停止后重新启动时需要新的通道
+	at.runDone = make(chan struct{})
+	stopCh, done := at.stopCh, at.runDone
+	at.runMu.Unlock()
+	defer close(done)
+
 	log.Println("🚀 AI驱动自动交易系统启动")
 	log.Printf("💰 初始余额: %.2f USDT", at.initialBalance)
 	log.Printf("⚙️  扫描间隔: %v", at.config.ScanInterval)
@@ -285,6 +404,10 @@ func (at *AutoTrader) Run() error {
 
 	// 启动回撤监控
 	at.startDrawdownMonitor()
+	// 启动保本/移动止损监控
+	at.startTrailingStopMonitor()
+	// 启动紧急停止监控
+	at.startKillSwitchMonitor()
 
 	ticker := time.NewTicker(at.config.ScanInterval)
 	defer ticker.Stop()
@@ -294,24 +417,47 @@ func (at *AutoTrader) Run() error {
 		log.Printf("❌ 执行失败: %v", err)
 	}
 
-	for at.isRunning {
+	for {
 		select {
+		case <-stopCh:
+			return nil
 		case <-ticker.C:
+			// 停止信号与定时器同时就绪时优先退出
+			select {
+			case <-stopCh:
+				return nil
+			default:
+			}
 			if err := at.runCycle(); err != nil {
 				log.Printf("❌ 执行失败: %v", err)
 			}
 		}
 	}
-
-	return nil
 }
 
-// Stop 停止自动交易
+// Stop 停止自动交易：不再开始新的周期，并等待进行中的周期与监控goroutine结束；未运行时直接返回
 func (at *AutoTrader) Stop() {
+	at.runMu.Lock()
+	if !at.isRunning {
+		at.runMu.Unlock()
+		return
+	}
 	at.isRunning = false
+	close(at.stopCh)
 	close(at.stopMonitorCh) // 通知监控goroutine停止
-	at.monitorWg.Wait()     // 等待监控goroutine结束
-	log.Println("⏹ 自动交易系统停止")
+	done := at.runDone
+	at.runMu.Unlock()
+
+	at.monitorWg.Wait() // 等待监控goroutine结束
+	<-done              // 等待进行中的决策周期结束
+	log.Printf("⏹ [%s] 自动交易系统停止", at.name)
+}
+
+// IsRunning 主循环是否在运行
+func (at *AutoTrader) IsRunning() bool {
+	at.runMu.Lock()
+	defer at.runMu.Unlock()
+	return at.isRunning
 }
 
 // autoSyncBalanceIfNeeded 自动同步余额（每10分钟检查一次，变化>5%才更新）
@@ -443,15 +589,28 @@ func (at *AutoTrader) runCycle() error {
 	log.Printf("📊 账户净值: %.2f USDT | 可用: %.2f USDT | 持仓: %d",
 		ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.PositionCount)
 
-	// 5. 调用AI获取完整决策
-	log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
-	decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	// 日亏损风控：触发后本周期只放行平仓等动作，之后的周期暂停到 stopUntil
+	if o := at.risk.UpdateEquity(ctx.Account.TotalEquity, time.Now()); o != nil {
+		log.Printf("🛑 风控: %s", o.Reason)
+		record.RiskOverrides = append(record.RiskOverrides, logger.RiskOverride{Rule: o.Rule, Reason: o.Reason})
+	}
+	if until, halted := at.risk.HaltedUntil(time.Now()); halted {
+		at.stopUntil = until
+	}
+
+	// 5. 调用AI获取完整决策（参与 A/B 实验时按分组替换模板或模型）
+	templateName, aiClient := at.assignExperiment(record, ctx)
+	log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", templateName)
+	decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, aiClient, at.customPrompt, at.overrideBasePrompt, templateName)
 
 	// 即使有错误，也保存思维链、决策和输入prompt（用于debug）
 	if decision != nil {
 		record.SystemPrompt = decision.SystemPrompt // 保存系统提示词
 		record.InputPrompt = decision.UserPrompt
 		record.CoTTrace = decision.CoTTrace
+		if decision.Compression != nil {
+			record.ExecutionLog = append(record.ExecutionLog, decision.Compression.String())
+		}
 		if len(decision.Decisions) > 0 {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
 			record.DecisionJSON = string(decisionJSON)
@@ -465,7 +624,7 @@ func (at *AutoTrader) runCycle() error {
 		// 打印系统提示词和AI思维链（即使有错误，也要输出以便调试）
 		if decision != nil {
 			log.Print("\n" + strings.Repeat("=", 70) + "\n")
-			log.Printf("📋 系统提示词 [模板: %s] (错误情况)", at.systemPromptTemplate)
+			log.Printf("📋 系统提示词 [模板: %s] (错误情况)", templateName)
 			log.Println(strings.Repeat("=", 70))
 			log.Println(decision.SystemPrompt)
 			log.Println(strings.Repeat("=", 70))
@@ -520,25 +679,46 @@ func (at *AutoTrader) runCycle() error {
 	}
 	log.Println()
 
+	// 风控检查：被拒绝的开仓记为失败动作，并写入 risk_overrides
+	sortedDecisions, overrides := at.risk.Check(sortedDecisions, riskPositions(ctx.Positions), time.Now())
+	for _, o := range overrides {
+		log.Printf("🛡 风控拒绝 %s %s [%s]: %s", o.Symbol, o.Action, o.Rule, o.Reason)
+		record.RiskOverrides = append(record.RiskOverrides, logger.RiskOverride{Symbol: o.Symbol, Action: o.Action, Rule: o.Rule, Reason: o.Reason})
+		record.Decisions = append(record.Decisions, logger.DecisionAction{
+			Action:    o.Action,
+			Symbol:    o.Symbol,
+			Timestamp: time.Now(),
+			Error:     "风控拒绝: " + o.Reason,
+		})
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🛡 %s %s 被风控拒绝: %s", o.Symbol, o.Action, o.Reason))
+	}
+
 	// 执行决策并记录结果
 	for _, d := range sortedDecisions {
 		actionRecord := logger.DecisionAction{
-			Action:    d.Action,
-			Symbol:    d.Symbol,
-			Quantity:  0,
-			Leverage:  d.Leverage,
-			Price:     0,
-			Timestamp: time.Now(),
-			Success:   false,
+			Action:     d.Action,
+			Symbol:     d.Symbol,
+			Quantity:   0,
+			Leverage:   d.Leverage,
+			Confidence: d.Confidence,
+			Price:      0,
+			Timestamp:  time.Now(),
+			Success:    false,
 		}
 
 		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
 			log.Printf("❌ 执行决策失败 (%s %s): %v", d.Symbol, d.Action, err)
 			actionRecord.Error = err.Error()
+			var rejected *riskRejection
+			if errors.As(err, &rejected) {
+				o := rejected.Override
+				record.RiskOverrides = append(record.RiskOverrides, logger.RiskOverride{Symbol: o.Symbol, Action: o.Action, Rule: o.Rule, Reason: o.Reason})
+			}
 			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %v", d.Symbol, d.Action, err))
 		} else {
 			actionRecord.Success = true
 			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
+			at.notifyTrade(&d, &actionRecord)
 			// 成功执行后短暂延迟
 			time.Sleep(1 * time.Second)
 		}
@@ -554,6 +734,51 @@ func (at *AutoTrader) runCycle() error {
 	return nil
 }
 
+// riskRejection 按计算后的仓位重新检查时被风控拒绝
+type riskRejection struct {
+	Override risk.Override
+}
+
+func (e *riskRejection) Error() string {
+	return fmt.Sprintf("风控拒绝（按计算后的仓位）[%s]: %s", e.Override.Rule, e.Override.Reason)
+}
+
+// exchangeRiskPositions 把交易所返回的持仓转换为风控检查使用的持仓（名义价值按标记价格计算）
+func exchangeRiskPositions(positions []map[string]interface{}) []risk.Position {
+	result := make([]risk.Position, 0, len(positions))
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		amt, _ := pos["positionAmt"].(float64)
+		mark, _ := pos["markPrice"].(float64)
+		lev, _ := pos["leverage"].(float64)
+		result = append(result, risk.Position{Symbol: symbol, Side: side, Notional: math.Abs(amt) * mark, Leverage: int(lev)})
+	}
+	return result
+}
+
+// recheckSizedOpen 仓位计算可能改变 AI 给出的名义价值（ATR 风险仓位），下单前按计算结果与当前持仓重新做风控检查
+func (at *AutoTrader) recheckSizedOpen(d *decision.Decision, positions []risk.Position) error {
+	if _, overrides := at.risk.Check([]decision.Decision{*d}, positions, time.Now()); len(overrides) > 0 {
+		return &riskRejection{Override: overrides[0]}
+	}
+	return nil
+}
+
+// riskPositions 转换为风控检查使用的持仓（名义价值按标记价格计算）
+func riskPositions(positions []decision.PositionInfo) []risk.Position {
+	result := make([]risk.Position, 0, len(positions))
+	for _, p := range positions {
+		result = append(result, risk.Position{
+			Symbol:   p.Symbol,
+			Side:     p.Side,
+			Notional: math.Abs(p.Quantity) * p.MarkPrice,
+			Leverage: p.Leverage,
+		})
+	}
+	return result
+}
+
 // buildTradingContext 构建交易上下文
 func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	// 1. 获取账户信息
@@ -675,10 +900,9 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			if best != nil {
 				// 先尝试解析 decision_json 以获得 stop_loss_condition（独立于价格更新）
 				if strings.TrimSpace(best.DecisionJSON) != "" {
-					var dj decision.Decision
-					if err := json.Unmarshal([]byte(best.DecisionJSON), &dj); err == nil {
-						if strings.TrimSpace(dj.StopLossCondition) != "" {
-							stopCond = dj.StopLossCondition
+					if items, err := decisions.Decode(best.DecisionJSON); err == nil {
+						if len(items) > 0 && strings.TrimSpace(items[0].StopLossCondition) != "" {
+							stopCond = items[0].StopLossCondition
 						}
 					} else {
 						log.Printf("⚠️ 解析 decision_json 失败: %v", err)
@@ -749,6 +973,7 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	if err != nil {
 		return nil, fmt.Errorf("获取候选币种失败: %w", err)
 	}
+	candidateCoins = at.appendScreenerCoins(candidateCoins)
 
 	// 4. 计算总盈亏和未实现盈亏百分比
 	totalPnL := totalEquity - at.initialBalance
@@ -786,11 +1011,14 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 
 	// 6. 构建上下文
 	ctx := &decision.Context{
+		TraderID:        at.id,
 		CurrentTime:     time.Now().Format("2006-01-02 15:04:05"),
 		RuntimeMinutes:  int(time.Since(at.startTime).Minutes()),
 		CallCount:       at.callCount,
 		BTCETHLeverage:  at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
 		AltcoinLeverage: at.config.AltcoinLeverage, // 使用配置的杠杆倍数
+		MarketSource:    at.config.MarketDataSource,
+		IndicatorConfig: at.config.IndicatorConfig,
 		Account: decision.AccountInfo{
 			TotalEquity:      totalEquity,
 			AvailableBalance: availableBalance,
@@ -805,11 +1033,80 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		Performance:    performance, // 添加历史表现分析
 	}
 
+	// 7. 近期开仓决策的实际结果（反馈给 AI）
+	ctx.Feedback = at.outcomeFeedback()
+
+	// 8. 组合敞口（失败不影响决策）
+	if snap, err := at.GetExposure(context.Background()); err != nil {
+		log.Printf("⚠️  获取组合敞口失败: %v", err)
+	} else {
+		ctx.Exposure = snap
+	}
+
+	// 9. Delta 中性对冲建议（可选）
+	if at.config.HedgeInPrompt && ctx.Exposure != nil && len(ctx.Exposure.Symbols) > 0 {
+		if hedges, err := at.HedgeSuggestions(context.Background()); err != nil {
+			log.Printf("⚠️  计算对冲建议失败: %v", err)
+		} else {
+			ctx.Hedges = account.FormatHedges(hedges)
+		}
+	}
+
 	return ctx, nil
 }
 
+// 结果反馈：读取最近 feedbackLookbackRecords 条决策记录，汇总最近 feedbackWindow 笔已平仓结果并列出最近 feedbackRecent 笔，
+// 信心度校准统计最近 calibrationWindow 笔
+const (
+	feedbackLookbackRecords = 300
+	feedbackWindow          = 20
+	feedbackRecent          = 5
+	calibrationWindow       = 100
+)
+
+// outcomeFeedback 关联最近决策记录中的开仓与平仓（有对账数据库时使用交易所成交），生成近期表现摘要与信心度校准提示
+func (at *AutoTrader) outcomeFeedback() string {
+	outcomes, err := at.recentOutcomes()
+	if err != nil {
+		log.Printf("⚠️  读取决策记录失败，跳过结果反馈: %v", err)
+		return ""
+	}
+	text := performance.Summarize(outcomes, feedbackWindow, feedbackRecent).Text()
+	if calibration := performance.Calibrate(outcomes, calibrationWindow).Text(); calibration != "" {
+		text += "\n" + calibration
+	}
+	return text
+}
+
+// recentOutcomes 最近 feedbackLookbackRecords 条决策记录中的交易结果
+func (at *AutoTrader) recentOutcomes() ([]performance.Outcome, error) {
+	records, err := at.decisionLogger.GetLatestRecords(feedbackLookbackRecords)
+	if err != nil {
+		return nil, err
+	}
+	store, _ := performance.SharedStore() // 对账数据库不可用时按日志价格估算
+	return performance.NewTracker(at.id, store).Outcomes(records), nil
+}
+
+// ConfidenceCalibration 最近交易的信心度校准报告
+func (at *AutoTrader) ConfidenceCalibration() (performance.Calibration, error) {
+	outcomes, err := at.recentOutcomes()
+	if err != nil {
+		return performance.Calibration{}, fmt.Errorf("读取决策记录失败: %w", err)
+	}
+	return performance.Calibrate(outcomes, calibrationWindow), nil
+}
+
+// GetExposure 获取当前组合敞口快照
+func (at *AutoTrader) GetExposure(ctx context.Context) (*account.Snapshot, error) {
+	return at.exposure.Snapshot(ctx)
+}
+
 // executeDecisionWithRecord 执行AI决策并记录详细信息
 func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	at.orderMu.Lock()
+	defer at.orderMu.Unlock()
+
 	switch decision.Action {
 	case "open_long":
 		return at.executeOpenLongWithRecord(decision, actionRecord)
@@ -853,14 +1150,6 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 		return err
 	}
 
-	// 计算数量
-	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
-	actionRecord.Quantity = quantity
-	actionRecord.Price = marketData.CurrentPrice
-
-	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
-	requiredMargin := decision.PositionSizeUSD / float64(decision.Leverage)
-
 	balance, err := at.trader.GetBalance()
 	if err != nil {
 		return fmt.Errorf("获取账户余额失败: %w", err)
@@ -870,8 +1159,24 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 		availableBalance = avail
 	}
 
+	// 计算数量（按风险与交易所规则），并按计算后的名义价值重新做风控检查
+	current := exchangeRiskPositions(positions)
+	sized, err := at.sizePosition(decision, "long", marketData, balance, current)
+	if err != nil {
+		return err
+	}
+	if err := at.recheckSizedOpen(decision, current); err != nil {
+		return err
+	}
+	quantity := sized.Quantity
+	actionRecord.Quantity = quantity
+	actionRecord.Price = marketData.CurrentPrice
+
+	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
+	requiredMargin := sized.Notional / float64(decision.Leverage)
+
 	// 手续费估算（Taker费率 0.04%）
-	estimatedFee := decision.PositionSizeUSD * 0.0004
+	estimatedFee := sized.Notional * 0.0004
 	totalRequired := requiredMargin + estimatedFee
 
 	if totalRequired > availableBalance {
@@ -891,16 +1196,14 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 		return err
 	}
 
-	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	recordOrderResult(order, actionRecord)
 
 	log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
 
 	// 记录开仓时间
 	posKey := decision.Symbol + "_long"
 	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+	at.trailing.track(decision.Symbol, "long", actionRecord.Price, quantity, decision.StopLoss)
 
 	// 设置止损止盈
 	if err := at.trader.SetStopLoss(decision.Symbol, "LONG", quantity, decision.StopLoss); err != nil {
@@ -944,6 +1247,114 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	return nil
 }
 
+// sizePosition 按账户净值、每笔风险与 ATR 止损距离计算下单数量，并按交易所规则取整；
+// 单币种名义价值上限扣除该币种已有持仓（positions）后作为封顶；
+// 计算结果回写到决策（position_size_usd 与缺失时补上的止损），使日志与保存的执行决策反映实际下单
+func (at *AutoTrader) sizePosition(d *decision.Decision, side string, marketData *market.Data, balance map[string]interface{}, positions []risk.Position) (sizing.Result, error) {
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	unrealized, _ := balance["totalUnrealizedProfit"].(float64)
+	available, _ := balance["availableBalance"].(float64)
+
+	maxNotional := at.config.MaxNotionalPerSymbol
+	if maxNotional > 0 {
+		for _, p := range positions {
+			if p.Symbol == d.Symbol {
+				maxNotional -= p.Notional
+			}
+		}
+		if maxNotional <= 0 {
+			reason := fmt.Sprintf("%s 已有持仓的名义价值已达上限 %.2f USDT", d.Symbol, at.config.MaxNotionalPerSymbol)
+			return sizing.Result{}, &riskRejection{Override: risk.Override{Symbol: d.Symbol, Action: d.Action, Rule: risk.RuleMaxNotional, Reason: reason}}
+		}
+	}
+
+	var rules *market.SymbolRules
+	if r, ok := market.GetSymbolRules(d.Symbol); ok {
+		rules = &r
+	}
+	res, err := sizing.Size(sizing.Input{
+		Symbol:       d.Symbol,
+		Side:         side,
+		Price:        marketData.CurrentPrice,
+		Equity:       wallet + unrealized,
+		Available:    available,
+		Leverage:     d.Leverage,
+		StopLoss:     d.StopLoss,
+		ATR:          sizing.ATR(marketData),
+		RequestedUSD: d.PositionSizeUSD,
+		MaxNotional:  maxNotional,
+	}, sizing.Config{
+		RiskPerTradePct: at.config.RiskPerTradePct,
+		ATRMultiplier:   at.config.ATRStopMultiplier,
+	}, rules)
+	if err != nil {
+		return res, err
+	}
+
+	if res.Method == sizing.MethodATRRisk || res.Capped || math.Abs(res.Notional-d.PositionSizeUSD) > 0.01*d.PositionSizeUSD {
+		capped := ""
+		if res.Capped {
+			capped = "，已封顶"
+		}
+		log.Printf("  📐 仓位计算(%s): AI %.2f USDT → %.2f USDT（数量 %v，止损距离 %.4f%s）",
+			res.Method, d.PositionSizeUSD, res.Notional, res.Quantity, res.StopDistance, capped)
+	}
+	if res.StopLoss != d.StopLoss {
+		log.Printf("  📐 止损 %.4f → %.4f（ATR %.4f）", d.StopLoss, res.StopLoss, sizing.ATR(marketData))
+	}
+	d.PositionSizeUSD = res.Notional
+	d.StopLoss = res.StopLoss
+	return res, nil
+}
+
+// riskStore 风控状态的持久化存储（未使用配置数据库时只保存在内存中）
+func riskStore(database interface{}) risk.Store {
+	if db, ok := database.(*cconfig.Database); ok && db != nil {
+		return db
+	}
+	return nil
+}
+
+// recordOrderResult 把成交回报中的订单ID、clientOrderId 与成交均价写入决策记录（回报没有成交价时保留下单前的市场价）
+func recordOrderResult(order map[string]interface{}, actionRecord *logger.DecisionAction) {
+	if orderID, ok := order["orderId"].(int64); ok {
+		actionRecord.OrderID = orderID
+	}
+	if clientOrderID, ok := order["clientOrderId"].(string); ok {
+		actionRecord.ClientOrderID = clientOrderID
+	}
+	if avgPrice, ok := order["avgPrice"].(float64); ok && avgPrice > 0 {
+		actionRecord.Price = avgPrice
+	}
+}
+
+// notifyTrade 开平仓成功后推送通知（其他动作不推送）
+func (at *AutoTrader) notifyTrade(d *decision.Decision, actionRecord *logger.DecisionAction) {
+	ev := notify.Event{TraderID: at.id, Symbol: d.Symbol, Message: d.Reasoning}
+	switch d.Action {
+	case "open_long", "open_short":
+		ev.Type, ev.Title = notify.EventPositionOpened, fmt.Sprintf("📈 [%s] 开仓 %s %s", at.name, d.Symbol, d.Action)
+	case "close_long", "close_short", "partial_close":
+		ev.Type, ev.Title = notify.EventPositionClosed, fmt.Sprintf("📉 [%s] 平仓 %s %s", at.name, d.Symbol, d.Action)
+	default:
+		return
+	}
+	ev.Fields = map[string]string{
+		"quantity": fmt.Sprintf("%.4f", actionRecord.Quantity),
+		"price":    fmt.Sprintf("%.4f", actionRecord.Price),
+	}
+	if ev.Type == notify.EventPositionOpened {
+		ev.Fields["leverage"] = fmt.Sprintf("%dx", d.Leverage)
+		if d.StopLoss > 0 {
+			ev.Fields["stop_loss"] = fmt.Sprintf("%.4f", d.StopLoss)
+		}
+		if d.TakeProfit > 0 {
+			ev.Fields["take_profit"] = fmt.Sprintf("%.4f", d.TakeProfit)
+		}
+	}
+	notify.Send(ev)
+}
+
 // executeOpenShortWithRecord 执行开空仓并记录详细信息
 func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	log.Printf("  📉 开空仓: %s", decision.Symbol)
@@ -964,14 +1375,6 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 		return err
 	}
 
-	// 计算数量
-	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
-	actionRecord.Quantity = quantity
-	actionRecord.Price = marketData.CurrentPrice
-
-	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
-	requiredMargin := decision.PositionSizeUSD / float64(decision.Leverage)
-
 	balance, err := at.trader.GetBalance()
 	if err != nil {
 		return fmt.Errorf("获取账户余额失败: %w", err)
@@ -981,8 +1384,24 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 		availableBalance = avail
 	}
 
+	// 计算数量（按风险与交易所规则），并按计算后的名义价值重新做风控检查
+	current := exchangeRiskPositions(positions)
+	sized, err := at.sizePosition(decision, "short", marketData, balance, current)
+	if err != nil {
+		return err
+	}
+	if err := at.recheckSizedOpen(decision, current); err != nil {
+		return err
+	}
+	quantity := sized.Quantity
+	actionRecord.Quantity = quantity
+	actionRecord.Price = marketData.CurrentPrice
+
+	// ⚠️ 保证金验证：防止保证金不足错误（code=-2019）
+	requiredMargin := sized.Notional / float64(decision.Leverage)
+
 	// 手续费估算（Taker费率 0.04%）
-	estimatedFee := decision.PositionSizeUSD * 0.0004
+	estimatedFee := sized.Notional * 0.0004
 	totalRequired := requiredMargin + estimatedFee
 
 	if totalRequired > availableBalance {
@@ -1002,16 +1421,14 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 		return err
 	}
 
-	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	recordOrderResult(order, actionRecord)
 
 	log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
 
 	// 记录开仓时间
 	posKey := decision.Symbol + "_short"
 	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+	at.trailing.track(decision.Symbol, "short", actionRecord.Price, quantity, decision.StopLoss)
 
 	// 设置止损止盈
 	if err := at.trader.SetStopLoss(decision.Symbol, "SHORT", quantity, decision.StopLoss); err != nil {
@@ -1070,10 +1487,7 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 		return err
 	}
 
-	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	recordOrderResult(order, actionRecord)
 
 	log.Printf("  ✓ 平仓成功")
 
@@ -1103,10 +1517,7 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 		return err
 	}
 
-	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	recordOrderResult(order, actionRecord)
 
 	log.Printf("  ✓ 平仓成功")
 
@@ -1136,18 +1547,22 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 		return fmt.Errorf("获取持仓失败: %w", err)
 	}
 
-	// 查找目标持仓
+	// 查找目标持仓（指定了方向时只匹配该方向，双向持仓下同币种可能多空并存）
 	var targetPosition map[string]interface{}
 	for _, pos := range positions {
 		symbol, _ := pos["symbol"].(string)
+		posSide, _ := pos["side"].(string)
 		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 {
+		if symbol == decision.Symbol && posAmt != 0 && (decision.PositionSide == "" || posSide == decision.PositionSide) {
 			targetPosition = pos
 			break
 		}
 	}
 
 	if targetPosition == nil {
+		if decision.PositionSide != "" {
+			return fmt.Errorf("持仓不存在: %s %s", decision.Symbol, decision.PositionSide)
+		}
 		return fmt.Errorf("持仓不存在: %s", decision.Symbol)
 	}
 
@@ -1179,8 +1594,8 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 		return fmt.Errorf("空单止损必须高于当前价格 (当前: %.2f, 新止损: %.2f)", marketData.CurrentPrice, decision.NewStopLoss)
 	}
 
-	// 取消旧的止损单（仅取消止损，不影响止盈）
-	if err := at.trader.CancelStopLossOrders(decision.Symbol); err != nil {
+	// 取消该方向旧的止损单（仅取消止损，不影响止盈与另一方向的止损）
+	if err := at.trader.CancelStopLossOrdersForSide(decision.Symbol, positionSide); err != nil {
 		log.Printf("  ⚠ 取消旧止损单失败: %v", err)
 		// 不中断执行，继续设置新止损
 	}
@@ -1193,6 +1608,7 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	}
 
 	log.Printf("  ✓ 止损已调整: %.2f (当前价格: %.2f)", decision.NewStopLoss, marketData.CurrentPrice)
+	at.trailing.setStop(decision.Symbol, side, decision.NewStopLoss)
 	return nil
 }
 
@@ -1370,10 +1786,7 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 		return fmt.Errorf("部分平仓失败: %w", err)
 	}
 
-	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
-	}
+	recordOrderResult(order, actionRecord)
 
 	remainingQuantity := totalQuantity - closeQuantity
 	log.Printf("  ✓ 部分平仓成功: 平仓 %.4f (%.1f%%), 剩余 %.4f",
@@ -1387,6 +1800,11 @@ func (at *AutoTrader) GetID() string {
 	return at.id
 }
 
+// GetUserID 获取trader所属用户ID
+func (at *AutoTrader) GetUserID() string {
+	return at.userID
+}
+
 // GetName 获取trader名称
 func (at *AutoTrader) GetName() string {
 	return at.name
@@ -1439,7 +1857,7 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 		"trader_name":     at.name,
 		"ai_model":        at.aiModel,
 		"exchange":        at.exchange,
-		"is_running":      at.isRunning,
+		"is_running":      at.IsRunning(),
 		"start_time":      at.startTime.Format(time.RFC3339),
 		"runtime_minutes": int(time.Since(at.startTime).Minutes()),
 		"call_count":      at.callCount,
@@ -1448,6 +1866,7 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 		"stop_until":      at.stopUntil.Format(time.RFC3339),
 		"last_reset_time": at.lastResetTime.Format(time.RFC3339),
 		"ai_provider":     aiProvider,
+		"kill_switch":     at.PauseState(),
 	}
 }
 
@@ -1640,6 +2059,31 @@ func sortDecisionsByPriority(decisions []decision.Decision) []decision.Decision
 	return sorted
 }
 
+// appendScreenerCoins 追加默认筛选器评分最高的币种（未启用筛选器或 ScreenerTopN<=0 时保持不变）
+func (at *AutoTrader) appendScreenerCoins(coins []decision.CandidateCoin) []decision.CandidateCoin {
+	screener := market.DefaultScreener()
+	if screener == nil || at.config.ScreenerTopN <= 0 {
+		return coins
+	}
+	index := make(map[string]int, len(coins))
+	for i, c := range coins {
+		index[c.Symbol] = i
+	}
+	added := 0
+	for _, symbol := range screener.TopSymbols(at.config.ScreenerTopN) {
+		if i, ok := index[symbol]; ok {
+			coins[i].Sources = append(coins[i].Sources, "screener")
+			continue
+		}
+		coins = append(coins, decision.CandidateCoin{Symbol: symbol, Sources: []string{"screener"}})
+		added++
+	}
+	if added > 0 {
+		log.Printf("🔭 [%s] 筛选器追加 %d 个候选币种", at.name, added)
+	}
+	return coins
+}
+
 // getCandidateCoins 获取交易员的候选币种列表
 func (at *AutoTrader) getCandidateCoins() ([]decision.CandidateCoin, error) {
 	log.Printf("🔧 getCandidateCoins 开始: trader=%s tradingCoins=%d defaultCoins=%d coinPoolAPIURL=%s", at.name, len(at.tradingCoins), len(at.defaultCoins), at.config.CoinPoolAPIURL)
@@ -1777,12 +2221,27 @@ func (at *AutoTrader) startDrawdownMonitor() {
 		ticker := time.NewTicker(1 * time.Minute) // 每分钟检查一次
 		defer ticker.Stop()
 
+		// 订阅实时成交价：价格触及回撤阈值时立即复查，无需等待下一分钟
+		var ticks <-chan market.PriceTick
+		if ch, cancel, err := market.SubscribePrice("", 256); err == nil {
+			ticks = ch
+			defer cancel()
+		}
+
 		log.Println("📊 启动持仓回撤监控（每分钟检查一次）")
 
 		for {
 			select {
 			case <-ticker.C:
 				at.checkPositionDrawdown()
+			case tick, ok := <-ticks:
+				if !ok {
+					ticks = nil
+					continue
+				}
+				if at.drawdownTriggeredBy(tick) {
+					at.checkPositionDrawdown()
+				}
 			case <-at.stopMonitorCh:
 				log.Println("⏹ 停止持仓回撤监控")
 				return
@@ -1800,6 +2259,9 @@ func (at *AutoTrader) checkPositionDrawdown() {
 		return
 	}
 
+	held := make(map[string]drawdownPosition, len(positions))
+	defer func() { at.drawdownPositions = held }()
+
 	for _, pos := range positions {
 		symbol := pos["symbol"].(string)
 		side := pos["side"].(string)
@@ -1816,6 +2278,11 @@ func (at *AutoTrader) checkPositionDrawdown() {
 			leverage = int(lev)
 		}
 
+		held[symbol] = drawdownPosition{side: side, entryPrice: entryPrice, leverage: leverage}
+		if err := market.TrackPrice(symbol); err != nil && err != market.ErrMonitorNotInitialized {
+			log.Printf("⚠️  回撤监控：%v", err)
+		}
+
 		var currentPnLPct float64
 		if side == "long" {
 			currentPnLPct = ((markPrice - entryPrice) / entryPrice) * float64(leverage) * 100
@@ -1862,6 +2329,13 @@ func (at *AutoTrader) checkPositionDrawdown() {
 				log.Printf("❌ 回撤平仓失败 (%s %s): %v", symbol, side, err)
 			} else {
 				log.Printf("✅ 回撤平仓成功: %s %s", symbol, side)
+				notify.Send(notify.Event{
+					Type:     notify.EventPositionClosed,
+					Title:    fmt.Sprintf("🚨 [%s] 回撤平仓 %s %s", at.name, symbol, side),
+					Message:  fmt.Sprintf("收益从 %.2f%% 回撤到 %.2f%%（回撤 %.2f%%）", peakPnLPct, currentPnLPct, drawdownPct),
+					TraderID: at.id,
+					Symbol:   symbol,
+				})
 				// 平仓后清理该symbol的缓存
 				at.ClearPeakPnLCache(symbol)
 			}
@@ -1873,8 +2347,55 @@ func (at *AutoTrader) checkPositionDrawdown() {
 	}
 }
 
+// drawdownPosition 回撤监控使用的持仓快照
+type drawdownPosition struct {
+	side       string
+	entryPrice float64
+	leverage   int
+}
+
+// tickDrawdownCheckInterval 实时成交触发回撤检查的最小间隔（避免频繁请求交易所持仓）
+const tickDrawdownCheckInterval = 5 * time.Second
+
+// drawdownTriggeredBy 用实时成交价估算持仓盈亏，达到回撤平仓条件时返回 true
+func (at *AutoTrader) drawdownTriggeredBy(tick market.PriceTick) bool {
+	pos, ok := at.drawdownPositions[tick.Symbol]
+	if !ok || pos.entryPrice <= 0 {
+		return false
+	}
+	if time.Since(at.lastTickDrawdownCheck) < tickDrawdownCheckInterval {
+		return false
+	}
+
+	var currentPnLPct float64
+	if pos.side == "long" {
+		currentPnLPct = ((tick.Price - pos.entryPrice) / pos.entryPrice) * float64(pos.leverage) * 100
+	} else {
+		currentPnLPct = ((pos.entryPrice - tick.Price) / pos.entryPrice) * float64(pos.leverage) * 100
+	}
+
+	at.peakPnLCacheMutex.RLock()
+	peakPnLPct, exists := at.peakPnLCache[tick.Symbol]
+	at.peakPnLCacheMutex.RUnlock()
+	if !exists || peakPnLPct <= 0 || currentPnLPct >= peakPnLPct {
+		return false
+	}
+
+	drawdownPct := ((peakPnLPct - currentPnLPct) / peakPnLPct) * 100
+	if currentPnLPct > 10 && drawdownPct >= (currentPnLPct/2.5) {
+		at.lastTickDrawdownCheck = time.Now()
+		log.Printf("⚡ 实时价格触及回撤阈值: %s %.6f | 估算收益: %.2f%% | 最高: %.2f%%，立即复查",
+			tick.Symbol, tick.Price, currentPnLPct, peakPnLPct)
+		return true
+	}
+	return false
+}
+
 // 紧急平仓函数
 func (at *AutoTrader) emergencyClosePosition(symbol, side string) error {
+	at.orderMu.Lock()
+	defer at.orderMu.Unlock()
+
 	switch side {
 	case "long":
 		order, err := at.trader.CloseLong(symbol, 0) // 0 = 全部平仓
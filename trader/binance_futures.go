@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"nofx/market"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,7 +14,7 @@ import (
 )
 
 // 全局日志前缀（避免与同包其他文件的同名变量冲突）
-var binanceName = "[Binance] "
+const binanceName = "[Binance] "
 
 // FuturesTrader 币安合约交易器
 type FuturesTrader struct {
@@ -316,6 +317,7 @@ func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int)
 		PositionSide(futures.PositionSideTypeLong).
 		Type(futures.OrderTypeMarket).
 		Quantity(quantityStr).
+		NewClientOrderID(newClientOrderID(clientOrderOpen)).
 		Do(context.Background())
 
 	if err != nil {
@@ -325,11 +327,7 @@ func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int)
 	log.Printf(binanceName+"✓ 开多仓成功: %s 数量: %s", symbol, quantityStr)
 	log.Printf(binanceName+"  订单ID: %d", order.OrderID)
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return t.fillResult(order), nil
 }
 
 // OpenShort 开空仓
@@ -370,6 +368,7 @@ func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int)
 		PositionSide(futures.PositionSideTypeShort).
 		Type(futures.OrderTypeMarket).
 		Quantity(quantityStr).
+		NewClientOrderID(newClientOrderID(clientOrderOpen)).
 		Do(context.Background())
 
 	if err != nil {
@@ -379,11 +378,7 @@ func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int)
 	log.Printf(binanceName+"✓ 开空仓成功: %s 数量: %s", symbol, quantityStr)
 	log.Printf(binanceName+"  订单ID: %d", order.OrderID)
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return t.fillResult(order), nil
 }
 
 // CloseLong 平多仓
@@ -420,6 +415,7 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 		PositionSide(futures.PositionSideTypeLong).
 		Type(futures.OrderTypeMarket).
 		Quantity(quantityStr).
+		NewClientOrderID(newClientOrderID(clientOrderClose)).
 		Do(context.Background())
 
 	if err != nil {
@@ -433,11 +429,7 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 		log.Printf(binanceName+"  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return t.fillResult(order), nil
 }
 
 // CloseShort 平空仓
@@ -474,6 +466,7 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 		PositionSide(futures.PositionSideTypeShort).
 		Type(futures.OrderTypeMarket).
 		Quantity(quantityStr).
+		NewClientOrderID(newClientOrderID(clientOrderClose)).
 		Do(context.Background())
 
 	if err != nil {
@@ -487,15 +480,66 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 		log.Printf(binanceName+"  ⚠ 取消挂单失败: %v", err)
 	}
 
+	return t.fillResult(order), nil
+}
+
+// 市价单回报仍为 NEW（尚未成交）时查询订单的次数与间隔
+const (
+	orderFillQueryAttempts = 3
+	orderFillQueryInterval = 300 * time.Millisecond
+)
+
+// fillResult 市价单回报：订单ID、状态、成交数量与成交均价（executedQty/avgPrice，float64）
+// 下单回报默认为 ACK，状态为 NEW 且没有成交价，此时查询订单补齐；仍未成交时不填成交价，由调用方沿用下单前的市场价
+func (t *FuturesTrader) fillResult(order *futures.CreateOrderResponse) map[string]interface{} {
+	status, executedQty, avgPrice := order.Status, order.ExecutedQuantity, order.AvgPrice
+	for i := 0; i < orderFillQueryAttempts && (status == futures.OrderStatusTypeNew || parseFloatOrZero(avgPrice) <= 0); i++ {
+		if i > 0 {
+			time.Sleep(orderFillQueryInterval)
+		}
+		o, err := t.client.NewGetOrderService().Symbol(order.Symbol).OrderID(order.OrderID).Do(context.Background())
+		if err != nil {
+			log.Printf(binanceName+"  ⚠ 查询订单 %d 成交结果失败: %v", order.OrderID, err)
+			break
+		}
+		status, executedQty, avgPrice = o.Status, o.ExecutedQuantity, o.AvgPrice
+	}
+
 	result := make(map[string]interface{})
 	result["orderId"] = order.OrderID
 	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	result["status"] = status
+	if order.ClientOrderID != "" {
+		result["clientOrderId"] = order.ClientOrderID
+	}
+	if qty := parseFloatOrZero(executedQty); qty > 0 {
+		result["executedQty"] = qty
+	}
+	if price := parseFloatOrZero(avgPrice); price > 0 {
+		result["avgPrice"] = price
+		log.Printf(binanceName+"  成交均价: %s 成交数量: %s", avgPrice, executedQty)
+	}
+	return result
+}
+
+// parseFloatOrZero 解析交易所返回的数字字符串（空串或格式错误时为 0）
+func parseFloatOrZero(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
 }
 
 // CancelStopLossOrders 仅取消止损单（不影响止盈单）
 func (t *FuturesTrader) CancelStopLossOrders(symbol string) error {
+	return t.cancelStopLossOrders(symbol, "")
+}
+
+// CancelStopLossOrdersForSide 仅取消指定持仓方向的止损单（单向持仓模式的 BOTH 订单同样取消）
+func (t *FuturesTrader) CancelStopLossOrdersForSide(symbol, positionSide string) error {
+	return t.cancelStopLossOrders(symbol, positionSide)
+}
+
+// cancelStopLossOrders 取消止损单，positionSide 为空时取消所有方向
+func (t *FuturesTrader) cancelStopLossOrders(symbol, positionSide string) error {
 	// 获取该币种的所有未完成订单
 	orders, err := t.client.NewListOpenOrdersService().
 		Symbol(symbol).
@@ -512,6 +556,9 @@ func (t *FuturesTrader) CancelStopLossOrders(symbol string) error {
 
 		// 只取消止损订单（不取消止盈订单）
 		if orderType == futures.OrderTypeStopMarket || orderType == futures.OrderTypeStop {
+			if !matchesPositionSide(string(order.PositionSide), positionSide) {
+				continue
+			}
 			_, err := t.client.NewCancelOrderService().
 				Symbol(symbol).
 				OrderID(order.OrderID).
@@ -536,6 +583,11 @@ func (t *FuturesTrader) CancelStopLossOrders(symbol string) error {
 	return nil
 }
 
+// matchesPositionSide 订单的持仓方向是否属于 want（want 为空表示不限；单向持仓模式的 BOTH 总是匹配）
+func matchesPositionSide(orderSide, want string) bool {
+	return want == "" || orderSide == "" || orderSide == "BOTH" || strings.EqualFold(orderSide, want)
+}
+
 // CancelTakeProfitOrders 仅取消止盈单（不影响止损单）
 func (t *FuturesTrader) CancelTakeProfitOrders(symbol string) error {
 	// 获取该币种的所有未完成订单
@@ -690,7 +742,8 @@ func (t *FuturesTrader) SetStopLoss(symbol string, positionSide string, quantity
 		Side(side).
 		PositionSide(posSide).
 		Type(futures.OrderTypeStopMarket).
-		StopPrice(fmt.Sprintf("%.8f", stopPrice)).
+		NewClientOrderID(newClientOrderID(clientOrderStopLoss)).
+		StopPrice(t.FormatPrice(symbol, stopPrice)).
 		Quantity(quantityStr).
 		WorkingType(futures.WorkingTypeContractPrice).
 		ClosePosition(true).
@@ -728,7 +781,8 @@ func (t *FuturesTrader) SetTakeProfit(symbol string, positionSide string, quanti
 		Side(side).
 		PositionSide(posSide).
 		Type(futures.OrderTypeTakeProfitMarket).
-		StopPrice(fmt.Sprintf("%.8f", takeProfitPrice)).
+		NewClientOrderID(newClientOrderID(clientOrderTakeProfit)).
+		StopPrice(t.FormatPrice(symbol, takeProfitPrice)).
 		Quantity(quantityStr).
 		WorkingType(futures.WorkingTypeContractPrice).
 		ClosePosition(true).
@@ -837,7 +891,11 @@ func trimTrailingZeros(s string) string {
 }
 
 // FormatQuantity 格式化数量到正确的精度
+// 优先使用行情模块缓存的交易规则（按 stepSize 向下取整），缓存不可用时再单独查询精度
 func (t *FuturesTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	if rules, ok := market.GetSymbolRules(symbol); ok && rules.StepSize > 0 {
+		return rules.FormatQty(quantity), nil
+	}
 	precision, err := t.GetSymbolPrecision(symbol)
 	if err != nil {
 		// 如果获取失败，使用默认格式
@@ -848,6 +906,14 @@ func (t *FuturesTrader) FormatQuantity(symbol string, quantity float64) (string,
 	return fmt.Sprintf(format, quantity), nil
 }
 
+// FormatPrice 按交易对 tickSize 格式化价格（交易规则不可用时保留8位小数）
+func (t *FuturesTrader) FormatPrice(symbol string, price float64) string {
+	if rules, ok := market.GetSymbolRules(symbol); ok && rules.TickSize > 0 {
+		return rules.FormatPrice(price)
+	}
+	return fmt.Sprintf("%.8f", price)
+}
+
 // 辅助函数
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && stringContains(s, substr)
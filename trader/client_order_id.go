@@ -0,0 +1,26 @@
+package trader
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 下单时指定的 clientOrderId
+//
+// 开平仓与止损/止盈单都带上本程序生成的 clientOrderId（nofx_<类型>_<毫秒时间>_<序号>，base36，不超过币安 36 字符限制），
+// 开平仓的 id 随成交回报返回并写入决策记录，对账工具据此精确匹配订单，不再依赖时间窗口。
+
+const (
+	clientOrderOpen       = "o"
+	clientOrderClose      = "c"
+	clientOrderStopLoss   = "sl"
+	clientOrderTakeProfit = "tp"
+)
+
+var clientOrderSeq atomic.Uint64
+
+// newClientOrderID 生成进程内唯一的 clientOrderId（毫秒时间区分进程重启，序号区分同一毫秒内的订单）
+func newClientOrderID(kind string) string {
+	return "nofx_" + kind + "_" + strconv.FormatInt(time.Now().UnixMilli(), 36) + "_" + strconv.FormatUint(clientOrderSeq.Add(1), 36)
+}
@@ -0,0 +1,52 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/logger"
+	"regexp"
+	"testing"
+)
+
+// 币安 newClientOrderId 的取值规则
+var binanceClientOrderID = regexp.MustCompile(`^[\.A-Z\:/a-z0-9_-]{1,36}$`)
+
+func TestNewClientOrderID(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, kind := range []string{clientOrderOpen, clientOrderClose, clientOrderStopLoss, clientOrderTakeProfit} {
+		for i := 0; i < 100; i++ {
+			id := newClientOrderID(kind)
+			if !binanceClientOrderID.MatchString(id) {
+				t.Fatalf("clientOrderId 不符合币安规则: %q", id)
+			}
+			if seen[id] {
+				t.Fatalf("clientOrderId 重复: %q", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestCloseRecordsClientOrderID(t *testing.T) {
+	const symbol = "BTCUSDT"
+	at, paper := newTrailingTestTrader(t, symbol, 60000, TrailingStopRules{})
+	open, err := paper.OpenLong(symbol, 0.1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var openRecord logger.DecisionAction
+	recordOrderResult(open, &openRecord)
+
+	d := decision.Decision{Symbol: symbol, Action: "close_long"}
+	var closeRecord logger.DecisionAction
+	if err := at.executeDecisionWithRecord(&d, &closeRecord); err != nil {
+		t.Fatal(err)
+	}
+	for name, rec := range map[string]logger.DecisionAction{"开仓": openRecord, "平仓": closeRecord} {
+		if rec.OrderID == 0 || rec.ClientOrderID == "" {
+			t.Errorf("%s记录应包含订单ID与 clientOrderId, got %d %q", name, rec.OrderID, rec.ClientOrderID)
+		}
+	}
+	if openRecord.ClientOrderID == closeRecord.ClientOrderID {
+		t.Errorf("开平仓的 clientOrderId 应不同: %q", openRecord.ClientOrderID)
+	}
+}
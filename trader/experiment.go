@@ -0,0 +1,75 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"nofx/decision"
+	"nofx/experiments"
+	"nofx/logger"
+	"nofx/mcp"
+	"nofx/performance"
+)
+
+// experimentLookbackRecords 实验对比读取的决策记录条数
+const experimentLookbackRecords = 2000
+
+// assignExperiment 为本周期分组，标记决策记录与上下文，返回本周期使用的提示词模板与AI客户端
+// （未参与实验、分到对照组或实验组的模板不存在时沿用交易员自己的配置；模板不存在时本周期不计入实验）
+func (at *AutoTrader) assignExperiment(record *logger.DecisionRecord, ctx *decision.Context) (string, *mcp.Client) {
+	template, client := at.systemPromptTemplate, at.mcpClient
+	a, ok := experiments.Assign(at.id, at.callCount)
+	if !ok {
+		return template, client
+	}
+	if a.PromptTemplate != "" {
+		if _, err := decision.GetPromptTemplate(a.PromptTemplate); err != nil {
+			log.Printf("⚠️  实验 %s 的 %s 组提示词模板不可用，本周期不计入实验: %v", a.Experiment, a.Arm, err)
+			return template, client
+		}
+		template = a.PromptTemplate
+	}
+	if a.Model != "" {
+		client = at.mcpClient.WithModel(a.Model)
+	}
+
+	record.Experiment, record.ExperimentArm = a.Experiment, a.Arm
+	ctx.Experiment = a.UsageTag(at.id)
+	log.Printf("🧪 实验 %s: %s 组 [模板: %s | 模型: %s]", a.Experiment, a.Arm, template, client.Model)
+	return template, client
+}
+
+// ExperimentReports 交易员参与的各实验（包括已停用的）的分组对比，AI费用取进程启动以来按用量标签的汇总
+// （汇总不受内存中调用记录条数上限影响）
+func (at *AutoTrader) ExperimentReports() ([]experiments.Report, error) {
+	var joined []experiments.Experiment
+	for _, e := range experiments.List() {
+		if e.Includes(at.id) {
+			joined = append(joined, e)
+		}
+	}
+	reports := make([]experiments.Report, 0, len(joined))
+	if len(joined) == 0 {
+		return reports, nil
+	}
+
+	records, err := at.decisionLogger.GetLatestRecords(experimentLookbackRecords)
+	if err != nil {
+		return nil, fmt.Errorf("读取决策记录失败: %w", err)
+	}
+	store, _ := performance.SharedStore() // 对账数据库不可用时按日志价格估算
+	outcomes := performance.NewTracker(at.id, store).Outcomes(records)
+	usage := at.mcpClient.Usage
+	if usage == nil {
+		usage = mcp.DefaultUsageTracker()
+	}
+	costs, _ := usage.StatsByTag(mcp.TagExperiment)
+	for _, e := range joined {
+		report := experiments.BuildReport(e, records, outcomes)
+		for i := range report.Arms {
+			tag := experiments.Assignment{Experiment: e.Name, Arm: report.Arms[i].Arm}.UsageTag(at.id)
+			report.Arms[i].AICostUSD = costs[tag].CostUSD
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
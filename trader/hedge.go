@@ -0,0 +1,46 @@
+package trader
+
+import (
+	"context"
+	"fmt"
+	"nofx/account"
+	"nofx/market"
+)
+
+// HedgeSuggestions 按当前持仓与各币种相对 BTC/ETH 的 beta，给出以每个基准中和组合 beta 的对冲建议
+// （beta 取行情数据中样本最多的相关性窗口；缺少数据的币种列入 Unhedged）
+func (at *AutoTrader) HedgeSuggestions(ctx context.Context) ([]*account.HedgeSuggestion, error) {
+	snap, err := at.GetExposure(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取组合敞口失败: %w", err)
+	}
+
+	// 同一币种只取一次行情（各基准共用）
+	cache := make(map[string]*market.Data)
+	get := func(symbol string) *market.Data {
+		if data, ok := cache[symbol]; ok {
+			return data
+		}
+		data, _ := market.Get(symbol) // 获取失败时为 nil，该币种计入 Unhedged
+		cache[symbol] = data
+		return data
+	}
+	beta := func(symbol, benchmark string) (float64, float64, bool) {
+		data := get(symbol)
+		if data == nil {
+			return 0, 0, false
+		}
+		stat, ok := data.Correlation.Best(benchmark)
+		return stat.Beta, stat.Correlation, ok
+	}
+
+	hedges := make([]*account.HedgeSuggestion, 0, len(account.HedgeBenchmarks))
+	for _, benchmark := range account.HedgeBenchmarks {
+		var price float64
+		if data := get(benchmark); data != nil {
+			price = data.CurrentPrice
+		}
+		hedges = append(hedges, account.SuggestHedge(snap, benchmark, price, beta))
+	}
+	return hedges, nil
+}
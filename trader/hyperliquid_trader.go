@@ -560,6 +560,11 @@ func (t *HyperliquidTrader) CancelStopLossOrders(symbol string) error {
 	return t.CancelStopOrders(symbol)
 }
 
+// CancelStopLossOrdersForSide Hyperliquid 为单向持仓，同一币种只有一个方向，等同于 CancelStopLossOrders
+func (t *HyperliquidTrader) CancelStopLossOrdersForSide(symbol, positionSide string) error {
+	return t.CancelStopLossOrders(symbol)
+}
+
 // CancelTakeProfitOrders 仅取消止盈单（Hyperliquid 暂无法区分止损和止盈，取消所有）
 func (t *HyperliquidTrader) CancelTakeProfitOrders(symbol string) error {
 	// Hyperliquid SDK 的 OpenOrder 结构不暴露 trigger 字段
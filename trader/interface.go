@@ -39,6 +39,9 @@ type Trader interface {
 	// CancelStopLossOrders 仅取消止损单（修复 BUG：调整止损时不删除止盈）
 	CancelStopLossOrders(symbol string) error
 
+	// CancelStopLossOrdersForSide 仅取消指定持仓方向（LONG/SHORT）的止损单，双向持仓时不影响另一方向
+	CancelStopLossOrdersForSide(symbol, positionSide string) error
+
 	// CancelTakeProfitOrders 仅取消止盈单（修复 BUG：调整止盈时不删除止损）
 	CancelTakeProfitOrders(symbol string) error
 
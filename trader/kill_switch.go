@@ -0,0 +1,157 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/killswitch"
+	"nofx/logger"
+	"nofx/notify"
+	"nofx/risk"
+	"time"
+)
+
+// killSwitchCheckInterval 紧急停止状态的轮询间隔（文件/数据库修改后最迟这么久生效）
+const killSwitchCheckInterval = 5 * time.Second
+
+// startKillSwitchMonitor 启动紧急停止监控：状态变化时写入决策记录，需要时平掉全部持仓
+// 开仓拦截在风控检查中完成（risk.RuleKillSwitch），不依赖该监控
+func (at *AutoTrader) startKillSwitchMonitor() {
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+
+		ticker := time.NewTicker(killSwitchCheckInterval)
+		defer ticker.Stop()
+
+		at.CheckKillSwitch()
+		for {
+			select {
+			case <-ticker.C:
+				at.CheckKillSwitch()
+			case <-at.stopMonitorCh:
+				return
+			}
+		}
+	}()
+}
+
+// CheckKillSwitch 立即检查紧急停止状态（接口暂停后调用，无需等待下次轮询）
+// pauseMu 只保护状态切换，写日志与平仓的网络请求在锁外执行，PauseState 不会被交易所请求阻塞
+func (at *AutoTrader) CheckKillSwitch() killswitch.State {
+	st := killswitch.Status(at.id)
+
+	at.pauseMu.Lock()
+	prev := at.pause
+	at.pause = st
+	// 同一次暂停只平仓一次（有失败时下次轮询重试）；正在平仓时不重复发起
+	flatten := st.Active && st.Flatten && !at.flattenedSince.Equal(st.Since) && !at.flattening
+	if flatten {
+		at.flattening = true
+	}
+	at.pauseMu.Unlock()
+
+	switch {
+	case st.Active && (!prev.Active || !prev.Since.Equal(st.Since)):
+		log.Printf("⛔ [%s] %s，停止开新仓", at.name, st.Describe())
+		at.logPauseRecord(fmt.Sprintf("⛔ 交易暂停: %s", st.Describe()), &logger.RiskOverride{Rule: risk.RuleKillSwitch, Reason: st.Describe()})
+		notify.Send(notify.Event{
+			Type:     notify.EventTradingPaused,
+			Title:    fmt.Sprintf("⛔ [%s] 交易暂停", at.name),
+			Message:  st.Describe(),
+			TraderID: at.id,
+			Fields:   map[string]string{"flatten": fmt.Sprint(st.Flatten)},
+		})
+	case !st.Active && prev.Active:
+		log.Printf("▶️ [%s] 紧急停止已解除，恢复开仓", at.name)
+		at.logPauseRecord("▶️ 交易恢复: 紧急停止已解除", nil)
+		notify.Send(notify.Event{
+			Type:     notify.EventTradingResumed,
+			Title:    fmt.Sprintf("▶️ [%s] 交易恢复", at.name),
+			Message:  "紧急停止已解除",
+			TraderID: at.id,
+		})
+	}
+
+	if flatten {
+		ok := at.flattenPositions(st)
+		at.pauseMu.Lock()
+		at.flattening = false
+		if ok {
+			at.flattenedSince = st.Since
+		}
+		at.pauseMu.Unlock()
+	}
+	return st
+}
+
+// PauseState 最近一次检查到的紧急停止状态
+func (at *AutoTrader) PauseState() killswitch.State {
+	at.pauseMu.Lock()
+	defer at.pauseMu.Unlock()
+	return at.pause
+}
+
+// flattenPositions 平掉全部持仓，平仓动作写入一条决策记录；全部成功时返回 true
+func (at *AutoTrader) flattenPositions(st killswitch.State) bool {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("❌ [%s] 紧急平仓：获取持仓失败: %v", at.name, err)
+		return false
+	}
+	if len(positions) == 0 {
+		return true
+	}
+
+	log.Printf("🚨 [%s] 紧急停止要求平仓，平掉 %d 个持仓", at.name, len(positions))
+	record := &logger.DecisionRecord{
+		ExecutionLog:  []string{fmt.Sprintf("🚨 紧急平仓: %s", st.Describe())},
+		Success:       true,
+		RiskOverrides: []logger.RiskOverride{{Rule: risk.RuleKillSwitch, Reason: st.Describe()}},
+	}
+	ok := true
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		d := decision.Decision{Symbol: symbol, Action: "close_" + side, Reasoning: "紧急停止平仓: " + st.Reason}
+		actionRecord := logger.DecisionAction{Action: d.Action, Symbol: symbol, Timestamp: time.Now()}
+		if amt, found := pos["positionAmt"].(float64); found {
+			actionRecord.Quantity = math.Abs(amt)
+		}
+		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
+			ok = false
+			actionRecord.Error = err.Error()
+			record.Success = false
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %v", symbol, d.Action, err))
+			log.Printf("❌ [%s] 紧急平仓失败 (%s %s): %v", at.name, symbol, side, err)
+		} else {
+			actionRecord.Success = true
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", symbol, d.Action))
+			at.notifyTrade(&d, &actionRecord)
+			at.ClearPeakPnLCache(symbol)
+		}
+		record.Decisions = append(record.Decisions, actionRecord)
+	}
+	if !ok {
+		record.ErrorMessage = "部分持仓紧急平仓失败，稍后重试"
+	}
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠ 保存紧急平仓记录失败: %v", err)
+	}
+	return ok
+}
+
+// logPauseRecord 把暂停/恢复写入决策日志（没有执行动作的记录）
+func (at *AutoTrader) logPauseRecord(message string, override *logger.RiskOverride) {
+	record := &logger.DecisionRecord{
+		ExecutionLog: []string{message},
+		Success:      true,
+	}
+	if override != nil {
+		record.RiskOverrides = append(record.RiskOverrides, *override)
+	}
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠ 保存暂停记录失败: %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"nofx/market"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 模拟盘（exchange = "paper"）
+//
+// PaperTrader 实现 Trader 接口，按实时价格（行情监控的成交价，没有时取 market.Get 的最新价）在内存中撮合：
+// 市价成交并计入滑点与 Taker 手续费，全仓按可用余额校验保证金。止损/止盈单只在读取余额、持仓或价格时
+// 按当时价格检查并触发，不模拟盘中的最高/最低价。重启后模拟账户从初始余额重新开始。
+
+const (
+	paperName        = "[Paper] "
+	paperFeeRate     = 0.0004 // Taker 手续费率（与币安 USDT 合约一致）
+	paperSlippageBps = 2.0    // 市价单滑点（万分之）
+)
+
+// paperPosition 模拟持仓
+type paperPosition struct {
+	symbol     string
+	side       string // long/short
+	quantity   float64
+	entryPrice float64
+	leverage   int
+	stopLoss   float64
+	takeProfit float64
+}
+
+// unrealized 按指定价格计算的未实现盈亏
+func (p *paperPosition) unrealized(price float64) float64 {
+	if p.side == "long" {
+		return (price - p.entryPrice) * p.quantity
+	}
+	return (p.entryPrice - price) * p.quantity
+}
+
+// margin 按开仓价计算的保证金
+func (p *paperPosition) margin() float64 {
+	return p.entryPrice * p.quantity / float64(p.leverage)
+}
+
+// PaperTrader 模拟盘交易器
+type PaperTrader struct {
+	mu          sync.Mutex
+	balance     float64 // 钱包余额（已计入已实现盈亏与手续费）
+	positions   map[string]*paperPosition
+	leverage    map[string]int
+	nextOrderID int64
+	priceFunc   func(symbol string) (float64, error)
+}
+
+// NewPaperTrader 创建模拟盘交易器
+func NewPaperTrader(initialBalance float64) *PaperTrader {
+	return &PaperTrader{
+		balance:     initialBalance,
+		positions:   make(map[string]*paperPosition),
+		leverage:    make(map[string]int),
+		nextOrderID: time.Now().UnixMilli(),
+		priceFunc:   paperMarketPrice,
+	}
+}
+
+// paperMarketPrice 优先使用实时成交价，没有订阅时取行情快照的最新价
+func paperMarketPrice(symbol string) (float64, error) {
+	if price, ok := market.LastPrice(symbol); ok && price > 0 {
+		return price, nil
+	}
+	data, err := market.Get(symbol)
+	if err != nil {
+		return 0, fmt.Errorf(paperName+"获取价格失败: %w", err)
+	}
+	if data.CurrentPrice <= 0 {
+		return 0, fmt.Errorf(paperName+"%s 价格无效", symbol)
+	}
+	return data.CurrentPrice, nil
+}
+
+func paperPositionKey(symbol, side string) string { return symbol + "_" + side }
+
+// marks 持仓交易对的当前价格（获取失败的交易对按开仓价计算）
+func (t *PaperTrader) marks() map[string]float64 {
+	marks := make(map[string]float64)
+	for _, p := range t.positions {
+		if _, ok := marks[p.symbol]; ok {
+			continue
+		}
+		price, err := t.priceFunc(p.symbol)
+		if err != nil {
+			log.Printf(paperName+"⚠ %v，按开仓价计算 %s", err, p.symbol)
+			price = p.entryPrice
+		}
+		marks[p.symbol] = price
+	}
+	return marks
+}
+
+// checkTriggers 按当前价格触发止损/止盈（调用方持有锁）
+func (t *PaperTrader) checkTriggers(marks map[string]float64) {
+	for key, p := range t.positions {
+		price := marks[p.symbol]
+		if price <= 0 {
+			continue
+		}
+		var reason string
+		if p.side == "long" {
+			if p.stopLoss > 0 && price <= p.stopLoss {
+				reason = "止损"
+			} else if p.takeProfit > 0 && price >= p.takeProfit {
+				reason = "止盈"
+			}
+		} else {
+			if p.stopLoss > 0 && price >= p.stopLoss {
+				reason = "止损"
+			} else if p.takeProfit > 0 && price <= p.takeProfit {
+				reason = "止盈"
+			}
+		}
+		if reason == "" {
+			continue
+		}
+		fill, pnl := t.fill(key, p.quantity, price)
+		log.Printf(paperName+"🎯 %s %s 触发%s，成交价 %.4f，盈亏 %.2f USDT", p.symbol, p.side, reason, fill, pnl)
+	}
+}
+
+// fill 以市价平掉持仓的 quantity（调用方持有锁），返回成交价与扣除手续费后的盈亏
+func (t *PaperTrader) fill(key string, quantity, price float64) (float64, float64) {
+	p := t.positions[key]
+	fill := paperSlip(price, p.side == "short")
+	fee := fill * quantity * paperFeeRate
+	pnl := (p.unrealized(fill)/p.quantity)*quantity - fee
+	t.balance += pnl
+	if quantity >= p.quantity*(1-1e-9) {
+		delete(t.positions, key)
+	} else {
+		p.quantity -= quantity
+	}
+	return fill, pnl
+}
+
+// paperSlip 按滑点计算成交价：买入上浮、卖出下浮
+func paperSlip(price float64, buy bool) float64 {
+	if buy {
+		return price * (1 + paperSlippageBps/10000)
+	}
+	return price * (1 - paperSlippageBps/10000)
+}
+
+// order 生成成交回报（kind 为 clientOrderId 类型，与实盘一致）
+func (t *PaperTrader) order(symbol, kind string, quantity, price float64) map[string]interface{} {
+	t.nextOrderID++
+	return map[string]interface{}{
+		"orderId":       t.nextOrderID,
+		"clientOrderId": newClientOrderID(kind),
+		"symbol":        symbol,
+		"status":        "FILLED",
+		"executedQty":   quantity,
+		"avgPrice":      price,
+	}
+}
+
+// GetBalance 获取模拟账户余额（字段与币安一致）
+func (t *PaperTrader) GetBalance() (map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	marks := t.marks()
+	t.checkTriggers(marks)
+	unrealized, marginUsed := 0.0, 0.0
+	for _, p := range t.positions {
+		unrealized += p.unrealized(marks[p.symbol])
+		marginUsed += p.margin()
+	}
+	return map[string]interface{}{
+		"totalWalletBalance":    t.balance,
+		"availableBalance":      t.balance + unrealized - marginUsed,
+		"totalUnrealizedProfit": unrealized,
+	}, nil
+}
+
+// GetPositions 获取模拟持仓（字段与币安一致，空仓 positionAmt 为负）
+func (t *PaperTrader) GetPositions() ([]map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	marks := t.marks()
+	t.checkTriggers(marks)
+	keys := make([]string, 0, len(t.positions))
+	for key := range t.positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		p := t.positions[key]
+		amt := p.quantity
+		liq := p.entryPrice * (1 - 1/float64(p.leverage))
+		if p.side == "short" {
+			amt = -amt
+			liq = p.entryPrice * (1 + 1/float64(p.leverage))
+		}
+		result = append(result, map[string]interface{}{
+			"symbol":           p.symbol,
+			"side":             p.side,
+			"positionAmt":      amt,
+			"entryPrice":       p.entryPrice,
+			"markPrice":        marks[p.symbol],
+			"unRealizedProfit": p.unrealized(marks[p.symbol]),
+			"leverage":         float64(p.leverage),
+			"liquidationPrice": liq,
+		})
+	}
+	return result, nil
+}
+
+// open 市价开仓
+func (t *PaperTrader) open(symbol, side string, quantity float64, leverage int) (map[string]interface{}, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf(paperName+"%s 开仓数量无效: %.8f", symbol, quantity)
+	}
+	price, err := t.priceFunc(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := paperPositionKey(symbol, side)
+	if _, ok := t.positions[key]; ok {
+		return nil, fmt.Errorf(paperName+"%s 已有%s仓位", symbol, side)
+	}
+	if leverage <= 0 {
+		leverage = t.leverage[symbol]
+	}
+	if leverage <= 0 {
+		leverage = 1
+	}
+	marks := t.marks()
+	t.checkTriggers(marks)
+
+	fill := paperSlip(price, side == "long")
+	fee := fill * quantity * paperFeeRate
+	margin := fill * quantity / float64(leverage)
+	available := t.balance
+	for _, p := range t.positions {
+		available += p.unrealized(marks[p.symbol]) - p.margin()
+	}
+	if margin+fee > available {
+		return nil, fmt.Errorf(paperName+"保证金不足: 需要 %.2f USDT，可用 %.2f USDT", margin+fee, available)
+	}
+	t.balance -= fee
+	t.positions[key] = &paperPosition{symbol: symbol, side: side, quantity: quantity, entryPrice: fill, leverage: leverage}
+	log.Printf(paperName+"✓ 开%s仓: %s 数量 %.6f 成交价 %.4f 手续费 %.4f", sideName(side), symbol, quantity, fill, fee)
+	return t.order(symbol, clientOrderOpen, quantity, fill), nil
+}
+
+// close 市价平仓（quantity=0 或超过持仓时全部平仓）
+func (t *PaperTrader) close(symbol, side string, quantity float64) (map[string]interface{}, error) {
+	price, err := t.priceFunc(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := paperPositionKey(symbol, side)
+	p, ok := t.positions[key]
+	if !ok {
+		return nil, fmt.Errorf(paperName+"没有找到 %s 的%s仓", symbol, sideName(side))
+	}
+	if quantity <= 0 || quantity > p.quantity {
+		quantity = p.quantity
+	}
+	fill, pnl := t.fill(key, quantity, price)
+	log.Printf(paperName+"✓ 平%s仓: %s 数量 %.6f 成交价 %.4f 盈亏 %.2f USDT", sideName(side), symbol, quantity, fill, pnl)
+	return t.order(symbol, clientOrderClose, quantity, fill), nil
+}
+
+func sideName(side string) string {
+	if side == "long" {
+		return "多"
+	}
+	return "空"
+}
+
+func (t *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return t.open(symbol, "long", quantity, leverage)
+}
+
+func (t *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return t.open(symbol, "short", quantity, leverage)
+}
+
+func (t *PaperTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.close(symbol, "long", quantity)
+}
+
+func (t *PaperTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.close(symbol, "short", quantity)
+}
+
+// SetLeverage 记录杠杆（开仓未指定杠杆时使用）
+func (t *PaperTrader) SetLeverage(symbol string, leverage int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leverage[symbol] = leverage
+	return nil
+}
+
+// SetMarginMode 模拟盘统一按全仓计算保证金
+func (t *PaperTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
+	return nil
+}
+
+func (t *PaperTrader) GetMarketPrice(symbol string) (float64, error) {
+	return t.priceFunc(symbol)
+}
+
+// setTrigger 设置持仓的止损或止盈价（同一持仓只保留最新的价格）
+func (t *PaperTrader) setTrigger(symbol, positionSide string, price float64, stopLoss bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	side := "long"
+	if positionSide == "SHORT" {
+		side = "short"
+	}
+	p, ok := t.positions[paperPositionKey(symbol, side)]
+	if !ok {
+		return fmt.Errorf(paperName+"没有找到 %s 的%s仓", symbol, sideName(side))
+	}
+	if stopLoss {
+		p.stopLoss = price
+	} else {
+		p.takeProfit = price
+	}
+	return nil
+}
+
+func (t *PaperTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return t.setTrigger(symbol, positionSide, stopPrice, true)
+}
+
+func (t *PaperTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return t.setTrigger(symbol, positionSide, takeProfitPrice, false)
+}
+
+// clearTriggers 清除该币种持仓的止损和/或止盈（side 为空时不限方向）
+func (t *PaperTrader) clearTriggers(symbol, side string, stopLoss, takeProfit bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range t.positions {
+		if p.symbol != symbol || (side != "" && p.side != side) {
+			continue
+		}
+		if stopLoss {
+			p.stopLoss = 0
+		}
+		if takeProfit {
+			p.takeProfit = 0
+		}
+	}
+}
+
+func (t *PaperTrader) CancelStopLossOrders(symbol string) error {
+	t.clearTriggers(symbol, "", true, false)
+	return nil
+}
+
+func (t *PaperTrader) CancelStopLossOrdersForSide(symbol, positionSide string) error {
+	t.clearTriggers(symbol, strings.ToLower(positionSide), true, false)
+	return nil
+}
+
+func (t *PaperTrader) CancelTakeProfitOrders(symbol string) error {
+	t.clearTriggers(symbol, "", false, true)
+	return nil
+}
+
+func (t *PaperTrader) CancelAllOrders(symbol string) error {
+	t.clearTriggers(symbol, "", true, true)
+	return nil
+}
+
+func (t *PaperTrader) CancelStopOrders(symbol string) error {
+	t.clearTriggers(symbol, "", true, true)
+	return nil
+}
+
+// FormatQuantity 有交易规则时按 stepSize 格式化，否则保留 6 位小数
+func (t *PaperTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	if rules, ok := market.GetSymbolRules(symbol); ok && rules.StepSize > 0 {
+		return rules.FormatQty(quantity), nil
+	}
+	return strconv.FormatFloat(math.Floor(quantity*1e6)/1e6, 'f', -1, 64), nil
+}
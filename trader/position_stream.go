@@ -0,0 +1,248 @@
+package trader
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// 持仓推送：通过用户数据流（ACCOUNT_UPDATE）接收持仓变化，并订阅持仓币种的标记价格。
+// 目前只有币安合约支持；其他交易所由调用方定时轮询 GetPositions。
+
+// PositionEvent 持仓或标记价格变化
+type PositionEvent struct {
+	Symbol     string
+	Side       string  // long/short；仅标记价格变化时为空
+	Quantity   float64 // 持仓数量（绝对值，0 表示已平仓）
+	EntryPrice float64
+	MarkPrice  float64
+	MarkOnly   bool // 仅标记价格变化（适用于该币种的所有持仓）
+}
+
+// positionStreamer 支持推送持仓变化的交易所
+type positionStreamer interface {
+	// StreamPositions 开始推送，stop 关闭后释放连接并关闭返回的通道
+	StreamPositions(stop <-chan struct{}) (<-chan PositionEvent, error)
+}
+
+const (
+	userStreamKeepalive  = 30 * time.Minute // listenKey 续期间隔（有效期 60 分钟）
+	userStreamMaxBackoff = time.Minute      // 重连退避上限
+)
+
+// StreamPositions 实现 positionStreamer
+func (t *FuturesTrader) StreamPositions(stop <-chan struct{}) (<-chan PositionEvent, error) {
+	listenKey, err := t.client.NewStartUserStreamService().Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan PositionEvent, 256)
+	go t.runPositionStream(listenKey, stop, out)
+	return out, nil
+}
+
+// runPositionStream 维持用户数据流与标记价格订阅：断线或 listenKey 过期时重建，退避从 1 秒倍增到 1 分钟
+func (t *FuturesTrader) runPositionStream(listenKey string, stop <-chan struct{}, out chan<- PositionEvent) {
+	defer close(out)
+	defer func() {
+		if listenKey != "" {
+			t.client.NewCloseUserStreamService().ListenKey(listenKey).Do(context.Background())
+		}
+	}()
+
+	send := func(ev PositionEvent, drop bool) {
+		if drop {
+			select {
+			case out <- ev:
+			default: // 标记价格只需要最新值，消费不及时时丢弃
+			}
+			return
+		}
+		select {
+		case out <- ev:
+		case <-stop:
+		}
+	}
+
+	// 标记价格订阅跟随持仓币种变化
+	var markSymbols []string
+	var markStop chan struct{}
+	resubscribeMark := func(symbols []string) {
+		sort.Strings(symbols)
+		if strings.Join(symbols, ",") == strings.Join(markSymbols, ",") {
+			return
+		}
+		if markStop != nil {
+			close(markStop)
+			markStop = nil
+		}
+		markSymbols = symbols
+		if len(symbols) == 0 {
+			return
+		}
+		_, stopC, err := futures.WsCombinedMarkPriceServe(symbols, func(e *futures.WsMarkPriceEvent) {
+			if mark, err := strconv.ParseFloat(e.MarkPrice, 64); err == nil && mark > 0 {
+				send(PositionEvent{Symbol: e.Symbol, MarkPrice: mark, MarkOnly: true}, true)
+			}
+		}, func(err error) {
+			log.Printf(binanceName+"⚠️ 标记价格推送错误: %v", err)
+		})
+		if err != nil {
+			log.Printf(binanceName+"⚠️ 订阅标记价格失败: %v", err)
+			markSymbols = nil
+			return
+		}
+		markStop = stopC
+	}
+	defer func() {
+		if markStop != nil {
+			close(markStop)
+		}
+	}()
+
+	held := make(map[string]bool) // symbol_side
+	heldSymbols := func() []string {
+		seen := make(map[string]bool)
+		var symbols []string
+		for key := range held {
+			symbol := key[:strings.LastIndex(key, "_")]
+			if !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+		return symbols
+	}
+	refreshHeld := func() {
+		positions, err := t.GetPositions()
+		if err != nil {
+			return
+		}
+		clear(held)
+		for _, pos := range positions {
+			symbol, _ := pos["symbol"].(string)
+			side, _ := pos["side"].(string)
+			held[symbol+"_"+side] = true
+		}
+		resubscribeMark(heldSymbols())
+	}
+	refreshHeld()
+
+	keepalive := time.NewTicker(userStreamKeepalive)
+	defer keepalive.Stop()
+	backoff := time.Second
+	wait := func() bool {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, userStreamMaxBackoff)
+		return true
+	}
+	for {
+		if listenKey == "" {
+			var err error
+			if listenKey, err = t.client.NewStartUserStreamService().Do(context.Background()); err != nil {
+				log.Printf(binanceName+"⚠️ 创建 listenKey 失败: %v", err)
+				listenKey = ""
+				if !wait() {
+					return
+				}
+				continue
+			}
+		}
+		expired := make(chan struct{}, 1)
+		positionsChanged := make(chan []PositionEvent, 16)
+		doneC, stopC, err := futures.WsUserDataServe(listenKey, func(e *futures.WsUserDataEvent) {
+			switch e.Event {
+			case futures.UserDataEventTypeListenKeyExpired:
+				select {
+				case expired <- struct{}{}:
+				default:
+				}
+			case futures.UserDataEventTypeAccountUpdate:
+				var events []PositionEvent
+				for _, p := range e.AccountUpdate.Positions {
+					amt, _ := strconv.ParseFloat(p.Amount, 64)
+					entry, _ := strconv.ParseFloat(p.EntryPrice, 64)
+					mark, _ := strconv.ParseFloat(p.MarkPrice, 64)
+					side := strings.ToLower(string(p.Side))
+					if p.Side == futures.PositionSideTypeBoth {
+						side = "long"
+						if amt < 0 {
+							side = "short"
+						}
+					}
+					events = append(events, PositionEvent{Symbol: p.Symbol, Side: side, Quantity: math.Abs(amt), EntryPrice: entry, MarkPrice: mark})
+				}
+				if len(events) > 0 {
+					select {
+					case positionsChanged <- events:
+					case <-stop:
+					}
+				}
+			}
+		}, func(err error) {
+			log.Printf(binanceName+"⚠️ 用户数据流错误: %v", err)
+		})
+
+		if err == nil {
+			backoff = time.Second
+		connected:
+			for {
+				select {
+				case <-stop:
+					close(stopC)
+					return
+				case events := <-positionsChanged:
+					t.invalidatePositionsCache()
+					for _, ev := range events {
+						if ev.Quantity > 0 {
+							held[ev.Symbol+"_"+ev.Side] = true
+						} else {
+							delete(held, ev.Symbol+"_"+ev.Side)
+						}
+						send(ev, false)
+					}
+					resubscribeMark(heldSymbols())
+				case <-keepalive.C:
+					if err := t.client.NewKeepaliveUserStreamService().ListenKey(listenKey).Do(context.Background()); err != nil {
+						log.Printf(binanceName+"⚠️ listenKey 续期失败: %v", err)
+					}
+				case <-expired:
+					log.Print(binanceName + "⚠️ listenKey 已过期，重新创建")
+					close(stopC)
+					<-doneC
+					listenKey = ""
+					break connected
+				case <-doneC:
+					log.Print(binanceName + "⚠️ 用户数据流断开，准备重连")
+					break connected
+				}
+			}
+		} else {
+			log.Printf(binanceName+"⚠️ 连接用户数据流失败: %v", err)
+		}
+
+		if !wait() {
+			return
+		}
+		// 断线期间的持仓变化可能已丢失，重连后以快照为准
+		t.invalidatePositionsCache()
+		refreshHeld()
+	}
+}
+
+// invalidatePositionsCache 持仓变化后使持仓缓存失效
+func (t *FuturesTrader) invalidatePositionsCache() {
+	t.positionsCacheMutex.Lock()
+	t.positionsCacheTime = time.Time{}
+	t.positionsCacheMutex.Unlock()
+}
@@ -0,0 +1,380 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/sizing"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 保本与移动止损
+//
+// 监控持仓的标记价格，按交易员配置的规则自动上移（多单）或下移（空单）止损，止损只收紧不放宽：
+//   - 保本：浮盈达到 break_even_at_r × R 时把止损移到开仓价（可加 break_even_offset_pct 覆盖手续费）；
+//   - 移动止损：浮盈达到 trail_activate_r × R 后，止损跟随价格保持 trail_atr_multiplier × ATR 的距离。
+//
+// R 为开仓时的止损距离；重启后不知道原始止损的持仓按 ATR × 止损倍数估算。
+// 当前止损未知的持仓（重启后或轮询新发现）不自动移动，直到开仓或 AI 调整止损后记录了当前止损。
+// 持仓来自定时 GetPositions，支持推送的交易所（币安）另通过用户数据流与标记价格推送实时更新。
+// 每次移动都作为一条 update_stop_loss 决策写入决策日志（decision_json 带 new_stop_loss），对账工具可以照常核对条件单。
+
+const (
+	trailingPollInterval = 15 * time.Second // 持仓快照刷新间隔
+	trailingMinInterval  = 30 * time.Second // 同一持仓两次移动止损的最小间隔
+	trailingMinStepPct   = 0.1              // 新止损至少比当前止损收紧价格的 0.1%，避免频繁撤单重下
+)
+
+// TrailingStopRules 交易员的保本/移动止损规则（0 表示不启用对应功能）
+type TrailingStopRules struct {
+	BreakEvenAtR       float64 `json:"break_even_at_r"`       // 浮盈达到 N×R 时移到保本
+	BreakEvenOffsetPct float64 `json:"break_even_offset_pct"` // 保本止损相对开仓价向盈利方向的偏移（百分比）
+	TrailATRMultiplier float64 `json:"trail_atr_multiplier"`  // 移动止损与价格的距离（ATR 倍数）
+	TrailActivateR     float64 `json:"trail_activate_r"`      // 浮盈达到 N×R 后开始移动止损
+}
+
+// ParseTrailingStopRules 解析交易员配置中的规则 JSON（空字符串表示不启用）
+func ParseTrailingStopRules(s string) (TrailingStopRules, error) {
+	var r TrailingStopRules
+	if strings.TrimSpace(s) == "" {
+		return r, nil
+	}
+	if err := json.Unmarshal([]byte(s), &r); err != nil {
+		return r, fmt.Errorf("解析移动止损规则失败: %w", err)
+	}
+	return r, r.Validate()
+}
+
+// Enabled 是否启用了保本或移动止损
+func (r TrailingStopRules) Enabled() bool {
+	return r.BreakEvenAtR > 0 || r.TrailATRMultiplier > 0
+}
+
+// Validate 检查规则取值
+func (r TrailingStopRules) Validate() error {
+	if r.BreakEvenAtR < 0 || r.BreakEvenOffsetPct < 0 || r.TrailATRMultiplier < 0 || r.TrailActivateR < 0 {
+		return fmt.Errorf("移动止损规则不能为负数")
+	}
+	if r.BreakEvenOffsetPct >= 5 {
+		return fmt.Errorf("break_even_offset_pct 过大: %.2f%%", r.BreakEvenOffsetPct)
+	}
+	return nil
+}
+
+// trailingPosition 移动止损跟踪的持仓
+type trailingPosition struct {
+	symbol      string
+	side        string
+	entryPrice  float64
+	quantity    float64
+	stopLoss    float64 // 当前止损（0 表示未知）
+	initialRisk float64 // R：开仓时的止损距离（0 表示未知）
+	lastMove    time.Time
+}
+
+// profitR 以 R 计的浮盈
+func (p *trailingPosition) profitR(price float64) float64 {
+	if p.initialRisk <= 0 {
+		return 0
+	}
+	if p.side == "short" {
+		return (p.entryPrice - price) / p.initialRisk
+	}
+	return (price - p.entryPrice) / p.initialRisk
+}
+
+// nextStop 按规则计算新的止损价；不需要移动时返回 0。
+// 当前止损未知时（重启后或轮询发现的持仓）不移动，避免挂出比交易所上现有止损更宽的止损。
+func (r TrailingStopRules) nextStop(p *trailingPosition, price, atr float64) (float64, string) {
+	profit := p.profitR(price)
+	if p.stopLoss <= 0 || p.initialRisk <= 0 || profit <= 0 {
+		return 0, ""
+	}
+	long := p.side != "short"
+	tighter := func(a, b float64) bool { // a 比 b 更紧
+		if b <= 0 {
+			return a > 0
+		}
+		if long {
+			return a > b
+		}
+		return a < b
+	}
+
+	var stop float64
+	var reason string
+	if r.BreakEvenAtR > 0 && profit >= r.BreakEvenAtR {
+		stop = p.entryPrice * (1 + r.BreakEvenOffsetPct/100)
+		if !long {
+			stop = p.entryPrice * (1 - r.BreakEvenOffsetPct/100)
+		}
+		reason = fmt.Sprintf("浮盈 %.2fR ≥ %.2fR，止损移到保本", profit, r.BreakEvenAtR)
+	}
+	if r.TrailATRMultiplier > 0 && atr > 0 && profit >= r.TrailActivateR {
+		trail := price - r.TrailATRMultiplier*atr
+		if !long {
+			trail = price + r.TrailATRMultiplier*atr
+		}
+		if tighter(trail, stop) {
+			stop = trail
+			reason = fmt.Sprintf("浮盈 %.2fR，止损跟随价格 %.4f 保持 %.1f×ATR(%.4f)", profit, price, r.TrailATRMultiplier, atr)
+		}
+	}
+
+	// 止损必须在价格的亏损一侧，且比当前止损收紧足够多
+	if stop <= 0 || (long && stop >= price) || (!long && stop <= price) {
+		return 0, ""
+	}
+	minStep := price * trailingMinStepPct / 100
+	if (long && stop < p.stopLoss+minStep) || (!long && stop > p.stopLoss-minStep) {
+		return 0, ""
+	}
+	return stop, reason
+}
+
+// trailingStop 单个交易员的移动止损状态
+type trailingStop struct {
+	rules TrailingStopRules
+
+	mu        sync.Mutex
+	positions map[string]*trailingPosition // symbol_side
+}
+
+func newTrailingStop(rules TrailingStopRules) *trailingStop {
+	return &trailingStop{rules: rules, positions: make(map[string]*trailingPosition)}
+}
+
+// track 记录新开仓的开仓价与初始止损
+func (ts *trailingStop) track(symbol, side string, entryPrice, quantity, stopLoss float64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	p := &trailingPosition{symbol: symbol, side: side, entryPrice: entryPrice, quantity: quantity, stopLoss: stopLoss}
+	if stopLoss > 0 && entryPrice > 0 {
+		p.initialRisk = math.Abs(entryPrice - stopLoss)
+	}
+	ts.positions[symbol+"_"+side] = p
+}
+
+// setStop 止损被调整（AI 决策或自动移动）后更新当前止损
+func (ts *trailingStop) setStop(symbol, side string, stopLoss float64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if p, ok := ts.positions[symbol+"_"+side]; ok {
+		p.stopLoss = stopLoss
+	}
+}
+
+// update 按持仓快照或推送更新持仓（数量为 0 时移除）；返回跟踪中的持仓
+func (ts *trailingStop) update(symbol, side string, entryPrice, quantity float64) *trailingPosition {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	key := symbol + "_" + side
+	if quantity <= 0 {
+		delete(ts.positions, key)
+		return nil
+	}
+	p, ok := ts.positions[key]
+	if !ok {
+		p = &trailingPosition{symbol: symbol, side: side}
+		ts.positions[key] = p
+	}
+	if entryPrice > 0 {
+		p.entryPrice = entryPrice
+	}
+	p.quantity = quantity
+	return p
+}
+
+// sync 以完整持仓快照为准，移除已不存在的持仓
+func (ts *trailingStop) sync(held map[string]bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for key := range ts.positions {
+		if !held[key] {
+			delete(ts.positions, key)
+		}
+	}
+}
+
+// bySymbol 该币种跟踪中的持仓
+func (ts *trailingStop) bySymbol(symbol string) []*trailingPosition {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	var result []*trailingPosition
+	for _, p := range ts.positions {
+		if p.symbol == symbol {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// startTrailingStopMonitor 启动保本/移动止损监控（未配置规则时不启动）
+func (at *AutoTrader) startTrailingStopMonitor() {
+	if !at.trailing.rules.Enabled() {
+		return
+	}
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+
+		var events <-chan PositionEvent
+		if s, ok := at.trader.(positionStreamer); ok {
+			ch, err := s.StreamPositions(at.stopMonitorCh)
+			if err != nil {
+				log.Printf("⚠️ 移动止损：订阅持仓推送失败，改为每 %v 轮询: %v", trailingPollInterval, err)
+			} else {
+				events = ch
+			}
+		}
+
+		ticker := time.NewTicker(trailingPollInterval)
+		defer ticker.Stop()
+		log.Printf("🪜 启动保本/移动止损监控（规则: %+v）", at.trailing.rules)
+
+		at.pollTrailingStops()
+		for {
+			select {
+			case <-ticker.C:
+				at.pollTrailingStops()
+			case ev, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				if ev.MarkOnly {
+					for _, p := range at.trailing.bySymbol(ev.Symbol) {
+						at.checkTrailingStop(p, ev.MarkPrice)
+					}
+					continue
+				}
+				if p := at.trailing.update(ev.Symbol, ev.Side, ev.EntryPrice, ev.Quantity); p != nil && ev.MarkPrice > 0 {
+					at.checkTrailingStop(p, ev.MarkPrice)
+				}
+			case <-at.stopMonitorCh:
+				log.Println("⏹ 停止保本/移动止损监控")
+				return
+			}
+		}
+	}()
+}
+
+// pollTrailingStops 按持仓快照检查所有持仓
+func (at *AutoTrader) pollTrailingStops() {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("❌ 移动止损：获取持仓失败: %v", err)
+		return
+	}
+	held := make(map[string]bool, len(positions))
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		entryPrice, _ := pos["entryPrice"].(float64)
+		markPrice, _ := pos["markPrice"].(float64)
+		quantity, _ := pos["positionAmt"].(float64)
+		held[symbol+"_"+side] = true
+		if p := at.trailing.update(symbol, side, entryPrice, math.Abs(quantity)); p != nil && markPrice > 0 {
+			at.checkTrailingStop(p, markPrice)
+		}
+	}
+	at.trailing.sync(held)
+}
+
+// checkTrailingStop 按最新价格检查单个持仓，需要时移动止损
+func (at *AutoTrader) checkTrailingStop(p *trailingPosition, price float64) {
+	rules := at.trailing.rules
+	at.trailing.mu.Lock()
+	if time.Since(p.lastMove) < trailingMinInterval {
+		at.trailing.mu.Unlock()
+		return
+	}
+	snapshot := *p
+	at.trailing.mu.Unlock()
+	if snapshot.stopLoss <= 0 {
+		return
+	}
+
+	var atr float64
+	if snapshot.initialRisk <= 0 || rules.TrailATRMultiplier > 0 {
+		data, err := market.Get(snapshot.symbol)
+		if err != nil {
+			return
+		}
+		atr = sizing.ATR(data)
+	}
+	if snapshot.initialRisk <= 0 {
+		// 不知道开仓时的止损：按开仓规则的 ATR 止损距离估算 R
+		mult := at.config.ATRStopMultiplier
+		if mult <= 0 {
+			mult = 2
+		}
+		snapshot.initialRisk = atr * mult
+		at.trailing.mu.Lock()
+		p.initialRisk = snapshot.initialRisk
+		at.trailing.mu.Unlock()
+	}
+
+	stop, reason := rules.nextStop(&snapshot, price, atr)
+	if stop <= 0 {
+		return
+	}
+	if r, ok := market.GetSymbolRules(snapshot.symbol); ok {
+		stop = r.RoundPrice(stop)
+	}
+	at.trailing.mu.Lock()
+	p.lastMove = time.Now()
+	at.trailing.mu.Unlock()
+
+	log.Printf("🪜 %s %s %s：止损 %.4f → %.4f", snapshot.symbol, snapshot.side, reason, snapshot.stopLoss, stop)
+	if err := at.moveStopLoss(snapshot.symbol, snapshot.side, stop, reason); err != nil {
+		log.Printf("❌ 移动止损失败 (%s %s): %v", snapshot.symbol, snapshot.side, err)
+	}
+}
+
+// moveStopLoss 以 update_stop_loss 决策执行止损调整（成功后更新跟踪的止损），并单独写入一条决策记录
+func (at *AutoTrader) moveStopLoss(symbol, side string, stop float64, reason string) error {
+	d := decision.Decision{
+		Symbol:       symbol,
+		Action:       "update_stop_loss",
+		NewStopLoss:  stop,
+		PositionSide: side,
+		Reasoning:    "自动移动止损: " + reason,
+	}
+	actionRecord := logger.DecisionAction{
+		Action:    d.Action,
+		Symbol:    symbol,
+		Timestamp: time.Now(),
+	}
+	decisionJSON, _ := json.Marshal([]decision.Decision{d})
+	record := &logger.DecisionRecord{
+		DecisionJSON: string(decisionJSON),
+		CoTTrace:     d.Reasoning,
+		ExecutionLog: []string{},
+		Success:      true,
+	}
+
+	at.orderMu.Lock()
+	err := at.executeUpdateStopLossWithRecord(&d, &actionRecord)
+	at.orderMu.Unlock()
+	if err != nil {
+		actionRecord.Error = err.Error()
+		record.Success = false
+		record.ErrorMessage = err.Error()
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 自动移动止损失败: %v", symbol, side, err))
+	} else {
+		actionRecord.Success = true
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 自动移动止损至 %.4f: %s", symbol, side, stop, reason))
+	}
+	record.Decisions = append(record.Decisions, actionRecord)
+	if logErr := at.decisionLogger.LogDecision(record); logErr != nil {
+		log.Printf("⚠ 保存决策记录失败: %v", logErr)
+	}
+	return err
+}
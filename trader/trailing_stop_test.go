@@ -0,0 +1,167 @@
+package trader
+
+import (
+	"math"
+	"nofx/logger"
+	"nofx/market"
+	"testing"
+	"time"
+)
+
+// memSnapshots 内存快照存储：通过回放让 market.Get 返回固定价格，不连接交易所
+type memSnapshots map[string][]market.Snapshot
+
+func (m memSnapshots) Save(s market.Snapshot) error {
+	m[s.Symbol] = append(m[s.Symbol], s)
+	return nil
+}
+
+func (m memSnapshots) Load(symbol string, from, to time.Time) ([]market.Snapshot, error) {
+	return m[symbol], nil
+}
+
+func (m memSnapshots) Close() error { return nil }
+
+// newTrailingTestTrader 以模拟盘为交易所、回放固定价格的 AutoTrader
+func newTrailingTestTrader(t *testing.T, symbol string, price float64, rules TrailingStopRules) (*AutoTrader, *PaperTrader) {
+	t.Helper()
+	t0 := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	store := memSnapshots{}
+	store.Save(market.Snapshot{Symbol: symbol, Time: t0, Data: &market.Data{Symbol: symbol, CurrentPrice: price}})
+	market.SetReplay(market.NewReplay(store, t0, time.Time{}))
+	t.Cleanup(func() { market.SetReplay(nil) })
+
+	paper := NewPaperTrader(100000)
+	paper.priceFunc = func(string) (float64, error) { return price, nil }
+	at := &AutoTrader{
+		trader:         paper,
+		trailing:       newTrailingStop(rules),
+		decisionLogger: logger.NewDecisionLogger(t.TempDir()),
+	}
+	return at, paper
+}
+
+// paperStop 模拟盘持仓当前的止损价
+func paperStop(t *testing.T, paper *PaperTrader, symbol, side string) float64 {
+	t.Helper()
+	p, ok := paper.positions[paperPositionKey(symbol, side)]
+	if !ok {
+		t.Fatalf("模拟盘没有 %s %s 持仓", symbol, side)
+	}
+	return p.stopLoss
+}
+
+func TestTrailingNextStop(t *testing.T) {
+	rules := TrailingStopRules{BreakEvenAtR: 1, BreakEvenOffsetPct: 0.1, TrailATRMultiplier: 2, TrailActivateR: 2}
+	tests := []struct {
+		name     string
+		pos      trailingPosition
+		price    float64
+		atr      float64
+		want     float64
+		wantMove bool
+	}{
+		{"浮盈不足 1R 不移动", trailingPosition{side: "long", entryPrice: 100, stopLoss: 95, initialRisk: 5}, 103, 1, 0, false},
+		{"多单 1.2R 移到保本", trailingPosition{side: "long", entryPrice: 100, stopLoss: 95, initialRisk: 5}, 106, 1, 100.1, true},
+		{"多单 2.4R 跟随 2×ATR", trailingPosition{side: "long", entryPrice: 100, stopLoss: 95, initialRisk: 5}, 112, 1, 110, true},
+		{"收紧幅度不足最小步长", trailingPosition{side: "long", entryPrice: 100, stopLoss: 109.95, initialRisk: 5}, 112, 1, 0, false},
+		{"新止损比当前止损宽不移动", trailingPosition{side: "long", entryPrice: 100, stopLoss: 111, initialRisk: 5}, 112, 1, 0, false},
+		{"当前止损未知不移动", trailingPosition{side: "long", entryPrice: 100, initialRisk: 5}, 112, 1, 0, false},
+		{"R 未知不移动", trailingPosition{side: "long", entryPrice: 100, stopLoss: 95}, 112, 1, 0, false},
+		{"空单 1.2R 移到保本", trailingPosition{side: "short", entryPrice: 100, stopLoss: 105, initialRisk: 5}, 94, 1, 99.9, true},
+		{"空单 2.4R 跟随 2×ATR", trailingPosition{side: "short", entryPrice: 100, stopLoss: 105, initialRisk: 5}, 88, 1, 90, true},
+		{"空单亏损不移动", trailingPosition{side: "short", entryPrice: 100, stopLoss: 105, initialRisk: 5}, 102, 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := rules.nextStop(&tt.pos, tt.price, tt.atr)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("nextStop = %.4f, want %.4f", got, tt.want)
+			}
+			if (reason != "") != tt.wantMove {
+				t.Errorf("reason = %q, 是否移动应为 %v", reason, tt.wantMove)
+			}
+		})
+	}
+}
+
+func TestCheckTrailingStopSkipsUnknownStop(t *testing.T) {
+	const symbol = "BTCUSDT"
+	rules := TrailingStopRules{BreakEvenAtR: 1}
+	at, paper := newTrailingTestTrader(t, symbol, 62500, rules)
+	if _, err := paper.OpenLong(symbol, 0.1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := paper.SetStopLoss(symbol, "LONG", 0.1, 58000); err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮询发现的持仓（如重启后）：不知道交易所上的止损，不能挂出可能更宽的止损
+	p := at.trailing.update(symbol, "long", 60000, 0.1)
+	p.initialRisk = 2000
+	at.checkTrailingStop(p, 62500)
+	if got := paperStop(t, paper, symbol, "long"); got != 58000 {
+		t.Fatalf("当前止损未知时不应移动止损, got %.2f", got)
+	}
+	if !p.lastMove.IsZero() {
+		t.Error("当前止损未知时不应记录移动时间")
+	}
+
+	// 记录了当前止损后正常移到保本
+	at.trailing.setStop(symbol, "long", 58000)
+	at.checkTrailingStop(p, 62500)
+	if got := paperStop(t, paper, symbol, "long"); got != 60000 {
+		t.Fatalf("浮盈 1.25R 应移到保本 60000, got %.2f", got)
+	}
+	if p.stopLoss != 60000 {
+		t.Errorf("跟踪的止损应更新为 60000, got %.2f", p.stopLoss)
+	}
+}
+
+func TestMoveStopLossHedgeMode(t *testing.T) {
+	const symbol = "BTCUSDT"
+	tests := []struct {
+		side      string
+		stop      float64
+		wantLong  float64
+		wantShort float64
+	}{
+		{"long", 59000, 59000, 62000},
+		{"short", 61000, 58000, 61000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.side, func(t *testing.T) {
+			at, paper := newTrailingTestTrader(t, symbol, 60000, TrailingStopRules{BreakEvenAtR: 1})
+			// 双向持仓：同一币种多空并存，各自挂有止损
+			if _, err := paper.OpenLong(symbol, 0.1, 10); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := paper.OpenShort(symbol, 0.1, 10); err != nil {
+				t.Fatal(err)
+			}
+			paper.SetStopLoss(symbol, "LONG", 0.1, 58000)
+			paper.SetStopLoss(symbol, "SHORT", 0.1, 62000)
+			at.trailing.track(symbol, "long", 60000, 0.1, 58000)
+			at.trailing.track(symbol, "short", 60000, 0.1, 62000)
+
+			if err := at.moveStopLoss(symbol, tt.side, tt.stop, "测试"); err != nil {
+				t.Fatal(err)
+			}
+			if got := paperStop(t, paper, symbol, "long"); got != tt.wantLong {
+				t.Errorf("多单止损 = %.2f, want %.2f", got, tt.wantLong)
+			}
+			if got := paperStop(t, paper, symbol, "short"); got != tt.wantShort {
+				t.Errorf("空单止损 = %.2f, want %.2f", got, tt.wantShort)
+			}
+			for _, side := range []string{"long", "short"} {
+				want := tt.wantLong
+				if side == "short" {
+					want = tt.wantShort
+				}
+				if got := at.trailing.positions[symbol+"_"+side].stopLoss; got != want {
+					t.Errorf("跟踪的%s止损 = %.2f, want %.2f", sideName(side), got, want)
+				}
+			}
+		})
+	}
+}
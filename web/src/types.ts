@@ -141,6 +141,15 @@ export interface CreateTraderRequest {
   use_oi_top?: boolean
   coin_pool_api_url?: string
   oi_top_api_url?: string
+  trailing_stop?: TrailingStopRules | null
+}
+
+// 保本/移动止损规则（0 表示不启用对应功能）
+export interface TrailingStopRules {
+  break_even_at_r: number
+  break_even_offset_pct: number
+  trail_atr_multiplier: number
+  trail_activate_r: number
 }
 
 export interface UpdateModelConfigRequest {
@@ -206,6 +215,7 @@ export interface TraderConfigData {
   use_oi_top: boolean
   coin_pool_api_url: string
   oi_top_api_url: string
+  trailing_stop?: TrailingStopRules | null
   initial_balance: number
   scan_interval_minutes: number
   is_running: boolean